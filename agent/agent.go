@@ -0,0 +1,201 @@
+// Package agent composes multiple named LLM participants into workflows
+// such as debates, critic/editor loops and router→specialist hand-offs.
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// Participant is anything that can take a turn in a multi-agent workflow
+type Participant interface {
+	// Name identifies the participant in the shared transcript
+	Name() string
+
+	// Respond produces the participant's next message given the shared history
+	Respond(ctx context.Context, history []simpleai.Message) (simpleai.Message, error)
+}
+
+// Config holds configuration for an Agent
+type Config struct {
+	Name        string
+	System      string // System prompt defining the agent's role
+	Model       string // Overrides the client's model (optional)
+	Temperature float64
+	MaxTokens   int
+
+	// Tools the agent may call during a turn
+	Tools []Tool
+
+	// MaxToolRounds limits tool-calling rounds per turn (default 8)
+	MaxToolRounds int
+
+	// ToolParallelism is how many tool calls of one round run at once
+	// (default 1, sequential). Calls needing approval always run alone.
+	ToolParallelism int
+
+	// Approver confirms calls to tools that require approval. When nil,
+	// such calls pause the agent with a *PendingApprovalError.
+	Approver Approver
+
+	// ToolPolicy decides whether a tool call may run, e.g. by tenant or
+	// role (see TagPolicy). Denied calls are reported to the model.
+	ToolPolicy ToolPolicy
+
+	// ToolCache stores results of cached and idempotent tools (default: in
+	// memory, per agent)
+	ToolCache ToolCache
+}
+
+// Agent is a named participant with its own system prompt and model settings,
+// backed by a simpleai.Client
+type Agent struct {
+	config    Config
+	client    *simpleai.Client
+	toolCache ToolCache
+}
+
+// New creates a new agent
+func New(client *simpleai.Client, config Config) *Agent {
+	toolCache := config.ToolCache
+	if toolCache == nil {
+		toolCache = NewMemoryToolCache()
+	}
+	return &Agent{
+		config:    config,
+		client:    client,
+		toolCache: toolCache,
+	}
+}
+
+// Name returns the agent name
+func (a *Agent) Name() string {
+	return a.config.Name
+}
+
+// System returns the agent's system prompt
+func (a *Agent) System() string {
+	return a.config.System
+}
+
+// Respond sends the shared history from this agent's point of view: its own
+// messages become assistant turns and everyone else's named user turns.
+// Tool calls are run until the model answers; a call needing approval may
+// pause the agent with a *PendingApprovalError (see Resume).
+func (a *Agent) Respond(ctx context.Context, history []simpleai.Message) (simpleai.Message, error) {
+	return a.loop(ctx, &LoopState{
+		System:   a.systemPrompt(history),
+		Messages: a.perspective(history),
+	})
+}
+
+// trimSpeaker removes a "Name:" prefix the model may copy from the transcript
+func trimSpeaker(content, name string) string {
+	return strings.TrimSpace(strings.TrimPrefix(content, name+":"))
+}
+
+// systemPrompt combines the agent's system prompt with system messages from
+// the shared history (e.g. a memory summary)
+func (a *Agent) systemPrompt(history []simpleai.Message) string {
+	parts := []string{}
+	if a.config.System != "" {
+		parts = append(parts, a.config.System)
+	}
+	for _, msg := range history {
+		if msg.Role == simpleai.RoleSystem {
+			parts = append(parts, msg.Content)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func (a *Agent) perspective(history []simpleai.Message) []simpleai.Message {
+	messages := make([]simpleai.Message, 0, len(history)+1)
+	for _, msg := range history {
+		switch {
+		case msg.Role == simpleai.RoleSystem:
+			continue
+		case msg.Name == a.config.Name && msg.Role == simpleai.RoleAssistant:
+			messages = append(messages, simpleai.Message{
+				Role:    simpleai.RoleAssistant,
+				Content: msg.Content,
+			})
+		default:
+			messages = append(messages, simpleai.Message{
+				Role:    simpleai.RoleUser,
+				Name:    msg.Name,
+				Content: msg.Content,
+			})
+		}
+	}
+
+	// A trailing assistant turn would be treated as a prefill
+	if len(messages) == 0 || messages[len(messages)-1].Role == simpleai.RoleAssistant {
+		messages = append(messages, simpleai.Message{
+			Role:    simpleai.RoleUser,
+			Content: "Continue.",
+		})
+	}
+	return messages
+}
+
+// chatParticipant adapts a simpleai.Chat, which keeps its own history
+type chatParticipant struct {
+	name string
+	chat *simpleai.Chat
+	mu   sync.Mutex
+	seen int // Number of shared history messages already sent to the chat
+}
+
+// FromChat adapts an existing Chat session as a participant. On each turn the
+// messages added to the shared history since its last turn are sent as one
+// user message.
+func FromChat(name string, chat *simpleai.Chat) Participant {
+	return &chatParticipant{name: name, chat: chat}
+}
+
+func (c *chatParticipant) Name() string {
+	return c.name
+}
+
+func (c *chatParticipant) Respond(ctx context.Context, history []simpleai.Message) (simpleai.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// History may have been trimmed by the shared memory
+	if c.seen > len(history) {
+		c.seen = 0
+	}
+
+	var sb strings.Builder
+	for _, msg := range history[c.seen:] {
+		if msg.Name == c.name || msg.Role == simpleai.RoleSystem {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n\n")
+		}
+		if msg.Name != "" {
+			sb.WriteString(msg.Name + ": ")
+		}
+		sb.WriteString(msg.Content)
+	}
+	if sb.Len() == 0 {
+		sb.WriteString("Continue.")
+	}
+
+	resp, err := c.chat.Send(ctx, sb.String())
+	if err != nil {
+		return simpleai.Message{}, err
+	}
+	c.seen = len(history)
+
+	return simpleai.Message{
+		Role:    simpleai.RoleAssistant,
+		Name:    c.name,
+		Content: resp.Content,
+	}, nil
+}
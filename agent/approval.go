@@ -0,0 +1,234 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	medahttp "github.com/medatechnology/goutil/http"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// ErrApprovalPending is returned by an Approver that cannot decide now.
+// The agent then stops with a *PendingApprovalError holding the state needed
+// to resume once a decision is made.
+var ErrApprovalPending = errors.New("agent: approval pending")
+
+// ApprovalRequest describes a tool call awaiting confirmation
+type ApprovalRequest struct {
+	ID        string    `json:"id"`
+	Agent     string    `json:"agent"`
+	Tool      string    `json:"tool"`
+	CallID    string    `json:"call_id"`
+	Arguments string    `json:"arguments"` // JSON-encoded arguments
+	Created   time.Time `json:"created"`
+}
+
+// Decision is the answer to an approval request
+type Decision struct {
+	Approved  bool   `json:"approved"`
+	Reason    string `json:"reason,omitempty"`    // Shown to the model when denied
+	Arguments string `json:"arguments,omitempty"` // Replaces the call's arguments when set
+}
+
+// Approver confirms tool calls before they run
+type Approver interface {
+	// Approve returns the decision for a call, or ErrApprovalPending to
+	// pause the agent until Agent.Resume is called
+	Approve(ctx context.Context, req ApprovalRequest) (Decision, error)
+}
+
+// ApproverFunc adapts a function (e.g. a CLI prompt) to an Approver
+type ApproverFunc func(ctx context.Context, req ApprovalRequest) (Decision, error)
+
+// Approve calls f
+func (f ApproverFunc) Approve(ctx context.Context, req ApprovalRequest) (Decision, error) {
+	return f(ctx, req)
+}
+
+// PauseApprover pauses on every call, for approvals that happen outside the
+// process (a review queue, a chat message). It is used when Config.Approver
+// is nil.
+var PauseApprover Approver = ApproverFunc(func(ctx context.Context, req ApprovalRequest) (Decision, error) {
+	return Decision{}, ErrApprovalPending
+})
+
+// PendingApproval is the serializable state of an agent paused for
+// approval. Store it (e.g. as JSON) and pass it to Agent.Resume with the
+// decision.
+type PendingApproval struct {
+	Request ApprovalRequest `json:"request"`
+	State   LoopState       `json:"state"`
+}
+
+// PendingApprovalError is returned when an agent pauses for approval
+type PendingApprovalError struct {
+	Pending *PendingApproval
+}
+
+func (e *PendingApprovalError) Error() string {
+	return fmt.Sprintf("agent %s: approval pending for tool %s (%s)",
+		e.Pending.Request.Agent, e.Pending.Request.Tool, e.Pending.Request.ID)
+}
+
+// Unwrap allows errors.Is(err, ErrApprovalPending)
+func (e *PendingApprovalError) Unwrap() error {
+	return ErrApprovalPending
+}
+
+// approve asks the approver about a call, converting a pending answer into
+// a *PendingApprovalError with a snapshot of the loop
+func (a *Agent) approve(ctx context.Context, l *LoopState, call simpleai.ToolCall) (Decision, error) {
+	req := ApprovalRequest{
+		ID:        fmt.Sprintf("%s-%s-%d", a.config.Name, call.ID, time.Now().UnixNano()),
+		Agent:     a.config.Name,
+		Tool:      call.Name,
+		CallID:    call.ID,
+		Arguments: call.Arguments,
+		Created:   time.Now(),
+	}
+
+	approver := a.config.Approver
+	if approver == nil {
+		approver = PauseApprover
+	}
+
+	decision, err := approver.Approve(ctx, req)
+	if errors.Is(err, ErrApprovalPending) {
+		state := *l
+		state.Messages = append([]simpleai.Message(nil), l.Messages...)
+		return Decision{}, &PendingApprovalError{
+			Pending: &PendingApproval{Request: req, State: state},
+		}
+	}
+	if err != nil {
+		return Decision{}, fmt.Errorf("approval for %s: %w", call.Name, err)
+	}
+	return decision, nil
+}
+
+// Resume continues an agent paused for approval, applying decision to the
+// pending call. It returns the agent's message as Respond would, and may
+// pause again on a later call.
+func (a *Agent) Resume(ctx context.Context, pending *PendingApproval, decision Decision) (simpleai.Message, error) {
+	l := pending.State
+	l.Messages = append([]simpleai.Message(nil), pending.State.Messages...)
+	if l.Next >= len(l.Calls) || l.Calls[l.Next].ID != pending.Request.CallID {
+		return simpleai.Message{}, fmt.Errorf("agent %s: pending approval does not match its state", a.config.Name)
+	}
+
+	call := l.Calls[l.Next]
+	if !decision.Approved {
+		l.Messages = append(l.Messages, toolResult(call, deniedResult(decision)))
+	} else {
+		if decision.Arguments != "" {
+			call.Arguments = decision.Arguments
+		}
+		tool, ok := a.tool(call.Name)
+		l.Messages = append(l.Messages, toolResult(call, a.execute(ctx, tool, ok, call)))
+	}
+	l.Next++
+
+	return a.loop(ctx, &l)
+}
+
+// ChannelApprover hands approval requests to another goroutine (e.g. a UI
+// or chat bot) and waits for Resolve
+type ChannelApprover struct {
+	requests chan ApprovalRequest
+	mu       sync.Mutex
+	waiting  map[string]chan Decision
+}
+
+// NewChannelApprover creates a channel approver
+func NewChannelApprover() *ChannelApprover {
+	return &ChannelApprover{
+		requests: make(chan ApprovalRequest),
+		waiting:  make(map[string]chan Decision),
+	}
+}
+
+// Requests returns the channel of calls awaiting a decision
+func (c *ChannelApprover) Requests() <-chan ApprovalRequest {
+	return c.requests
+}
+
+// Approve publishes the request and blocks until it is resolved or ctx ends
+func (c *ChannelApprover) Approve(ctx context.Context, req ApprovalRequest) (Decision, error) {
+	ch := make(chan Decision, 1)
+	c.mu.Lock()
+	c.waiting[req.ID] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.waiting, req.ID)
+		c.mu.Unlock()
+	}()
+
+	select {
+	case c.requests <- req:
+	case <-ctx.Done():
+		return Decision{}, ctx.Err()
+	}
+
+	select {
+	case d := <-ch:
+		return d, nil
+	case <-ctx.Done():
+		return Decision{}, ctx.Err()
+	}
+}
+
+// Resolve delivers the decision for a request
+func (c *ChannelApprover) Resolve(id string, decision Decision) error {
+	c.mu.Lock()
+	ch, ok := c.waiting[id]
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("agent: no approval request %q", id)
+	}
+	ch <- decision
+	return nil
+}
+
+// WebhookApprover posts approval requests to an HTTP endpoint. A 200
+// response with a Decision body decides immediately; 202 Accepted pauses
+// the agent until the endpoint's owner calls Agent.Resume.
+type WebhookApprover struct {
+	url    string
+	client medahttp.HttpClient
+}
+
+// NewWebhookApprover creates a webhook approver; headers (e.g.
+// Authorization) are sent with every request
+func NewWebhookApprover(url string, headers map[string][]string) *WebhookApprover {
+	all := map[string][]string{"Content-Type": {"application/json"}}
+	for k, v := range headers {
+		all[k] = v
+	}
+
+	client := medahttp.NewHttp()
+	client.SetHeader(all)
+	return &WebhookApprover{url: url, client: client}
+}
+
+// Approve posts the request and reads the decision
+func (w *WebhookApprover) Approve(ctx context.Context, req ApprovalRequest) (Decision, error) {
+	var decision Decision
+	statusCode, err := w.client.Post(w.url, req, &decision, nil)
+	if statusCode == 202 {
+		// The body, if any, is irrelevant
+		return Decision{}, ErrApprovalPending
+	}
+	if err != nil {
+		return Decision{}, fmt.Errorf("webhook request failed: %w", err)
+	}
+
+	if statusCode != 200 {
+		return Decision{}, fmt.Errorf("webhook returned status %d", statusCode)
+	}
+	return decision, nil
+}
@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// defaultIdempotencyTTL is how long idempotency keys are remembered when
+// Tool.IdempotencyTTL is not set
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// ToolCache stores tool results for caching and idempotency. Implement it
+// with a shared store (e.g. Redis) to deduplicate calls across processes.
+type ToolCache interface {
+	Get(key string) (string, bool)
+	Set(key, result string, ttl time.Duration)
+}
+
+// MemoryToolCache keeps tool results in memory
+type MemoryToolCache struct {
+	mu      sync.Mutex
+	entries map[string]toolCacheEntry
+}
+
+type toolCacheEntry struct {
+	result  string
+	expires time.Time
+}
+
+// NewMemoryToolCache creates an in-memory tool cache
+func NewMemoryToolCache() *MemoryToolCache {
+	return &MemoryToolCache{entries: make(map[string]toolCacheEntry)}
+}
+
+// Get returns an unexpired result
+func (c *MemoryToolCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return "", false
+	}
+	return entry.result, true
+}
+
+// Set stores a result for ttl, dropping expired entries on the way
+func (c *MemoryToolCache) Set(key, result string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = toolCacheEntry{result: result, expires: now.Add(ttl)}
+}
+
+type idempotencyKeyContextKey struct{}
+
+// IdempotencyKey returns the idempotency key of the running call of an
+// idempotent tool, e.g. to forward to a payments API
+func IdempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyContextKey{}).(string)
+	return key
+}
+
+// cacheKey returns the key a call's result is cached under, or "" when the
+// tool is neither cached nor idempotent. Cached results are scoped to the
+// user and idempotency keys to the user and session, from the request
+// metadata.
+func (a *Agent) cacheKey(ctx context.Context, tool Tool, call simpleai.ToolCall) string {
+	md, _ := simpleai.RequestMetadataFromContext(ctx)
+	switch {
+	case tool.Idempotent && tool.IdempotencyKey != nil:
+		return "idem:" + tool.Name + ":" + tool.IdempotencyKey(ctx, json.RawMessage(call.Arguments))
+	case tool.Idempotent:
+		return "idem:" + hashKey(tool.Name, canonicalArgs(call.Arguments), md.UserID, md.SessionID)
+	case tool.CacheTTL > 0:
+		return "cache:" + hashKey(tool.Name, canonicalArgs(call.Arguments), md.UserID)
+	}
+	return ""
+}
+
+// cachedResult returns the stored result of an identical earlier call
+func (a *Agent) cachedResult(ctx context.Context, tool Tool, call simpleai.ToolCall) (string, bool) {
+	key := a.cacheKey(ctx, tool, call)
+	if key == "" {
+		return "", false
+	}
+	return a.toolCache.Get(key)
+}
+
+// storeResult remembers a successful result for caching or idempotency
+func (a *Agent) storeResult(ctx context.Context, tool Tool, call simpleai.ToolCall, result string) {
+	key := a.cacheKey(ctx, tool, call)
+	if key == "" {
+		return
+	}
+	ttl := tool.CacheTTL
+	if tool.Idempotent {
+		ttl = tool.IdempotencyTTL
+		if ttl <= 0 {
+			ttl = defaultIdempotencyTTL
+		}
+	}
+	a.toolCache.Set(key, result, ttl)
+}
+
+// canonicalArgs re-encodes JSON arguments with sorted keys so calls that
+// differ only in key order or spacing match
+func canonicalArgs(args string) string {
+	var v any
+	if err := json.Unmarshal([]byte(args), &v); err != nil {
+		return args
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func hashKey(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,307 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// ErrMaxToolRounds is returned when the model keeps calling tools beyond
+// Config.MaxToolRounds
+var ErrMaxToolRounds = errors.New("agent: tool round limit reached")
+
+// ToolFunc executes a tool call with the model's JSON arguments and returns
+// the result shown to the model
+type ToolFunc func(ctx context.Context, args json.RawMessage) (string, error)
+
+// Tool is a function an agent can call
+type Tool struct {
+	simpleai.Tool
+	Run ToolFunc
+
+	// RequiresApproval pauses before every call until Config.Approver
+	// confirms it. Use it for tools with real-world side effects.
+	RequiresApproval bool
+
+	// CacheTTL reuses the result of an identical call (same arguments and
+	// user) for this long. Use it for read-only tools.
+	CacheTTL time.Duration
+
+	// Idempotent runs a side-effecting tool at most once per idempotency
+	// key; repeated calls get the first result. The key defaults to the
+	// arguments, user and session; Run can read it with IdempotencyKey.
+	Idempotent bool
+	// IdempotencyKey derives the key from the call instead
+	IdempotencyKey func(ctx context.Context, args json.RawMessage) string
+	// IdempotencyTTL is how long keys are remembered (default 24h)
+	IdempotencyTTL time.Duration
+
+	// Policy decides whether a call may run, after Config.ToolPolicy
+	Policy ToolPolicy
+
+	// SkipValidation passes arguments to Run without checking them against
+	// Parameters
+	SkipValidation bool
+}
+
+// NewTool creates a tool; parameters is the JSON schema of the arguments
+func NewTool(name, description string, parameters map[string]any, run ToolFunc) Tool {
+	return Tool{
+		Tool: simpleai.Tool{
+			Name:        name,
+			Description: description,
+			Parameters:  parameters,
+		},
+		Run: run,
+	}
+}
+
+// WithApproval returns a copy of the tool that requires approval
+func (t Tool) WithApproval() Tool {
+	t.RequiresApproval = true
+	return t
+}
+
+// WithCache returns a copy of the tool whose results are reused for ttl
+func (t Tool) WithCache(ttl time.Duration) Tool {
+	t.CacheTTL = ttl
+	return t
+}
+
+// WithIdempotency returns a copy of the tool that runs at most once per
+// idempotency key
+func (t Tool) WithIdempotency() Tool {
+	t.Idempotent = true
+	return t
+}
+
+// LoopState is the state of a tool-calling exchange, kept in
+// PendingApproval so a paused exchange can be resumed
+type LoopState struct {
+	System   string              `json:"system,omitempty"`
+	Messages []simpleai.Message  `json:"messages"`
+	Calls    []simpleai.ToolCall `json:"calls"` // Calls of the current round
+	Next     int                 `json:"next"`  // Index of the next call to run
+	Round    int                 `json:"round"`
+}
+
+func (a *Agent) tool(name string) (Tool, bool) {
+	for _, t := range a.config.Tools {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Tool{}, false
+}
+
+func (a *Agent) toolDefinitions() []simpleai.Tool {
+	if len(a.config.Tools) == 0 {
+		return nil
+	}
+	tools := make([]simpleai.Tool, len(a.config.Tools))
+	for i, t := range a.config.Tools {
+		tools[i] = t.Tool
+	}
+	return tools
+}
+
+// loop completes the conversation, running requested tools and sending
+// their results back until the model answers without tool calls
+func (a *Agent) loop(ctx context.Context, l *LoopState) (simpleai.Message, error) {
+	maxRounds := a.config.MaxToolRounds
+	if maxRounds <= 0 {
+		maxRounds = 8
+	}
+
+	for {
+		// Finish the calls of the current round
+		if err := a.runCalls(ctx, l); err != nil {
+			return simpleai.Message{}, err
+		}
+
+		resp, err := a.client.Complete(ctx, &simpleai.Request{
+			Messages:     l.Messages,
+			SystemPrompt: l.System,
+			Model:        a.config.Model,
+			Temperature:  a.config.Temperature,
+			MaxTokens:    a.config.MaxTokens,
+			Tools:        a.toolDefinitions(),
+		})
+		if err != nil {
+			return simpleai.Message{}, err
+		}
+
+		if len(resp.ToolCalls) == 0 || len(a.config.Tools) == 0 {
+			return simpleai.Message{
+				Role:    simpleai.RoleAssistant,
+				Name:    a.config.Name,
+				Content: trimSpeaker(resp.Content, a.config.Name),
+			}, nil
+		}
+
+		if l.Round >= maxRounds {
+			return simpleai.Message{}, ErrMaxToolRounds
+		}
+		l.Round++
+		l.Messages = append(l.Messages, simpleai.Message{
+			Role:      simpleai.RoleAssistant,
+			Content:   resp.Content,
+			ToolCalls: resp.ToolCalls,
+		})
+		l.Calls = resp.ToolCalls
+		l.Next = 0
+	}
+}
+
+// runCalls runs the remaining calls of the current round, asking for
+// approval where required. Consecutive calls that need no approval run
+// concurrently up to Config.ToolParallelism; results keep the call order.
+func (a *Agent) runCalls(ctx context.Context, l *LoopState) error {
+	for l.Next < len(l.Calls) {
+		call := l.Calls[l.Next]
+
+		tool, ok := a.tool(call.Name)
+		if !ok || !tool.RequiresApproval {
+			end := l.Next + 1
+			for end < len(l.Calls) {
+				if next, ok := a.tool(l.Calls[end].Name); ok && next.RequiresApproval {
+					break
+				}
+				end++
+			}
+			l.Messages = append(l.Messages, a.executeAll(ctx, l.Calls[l.Next:end])...)
+			l.Next = end
+			continue
+		}
+
+		if err := a.checkCall(ctx, tool, &call); err != nil {
+			l.Messages = append(l.Messages, toolResult(call, "Error: "+err.Error()))
+			l.Next++
+			continue
+		}
+		if result, cached := a.cachedResult(ctx, tool, call); cached {
+			l.Messages = append(l.Messages, toolResult(call, result))
+			l.Next++
+			continue
+		}
+		decision, err := a.approve(ctx, l, call)
+		if err != nil {
+			return err
+		}
+		if !decision.Approved {
+			l.Messages = append(l.Messages, toolResult(call, deniedResult(decision)))
+			l.Next++
+			continue
+		}
+		if decision.Arguments != "" {
+			call.Arguments = decision.Arguments
+		}
+		l.Messages = append(l.Messages, toolResult(call, a.execute(ctx, tool, ok, call)))
+		l.Next++
+	}
+	return nil
+}
+
+// executeAll runs calls that need no approval, concurrently when
+// Config.ToolParallelism allows, and returns their results in order.
+// Identical calls to an idempotent tool run once and share the result.
+func (a *Agent) executeAll(ctx context.Context, calls []simpleai.ToolCall) []simpleai.Message {
+	results := make([]simpleai.Message, len(calls))
+	run := func(i int) {
+		tool, ok := a.tool(calls[i].Name)
+		results[i] = toolResult(calls[i], a.execute(ctx, tool, ok, calls[i]))
+	}
+
+	// same[i] is the call whose result call i reuses
+	same := make([]int, len(calls))
+	seen := make(map[string]int)
+	var unique []int
+	for i, call := range calls {
+		same[i] = i
+		if tool, ok := a.tool(call.Name); ok && tool.Idempotent {
+			key := a.cacheKey(ctx, tool, call)
+			if j, dup := seen[key]; dup {
+				same[i] = j
+				continue
+			}
+			seen[key] = i
+		}
+		unique = append(unique, i)
+	}
+
+	parallelism := min(max(a.config.ToolParallelism, 1), len(unique))
+	if parallelism == 1 {
+		for _, i := range unique {
+			run(i)
+		}
+	} else {
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, parallelism)
+		for _, i := range unique {
+			wg.Add(1)
+			slots <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				run(i)
+			}()
+		}
+		wg.Wait()
+	}
+
+	for i, j := range same {
+		if i != j {
+			results[i] = toolResult(calls[i], results[j].Content)
+		}
+	}
+	return results
+}
+
+// execute runs a call; errors are reported to the model so it can recover
+func (a *Agent) execute(ctx context.Context, tool Tool, ok bool, call simpleai.ToolCall) string {
+	if !ok || tool.Run == nil {
+		return fmt.Sprintf("Error: unknown tool %q", call.Name)
+	}
+	if err := a.checkCall(ctx, tool, &call); err != nil {
+		return "Error: " + err.Error()
+	}
+	args := call.Arguments
+	if args == "" {
+		args = "{}"
+	}
+	if result, cached := a.cachedResult(ctx, tool, call); cached {
+		return result // Approved arguments matched an earlier call
+	}
+
+	runCtx := ctx
+	if tool.Idempotent {
+		runCtx = context.WithValue(ctx, idempotencyKeyContextKey{}, a.cacheKey(ctx, tool, call))
+	}
+	result, err := tool.Run(runCtx, json.RawMessage(args))
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+	a.storeResult(ctx, tool, call, result)
+	return result
+}
+
+func toolResult(call simpleai.ToolCall, content string) simpleai.Message {
+	return simpleai.Message{
+		Role:       simpleai.RoleTool,
+		Name:       call.Name,
+		ToolCallID: call.ID,
+		Content:    content,
+	}
+}
+
+func deniedResult(d Decision) string {
+	if d.Reason == "" {
+		return "The tool call was not approved."
+	}
+	return "The tool call was not approved: " + d.Reason
+}
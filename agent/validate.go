@@ -0,0 +1,281 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// ErrToolDenied is returned by policies that reject a call
+var ErrToolDenied = errors.New("agent: tool call denied")
+
+// ArgumentError lists what is wrong with a tool call's arguments
+type ArgumentError struct {
+	Tool     string
+	Problems []string
+}
+
+func (e *ArgumentError) Error() string {
+	return fmt.Sprintf("agent: invalid arguments for %s: %s", e.Tool, strings.Join(e.Problems, "; "))
+}
+
+// ToolPolicy decides whether a call may run. A non-nil error denies it and
+// is reported to the model.
+type ToolPolicy func(ctx context.Context, tool Tool, args json.RawMessage) error
+
+// AllowTools permits only the named tools
+func AllowTools(names ...string) ToolPolicy {
+	return func(ctx context.Context, tool Tool, args json.RawMessage) error {
+		if slices.Contains(names, tool.Name) {
+			return nil
+		}
+		return fmt.Errorf("%w: %s is not allowed", ErrToolDenied, tool.Name)
+	}
+}
+
+// DenyTools rejects the named tools
+func DenyTools(names ...string) ToolPolicy {
+	return func(ctx context.Context, tool Tool, args json.RawMessage) error {
+		if slices.Contains(names, tool.Name) {
+			return fmt.Errorf("%w: %s is not allowed", ErrToolDenied, tool.Name)
+		}
+		return nil
+	}
+}
+
+// TagPolicy permits tools by the value of a request metadata tag, such as a
+// tenant or user role. "*" allows every tool; callers without the tag or
+// with an unlisted value are denied.
+//
+//	agent.TagPolicy("role", map[string][]string{
+//		"admin":  {"*"},
+//		"viewer": {"lookup_order"},
+//	})
+func TagPolicy(tag string, allowed map[string][]string) ToolPolicy {
+	return func(ctx context.Context, tool Tool, args json.RawMessage) error {
+		md, _ := simpleai.RequestMetadataFromContext(ctx)
+		names := allowed[md.Tags[tag]]
+		if slices.Contains(names, "*") || slices.Contains(names, tool.Name) {
+			return nil
+		}
+		return fmt.Errorf("%w: %s is not allowed for %s %q", ErrToolDenied, tool.Name, tag, md.Tags[tag])
+	}
+}
+
+// Policies combines policies; every one must allow a call
+func Policies(policies ...ToolPolicy) ToolPolicy {
+	return func(ctx context.Context, tool Tool, args json.RawMessage) error {
+		for _, policy := range policies {
+			if err := policy(ctx, tool, args); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// checkCall validates and coerces a call's arguments and applies the
+// policies, so rejected calls never reach the tool
+func (a *Agent) checkCall(ctx context.Context, tool Tool, call *simpleai.ToolCall) error {
+	if !tool.SkipValidation && tool.Parameters != nil {
+		args, err := ValidateArgs(tool.Parameters, json.RawMessage(call.Arguments))
+		if err != nil {
+			var argErr *ArgumentError
+			if errors.As(err, &argErr) {
+				argErr.Tool = tool.Name
+			}
+			return err
+		}
+		call.Arguments = string(args)
+	}
+
+	for _, policy := range []ToolPolicy{a.config.ToolPolicy, tool.Policy} {
+		if policy == nil {
+			continue
+		}
+		if err := policy(ctx, tool, json.RawMessage(call.Arguments)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateArgs checks JSON arguments against a JSON schema and returns them
+// with common mismatches coerced: numbers and booleans sent as strings,
+// scalars where a string is expected, arrays and objects sent as JSON
+// strings, and null for optional properties. Other problems are returned
+// as an *ArgumentError.
+func ValidateArgs(schema map[string]any, args json.RawMessage) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(args)) == 0 {
+		args = json.RawMessage("{}")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(args))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, &ArgumentError{Problems: []string{"arguments are not valid JSON"}}
+	}
+
+	var problems []string
+	value = coerce(schema, value, "arguments", &problems)
+	if len(problems) > 0 {
+		return nil, &ArgumentError{Problems: problems}
+	}
+	return json.Marshal(value)
+}
+
+// coerce validates value against schema, recording problems under path,
+// and returns the coerced value
+func coerce(schema map[string]any, value any, path string, problems *[]string) any {
+	typ, _ := schema["type"].(string)
+	switch typ {
+	case "object":
+		if s, ok := value.(string); ok {
+			value = decodeJSON(s, value)
+		}
+		obj, ok := value.(map[string]any)
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s must be an object", path))
+			return value
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		required := stringList(schema["required"])
+		for _, name := range required {
+			if v, ok := obj[name]; !ok || v == nil {
+				*problems = append(*problems, fmt.Sprintf("%s is required", joinPath(path, name)))
+			}
+		}
+		for name, v := range obj {
+			prop, known := properties[name].(map[string]any)
+			switch {
+			case v == nil && !slices.Contains(required, name):
+				delete(obj, name)
+			case known:
+				obj[name] = coerce(prop, v, joinPath(path, name), problems)
+			case schema["additionalProperties"] == false:
+				*problems = append(*problems, fmt.Sprintf("%s is not a known property", joinPath(path, name)))
+			}
+		}
+		return obj
+
+	case "array":
+		if s, ok := value.(string); ok {
+			value = decodeJSON(s, value)
+		}
+		list, ok := value.([]any)
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s must be an array", path))
+			return value
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, v := range list {
+				list[i] = coerce(items, v, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+		return list
+
+	case "string":
+		switch v := value.(type) {
+		case json.Number:
+			value = v.String()
+		case bool:
+			value = strconv.FormatBool(v)
+		case string:
+		default:
+			*problems = append(*problems, fmt.Sprintf("%s must be a string", path))
+			return value
+		}
+
+	case "number", "integer":
+		n, ok := value.(json.Number)
+		if s, isString := value.(string); isString {
+			n, ok = json.Number(strings.TrimSpace(s)), true
+		}
+		f, err := n.Float64()
+		if !ok || err != nil {
+			*problems = append(*problems, fmt.Sprintf("%s must be a %s", path, typ))
+			return value
+		}
+		value = n
+		if _, err := n.Int64(); typ == "integer" && err != nil {
+			if f != math.Trunc(f) {
+				*problems = append(*problems, fmt.Sprintf("%s must be an integer", path))
+				return value
+			}
+			if math.Abs(f) < 1<<63 {
+				value = json.Number(strconv.FormatInt(int64(f), 10)) // 1.0 or 1e3
+			}
+		}
+
+	case "boolean":
+		if s, ok := value.(string); ok {
+			if b, err := strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+				value = b
+			}
+		}
+		if _, ok := value.(bool); !ok {
+			*problems = append(*problems, fmt.Sprintf("%s must be a boolean", path))
+			return value
+		}
+	}
+
+	if enum := reflect.ValueOf(schema["enum"]); enum.Kind() == reflect.Slice && !inEnum(enum, value) {
+		*problems = append(*problems, fmt.Sprintf("%s must be one of %v", path, schema["enum"]))
+	}
+	return value
+}
+
+// decodeJSON parses a JSON array or object sent as a string, or returns
+// fallback
+func decodeJSON(s string, fallback any) any {
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return fallback
+	}
+	return v
+}
+
+// inEnum compares by printed value, so 1 matches json.Number("1")
+func inEnum(enum reflect.Value, value any) bool {
+	for i := range enum.Len() {
+		if fmt.Sprint(enum.Index(i).Interface()) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// stringList reads a []string or a decoded []any of strings
+func stringList(v any) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []any:
+		var names []string
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				names = append(names, s)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+func joinPath(path, name string) string {
+	if path == "arguments" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,361 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/memory"
+)
+
+// Stop reasons reported in Result
+const (
+	StopMaxTurns  = "max_turns"
+	StopCondition = "stop_condition"
+	StopApproved  = "approved"
+	StopVerdict   = "verdict"
+	StopRounds    = "rounds"
+	StopRouted    = "routed"
+)
+
+// Common errors
+var (
+	ErrNoParticipants = errors.New("agent: no participants")
+	ErrNoRoute        = errors.New("agent: router did not select a specialist")
+)
+
+// RunConfig holds options shared by all workflows
+type RunConfig struct {
+	// MaxTurns limits the total number of turns (default 10)
+	MaxTurns int
+
+	// Memory is the shared memory all participants read from and write to.
+	// Defaults to an in-memory store.
+	Memory memory.Memory
+
+	// ContextTokens limits how much shared history each participant sees
+	// (0 uses the memory's default)
+	ContextTokens int
+
+	// Stop ends the workflow early when it returns true for a turn
+	Stop func(turn Turn) bool
+
+	// OnTurn is called after every turn, e.g. for streaming progress to a UI
+	OnTurn func(turn Turn)
+}
+
+// DefaultRunConfig returns sensible defaults
+func DefaultRunConfig() RunConfig {
+	return RunConfig{
+		MaxTurns: 10,
+	}
+}
+
+// Turn is one participant's contribution
+type Turn struct {
+	Index   int
+	Speaker string
+	Message simpleai.Message
+}
+
+// Result is the outcome of a workflow
+type Result struct {
+	Turns      []Turn
+	Final      simpleai.Message // The workflow's answer (last turn, verdict or approved draft)
+	StopReason string
+	Route      string // Specialist chosen by Route
+}
+
+// run tracks the shared state of a workflow
+type run struct {
+	config RunConfig
+	result *Result
+}
+
+func newRun(config RunConfig) *run {
+	if config.MaxTurns <= 0 {
+		config.MaxTurns = DefaultRunConfig().MaxTurns
+	}
+	if config.Memory == nil {
+		config.Memory = memory.NewSimple(memory.MemoryConfig{
+			MaxTokens: 1 << 20, // The turn limit bounds the transcript
+		})
+	}
+	return &run{config: config, result: &Result{}}
+}
+
+// post adds a message to the shared memory
+func (r *run) post(ctx context.Context, msg simpleai.Message) error {
+	return r.config.Memory.Add(ctx, msg)
+}
+
+// exhausted reports whether the turn limit has been reached
+func (r *run) exhausted() bool {
+	return len(r.result.Turns) >= r.config.MaxTurns
+}
+
+// turn lets p respond to the shared history and records the result.
+// It returns true when the stop condition matched.
+func (r *run) turn(ctx context.Context, p Participant) (simpleai.Message, bool, error) {
+	history, err := r.config.Memory.GetMessages(ctx, r.config.ContextTokens)
+	if err != nil {
+		return simpleai.Message{}, false, err
+	}
+
+	msg, err := p.Respond(ctx, history)
+	if err != nil {
+		return simpleai.Message{}, false, fmt.Errorf("agent %s: %w", p.Name(), err)
+	}
+	msg.Name = p.Name()
+
+	if err := r.post(ctx, msg); err != nil {
+		return simpleai.Message{}, false, err
+	}
+
+	t := Turn{Index: len(r.result.Turns), Speaker: p.Name(), Message: msg}
+	r.result.Turns = append(r.result.Turns, t)
+	r.result.Final = msg
+	if r.config.OnTurn != nil {
+		r.config.OnTurn(t)
+	}
+
+	return msg, r.config.Stop != nil && r.config.Stop(t), nil
+}
+
+// RoundRobin lets participants speak in order, starting from input, until
+// the turn limit or the stop condition is reached
+func RoundRobin(ctx context.Context, input string, participants []Participant, config RunConfig) (*Result, error) {
+	if len(participants) == 0 {
+		return nil, ErrNoParticipants
+	}
+
+	r := newRun(config)
+	if err := r.post(ctx, simpleai.Message{Role: simpleai.RoleUser, Content: input}); err != nil {
+		return nil, err
+	}
+
+	for i := 0; !r.exhausted(); i++ {
+		_, stop, err := r.turn(ctx, participants[i%len(participants)])
+		if err != nil {
+			return r.result, err
+		}
+		if stop {
+			r.result.StopReason = StopCondition
+			return r.result, nil
+		}
+	}
+
+	r.result.StopReason = StopMaxTurns
+	return r.result, nil
+}
+
+// DebateConfig configures a debate
+type DebateConfig struct {
+	RunConfig
+
+	// Rounds is the number of times every debater speaks (default 2)
+	Rounds int
+
+	// Judge, if set, reads the debate and gives the final verdict
+	Judge Participant
+}
+
+// Debate has the debaters argue about topic for a number of rounds, then
+// lets the judge (if any) decide. The turn limit applies to all turns,
+// including the verdict.
+func Debate(ctx context.Context, topic string, debaters []Participant, config DebateConfig) (*Result, error) {
+	if len(debaters) == 0 {
+		return nil, ErrNoParticipants
+	}
+	if config.Rounds <= 0 {
+		config.Rounds = 2
+	}
+
+	r := newRun(config.RunConfig)
+	if err := r.post(ctx, simpleai.Message{
+		Role:    simpleai.RoleUser,
+		Content: "Debate topic: " + topic,
+	}); err != nil {
+		return nil, err
+	}
+
+	for round := 0; round < config.Rounds; round++ {
+		for _, d := range debaters {
+			// Keep a turn in reserve for the judge
+			if r.exhausted() || (config.Judge != nil && len(r.result.Turns) >= r.config.MaxTurns-1) {
+				break
+			}
+			_, stop, err := r.turn(ctx, d)
+			if err != nil {
+				return r.result, err
+			}
+			if stop {
+				r.result.StopReason = StopCondition
+				return r.result, nil
+			}
+		}
+	}
+
+	if config.Judge == nil {
+		r.result.StopReason = StopRounds
+		if r.exhausted() {
+			r.result.StopReason = StopMaxTurns
+		}
+		return r.result, nil
+	}
+
+	if err := r.post(ctx, simpleai.Message{
+		Role:    simpleai.RoleUser,
+		Content: "The debate is over. Weigh the arguments and give your verdict.",
+	}); err != nil {
+		return r.result, err
+	}
+	if _, _, err := r.turn(ctx, config.Judge); err != nil {
+		return r.result, err
+	}
+
+	r.result.StopReason = StopVerdict
+	return r.result, nil
+}
+
+// RefineConfig configures a critic/editor loop
+type RefineConfig struct {
+	RunConfig
+
+	// Approved reports whether the critique accepts the draft.
+	// Defaults to the critique containing "APPROVED".
+	Approved func(critique string) bool
+}
+
+// Refine has the writer draft a response to task and the critic review it,
+// with the writer revising until the critic approves or the turn limit is
+// reached. Result.Final is the last draft.
+func Refine(ctx context.Context, task string, writer, critic Participant, config RefineConfig) (*Result, error) {
+	if config.Approved == nil {
+		config.Approved = func(critique string) bool {
+			return strings.Contains(strings.ToUpper(critique), "APPROVED")
+		}
+	}
+
+	r := newRun(config.RunConfig)
+	if err := r.post(ctx, simpleai.Message{Role: simpleai.RoleUser, Content: task}); err != nil {
+		return nil, err
+	}
+
+	var draft simpleai.Message
+	for !r.exhausted() {
+		msg, stop, err := r.turn(ctx, writer)
+		if err != nil {
+			return r.result, err
+		}
+		draft = msg
+		if stop {
+			r.result.StopReason = StopCondition
+			break
+		}
+		if r.exhausted() {
+			r.result.StopReason = StopMaxTurns
+			break
+		}
+
+		critique, stop, err := r.turn(ctx, critic)
+		if err != nil {
+			return r.result, err
+		}
+		if config.Approved(critique.Content) {
+			r.result.StopReason = StopApproved
+			break
+		}
+		if stop {
+			r.result.StopReason = StopCondition
+			break
+		}
+	}
+
+	if r.result.StopReason == "" {
+		r.result.StopReason = StopMaxTurns
+	}
+	r.result.Final = draft
+	return r.result, nil
+}
+
+// Specialist is a participant that a router can hand off to
+type Specialist struct {
+	Participant
+	Description string // What the specialist handles, shown to the router
+}
+
+// RouteConfig configures a router→specialist hand-off
+type RouteConfig struct {
+	RunConfig
+
+	// Fallback is the specialist used when the router's choice is not
+	// recognized (default: return ErrNoRoute)
+	Fallback string
+}
+
+// Route asks router to pick the best specialist for input, then lets that
+// specialist answer. The router only needs to reply with a specialist name.
+func Route(ctx context.Context, input string, router *Agent, specialists []Specialist, config RouteConfig) (*Result, error) {
+	if len(specialists) == 0 {
+		return nil, ErrNoParticipants
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Choose the specialist best suited to handle the user's message. Reply with only the specialist's name.\n\nSpecialists:\n")
+	for _, s := range specialists {
+		sb.WriteString("- " + s.Name() + ": " + s.Description + "\n")
+	}
+
+	routerConfig := router.config
+	routerConfig.System = strings.TrimSpace(router.config.System + "\n\n" + sb.String())
+	r := newRun(config.RunConfig)
+
+	choice, err := New(router.client, routerConfig).Respond(ctx, []simpleai.Message{
+		{Role: simpleai.RoleUser, Content: input},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("agent %s: %w", router.Name(), err)
+	}
+	r.result.Turns = append(r.result.Turns, Turn{Speaker: router.Name(), Message: choice})
+
+	specialist := matchSpecialist(choice.Content, specialists, config.Fallback)
+	if specialist == nil {
+		return r.result, ErrNoRoute
+	}
+	r.result.Route = specialist.Name()
+
+	if err := r.post(ctx, simpleai.Message{Role: simpleai.RoleUser, Content: input}); err != nil {
+		return r.result, err
+	}
+	if _, _, err := r.turn(ctx, specialist); err != nil {
+		return r.result, err
+	}
+
+	r.result.StopReason = StopRouted
+	return r.result, nil
+}
+
+// matchSpecialist finds the specialist named in the router's reply,
+// preferring an exact match over a mention
+func matchSpecialist(reply string, specialists []Specialist, fallback string) Participant {
+	reply = strings.ToLower(strings.Trim(strings.TrimSpace(reply), ".\"'`*"))
+	for _, s := range specialists {
+		if strings.ToLower(s.Name()) == reply {
+			return s.Participant
+		}
+	}
+	for _, s := range specialists {
+		if strings.Contains(reply, strings.ToLower(s.Name())) {
+			return s.Participant
+		}
+	}
+	for _, s := range specialists {
+		if s.Name() == fallback {
+			return s.Participant
+		}
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+// Package analytics reports what users talk about. It embeds stored
+// conversations, clusters them with k-means and labels each cluster with an
+// LLM.
+//
+//	store, _ := bot.NewFileStore("data/sessions")
+//	conversations, _ := analytics.LoadConversations(ctx, store)
+//	report, err := analytics.New(analytics.Config{
+//		Embedder: embedder,
+//		Client:   client,
+//	}).Analyze(ctx, conversations)
+//	fmt.Print(report.Markdown())
+package analytics
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/bot"
+	"github.com/medatechnology/simpleai/embedding"
+)
+
+// Analytics errors
+var (
+	ErrNoEmbedder      = errors.New("analytics: embedder is required")
+	ErrNotListable     = errors.New("analytics: store cannot list its sessions")
+	ErrNoConversations = errors.New("analytics: no conversations to analyze")
+)
+
+// Conversation is one stored session
+type Conversation struct {
+	ID       string
+	Messages []simpleai.Message
+}
+
+// Cluster is a group of similar conversations
+type Cluster struct {
+	Label           string    `json:"label"`
+	Description     string    `json:"description,omitempty"`
+	Size            int       `json:"size"`
+	Share           float64   `json:"share"` // Fraction of all conversations
+	ConversationIDs []string  `json:"conversation_ids"`
+	Examples        []string  `json:"examples"` // Excerpts of the conversations closest to the center
+	Centroid        []float64 `json:"-"`
+}
+
+// Report is the result of an analysis, with clusters largest first
+type Report struct {
+	GeneratedAt   time.Time `json:"generated_at"`
+	Conversations int       `json:"conversations"`
+	Clusters      []Cluster `json:"clusters"`
+}
+
+// Config holds configuration for an Analyzer
+type Config struct {
+	Embedder embedding.Embedder // Required
+
+	// Client labels the clusters; without it clusters are numbered
+	Client *simpleai.Client
+
+	// Clusters is the number of topics; 0 picks about sqrt(n/2), at most 20
+	Clusters      int
+	MaxIterations int   // k-means iterations (default 50)
+	Seed          int64 // Seeds cluster initialization, for reproducible reports
+
+	Roles    []simpleai.Role // Messages embedded per conversation (default user messages)
+	MaxChars int             // Text embedded per conversation (default 2000)
+	Examples int             // Excerpts per cluster shown to the labeler and kept in the report (default 5)
+}
+
+// DefaultConfig returns sensible defaults
+func DefaultConfig() Config {
+	return Config{
+		MaxIterations: 50,
+		Roles:         []simpleai.Role{simpleai.RoleUser},
+		MaxChars:      2000,
+		Examples:      5,
+	}
+}
+
+// Analyzer clusters conversations into topics
+type Analyzer struct {
+	config Config
+}
+
+// New creates an analyzer
+func New(config Config) *Analyzer {
+	defaults := DefaultConfig()
+	if config.MaxIterations <= 0 {
+		config.MaxIterations = defaults.MaxIterations
+	}
+	if len(config.Roles) == 0 {
+		config.Roles = defaults.Roles
+	}
+	if config.MaxChars <= 0 {
+		config.MaxChars = defaults.MaxChars
+	}
+	if config.Examples <= 0 {
+		config.Examples = defaults.Examples
+	}
+	return &Analyzer{config: config}
+}
+
+// LoadConversations reads every session of a chat store. The store must
+// list its keys, as bot.MemoryStore and bot.FileStore do.
+func LoadConversations(ctx context.Context, store bot.ChatStore) ([]Conversation, error) {
+	lister, ok := store.(interface {
+		Keys(ctx context.Context) ([]string, error)
+	})
+	if !ok {
+		return nil, ErrNotListable
+	}
+
+	keys, err := lister.Keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conversations := make([]Conversation, 0, len(keys))
+	for _, key := range keys {
+		messages, err := store.Load(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("analytics: failed to load %s: %w", key, err)
+		}
+		if len(messages) > 0 {
+			conversations = append(conversations, Conversation{ID: key, Messages: messages})
+		}
+	}
+	return conversations, nil
+}
+
+// Analyze embeds, clusters and labels conversations. Conversations without
+// text in the configured roles are skipped.
+func (a *Analyzer) Analyze(ctx context.Context, conversations []Conversation) (*Report, error) {
+	if a.config.Embedder == nil {
+		return nil, ErrNoEmbedder
+	}
+
+	var ids, texts []string
+	for _, conv := range conversations {
+		if text := a.text(conv); text != "" {
+			ids = append(ids, conv.ID)
+			texts = append(texts, text)
+		}
+	}
+	if len(texts) == 0 {
+		return nil, ErrNoConversations
+	}
+
+	vectors, err := a.config.Embedder.EmbedBatch(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("analytics: embedding failed: %w", err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("analytics: embedder returned %d vectors for %d texts", len(vectors), len(texts))
+	}
+	for _, v := range vectors {
+		normalize(v)
+	}
+
+	k := a.config.Clusters
+	if k <= 0 {
+		k = min(max(int(math.Round(math.Sqrt(float64(len(texts))/2))), 1), 20)
+	}
+	assignments, centroids := kmeans(vectors, k, a.config.MaxIterations, a.config.Seed)
+
+	clusters := make([]Cluster, len(centroids))
+	members := make([][]int, len(centroids))
+	for i, c := range assignments {
+		members[c] = append(members[c], i)
+	}
+	for c := range clusters {
+		// Examples are the members closest to the center
+		slices.SortFunc(members[c], func(x, y int) int {
+			return -cmp.Compare(dot(vectors[x], centroids[c]), dot(vectors[y], centroids[c]))
+		})
+		cluster := Cluster{
+			Size:     len(members[c]),
+			Share:    float64(len(members[c])) / float64(len(texts)),
+			Centroid: centroids[c],
+		}
+		for n, i := range members[c] {
+			cluster.ConversationIDs = append(cluster.ConversationIDs, ids[i])
+			if n < a.config.Examples {
+				cluster.Examples = append(cluster.Examples, excerpt(texts[i], 300))
+			}
+		}
+		clusters[c] = cluster
+	}
+
+	clusters = slices.DeleteFunc(clusters, func(c Cluster) bool { return c.Size == 0 })
+	slices.SortStableFunc(clusters, func(x, y Cluster) int { return y.Size - x.Size })
+
+	for i := range clusters {
+		clusters[i].Label = fmt.Sprintf("Topic %d", i+1)
+		if a.config.Client != nil {
+			if err := a.label(ctx, &clusters[i]); err != nil {
+				return nil, fmt.Errorf("analytics: labeling failed: %w", err)
+			}
+		}
+	}
+
+	return &Report{
+		GeneratedAt:   time.Now(),
+		Conversations: len(texts),
+		Clusters:      clusters,
+	}, nil
+}
+
+// label asks the model to name a cluster from its examples
+func (a *Analyzer) label(ctx context.Context, cluster *Cluster) error {
+	var b strings.Builder
+	b.WriteString("These conversations between users and an assistant were grouped by similarity. ")
+	b.WriteString("Give the topic they share a short label (2-5 words) and a one-sentence description of what users ask about.\n")
+	for i, example := range cluster.Examples {
+		fmt.Fprintf(&b, "\nConversation %d:\n%s\n", i+1, example)
+	}
+
+	var out struct {
+		Label       string `json:"label"`
+		Description string `json:"description"`
+	}
+	if err := a.config.Client.Extract(ctx, b.String(), &out); err != nil {
+		return err
+	}
+	if out.Label != "" {
+		cluster.Label = out.Label
+	}
+	cluster.Description = out.Description
+	return nil
+}
+
+// text joins the messages of the configured roles, truncated to MaxChars
+func (a *Analyzer) text(conv Conversation) string {
+	var parts []string
+	for _, msg := range conv.Messages {
+		if slices.Contains(a.config.Roles, msg.Role) && strings.TrimSpace(msg.Content) != "" {
+			parts = append(parts, strings.TrimSpace(msg.Content))
+		}
+	}
+	return excerpt(strings.Join(parts, "\n"), a.config.MaxChars)
+}
+
+// excerpt truncates text to at most n bytes on a rune boundary
+func excerpt(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n] + "..."
+}
+
+// Markdown renders the report as a Markdown document
+func (r *Report) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Conversation Topics\n\n%d conversations, %d topics (%s)\n",
+		r.Conversations, len(r.Clusters), r.GeneratedAt.Format(time.RFC1123))
+	for _, c := range r.Clusters {
+		fmt.Fprintf(&b, "\n## %s (%d, %.0f%%)\n\n", c.Label, c.Size, c.Share*100)
+		if c.Description != "" {
+			b.WriteString(c.Description + "\n\n")
+		}
+		for _, example := range c.Examples {
+			b.WriteString("> " + strings.ReplaceAll(example, "\n", " ") + "\n\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,102 @@
+package analytics
+
+import (
+	"math"
+	"math/rand"
+)
+
+// kmeans clusters unit vectors into k groups by cosine similarity, seeding
+// the centers with k-means++. It returns each vector's cluster and the
+// cluster centers.
+func kmeans(vectors [][]float64, k, iterations int, seed int64) ([]int, [][]float64) {
+	k = min(k, len(vectors))
+	rng := rand.New(rand.NewSource(seed))
+
+	// k-means++: pick centers far from the ones already chosen
+	centroids := [][]float64{clone(vectors[rng.Intn(len(vectors))])}
+	distances := make([]float64, len(vectors))
+	for len(centroids) < k {
+		total := 0.0
+		for i, v := range vectors {
+			d := 1 - dot(v, centroids[len(centroids)-1])
+			if len(centroids) == 1 || d < distances[i] {
+				distances[i] = d
+			}
+			total += distances[i]
+		}
+		if total <= 0 {
+			break // Fewer distinct vectors than clusters
+		}
+		target := rng.Float64() * total
+		next := len(vectors) - 1
+		for i, d := range distances {
+			if target -= d; target <= 0 {
+				next = i
+				break
+			}
+		}
+		centroids = append(centroids, clone(vectors[next]))
+	}
+
+	assignments := make([]int, len(vectors))
+	for iter := 0; iter < iterations; iter++ {
+		changed := false
+		for i, v := range vectors {
+			best, bestSim := 0, math.Inf(-1)
+			for c, centroid := range centroids {
+				if sim := dot(v, centroid); sim > bestSim {
+					best, bestSim = c, sim
+				}
+			}
+			if assignments[i] != best {
+				assignments[i] = best
+				changed = true
+			}
+		}
+		if !changed && iter > 0 {
+			break
+		}
+
+		// Move each center to the mean of its members
+		sums := make([][]float64, len(centroids))
+		for c := range sums {
+			sums[c] = make([]float64, len(vectors[0]))
+		}
+		for i, v := range vectors {
+			for j, x := range v {
+				sums[assignments[i]][j] += x
+			}
+		}
+		for c, sum := range sums {
+			if normalize(sum) {
+				centroids[c] = sum
+			}
+		}
+	}
+	return assignments, centroids
+}
+
+// normalize scales v to unit length in place, reporting false for a zero
+// vector
+func normalize(v []float64) bool {
+	norm := math.Sqrt(dot(v, v))
+	if norm == 0 {
+		return false
+	}
+	for i := range v {
+		v[i] /= norm
+	}
+	return true
+}
+
+func dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range min(len(a), len(b)) {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func clone(v []float64) []float64 {
+	return append([]float64(nil), v...)
+}
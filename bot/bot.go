@@ -0,0 +1,292 @@
+// Package bot holds what chat-platform adapters (Slack, Discord, Telegram,
+// email) share: per-conversation sessions with persistence, streamed replies
+// delivered as throttled message edits, and command handling.
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/agent"
+)
+
+// Config holds configuration for a Bot
+type Config struct {
+	Client      *simpleai.Client
+	ChatOptions []simpleai.ChatOption // Applied to every new session (system prompt, limits, autocompact)
+
+	// Store persists session history (default: in memory)
+	Store ChatStore
+
+	// Agent, if set, answers instead of a plain chat. Replies are then
+	// delivered once complete rather than streamed.
+	Agent agent.Participant
+
+	// EditInterval is the minimum time between streamed message edits, to
+	// stay within platform rate limits (default 1s)
+	EditInterval time.Duration
+
+	// Commands are extra commands; "reset" and "help" are built in
+	Commands []Command
+
+	// OnEnd is called with the history of a conversation ended by End or
+	// /reset, e.g. to hand it off to a ticketing system (see package
+	// handoff). It is not called for empty conversations.
+	OnEnd func(ctx context.Context, key string, history []simpleai.Message)
+}
+
+// Invocation is a command as received from a platform
+type Invocation struct {
+	Command string // Command name without the leading slash
+	Args    string
+	Key     string // Session key of the conversation
+	User    string // Platform user ID
+}
+
+// Command is a named action users can trigger (e.g. /reset)
+type Command struct {
+	Name        string
+	Description string
+	Run         func(ctx context.Context, inv Invocation) (string, error)
+}
+
+// UpdateFunc delivers a reply: it is called with the growing text while
+// streaming and once more with done set when the reply is complete
+type UpdateFunc func(text string, done bool) error
+
+// Bot answers messages with one chat session per conversation key
+type Bot struct {
+	config   Config
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+type session struct {
+	mu   sync.Mutex // Serializes turns within a conversation
+	chat *simpleai.Chat
+}
+
+// New creates a bot
+func New(config Config) *Bot {
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if config.EditInterval == 0 {
+		config.EditInterval = time.Second
+	}
+	return &Bot{
+		config:   config,
+		sessions: make(map[string]*session),
+	}
+}
+
+// Commands returns the built-in and configured commands, sorted by name
+func (b *Bot) Commands() []Command {
+	commands := []Command{
+		{Name: "reset", Description: "Start a new conversation", Run: b.reset},
+		{Name: "help", Description: "List commands", Run: b.help},
+	}
+	commands = append(commands, b.config.Commands...)
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	return commands
+}
+
+// session returns the session for key, restoring it from the store
+func (b *Bot) session(ctx context.Context, key string) (*session, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if s, ok := b.sessions[key]; ok {
+		return s, nil
+	}
+
+	history, err := b.config.Store.Load(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("bot: load session %s: %w", key, err)
+	}
+	s := &session{chat: b.newChat(history)}
+	b.sessions[key] = s
+	return s, nil
+}
+
+func (b *Bot) newChat(history []simpleai.Message) *simpleai.Chat {
+	opts := append([]simpleai.ChatOption(nil), b.config.ChatOptions...)
+	if len(history) > 0 {
+		opts = append(opts, simpleai.WithMessages(history))
+	}
+	return b.config.Client.NewChat(opts...)
+}
+
+// Chat returns the chat session for a conversation key
+func (b *Bot) Chat(ctx context.Context, key string) (*simpleai.Chat, error) {
+	s, err := b.session(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.chat, nil
+}
+
+// Handle answers text in the conversation identified by key. user is the
+// sender's display name or ID, used to name the speaker for agents.
+func (b *Bot) Handle(ctx context.Context, key, user, text string, update UpdateFunc) error {
+	s, err := b.session(ctx, key)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b.config.Agent != nil {
+		err = b.respondAgent(ctx, s, user, text, update)
+	} else {
+		err = b.respondChat(ctx, s, text, update)
+	}
+	if err != nil {
+		return err
+	}
+	return b.config.Store.Save(ctx, key, s.chat.History())
+}
+
+func (b *Bot) respondChat(ctx context.Context, s *session, text string, update UpdateFunc) error {
+	stream, err := s.chat.Stream(ctx, text)
+	if err != nil {
+		return err
+	}
+
+	var reply strings.Builder
+	var lastEdit time.Time
+	for event := range stream {
+		if event.Error != nil {
+			return event.Error
+		}
+		reply.WriteString(event.Content)
+		if event.Content != "" && time.Since(lastEdit) >= b.config.EditInterval {
+			if err := update(reply.String(), false); err != nil {
+				return err
+			}
+			lastEdit = time.Now()
+		}
+	}
+	return update(reply.String(), true)
+}
+
+func (b *Bot) respondAgent(ctx context.Context, s *session, user, text string, update UpdateFunc) error {
+	history := append(s.chat.History(), simpleai.Message{
+		Role:    simpleai.RoleUser,
+		Name:    user,
+		Content: text,
+	})
+
+	msg, err := b.config.Agent.Respond(ctx, history)
+	if err != nil {
+		return err
+	}
+
+	s.chat = b.newChat(append(history, msg))
+	return update(msg.Content, true)
+}
+
+// Command runs a command and returns the reply text
+func (b *Bot) Command(ctx context.Context, inv Invocation) (string, error) {
+	for _, cmd := range b.Commands() {
+		if cmd.Name == inv.Command {
+			return cmd.Run(ctx, inv)
+		}
+	}
+	return fmt.Sprintf("Unknown command /%s. Try /help.", inv.Command), nil
+}
+
+// End ends the conversation identified by key: its session and stored
+// history are deleted and OnEnd receives the history
+func (b *Bot) End(ctx context.Context, key string) error {
+	b.mu.Lock()
+	s, ok := b.sessions[key]
+	delete(b.sessions, key)
+	b.mu.Unlock()
+
+	var history []simpleai.Message
+	if b.config.OnEnd != nil {
+		if ok {
+			s.mu.Lock() // Let a running turn finish
+			history = s.chat.History()
+			s.mu.Unlock()
+		} else {
+			loaded, err := b.config.Store.Load(ctx, key)
+			if err != nil {
+				return fmt.Errorf("bot: load session %s: %w", key, err)
+			}
+			history = loaded
+		}
+	}
+
+	if err := b.config.Store.Delete(ctx, key); err != nil {
+		return err
+	}
+	if len(history) > 0 {
+		b.config.OnEnd(ctx, key, history)
+	}
+	return nil
+}
+
+func (b *Bot) reset(ctx context.Context, inv Invocation) (string, error) {
+	if err := b.End(ctx, inv.Key); err != nil {
+		return "", err
+	}
+	return "Started a new conversation.", nil
+}
+
+func (b *Bot) help(ctx context.Context, inv Invocation) (string, error) {
+	var sb strings.Builder
+	for _, cmd := range b.Commands() {
+		sb.WriteString("/" + cmd.Name + " - " + cmd.Description + "\n")
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// ParseCommand splits "/name args" (also "/name@botname args") into an
+// invocation; ok is false if text is not a command
+func ParseCommand(text string) (name, args string, ok bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", "", false
+	}
+	name, args, _ = strings.Cut(text[1:], " ")
+	name, _, _ = strings.Cut(name, "@")
+	return strings.ToLower(name), strings.TrimSpace(args), name != ""
+}
+
+// Split breaks text into chunks of at most limit bytes for platforms with
+// message size limits, preferring paragraph and line boundaries
+func Split(text string, limit int) []string {
+	if limit <= 0 || len(text) <= limit {
+		return []string{text}
+	}
+
+	var chunks []string
+	for len(text) > limit {
+		cut := strings.LastIndex(text[:limit], "\n\n")
+		if cut <= 0 {
+			cut = strings.LastIndex(text[:limit], "\n")
+		}
+		if cut <= 0 {
+			cut = strings.LastIndex(text[:limit], " ")
+		}
+		if cut <= 0 {
+			cut = limit
+			// Don't split a UTF-8 sequence
+			for cut > 0 && text[cut]&0xC0 == 0x80 {
+				cut--
+			}
+		}
+		chunks = append(chunks, strings.TrimRight(text[:cut], " \n"))
+		text = strings.TrimLeft(text[cut:], " \n")
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
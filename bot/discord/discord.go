@@ -0,0 +1,528 @@
+// Package discord connects a bot.Bot to Discord through the gateway. The bot
+// answers direct messages and mentions, streams replies by editing its
+// message, and registers the bot's commands as slash commands.
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/goutil/simplelog"
+	"github.com/medatechnology/simpleai/bot"
+	"github.com/medatechnology/simpleai/internal/websocket"
+)
+
+// DefaultBaseURL is the Discord REST API base URL
+const DefaultBaseURL = "https://discord.com/api/v10"
+
+// MaxMessageLength is Discord's message content limit
+const MaxMessageLength = 2000
+
+// Gateway intents
+const (
+	IntentGuildMessages  = 1 << 9
+	IntentDirectMessages = 1 << 12
+	IntentMessageContent = 1 << 15
+)
+
+// Config holds configuration for the Discord adapter
+type Config struct {
+	Token         string // Bot token
+	ApplicationID string // Needed for slash commands
+	BaseURL       string
+
+	// Intents defaults to guild messages, direct messages and message content
+	Intents int
+
+	// RegisterCommands registers /ask and the bot's commands as global
+	// slash commands on start
+	RegisterCommands bool
+
+	// Thinking is posted while the reply is generated (default "…")
+	Thinking string
+}
+
+// Discord is a gateway connection serving a bot
+type Discord struct {
+	config Config
+	bot    *bot.Bot
+	client *http.Client
+
+	mu     sync.Mutex
+	selfID string
+}
+
+// New creates a Discord adapter for b
+func New(b *bot.Bot, config Config) *Discord {
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultBaseURL
+	}
+	if config.Intents == 0 {
+		config.Intents = IntentGuildMessages | IntentDirectMessages | IntentMessageContent
+	}
+	if config.Thinking == "" {
+		config.Thinking = "…"
+	}
+	return &Discord{
+		config: config,
+		bot:    b,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// NewFromEnv creates a Discord adapter using DISCORD_TOKEN and
+// DISCORD_APPLICATION_ID
+func NewFromEnv(b *bot.Bot) *Discord {
+	return New(b, Config{
+		Token:            os.Getenv("DISCORD_TOKEN"),
+		ApplicationID:    os.Getenv("DISCORD_APPLICATION_ID"),
+		RegisterCommands: os.Getenv("DISCORD_APPLICATION_ID") != "",
+	})
+}
+
+// Run connects to the gateway and serves events until ctx is cancelled,
+// reconnecting when the gateway asks to or the connection drops
+func (d *Discord) Run(ctx context.Context) error {
+	if d.config.Token == "" {
+		return errors.New("discord: Token is required")
+	}
+	if d.config.RegisterCommands {
+		if err := d.registerCommands(ctx); err != nil {
+			return err
+		}
+	}
+
+	backoff := time.Second
+	for {
+		err := d.serve(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// Authentication failures won't fix themselves
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) && closeErr.Code == 4004 {
+			return fmt.Errorf("discord: authentication failed: %w", err)
+		}
+		if err != nil {
+			simplelog.LogErr(err, "discord connection lost, reconnecting")
+		} else {
+			backoff = time.Second
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+// Gateway opcodes
+const (
+	opDispatch       = 0
+	opHeartbeat      = 1
+	opIdentify       = 2
+	opReconnect      = 7
+	opInvalidSession = 9
+	opHello          = 10
+)
+
+type gatewayPayload struct {
+	Op       int             `json:"op"`
+	Data     json.RawMessage `json:"d,omitempty"`
+	Sequence *int64          `json:"s,omitempty"`
+	Type     string          `json:"t,omitempty"`
+}
+
+type user struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Bot      bool   `json:"bot"`
+}
+
+type message struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	GuildID   string `json:"guild_id"`
+	Author    user   `json:"author"`
+	Content   string `json:"content"`
+	Mentions  []user `json:"mentions"`
+}
+
+type interaction struct {
+	ID        string `json:"id"`
+	Token     string `json:"token"`
+	Type      int    `json:"type"`
+	ChannelID string `json:"channel_id"`
+	Member    *struct {
+		User user `json:"user"`
+	} `json:"member"`
+	User *user `json:"user"`
+	Data struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string `json:"name"`
+			Value any    `json:"value"`
+		} `json:"options"`
+	} `json:"data"`
+}
+
+// serve handles one gateway session; it returns nil when the gateway
+// requests a reconnect
+func (d *Discord) serve(ctx context.Context) error {
+	var gateway struct {
+		URL string `json:"url"`
+	}
+	if err := d.api(ctx, http.MethodGet, "/gateway/bot", nil, &gateway); err != nil {
+		return err
+	}
+
+	conn, err := websocket.Dial(ctx, gateway.URL+"/?v=10&encoding=json", nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(1000)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() { conn.Close(1000) })
+	defer stop()
+
+	var seqMu sync.Mutex
+	var seq *int64
+	send := func(op int, data any) error {
+		payload, err := json.Marshal(map[string]any{"op": op, "d": data})
+		if err != nil {
+			return err
+		}
+		return conn.WriteMessage(payload)
+	}
+
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var p gatewayPayload
+		if err := json.Unmarshal(data, &p); err != nil {
+			continue
+		}
+		if p.Sequence != nil {
+			seqMu.Lock()
+			seq = p.Sequence
+			seqMu.Unlock()
+		}
+
+		switch p.Op {
+		case opHello:
+			var hello struct {
+				HeartbeatInterval int `json:"heartbeat_interval"`
+			}
+			json.Unmarshal(p.Data, &hello)
+			go d.heartbeat(ctx, time.Duration(hello.HeartbeatInterval)*time.Millisecond, func() error {
+				seqMu.Lock()
+				defer seqMu.Unlock()
+				return send(opHeartbeat, seq)
+			})
+
+			if err := send(opIdentify, map[string]any{
+				"token":   d.config.Token,
+				"intents": d.config.Intents,
+				"properties": map[string]string{
+					"os":      "linux",
+					"browser": "simpleai",
+					"device":  "simpleai",
+				},
+			}); err != nil {
+				return err
+			}
+		case opHeartbeat:
+			seqMu.Lock()
+			err := send(opHeartbeat, seq)
+			seqMu.Unlock()
+			if err != nil {
+				return err
+			}
+		case opReconnect, opInvalidSession:
+			return nil
+		case opDispatch:
+			d.dispatch(ctx, p)
+		}
+	}
+}
+
+func (d *Discord) heartbeat(ctx context.Context, interval time.Duration, beat func() error) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if beat() != nil {
+				return
+			}
+		}
+	}
+}
+
+func (d *Discord) dispatch(ctx context.Context, p gatewayPayload) {
+	switch p.Type {
+	case "READY":
+		var ready struct {
+			User user `json:"user"`
+		}
+		if json.Unmarshal(p.Data, &ready) == nil {
+			d.mu.Lock()
+			d.selfID = ready.User.ID
+			d.mu.Unlock()
+		}
+	case "MESSAGE_CREATE":
+		var m message
+		if json.Unmarshal(p.Data, &m) == nil {
+			go d.handleMessage(ctx, m)
+		}
+	case "INTERACTION_CREATE":
+		var i interaction
+		if json.Unmarshal(p.Data, &i) == nil {
+			go d.handleInteraction(ctx, i)
+		}
+	}
+}
+
+func (d *Discord) handleMessage(ctx context.Context, m message) {
+	if m.Author.Bot {
+		return
+	}
+
+	d.mu.Lock()
+	self := d.selfID
+	d.mu.Unlock()
+
+	// In servers, only answer when mentioned; always answer DMs
+	if m.GuildID != "" {
+		mentioned := false
+		for _, u := range m.Mentions {
+			mentioned = mentioned || u.ID == self
+		}
+		if !mentioned {
+			return
+		}
+	}
+
+	text := strings.NewReplacer("<@"+self+">", "", "<@!"+self+">", "").Replace(m.Content)
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+
+	key := "discord:" + m.ChannelID
+	if name, args, ok := bot.ParseCommand(text); ok {
+		reply, err := d.bot.Command(ctx, bot.Invocation{Command: name, Args: args, Key: key, User: m.Author.ID})
+		if err != nil {
+			reply = "Error: " + err.Error()
+		}
+		d.send(ctx, m.ChannelID, reply)
+		return
+	}
+
+	placeholder, err := d.send(ctx, m.ChannelID, d.config.Thinking)
+	if err != nil {
+		simplelog.LogErr(err, "discord send failed")
+		return
+	}
+
+	edit := func(text string) error {
+		return d.api(ctx, http.MethodPatch, "/channels/"+m.ChannelID+"/messages/"+placeholder,
+			map[string]string{"content": text}, nil)
+	}
+	err = d.bot.Handle(ctx, key, m.Author.Username, text, d.streamer(edit, func(text string) error {
+		_, err := d.send(ctx, m.ChannelID, text)
+		return err
+	}))
+	if err != nil {
+		edit("Sorry, something went wrong: " + err.Error())
+	}
+}
+
+// streamer returns an update function that edits the first message while
+// streaming and sends any text beyond the length limit as follow-ups
+func (d *Discord) streamer(edit, followUp func(text string) error) bot.UpdateFunc {
+	return func(text string, done bool) error {
+		if text == "" {
+			return nil
+		}
+		chunks := bot.Split(text, MaxMessageLength)
+		if !done {
+			// Show the head of a long reply until it is complete
+			return edit(chunks[0])
+		}
+		if err := edit(chunks[0]); err != nil {
+			return err
+		}
+		for _, chunk := range chunks[1:] {
+			if err := followUp(chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (d *Discord) handleInteraction(ctx context.Context, i interaction) {
+	const applicationCommand = 2
+	if i.Type != applicationCommand {
+		return
+	}
+
+	userID := ""
+	switch {
+	case i.Member != nil:
+		userID = i.Member.User.ID
+	case i.User != nil:
+		userID = i.User.ID
+	}
+
+	args := ""
+	for _, opt := range i.Data.Options {
+		args = strings.TrimSpace(args + " " + fmt.Sprint(opt.Value))
+	}
+
+	// Defer the response; the reply is delivered by editing it
+	const deferredChannelMessage = 5
+	if err := d.api(ctx, http.MethodPost, "/interactions/"+i.ID+"/"+i.Token+"/callback",
+		map[string]any{"type": deferredChannelMessage}, nil); err != nil {
+		simplelog.LogErr(err, "discord interaction callback failed")
+		return
+	}
+
+	webhook := "/webhooks/" + d.config.ApplicationID + "/" + i.Token
+	edit := func(text string) error {
+		return d.api(ctx, http.MethodPatch, webhook+"/messages/@original", map[string]string{"content": text}, nil)
+	}
+	followUp := func(text string) error {
+		return d.api(ctx, http.MethodPost, webhook, map[string]string{"content": text}, nil)
+	}
+
+	key := "discord:" + i.ChannelID
+	if i.Data.Name == "ask" {
+		if err := d.bot.Handle(ctx, key, userID, args, d.streamer(edit, followUp)); err != nil {
+			edit("Sorry, something went wrong: " + err.Error())
+		}
+		return
+	}
+
+	reply, err := d.bot.Command(ctx, bot.Invocation{Command: i.Data.Name, Args: args, Key: key, User: userID})
+	if err != nil {
+		reply = "Error: " + err.Error()
+	}
+	d.streamer(edit, followUp)(reply, true)
+}
+
+// registerCommands registers /ask and the bot's commands globally
+func (d *Discord) registerCommands(ctx context.Context) error {
+	if d.config.ApplicationID == "" {
+		return errors.New("discord: ApplicationID is required to register commands")
+	}
+
+	textOption := func(required bool) []map[string]any {
+		return []map[string]any{{
+			"type":        3, // STRING
+			"name":        "text",
+			"description": "Text",
+			"required":    required,
+		}}
+	}
+
+	commands := []map[string]any{{
+		"name":        "ask",
+		"description": "Ask the assistant",
+		"options":     textOption(true),
+	}}
+	for _, cmd := range d.bot.Commands() {
+		commands = append(commands, map[string]any{
+			"name":        cmd.Name,
+			"description": cmd.Description,
+			"options":     textOption(false),
+		})
+	}
+
+	return d.api(ctx, http.MethodPut, "/applications/"+d.config.ApplicationID+"/commands", commands, nil)
+}
+
+func (d *Discord) send(ctx context.Context, channel, text string) (string, error) {
+	var last struct {
+		ID string `json:"id"`
+	}
+	for _, chunk := range bot.Split(text, MaxMessageLength) {
+		if err := d.api(ctx, http.MethodPost, "/channels/"+channel+"/messages",
+			map[string]string{"content": chunk}, &last); err != nil {
+			return "", err
+		}
+	}
+	return last.ID, nil
+}
+
+// api calls the REST API, waiting out rate limits once
+func (d *Discord) api(ctx context.Context, method, path string, body, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, d.config.BaseURL+path, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bot "+d.config.Token)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return err
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt == 0 {
+			var limit struct {
+				RetryAfter float64 `json:"retry_after"`
+			}
+			json.Unmarshal(data, &limit)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(limit.RetryAfter * float64(time.Second))):
+			}
+			continue
+		}
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("discord %s %s: status %d: %s", method, path, resp.StatusCode, data)
+		}
+		if out != nil && len(data) > 0 {
+			return json.Unmarshal(data, out)
+		}
+		return nil
+	}
+}
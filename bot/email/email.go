@@ -0,0 +1,382 @@
+// Package email turns a bot.Bot into an email assistant: it polls an inbox
+// over IMAP, keeps one conversation per sender, and replies over SMTP in the
+// same thread with a templated signature.
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/medatechnology/goutil/simplelog"
+	"github.com/medatechnology/simpleai/bot"
+	"github.com/medatechnology/simpleai/internal/imap"
+	"github.com/medatechnology/simpleai/template"
+)
+
+// Message is an incoming email
+type Message struct {
+	From      string // Sender address
+	Name      string // Sender display name
+	Subject   string
+	Body      string // Plain text body with quoted replies removed
+	MessageID string
+	Date      time.Time
+}
+
+// Config holds configuration for the email adapter
+type Config struct {
+	IMAPAddr string // host:port with implicit TLS, e.g. "imap.example.com:993"
+	SMTPAddr string // host:port with STARTTLS, e.g. "smtp.example.com:587"
+	Username string
+	Password string
+
+	From     string // Reply address (default: Username)
+	FromName string // Display name for replies
+
+	// Mailbox to poll (default "INBOX")
+	Mailbox string
+
+	// PollInterval between inbox checks (default 1 minute)
+	PollInterval time.Duration
+
+	// Signature is a template appended to every reply. It receives the
+	// incoming Message as .Message, FromName as .Name and the time as .Now.
+	Signature string
+
+	// Accept filters which messages are answered (default: all except
+	// automated mail). Rejected messages are marked as read.
+	Accept func(Message) bool
+}
+
+// Email is an inbox served by a bot
+type Email struct {
+	config Config
+	bot    *bot.Bot
+}
+
+// New creates an email adapter for b
+func New(b *bot.Bot, config Config) *Email {
+	if config.From == "" {
+		config.From = config.Username
+	}
+	if config.Mailbox == "" {
+		config.Mailbox = "INBOX"
+	}
+	if config.PollInterval == 0 {
+		config.PollInterval = time.Minute
+	}
+	return &Email{config: config, bot: b}
+}
+
+// NewFromEnv creates an email adapter using EMAIL_IMAP_ADDR, EMAIL_SMTP_ADDR,
+// EMAIL_USERNAME, EMAIL_PASSWORD and EMAIL_FROM
+func NewFromEnv(b *bot.Bot) *Email {
+	return New(b, Config{
+		IMAPAddr: os.Getenv("EMAIL_IMAP_ADDR"),
+		SMTPAddr: os.Getenv("EMAIL_SMTP_ADDR"),
+		Username: os.Getenv("EMAIL_USERNAME"),
+		Password: os.Getenv("EMAIL_PASSWORD"),
+		From:     os.Getenv("EMAIL_FROM"),
+	})
+}
+
+// Run polls the inbox until ctx is cancelled
+func (e *Email) Run(ctx context.Context) error {
+	if e.config.IMAPAddr == "" || e.config.SMTPAddr == "" || e.config.Username == "" {
+		return errors.New("email: IMAPAddr, SMTPAddr and Username are required")
+	}
+
+	ticker := time.NewTicker(e.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := e.Poll(ctx); err != nil && ctx.Err() == nil {
+			simplelog.LogErr(err, "email poll failed")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll answers all unread messages once. A message is marked as read only
+// after it was answered, so failures are retried on the next poll.
+func (e *Email) Poll(ctx context.Context) error {
+	client, err := imap.Dial(ctx, e.config.IMAPAddr)
+	if err != nil {
+		return err
+	}
+	defer client.Logout()
+
+	if err := client.Login(e.config.Username, e.config.Password); err != nil {
+		return err
+	}
+	if err := client.Select(e.config.Mailbox); err != nil {
+		return err
+	}
+	uids, err := client.Search("UNSEEN")
+	if err != nil {
+		return err
+	}
+
+	for _, uid := range uids {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		raw, err := client.Fetch(uid)
+		if err != nil {
+			return err
+		}
+		if err := e.handle(ctx, raw); err != nil {
+			simplelog.LogErr(err, "email reply failed")
+			continue
+		}
+		if err := client.AddFlags(uid, `\Seen`); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handle answers one raw message; ignored messages return nil
+func (e *Email) handle(ctx context.Context, raw []byte) error {
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		simplelog.LogErr(err, "email: skipping unparsable message")
+		return nil
+	}
+	if automated(parsed.Header) {
+		return nil
+	}
+
+	msg, err := e.parse(parsed)
+	if err != nil {
+		simplelog.LogErr(err, "email: skipping unparsable message")
+		return nil
+	}
+	// Never answer ourselves, which could loop
+	if strings.EqualFold(msg.From, e.config.From) {
+		return nil
+	}
+	if e.config.Accept != nil && !e.config.Accept(*msg) {
+		return nil
+	}
+
+	key := "email:" + strings.ToLower(msg.From)
+	text := strings.TrimSpace(msg.Body)
+	if text == "" {
+		text = msg.Subject
+	}
+
+	var reply string
+	if name, args, ok := bot.ParseCommand(text); ok {
+		reply, err = e.bot.Command(ctx, bot.Invocation{Command: name, Args: args, Key: key, User: msg.From})
+	} else {
+		err = e.bot.Handle(ctx, key, msg.From, text, func(text string, done bool) error {
+			if done {
+				reply = text
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	return e.send(msg, parsed.Header, reply)
+}
+
+func (e *Email) parse(m *mail.Message) (*Message, error) {
+	from, err := mail.ParseAddress(m.Header.Get("From"))
+	if err != nil {
+		return nil, err
+	}
+
+	var dec mime.WordDecoder
+	subject, err := dec.DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		subject = m.Header.Get("Subject")
+	}
+
+	body, err := textBody(m.Header.Get("Content-Type"), m.Header.Get("Content-Transfer-Encoding"), m.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	date, _ := m.Header.Date()
+	return &Message{
+		From:      from.Address,
+		Name:      from.Name,
+		Subject:   subject,
+		Body:      stripQuoted(body),
+		MessageID: m.Header.Get("Message-ID"),
+		Date:      date,
+	}, nil
+}
+
+// automated reports auto-replies, bounces and list mail, which must not be
+// answered
+func automated(h mail.Header) bool {
+	if auto := strings.ToLower(h.Get("Auto-Submitted")); auto != "" && auto != "no" {
+		return true
+	}
+	switch strings.ToLower(h.Get("Precedence")) {
+	case "bulk", "junk", "list", "auto_reply":
+		return true
+	}
+	return h.Get("List-Id") != "" || h.Get("X-Autoreply") != "" || h.Get("X-Autorespond") != ""
+}
+
+// textBody extracts the text/plain part of a possibly multipart body
+func textBody(contentType, encoding string, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		var fallback string
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return fallback, nil
+			}
+			if err != nil {
+				return "", err
+			}
+			text, err := textBody(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				continue
+			}
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			switch {
+			case partType == "text/plain", partType == "":
+				return text, nil
+			case strings.HasPrefix(partType, "multipart/") && text != "":
+				return text, nil
+			}
+			if fallback == "" && partType == "text/html" {
+				fallback = text
+			}
+		}
+	}
+
+	switch strings.ToLower(encoding) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+
+	text := string(data)
+	if mediaType == "text/html" {
+		text = stripHTML(text)
+	}
+	return text, nil
+}
+
+var (
+	wrotePattern = regexp.MustCompile(`(?m)^On .+wrote:\s*$`)
+	tagPattern   = regexp.MustCompile(`(?s)<style.*?</style>|<script.*?</script>|<[^>]+>`)
+)
+
+// stripQuoted removes the quoted previous conversation from a reply; the
+// chat session already holds it
+func stripQuoted(body string) string {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	if loc := wrotePattern.FindStringIndex(body); loc != nil {
+		body = body[:loc[0]]
+	}
+
+	var lines []string
+	for _, line := range strings.Split(body, "\n") {
+		if !strings.HasPrefix(line, ">") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func stripHTML(html string) string {
+	text := tagPattern.ReplaceAllString(html, "")
+	return strings.NewReplacer("&nbsp;", " ", "&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&#39;", "'").Replace(text)
+}
+
+// send replies in the thread of the incoming message
+func (e *Email) send(msg *Message, header mail.Header, reply string) error {
+	if e.config.Signature != "" {
+		signature, err := template.Prompt(e.config.Signature, map[string]any{
+			"Message": msg,
+			"Name":    e.config.FromName,
+			"Now":     time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("email: signature: %w", err)
+		}
+		reply += "\n\n-- \n" + strings.TrimSpace(signature)
+	}
+
+	subject := msg.Subject
+	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+		subject = "Re: " + subject
+	}
+	references := strings.TrimSpace(header.Get("References") + " " + msg.MessageID)
+
+	from := (&mail.Address{Name: e.config.FromName, Address: e.config.From}).String()
+	to := (&mail.Address{Name: msg.Name, Address: msg.From}).String()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: %s\r\n", e.messageID())
+	if msg.MessageID != "" {
+		fmt.Fprintf(&buf, "In-Reply-To: %s\r\n", msg.MessageID)
+		fmt.Fprintf(&buf, "References: %s\r\n", references)
+	}
+	buf.WriteString("Auto-Submitted: auto-replied\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(reply, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	if err := qp.Close(); err != nil {
+		return err
+	}
+
+	host, _, _ := net.SplitHostPort(e.config.SMTPAddr)
+	auth := smtp.PlainAuth("", e.config.Username, e.config.Password, host)
+	return smtp.SendMail(e.config.SMTPAddr, auth, e.config.From, []string{msg.From}, buf.Bytes())
+}
+
+func (e *Email) messageID() string {
+	domain := "localhost"
+	if _, d, ok := strings.Cut(e.config.From, "@"); ok {
+		domain = d
+	}
+	return fmt.Sprintf("<%d.simpleai@%s>", time.Now().UnixNano(), domain)
+}
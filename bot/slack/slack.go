@@ -0,0 +1,317 @@
+// Package slack connects a bot.Bot to Slack over Socket Mode, so no public
+// HTTP endpoint is needed. The bot answers direct messages and mentions,
+// streams replies by editing its message, and handles slash commands.
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/medatechnology/goutil/simplelog"
+	"github.com/medatechnology/simpleai/bot"
+	"github.com/medatechnology/simpleai/internal/websocket"
+)
+
+// DefaultBaseURL is the Slack Web API base URL
+const DefaultBaseURL = "https://slack.com/api"
+
+// Config holds configuration for the Slack adapter
+type Config struct {
+	AppToken string // App-level token (xapp-...) with connections:write
+	BotToken string // Bot token (xoxb-...) with chat:write, app_mentions:read, im:history
+	BaseURL  string
+
+	// Threads keeps one session per thread and replies in threads;
+	// otherwise there is one session per channel
+	Threads bool
+
+	// Thinking is posted while the reply is generated (default "…")
+	Thinking string
+}
+
+// Slack is a Socket Mode connection serving a bot
+type Slack struct {
+	config Config
+	bot    *bot.Bot
+	client *http.Client
+}
+
+// New creates a Slack adapter for b
+func New(b *bot.Bot, config Config) *Slack {
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultBaseURL
+	}
+	if config.Thinking == "" {
+		config.Thinking = "…"
+	}
+	return &Slack{
+		config: config,
+		bot:    b,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// NewFromEnv creates a Slack adapter using SLACK_APP_TOKEN and SLACK_BOT_TOKEN
+func NewFromEnv(b *bot.Bot) *Slack {
+	return New(b, Config{
+		AppToken: os.Getenv("SLACK_APP_TOKEN"),
+		BotToken: os.Getenv("SLACK_BOT_TOKEN"),
+	})
+}
+
+// Run connects to Slack and serves events until ctx is cancelled,
+// reconnecting when Slack asks to or the connection drops
+func (s *Slack) Run(ctx context.Context) error {
+	if s.config.AppToken == "" || s.config.BotToken == "" {
+		return errors.New("slack: AppToken and BotToken are required")
+	}
+
+	backoff := time.Second
+	for {
+		err := s.serve(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			simplelog.LogErr(err, "slack connection lost, reconnecting")
+		} else {
+			backoff = time.Second
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+type envelope struct {
+	EnvelopeID string          `json:"envelope_id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+type eventPayload struct {
+	Event struct {
+		Type        string `json:"type"`
+		Subtype     string `json:"subtype"`
+		BotID       string `json:"bot_id"`
+		User        string `json:"user"`
+		Text        string `json:"text"`
+		Channel     string `json:"channel"`
+		ChannelType string `json:"channel_type"`
+		TS          string `json:"ts"`
+		ThreadTS    string `json:"thread_ts"`
+	} `json:"event"`
+}
+
+type commandPayload struct {
+	Command   string `json:"command"`
+	Text      string `json:"text"`
+	ChannelID string `json:"channel_id"`
+	UserID    string `json:"user_id"`
+}
+
+// serve handles one Socket Mode connection; it returns nil when Slack
+// requests a reconnect
+func (s *Slack) serve(ctx context.Context) error {
+	var open struct {
+		URL string `json:"url"`
+	}
+	if err := s.call(ctx, s.config.AppToken, "apps.connections.open", nil, &open); err != nil {
+		return err
+	}
+
+	conn, err := websocket.Dial(ctx, open.URL, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(1000)
+
+	// Unblock ReadMessage on shutdown
+	stop := context.AfterFunc(ctx, func() { conn.Close(1000) })
+	defer stop()
+
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var env envelope
+		if err := json.Unmarshal(data, &env); err != nil {
+			continue
+		}
+
+		// Acknowledge within 3 seconds, before doing any work
+		if env.EnvelopeID != "" {
+			ack, _ := json.Marshal(map[string]string{"envelope_id": env.EnvelopeID})
+			if err := conn.WriteMessage(ack); err != nil {
+				return err
+			}
+		}
+
+		switch env.Type {
+		case "disconnect":
+			return nil
+		case "events_api":
+			var p eventPayload
+			if json.Unmarshal(env.Payload, &p) == nil {
+				go s.handleEvent(ctx, p)
+			}
+		case "slash_commands":
+			var p commandPayload
+			if json.Unmarshal(env.Payload, &p) == nil {
+				go s.handleCommand(ctx, p)
+			}
+		}
+	}
+}
+
+var mentionPattern = regexp.MustCompile(`<@[A-Z0-9]+>`)
+
+func (s *Slack) handleEvent(ctx context.Context, p eventPayload) {
+	e := p.Event
+	// Ignore bots (including ourselves) and edits, joins and other subtypes
+	if e.BotID != "" || e.Subtype != "" {
+		return
+	}
+	if e.Type != "app_mention" && !(e.Type == "message" && e.ChannelType == "im") {
+		return
+	}
+
+	text := strings.TrimSpace(mentionPattern.ReplaceAllString(e.Text, ""))
+	if text == "" {
+		return
+	}
+
+	thread := ""
+	if s.config.Threads {
+		thread = e.ThreadTS
+		if thread == "" {
+			thread = e.TS
+		}
+	}
+	key := "slack:" + e.Channel
+	if thread != "" {
+		key += ":" + thread
+	}
+
+	if name, args, ok := bot.ParseCommand(text); ok {
+		reply, err := s.bot.Command(ctx, bot.Invocation{Command: name, Args: args, Key: key, User: e.User})
+		if err != nil {
+			reply = "Error: " + err.Error()
+		}
+		s.post(ctx, e.Channel, thread, reply)
+		return
+	}
+
+	s.answer(ctx, e.Channel, thread, key, e.User, text)
+}
+
+func (s *Slack) handleCommand(ctx context.Context, p commandPayload) {
+	key := "slack:" + p.ChannelID
+	name := strings.TrimPrefix(p.Command, "/")
+	if name == "ask" {
+		s.answer(ctx, p.ChannelID, "", key, p.UserID, p.Text)
+		return
+	}
+
+	reply, err := s.bot.Command(ctx, bot.Invocation{Command: name, Args: p.Text, Key: key, User: p.UserID})
+	if err != nil {
+		reply = "Error: " + err.Error()
+	}
+	s.post(ctx, p.ChannelID, "", reply)
+}
+
+// answer posts a placeholder and edits it as the reply streams in
+func (s *Slack) answer(ctx context.Context, channel, thread, key, user, text string) {
+	ts, err := s.post(ctx, channel, thread, s.config.Thinking)
+	if err != nil {
+		simplelog.LogErr(err, "slack post failed")
+		return
+	}
+
+	err = s.bot.Handle(ctx, key, user, text, func(reply string, done bool) error {
+		if reply == "" {
+			return nil
+		}
+		return s.update(ctx, channel, ts, reply)
+	})
+	if err != nil {
+		s.update(ctx, channel, ts, "Sorry, something went wrong: "+err.Error())
+	}
+}
+
+func (s *Slack) post(ctx context.Context, channel, thread, text string) (string, error) {
+	var resp struct {
+		TS string `json:"ts"`
+	}
+	body := map[string]string{"channel": channel, "text": text}
+	if thread != "" {
+		body["thread_ts"] = thread
+	}
+	err := s.call(ctx, s.config.BotToken, "chat.postMessage", body, &resp)
+	return resp.TS, err
+}
+
+func (s *Slack) update(ctx context.Context, channel, ts, text string) error {
+	return s.call(ctx, s.config.BotToken, "chat.update", map[string]string{
+		"channel": channel,
+		"ts":      ts,
+		"text":    text,
+	}, nil)
+}
+
+// call invokes a Web API method and checks Slack's ok/error envelope
+func (s *Slack) call(ctx context.Context, token, method string, body, out any) error {
+	payload := []byte("{}")
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.BaseURL+"/"+method, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return fmt.Errorf("slack %s: status %d: %w", method, resp.StatusCode, err)
+	}
+
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return err
+	}
+	if !result.OK {
+		return fmt.Errorf("slack %s: %s", method, result.Error)
+	}
+	if out != nil {
+		return json.Unmarshal(raw, out)
+	}
+	return nil
+}
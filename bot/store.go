@@ -0,0 +1,152 @@
+package bot
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// ChatStore persists conversation history per session key, so sessions
+// survive restarts
+type ChatStore interface {
+	// Load returns the stored history, or nil if there is none
+	Load(ctx context.Context, key string) ([]simpleai.Message, error)
+	Save(ctx context.Context, key string, messages []simpleai.Message) error
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryStore keeps history in memory
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string][]simpleai.Message
+}
+
+// NewMemoryStore creates an in-memory chat store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string][]simpleai.Message)}
+}
+
+// Load returns a copy of the stored history
+func (m *MemoryStore) Load(ctx context.Context, key string) ([]simpleai.Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	messages, ok := m.sessions[key]
+	if !ok {
+		return nil, nil
+	}
+	return append([]simpleai.Message(nil), messages...), nil
+}
+
+// Save stores a copy of the history
+func (m *MemoryStore) Save(ctx context.Context, key string, messages []simpleai.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[key] = append([]simpleai.Message(nil), messages...)
+	return nil
+}
+
+// Delete removes the history
+func (m *MemoryStore) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, key)
+	return nil
+}
+
+// Keys returns the keys of all stored sessions
+func (m *MemoryStore) Keys(ctx context.Context) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, 0, len(m.sessions))
+	for key := range m.sessions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// FileStore keeps each session's history as a JSON file in a directory
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a file-backed chat store in dir, creating it if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path maps a session key (e.g. "slack:C123:1700000000.1234") to a safe
+// file name. The key is base64url-encoded, so distinct keys never share a
+// file.
+func (f *FileStore) path(key string) string {
+	return filepath.Join(f.dir, base64.RawURLEncoding.EncodeToString([]byte(key))+".json")
+}
+
+// Load reads the history file
+func (f *FileStore) Load(ctx context.Context, key string) ([]simpleai.Message, error) {
+	data, err := os.ReadFile(f.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []simpleai.Message
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Save writes the history file atomically
+func (f *FileStore) Save(ctx context.Context, key string, messages []simpleai.Message) error {
+	data, err := json.Marshal(messages)
+	if err != nil {
+		return err
+	}
+	tmp := f.path(key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path(key))
+}
+
+// Delete removes the history file
+func (f *FileStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(f.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Keys returns the keys of all stored sessions. Files not written by the
+// store are skipped.
+func (f *FileStore) Keys(ctx context.Context) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(f.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(matches))
+	for _, match := range matches {
+		key, err := base64.RawURLEncoding.DecodeString(strings.TrimSuffix(filepath.Base(match), ".json"))
+		if err != nil {
+			continue
+		}
+		keys = append(keys, string(key))
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/medatechnology/simpleai"
+)
+
+func TestFileStoreKeysDoNotCollide(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Keys that differ only in characters unsafe for file names
+	a, b := "email:a+b@x.com", "email:a_b@x.com"
+	if store.path(a) == store.path(b) {
+		t.Fatalf("%q and %q map to the same file %s", a, b, store.path(a))
+	}
+
+	if err := store.Save(ctx, a, []simpleai.Message{{Role: simpleai.RoleUser, Content: "from a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(ctx, b, []simpleai.Message{{Role: simpleai.RoleUser, Content: "from b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]string{a: "from a", b: "from b"} {
+		messages, err := store.Load(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(messages) != 1 || messages[0].Content != want {
+			t.Errorf("Load(%q) = %+v, want one message %q", key, messages, want)
+		}
+	}
+
+	keys, err := store.Keys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{a, b}; !slices.Equal(keys, want) {
+		t.Errorf("Keys() = %q, want %q", keys, want)
+	}
+}
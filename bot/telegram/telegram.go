@@ -0,0 +1,337 @@
+// Package telegram connects a bot.Bot to Telegram using long polling, so no
+// public HTTP endpoint is needed. Each chat has its own session, replies are
+// streamed by editing the bot's message, and voice notes can be transcribed.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/medatechnology/goutil/simplelog"
+	"github.com/medatechnology/simpleai/bot"
+)
+
+// DefaultBaseURL is the Telegram Bot API base URL
+const DefaultBaseURL = "https://api.telegram.org"
+
+// MaxMessageLength is Telegram's message text limit
+const MaxMessageLength = 4096
+
+// TranscribeFunc turns a voice note into text. mimeType is usually
+// "audio/ogg" (Opus).
+type TranscribeFunc func(ctx context.Context, audio []byte, mimeType string) (string, error)
+
+// Config holds configuration for the Telegram adapter
+type Config struct {
+	Token   string // Bot token from @BotFather
+	BaseURL string
+
+	// Transcribe, if set, answers voice notes by transcribing them first;
+	// otherwise voice notes are ignored
+	Transcribe TranscribeFunc
+
+	// PollTimeout is the long polling timeout (default 30s)
+	PollTimeout time.Duration
+
+	// RegisterCommands publishes the bot's commands to Telegram's command
+	// menu on start
+	RegisterCommands bool
+
+	// Thinking is posted while the reply is generated (default "…")
+	Thinking string
+}
+
+// Telegram is a long polling connection serving a bot
+type Telegram struct {
+	config Config
+	bot    *bot.Bot
+	client *http.Client
+}
+
+// New creates a Telegram adapter for b
+func New(b *bot.Bot, config Config) *Telegram {
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultBaseURL
+	}
+	if config.PollTimeout == 0 {
+		config.PollTimeout = 30 * time.Second
+	}
+	if config.Thinking == "" {
+		config.Thinking = "…"
+	}
+	return &Telegram{
+		config: config,
+		bot:    b,
+		// Leave room for the long poll
+		client: &http.Client{Timeout: config.PollTimeout + 30*time.Second},
+	}
+}
+
+// NewFromEnv creates a Telegram adapter using TELEGRAM_BOT_TOKEN
+func NewFromEnv(b *bot.Bot) *Telegram {
+	return New(b, Config{
+		Token:            os.Getenv("TELEGRAM_BOT_TOKEN"),
+		RegisterCommands: true,
+	})
+}
+
+type update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *message `json:"message"`
+}
+
+type message struct {
+	MessageID int64 `json:"message_id"`
+	From      *struct {
+		ID        int64  `json:"id"`
+		IsBot     bool   `json:"is_bot"`
+		FirstName string `json:"first_name"`
+		Username  string `json:"username"`
+	} `json:"from"`
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Text  string `json:"text"`
+	Voice *struct {
+		FileID   string `json:"file_id"`
+		MimeType string `json:"mime_type"`
+	} `json:"voice"`
+}
+
+// Run polls for updates until ctx is cancelled, retrying with backoff when
+// polling fails
+func (t *Telegram) Run(ctx context.Context) error {
+	if t.config.Token == "" {
+		return errors.New("telegram: Token is required")
+	}
+	if t.config.RegisterCommands {
+		if err := t.registerCommands(ctx); err != nil {
+			return err
+		}
+	}
+
+	var offset int64
+	backoff := time.Second
+	for {
+		var updates []update
+		err := t.call(ctx, "getUpdates", map[string]any{
+			"offset":          offset,
+			"timeout":         int(t.config.PollTimeout / time.Second),
+			"allowed_updates": []string{"message"},
+		}, &updates)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			simplelog.LogErr(err, "telegram polling failed, retrying")
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, time.Minute)
+			continue
+		}
+		backoff = time.Second
+
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message != nil {
+				go t.handleMessage(ctx, u.Message)
+			}
+		}
+	}
+}
+
+func (t *Telegram) handleMessage(ctx context.Context, m *message) {
+	if m.From == nil || m.From.IsBot {
+		return
+	}
+
+	chat := m.Chat.ID
+	key := "telegram:" + strconv.FormatInt(chat, 10)
+	user := m.From.Username
+	if user == "" {
+		user = m.From.FirstName
+	}
+
+	text := m.Text
+	if m.Voice != nil {
+		if t.config.Transcribe == nil {
+			return
+		}
+		var err error
+		if text, err = t.transcribe(ctx, m.Voice.FileID, m.Voice.MimeType); err != nil {
+			simplelog.LogErr(err, "telegram voice transcription failed")
+			t.send(ctx, chat, "Sorry, I couldn't understand that voice note.")
+			return
+		}
+	}
+	if text == "" {
+		return
+	}
+
+	if name, args, ok := bot.ParseCommand(text); ok && name != "ask" {
+		if name == "start" {
+			name = "help"
+		}
+		reply, err := t.bot.Command(ctx, bot.Invocation{Command: name, Args: args, Key: key, User: user})
+		if err != nil {
+			reply = "Error: " + err.Error()
+		}
+		t.send(ctx, chat, reply)
+		return
+	} else if ok {
+		text = args
+	}
+
+	placeholder, err := t.send(ctx, chat, t.config.Thinking)
+	if err != nil {
+		simplelog.LogErr(err, "telegram send failed")
+		return
+	}
+
+	// Telegram rejects edits that don't change the text
+	shown := t.config.Thinking
+	edit := func(text string) error {
+		if text == shown {
+			return nil
+		}
+		shown = text
+		return t.call(ctx, "editMessageText", map[string]any{
+			"chat_id":    chat,
+			"message_id": placeholder,
+			"text":       text,
+		}, nil)
+	}
+	err = t.bot.Handle(ctx, key, user, text, func(reply string, done bool) error {
+		if reply == "" {
+			return nil
+		}
+		chunks := bot.Split(reply, MaxMessageLength)
+		if err := edit(chunks[0]); err != nil {
+			return err
+		}
+		if done {
+			for _, chunk := range chunks[1:] {
+				if _, err := t.send(ctx, chat, chunk); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		edit("Sorry, something went wrong: " + err.Error())
+	}
+}
+
+// transcribe downloads a voice note and passes it to the transcriber
+func (t *Telegram) transcribe(ctx context.Context, fileID, mimeType string) (string, error) {
+	var file struct {
+		FilePath string `json:"file_path"`
+	}
+	if err := t.call(ctx, "getFile", map[string]string{"file_id": fileID}, &file); err != nil {
+		return "", err
+	}
+
+	fileURL := t.config.BaseURL + "/file/bot" + t.config.Token + "/" + file.FilePath
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", unwrapURL(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("telegram: download voice note: status %d", resp.StatusCode)
+	}
+	audio, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if mimeType == "" {
+		mimeType = "audio/ogg"
+	}
+	return t.config.Transcribe(ctx, audio, mimeType)
+}
+
+// registerCommands publishes /ask and the bot's commands to the command menu
+func (t *Telegram) registerCommands(ctx context.Context) error {
+	commands := []map[string]string{{"command": "ask", "description": "Ask the assistant"}}
+	for _, cmd := range t.bot.Commands() {
+		commands = append(commands, map[string]string{"command": cmd.Name, "description": cmd.Description})
+	}
+	return t.call(ctx, "setMyCommands", map[string]any{"commands": commands}, nil)
+}
+
+// send sends text, split to the size limit, and returns the last message ID
+func (t *Telegram) send(ctx context.Context, chat int64, text string) (int64, error) {
+	var sent struct {
+		MessageID int64 `json:"message_id"`
+	}
+	for _, chunk := range bot.Split(text, MaxMessageLength) {
+		if err := t.call(ctx, "sendMessage", map[string]any{"chat_id": chat, "text": chunk}, &sent); err != nil {
+			return 0, err
+		}
+	}
+	return sent.MessageID, nil
+}
+
+// call invokes a Bot API method and unwraps its ok/result envelope
+func (t *Telegram) call(ctx context.Context, method string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	apiURL := t.config.BaseURL + "/bot" + t.config.Token + "/" + method
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram %s: %w", method, unwrapURL(err))
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("telegram %s: status %d: %w", method, resp.StatusCode, err)
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram %s: %s", method, result.Description)
+	}
+	if out != nil {
+		return json.Unmarshal(result.Result, out)
+	}
+	return nil
+}
+
+// unwrapURL drops the request URL from transport errors, since Bot API URLs
+// contain the token
+func unwrapURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
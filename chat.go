@@ -2,20 +2,67 @@ package simpleai
 
 import (
 	"context"
+	"maps"
+	"strings"
 	"sync"
+
+	"github.com/medatechnology/simpleai/contextbuilder"
+	"github.com/medatechnology/simpleai/internal/lang"
+	"github.com/medatechnology/simpleai/template"
 )
 
 // AutocompactConfig configures automatic conversation compaction
 type AutocompactConfig struct {
-	// Threshold is the message count that triggers compaction
+	// Threshold is the message count that triggers compaction (0 = off)
 	Threshold int
+	// TokenThreshold is the context size in tokens (system prompt, summary
+	// and history) that triggers compaction (0 = off). Tokens are counted
+	// with the chat's token counter, or the provider's estimate.
+	TokenThreshold int
 	// KeepRecent is how many recent messages to preserve (not summarized)
 	KeepRecent int
 	// Summarizer is an optional custom summarizer (uses memory.AISummarizer by default)
 	// If nil, uses the chat client's provider for summarization
 	Summarizer Summarizer
+	// Strategy selects how history is compacted (default CompactSummarize)
+	Strategy CompactStrategy
+	// KeepFirst is how many leading messages CompactDropMiddle preserves
+	KeepFirst int
+	// DedupThreshold is the similarity at which CompactDedup treats two turns
+	// as duplicates (default 0.9)
+	DedupThreshold float64
+	// Similarity scores two texts from 0 to 1 for CompactDedup
+	// (default: word overlap); plug in embeddings for semantic matching
+	Similarity func(a, b string) float64
+	// SummaryTemplate formats the summary for the prompt as a Go template
+	// with {{.Summary}} (default DefaultSummaryTemplate)
+	SummaryTemplate string
+	// SummaryPlacement is where the summary goes (default SummaryInSystem)
+	SummaryPlacement SummaryPlacement
+	// SummaryTokenBudget consolidates the accumulated summary into one
+	// shorter summary once it exceeds this many tokens (0 = off), so
+	// compaction stays effective in very long sessions
+	SummaryTokenBudget int
 }
 
+// DefaultSummaryTemplate is how the conversation summary is injected unless
+// AutocompactConfig.SummaryTemplate is set
+const DefaultSummaryTemplate = "[Previous conversation summary: {{.Summary}}]"
+
+// SummaryPlacement is where the conversation summary is injected
+type SummaryPlacement string
+
+const (
+	// SummaryInSystem appends the summary to the system prompt
+	SummaryInSystem SummaryPlacement = "system"
+	// SummaryAsMessage adds the summary as its own system message before
+	// the history
+	SummaryAsMessage SummaryPlacement = "message"
+	// SummaryBeforeMessage adds the summary as a system message right
+	// before the current user message
+	SummaryBeforeMessage SummaryPlacement = "before_message"
+)
+
 // Summarizer can summarize conversation history (mirrors memory.Summarizer)
 type Summarizer interface {
 	Summarize(ctx context.Context, messages []Message) (string, error)
@@ -24,11 +71,26 @@ type Summarizer interface {
 // DefaultAutocompactConfig returns sensible defaults for autocompact
 func DefaultAutocompactConfig() AutocompactConfig {
 	return AutocompactConfig{
-		Threshold:  20,
-		KeepRecent: 4,
+		Threshold:          20,
+		KeepRecent:         4,
+		SummaryTokenBudget: 1000,
 	}
 }
 
+// StreamErrorPolicy decides what Chat.Stream keeps in history when a
+// stream fails or closes without a final event
+type StreamErrorPolicy string
+
+const (
+	// StreamDiscardTurn removes the user message, as Send does on error (default)
+	StreamDiscardTurn StreamErrorPolicy = "discard_turn"
+	// StreamKeepUser keeps the user message but no reply
+	StreamKeepUser StreamErrorPolicy = "keep_user"
+	// StreamKeepPartial keeps the user message and the partial reply,
+	// marked with "incomplete" metadata
+	StreamKeepPartial StreamErrorPolicy = "keep_partial"
+)
+
 // Chat represents a conversation session with an AI provider
 type Chat struct {
 	client       *Client
@@ -39,8 +101,31 @@ type Chat struct {
 	tokenCounter func(string) int
 	mu           sync.RWMutex
 
+	// Request options applied to every turn
+	requestOptions []RequestOption
+
+	// What Stream keeps in history when a stream fails
+	streamErrorPolicy StreamErrorPolicy
+
+	// Token counts by message content, see countTokens
+	tokenCache map[string]int
+
+	// Saved states, oldest first, see Checkpoint
+	checkpoints []chatCheckpoint
+
+	// Conversation locale and how it is detected, see Locale
+	locale       string
+	detectLocale func(text string) string
+
+	// System prompt template rendered each turn, see WithSystemTemplate
+	systemTemplate string
+	systemVars     map[string]any
+
+	// Time and profile block added to the system prompt, see WithContextInjection
+	injection *ContextInjectionConfig
+
 	// Autocompact fields
-	autocompact       *AutocompactConfig
+	autocompact         *AutocompactConfig
 	conversationSummary string // Accumulated summary from compacted messages
 }
 
@@ -50,6 +135,7 @@ func NewChat(client *Client, opts ...ChatOption) *Chat {
 		client:       client,
 		history:      []Message{},
 		historyLimit: 100, // default limit
+		detectLocale: lang.Detect,
 	}
 
 	for _, opt := range opts {
@@ -59,25 +145,31 @@ func NewChat(client *Client, opts ...ChatOption) *Chat {
 	return c
 }
 
-// Send sends a user message and returns the assistant's response
-func (c *Chat) Send(ctx context.Context, message string) (*Response, error) {
+// Send sends a user message and returns the assistant's response.
+// Request options apply to this turn only.
+func (c *Chat) Send(ctx context.Context, message string, opts ...RequestOption) (*Response, error) {
+	return c.SendMessage(ctx, Message{Role: RoleUser, Content: message}, opts...)
+}
+
+// SendMessage is like Send but takes a full message, e.g. with Metadata
+// to keep in history. A missing role defaults to user, and the ID and
+// Timestamp are set if empty.
+func (c *Chat) SendMessage(ctx context.Context, msg Message, opts ...RequestOption) (*Response, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	// Add user message to history
-	c.history = append(c.history, Message{
-		Role:    RoleUser,
-		Content: message,
-	})
+	c.history = append(c.history, stampMessage(msg, RoleUser))
+	c.detectLocaleOf(msg.Content)
 
-	// Build request with full history
+	// Build request with full history; the system prompt travels in
+	// Messages, along with the summary
 	req := &Request{
-		Messages:     c.buildMessages(),
-		SystemPrompt: c.system,
+		Messages: c.buildMessages(),
 	}
 
 	// Send to provider
-	resp, err := c.client.Complete(ctx, req)
+	resp, err := c.client.Complete(ctx, req, c.turnOptions(opts)...)
 	if err != nil {
 		// Remove the user message on error
 		c.history = c.history[:len(c.history)-1]
@@ -85,10 +177,10 @@ func (c *Chat) Send(ctx context.Context, message string) (*Response, error) {
 	}
 
 	// Add assistant response to history
-	c.history = append(c.history, Message{
-		Role:    RoleAssistant,
-		Content: resp.Content,
-	})
+	c.history = append(c.history, stampMessage(Message{
+		Content:   resp.Content,
+		ToolCalls: resp.ToolCalls,
+	}, RoleAssistant))
 
 	// Trim history if needed
 	c.trimHistory()
@@ -96,60 +188,143 @@ func (c *Chat) Send(ctx context.Context, message string) (*Response, error) {
 	return resp, nil
 }
 
-// Stream sends a user message and streams the response
-func (c *Chat) Stream(ctx context.Context, message string) (<-chan StreamEvent, error) {
+// Stream sends a user message and streams the response.
+// Request options apply to this turn only.
+func (c *Chat) Stream(ctx context.Context, message string, opts ...RequestOption) (<-chan StreamEvent, error) {
+	return c.StreamMessage(ctx, Message{Role: RoleUser, Content: message}, opts...)
+}
+
+// StreamMessage is like Stream but takes a full message, as SendMessage does
+func (c *Chat) StreamMessage(ctx context.Context, msg Message, opts ...RequestOption) (<-chan StreamEvent, error) {
 	c.mu.Lock()
 
 	// Add user message to history
-	c.history = append(c.history, Message{
-		Role:    RoleUser,
-		Content: message,
-	})
+	msg = stampMessage(msg, RoleUser)
+	c.history = append(c.history, msg)
+	c.detectLocaleOf(msg.Content)
 
 	// Build request
 	req := &Request{
-		Messages:     c.buildMessages(),
-		SystemPrompt: c.system,
-		Stream:       true,
+		Messages: c.buildMessages(),
+		Stream:   true,
 	}
 
 	c.mu.Unlock()
 
 	// Get stream from provider
-	stream, err := c.client.Stream(ctx, req)
+	stream, err := c.client.Stream(ctx, req, c.turnOptions(opts)...)
 	if err != nil {
 		c.mu.Lock()
-		c.history = c.history[:len(c.history)-1]
+		c.removeMessage(msg.ID)
 		c.mu.Unlock()
 		return nil, err
 	}
 
-	// Create output channel that accumulates the response
+	// Create output channel that accumulates the response. History is
+	// finalized before the final event is delivered, or when the stream
+	// closes without one.
 	out := make(chan StreamEvent)
 	go func() {
 		defer close(out)
-		var fullContent string
+		var content strings.Builder
+		finalized := false
 
 		for event := range stream {
-			fullContent += event.Content
-			out <- event
-
-			if event.Done {
-				// Add complete response to history
-				c.mu.Lock()
-				c.history = append(c.history, Message{
-					Role:    RoleAssistant,
-					Content: fullContent,
-				})
-				c.trimHistory()
-				c.mu.Unlock()
+			content.WriteString(event.Content)
+
+			if event.Error != nil && !finalized {
+				c.finishStream(msg.ID, content.String(), nil, event.Error)
+				finalized = true
+			} else if event.Done && !finalized {
+				c.finishStream(msg.ID, content.String(), event.ToolCalls, nil)
+				finalized = true
+			}
+
+			// Keep draining if the consumer went away, so the provider
+			// goroutine can exit
+			select {
+			case out <- event:
+			case <-ctx.Done():
 			}
 		}
+
+		if !finalized {
+			c.finishStream(msg.ID, content.String(), nil, ErrStreamClosed)
+		}
 	}()
 
 	return out, nil
 }
 
+// finishStream records the outcome of a streamed turn: the reply on
+// success, with the tool calls from the final event, otherwise whatever the
+// stream error policy keeps
+func (c *Chat) finishStream(userID, content string, toolCalls []ToolCall, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err == nil {
+		c.history = append(c.history, stampMessage(Message{
+			Content:   content,
+			ToolCalls: toolCalls,
+		}, RoleAssistant))
+		c.trimHistory()
+		return
+	}
+
+	switch c.streamErrorPolicy {
+	case StreamKeepPartial:
+		if content != "" {
+			c.history = append(c.history, stampMessage(Message{
+				Content:  content,
+				Metadata: map[string]any{"incomplete": true, "error": err.Error()},
+			}, RoleAssistant))
+		}
+		c.trimHistory()
+	case StreamKeepUser:
+		c.trimHistory()
+	default:
+		c.removeMessage(userID)
+	}
+}
+
+// removeMessage drops the history message with id
+func (c *Chat) removeMessage(id string) {
+	for i := len(c.history) - 1; i >= 0; i-- {
+		if c.history[i].ID == id {
+			c.history = append(c.history[:i:i], c.history[i+1:]...)
+			return
+		}
+	}
+}
+
+// Annotate merges metadata into the history message with the given ID,
+// e.g. to attach source document IDs to an answer. It reports whether the
+// message was found.
+func (c *Chat) Annotate(id string, metadata map[string]any) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.history {
+		if c.history[i].ID != id {
+			continue
+		}
+		merged := make(map[string]any, len(c.history[i].Metadata)+len(metadata))
+		maps.Copy(merged, c.history[i].Metadata)
+		maps.Copy(merged, metadata)
+		c.history[i].Metadata = merged
+		return true
+	}
+	return false
+}
+
+// turnOptions puts the chat's request options before the per-call ones
+func (c *Chat) turnOptions(opts []RequestOption) []RequestOption {
+	if len(c.requestOptions) == 0 {
+		return opts
+	}
+	return append(append([]RequestOption(nil), c.requestOptions...), opts...)
+}
+
 // History returns a copy of the conversation history
 func (c *Chat) History() []Message {
 	c.mu.RLock()
@@ -165,57 +340,170 @@ func (c *Chat) Clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.history = []Message{}
+	c.tokenCache = nil
 }
 
-// SetSystem updates the system prompt
+// SetSystem updates the system prompt, replacing any system template
 func (c *Chat) SetSystem(prompt string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.system = prompt
+	c.systemTemplate = ""
 }
 
-// System returns the current system prompt
+// System returns the current system prompt, rendered if it is a template
 func (c *Chat) System() string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.system
+	return c.systemPrompt()
 }
 
-// buildMessages constructs the message list for the request
+// buildMessages constructs the message list for the request, fitting the
+// system prompt, summary, history and current message into maxTokens
 func (c *Chat) buildMessages() []Message {
+	if len(c.history) == 0 {
+		return nil
+	}
+	history := c.history[:len(c.history)-1]
+	current := c.history[len(c.history)-1]
+
+	// Tool calls and their results are kept or dropped together, since
+	// providers reject results whose call is missing. A current tool result
+	// keeps its whole turn.
+	units := toolUnits(history)
+	var pinned []Message
+	if current.Role == RoleTool && len(units) > 0 {
+		last := units[len(units)-1]
+		if history[last[0]].Role == RoleAssistant && len(history[last[0]].ToolCalls) > 0 {
+			pinned = history[last[0]:last[1]]
+			units = units[:len(units)-1]
+		}
+	}
+	items := make([]string, len(units))
+	for i, unit := range units {
+		items[i] = unitText(history[unit[0]:unit[1]])
+	}
+
+	system := c.systemPrompt()
+	if block := c.injectedContext(); block != "" {
+		if system != "" {
+			system += "\n\n"
+		}
+		system += block
+	}
+	builder := contextbuilder.New(c.maxTokens, c.countTokens)
+	if system != "" {
+		builder.Add(contextbuilder.Section{Name: "system", Items: []string{system}, Required: true})
+	}
+	builder.Add(contextbuilder.Section{Name: "message", Items: []string{unitText(pinned), current.Content}, Required: true})
+	// Recent turns matter more than the summary of older ones
+	builder.AddItems("history", items, 2, contextbuilder.DropOldest)
+	builder.AddText("summary", c.conversationSummary, 1, contextbuilder.TruncateStart)
+	built := builder.Build()
+
 	messages := make([]Message, 0, len(c.history)+2)
 
+	var summary *Message
+	if text := built.Section("summary").Text(""); text != "" {
+		summary = &Message{Role: RoleSystem, Content: c.formatSummary(text)}
+	}
+	placement := SummaryInSystem
+	if c.autocompact != nil && c.autocompact.SummaryPlacement != "" {
+		placement = c.autocompact.SummaryPlacement
+	}
+
 	// Add system message if present (for providers that need it in messages)
-	if c.system != "" {
-		systemContent := c.system
-		// Append conversation summary to system prompt if available
-		if c.conversationSummary != "" {
-			systemContent += "\n\n[Previous conversation summary: " + c.conversationSummary + "]"
+	systemContent := system
+	if summary != nil && placement == SummaryInSystem {
+		if systemContent != "" {
+			systemContent += "\n\n"
 		}
+		systemContent += summary.Content
+	}
+	if systemContent != "" {
 		messages = append(messages, Message{
 			Role:    RoleSystem,
 			Content: systemContent,
 		})
-	} else if c.conversationSummary != "" {
-		// If no system prompt but we have a summary, add it as a system message
-		messages = append(messages, Message{
-			Role:    RoleSystem,
-			Content: "[Previous conversation summary: " + c.conversationSummary + "]",
-		})
+	}
+	if summary != nil && placement == SummaryAsMessage {
+		messages = append(messages, *summary)
 	}
 
-	// Add conversation history
-	messages = append(messages, c.history...)
+	// Add the history that fits, then the current message
+	for _, i := range built.Section("history").Indexes {
+		messages = append(messages, history[units[i][0]:units[i][1]]...)
+	}
+	if summary != nil && placement == SummaryBeforeMessage {
+		messages = append(messages, *summary)
+	}
+	messages = append(messages, pinned...)
+	messages = append(messages, current)
 
 	return messages
 }
 
+// toolUnits splits history into the [start, end) ranges the context budget
+// keeps or drops whole: an assistant message with tool calls together with
+// the tool results that follow it, and every other message on its own.
+// Tool results whose call was trimmed from history are left out.
+func toolUnits(history []Message) [][2]int {
+	var units [][2]int
+	for i := 0; i < len(history); {
+		if history[i].Role == RoleTool {
+			i++
+			continue
+		}
+		end := i + 1
+		if history[i].Role == RoleAssistant && len(history[i].ToolCalls) > 0 {
+			for end < len(history) && history[end].Role == RoleTool {
+				end++
+			}
+		}
+		units = append(units, [2]int{i, end})
+		i = end
+	}
+	return units
+}
+
+// unitText is the text of messages that is counted against the budget
+func unitText(messages []Message) string {
+	var sb strings.Builder
+	for _, msg := range messages {
+		sb.WriteString(msg.Content)
+		for _, call := range msg.ToolCalls {
+			sb.WriteString(call.Name + call.Arguments)
+		}
+	}
+	return sb.String()
+}
+
+// formatSummary renders the summary with the configured template
+func (c *Chat) formatSummary(summary string) string {
+	tmpl := DefaultSummaryTemplate
+	if c.autocompact != nil && c.autocompact.SummaryTemplate != "" {
+		tmpl = c.autocompact.SummaryTemplate
+	}
+	text, err := template.Prompt(tmpl, map[string]any{"Summary": summary, "Locale": c.locale})
+	if err != nil {
+		return "[Previous conversation summary: " + summary + "]"
+	}
+	return text
+}
+
 // trimHistory removes old messages if over the limit
 func (c *Chat) trimHistory() {
+	defer c.pruneTokenCache()
+
 	// Check if autocompact should be triggered
-	if c.autocompact != nil && len(c.history) >= c.autocompact.Threshold {
-		c.compactHistory()
-		return
+	if c.shouldCompact() {
+		if c.autocompact.Strategy == "" || c.autocompact.Strategy == CompactSummarize {
+			c.compactHistory()
+			return
+		}
+		// The other strategies need no model call and may not shrink the
+		// history enough, so the limits below still apply
+		c.history = c.autocompact.compact(c.history)
 	}
 
 	// Trim by message count
@@ -226,12 +514,35 @@ func (c *Chat) trimHistory() {
 
 	// Trim by token count
 	if c.maxTokens > 0 && c.tokenCounter != nil {
-		for c.countHistoryTokens() > c.maxTokens && len(c.history) > 1 {
+		total := c.countHistoryTokens()
+		for total > c.maxTokens && len(c.history) > 1 {
+			total -= c.countTokens(c.history[0].Content)
 			c.history = c.history[1:]
 		}
 	}
 }
 
+// shouldCompact reports whether autocompact's message or token threshold
+// is reached
+func (c *Chat) shouldCompact() bool {
+	if c.autocompact == nil {
+		return false
+	}
+	if c.autocompact.Threshold > 0 && len(c.history) >= c.autocompact.Threshold {
+		return true
+	}
+	return c.autocompact.TokenThreshold > 0 && c.contextTokens() >= c.autocompact.TokenThreshold
+}
+
+// contextTokens counts the system prompt, summary and history
+func (c *Chat) contextTokens() int {
+	total := c.countTokens(c.systemPrompt()) + c.countTokens(c.conversationSummary)
+	for _, msg := range c.history {
+		total += c.countTokens(msg.Content)
+	}
+	return total
+}
+
 // countHistoryTokens returns the total tokens in history
 func (c *Chat) countHistoryTokens() int {
 	if c.tokenCounter == nil {
@@ -239,14 +550,60 @@ func (c *Chat) countHistoryTokens() int {
 	}
 	total := 0
 	for _, msg := range c.history {
-		total += c.tokenCounter(msg.Content)
+		total += c.countTokens(msg.Content)
 	}
 	return total
 }
 
+// countTokens counts text with the chat's token counter, or the provider's
+// estimate. Counts are cached by content so long histories aren't recounted
+// on every turn; the caller must hold the write lock.
+func (c *Chat) countTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	if n, ok := c.tokenCache[text]; ok {
+		return n
+	}
+
+	count := c.tokenCounter
+	if count == nil && c.client != nil {
+		count = c.client.CountTokens
+	}
+	if count == nil {
+		count = contextbuilder.EstimateTokens
+	}
+
+	n := count(text)
+	if c.tokenCache == nil {
+		c.tokenCache = make(map[string]int)
+	}
+	c.tokenCache[text] = n
+	return n
+}
+
+// pruneTokenCache drops cached counts of text that has left the chat
+func (c *Chat) pruneTokenCache() {
+	if len(c.tokenCache) <= 2*len(c.history)+8 {
+		return
+	}
+	keep := make(map[string]int, len(c.history)+2)
+	for _, text := range []string{c.systemPrompt(), c.conversationSummary} {
+		if n, ok := c.tokenCache[text]; ok {
+			keep[text] = n
+		}
+	}
+	for _, msg := range c.history {
+		if n, ok := c.tokenCache[msg.Content]; ok {
+			keep[msg.Content] = n
+		}
+	}
+	c.tokenCache = keep
+}
+
 // compactHistory summarizes old messages and keeps only recent ones
 func (c *Chat) compactHistory() {
-	if c.autocompact == nil || len(c.history) < c.autocompact.Threshold {
+	if !c.shouldCompact() {
 		return
 	}
 
@@ -259,42 +616,9 @@ func (c *Chat) compactHistory() {
 	oldMessages := c.history[:len(c.history)-keepRecent]
 	recentMessages := c.history[len(c.history)-keepRecent:]
 
-	var summaryContent string
-	var err error
-
 	// Unlock before making AI call to avoid deadlock
 	c.mu.Unlock()
-
-	// Use custom summarizer if provided, otherwise use default AI summarization
-	if c.autocompact.Summarizer != nil {
-		summaryContent, err = c.autocompact.Summarizer.Summarize(context.Background(), oldMessages)
-	} else {
-		// Default: use chat client's provider for summarization
-		var conversationText string
-		for _, msg := range oldMessages {
-			conversationText += string(msg.Role) + ": " + msg.Content + "\n\n"
-		}
-
-		summaryReq := &Request{
-			Messages: []Message{
-				{
-					Role:    RoleUser,
-					Content: "Summarize this conversation concisely, preserving key information:\n\n" + conversationText,
-				},
-			},
-			MaxTokens:   500,
-			Temperature: 0.3,
-		}
-
-		summaryResp, reqErr := c.client.Complete(context.Background(), summaryReq)
-		if reqErr != nil {
-			err = reqErr
-		} else {
-			summaryContent = summaryResp.Content
-		}
-	}
-
-	// Relock after AI call
+	summaryContent, err := c.summarize(oldMessages)
 	c.mu.Lock()
 
 	if err != nil {
@@ -312,6 +636,88 @@ func (c *Chat) compactHistory() {
 
 	// Keep only recent messages
 	c.history = recentMessages
+
+	c.consolidateSummary()
+}
+
+// summarize summarizes messages with the custom summarizer, or the chat's
+// client. The caller must not hold the lock.
+func (c *Chat) summarize(messages []Message) (string, error) {
+	// Use custom summarizer if provided, otherwise use default AI summarization
+	if c.autocompact.Summarizer != nil {
+		return c.autocompact.Summarizer.Summarize(context.Background(), messages)
+	}
+
+	// Default: use chat client's provider for summarization
+	var conversationText string
+	for _, msg := range messages {
+		speaker := string(msg.Role)
+		if msg.Name != "" {
+			speaker = msg.Name
+		}
+		conversationText += speaker + ": " + msg.Content + "\n\n"
+	}
+
+	summaryReq := &Request{
+		Messages: []Message{
+			{
+				Role:    RoleUser,
+				Content: "Summarize this conversation concisely, preserving key information:\n\n" + conversationText,
+			},
+		},
+		MaxTokens:   500,
+		Temperature: 0.3,
+	}
+
+	summaryResp, err := c.client.Complete(context.Background(), summaryReq)
+	if err != nil {
+		return "", err
+	}
+	return summaryResp.Content, nil
+}
+
+// consolidateSummary re-summarizes the accumulated summary once it exceeds
+// SummaryTokenBudget. Without it the summary only grows, one appended
+// summary per compaction. On failure the summary is kept as is.
+func (c *Chat) consolidateSummary() {
+	budget := c.autocompact.SummaryTokenBudget
+	if budget <= 0 || c.countTokens(c.conversationSummary) <= budget {
+		return
+	}
+	summary := c.conversationSummary
+
+	c.mu.Unlock()
+	var consolidated string
+	var err error
+	if c.autocompact.Summarizer != nil {
+		consolidated, err = c.autocompact.Summarizer.Summarize(context.Background(), []Message{
+			{Role: RoleSystem, Content: "Summary of the earlier conversation:\n\n" + summary},
+		})
+	} else {
+		var resp *Response
+		resp, err = c.client.Complete(context.Background(), &Request{
+			Messages: []Message{
+				{
+					Role: RoleUser,
+					Content: "These are summaries of successive parts of one conversation, oldest first. " +
+						"Merge them into a single concise summary. Keep names, facts, decisions and open tasks; " +
+						"drop details that later parts superseded.\n\n" + summary,
+				},
+			},
+			MaxTokens:   budget,
+			Temperature: 0.3,
+		})
+		if err == nil {
+			consolidated = resp.Content
+		}
+	}
+	c.mu.Lock()
+
+	// Keep the summary if this failed or it changed meanwhile
+	if err != nil || strings.TrimSpace(consolidated) == "" || c.conversationSummary != summary {
+		return
+	}
+	c.conversationSummary = consolidated
 }
 
 // Summary returns the current conversation summary
@@ -320,4 +726,3 @@ func (c *Chat) Summary() string {
 	defer c.mu.RUnlock()
 	return c.conversationSummary
 }
-
@@ -0,0 +1,243 @@
+package simpleai
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// scriptedProvider streams a fixed list of events and closes the channel
+type scriptedProvider struct {
+	events []StreamEvent
+}
+
+func (p *scriptedProvider) Name() string { return "scripted" }
+
+func (p *scriptedProvider) CountTokens(text string) int { return len(text) / 4 }
+
+func (p *scriptedProvider) Complete(ctx context.Context, req *Request) (*Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (p *scriptedProvider) Stream(ctx context.Context, req *Request) (<-chan StreamEvent, error) {
+	ch := make(chan StreamEvent, len(p.events))
+	for _, event := range p.events {
+		ch <- event
+	}
+	close(ch)
+	return ch, nil
+}
+
+func TestChatStreamHistory(t *testing.T) {
+	errMidStream := errors.New("connection reset")
+	partial := []StreamEvent{{Content: "Once "}, {Content: "upon"}}
+
+	scenarios := map[string]struct {
+		events []StreamEvent
+		err    error // Expected on a partial reply's metadata
+	}{
+		"closed without done": {events: partial, err: ErrStreamClosed},
+		"error mid-stream":    {events: append(partial, StreamEvent{Error: errMidStream, Done: true}), err: errMidStream},
+	}
+
+	policies := []struct {
+		policy StreamErrorPolicy
+		want   []Message // Roles and content of the history
+	}{
+		{StreamDiscardTurn, nil},
+		{StreamKeepUser, []Message{{Role: RoleUser, Content: "Tell me a story"}}},
+		{StreamKeepPartial, []Message{
+			{Role: RoleUser, Content: "Tell me a story"},
+			{Role: RoleAssistant, Content: "Once upon"},
+		}},
+	}
+
+	for name, scenario := range scenarios {
+		for _, p := range policies {
+			t.Run(name+"/"+string(p.policy), func(t *testing.T) {
+				client := NewClient(&scriptedProvider{events: scenario.events})
+				chat := NewChat(client, WithStreamErrorPolicy(p.policy))
+
+				content := streamAll(t, chat, "Tell me a story")
+				if content != "Once upon" {
+					t.Errorf("streamed content = %q, want %q", content, "Once upon")
+				}
+
+				history := chat.History()
+				assertHistory(t, history, p.want)
+				if p.policy == StreamKeepPartial {
+					reply := history[len(history)-1]
+					if reply.Metadata["incomplete"] != true {
+						t.Errorf("partial reply metadata = %v, want incomplete", reply.Metadata)
+					}
+					if reply.Metadata["error"] != scenario.err.Error() {
+						t.Errorf("partial reply error = %v, want %q", reply.Metadata["error"], scenario.err.Error())
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestChatStreamKeepPartialWithoutContent(t *testing.T) {
+	client := NewClient(&scriptedProvider{events: []StreamEvent{{Error: errors.New("overloaded"), Done: true}}})
+	chat := NewChat(client, WithStreamErrorPolicy(StreamKeepPartial))
+
+	streamAll(t, chat, "Hello")
+	assertHistory(t, chat.History(), []Message{{Role: RoleUser, Content: "Hello"}})
+}
+
+func TestChatStreamSuccess(t *testing.T) {
+	client := NewClient(&scriptedProvider{events: []StreamEvent{
+		{Content: "Hi"},
+		{Content: " there"},
+		{Done: true, FinishReason: "stop"},
+	}})
+	chat := NewChat(client)
+
+	streamAll(t, chat, "Hello")
+	assertHistory(t, chat.History(), []Message{
+		{Role: RoleUser, Content: "Hello"},
+		{Role: RoleAssistant, Content: "Hi there"},
+	})
+
+	// The turn is complete, so the next one sees it
+	streamAll(t, chat, "Again")
+	if got := len(chat.History()); got != 4 {
+		t.Errorf("history has %d messages after a second turn, want 4", got)
+	}
+}
+
+// streamAll sends message and reads the stream to the end
+func streamAll(t *testing.T, chat *Chat, message string) string {
+	t.Helper()
+	stream, err := chat.Stream(context.Background(), message)
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	var content string
+	for event := range stream {
+		content += event.Content
+	}
+	return content
+}
+
+func assertHistory(t *testing.T, got, want []Message) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("history has %d messages, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].Role != want[i].Role || got[i].Content != want[i].Content {
+			t.Errorf("history[%d] = %s %q, want %s %q", i, got[i].Role, got[i].Content, want[i].Role, want[i].Content)
+		}
+	}
+}
+
+func TestChatBudgetKeepsToolCallsWithResults(t *testing.T) {
+	chat := NewChat(NewClient(&scriptedProvider{}))
+	chat.maxTokens = 16
+	chat.tokenCounter = func(text string) int { return len(text) / 4 }
+	chat.history = []Message{
+		{Role: RoleTool, ToolCallID: "0", Content: "result of a trimmed call"},
+		{Role: RoleUser, Content: "What's the weather in Paris and Berlin?"},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "1", Name: "weather", Arguments: `{"city":"Paris"}`}}},
+		{Role: RoleTool, ToolCallID: "1", Content: "sunny"},
+		{Role: RoleAssistant, Content: "Paris is sunny."},
+		{Role: RoleUser, Content: "Thanks"},
+	}
+
+	assertHistory(t, chat.buildMessages(), []Message{
+		{Role: RoleAssistant},
+		{Role: RoleTool, Content: "sunny"},
+		{Role: RoleAssistant, Content: "Paris is sunny."},
+		{Role: RoleUser, Content: "Thanks"},
+	})
+
+	// Shrinking the budget drops the call and its result together
+	chat.maxTokens = 8
+	assertHistory(t, chat.buildMessages(), []Message{
+		{Role: RoleAssistant, Content: "Paris is sunny."},
+		{Role: RoleUser, Content: "Thanks"},
+	})
+}
+
+// toolProvider asks for the weather tool until it gets its result, and
+// records the messages of every request
+type toolProvider struct {
+	scriptedProvider
+	requests [][]Message
+}
+
+func (p *toolProvider) reply(req *Request) *Response {
+	p.requests = append(p.requests, append([]Message(nil), req.Messages...))
+	if last := req.Messages[len(req.Messages)-1]; last.Role == RoleTool {
+		return &Response{Content: "Paris is " + last.Content + ".", FinishReason: "stop"}
+	}
+	return &Response{
+		ToolCalls:    []ToolCall{{ID: "call_1", Name: "weather", Arguments: `{"city":"Paris"}`}},
+		FinishReason: "tool_calls",
+	}
+}
+
+func (p *toolProvider) Complete(ctx context.Context, req *Request) (*Response, error) {
+	return p.reply(req), nil
+}
+
+func (p *toolProvider) Stream(ctx context.Context, req *Request) (<-chan StreamEvent, error) {
+	resp := p.reply(req)
+	ch := make(chan StreamEvent, 2)
+	if resp.Content != "" {
+		ch <- StreamEvent{Content: resp.Content}
+	}
+	ch <- StreamEvent{Done: true, FinishReason: resp.FinishReason, ToolCalls: resp.ToolCalls}
+	close(ch)
+	return ch, nil
+}
+
+func TestChatToolRoundTrip(t *testing.T) {
+	result := Message{Role: RoleTool, ToolCallID: "call_1", Content: "sunny"}
+	turns := map[string]func(t *testing.T, chat *Chat, msg Message){
+		"send": func(t *testing.T, chat *Chat, msg Message) {
+			if _, err := chat.SendMessage(context.Background(), msg); err != nil {
+				t.Fatalf("SendMessage: %v", err)
+			}
+		},
+		"stream": func(t *testing.T, chat *Chat, msg Message) {
+			stream, err := chat.StreamMessage(context.Background(), msg)
+			if err != nil {
+				t.Fatalf("StreamMessage: %v", err)
+			}
+			for range stream {
+			}
+		},
+	}
+
+	for name, turn := range turns {
+		t.Run(name, func(t *testing.T) {
+			provider := &toolProvider{}
+			chat := NewChat(NewClient(provider))
+
+			turn(t, chat, Message{Role: RoleUser, Content: "What's the weather in Paris?"})
+			turn(t, chat, result)
+
+			history := chat.History()
+			assertHistory(t, history, []Message{
+				{Role: RoleUser, Content: "What's the weather in Paris?"},
+				{Role: RoleAssistant},
+				{Role: RoleTool, Content: "sunny"},
+				{Role: RoleAssistant, Content: "Paris is sunny."},
+			})
+			if calls := history[1].ToolCalls; len(calls) != 1 || calls[0].ID != "call_1" {
+				t.Errorf("assistant tool calls in history = %+v, want call_1", calls)
+			}
+
+			// The result is sent with the call that produced it
+			sent := provider.requests[len(provider.requests)-1]
+			assertHistory(t, sent, history[:3])
+			if len(sent[1].ToolCalls) != 1 {
+				t.Errorf("tool result was sent without its call: %+v", sent)
+			}
+		})
+	}
+}
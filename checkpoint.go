@@ -0,0 +1,85 @@
+package simpleai
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"maps"
+	"slices"
+)
+
+// ErrCheckpointNotFound is returned by Rollback for an unknown checkpoint
+var ErrCheckpointNotFound = errors.New("simpleai: checkpoint not found")
+
+// chatCheckpoint is a saved chat state
+type chatCheckpoint struct {
+	id             string
+	history        []Message
+	system         string
+	systemTemplate string
+	systemVars     map[string]any
+	locale         string
+	summary        string
+}
+
+// Checkpoint saves the chat's history, system prompt, locale and summary and
+// returns an ID to roll back to, e.g. before an agent experiment or a tool
+// sequence that may fail. Checkpoints are kept in memory with the chat;
+// messages are copied, not their metadata or documents.
+func (c *Chat) Checkpoint() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := make([]byte, 8)
+	rand.Read(b)
+	id := "ckpt_" + hex.EncodeToString(b)
+	c.checkpoints = append(c.checkpoints, chatCheckpoint{
+		id:             id,
+		history:        slices.Clone(c.history),
+		system:         c.system,
+		systemTemplate: c.systemTemplate,
+		systemVars:     maps.Clone(c.systemVars),
+		locale:         c.locale,
+		summary:        c.conversationSummary,
+	})
+	return id
+}
+
+// Rollback restores the chat to a checkpoint. The checkpoint and any taken
+// after it stay available, so a rollback can itself be undone.
+func (c *Chat) Rollback(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i := slices.IndexFunc(c.checkpoints, func(cp chatCheckpoint) bool { return cp.id == id })
+	if i < 0 {
+		return ErrCheckpointNotFound
+	}
+	checkpoint := c.checkpoints[i]
+	c.history = slices.Clone(checkpoint.history)
+	c.system = checkpoint.system
+	c.systemTemplate = checkpoint.systemTemplate
+	c.systemVars = maps.Clone(checkpoint.systemVars)
+	c.locale = checkpoint.locale
+	c.conversationSummary = checkpoint.summary
+	return nil
+}
+
+// ReleaseCheckpoint forgets a checkpoint that is no longer needed
+func (c *Chat) ReleaseCheckpoint(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkpoints = slices.DeleteFunc(c.checkpoints, func(cp chatCheckpoint) bool { return cp.id == id })
+}
+
+// Checkpoints returns the IDs of the saved checkpoints, oldest first
+func (c *Chat) Checkpoints() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ids := make([]string, len(c.checkpoints))
+	for i, checkpoint := range c.checkpoints {
+		ids[i] = checkpoint.id
+	}
+	return ids
+}
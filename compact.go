@@ -0,0 +1,137 @@
+package simpleai
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CompactStrategy selects how autocompact shrinks a long history
+type CompactStrategy string
+
+const (
+	// CompactSummarize summarizes old messages with an extra model call
+	CompactSummarize CompactStrategy = "summarize"
+	// CompactDropMiddle keeps the first KeepFirst and last KeepRecent
+	// messages and drops the rest
+	CompactDropMiddle CompactStrategy = "drop_middle"
+	// CompactDedup drops older turns that nearly repeat a later one
+	CompactDedup CompactStrategy = "dedup"
+	// CompactElideTools replaces old tool outputs with a short placeholder
+	CompactElideTools CompactStrategy = "elide_tools"
+)
+
+// elideMinLength is the shortest tool output worth eliding
+const elideMinLength = 200
+
+// compact applies a strategy that needs no model call
+func (a *AutocompactConfig) compact(history []Message) []Message {
+	keepRecent := min(max(a.KeepRecent, 0), len(history))
+	switch a.Strategy {
+	case CompactDropMiddle:
+		keepFirst := min(max(a.KeepFirst, 0), len(history)-keepRecent)
+		result := append([]Message(nil), history[:keepFirst]...)
+		return append(result, history[len(history)-keepRecent:]...)
+
+	case CompactDedup:
+		threshold := a.DedupThreshold
+		if threshold == 0 {
+			threshold = 0.9
+		}
+		similarity := a.Similarity
+		if similarity == nil {
+			similarity = WordSimilarity
+		}
+		return dedupTurns(history, len(history)-keepRecent, threshold, similarity)
+
+	case CompactElideTools:
+		result := append([]Message(nil), history...)
+		for i := range result[:len(result)-keepRecent] {
+			if result[i].Role == RoleTool && len(result[i].Content) >= elideMinLength {
+				result[i].Content = fmt.Sprintf("[tool output elided, %d characters]", len(result[i].Content))
+			}
+		}
+		return result
+	}
+	return history
+}
+
+// dedupTurns drops turns (a user message and the replies that follow it)
+// starting before limit that nearly repeat a later turn
+func dedupTurns(history []Message, limit int, threshold float64, similarity func(a, b string) float64) []Message {
+	// Split into turns
+	var starts []int
+	for i, msg := range history {
+		if i == 0 || msg.Role == RoleUser && history[i-1].Role != RoleUser {
+			starts = append(starts, i)
+		}
+	}
+
+	texts := make([]string, len(starts))
+	for t, start := range starts {
+		end := len(history)
+		if t+1 < len(starts) {
+			end = starts[t+1]
+		}
+		var sb strings.Builder
+		for _, msg := range history[start:end] {
+			sb.WriteString(msg.Content)
+			sb.WriteString("\n")
+		}
+		texts[t] = sb.String()
+	}
+
+	// Newest first, so the latest of near-duplicates survives
+	drop := make([]bool, len(starts))
+	for t := len(starts) - 1; t >= 0; t-- {
+		if starts[t] >= limit {
+			continue
+		}
+		for later := t + 1; later < len(starts); later++ {
+			if !drop[later] && similarity(texts[t], texts[later]) >= threshold {
+				drop[t] = true
+				break
+			}
+		}
+	}
+
+	var result []Message
+	for t, start := range starts {
+		if drop[t] {
+			continue
+		}
+		end := len(history)
+		if t+1 < len(starts) {
+			end = starts[t+1]
+		}
+		result = append(result, history[start:end]...)
+	}
+	return result
+}
+
+// WordSimilarity is the Jaccard similarity of the lowercased word sets of
+// a and b, from 0 (nothing shared) to 1 (same words)
+func WordSimilarity(a, b string) float64 {
+	wordsA := wordSet(a)
+	wordsB := wordSet(b)
+	if len(wordsA) == 0 && len(wordsB) == 0 {
+		return 1
+	}
+
+	shared := 0
+	for w := range wordsA {
+		if wordsB[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wordsA)+len(wordsB)-shared)
+}
+
+func wordSet(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !('a' <= r && r <= 'z' || '0' <= r && r <= '9' || r > 127)
+	}) {
+		set[w] = true
+	}
+	return set
+}
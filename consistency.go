@@ -0,0 +1,216 @@
+package simpleai
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/medatechnology/simpleai/embedding"
+)
+
+// ConsistencyConfig configures self-consistency sampling
+type ConsistencyConfig struct {
+	// Samples is how many answers are generated (default 5)
+	Samples int
+
+	// Temperature is the sampling temperature, high enough for the
+	// reasoning paths to differ (default 0.8)
+	Temperature float64
+
+	// Concurrency is how many samples run at once (default: all)
+	Concurrency int
+
+	// Extract reduces a reply to the answer that is compared, e.g. the
+	// number after "Answer:" in a worked solution (default: the whole
+	// reply)
+	Extract func(content string) string
+
+	// Embedder groups answers by meaning; nil groups answers that match
+	// exactly, ignoring case, spacing and trailing punctuation
+	Embedder embedding.Embedder
+
+	// Threshold is the cosine similarity at which Embedder answers count
+	// as the same (default 0.9)
+	Threshold float64
+}
+
+// DefaultConsistencyConfig returns the default sampling settings
+func DefaultConsistencyConfig() ConsistencyConfig {
+	return ConsistencyConfig{
+		Samples:     5,
+		Temperature: 0.8,
+		Threshold:   0.9,
+	}
+}
+
+// ConsistencyCluster is a group of samples giving the same answer
+type ConsistencyCluster struct {
+	Answer  string // The representative sample's answer
+	Samples []int  // Indexes into ConsistencyResult.Samples
+}
+
+// ConsistencyResult is the consensus of a self-consistency run
+type ConsistencyResult struct {
+	// Answer is the consensus answer and Response the sample it comes from
+	Answer   string
+	Response *Response
+
+	// Confidence is the share of successful samples that agree with the
+	// consensus, from 0 to 1
+	Confidence float64
+
+	Clusters []ConsistencyCluster // Largest first
+	Samples  []*Response          // Nil where a sample failed
+	Usage    Usage                // Summed over all samples
+}
+
+// CompleteConsistent samples several answers to req and returns the one
+// most of them agree on, with the share that agrees as its confidence.
+// This self-consistency technique improves accuracy on reasoning and
+// factual questions at the cost of one call per sample. Failed samples
+// are left out; it returns an error only if all of them fail. With an
+// idempotency key, each sample gets its own key derived from it.
+func (c *Client) CompleteConsistent(ctx context.Context, req *Request, config ConsistencyConfig, opts ...RequestOption) (*ConsistencyResult, error) {
+	defaults := DefaultConsistencyConfig()
+	if config.Samples <= 0 {
+		config.Samples = defaults.Samples
+	}
+	if config.Temperature <= 0 {
+		config.Temperature = defaults.Temperature
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = config.Samples
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = defaults.Threshold
+	}
+	if config.Extract == nil {
+		config.Extract = strings.TrimSpace
+	}
+
+	ctx, req = applyRequestOptions(ctx, req, append([]RequestOption{WithTemperature(config.Temperature)}, opts...))
+
+	samples := make([]*Response, config.Samples)
+	errs := make([]error, config.Samples)
+	sem := make(chan struct{}, config.Concurrency)
+	var wg sync.WaitGroup
+	for i := range samples {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			samples[i], errs[i] = c.Complete(subIdempotencyKey(ctx, strconv.Itoa(i)), req)
+		}()
+	}
+	wg.Wait()
+
+	result := &ConsistencyResult{Samples: samples}
+	var answers []string
+	var indexes []int
+	for i, resp := range samples {
+		if errs[i] != nil || resp == nil {
+			continue
+		}
+		result.Usage.PromptTokens += resp.Usage.PromptTokens
+		result.Usage.CompletionTokens += resp.Usage.CompletionTokens
+		result.Usage.TotalTokens += resp.Usage.TotalTokens
+		answers = append(answers, config.Extract(resp.Content))
+		indexes = append(indexes, i)
+	}
+	if len(answers) == 0 {
+		return nil, errs[0]
+	}
+
+	var clusters []ConsistencyCluster
+	var err error
+	if config.Embedder != nil {
+		clusters, err = clusterBySimilarity(ctx, config.Embedder, config.Threshold, answers, indexes)
+		if err != nil {
+			return nil, fmt.Errorf("simpleai: embedding answers: %w", err)
+		}
+	} else {
+		clusters = clusterByAnswer(answers, indexes)
+	}
+	// Stable, so ties go to the answer seen first
+	slices.SortStableFunc(clusters, func(a, b ConsistencyCluster) int {
+		return len(b.Samples) - len(a.Samples)
+	})
+
+	result.Clusters = clusters
+	result.Answer = clusters[0].Answer
+	result.Response = samples[clusters[0].Samples[0]]
+	result.Confidence = float64(len(clusters[0].Samples)) / float64(len(answers))
+	return result, nil
+}
+
+// clusterByAnswer groups samples whose normalized answers are equal
+func clusterByAnswer(answers []string, indexes []int) []ConsistencyCluster {
+	var clusters []ConsistencyCluster
+	byKey := make(map[string]int)
+	for i, answer := range answers {
+		key := normalizeAnswer(answer)
+		if c, ok := byKey[key]; ok {
+			clusters[c].Samples = append(clusters[c].Samples, indexes[i])
+			continue
+		}
+		byKey[key] = len(clusters)
+		clusters = append(clusters, ConsistencyCluster{Answer: answer, Samples: []int{indexes[i]}})
+	}
+	return clusters
+}
+
+// clusterBySimilarity adds each answer to the first cluster whose first
+// answer is similar enough, then makes the answer closest to the rest of
+// its cluster the representative, first in Samples
+func clusterBySimilarity(ctx context.Context, embedder embedding.Embedder, threshold float64, answers []string, indexes []int) ([]ConsistencyCluster, error) {
+	vectors, err := embedder.EmbedBatch(ctx, answers)
+	if err != nil {
+		return nil, err
+	}
+
+	var members [][]int // Positions in answers, per cluster
+	for i := range answers {
+		joined := false
+		for c, m := range members {
+			if embedding.CosineSimilarity(vectors[i], vectors[m[0]]) >= threshold {
+				members[c] = append(m, i)
+				joined = true
+				break
+			}
+		}
+		if !joined {
+			members = append(members, []int{i})
+		}
+	}
+
+	clusters := make([]ConsistencyCluster, len(members))
+	for c, m := range members {
+		best, bestScore := 0, -1.0
+		for j, a := range m {
+			score := 0.0
+			for _, b := range m {
+				score += embedding.CosineSimilarity(vectors[a], vectors[b])
+			}
+			if score > bestScore {
+				best, bestScore = j, score
+			}
+		}
+		m[0], m[best] = m[best], m[0]
+
+		clusters[c].Answer = answers[m[0]]
+		for _, a := range m {
+			clusters[c].Samples = append(clusters[c].Samples, indexes[a])
+		}
+	}
+	return clusters, nil
+}
+
+// normalizeAnswer ignores case, spacing and trailing punctuation
+func normalizeAnswer(answer string) string {
+	answer = strings.Join(strings.Fields(strings.ToLower(answer)), " ")
+	return strings.TrimRight(answer, ".!?")
+}
@@ -0,0 +1,323 @@
+// Package contextbuilder assembles prompt context (system prompt, retrieved
+// documents, memory, history, the current message) under a token budget.
+// Sections are filled in priority order and trimmed with a per-section
+// truncation strategy, so the most important context always fits.
+package contextbuilder
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Strategy decides how a section is trimmed when it doesn't fit
+type Strategy int
+
+const (
+	// DropOldest drops whole items from the start (e.g. old history)
+	DropOldest Strategy = iota
+	// DropNewest drops whole items from the end (e.g. less relevant documents)
+	DropNewest
+	// TruncateEnd keeps the beginning, cutting the last item that fits partially
+	TruncateEnd
+	// TruncateStart keeps the end, cutting the first item that fits partially
+	TruncateStart
+	// DropSection keeps the section whole or not at all
+	DropSection
+)
+
+// Section is a named part of the context. Items are its units, such as
+// messages or documents; plain text is a single item.
+type Section struct {
+	Name     string
+	Items    []string
+	Priority int // Higher priorities get budget first
+	Strategy Strategy
+
+	// Required sections are always included in full and are charged first,
+	// even if they exceed the budget
+	Required bool
+
+	// MaxTokens caps the section's share of the budget (0 = no cap)
+	MaxTokens int
+}
+
+// Built is a section after budgeting
+type Built struct {
+	Name      string
+	Items     []string // Kept items, in their original order
+	Indexes   []int    // Indexes of the kept items in Section.Items
+	Tokens    int
+	Truncated bool // Items were dropped or cut
+
+	// Duplicates are the indexes of items dropped because they repeat
+	// context from a more important section (see Builder.Deduplicate)
+	Duplicates []int
+}
+
+// Text joins the kept items with sep
+func (b *Built) Text(sep string) string {
+	if b == nil {
+		return ""
+	}
+	return strings.Join(b.Items, sep)
+}
+
+// Result is the assembled context
+type Result struct {
+	Sections  []Built // In the order they were added
+	Tokens    int
+	Truncated bool
+}
+
+// Section returns the built section with name, or nil
+func (r *Result) Section(name string) *Built {
+	for i := range r.Sections {
+		if r.Sections[i].Name == name {
+			return &r.Sections[i]
+		}
+	}
+	return nil
+}
+
+// Text joins non-empty sections with blank lines and items with newlines
+func (r *Result) Text() string {
+	var parts []string
+	for _, s := range r.Sections {
+		if text := s.Text("\n"); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Builder collects sections and fits them into a budget
+type Builder struct {
+	budget   int
+	counter  func(string) int
+	sections []Section
+	dedup    *Dedup
+}
+
+// New creates a builder with a token budget (0 = unlimited). A nil counter
+// uses EstimateTokens.
+func New(budget int, counter func(string) int) *Builder {
+	if counter == nil {
+		counter = EstimateTokens
+	}
+	return &Builder{budget: budget, counter: counter}
+}
+
+// EstimateTokens estimates tokens as ~4 characters per token
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// Add adds a section
+func (b *Builder) Add(section Section) *Builder {
+	b.sections = append(b.sections, section)
+	return b
+}
+
+// AddText adds a single-item text section; empty text is skipped
+func (b *Builder) AddText(name, text string, priority int, strategy Strategy) *Builder {
+	if text == "" {
+		return b
+	}
+	return b.Add(Section{Name: name, Items: []string{text}, Priority: priority, Strategy: strategy})
+}
+
+// AddItems adds a multi-item section
+func (b *Builder) AddItems(name string, items []string, priority int, strategy Strategy) *Builder {
+	return b.Add(Section{Name: name, Items: items, Priority: priority, Strategy: strategy})
+}
+
+// Deduplicate drops items that repeat context already included from a
+// section that was filled before, such as retrieved documents overlapping
+// recent history. See NewDedup for threshold and minWords.
+func (b *Builder) Deduplicate(threshold float64, minWords int) *Builder {
+	b.dedup = NewDedup(threshold, minWords)
+	return b
+}
+
+// Build fits the sections into the budget
+func (b *Builder) Build() *Result {
+	result := &Result{Sections: make([]Built, len(b.sections))}
+
+	// Required sections first, then by priority; ties keep insertion order
+	order := make([]int, len(b.sections))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		si, sj := b.sections[order[i]], b.sections[order[j]]
+		if si.Required != sj.Required {
+			return si.Required
+		}
+		return si.Priority > sj.Priority
+	})
+
+	remaining := b.budget
+	for _, i := range order {
+		section := b.sections[i]
+		allowance := -1 // Unlimited
+		if b.budget > 0 && !section.Required {
+			allowance = max(remaining, 0)
+		}
+		if section.MaxTokens > 0 && !section.Required && (allowance < 0 || section.MaxTokens < allowance) {
+			allowance = section.MaxTokens
+		}
+
+		var built Built
+		if b.dedup != nil {
+			built = b.fitUnique(section, allowance)
+		} else {
+			built = b.fit(section, allowance)
+		}
+		result.Sections[i] = built
+		result.Tokens += built.Tokens
+		result.Truncated = result.Truncated || built.Truncated
+		remaining -= built.Tokens
+	}
+	return result
+}
+
+// fitUnique fits the items of s that repeat neither earlier sections nor
+// each other, then records the kept items so later sections don't repeat
+// them
+func (b *Builder) fitUnique(s Section, allowance int) Built {
+	unique := s
+	unique.Items = nil
+	local := NewDedup(b.dedup.threshold, b.dedup.minWords)
+	var original, duplicates []int
+	for i, item := range s.Items {
+		if b.dedup.Contains(item) || local.Duplicate(item) {
+			duplicates = append(duplicates, i)
+			continue
+		}
+		unique.Items = append(unique.Items, item)
+		original = append(original, i)
+	}
+
+	built := b.fit(unique, allowance)
+	for j, index := range built.Indexes {
+		built.Indexes[j] = original[index]
+	}
+	for _, item := range built.Items {
+		b.dedup.Add(item)
+	}
+	built.Duplicates = duplicates
+	return built
+}
+
+// fit trims a section to allowance tokens (negative = unlimited)
+func (b *Builder) fit(s Section, allowance int) Built {
+	built := Built{Name: s.Name}
+	costs := make([]int, len(s.Items))
+	total := 0
+	for i, item := range s.Items {
+		costs[i] = b.counter(item)
+		total += costs[i]
+	}
+
+	keep := func(i int, item string, cost int) {
+		built.Items = append(built.Items, item)
+		built.Indexes = append(built.Indexes, i)
+		built.Tokens += cost
+	}
+
+	if allowance < 0 || total <= allowance {
+		for i, item := range s.Items {
+			keep(i, item, costs[i])
+		}
+		return built
+	}
+	built.Truncated = true
+
+	switch s.Strategy {
+	case DropSection:
+		return built
+
+	case DropNewest, TruncateEnd:
+		for i, item := range s.Items {
+			if built.Tokens+costs[i] > allowance {
+				if s.Strategy == TruncateEnd {
+					if cut := b.truncate(item, allowance-built.Tokens, false); cut != "" {
+						keep(i, cut, b.counter(cut))
+					}
+				}
+				break
+			}
+			keep(i, item, costs[i])
+		}
+
+	case DropOldest, TruncateStart:
+		// Walk backwards, then restore the original order
+		var items []string
+		var indexes []int
+		tokens := 0
+		for i := len(s.Items) - 1; i >= 0; i-- {
+			item, cost := s.Items[i], costs[i]
+			if tokens+cost > allowance {
+				if s.Strategy == TruncateStart {
+					if cut := b.truncate(item, allowance-tokens, true); cut != "" {
+						items = append(items, cut)
+						indexes = append(indexes, i)
+						tokens += b.counter(cut)
+					}
+				}
+				break
+			}
+			items = append(items, item)
+			indexes = append(indexes, i)
+			tokens += cost
+		}
+		for j := len(items) - 1; j >= 0; j-- {
+			built.Items = append(built.Items, items[j])
+			built.Indexes = append(built.Indexes, indexes[j])
+		}
+		built.Tokens = tokens
+	}
+	return built
+}
+
+// truncate cuts text to at most limit tokens, keeping the start or (with
+// fromStart) the end, preferring a word boundary
+func (b *Builder) truncate(text string, limit int, fromStart bool) string {
+	if limit <= 0 {
+		return ""
+	}
+
+	// Binary search the longest piece that fits
+	piece := func(n int) string {
+		if fromStart {
+			return text[len(text)-n:]
+		}
+		return text[:n]
+	}
+	lo, hi := 0, len(text)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if b.counter(piece(mid)) <= limit {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+
+	// Don't split a UTF-8 sequence
+	for lo > 0 && !utf8.ValidString(piece(lo)) {
+		lo--
+	}
+	cut := piece(lo)
+
+	// Back off to a word boundary unless that loses too much
+	if fromStart {
+		if i := strings.IndexAny(cut, " \n"); i >= 0 && i < len(cut)/4 {
+			cut = cut[i+1:]
+		}
+	} else if i := strings.LastIndexAny(cut, " \n"); i > len(cut)*3/4 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut)
+}
@@ -0,0 +1,81 @@
+package contextbuilder
+
+import (
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+// Dedup recognizes text that is already in the context, such as a retrieved
+// chunk that repeats a recent message. Texts are compared by their word
+// trigrams, so reformatting, case and punctuation don't hide a duplicate and
+// a snippet is caught inside a longer text.
+type Dedup struct {
+	threshold float64
+	minWords  int
+	seen      map[uint64]bool
+}
+
+// NewDedup creates a deduplicator. A text is a duplicate when at least
+// threshold (0-1, default 0.8) of its trigrams were seen before. Texts
+// shorter than minWords (default 20) are never duplicates, so short
+// messages like "yes" are left alone.
+func NewDedup(threshold float64, minWords int) *Dedup {
+	if threshold <= 0 || threshold > 1 {
+		threshold = 0.8
+	}
+	if minWords <= 0 {
+		minWords = 20
+	}
+	return &Dedup{threshold: threshold, minWords: minWords, seen: make(map[uint64]bool)}
+}
+
+// Duplicate reports whether text is mostly in the context already. If it
+// isn't, it is added to the context.
+func (d *Dedup) Duplicate(text string) bool {
+	if d.Contains(text) {
+		return true
+	}
+	d.Add(text)
+	return false
+}
+
+// Contains reports whether text is mostly in the context already, without
+// adding it
+func (d *Dedup) Contains(text string) bool {
+	shingles := trigrams(text)
+	if len(shingles)+2 < d.minWords {
+		return false
+	}
+	seen := 0
+	for _, s := range shingles {
+		if d.seen[s] {
+			seen++
+		}
+	}
+	return float64(seen) >= d.threshold*float64(len(shingles))
+}
+
+// Add puts text in the context without checking it
+func (d *Dedup) Add(text string) {
+	for _, s := range trigrams(text) {
+		d.seen[s] = true
+	}
+}
+
+// trigrams hashes the word trigrams of normalized text
+func trigrams(text string) []uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) < 3 {
+		return nil
+	}
+	hashes := make([]uint64, 0, len(words)-2)
+	for i := 0; i+3 <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(words[i] + " " + words[i+1] + " " + words[i+2]))
+		hashes = append(hashes, h.Sum64())
+	}
+	return hashes
+}
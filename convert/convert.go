@@ -0,0 +1,265 @@
+// Package convert translates chat histories between simpleai messages and
+// the serialized formats of other ecosystems: OpenAI chat messages,
+// LangChain message dicts and LlamaIndex chat messages and chat stores.
+// Use it to bring existing conversation datasets into a ChatStore or
+// memory, or to hand simpleai histories to other tools.
+//
+//	data, _ := os.ReadFile("history.json")
+//	messages, format, err := convert.Parse(data) // Detects the format
+//	err = store.Save(ctx, "user-42", messages)
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/bot"
+)
+
+// ErrUnknownFormat is returned by Parse when the data matches no format
+var ErrUnknownFormat = errors.New("convert: unrecognized chat format")
+
+// Format names a serialized chat format
+type Format string
+
+const (
+	OpenAI     Format = "openai"     // [{"role": "user", "content": "..."}] or {"messages": [...]}
+	LangChain  Format = "langchain"  // messages_to_dict or dumpd output
+	LlamaIndex Format = "llamaindex" // ChatMessage dicts with additional_kwargs or blocks
+)
+
+// OpenAIMessage is a message in the OpenAI chat completions format
+type OpenAIMessage struct {
+	Role       string           `json:"role"`
+	Content    *string          `json:"content"` // Null for assistant messages with only tool calls
+	Name       string           `json:"name,omitempty"`
+	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// OpenAIToolCall is a tool call in the OpenAI format
+type OpenAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"` // Always "function"
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// ToOpenAI converts messages to the OpenAI format. Bookkeeping fields (ID,
+// timestamp, metadata) and documents are dropped.
+func ToOpenAI(messages []simpleai.Message) []OpenAIMessage {
+	out := make([]OpenAIMessage, len(messages))
+	for i, msg := range messages {
+		m := OpenAIMessage{
+			Role:       string(msg.Role),
+			Name:       msg.Name,
+			ToolCallID: msg.ToolCallID,
+		}
+		if msg.Content != "" || len(msg.ToolCalls) == 0 {
+			content := msg.Content
+			m.Content = &content
+		}
+		for _, call := range msg.ToolCalls {
+			tc := OpenAIToolCall{ID: call.ID, Type: "function"}
+			tc.Function.Name = call.Name
+			tc.Function.Arguments = call.Arguments
+			m.ToolCalls = append(m.ToolCalls, tc)
+		}
+		out[i] = m
+	}
+	return out
+}
+
+// MarshalOpenAI encodes messages as a JSON array in the OpenAI format
+func MarshalOpenAI(messages []simpleai.Message) ([]byte, error) {
+	return json.Marshal(ToOpenAI(messages))
+}
+
+// WriteOpenAIJSONL writes one {"messages": [...]} line per conversation,
+// the layout of OpenAI fine-tuning and eval datasets
+func WriteOpenAIJSONL(w io.Writer, conversations [][]simpleai.Message) error {
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf)
+	for _, messages := range conversations {
+		if err := enc.Encode(map[string]any{"messages": ToOpenAI(messages)}); err != nil {
+			return err
+		}
+	}
+	return buf.Flush()
+}
+
+// openAIWire is an OpenAI message as found in the wild: content may be a
+// string, null or a list of parts
+type openAIWire struct {
+	Role       string           `json:"role"`
+	Content    json.RawMessage  `json:"content"`
+	Name       string           `json:"name,omitempty"`
+	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// ParseOpenAI decodes OpenAI messages: a JSON array or an object with a
+// "messages" array. Content given as parts keeps its text parts only.
+func ParseOpenAI(data []byte) ([]simpleai.Message, error) {
+	var wire []openAIWire
+	if err := unmarshalList(data, &wire); err != nil {
+		return nil, fmt.Errorf("convert: invalid OpenAI messages: %w", err)
+	}
+	messages := make([]simpleai.Message, 0, len(wire))
+	for _, w := range wire {
+		if w.Role == "" {
+			return nil, fmt.Errorf("convert: invalid OpenAI messages: message without role")
+		}
+		msg := simpleai.Message{
+			Role:       role(w.Role),
+			Content:    text(w.Content),
+			Name:       w.Name,
+			ToolCallID: w.ToolCallID,
+		}
+		for _, call := range w.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, simpleai.ToolCall{
+				ID:        call.ID,
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			})
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// ReadOpenAIJSONL reads a dataset with one {"messages": [...]} conversation
+// per line, such as an OpenAI fine-tuning file. Blank lines are skipped.
+func ReadOpenAIJSONL(r io.Reader) ([][]simpleai.Message, error) {
+	var conversations [][]simpleai.Message
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		messages, err := ParseOpenAI(data)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		conversations = append(conversations, messages)
+	}
+	return conversations, scanner.Err()
+}
+
+// Parse detects the format of a serialized history and decodes it
+func Parse(data []byte) ([]simpleai.Message, Format, error) {
+	format, err := Detect(data)
+	if err != nil {
+		return nil, "", err
+	}
+	var messages []simpleai.Message
+	switch format {
+	case LangChain:
+		messages, err = ParseLangChain(data)
+	case LlamaIndex:
+		messages, err = ParseLlamaIndex(data)
+	default:
+		messages, err = ParseOpenAI(data)
+	}
+	return messages, format, err
+}
+
+// Detect reports the format of a serialized history from its first message
+func Detect(data []byte) (Format, error) {
+	var list []map[string]json.RawMessage
+	if err := unmarshalList(data, &list); err != nil || len(list) == 0 {
+		return "", ErrUnknownFormat
+	}
+	first := list[0]
+	switch {
+	case first["lc"] != nil || first["data"] != nil:
+		return LangChain, nil
+	case first["blocks"] != nil || first["additional_kwargs"] != nil && first["role"] != nil:
+		return LlamaIndex, nil
+	case first["type"] != nil && first["content"] != nil && first["role"] == nil:
+		return LangChain, nil // A message dict without the data wrapper
+	case first["role"] != nil:
+		return OpenAI, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// Import saves each conversation under its session key in store
+func Import(ctx context.Context, store bot.ChatStore, sessions map[string][]simpleai.Message) error {
+	for key, messages := range sessions {
+		if err := store.Save(ctx, key, messages); err != nil {
+			return fmt.Errorf("convert: failed to import session %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// unmarshalList decodes a JSON array, or the "messages" array of an object
+func unmarshalList(data []byte, v any) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var wrapper struct {
+			Messages json.RawMessage `json:"messages"`
+		}
+		if err := json.Unmarshal(data, &wrapper); err != nil {
+			return err
+		}
+		if wrapper.Messages == nil {
+			return errors.New(`object without a "messages" array`)
+		}
+		data = wrapper.Messages
+	}
+	return json.Unmarshal(data, v)
+}
+
+// role maps the role names used across ecosystems to simpleai roles
+func role(name string) simpleai.Role {
+	switch strings.ToLower(name) {
+	case "human", "user":
+		return simpleai.RoleUser
+	case "ai", "assistant", "chatbot", "model":
+		return simpleai.RoleAssistant
+	case "system", "developer":
+		return simpleai.RoleSystem
+	case "tool", "function":
+		return simpleai.RoleTool
+	}
+	return simpleai.Role(name)
+}
+
+// text returns message content given as a string or a list of parts,
+// joining the text parts
+func text(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(raw, &parts) != nil {
+		return ""
+	}
+	var texts []string
+	for _, part := range parts {
+		if part.Text != "" && (part.Type == "" || part.Type == "text" || part.Type == "input_text" || part.Type == "output_text") {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,114 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// langChainMessage is a serialized LangChain message in any of its layouts:
+// messages_to_dict ({"type", "data"}), dumpd ({"lc", "id", "kwargs"}) or a
+// bare message dict
+type langChainMessage struct {
+	Type   string          `json:"type"`
+	Data   *langChainData  `json:"data"`
+	LC     int             `json:"lc"`
+	ID     json.RawMessage `json:"id"` // Class path for dumpd, message ID otherwise
+	Kwargs *langChainData  `json:"kwargs"`
+	langChainData
+}
+
+type langChainData struct {
+	Content    json.RawMessage `json:"content"`
+	Type       string          `json:"type"`
+	Role       string          `json:"role"` // ChatMessage
+	Name       string          `json:"name"`
+	ID         string          `json:"id"`
+	ToolCallID string          `json:"tool_call_id"`
+	ToolCalls  []struct {
+		ID   string          `json:"id"`
+		Name string          `json:"name"`
+		Args json.RawMessage `json:"args"`
+	} `json:"tool_calls"`
+	AdditionalKwargs struct {
+		ToolCalls []OpenAIToolCall `json:"tool_calls"`
+	} `json:"additional_kwargs"`
+}
+
+// ParseLangChain decodes LangChain messages serialized with
+// messages_to_dict, dumpd or model_dump: a JSON array, or an object with a
+// "messages" array. Tool calls are kept; multimodal content keeps its text
+// parts.
+func ParseLangChain(data []byte) ([]simpleai.Message, error) {
+	var wire []langChainMessage
+	if err := unmarshalList(data, &wire); err != nil {
+		return nil, fmt.Errorf("convert: invalid LangChain messages: %w", err)
+	}
+
+	messages := make([]simpleai.Message, 0, len(wire))
+	for i, w := range wire {
+		var d langChainData
+		var kind string
+		switch {
+		case w.Data != nil: // messages_to_dict
+			d, kind = *w.Data, w.Type
+		case w.LC > 0 && w.Kwargs != nil: // dumpd
+			d, kind = *w.Kwargs, className(w.ID)
+		default: // Bare message dict; its id is the message ID
+			d, kind = w.langChainData, w.Type
+			json.Unmarshal(w.ID, &d.ID)
+		}
+		if kind == "chat" && d.Role != "" {
+			kind = d.Role
+		}
+		if kind == "" {
+			return nil, fmt.Errorf("convert: invalid LangChain messages: message %d has no type", i)
+		}
+
+		msg := simpleai.Message{
+			Role:       role(kind),
+			Content:    text(d.Content),
+			Name:       d.Name, // The tool name for tool messages
+			ID:         d.ID,
+			ToolCallID: d.ToolCallID,
+		}
+		for _, call := range d.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, simpleai.ToolCall{ID: call.ID, Name: call.Name, Arguments: compact(call.Args)})
+		}
+		if len(msg.ToolCalls) == 0 {
+			for _, call := range d.AdditionalKwargs.ToolCalls {
+				msg.ToolCalls = append(msg.ToolCalls, simpleai.ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+			}
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// className maps a dumpd class path such as
+// ["langchain", "schema", "messages", "HumanMessage"] to a message type
+func className(id json.RawMessage) string {
+	var path []string
+	if json.Unmarshal(id, &path) != nil || len(path) == 0 {
+		return ""
+	}
+	name := path[len(path)-1]
+	name = strings.TrimSuffix(name, "Chunk")
+	name = strings.TrimSuffix(name, "Message")
+	return strings.ToLower(name)
+}
+
+// compact renders tool arguments as compact JSON
+func compact(args json.RawMessage) string {
+	if len(args) == 0 || string(args) == "null" {
+		return "{}"
+	}
+	var buf bytes.Buffer
+	if json.Compact(&buf, args) != nil {
+		return string(args)
+	}
+	return buf.String()
+}
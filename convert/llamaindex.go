@@ -0,0 +1,83 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// llamaIndexMessage is a serialized LlamaIndex ChatMessage. Older versions
+// store the text in content, newer ones in blocks.
+type llamaIndexMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+	Blocks  []struct {
+		BlockType string `json:"block_type"`
+		Text      string `json:"text"`
+	} `json:"blocks"`
+	AdditionalKwargs struct {
+		Name       string           `json:"name"`
+		ToolCallID string           `json:"tool_call_id"`
+		ToolCalls  []OpenAIToolCall `json:"tool_calls"`
+	} `json:"additional_kwargs"`
+}
+
+// ParseLlamaIndex decodes LlamaIndex chat messages: a JSON array, or an
+// object with a "messages" array. Text blocks are joined; other blocks are
+// dropped.
+func ParseLlamaIndex(data []byte) ([]simpleai.Message, error) {
+	var wire []llamaIndexMessage
+	if err := unmarshalList(data, &wire); err != nil {
+		return nil, fmt.Errorf("convert: invalid LlamaIndex messages: %w", err)
+	}
+	return llamaIndexMessages(wire), nil
+}
+
+// ParseLlamaIndexStore decodes a persisted SimpleChatStore into its
+// conversations by chat store key, ready for Import
+func ParseLlamaIndexStore(data []byte) (map[string][]simpleai.Message, error) {
+	var file struct {
+		Store map[string][]llamaIndexMessage `json:"store"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("convert: invalid LlamaIndex chat store: %w", err)
+	}
+	if file.Store == nil {
+		return nil, fmt.Errorf(`convert: invalid LlamaIndex chat store: no "store" object`)
+	}
+	sessions := make(map[string][]simpleai.Message, len(file.Store))
+	for key, wire := range file.Store {
+		sessions[key] = llamaIndexMessages(wire)
+	}
+	return sessions, nil
+}
+
+func llamaIndexMessages(wire []llamaIndexMessage) []simpleai.Message {
+	messages := make([]simpleai.Message, 0, len(wire))
+	for _, w := range wire {
+		content := text(w.Content)
+		if content == "" && len(w.Blocks) > 0 {
+			var texts []string
+			for _, block := range w.Blocks {
+				if block.BlockType == "text" && block.Text != "" {
+					texts = append(texts, block.Text)
+				}
+			}
+			content = strings.Join(texts, "\n")
+		}
+
+		msg := simpleai.Message{
+			Role:       role(w.Role),
+			Content:    content,
+			Name:       w.AdditionalKwargs.Name,
+			ToolCallID: w.AdditionalKwargs.ToolCallID,
+		}
+		for _, call := range w.AdditionalKwargs.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, simpleai.ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
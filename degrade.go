@@ -0,0 +1,322 @@
+package simpleai
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Capabilities lists the request features a provider handles natively
+type Capabilities struct {
+	Tools     bool // Request.Tools, tool calls and tool results in history
+	Documents bool // Message.Documents, with citations
+
+	// StreamPrefix reports that streams start with Request.AssistantPrefix,
+	// so StreamResume can skip it when continuing a stream
+	StreamPrefix bool
+}
+
+// CapabilityReporter is implemented by providers that report their
+// capabilities, see Degrade
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// degraded emulates the features its provider lacks
+type degraded struct {
+	Provider
+	caps Capabilities
+}
+
+// Degrade wraps provider so requests using features it doesn't support
+// still work instead of losing them silently, e.g. when falling back from
+// a provider with tools to one without:
+//   - Tool definitions are described in the system prompt and the model is
+//     asked to reply with JSON to call one; such replies are turned back
+//     into Response.ToolCalls. Earlier tool calls and results in the
+//     history become text.
+//   - Documents are inlined into their message. Citations are not
+//     available.
+//
+// Providers that don't implement CapabilityReporter, or support everything,
+// are returned as is.
+func Degrade(provider Provider) Provider {
+	reporter, ok := provider.(CapabilityReporter)
+	if !ok {
+		return provider
+	}
+	caps := reporter.Capabilities()
+	if caps.Tools && caps.Documents {
+		return provider
+	}
+	return &degraded{Provider: provider, caps: caps}
+}
+
+// Capabilities implements CapabilityReporter; missing features are
+// emulated
+func (d *degraded) Capabilities() Capabilities {
+	return Capabilities{Tools: true, Documents: true, StreamPrefix: d.caps.StreamPrefix}
+}
+
+// Model returns the wrapped provider's default model
+func (d *degraded) Model() string {
+	if namer, ok := d.Provider.(ModelNamer); ok {
+		return namer.Model()
+	}
+	return ""
+}
+
+// Complete implements Provider
+func (d *degraded) Complete(ctx context.Context, req *Request) (*Response, error) {
+	adapted, emulateTools := d.adapt(req)
+	resp, err := d.Provider.Complete(ctx, adapted)
+	if err != nil || !emulateTools {
+		return resp, err
+	}
+	if calls, ok := parseToolReply(resp.Content); ok {
+		resp.Content = ""
+		resp.ToolCalls = calls
+		resp.FinishReason = "tool_calls"
+	}
+	return resp, nil
+}
+
+// Stream implements Provider. When tools are emulated, a reply that starts
+// like JSON is held back until it ends, then sent as tool calls or as text.
+func (d *degraded) Stream(ctx context.Context, req *Request) (<-chan StreamEvent, error) {
+	adapted, emulateTools := d.adapt(req)
+	stream, err := d.Provider.Stream(ctx, adapted)
+	if err != nil || !emulateTools {
+		return stream, err
+	}
+	return toolReplyStream(ctx, stream), nil
+}
+
+// adapt returns a copy of req without the features the provider lacks, and
+// whether tool calls must be parsed from the reply
+func (d *degraded) adapt(req *Request) (*Request, bool) {
+	r := *req
+	r.Messages = slices.Clone(req.Messages)
+
+	if !d.caps.Documents {
+		for i := range r.Messages {
+			if msg := &r.Messages[i]; len(msg.Documents) > 0 {
+				msg.Content = inlineDocuments(msg.Documents, msg.Content)
+				msg.Documents = nil
+			}
+		}
+	}
+
+	if d.caps.Tools {
+		return &r, false
+	}
+	for i := range r.Messages {
+		msg := &r.Messages[i]
+		if msg.Role == RoleTool {
+			msg.Role = RoleUser
+			msg.Content = "Result of tool " + msg.Name + ":\n" + msg.Content
+			msg.Name, msg.ToolCallID = "", ""
+		}
+		if len(msg.ToolCalls) > 0 {
+			msg.Content = strings.TrimSpace(msg.Content + "\n" + toolCallJSON(msg.ToolCalls))
+			msg.ToolCalls = nil
+		}
+	}
+	emulate := len(r.Tools) > 0 && r.ToolChoice != ToolChoiceNone
+	if emulate {
+		addInstruction(&r, toolPrompt(r.Tools, r.ToolChoice))
+	}
+	r.Tools, r.ToolChoice = nil, ""
+	return &r, emulate
+}
+
+// inlineDocuments puts numbered documents before content
+func inlineDocuments(docs []Document, content string) string {
+	var sb strings.Builder
+	sb.WriteString("Documents:\n\n")
+	for i, doc := range docs {
+		fmt.Fprintf(&sb, "[%d]", i+1)
+		if doc.Title != "" {
+			sb.WriteString(" " + doc.Title)
+		}
+		sb.WriteString("\n")
+		if doc.Context != "" {
+			sb.WriteString("(" + doc.Context + ")\n")
+		}
+		sb.WriteString(doc.Content + "\n\n")
+	}
+	sb.WriteString(content)
+	return sb.String()
+}
+
+// toolPrompt describes tools and how to call them with a JSON reply
+func toolPrompt(tools []Tool, choice string) string {
+	var sb strings.Builder
+	sb.WriteString("You can call these tools:\n")
+	for _, tool := range tools {
+		sb.WriteString("\n- " + tool.Name)
+		if tool.Description != "" {
+			sb.WriteString(": " + tool.Description)
+		}
+		params := tool.Parameters
+		if params == nil {
+			params = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		schema, _ := json.Marshal(params)
+		sb.WriteString("\n  Arguments (JSON schema): " + string(schema))
+	}
+	sb.WriteString("\n\nTo call tools, reply with only this JSON and nothing else:\n")
+	sb.WriteString(`{"tool_calls": [{"name": "<tool name>", "arguments": {...}}]}`)
+	switch choice {
+	case "", ToolChoiceAuto:
+		sb.WriteString("\nIf no tool is needed, answer normally.")
+	case ToolChoiceRequired:
+		sb.WriteString("\nYou must call at least one tool.")
+	default:
+		sb.WriteString("\nYou must call the tool " + choice + ".")
+	}
+	return sb.String()
+}
+
+// toolReply is the JSON a model writes to call tools
+type toolReply struct {
+	ToolCalls []toolReplyCall `json:"tool_calls"`
+}
+
+type toolReplyCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// toolCallJSON writes past tool calls the way the model is asked to
+func toolCallJSON(calls []ToolCall) string {
+	var reply toolReply
+	for _, call := range calls {
+		args := json.RawMessage(call.Arguments)
+		if !json.Valid(args) {
+			args = json.RawMessage("{}")
+		}
+		reply.ToolCalls = append(reply.ToolCalls, toolReplyCall{Name: call.Name, Arguments: args})
+	}
+	data, _ := json.Marshal(reply)
+	return string(data)
+}
+
+// parseToolReply returns the tool calls of a reply that consists of the
+// tool call JSON, possibly in a code fence
+func parseToolReply(content string) ([]ToolCall, bool) {
+	trimmed := strings.TrimSpace(content)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "```") {
+		return nil, false
+	}
+	var reply toolReply
+	if decodeJSONReply(trimmed, &reply) != nil || len(reply.ToolCalls) == 0 {
+		return nil, false
+	}
+	calls := make([]ToolCall, 0, len(reply.ToolCalls))
+	for _, call := range reply.ToolCalls {
+		if call.Name == "" {
+			return nil, false
+		}
+		args := "{}"
+		var buf bytes.Buffer
+		if len(call.Arguments) > 0 && json.Compact(&buf, call.Arguments) == nil && buf.String() != "null" {
+			args = buf.String()
+		}
+		b := make([]byte, 8)
+		rand.Read(b)
+		calls = append(calls, ToolCall{ID: "call_" + hex.EncodeToString(b), Name: call.Name, Arguments: args})
+	}
+	return calls, true
+}
+
+// toolReplyStream forwards stream, holding back a reply that starts like
+// JSON until the final event, which then carries the parsed tool calls (or
+// the held text if it wasn't a tool call)
+func toolReplyStream(ctx context.Context, stream <-chan StreamEvent) <-chan StreamEvent {
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+		send := func(event StreamEvent) bool {
+			select {
+			case out <- event:
+				return true
+			case <-ctx.Done():
+				go func() {
+					for range stream {
+					}
+				}()
+				return false
+			}
+		}
+
+		const (
+			undecided = iota
+			passing
+			holding
+		)
+		var held strings.Builder
+		mode := undecided
+		for event := range stream {
+			final := event.Done || event.Error != nil
+			if mode != passing {
+				held.WriteString(event.Content)
+			}
+			if mode == undecided {
+				trimmed := strings.TrimSpace(held.String())
+				switch {
+				case trimmed == "" && !final:
+					continue
+				case strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "```"):
+					mode = holding
+				default:
+					mode = passing
+					event.Content = held.String()
+				}
+			}
+			if mode == holding {
+				if !final {
+					continue
+				}
+				calls, ok := parseToolReply(held.String())
+				if ok && event.Error == nil {
+					event.Content = ""
+					event.ToolCalls = calls
+					event.FinishReason = "tool_calls"
+				} else {
+					event.Content = held.String()
+				}
+				held.Reset()
+			}
+			if !send(event) {
+				return
+			}
+		}
+		// The stream closed without a final event
+		if mode == holding && held.Len() > 0 {
+			send(StreamEvent{Content: held.String()})
+		}
+	}()
+	return out
+}
+
+// addInstruction appends text to the request's system prompt, or to its
+// last system message if it has no system prompt
+func addInstruction(r *Request, text string) {
+	if r.SystemPrompt != "" {
+		r.SystemPrompt += "\n\n" + text
+		return
+	}
+	for i := len(r.Messages) - 1; i >= 0; i-- {
+		if r.Messages[i].Role == RoleSystem {
+			r.Messages[i].Content += "\n\n" + text
+			return
+		}
+	}
+	r.SystemPrompt = text
+}
@@ -0,0 +1,55 @@
+package simpleai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ErrDryRunUnsupported is returned by Client.DryRun when no provider built
+// a request, e.g. because middleware answered from a cache
+var ErrDryRunUnsupported = errors.New("simpleai: no provider request was built")
+
+// DryRun is the HTTP request a provider would have sent. In dry-run mode
+// providers return it as the error of Complete and Stream instead of
+// calling the API; use errors.As or Client.DryRun to get it.
+type DryRun struct {
+	Method string
+	URL    string          // API keys in the query are redacted
+	Header http.Header     // Credentials are redacted
+	Body   json.RawMessage // The exact JSON payload
+}
+
+func (d *DryRun) Error() string {
+	return "simpleai: dry run: " + d.Method + " " + d.URL
+}
+
+type dryRunKey struct{}
+
+// ContextWithDryRun returns a context in which providers build their
+// requests but return them as a *DryRun error instead of sending them
+func ContextWithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun reports whether ctx is in dry-run mode
+func IsDryRun(ctx context.Context) bool {
+	dry, _ := ctx.Value(dryRunKey{}).(bool)
+	return dry
+}
+
+// DryRun runs req through the client like Complete, but returns the
+// provider request instead of sending it. Use it to check prompt assembly
+// and parameter mapping in tests.
+func (c *Client) DryRun(ctx context.Context, req *Request, opts ...RequestOption) (*DryRun, error) {
+	_, err := c.Complete(ContextWithDryRun(ctx), req, opts...)
+	var dry *DryRun
+	if errors.As(err, &dry) {
+		return dry, nil
+	}
+	if err == nil {
+		return nil, ErrDryRunUnsupported
+	}
+	return nil, err
+}
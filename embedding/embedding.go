@@ -2,6 +2,7 @@ package embedding
 
 import (
 	"context"
+	"time"
 )
 
 // Embedder generates vector embeddings from text
@@ -25,6 +26,7 @@ type Document struct {
 	Content   string
 	Embedding []float64
 	Metadata  map[string]any
+	ExpiresAt time.Time // Stores that support expiry drop the document after this (zero = never)
 }
 
 // CosineSimilarity calculates the cosine similarity between two vectors
@@ -4,12 +4,17 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 
 	"github.com/medatechnology/goutil/utils"
 	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/embedding"
 	shttp "github.com/medatechnology/simpleai/http"
+	"github.com/medatechnology/simpleai/jobs"
 	"github.com/medatechnology/simpleai/middleware"
 	"github.com/medatechnology/simpleai/provider"
+	"github.com/medatechnology/simpleai/rag"
 	"github.com/medatechnology/simplehttp"
 	"github.com/medatechnology/simplehttp/framework/fiber"
 )
@@ -31,6 +36,7 @@ func main() {
 		simpleai.WithMiddleware(middleware.SimpleLogger(func(msg string) {
 			log.Println("[AI]", msg)
 		})),
+		simpleai.WithIdempotency(simpleai.DefaultIdempotencyConfig()),
 	)
 
 	// Create chat session with Doctor AI system prompt and autocompact
@@ -72,14 +78,51 @@ IMPORTANT: Always remind users to consult real healthcare professionals for medi
 	// API routes
 	api := server.Group("/api/v1")
 
+	// With API_KEYS set, clients without a key get a small trial budget
+	if keys := os.Getenv("API_KEYS"); keys != "" {
+		api.Use(shttp.NewTrialSimple(strings.Split(keys, ",")...).Middleware())
+	}
+
 	// Non-streaming completion
 	api.POST("/chat/complete", shttp.CompleteHandler(client))
 
-	// Streaming completion via SSE
-	api.POST("/chat/stream", shttp.StreamHandler(client))
+	// Streaming completion via SSE; in-flight streams can be stopped
+	requests := shttp.NewRequests()
+	api.POST("/chat/stream", shttp.StreamHandler(client, shttp.WithRequests(requests)))
 
 	// Chat with history (Doctor AI)
-	api.POST("/doctor/chat", shttp.ChatStreamHandler(chat))
+	api.POST("/doctor/chat", shttp.ChatStreamHandler(chat, shttp.WithRequests(requests)))
+
+	// Stop a stream: {"request_id": "..."}
+	api.DELETE("/requests", shttp.CancelHandler(requests))
+
+	// Chat sessions keyed by session_id
+	sessions := shttp.NewSessions(shttp.SessionsConfig{
+		Client:      client,
+		ChatOptions: []simpleai.ChatOption{simpleai.WithHistoryLimit(50)},
+	})
+	api.POST("/sessions/chat", shttp.SessionChatHandler(sessions, shttp.WithRequests(requests)))
+
+	// OpenAI-compatible embeddings and RAG search for non-Go clients
+	embedder := embedding.NewOpenAI(embedding.OpenAIConfig{APIKey: os.Getenv("OPENAI_API_KEY")})
+	retriever := rag.New(embedder, rag.NewMemoryStore(), rag.DefaultConfig())
+	v1 := server.Group("/v1")
+	v1.POST("/embeddings", shttp.EmbeddingsHandler(embedder))
+	v1.POST("/rag/search", shttp.RAGSearchHandler(retriever))
+
+	// Asynchronous completions for long generations: submit, then poll
+	runner := jobs.NewSimple(client)
+	defer runner.Stop()
+	v1.POST("/completions/async", shttp.SubmitJobHandler(runner))
+	v1.GET("/completions/:id", shttp.JobHandler(runner))
+
+	// Admin endpoints, authenticated with "Authorization: Bearer $ADMIN_TOKEN"
+	admin := server.Group("/admin")
+	adminToken := shttp.WithAdminToken(os.Getenv("ADMIN_TOKEN"))
+	admin.GET("/sessions", shttp.ListSessionsHandler(sessions, adminToken))
+	admin.GET("/session", shttp.SessionHandler(sessions, adminToken))
+	admin.DELETE("/session", shttp.DeleteSessionHandler(sessions, adminToken))
+	admin.DELETE("/rag", shttp.ClearRAGHandler(map[string]*rag.RAG{"default": retriever}, adminToken))
 
 	// Simple chat endpoint for testing
 	api.POST("/chat", func(c simplehttp.Context) error {
@@ -114,6 +157,12 @@ IMPORTANT: Always remind users to consult real healthcare professionals for medi
 	log.Println("  POST /api/v1/chat/complete - OpenAI-compatible completion")
 	log.Println("  POST /api/v1/chat/stream   - SSE streaming")
 	log.Println("  POST /api/v1/doctor/chat   - Doctor AI chat with history")
+	log.Println("  POST /v1/embeddings        - OpenAI-compatible embeddings")
+	log.Println("  POST /v1/rag/search        - RAG search")
+	log.Println("  POST /v1/completions/async - Queue a completion")
+	log.Println("  GET  /v1/completions/:id   - Job status and result")
+	log.Println("  POST /api/v1/sessions/chat - Chat sessions by session_id")
+	log.Println("  GET  /admin/sessions       - List sessions (admin)")
 
 	if err := server.Start(":" + config.Port); err != nil {
 		log.Fatal(err)
@@ -6,8 +6,8 @@ import (
 
 	"github.com/medatechnology/simpleai"
 	"github.com/medatechnology/simpleai/middleware"
+	_ "github.com/medatechnology/simpleai/personas"
 	"github.com/medatechnology/simpleai/provider"
-	"github.com/medatechnology/simpleai/template"
 )
 
 func main() {
@@ -26,27 +26,8 @@ func main() {
 		})),
 	)
 
-	// Create prompt template for Doctor AI
-	tmpl := template.NewEngine()
-	err := tmpl.Load("doctor_system", `You are Dr. AI, a knowledgeable medical assistant.
-
-Your responsibilities:
-- Provide general health information and guidance
-- Analyze symptoms (not diagnose)
-- Offer wellness advice and preventive care tips
-
-Patient Context:
-- Name: {{.Name}}
-- Age: {{.Age}}
-- Known Conditions: {{if .Conditions}}{{join .Conditions ", "}}{{else}}None{{end}}
-
-IMPORTANT: Always remind users to consult real healthcare professionals for medical decisions.`)
-	if err != nil {
-		panic(err)
-	}
-
-	// Generate system prompt from template
-	systemPrompt, err := tmpl.Execute("doctor_system", map[string]any{
+	// Create a Doctor AI chat session from the built-in persona
+	chat, err := client.NewChatFromPersona("doctor", map[string]any{
 		"Name":       "Patient",
 		"Age":        35,
 		"Conditions": []string{},
@@ -55,12 +36,6 @@ IMPORTANT: Always remind users to consult real healthcare professionals for medi
 		panic(err)
 	}
 
-	// Create chat session
-	chat := client.NewChat(
-		simpleai.WithSystem(systemPrompt),
-		simpleai.WithHistoryLimit(50),
-	)
-
 	// Example conversation
 	ctx := context.Background()
 
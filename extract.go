@@ -0,0 +1,133 @@
+package simpleai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrExtractFailed is returned when the model's reply cannot be decoded
+var ErrExtractFailed = errors.New("simpleai: failed to extract structured output")
+
+// Extract asks the model to answer input with JSON matching the shape of out
+// and decodes the reply into out, which must be a non-nil pointer. The shape
+// is derived from out's type (json tags are respected). If the first reply is
+// not valid JSON the model is asked once to correct it.
+func (c *Client) Extract(ctx context.Context, input string, out any, opts ...RequestOption) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("simpleai: Extract requires a non-nil pointer, got %T", out)
+	}
+
+	req := &Request{
+		Messages:     []Message{{Role: RoleUser, Content: input}},
+		SystemPrompt: JSONPrompt(out),
+	}
+
+	resp, err := c.Complete(ctx, req, opts...)
+	if err != nil {
+		return err
+	}
+
+	decodeErr := decodeJSONReply(resp.Content, out)
+	if decodeErr == nil {
+		return nil
+	}
+
+	// One repair attempt with the decode error
+	req.Messages = append(req.Messages,
+		Message{Role: RoleAssistant, Content: resp.Content},
+		Message{Role: RoleUser, Content: "That was not valid JSON for the requested shape (" + decodeErr.Error() + "). Reply with only the corrected JSON."},
+	)
+	resp, err = c.Complete(ctx, req, opts...)
+	if err != nil {
+		return err
+	}
+	if err := decodeJSONReply(resp.Content, out); err != nil {
+		return fmt.Errorf("%w: %v", ErrExtractFailed, err)
+	}
+	return nil
+}
+
+// JSONPrompt returns the system prompt Extract uses to ask for JSON shaped
+// like v, for callers that send the request themselves (e.g. to stream it)
+func JSONPrompt(v any) string {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return "Reply with only a JSON value, with no commentary or code fences."
+	}
+	shape, _ := json.Marshal(jsonShape(t, 0))
+	return "Reply with only a JSON value matching this shape, with no commentary or code fences:\n" + string(shape)
+}
+
+// decodeJSONReply decodes the JSON value in a model reply, tolerating code
+// fences and surrounding prose
+func decodeJSONReply(content string, out any) error {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "```") {
+		content = strings.TrimPrefix(content, "```json")
+		content = strings.TrimPrefix(content, "```")
+		content = strings.TrimSuffix(strings.TrimSpace(content), "```")
+	}
+
+	if start := strings.IndexAny(content, "{["); start > 0 {
+		content = content[start:]
+	}
+	if end := strings.LastIndexAny(content, "}]"); end >= 0 && end < len(content)-1 {
+		content = content[:end+1]
+	}
+
+	return json.Unmarshal([]byte(content), out)
+}
+
+// jsonShape returns an example value describing t, e.g.
+// {"name":"string","age":0,"tags":["string"]}
+func jsonShape(t reflect.Type, depth int) any {
+	if depth > 8 {
+		return nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return false
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return 0
+	case reflect.Float32, reflect.Float64:
+		return 0.0
+	case reflect.Slice, reflect.Array:
+		return []any{jsonShape(t.Elem(), depth+1)}
+	case reflect.Map:
+		return map[string]any{"key": jsonShape(t.Elem(), depth+1)}
+	case reflect.Struct:
+		fields := map[string]any{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := f.Name
+			if tag := f.Tag.Get("json"); tag != "" {
+				tagName, _, _ := strings.Cut(tag, ",")
+				if tagName == "-" {
+					continue
+				}
+				if tagName != "" {
+					name = tagName
+				}
+			}
+			fields[name] = jsonShape(f.Type, depth+1)
+		}
+		return fields
+	default:
+		return "any"
+	}
+}
@@ -0,0 +1,249 @@
+// Package finetune exports stored chat sessions as fine-tuning datasets in
+// the JSONL chat format used by OpenAI and Mistral, closing the loop from
+// production conversations to custom models.
+//
+//	conversations, _ := analytics.LoadConversations(ctx, store)
+//	stats, err := finetune.New(finetune.Config{
+//		MinScore: 1,               // Only sessions users rated well
+//		Scrub:    finetune.ScrubPII,
+//	}).ExportFile("train.jsonl", conversations)
+package finetune
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/analytics"
+	"github.com/medatechnology/simpleai/internal/pii"
+)
+
+// FeedbackKey is the message metadata key holding user feedback on an
+// assistant reply: a number (e.g. 1 to 5, or -1/1 for thumbs) or a bool
+const FeedbackKey = "feedback"
+
+// Format is a fine-tuning dataset format
+type Format string
+
+const (
+	FormatOpenAI  Format = "openai"
+	FormatMistral Format = "mistral" // Omits speaker names
+)
+
+// Config holds configuration for an Exporter
+type Config struct {
+	Format Format // Default FormatOpenAI
+
+	// SystemPrompt is prepended to sessions without a system message
+	SystemPrompt string
+
+	// Score rates a session; the default averages the FeedbackKey metadata
+	// of its assistant messages. ok is false for unrated sessions.
+	Score func(conv analytics.Conversation) (score float64, ok bool)
+	// MinScore skips rated sessions scoring below it (when non-zero)
+	MinScore float64
+	// RatedOnly skips sessions without a score
+	RatedOnly bool
+	// WeightFeedback sets weight 0 on assistant replies with negative
+	// feedback, so the model doesn't learn them but keeps the context
+	WeightFeedback bool
+
+	// Scrub rewrites every text before export, e.g. ScrubPII
+	Scrub func(text string) string
+
+	// MinReplies skips sessions with fewer assistant replies (default 1)
+	MinReplies int
+}
+
+// DefaultConfig returns sensible defaults
+func DefaultConfig() Config {
+	return Config{
+		Format:     FormatOpenAI,
+		Score:      FeedbackScore,
+		MinReplies: 1,
+	}
+}
+
+// Stats counts the sessions of an export
+type Stats struct {
+	Sessions int `json:"sessions"`
+	Exported int `json:"exported"`
+	Skipped  int `json:"skipped"`
+}
+
+// Exporter writes fine-tuning datasets
+type Exporter struct {
+	config Config
+}
+
+// New creates an exporter
+func New(config Config) *Exporter {
+	defaults := DefaultConfig()
+	if config.Format == "" {
+		config.Format = defaults.Format
+	}
+	if config.Score == nil {
+		config.Score = defaults.Score
+	}
+	if config.MinReplies <= 0 {
+		config.MinReplies = defaults.MinReplies
+	}
+	return &Exporter{config: config}
+}
+
+// Fine-tuning file types
+type example struct {
+	Messages []exampleMessage `json:"messages"`
+}
+
+type exampleMessage struct {
+	Role       string            `json:"role"`
+	Content    string            `json:"content"`
+	Name       string            `json:"name,omitempty"`
+	ToolCalls  []exampleToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string            `json:"tool_call_id,omitempty"`
+	Weight     *int              `json:"weight,omitempty"`
+}
+
+type exampleToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// Export writes one JSON line per qualifying session to w
+func (e *Exporter) Export(w io.Writer, conversations []analytics.Conversation) (Stats, error) {
+	stats := Stats{Sessions: len(conversations)}
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf)
+	for _, conv := range conversations {
+		ex, ok := e.example(conv)
+		if !ok {
+			stats.Skipped++
+			continue
+		}
+		if err := enc.Encode(ex); err != nil {
+			return stats, err
+		}
+		stats.Exported++
+	}
+	return stats, buf.Flush()
+}
+
+// ExportFile writes the dataset to a file, replacing it
+func (e *Exporter) ExportFile(path string, conversations []analytics.Conversation) (Stats, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return Stats{}, err
+	}
+	stats, err := e.Export(file, conversations)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return stats, err
+}
+
+// example converts a session, reporting false when it is skipped
+func (e *Exporter) example(conv analytics.Conversation) (example, bool) {
+	score, rated := e.config.Score(conv)
+	if !rated && e.config.RatedOnly {
+		return example{}, false
+	}
+	if rated && e.config.MinScore != 0 && score < e.config.MinScore {
+		return example{}, false
+	}
+
+	var ex example
+	if e.config.SystemPrompt != "" && (len(conv.Messages) == 0 || conv.Messages[0].Role != simpleai.RoleSystem) {
+		ex.Messages = append(ex.Messages, exampleMessage{Role: "system", Content: e.scrub(e.config.SystemPrompt)})
+	}
+
+	replies := 0
+	for _, msg := range conv.Messages {
+		m := exampleMessage{
+			Role:       string(msg.Role),
+			Content:    e.scrub(msg.Content),
+			ToolCallID: msg.ToolCallID,
+		}
+		if e.config.Format == FormatOpenAI && msg.Role != simpleai.RoleTool {
+			m.Name = msg.Name
+		}
+		for _, call := range msg.ToolCalls {
+			tc := exampleToolCall{ID: call.ID, Type: "function"}
+			tc.Function.Name = call.Name
+			tc.Function.Arguments = e.scrub(call.Arguments)
+			m.ToolCalls = append(m.ToolCalls, tc)
+		}
+		if msg.Role == simpleai.RoleAssistant {
+			replies++
+			if score, ok := feedback(msg); ok && e.config.WeightFeedback && score < 0 {
+				weight := 0
+				m.Weight = &weight
+			}
+		}
+		ex.Messages = append(ex.Messages, m)
+	}
+
+	// Both formats train on conversations ending with the assistant
+	for len(ex.Messages) > 0 && ex.Messages[len(ex.Messages)-1].Role != string(simpleai.RoleAssistant) {
+		ex.Messages = ex.Messages[:len(ex.Messages)-1]
+	}
+	if replies < e.config.MinReplies || len(ex.Messages) == 0 {
+		return example{}, false
+	}
+	return ex, true
+}
+
+func (e *Exporter) scrub(text string) string {
+	if e.config.Scrub == nil {
+		return text
+	}
+	return e.config.Scrub(text)
+}
+
+// FeedbackScore averages the FeedbackKey metadata of a session's assistant
+// messages; true counts as 1 and false as -1
+func FeedbackScore(conv analytics.Conversation) (float64, bool) {
+	total, count := 0.0, 0
+	for _, msg := range conv.Messages {
+		if msg.Role != simpleai.RoleAssistant {
+			continue
+		}
+		if score, ok := feedback(msg); ok {
+			total += score
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return total / float64(count), true
+}
+
+// feedback reads the FeedbackKey metadata of a message
+func feedback(msg simpleai.Message) (float64, bool) {
+	switch v := msg.Metadata[FeedbackKey].(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return -1, true
+	}
+	return 0, false
+}
+
+// ScrubPII replaces email addresses, card numbers, US social security
+// numbers, phone numbers and IPv4 addresses with placeholders such as
+// [EMAIL]. It is a baseline; chain your own scrubber for names or IDs.
+func ScrubPII(text string) string {
+	return pii.Scrub(text)
+}
@@ -7,6 +7,7 @@ toolchain go1.24.11
 require (
 	github.com/medatechnology/goutil v1.2.2
 	github.com/medatechnology/simplehttp v0.0.9
+	golang.org/x/net v0.47.0
 )
 
 require (
@@ -30,7 +31,6 @@ require (
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasthttp v1.60.0 // indirect
 	golang.org/x/crypto v0.46.0 // indirect
-	golang.org/x/net v0.47.0 // indirect
 	golang.org/x/sys v0.39.0 // indirect
 	golang.org/x/time v0.14.0 // indirect
 )
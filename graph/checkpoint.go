@@ -0,0 +1,139 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrCheckpointNotFound is returned when no checkpoint exists for a run
+var ErrCheckpointNotFound = errors.New("graph: checkpoint not found")
+
+// Checkpoint is the serializable state of a run between nodes. State values
+// must be JSON-serializable to be checkpointed.
+type Checkpoint struct {
+	RunID   string         `json:"run_id"`
+	Graph   string         `json:"graph"`
+	Next    string         `json:"next"` // Node to run on resume, End when completed
+	Values  map[string]any `json:"values"`
+	Steps   int            `json:"steps"`
+	Visits  map[string]int `json:"visits"`
+	Updated time.Time      `json:"updated"`
+}
+
+// Checkpointer stores checkpoints
+type Checkpointer interface {
+	Save(ctx context.Context, cp *Checkpoint) error
+	Load(ctx context.Context, runID string) (*Checkpoint, error)
+	Delete(ctx context.Context, runID string) error
+}
+
+// MemoryCheckpointer keeps checkpoints in memory, e.g. for tests or
+// short-lived processes
+type MemoryCheckpointer struct {
+	mu          sync.RWMutex
+	checkpoints map[string][]byte
+}
+
+// NewMemoryCheckpointer creates an in-memory checkpointer
+func NewMemoryCheckpointer() *MemoryCheckpointer {
+	return &MemoryCheckpointer{checkpoints: make(map[string][]byte)}
+}
+
+// Save stores a copy of the checkpoint
+func (m *MemoryCheckpointer) Save(ctx context.Context, cp *Checkpoint) error {
+	// Encode to get a snapshot and the same serialization rules as on disk
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checkpoints[cp.RunID] = data
+	return nil
+}
+
+// Load returns the checkpoint for a run
+func (m *MemoryCheckpointer) Load(ctx context.Context, runID string) (*Checkpoint, error) {
+	m.mu.RLock()
+	data, ok := m.checkpoints[runID]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ErrCheckpointNotFound
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// Delete removes the checkpoint for a run
+func (m *MemoryCheckpointer) Delete(ctx context.Context, runID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.checkpoints, runID)
+	return nil
+}
+
+// FileCheckpointer stores each run's checkpoint as a JSON file in a directory
+type FileCheckpointer struct {
+	dir string
+}
+
+// NewFileCheckpointer creates a checkpointer writing to dir, creating it if needed
+func NewFileCheckpointer(dir string) (*FileCheckpointer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileCheckpointer{dir: dir}, nil
+}
+
+func (f *FileCheckpointer) path(runID string) string {
+	return filepath.Join(f.dir, filepath.Base(runID)+".json")
+}
+
+// Save writes the checkpoint atomically
+func (f *FileCheckpointer) Save(ctx context.Context, cp *Checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := f.path(cp.RunID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path(cp.RunID))
+}
+
+// Load reads the checkpoint for a run
+func (f *FileCheckpointer) Load(ctx context.Context, runID string) (*Checkpoint, error) {
+	data, err := os.ReadFile(f.path(runID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrCheckpointNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// Delete removes the checkpoint file for a run
+func (f *FileCheckpointer) Delete(ctx context.Context, runID string) error {
+	err := os.Remove(f.path(runID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
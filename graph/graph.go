@@ -0,0 +1,271 @@
+// Package graph runs workflows as a graph of nodes connected by conditional
+// edges. Unlike a pipeline, a graph may loop (e.g. draft → review → draft),
+// with limits on cycles and checkpoints that let long-running workflows
+// resume after a failure or restart.
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/medatechnology/simpleai/pipeline"
+)
+
+// End is the pseudo-node that terminates a run
+const End = "__end__"
+
+// Common errors
+var (
+	ErrNoStart      = errors.New("graph: no start node")
+	ErrUnknownNode  = errors.New("graph: unknown node")
+	ErrMaxSteps     = errors.New("graph: step limit reached")
+	ErrMaxVisits    = errors.New("graph: node visit limit reached")
+	ErrRunCompleted = errors.New("graph: run already completed")
+)
+
+// Condition decides whether an edge is taken
+type Condition func(s *pipeline.State) bool
+
+type edge struct {
+	to        string
+	condition Condition
+}
+
+// Graph is a set of nodes and the edges between them. Nodes are pipeline
+// steps, so LLM calls (pipeline.Prompt, pipeline.Extract), tools and plain
+// code all share the same state.
+type Graph struct {
+	name  string
+	start string
+	nodes map[string]*pipeline.Step
+	edges map[string][]edge
+}
+
+// New creates an empty graph
+func New(name string) *Graph {
+	return &Graph{
+		name:  name,
+		nodes: make(map[string]*pipeline.Step),
+		edges: make(map[string][]edge),
+	}
+}
+
+// Name returns the graph name
+func (g *Graph) Name() string {
+	return g.name
+}
+
+// AddNode adds a node running fn. The first node added is the start node.
+func (g *Graph) AddNode(name string, fn pipeline.StepFunc) *Graph {
+	return g.AddStep(pipeline.NewStep(name, fn))
+}
+
+// AddStep adds a pipeline step as a node named after the step, keeping its
+// retries. The first node added is the start node.
+func (g *Graph) AddStep(step *pipeline.Step) *Graph {
+	if g.start == "" {
+		g.start = step.Name()
+	}
+	g.nodes[step.Name()] = step
+	return g
+}
+
+// SetStart sets the node a run begins with
+func (g *Graph) SetStart(name string) *Graph {
+	g.start = name
+	return g
+}
+
+// AddEdge adds an unconditional edge
+func (g *Graph) AddEdge(from, to string) *Graph {
+	return g.AddConditionalEdge(from, to, nil)
+}
+
+// AddConditionalEdge adds an edge taken when condition holds. Edges are
+// evaluated in the order they were added and the first match wins; a node
+// without a matching edge ends the run.
+func (g *Graph) AddConditionalEdge(from, to string, condition Condition) *Graph {
+	g.edges[from] = append(g.edges[from], edge{to: to, condition: condition})
+	return g
+}
+
+// Validate checks that the start node and all edge endpoints exist
+func (g *Graph) Validate() error {
+	if g.start == "" {
+		return ErrNoStart
+	}
+	if _, ok := g.nodes[g.start]; !ok {
+		return fmt.Errorf("%w: start %q", ErrUnknownNode, g.start)
+	}
+	for from, edges := range g.edges {
+		if _, ok := g.nodes[from]; !ok {
+			return fmt.Errorf("%w: edge from %q", ErrUnknownNode, from)
+		}
+		for _, e := range edges {
+			if _, ok := g.nodes[e.to]; !ok && e.to != End {
+				return fmt.Errorf("%w: edge %q → %q", ErrUnknownNode, from, e.to)
+			}
+		}
+	}
+	return nil
+}
+
+// next returns the node that follows from
+func (g *Graph) next(from string, s *pipeline.State) string {
+	for _, e := range g.edges[from] {
+		if e.condition == nil || e.condition(s) {
+			return e.to
+		}
+	}
+	return End
+}
+
+// RunConfig configures a graph run
+type RunConfig struct {
+	// RunID identifies the run for checkpointing (default: generated)
+	RunID string
+
+	// MaxSteps limits the total number of node executions (default 100)
+	MaxSteps int
+
+	// MaxVisits limits how often a single node runs, bounding cycles
+	// (default 10)
+	MaxVisits int
+
+	// Checkpointer, if set, saves the state after every node so the run
+	// can be resumed with Resume
+	Checkpointer Checkpointer
+
+	// OnNode is called after every node, e.g. for progress reporting
+	OnNode func(node string, s *pipeline.State)
+}
+
+// DefaultRunConfig returns sensible defaults
+func DefaultRunConfig() RunConfig {
+	return RunConfig{
+		MaxSteps:  100,
+		MaxVisits: 10,
+	}
+}
+
+// Result is the outcome of a graph run
+type Result struct {
+	RunID string
+	State *pipeline.State
+	Path  []string        // Nodes in the order they ran
+	Trace *pipeline.Trace // Per-node timing, attempts, outputs and errors
+}
+
+// Run executes the graph from its start node with the given input values
+func (g *Graph) Run(ctx context.Context, input map[string]any, config RunConfig) (*Result, error) {
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+	if config.RunID == "" {
+		config.RunID = fmt.Sprintf("%s-%d", g.name, time.Now().UnixNano())
+	}
+
+	cp := &Checkpoint{
+		RunID:  config.RunID,
+		Graph:  g.name,
+		Next:   g.start,
+		Values: input,
+		Visits: map[string]int{},
+	}
+	return g.run(ctx, cp, config)
+}
+
+// Resume continues a checkpointed run from the node that was about to run
+// (or that failed) when the checkpoint was saved
+func (g *Graph) Resume(ctx context.Context, runID string, config RunConfig) (*Result, error) {
+	if config.Checkpointer == nil {
+		return nil, errors.New("graph: Resume requires a Checkpointer")
+	}
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+
+	cp, err := config.Checkpointer.Load(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	if cp.Next == End {
+		return nil, ErrRunCompleted
+	}
+	if cp.Visits == nil {
+		cp.Visits = map[string]int{}
+	}
+
+	config.RunID = runID
+	return g.run(ctx, cp, config)
+}
+
+func (g *Graph) run(ctx context.Context, cp *Checkpoint, config RunConfig) (*Result, error) {
+	defaults := DefaultRunConfig()
+	if config.MaxSteps <= 0 {
+		config.MaxSteps = defaults.MaxSteps
+	}
+	if config.MaxVisits <= 0 {
+		config.MaxVisits = defaults.MaxVisits
+	}
+
+	result := &Result{
+		RunID: config.RunID,
+		State: pipeline.NewState(cp.Values),
+		Trace: &pipeline.Trace{Pipeline: g.name, Start: time.Now()},
+	}
+	defer func() { result.Trace.Duration = time.Since(result.Trace.Start) }()
+
+	for cp.Next != End {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		node, ok := g.nodes[cp.Next]
+		if !ok {
+			return result, fmt.Errorf("%w: %q", ErrUnknownNode, cp.Next)
+		}
+		if cp.Steps >= config.MaxSteps {
+			return result, ErrMaxSteps
+		}
+		if cp.Visits[cp.Next] >= config.MaxVisits {
+			return result, fmt.Errorf("%w: %q", ErrMaxVisits, cp.Next)
+		}
+
+		cp.Steps++
+		cp.Visits[cp.Next]++
+		result.Path = append(result.Path, cp.Next)
+
+		if err := node.Execute(ctx, result.State, result.Trace); err != nil {
+			// Save the failing node as next so Resume retries it
+			if saveErr := g.save(ctx, cp, result.State, config); saveErr != nil {
+				return result, errors.Join(err, saveErr)
+			}
+			return result, fmt.Errorf("graph %s: node %s: %w", g.name, cp.Next, err)
+		}
+		if config.OnNode != nil {
+			config.OnNode(cp.Next, result.State)
+		}
+
+		cp.Next = g.next(cp.Next, result.State)
+		if err := g.save(ctx, cp, result.State, config); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+func (g *Graph) save(ctx context.Context, cp *Checkpoint, s *pipeline.State, config RunConfig) error {
+	if config.Checkpointer == nil {
+		return nil
+	}
+	cp.Values = s.Values()
+	cp.Updated = time.Now()
+	if err := config.Checkpointer.Save(ctx, cp); err != nil {
+		return fmt.Errorf("graph: save checkpoint: %w", err)
+	}
+	return nil
+}
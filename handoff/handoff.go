@@ -0,0 +1,251 @@
+// Package handoff turns a conversation into a structured summary (issue,
+// sentiment, action items) and posts it to ticketing systems or CRMs, so a
+// human can pick up where the assistant left off.
+//
+//	h := handoff.New(handoff.Config{
+//		Client: client,
+//		Sinks:  []handoff.Sink{handoff.NewWebhookSinkSimple("https://crm.example.com/hooks/ai")},
+//	})
+//	summary, err := h.Send(ctx, sessionID, chat.History())
+package handoff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// Handoff errors
+var (
+	ErrNoClient   = errors.New("handoff: client is required")
+	ErrNoMessages = errors.New("handoff: conversation is empty")
+)
+
+// Sentiment values
+const (
+	SentimentPositive = "positive"
+	SentimentNeutral  = "neutral"
+	SentimentNegative = "negative"
+	SentimentMixed    = "mixed"
+)
+
+// Priority values
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
+
+// ActionItem is a follow-up task from the conversation
+type ActionItem struct {
+	Description string `json:"description"`
+	Owner       string `json:"owner,omitempty"` // "agent", "customer" or a team
+	Due         string `json:"due,omitempty"`   // As stated, e.g. "by Friday"
+}
+
+// Summary is the handoff of one conversation
+type Summary struct {
+	ConversationID string         `json:"conversation_id,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	Messages       int            `json:"messages"` // Messages summarized
+	Issue          string         `json:"issue"`
+	Category       string         `json:"category,omitempty"`
+	Resolved       bool           `json:"resolved"`
+	Resolution     string         `json:"resolution,omitempty"`
+	Sentiment      string         `json:"sentiment"` // positive, neutral, negative or mixed
+	Priority       string         `json:"priority"`  // low, normal, high or urgent
+	ActionItems    []ActionItem   `json:"action_items"`
+	Metadata       map[string]any `json:"metadata,omitempty"` // From Config.Metadata
+}
+
+// extracted is the part of a Summary the model fills in
+type extracted struct {
+	Issue       string       `json:"issue"`
+	Category    string       `json:"category"`
+	Resolved    bool         `json:"resolved"`
+	Resolution  string       `json:"resolution"`
+	Sentiment   string       `json:"sentiment"`
+	Priority    string       `json:"priority"`
+	ActionItems []ActionItem `json:"action_items"`
+}
+
+// Config holds configuration for a Handoff
+type Config struct {
+	Client *simpleai.Client // Required
+
+	// Sinks receive each summary from Send
+	Sinks []Sink
+
+	// Instructions add domain guidance for the summary, e.g. the allowed
+	// categories or who owns which action items
+	Instructions string
+
+	// Options apply to the extraction request, e.g. WithModel for a
+	// cheaper model
+	Options []simpleai.RequestOption
+
+	// MaxChars limits the transcript sent to the model, keeping the end
+	// (default 12000)
+	MaxChars int
+
+	// Metadata is copied into every summary, e.g. the channel or product
+	Metadata map[string]any
+
+	// OnError is called when a summary started by OnEnd fails
+	OnError func(conversationID string, err error)
+}
+
+// DefaultConfig returns sensible defaults
+func DefaultConfig() Config {
+	return Config{
+		MaxChars: 12000,
+	}
+}
+
+// Handoff summarizes conversations and delivers the summaries
+type Handoff struct {
+	config Config
+}
+
+// New creates a handoff
+func New(config Config) *Handoff {
+	if config.MaxChars <= 0 {
+		config.MaxChars = DefaultConfig().MaxChars
+	}
+	return &Handoff{config: config}
+}
+
+// Summarize extracts the summary of a conversation without sending it
+func (h *Handoff) Summarize(ctx context.Context, conversationID string, messages []simpleai.Message) (*Summary, error) {
+	if h.config.Client == nil {
+		return nil, ErrNoClient
+	}
+	text := transcript(messages, h.config.MaxChars)
+	if text == "" {
+		return nil, ErrNoMessages
+	}
+
+	var input strings.Builder
+	input.WriteString("Summarize this support conversation for the person taking it over.\n")
+	input.WriteString("- issue: the customer's problem or request in one or two sentences\n")
+	input.WriteString("- category: a short label for the kind of issue\n")
+	input.WriteString("- resolved and resolution: whether and how it was solved in the conversation\n")
+	input.WriteString("- sentiment: the customer's sentiment at the end: positive, neutral, negative or mixed\n")
+	input.WriteString("- priority: low, normal, high or urgent\n")
+	input.WriteString("- action_items: follow-ups still to do; owner is \"agent\", \"customer\" or a team\n")
+	if h.config.Instructions != "" {
+		input.WriteString("\n" + h.config.Instructions + "\n")
+	}
+	input.WriteString("\nConversation:\n\n" + text)
+
+	var out extracted
+	if err := h.config.Client.Extract(ctx, input.String(), &out, h.config.Options...); err != nil {
+		return nil, fmt.Errorf("handoff: summarize: %w", err)
+	}
+
+	summary := &Summary{
+		ConversationID: conversationID,
+		CreatedAt:      time.Now(),
+		Messages:       len(messages),
+		Issue:          out.Issue,
+		Category:       out.Category,
+		Resolved:       out.Resolved,
+		Resolution:     out.Resolution,
+		Sentiment:      normalize(out.Sentiment, SentimentNeutral, SentimentPositive, SentimentNegative, SentimentMixed),
+		Priority:       normalize(out.Priority, PriorityNormal, PriorityLow, PriorityHigh, PriorityUrgent),
+		ActionItems:    out.ActionItems,
+		Metadata:       h.config.Metadata,
+	}
+	if summary.ActionItems == nil {
+		summary.ActionItems = []ActionItem{}
+	}
+	return summary, nil
+}
+
+// Send summarizes a conversation and posts the summary to every sink. The
+// summary is returned even when a sink fails.
+func (h *Handoff) Send(ctx context.Context, conversationID string, messages []simpleai.Message) (*Summary, error) {
+	summary, err := h.Summarize(ctx, conversationID, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, sink := range h.config.Sinks {
+		if err := sink.Send(ctx, *summary); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return summary, errors.Join(errs...)
+}
+
+// SendChat hands off a chat, including the summary of its compacted
+// history
+func (h *Handoff) SendChat(ctx context.Context, conversationID string, chat *simpleai.Chat) (*Summary, error) {
+	messages := chat.History()
+	if summary := chat.Summary(); summary != "" {
+		messages = append([]simpleai.Message{{
+			Role:    simpleai.RoleSystem,
+			Content: "Summary of the earlier conversation: " + summary,
+		}}, messages...)
+	}
+	return h.Send(ctx, conversationID, messages)
+}
+
+// OnEnd sends the handoff in the background, reporting failures to
+// OnError. Use it as bot.Config.OnEnd to hand off conversations when they
+// end.
+func (h *Handoff) OnEnd(ctx context.Context, key string, history []simpleai.Message) {
+	go func() {
+		_, err := h.Send(context.WithoutCancel(ctx), key, history)
+		if err != nil && h.config.OnError != nil {
+			h.config.OnError(key, err)
+		}
+	}()
+}
+
+// transcript renders messages as "role: content" lines, keeping the last
+// maxChars characters
+func transcript(messages []simpleai.Message, maxChars int) string {
+	var sb strings.Builder
+	for _, msg := range messages {
+		if strings.TrimSpace(msg.Content) == "" {
+			continue
+		}
+		speaker := string(msg.Role)
+		if msg.Name != "" {
+			speaker += " (" + msg.Name + ")"
+		}
+		sb.WriteString(speaker + ": " + msg.Content + "\n\n")
+	}
+	text := strings.TrimSpace(sb.String())
+	if len(text) <= maxChars {
+		return text
+	}
+	start := len(text) - maxChars
+	for start < len(text) && !utf8.RuneStart(text[start]) {
+		start++
+	}
+	return "..." + text[start:]
+}
+
+// normalize returns value lowercased if it is one of allowed, otherwise
+// fallback
+func normalize(value, fallback string, allowed ...string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == fallback {
+		return value
+	}
+	for _, a := range allowed {
+		if value == a {
+			return value
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,133 @@
+package handoff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/medatechnology/simpleai/middleware"
+)
+
+// Sink receives handoff summaries, e.g. a ticketing system or CRM
+type Sink interface {
+	Send(ctx context.Context, summary Summary) error
+}
+
+// SinkFunc adapts a function to a Sink, e.g. to create tickets with a
+// CRM's SDK
+type SinkFunc func(ctx context.Context, summary Summary) error
+
+// Send implements Sink
+func (f SinkFunc) Send(ctx context.Context, summary Summary) error {
+	return f(ctx, summary)
+}
+
+// WebhookSinkConfig holds configuration for a WebhookSink
+type WebhookSinkConfig struct {
+	URL     string
+	Headers map[string]string // E.g. an Authorization header for the CRM
+
+	// Secret signs the body like middleware.Webhook does, see
+	// middleware.VerifyWebhook; unsigned when empty
+	Secret string
+
+	MaxAttempts  int           // Delivery attempts (default 3)
+	InitialDelay time.Duration // Delay before the first retry, doubled each time (default 1s)
+	Timeout      time.Duration // Per-attempt HTTP timeout (default 10s)
+}
+
+// DefaultWebhookSinkConfig returns sensible defaults
+func DefaultWebhookSinkConfig() WebhookSinkConfig {
+	return WebhookSinkConfig{
+		MaxAttempts:  3,
+		InitialDelay: 1 * time.Second,
+		Timeout:      10 * time.Second,
+	}
+}
+
+// WebhookSink posts summaries as JSON to a URL
+type WebhookSink struct {
+	config WebhookSinkConfig
+	client *http.Client
+}
+
+// NewWebhookSink creates a webhook sink
+func NewWebhookSink(config WebhookSinkConfig) *WebhookSink {
+	defaults := DefaultWebhookSinkConfig()
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.InitialDelay <= 0 {
+		config.InitialDelay = defaults.InitialDelay
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	return &WebhookSink{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+// NewWebhookSinkSimple creates a webhook sink with defaults
+func NewWebhookSinkSimple(url string) *WebhookSink {
+	config := DefaultWebhookSinkConfig()
+	config.URL = url
+	return NewWebhookSink(config)
+}
+
+// Send implements Sink, retrying server errors and rate limiting
+func (w *WebhookSink) Send(ctx context.Context, summary Summary) error {
+	body, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	delay := w.config.InitialDelay
+	var lastErr error
+	for attempt := 1; attempt <= w.config.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			delay *= 2
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range w.config.Headers {
+			req.Header.Set(k, v)
+		}
+		if w.config.Secret != "" {
+			ts := strconv.FormatInt(time.Now().Unix(), 10)
+			req.Header.Set(middleware.WebhookTimestampHeader, ts)
+			req.Header.Set(middleware.WebhookSignatureHeader, middleware.SignWebhook(w.config.Secret, ts, body))
+		}
+
+		resp, err := w.client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("handoff: webhook returned status %d", resp.StatusCode)
+		// Client errors other than rate limiting won't succeed on retry
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return lastErr
+		}
+	}
+	return lastErr
+}
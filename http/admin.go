@@ -0,0 +1,148 @@
+package http
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/medatechnology/simpleai/rag"
+	"github.com/medatechnology/simplehttp"
+)
+
+// SessionsResponse lists the active sessions
+type SessionsResponse struct {
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+// ClearRAGResponse reports a cleared RAG namespace
+type ClearRAGResponse struct {
+	Namespace string `json:"namespace"`
+	Deleted   int    `json:"deleted"` // Documents in the store before clearing
+}
+
+// ListSessionsHandler creates an admin handler that lists the active
+// sessions, most recently active first. Requires WithAdminToken.
+func ListSessionsHandler(sessions *Sessions, opts ...HandlerOption) simplehttp.HandlerFunc {
+	cfg := newHandlerConfig(opts)
+	return func(c simplehttp.Context) error {
+		cfg.setHeaders(c, false)
+		if !cfg.authorized(c) {
+			return unauthorized(c)
+		}
+		return c.JSON(http.StatusOK, SessionsResponse{Sessions: sessions.List()})
+	}
+}
+
+// SessionHandler creates an admin handler that returns a session's
+// history and summary, e.g. GET /admin/session?id=abc. Stored sessions can
+// be inspected without loading them. Requires WithAdminToken.
+func SessionHandler(sessions *Sessions, opts ...HandlerOption) simplehttp.HandlerFunc {
+	cfg := newHandlerConfig(opts)
+	return func(c simplehttp.Context) error {
+		cfg.setHeaders(c, false)
+		if !cfg.authorized(c) {
+			return unauthorized(c)
+		}
+		id := c.GetQueryParam("id")
+		if id == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "id is required",
+			})
+		}
+
+		detail, err := sessions.Inspect(c.Context(), id)
+		if err != nil {
+			return sessionError(c, err)
+		}
+		return c.JSON(http.StatusOK, detail)
+	}
+}
+
+// DeleteSessionHandler creates an admin handler that ends a session and
+// deletes its stored history, e.g. DELETE /admin/session?id=abc. Requires
+// WithAdminToken.
+func DeleteSessionHandler(sessions *Sessions, opts ...HandlerOption) simplehttp.HandlerFunc {
+	cfg := newHandlerConfig(opts)
+	return func(c simplehttp.Context) error {
+		cfg.setHeaders(c, false)
+		if !cfg.authorized(c) {
+			return unauthorized(c)
+		}
+		id := c.GetQueryParam("id")
+		if id == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "id is required",
+			})
+		}
+
+		if err := sessions.Delete(c.Context(), id); err != nil {
+			return sessionError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]string{"status": "deleted"})
+	}
+}
+
+// ClearRAGHandler creates an admin handler that deletes every document of
+// a RAG namespace, e.g. DELETE /admin/rag?namespace=faq. namespaces maps
+// names to the RAGs that may be cleared. Requires WithAdminToken.
+func ClearRAGHandler(namespaces map[string]*rag.RAG, opts ...HandlerOption) simplehttp.HandlerFunc {
+	cfg := newHandlerConfig(opts)
+	return func(c simplehttp.Context) error {
+		cfg.setHeaders(c, false)
+		if !cfg.authorized(c) {
+			return unauthorized(c)
+		}
+		name := c.GetQueryParam("namespace")
+		r, ok := namespaces[name]
+		if !ok {
+			names := make([]string, 0, len(namespaces))
+			for n := range namespaces {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return c.JSON(http.StatusNotFound, map[string]any{
+				"error":      "unknown namespace: " + name,
+				"namespaces": names,
+			})
+		}
+
+		store := r.Store()
+		deleted := store.Count()
+		if err := store.Clear(c.Context()); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		return c.JSON(http.StatusOK, ClearRAGResponse{Namespace: name, Deleted: deleted})
+	}
+}
+
+// authorized checks the request's bearer token against AdminToken. Without
+// an AdminToken nothing is authorized.
+func (cfg *HandlerConfig) authorized(c simplehttp.Context) bool {
+	if cfg.AdminToken == "" {
+		return false
+	}
+	expected := "Bearer " + cfg.AdminToken
+	return subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte(expected)) == 1
+}
+
+// unauthorized responds with 401
+func unauthorized(c simplehttp.Context) error {
+	return c.JSON(http.StatusUnauthorized, map[string]string{
+		"error": "admin token required",
+	})
+}
+
+// sessionError responds with 404 for unknown sessions and 500 otherwise
+func sessionError(c simplehttp.Context, err error) error {
+	if errors.Is(err, ErrSessionNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{
+		"error": err.Error(),
+	})
+}
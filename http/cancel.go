@@ -0,0 +1,171 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/medatechnology/simplehttp"
+)
+
+// ErrRequestCancelled is the cancellation cause of a stream stopped through
+// Requests.Cancel
+var ErrRequestCancelled = errors.New("simpleai: request cancelled")
+
+// ErrDuplicateRequest is returned when a client-chosen request ID is already
+// in flight
+var ErrDuplicateRequest = errors.New("simpleai: request ID already in flight")
+
+// RequestEvent is the SSE event name that tells the client the ID of its
+// stream, sent before any content
+const RequestEvent = "request"
+
+// FinishCancelled is the finish reason of a stream stopped through
+// Requests.Cancel
+const FinishCancelled = "cancelled"
+
+// Requests tracks in-flight streams by caller and request ID so they can be
+// stopped from another HTTP call, e.g. a "stop generating" button. A caller
+// can only see and stop its own streams.
+type Requests struct {
+	mu     sync.Mutex
+	active map[requestKey]context.CancelCauseFunc
+}
+
+// requestKey scopes a request ID to the caller that started the stream
+type requestKey struct {
+	caller string
+	id     string
+}
+
+// NewRequests creates an empty request registry
+func NewRequests() *Requests {
+	return &Requests{active: make(map[requestKey]context.CancelCauseFunc)}
+}
+
+// Cancel stops the in-flight streams with the given ID, whoever started
+// them. It reports whether any stream was found.
+func (r *Requests) Cancel(id string) bool {
+	r.mu.Lock()
+	var cancels []context.CancelCauseFunc
+	for key, cancel := range r.active {
+		if key.id == id {
+			cancels = append(cancels, cancel)
+		}
+	}
+	r.mu.Unlock()
+	for _, cancel := range cancels {
+		cancel(ErrRequestCancelled)
+	}
+	return len(cancels) > 0
+}
+
+// CancelFor stops caller's in-flight stream with the given ID. It reports
+// whether the stream was found; streams of other callers are never stopped.
+func (r *Requests) CancelFor(caller, id string) bool {
+	r.mu.Lock()
+	cancel, ok := r.active[requestKey{caller, id}]
+	r.mu.Unlock()
+	if ok {
+		cancel(ErrRequestCancelled)
+	}
+	return ok
+}
+
+// IDs returns the IDs of the in-flight streams of all callers, sorted
+func (r *Requests) IDs() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]string, 0, len(r.active))
+	for key := range r.active {
+		ids = append(ids, key.id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// start registers caller's stream under id, or a random ID if empty, and
+// returns its cancellable context and a function removing it from the
+// registry
+func (r *Requests) start(ctx context.Context, caller, id string) (context.Context, string, func(), error) {
+	if id == "" {
+		id = newRequestID()
+	}
+	key := requestKey{caller, id}
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.active[key]; ok {
+		cancel(nil)
+		return nil, "", nil, ErrDuplicateRequest
+	}
+	r.active[key] = cancel
+	return ctx, id, func() {
+		r.mu.Lock()
+		delete(r.active, key)
+		r.mu.Unlock()
+		cancel(nil)
+	}, nil
+}
+
+// register starts tracking the request's stream when a registry is
+// configured, and cancels its context when the lifecycle closes. The
+// returned done function is safe to call more than once.
+func (cfg *HandlerConfig) register(c simplehttp.Context, id string) (context.Context, string, func(), error) {
+	ctx, stop := cfg.cancelOnClose(c.Context())
+	if cfg.Requests == nil {
+		return ctx, "", stop, nil
+	}
+	ctx, id, done, err := cfg.Requests.start(ctx, cfg.caller(c), id)
+	if err != nil {
+		stop()
+		return nil, "", nil, err
+	}
+	var once sync.Once
+	return ctx, id, func() {
+		once.Do(func() {
+			done()
+			stop()
+		})
+	}, nil
+}
+
+// CancelRequest identifies the stream to stop
+type CancelRequest struct {
+	RequestID string `json:"request_id"`
+}
+
+// CancelHandler creates an HTTP handler that stops an in-flight stream
+// started by a handler using WithRequests. The stream ends with a done event
+// whose finish_reason is "cancelled". Only the caller that started a stream
+// can stop it, so pass the same WithCaller as to the stream handlers; other
+// callers get 404, as for unknown IDs.
+func CancelHandler(requests *Requests, opts ...HandlerOption) simplehttp.HandlerFunc {
+	cfg := newHandlerConfig(opts)
+	return func(c simplehttp.Context) error {
+		cfg.setHeaders(c, false)
+		var req CancelRequest
+		if err := c.BindJSON(&req); err != nil || req.RequestID == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "request_id is required",
+			})
+		}
+		if !requests.CancelFor(cfg.caller(c), req.RequestID) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "no request in flight with this ID",
+			})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"status": FinishCancelled})
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "req_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,212 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/medatechnology/simpleai/embedding"
+	"github.com/medatechnology/simpleai/rag"
+	"github.com/medatechnology/simplehttp"
+)
+
+// MaxEmbeddingInputs is the most texts one embeddings request may hold
+const MaxEmbeddingInputs = 2048
+
+// EmbeddingsRequest is an OpenAI-compatible embeddings request
+type EmbeddingsRequest struct {
+	Input json.RawMessage `json:"input"`           // A string or an array of strings
+	Model string          `json:"model,omitempty"` // Echoed; the handler's embedder is always used
+	// EncodingFormat is "float" (default) or "base64" (little-endian
+	// float32), which the OpenAI SDKs request by default
+	EncodingFormat string `json:"encoding_format,omitempty"`
+}
+
+// EmbeddingsResponse is an OpenAI-compatible embeddings response
+type EmbeddingsResponse struct {
+	Object string          `json:"object"` // "list"
+	Data   []EmbeddingData `json:"data"`
+	Model  string          `json:"model"`
+	Usage  EmbeddingsUsage `json:"usage"`
+}
+
+// EmbeddingData is one embedding, in input order
+type EmbeddingData struct {
+	Object    string `json:"object"` // "embedding"
+	Index     int    `json:"index"`
+	Embedding any    `json:"embedding"` // []float64, or a base64 string
+}
+
+// EmbeddingsUsage estimates the input tokens at ~4 characters per token
+type EmbeddingsUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// EmbeddingsHandler creates an OpenAI-compatible /v1/embeddings handler, so
+// clients in any language (including the OpenAI SDKs) can embed text with
+// the server's embedder
+func EmbeddingsHandler(embedder embedding.Embedder, opts ...HandlerOption) simplehttp.HandlerFunc {
+	cfg := newHandlerConfig(opts)
+	return func(c simplehttp.Context) error {
+		cfg.setHeaders(c, false)
+		var req EmbeddingsRequest
+		if err := c.BindJSON(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid request: " + err.Error(),
+			})
+		}
+		texts, err := embeddingInputs(req.Input)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid input: " + err.Error(),
+			})
+		}
+		if req.EncodingFormat != "" && req.EncodingFormat != "float" && req.EncodingFormat != "base64" {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid encoding_format: " + req.EncodingFormat,
+			})
+		}
+
+		vectors, err := embedder.EmbedBatch(c.Context(), texts)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		if len(vectors) != len(texts) {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "embedder returned a wrong number of embeddings",
+			})
+		}
+
+		resp := EmbeddingsResponse{
+			Object: "list",
+			Data:   make([]EmbeddingData, len(vectors)),
+			Model:  req.Model,
+		}
+		if resp.Model == "" {
+			resp.Model = embedder.Name()
+		}
+		for i, vector := range vectors {
+			var value any = vector
+			if req.EncodingFormat == "base64" {
+				value = encodeFloat32(vector)
+			}
+			resp.Data[i] = EmbeddingData{Object: "embedding", Index: i, Embedding: value}
+			resp.Usage.PromptTokens += (len(texts[i]) + 3) / 4
+		}
+		resp.Usage.TotalTokens = resp.Usage.PromptTokens
+		recordTokens(c.Context(), resp.Usage.TotalTokens)
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+// embeddingInputs decodes an input that is a string or an array of strings
+func embeddingInputs(raw json.RawMessage) ([]string, error) {
+	var one string
+	if err := json.Unmarshal(raw, &one); err == nil {
+		if one == "" {
+			return nil, errors.New("input is empty")
+		}
+		return []string{one}, nil
+	}
+	var many []string
+	if err := json.Unmarshal(raw, &many); err != nil {
+		return nil, errors.New("input must be a string or an array of strings")
+	}
+	if len(many) == 0 {
+		return nil, errors.New("input is empty")
+	}
+	if len(many) > MaxEmbeddingInputs {
+		return nil, errors.New("too many inputs")
+	}
+	for _, text := range many {
+		if text == "" {
+			return nil, errors.New("input contains an empty string")
+		}
+	}
+	return many, nil
+}
+
+// encodeFloat32 encodes a vector as base64 little-endian float32, the
+// OpenAI "base64" encoding format
+func encodeFloat32(vector []float64) string {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(v)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// MaxSearchResults caps top_k in RAG search requests
+const MaxSearchResults = 100
+
+// RAGSearchRequest is a retrieval query
+type RAGSearchRequest struct {
+	Query string `json:"query"`
+	TopK  int    `json:"top_k,omitempty"` // Default: the RAG's TopK
+	// MinSimilarity drops weaker results; it can only raise the RAG's
+	// MinSimilarity
+	MinSimilarity float64 `json:"min_similarity,omitempty"`
+}
+
+// RAGSearchResult is one retrieved document
+type RAGSearchResult struct {
+	ID         string         `json:"id"`
+	Content    string         `json:"content"`
+	Similarity float64        `json:"similarity"`
+	Metadata   map[string]any `json:"metadata,omitempty"`
+	ExpiresAt  time.Time      `json:"expires_at,omitzero"`
+}
+
+// RAGSearchResponse holds the results, most similar first
+type RAGSearchResponse struct {
+	Results []RAGSearchResult `json:"results"`
+}
+
+// RAGSearchHandler creates an HTTP handler that searches a RAG store, e.g.
+// {"query": "refund policy", "top_k": 3}, for retrieval from non-Go clients
+func RAGSearchHandler(r *rag.RAG, opts ...HandlerOption) simplehttp.HandlerFunc {
+	cfg := newHandlerConfig(opts)
+	return func(c simplehttp.Context) error {
+		cfg.setHeaders(c, false)
+		var req RAGSearchRequest
+		if err := c.BindJSON(&req); err != nil || req.Query == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "query is required",
+			})
+		}
+		if req.TopK <= 0 {
+			req.TopK = r.Config().TopK
+		}
+		req.TopK = min(req.TopK, MaxSearchResults)
+
+		results, err := r.Search(c.Context(), req.Query, req.TopK)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		resp := RAGSearchResponse{Results: []RAGSearchResult{}}
+		for _, result := range results {
+			if result.Similarity < req.MinSimilarity {
+				continue
+			}
+			doc := result.Document
+			resp.Results = append(resp.Results, RAGSearchResult{
+				ID:         doc.ID,
+				Content:    doc.Content,
+				Similarity: result.Similarity,
+				Metadata:   doc.Metadata,
+				ExpiresAt:  doc.ExpiresAt,
+			})
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
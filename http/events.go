@@ -0,0 +1,66 @@
+package http
+
+import (
+	"encoding/json"
+
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simplehttp"
+)
+
+// SSE event names of streamed completions. Clients can dispatch on the
+// event name instead of inspecting the JSON payload.
+const (
+	DeltaEvent = "delta" // Content: {"content": "..."}
+	UsageEvent = "usage" // Token usage before the done event, when the provider reports it
+	DoneEvent  = "done"  // End of the stream: {"finish_reason": "stop"}
+	ErrorEvent = "error" // Failure: {"error": "..."}
+)
+
+// DeltaData is the payload of a delta event
+type DeltaData struct {
+	Content string `json:"content"`
+}
+
+// DoneData is the payload of a done event
+type DoneData struct {
+	FinishReason string              `json:"finish_reason,omitempty"`
+	StopSequence string              `json:"stop_sequence,omitempty"`
+	ToolCalls    []simpleai.ToolCall `json:"tool_calls,omitempty"`
+	Citations    []simpleai.Citation `json:"citations,omitempty"`
+}
+
+// sendEvent writes a stream event as typed SSE events, or as one unnamed
+// StreamChunk in legacy mode
+func sendEvent(w simplehttp.SSEWriter, event simpleai.StreamEvent, legacy bool) {
+	if legacy {
+		data, _ := json.Marshal(StreamChunk{
+			Content:      event.Content,
+			Done:         event.Done,
+			FinishReason: event.FinishReason,
+		})
+		w.Send(string(data))
+		return
+	}
+
+	if event.Content != "" {
+		writeEvent(w, DeltaEvent, DeltaData{Content: event.Content})
+	}
+	if !event.Done {
+		return
+	}
+	if event.Usage != nil {
+		writeEvent(w, UsageEvent, event.Usage)
+	}
+	writeEvent(w, DoneEvent, DoneData{
+		FinishReason: event.FinishReason,
+		StopSequence: event.StopSequence,
+		ToolCalls:    event.ToolCalls,
+		Citations:    event.Citations,
+	})
+}
+
+// writeEvent sends a named SSE event with a JSON payload
+func writeEvent(w simplehttp.SSEWriter, name string, payload any) {
+	data, _ := json.Marshal(payload)
+	w.SendEvent(simplehttp.SSEEvent{Event: name, Data: string(data)})
+}
@@ -1,7 +1,8 @@
 package http
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"net/http"
 
 	"github.com/medatechnology/simpleai"
@@ -15,17 +16,26 @@ type ChatRequest struct {
 	MaxTokens   int                `json:"max_tokens,omitempty"`
 	Temperature float64            `json:"temperature,omitempty"`
 	Stream      bool               `json:"stream,omitempty"`
+	// RequestID names the stream for CancelHandler (default: generated and
+	// sent in a "request" SSE event)
+	RequestID string `json:"request_id,omitempty"`
 }
 
+// IdempotencyKeyHeader is the request header CompleteHandler reads the
+// idempotency key from
+const IdempotencyKeyHeader = "Idempotency-Key"
+
 // ChatResponse represents a non-streaming chat response
 type ChatResponse struct {
+	ID           string         `json:"id,omitempty"`
 	Content      string         `json:"content"`
 	Model        string         `json:"model"`
 	FinishReason string         `json:"finish_reason"`
 	Usage        simpleai.Usage `json:"usage"`
 }
 
-// StreamChunk represents a streaming response chunk (SSE data)
+// StreamChunk is the unnamed SSE data of each chunk in legacy mode (see
+// WithLegacyEvents)
 type StreamChunk struct {
 	Content      string `json:"content,omitempty"`
 	Done         bool   `json:"done"`
@@ -33,14 +43,39 @@ type StreamChunk struct {
 }
 
 // StreamHandler creates an HTTP handler for streaming AI completions via SSE
-func StreamHandler(client *simpleai.Client) simplehttp.HandlerFunc {
+func StreamHandler(client *simpleai.Client, opts ...HandlerOption) simplehttp.HandlerFunc {
+	cfg := newHandlerConfig(opts)
 	return func(c simplehttp.Context) error {
+		cfg.setHeaders(c, true)
+		release, ok := cfg.track()
+		if !ok {
+			return shuttingDown(c)
+		}
+
+		if v := cfg.checkBody(c); v != nil {
+			release()
+			return rejectRequest(c, v)
+		}
 		var req ChatRequest
 		if err := c.BindJSON(&req); err != nil {
+			release()
+			if v := bodyTooLarge(err); v != nil {
+				return rejectRequest(c, v)
+			}
 			return c.JSON(http.StatusBadRequest, map[string]string{
 				"error": "invalid request: " + err.Error(),
 			})
 		}
+		if v := cfg.validateChat(&req); v != nil {
+			release()
+			return rejectRequest(c, v)
+		}
+		if _, err := cfg.applyPolicy(c, &req); err != nil {
+			release()
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": err.Error() + ": " + req.Model,
+			})
+		}
 
 		// Convert to simpleai request
 		aiReq := &simpleai.Request{
@@ -51,9 +86,19 @@ func StreamHandler(client *simpleai.Client) simplehttp.HandlerFunc {
 			Stream:      true,
 		}
 
+		ctx, requestID, done, err := cfg.register(c, req.RequestID)
+		if err != nil {
+			release()
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
 		// Start streaming
-		events, err := client.Stream(c.Context(), aiReq)
+		events, err := client.Stream(ctx, aiReq)
 		if err != nil {
+			done()
+			release()
 			return c.JSON(http.StatusInternalServerError, map[string]string{
 				"error": err.Error(),
 			})
@@ -61,40 +106,45 @@ func StreamHandler(client *simpleai.Client) simplehttp.HandlerFunc {
 
 		// Stream via SSE
 		return c.SSE(func(w simplehttp.SSEWriter) error {
-			for event := range events {
-				if event.Error != nil {
-					// Send error event
-					errData, _ := json.Marshal(map[string]string{"error": event.Error.Error()})
-					w.SendEvent(simplehttp.SSEEvent{Event: "error", Data: string(errData)})
-					return event.Error
-				}
-
-				chunk := StreamChunk{
-					Content:      event.Content,
-					Done:         event.Done,
-					FinishReason: event.FinishReason,
-				}
-				data, _ := json.Marshal(chunk)
-				w.Send(string(data))
-
-				if event.Done {
-					break
-				}
-			}
-			return nil
+			defer release()
+			defer done()
+			return writeEvents(ctx, w, requestID, events, cfg)
 		})
 	}
 }
 
 // CompleteHandler creates an HTTP handler for non-streaming AI completions
-func CompleteHandler(client *simpleai.Client) simplehttp.HandlerFunc {
+func CompleteHandler(client *simpleai.Client, opts ...HandlerOption) simplehttp.HandlerFunc {
+	cfg := newHandlerConfig(opts)
 	return func(c simplehttp.Context) error {
+		cfg.setHeaders(c, false)
+		release, ok := cfg.track()
+		if !ok {
+			return shuttingDown(c)
+		}
+		defer release()
+
+		if v := cfg.checkBody(c); v != nil {
+			return rejectRequest(c, v)
+		}
 		var req ChatRequest
 		if err := c.BindJSON(&req); err != nil {
+			if v := bodyTooLarge(err); v != nil {
+				return rejectRequest(c, v)
+			}
 			return c.JSON(http.StatusBadRequest, map[string]string{
 				"error": "invalid request: " + err.Error(),
 			})
 		}
+		if v := cfg.validateChat(&req); v != nil {
+			return rejectRequest(c, v)
+		}
+		alias, err := cfg.applyPolicy(c, &req)
+		if err != nil {
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": err.Error() + ": " + req.Model,
+			})
+		}
 
 		// Convert to simpleai request
 		aiReq := &simpleai.Request{
@@ -104,17 +154,35 @@ func CompleteHandler(client *simpleai.Client) simplehttp.HandlerFunc {
 			Temperature: req.Temperature,
 		}
 
-		// Complete request
-		resp, err := client.Complete(c.Context(), aiReq)
+		// Complete request; a retried Idempotency-Key gets the original
+		// response on clients using simpleai.WithIdempotency. Keys are
+		// scoped to the caller.
+		var reqOpts []simpleai.RequestOption
+		if key := c.GetHeader(IdempotencyKeyHeader); key != "" {
+			reqOpts = append(reqOpts, simpleai.WithIdempotencyKey(cfg.caller(c)+"/"+key))
+		}
+		resp, err := client.Complete(c.Context(), aiReq, reqOpts...)
+		if errors.Is(err, simpleai.ErrIdempotencyKeyReused) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]string{
+				"error": err.Error(),
+			})
+		}
 		if err != nil {
 			return c.JSON(http.StatusInternalServerError, map[string]string{
 				"error": err.Error(),
 			})
 		}
 
+		recordTokens(c.Context(), resp.Usage.TotalTokens)
+
+		model := resp.Model
+		if alias != "" {
+			model = alias
+		}
 		return c.JSON(http.StatusOK, ChatResponse{
+			ID:           resp.ID,
 			Content:      resp.Content,
-			Model:        resp.Model,
+			Model:        model,
 			FinishReason: resp.FinishReason,
 			Usage:        resp.Usage,
 		})
@@ -122,20 +190,50 @@ func CompleteHandler(client *simpleai.Client) simplehttp.HandlerFunc {
 }
 
 // ChatStreamHandler creates an HTTP handler for streaming chat sessions
-func ChatStreamHandler(chat *simpleai.Chat) simplehttp.HandlerFunc {
+func ChatStreamHandler(chat *simpleai.Chat, opts ...HandlerOption) simplehttp.HandlerFunc {
+	cfg := newHandlerConfig(opts)
 	return func(c simplehttp.Context) error {
+		cfg.setHeaders(c, true)
+		release, ok := cfg.track()
+		if !ok {
+			return shuttingDown(c)
+		}
+
+		if v := cfg.checkBody(c); v != nil {
+			release()
+			return rejectRequest(c, v)
+		}
 		var req struct {
-			Message string `json:"message"`
+			Message   string `json:"message"`
+			RequestID string `json:"request_id,omitempty"`
 		}
 		if err := c.BindJSON(&req); err != nil {
+			release()
+			if v := bodyTooLarge(err); v != nil {
+				return rejectRequest(c, v)
+			}
 			return c.JSON(http.StatusBadRequest, map[string]string{
 				"error": "invalid request: " + err.Error(),
 			})
 		}
+		if v := cfg.validateMessage(req.Message); v != nil {
+			release()
+			return rejectRequest(c, v)
+		}
+
+		ctx, requestID, done, err := cfg.register(c, req.RequestID)
+		if err != nil {
+			release()
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": err.Error(),
+			})
+		}
 
 		// Start streaming
-		events, err := chat.Stream(c.Context(), req.Message)
+		events, err := chat.Stream(ctx, req.Message)
 		if err != nil {
+			done()
+			release()
 			return c.JSON(http.StatusInternalServerError, map[string]string{
 				"error": err.Error(),
 			})
@@ -143,26 +241,69 @@ func ChatStreamHandler(chat *simpleai.Chat) simplehttp.HandlerFunc {
 
 		// Stream via SSE
 		return c.SSE(func(w simplehttp.SSEWriter) error {
-			for event := range events {
-				if event.Error != nil {
-					errData, _ := json.Marshal(map[string]string{"error": event.Error.Error()})
-					w.SendEvent(simplehttp.SSEEvent{Event: "error", Data: string(errData)})
-					return event.Error
-				}
-
-				chunk := StreamChunk{
-					Content:      event.Content,
-					Done:         event.Done,
-					FinishReason: event.FinishReason,
-				}
-				data, _ := json.Marshal(chunk)
-				w.Send(string(data))
-
-				if event.Done {
-					break
-				}
-			}
-			return nil
+			defer release()
+			defer done()
+			return writeEvents(ctx, w, requestID, events, cfg)
 		})
 	}
 }
+
+// writeEvents forwards stream events to the SSE writer until the stream is
+// done, fails, is cancelled, or the server starts closing streams
+func writeEvents(ctx context.Context, w simplehttp.SSEWriter, requestID string, events <-chan simpleai.StreamEvent, cfg *HandlerConfig) error {
+	if requestID != "" {
+		writeEvent(w, RequestEvent, map[string]string{"request_id": requestID})
+	}
+
+	closing := cfg.closing()
+	for {
+		select {
+		case <-closing:
+			// The provider call is cancelled; let its goroutine exit in the
+			// background
+			go drainEvents(events)
+			writeEvent(w, ShutdownEvent, map[string]string{"message": "server shutting down"})
+			return nil
+		case event, ok := <-events:
+			if errors.Is(context.Cause(ctx), ErrShuttingDown) && (!ok || event.Error != nil) {
+				go drainEvents(events)
+				writeEvent(w, ShutdownEvent, map[string]string{"message": "server shutting down"})
+				return nil
+			}
+			if cancelled := errors.Is(context.Cause(ctx), ErrRequestCancelled); cancelled && (!ok || event.Error != nil) {
+				go drainEvents(events)
+				sendEvent(w, simpleai.StreamEvent{Done: true, FinishReason: FinishCancelled}, cfg.LegacyEvents)
+				return nil
+			}
+			if !ok {
+				return nil
+			}
+			if event.Error != nil {
+				// Send error event
+				writeEvent(w, ErrorEvent, map[string]string{"error": event.Error.Error()})
+				return event.Error
+			}
+
+			if event.Usage != nil {
+				recordTokens(ctx, event.Usage.TotalTokens)
+			}
+			sendEvent(w, event, cfg.LegacyEvents)
+			if event.Done {
+				return nil
+			}
+		}
+	}
+}
+
+// drainEvents consumes remaining events so the producer can exit
+func drainEvents(events <-chan simpleai.StreamEvent) {
+	for range events {
+	}
+}
+
+// shuttingDown responds with 503 while the server drains
+func shuttingDown(c simplehttp.Context) error {
+	return c.JSON(http.StatusServiceUnavailable, map[string]string{
+		"error": ErrShuttingDown.Error(),
+	})
+}
@@ -0,0 +1,180 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/medatechnology/simplehttp"
+)
+
+// CORSConfig holds cross-origin settings for browser clients
+type CORSConfig struct {
+	// AllowedOrigins are exact origins, "*" for any, or wildcard subdomains
+	// such as "https://*.example.com". "*" is ignored with AllowCredentials,
+	// which needs explicit origins.
+	AllowedOrigins []string
+
+	AllowedMethods []string // Default GET, POST, DELETE, OPTIONS
+	AllowedHeaders []string // Default Content-Type, Authorization, Last-Event-ID, Idempotency-Key
+	ExposedHeaders []string
+
+	// AllowCredentials lets browsers send cookies to the listed origins;
+	// the origin is then echoed instead of "*"
+	AllowCredentials bool
+
+	// MaxAge is how long browsers may cache a preflight (default 10m)
+	MaxAge time.Duration
+}
+
+// HeaderConfig holds the response headers of the handlers
+type HeaderConfig struct {
+	// CORS sends cross-origin headers; nil sends none
+	CORS *CORSConfig
+
+	// StreamCacheControl is the Cache-Control of SSE streams. The default
+	// "no-cache, no-transform" also stops proxies such as Cloudflare from
+	// compressing, and so buffering, streams.
+	StreamCacheControl string
+
+	// ProxyBuffering keeps nginx's response buffering on streams; by
+	// default streams send "X-Accel-Buffering: no" so each event is flushed
+	ProxyBuffering bool
+
+	// Headers are added to every response
+	Headers map[string]string
+}
+
+// DefaultHeaderConfig returns the headers handlers send without
+// WithHeaders
+func DefaultHeaderConfig() HeaderConfig {
+	return HeaderConfig{
+		StreamCacheControl: "no-cache, no-transform",
+	}
+}
+
+// WithHeaders sets the response headers of a handler, e.g. CORS for
+// browser clients
+func WithHeaders(config HeaderConfig) HandlerOption {
+	return func(c *HandlerConfig) {
+		if config.StreamCacheControl == "" {
+			config.StreamCacheControl = DefaultHeaderConfig().StreamCacheControl
+		}
+		if cors := config.CORS; cors != nil {
+			cors := *cors
+			if len(cors.AllowedMethods) == 0 {
+				cors.AllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodOptions}
+			}
+			if len(cors.AllowedHeaders) == 0 {
+				cors.AllowedHeaders = []string{"Content-Type", "Authorization", "Last-Event-ID", IdempotencyKeyHeader}
+			}
+			if cors.MaxAge <= 0 {
+				cors.MaxAge = 10 * time.Minute
+			}
+			config.CORS = &cors
+		}
+		c.Headers = &config
+	}
+}
+
+// headers returns the configured or default headers
+func (cfg *HandlerConfig) headers() HeaderConfig {
+	if cfg.Headers != nil {
+		return *cfg.Headers
+	}
+	return DefaultHeaderConfig()
+}
+
+// setHeaders writes the configured headers; stream adds the SSE ones
+func (cfg *HandlerConfig) setHeaders(c simplehttp.Context, stream bool) {
+	h := cfg.headers()
+	for key, value := range h.Headers {
+		c.SetResponseHeader(key, value)
+	}
+	if h.CORS != nil {
+		h.CORS.setOrigin(c)
+		if len(h.CORS.ExposedHeaders) > 0 {
+			c.SetResponseHeader("Access-Control-Expose-Headers", strings.Join(h.CORS.ExposedHeaders, ", "))
+		}
+	}
+	if stream {
+		c.SetResponseHeader("Cache-Control", h.StreamCacheControl)
+		if !h.ProxyBuffering {
+			c.SetResponseHeader("X-Accel-Buffering", "no")
+		}
+	}
+}
+
+// setOrigin allows the request's origin if it matches. It reports whether
+// the origin is allowed.
+func (cors *CORSConfig) setOrigin(c simplehttp.Context) bool {
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		return false
+	}
+	allowed, wildcard := false, false
+	for _, pattern := range cors.AllowedOrigins {
+		if pattern == "*" {
+			// Credentialed requests from any site would let every page act
+			// as the signed-in user
+			if cors.AllowCredentials {
+				continue
+			}
+			allowed, wildcard = true, true
+			break
+		}
+		if matchOrigin(pattern, origin) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return false
+	}
+
+	if wildcard {
+		c.SetResponseHeader("Access-Control-Allow-Origin", "*")
+	} else {
+		c.SetResponseHeader("Access-Control-Allow-Origin", origin)
+		c.SetResponseHeader("Vary", "Origin")
+	}
+	if cors.AllowCredentials {
+		c.SetResponseHeader("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+// matchOrigin matches an origin against an exact origin or one with a
+// "*." subdomain wildcard
+func matchOrigin(pattern, origin string) bool {
+	if strings.EqualFold(pattern, origin) {
+		return true
+	}
+	scheme, host, ok := strings.Cut(pattern, "://*.")
+	if !ok {
+		return false
+	}
+	prefix := strings.ToLower(scheme + "://")
+	suffix := strings.ToLower("." + host)
+	origin = strings.ToLower(origin)
+	return strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) &&
+		len(origin) > len(prefix)+len(suffix)
+}
+
+// PreflightHandler creates a handler answering CORS preflight requests;
+// register it for OPTIONS on the routes browsers call. It uses the CORS
+// settings of WithHeaders.
+func PreflightHandler(opts ...HandlerOption) simplehttp.HandlerFunc {
+	cfg := newHandlerConfig(opts)
+	return func(c simplehttp.Context) error {
+		cors := cfg.headers().CORS
+		if cors == nil || !cors.setOrigin(c) {
+			return c.String(http.StatusNoContent, "")
+		}
+		c.SetResponseHeader("Access-Control-Allow-Methods", strings.Join(cors.AllowedMethods, ", "))
+		c.SetResponseHeader("Access-Control-Allow-Headers", strings.Join(cors.AllowedHeaders, ", "))
+		c.SetResponseHeader("Access-Control-Max-Age", strconv.Itoa(int(cors.MaxAge.Seconds())))
+		return c.String(http.StatusNoContent, "")
+	}
+}
@@ -0,0 +1,161 @@
+package http
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/jobs"
+	"github.com/medatechnology/simplehttp"
+)
+
+// modelAliasTag keeps the policy alias of a job's model in its metadata
+// tags, so results report it like CompleteHandler does
+const modelAliasTag = "model_alias"
+
+// JobResponse is the state of an asynchronous completion
+type JobResponse struct {
+	ID         string        `json:"id"`
+	Status     jobs.Status   `json:"status"`
+	CreatedAt  time.Time     `json:"created_at"`
+	StartedAt  time.Time     `json:"started_at,omitzero"`
+	FinishedAt time.Time     `json:"finished_at,omitzero"`
+	Result     *ChatResponse `json:"result,omitempty"` // Once succeeded
+	Error      string        `json:"error,omitempty"`  // Once failed
+}
+
+// SubmitJobHandler creates an HTTP handler that queues a completion and
+// answers 202 with its job ID right away, e.g. POST /v1/completions/async.
+// It takes the same body as CompleteHandler; poll JobHandler for the result.
+// The job is owned by the caller, see WithCaller.
+func SubmitJobHandler(runner *jobs.Runner, opts ...HandlerOption) simplehttp.HandlerFunc {
+	cfg := newHandlerConfig(opts)
+	return func(c simplehttp.Context) error {
+		cfg.setHeaders(c, false)
+		release, ok := cfg.track()
+		if !ok {
+			return shuttingDown(c)
+		}
+		defer release()
+
+		if v := cfg.checkBody(c); v != nil {
+			return rejectRequest(c, v)
+		}
+		var req ChatRequest
+		if err := c.BindJSON(&req); err != nil {
+			if v := bodyTooLarge(err); v != nil {
+				return rejectRequest(c, v)
+			}
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid request: " + err.Error(),
+			})
+		}
+		if v := cfg.validateChat(&req); v != nil {
+			return rejectRequest(c, v)
+		}
+		alias, err := cfg.applyPolicy(c, &req)
+		if err != nil {
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": err.Error() + ": " + req.Model,
+			})
+		}
+
+		ctx := c.Context()
+		if alias != "" {
+			md, _ := simpleai.RequestMetadataFromContext(ctx)
+			md.Tags = maps.Clone(md.Tags)
+			if md.Tags == nil {
+				md.Tags = make(map[string]string)
+			}
+			md.Tags[modelAliasTag] = alias
+			ctx = simpleai.WithRequestMetadata(ctx, md)
+		}
+
+		// Trial clients are billed when the job finishes, after this
+		// request has ended
+		billing := c.Context()
+		job, err := runner.Submit(ctx, &simpleai.Request{
+			Messages:    req.Messages,
+			Model:       req.Model,
+			MaxTokens:   req.MaxTokens,
+			Temperature: req.Temperature,
+		}, jobs.WithOwner(cfg.caller(c)), jobs.WithOnDone(func(job jobs.Job) {
+			if job.Response != nil {
+				recordTokens(billing, job.Response.Usage.TotalTokens)
+			}
+		}))
+		if errors.Is(err, jobs.ErrQueueFull) {
+			c.SetResponseHeader("Retry-After", "30")
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		return c.JSON(http.StatusAccepted, jobResponse(job))
+	}
+}
+
+// JobHandler creates an HTTP handler that returns an asynchronous
+// completion's status and, once done, its result. The job ID is the last
+// path segment, e.g. GET /v1/completions/:id, or the id query parameter.
+// Only the caller that submitted a job can read it; others get 404, as for
+// unknown jobs, so pass the same WithCaller as to SubmitJobHandler.
+func JobHandler(runner *jobs.Runner, opts ...HandlerOption) simplehttp.HandlerFunc {
+	cfg := newHandlerConfig(opts)
+	return func(c simplehttp.Context) error {
+		cfg.setHeaders(c, false)
+		id := c.GetQueryParam("id")
+		if id == "" {
+			id = path.Base(c.GetPath())
+		}
+
+		job, err := runner.Get(c.Context(), id)
+		if err == nil && job.Owner != cfg.caller(c) {
+			err = jobs.ErrJobNotFound
+		}
+		if errors.Is(err, jobs.ErrJobNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		return c.JSON(http.StatusOK, jobResponse(job))
+	}
+}
+
+// jobResponse converts a job to its HTTP representation
+func jobResponse(job jobs.Job) JobResponse {
+	resp := JobResponse{
+		ID:         job.ID,
+		Status:     job.Status,
+		CreatedAt:  job.CreatedAt,
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+		Error:      job.Error,
+	}
+	if r := job.Response; r != nil {
+		model := r.Model
+		if alias := job.Metadata.Tags[modelAliasTag]; alias != "" {
+			model = alias
+		}
+		resp.Result = &ChatResponse{
+			ID:           r.ID,
+			Content:      r.Content,
+			Model:        model,
+			FinishReason: r.FinishReason,
+			Usage:        r.Usage,
+		}
+	}
+	return resp
+}
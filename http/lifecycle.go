@@ -0,0 +1,158 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrShuttingDown is returned when a request arrives after shutdown began
+var ErrShuttingDown = errors.New("simpleai: server is shutting down")
+
+// ShutdownEvent is the SSE event name sent to streams cut off by shutdown
+const ShutdownEvent = "shutdown"
+
+// Lifecycle coordinates graceful shutdown of the AI handlers.
+// Once Shutdown is called, new completions are rejected with 503, in-flight
+// streams get a grace period to finish, and any stream still running after
+// that receives a final "shutdown" SSE event and is closed.
+type Lifecycle struct {
+	mu       sync.Mutex
+	draining bool
+	active   int
+	idle     chan struct{} // closed when active drops to zero while draining
+	closing  chan struct{} // closed when the grace period expires
+}
+
+// NewLifecycle creates a new lifecycle tracker
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{
+		closing: make(chan struct{}),
+	}
+}
+
+// Begin registers an in-flight request. It returns false if the server is
+// shutting down and the request should be rejected.
+func (l *Lifecycle) Begin() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.draining {
+		return false
+	}
+	l.active++
+	return true
+}
+
+// End marks an in-flight request as finished
+func (l *Lifecycle) End() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	if l.draining && l.active == 0 && l.idle != nil {
+		close(l.idle)
+		l.idle = nil
+	}
+}
+
+// Active returns the number of in-flight requests
+func (l *Lifecycle) Active() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active
+}
+
+// Draining reports whether shutdown has begun
+func (l *Lifecycle) Draining() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.draining
+}
+
+// Closing returns a channel that is closed when in-flight streams must stop
+func (l *Lifecycle) Closing() <-chan struct{} {
+	return l.closing
+}
+
+// Shutdown stops accepting new requests and waits up to grace for in-flight
+// requests to finish. Streams still running after grace are told to close
+// and Shutdown waits for them until ctx is done.
+// Call it before shutting down the HTTP server itself.
+func (l *Lifecycle) Shutdown(ctx context.Context, grace time.Duration) error {
+	l.mu.Lock()
+	if l.draining {
+		l.mu.Unlock()
+		return nil
+	}
+	l.draining = true
+	idle := make(chan struct{})
+	if l.active == 0 {
+		close(idle)
+	} else {
+		l.idle = idle
+	}
+	l.mu.Unlock()
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+
+	select {
+	case <-idle:
+		close(l.closing)
+		return nil
+	case <-ctx.Done():
+		close(l.closing)
+		return ctx.Err()
+	case <-timer.C:
+	}
+
+	// Grace period expired: cut off remaining streams
+	close(l.closing)
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// track registers a request with the lifecycle, if any, and returns a release
+// function that is safe to call more than once
+func (cfg *HandlerConfig) track() (release func(), ok bool) {
+	if cfg.Lifecycle == nil {
+		return func() {}, true
+	}
+	if !cfg.Lifecycle.Begin() {
+		return nil, false
+	}
+	var once sync.Once
+	return func() { once.Do(cfg.Lifecycle.End) }, true
+}
+
+// closing returns the lifecycle's closing channel, or nil if none is configured
+func (cfg *HandlerConfig) closing() <-chan struct{} {
+	if cfg.Lifecycle == nil {
+		return nil
+	}
+	return cfg.Lifecycle.Closing()
+}
+
+// cancelOnClose returns a context for a stream's provider call that is
+// cancelled with ErrShuttingDown once the lifecycle's grace period expires,
+// so shutdown doesn't wait for tokens nobody will read. stop releases it.
+func (cfg *HandlerConfig) cancelOnClose(ctx context.Context) (context.Context, func()) {
+	closing := cfg.closing()
+	if closing == nil {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		select {
+		case <-closing:
+			cancel(ErrShuttingDown)
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() { cancel(nil) }
+}
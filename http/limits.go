@@ -0,0 +1,197 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simplehttp"
+)
+
+// Validation error codes, in the "code" field of error bodies
+const (
+	CodeBodyTooLarge    = "body_too_large"
+	CodeTooManyMessages = "too_many_messages"
+	CodeMessageTooLong  = "message_too_long"
+	CodeTooManyTokens   = "too_many_tokens"
+	CodeInvalidRequest  = "invalid_request"
+)
+
+// Limits bounds the size of requests the handlers accept, so abusive
+// payloads are rejected before they reach a provider. Zero fields are
+// unlimited.
+type Limits struct {
+	// MaxBodyBytes rejects requests whose Content-Length is larger before
+	// the body is decoded, and stops reading bodies that turn out larger
+	MaxBodyBytes int64
+
+	MaxMessages      int // Messages per request
+	MaxMessageLength int // Characters per message, counting its documents
+	MaxTotalTokens   int // Estimated input tokens per request
+
+	// TokenCounter estimates tokens for MaxTotalTokens (default: ~4
+	// characters per token)
+	TokenCounter func(string) int
+}
+
+// DefaultLimits returns the limits handlers apply without WithLimits
+func DefaultLimits() Limits {
+	return Limits{
+		MaxBodyBytes:     4 << 20,
+		MaxMessages:      500,
+		MaxMessageLength: 200000,
+	}
+}
+
+// ValidationResponse is the body of 413 and 422 responses
+type ValidationResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+	Index *int   `json:"index,omitempty"` // Offending message
+	Limit int64  `json:"limit,omitempty"`
+	Size  int64  `json:"size,omitempty"` // The request's value for the exceeded limit
+}
+
+// WithLimits replaces the default request limits; pass Limits{} to disable
+// them
+func WithLimits(limits Limits) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.Limits = &limits
+	}
+}
+
+// limits returns the configured or default limits
+func (cfg *HandlerConfig) limits() Limits {
+	if cfg.Limits != nil {
+		return *cfg.Limits
+	}
+	return DefaultLimits()
+}
+
+// checkBody rejects a request whose declared body is too large and caps
+// how much of the body can be read, since Content-Length may be missing or
+// wrong
+func (cfg *HandlerConfig) checkBody(c simplehttp.Context) *ValidationResponse {
+	limit := cfg.limits().MaxBodyBytes
+	if limit <= 0 {
+		return nil
+	}
+	size, err := strconv.ParseInt(c.GetHeader("Content-Length"), 10, 64)
+	if err == nil && size > limit {
+		return &ValidationResponse{
+			Error: fmt.Sprintf("request body of %d bytes exceeds the limit of %d", size, limit),
+			Code:  CodeBodyTooLarge,
+			Limit: limit,
+			Size:  size,
+		}
+	}
+	if r := c.Request(); r != nil && r.Body != nil {
+		r.Body = http.MaxBytesReader(c.Response(), r.Body, limit)
+	}
+	return nil
+}
+
+// bodyTooLarge reports a bind error caused by reading past MaxBodyBytes
+func bodyTooLarge(err error) *ValidationResponse {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return nil
+	}
+	return &ValidationResponse{
+		Error: fmt.Sprintf("request body exceeds the limit of %d bytes", tooLarge.Limit),
+		Code:  CodeBodyTooLarge,
+		Limit: tooLarge.Limit,
+	}
+}
+
+// validateChat checks a completion request's structure and size
+func (cfg *HandlerConfig) validateChat(req *ChatRequest) *ValidationResponse {
+	if len(req.Messages) == 0 {
+		return invalid("messages are required", nil)
+	}
+	if req.MaxTokens < 0 {
+		return invalid("max_tokens must not be negative", nil)
+	}
+	if req.Temperature < 0 || req.Temperature > 2 {
+		return invalid("temperature must be between 0 and 2", nil)
+	}
+	for i, msg := range req.Messages {
+		switch msg.Role {
+		case simpleai.RoleSystem, simpleai.RoleUser, simpleai.RoleAssistant, simpleai.RoleTool:
+		default:
+			return invalid(fmt.Sprintf("message %d has an invalid role %q", i, msg.Role), &i)
+		}
+	}
+	return cfg.checkMessages(req.Messages)
+}
+
+// validateMessage checks a single chat message's size
+func (cfg *HandlerConfig) validateMessage(message string) *ValidationResponse {
+	if message == "" {
+		return invalid("message is required", nil)
+	}
+	return cfg.checkMessages([]simpleai.Message{{Role: simpleai.RoleUser, Content: message}})
+}
+
+// checkMessages applies the message count, length and token limits
+func (cfg *HandlerConfig) checkMessages(messages []simpleai.Message) *ValidationResponse {
+	limits := cfg.limits()
+	if limits.MaxMessages > 0 && len(messages) > limits.MaxMessages {
+		return &ValidationResponse{
+			Error: fmt.Sprintf("%d messages exceed the limit of %d", len(messages), limits.MaxMessages),
+			Code:  CodeTooManyMessages,
+			Limit: int64(limits.MaxMessages),
+			Size:  int64(len(messages)),
+		}
+	}
+
+	count := limits.TokenCounter
+	if count == nil {
+		count = func(s string) int { return (len(s) + 3) / 4 }
+	}
+	tokens := 0
+	for i, msg := range messages {
+		text := msg.Content
+		for _, doc := range msg.Documents {
+			text += doc.Title + doc.Context + doc.Content
+		}
+		if length := utf8.RuneCountInString(text); limits.MaxMessageLength > 0 && length > limits.MaxMessageLength {
+			return &ValidationResponse{
+				Error: fmt.Sprintf("message %d has %d characters, over the limit of %d", i, length, limits.MaxMessageLength),
+				Code:  CodeMessageTooLong,
+				Index: &i,
+				Limit: int64(limits.MaxMessageLength),
+				Size:  int64(length),
+			}
+		}
+		if limits.MaxTotalTokens > 0 {
+			tokens += count(text)
+		}
+	}
+	if limits.MaxTotalTokens > 0 && tokens > limits.MaxTotalTokens {
+		return &ValidationResponse{
+			Error: fmt.Sprintf("about %d input tokens exceed the limit of %d", tokens, limits.MaxTotalTokens),
+			Code:  CodeTooManyTokens,
+			Limit: int64(limits.MaxTotalTokens),
+			Size:  int64(tokens),
+		}
+	}
+	return nil
+}
+
+func invalid(message string, index *int) *ValidationResponse {
+	return &ValidationResponse{Error: message, Code: CodeInvalidRequest, Index: index}
+}
+
+// rejectRequest responds to a failed validation: 422 for malformed requests
+// and 413 for exceeded limits
+func rejectRequest(c simplehttp.Context, v *ValidationResponse) error {
+	status := http.StatusRequestEntityTooLarge
+	if v.Code == CodeInvalidRequest {
+		status = http.StatusUnprocessableEntity
+	}
+	return c.JSON(status, v)
+}
@@ -0,0 +1,87 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/medatechnology/simpleai/moderation"
+	"github.com/medatechnology/simplehttp"
+)
+
+// ModerationRequest is a moderation audit query
+type ModerationRequest struct {
+	moderation.Filter
+	// BySession summarizes flagged entries per conversation instead of
+	// listing entries
+	BySession bool `json:"by_session,omitempty"`
+}
+
+// ModerationResponse holds audit entries or flagged sessions
+type ModerationResponse struct {
+	Entries  []moderation.Entry   `json:"entries,omitempty"`
+	Sessions []moderation.Session `json:"sessions,omitempty"`
+}
+
+// ReviewRequest records a reviewer's decision on an audit entry
+type ReviewRequest struct {
+	ID string `json:"id"`
+	moderation.Review
+}
+
+// ModerationHandler creates an HTTP handler that answers moderation audit
+// queries, e.g. {"flagged_only": true, "unreviewed": true, "by_session": true}
+// for a review queue. Protect it like any admin endpoint.
+func ModerationHandler(auditor *moderation.Auditor, opts ...HandlerOption) simplehttp.HandlerFunc {
+	cfg := newHandlerConfig(opts)
+	return func(c simplehttp.Context) error {
+		cfg.setHeaders(c, false)
+		var req ModerationRequest
+		if err := c.BindJSON(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid request: " + err.Error(),
+			})
+		}
+
+		var resp ModerationResponse
+		var err error
+		if req.BySession {
+			resp.Sessions, err = auditor.FlaggedSessions(c.Context(), req.Filter)
+		} else {
+			resp.Entries, err = auditor.Entries(c.Context(), req.Filter)
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+// ModerationReviewHandler creates an HTTP handler that records review
+// decisions on audit entries
+func ModerationReviewHandler(auditor *moderation.Auditor, opts ...HandlerOption) simplehttp.HandlerFunc {
+	cfg := newHandlerConfig(opts)
+	return func(c simplehttp.Context) error {
+		cfg.setHeaders(c, false)
+		var req ReviewRequest
+		if err := c.BindJSON(&req); err != nil || req.ID == "" || req.Status == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "id and status are required",
+			})
+		}
+
+		err := auditor.Review(c.Context(), req.ID, req.Review)
+		if errors.Is(err, moderation.ErrEntryNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+	}
+}
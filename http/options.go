@@ -0,0 +1,107 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/medatechnology/simplehttp"
+)
+
+// HandlerConfig holds optional configuration shared by the handlers
+type HandlerConfig struct {
+	// Lifecycle tracks in-flight requests for graceful shutdown
+	Lifecycle *Lifecycle
+
+	// Requests tracks in-flight streams so CancelHandler can stop them
+	Requests *Requests
+
+	// LegacyEvents streams every chunk as an unnamed StreamChunk instead of
+	// typed delta, usage and done events
+	LegacyEvents bool
+
+	// AdminToken is the bearer token admin handlers require; without it
+	// they reject every request
+	AdminToken string
+
+	// ModelPolicy restricts the models and max_tokens clients may request
+	ModelPolicy *ModelPolicy
+
+	// Limits bounds request sizes (default DefaultLimits)
+	Limits *Limits
+
+	// Headers are the response headers, e.g. CORS (default
+	// DefaultHeaderConfig)
+	Headers *HeaderConfig
+
+	// Caller identifies the client of a request, e.g. a user ID from a
+	// session. Idempotency keys are scoped to it, so one client can't read
+	// another's responses by reusing its key. Default: a hash of the API key
+	// in the Authorization or X-API-Key header, else the client's IP.
+	Caller func(c simplehttp.Context) string
+}
+
+// HandlerOption is a functional option for configuring handlers
+type HandlerOption func(*HandlerConfig)
+
+// WithLifecycle registers handlers with a Lifecycle so they stop accepting
+// new requests and drain in-flight streams on shutdown
+func WithLifecycle(l *Lifecycle) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.Lifecycle = l
+	}
+}
+
+// WithRequests registers streams with a Requests registry so they can be
+// stopped through CancelHandler
+func WithRequests(r *Requests) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.Requests = r
+	}
+}
+
+// WithLegacyEvents keeps the original stream format for existing clients:
+// one unnamed StreamChunk per event, with "done" set on the last
+func WithLegacyEvents() HandlerOption {
+	return func(c *HandlerConfig) {
+		c.LegacyEvents = true
+	}
+}
+
+// WithAdminToken sets the bearer token admin handlers require in the
+// Authorization header
+func WithAdminToken(token string) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.AdminToken = token
+	}
+}
+
+// WithCaller sets how handlers identify the client of a request
+func WithCaller(caller func(c simplehttp.Context) string) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.Caller = caller
+	}
+}
+
+// caller returns the identity of the request's client
+func (cfg *HandlerConfig) caller(c simplehttp.Context) string {
+	if cfg.Caller != nil {
+		return cfg.Caller(c)
+	}
+	if key := requestAPIKey(c); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:16])
+	}
+	if client, ok := c.Context().Value(trialKey{}).(*trialClient); ok {
+		return "ip:" + client.key
+	}
+	return "ip:" + remoteIP(c)
+}
+
+// newHandlerConfig applies options to an empty config
+func newHandlerConfig(opts []HandlerOption) *HandlerConfig {
+	cfg := &HandlerConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
@@ -0,0 +1,107 @@
+package http
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/medatechnology/simplehttp"
+)
+
+// ErrModelNotAllowed is returned when a client requests a model the policy
+// doesn't allow
+var ErrModelNotAllowed = errors.New("simpleai: model not allowed")
+
+// ModelPolicy controls which models clients of the completion handlers may
+// request and how many tokens they may ask for, see WithModelPolicy
+type ModelPolicy struct {
+	// Allowed lists the internal models clients may request by name; empty
+	// allows any model unless Aliases is set
+	Allowed []string
+
+	// Aliases maps public model names to internal ones, e.g. "fast" to
+	// "mistral-small-latest". Aliases are always allowed, and responses
+	// report the alias instead of the internal model.
+	Aliases map[string]string
+
+	// Default is the model of requests that name none (default: the
+	// client's default model)
+	Default string
+
+	// MaxTokens caps max_tokens per caller role; the "" entry caps callers
+	// whose role has none. Requests above the cap, or without max_tokens,
+	// get the cap.
+	MaxTokens map[string]int
+
+	// Role returns the caller's role, e.g. from an auth header or API key
+	Role func(c simplehttp.Context) string
+}
+
+// Resolve returns the internal model for a requested model name, or
+// ErrModelNotAllowed
+func (p *ModelPolicy) Resolve(model string) (string, error) {
+	if model == "" {
+		model = p.Default
+	}
+	if internal, ok := p.Aliases[model]; ok {
+		return internal, nil
+	}
+	if model == "" || slices.Contains(p.Allowed, model) {
+		return model, nil
+	}
+	if len(p.Allowed) == 0 && len(p.Aliases) == 0 {
+		return model, nil
+	}
+	return "", ErrModelNotAllowed
+}
+
+// maxTokens returns the max_tokens cap of the caller (0 = none)
+func (p *ModelPolicy) maxTokens(c simplehttp.Context) int {
+	role := ""
+	if p.Role != nil {
+		role = p.Role(c)
+	}
+	if limit, ok := p.MaxTokens[role]; ok {
+		return limit
+	}
+	return p.MaxTokens[""]
+}
+
+// apply enforces the policy on a request in place. It returns the name
+// responses should report for the model, "" to report the provider's.
+func (p *ModelPolicy) apply(c simplehttp.Context, req *ChatRequest) (string, error) {
+	requested := req.Model
+	if requested == "" {
+		requested = p.Default
+	}
+	model, err := p.Resolve(req.Model)
+	if err != nil {
+		return "", err
+	}
+	req.Model = model
+
+	if limit := p.maxTokens(c); limit > 0 && (req.MaxTokens <= 0 || req.MaxTokens > limit) {
+		req.MaxTokens = limit
+	}
+
+	if _, ok := p.Aliases[requested]; ok {
+		return requested, nil
+	}
+	return "", nil
+}
+
+// WithModelPolicy enforces a model allowlist, aliases and max_tokens caps
+// on the completion handlers, instead of passing the client's model and
+// limits straight to the provider
+func WithModelPolicy(policy *ModelPolicy) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.ModelPolicy = policy
+	}
+}
+
+// applyPolicy enforces the configured model policy, if any
+func (cfg *HandlerConfig) applyPolicy(c simplehttp.Context, req *ChatRequest) (string, error) {
+	if cfg.ModelPolicy == nil {
+		return "", nil
+	}
+	return cfg.ModelPolicy.apply(c, req)
+}
@@ -0,0 +1,344 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/bot"
+	"github.com/medatechnology/simplehttp"
+)
+
+// ErrSessionNotFound is returned for a session ID that is neither active
+// nor stored
+var ErrSessionNotFound = errors.New("simpleai: session not found")
+
+// SessionsConfig holds configuration for a session registry
+type SessionsConfig struct {
+	Client      *simpleai.Client
+	ChatOptions []simpleai.ChatOption // Applied to every new session (system prompt, limits, autocompact)
+
+	// Store persists session history so sessions survive restarts
+	// (optional)
+	Store bot.ChatStore
+
+	// IdleTimeout unloads sessions inactive for longer (default 1h), and
+	// MaxActive caps how many are loaded at once, unloading the least
+	// recently active first (default 10000). Unloaded sessions are restored
+	// from Store on their next message; without a Store, they start over.
+	IdleTimeout time.Duration
+	MaxActive   int
+}
+
+// Sessions holds one chat per session ID for SessionChatHandler, so one
+// server can serve many conversations. Sessions are created on first use,
+// or restored from the store.
+type Sessions struct {
+	config SessionsConfig
+	mu     sync.Mutex
+	active map[string]*chatSession
+}
+
+type chatSession struct {
+	mu         sync.Mutex // Serializes turns within a session
+	chat       *simpleai.Chat
+	createdAt  time.Time
+	lastActive time.Time
+	turns      int // In-flight turns, guarded by the registry lock; busy sessions are never unloaded
+}
+
+// SessionInfo describes an active session
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	Messages   int       `json:"messages"`
+	Compacted  bool      `json:"compacted"`           // Earlier history was summarized
+	CreatedAt  time.Time `json:"created_at,omitzero"` // When the session was loaded; zero if only stored
+	LastActive time.Time `json:"last_active,omitzero"`
+}
+
+// SessionDetail is a session's full state
+type SessionDetail struct {
+	SessionInfo
+	Active  bool               `json:"active"` // Loaded in memory, as opposed to only stored
+	System  string             `json:"system,omitempty"`
+	Summary string             `json:"summary,omitempty"`
+	History []simpleai.Message `json:"history"`
+}
+
+// NewSessions creates a session registry
+func NewSessions(config SessionsConfig) *Sessions {
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = time.Hour
+	}
+	if config.MaxActive <= 0 {
+		config.MaxActive = 10000
+	}
+	return &Sessions{
+		config: config,
+		active: make(map[string]*chatSession),
+	}
+}
+
+// Chat returns the chat of a session, creating or restoring it
+func (s *Sessions) Chat(ctx context.Context, id string) (*simpleai.Chat, error) {
+	session, err := s.session(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	return session.chat, nil
+}
+
+// session returns the active session for id, restoring it from the store.
+// A turn keeps the session loaded until endTurn.
+func (s *Sessions) session(ctx context.Context, id string, turn bool) (*chatSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if session, ok := s.active[id]; ok {
+		session.lastActive = now
+		if turn {
+			session.turns++
+		}
+		return session, nil
+	}
+
+	opts := append([]simpleai.ChatOption(nil), s.config.ChatOptions...)
+	if s.config.Store != nil {
+		history, err := s.config.Store.Load(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("simpleai: load session %s: %w", id, err)
+		}
+		if len(history) > 0 {
+			opts = append(opts, simpleai.WithMessages(history))
+		}
+	}
+	session := &chatSession{
+		chat:       s.config.Client.NewChat(opts...),
+		createdAt:  now,
+		lastActive: now,
+	}
+	if turn {
+		session.turns++
+	}
+	s.evict(now)
+	s.active[id] = session
+	return session, nil
+}
+
+// endTurn lets a session be unloaded again once idle
+func (s *Sessions) endTurn(session *chatSession) {
+	s.mu.Lock()
+	session.turns--
+	session.lastActive = time.Now()
+	s.mu.Unlock()
+}
+
+// evict unloads idle sessions, then the least recently active ones until
+// there is room for one more; the registry lock must be held
+func (s *Sessions) evict(now time.Time) {
+	var idle []string
+	for id, session := range s.active {
+		if session.turns > 0 {
+			continue
+		}
+		if now.Sub(session.lastActive) > s.config.IdleTimeout {
+			delete(s.active, id)
+			continue
+		}
+		idle = append(idle, id)
+	}
+	if len(s.active) < s.config.MaxActive {
+		return
+	}
+	sort.Slice(idle, func(i, j int) bool {
+		return s.active[idle[i]].lastActive.Before(s.active[idle[j]].lastActive)
+	})
+	for _, id := range idle {
+		if len(s.active) < s.config.MaxActive {
+			return
+		}
+		delete(s.active, id)
+	}
+}
+
+// save persists a session's history when a store is configured
+func (s *Sessions) save(ctx context.Context, id string, chat *simpleai.Chat) error {
+	if s.config.Store == nil {
+		return nil
+	}
+	return s.config.Store.Save(ctx, id, chat.History())
+}
+
+// List returns the active sessions, most recently active first
+func (s *Sessions) List() []SessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	infos := make([]SessionInfo, 0, len(s.active))
+	for id, session := range s.active {
+		infos = append(infos, session.info(id))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].LastActive.After(infos[j].LastActive)
+	})
+	return infos
+}
+
+// Inspect returns a session's history and summary without activating it
+func (s *Sessions) Inspect(ctx context.Context, id string) (*SessionDetail, error) {
+	s.mu.Lock()
+	session, ok := s.active[id]
+	var info SessionInfo
+	if ok {
+		info = session.info(id)
+	}
+	s.mu.Unlock()
+	if ok {
+		return &SessionDetail{
+			SessionInfo: info,
+			Active:      true,
+			System:      session.chat.System(),
+			Summary:     session.chat.Summary(),
+			History:     session.chat.History(),
+		}, nil
+	}
+
+	if s.config.Store == nil {
+		return nil, ErrSessionNotFound
+	}
+	history, err := s.config.Store.Load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		return nil, ErrSessionNotFound
+	}
+	return &SessionDetail{
+		SessionInfo: SessionInfo{ID: id, Messages: len(history)},
+		History:     history,
+	}, nil
+}
+
+// Delete ends a session and deletes its stored history
+func (s *Sessions) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	_, ok := s.active[id]
+	delete(s.active, id)
+	s.mu.Unlock()
+
+	if s.config.Store == nil {
+		if !ok {
+			return ErrSessionNotFound
+		}
+		return nil
+	}
+	if !ok {
+		history, err := s.config.Store.Load(ctx, id)
+		if err != nil {
+			return err
+		}
+		if len(history) == 0 {
+			return ErrSessionNotFound
+		}
+	}
+	return s.config.Store.Delete(ctx, id)
+}
+
+// info describes the session; the registry lock must be held
+func (cs *chatSession) info(id string) SessionInfo {
+	return SessionInfo{
+		ID:         id,
+		Messages:   len(cs.chat.History()),
+		Compacted:  cs.chat.Summary() != "",
+		CreatedAt:  cs.createdAt,
+		LastActive: cs.lastActive,
+	}
+}
+
+// SessionChatRequest is a message to a session
+type SessionChatRequest struct {
+	SessionID string `json:"session_id"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// SessionChatHandler creates an HTTP handler for streaming chat sessions
+// keyed by session_id. History is saved to the sessions' store once the
+// reply is complete.
+func SessionChatHandler(sessions *Sessions, opts ...HandlerOption) simplehttp.HandlerFunc {
+	cfg := newHandlerConfig(opts)
+	return func(c simplehttp.Context) error {
+		cfg.setHeaders(c, true)
+		release, ok := cfg.track()
+		if !ok {
+			return shuttingDown(c)
+		}
+
+		if v := cfg.checkBody(c); v != nil {
+			release()
+			return rejectRequest(c, v)
+		}
+		var req SessionChatRequest
+		if err := c.BindJSON(&req); err != nil || req.SessionID == "" {
+			release()
+			if v := bodyTooLarge(err); v != nil {
+				return rejectRequest(c, v)
+			}
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "session_id and message are required",
+			})
+		}
+		if v := cfg.validateMessage(req.Message); v != nil {
+			release()
+			return rejectRequest(c, v)
+		}
+
+		session, err := sessions.session(c.Context(), req.SessionID, true)
+		if err != nil {
+			release()
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		ctx, requestID, done, err := cfg.register(c, req.RequestID)
+		if err != nil {
+			sessions.endTurn(session)
+			release()
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		session.mu.Lock()
+		events, err := session.chat.Stream(ctx, req.Message)
+		if err != nil {
+			session.mu.Unlock()
+			sessions.endTurn(session)
+			done()
+			release()
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		return c.SSE(func(w simplehttp.SSEWriter) error {
+			defer release()
+			defer done()
+			err := writeEvents(ctx, w, requestID, events, cfg)
+			// The chat records the reply once its stream is drained; the
+			// session's next turn waits for that
+			drainEvents(events)
+			session.mu.Unlock()
+			if saveErr := sessions.save(context.WithoutCancel(ctx), req.SessionID, session.chat); err == nil {
+				err = saveErr
+			}
+			sessions.endTurn(session)
+			return err
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/simplehttp"
+)
+
+// CodeTrialExhausted is the error code of requests over the trial budget
+const CodeTrialExhausted = "trial_exhausted"
+
+// Trial response headers, sent on anonymous requests
+const (
+	TrialRequestsHeader = "X-Trial-Remaining-Requests"
+	TrialTokensHeader   = "X-Trial-Remaining-Tokens"
+)
+
+// TrialUsage is a client's usage in the current trial window
+type TrialUsage struct {
+	Requests int       `json:"requests"`
+	Tokens   int       `json:"tokens"`
+	Reset    time.Time `json:"reset"` // When the window ends
+}
+
+// TrialStore counts trial usage per client. Implement it on Redis (INCRBY
+// with an expiry) to share budgets across instances.
+type TrialStore interface {
+	// Add adds requests and tokens to key's usage, opening a window that
+	// ends after window if none is open, and returns the new totals
+	Add(ctx context.Context, key string, requests, tokens int, window time.Duration) (TrialUsage, error)
+}
+
+// TrialConfig holds configuration for anonymous trial access
+type TrialConfig struct {
+	// Store counts usage per client (default: in memory)
+	Store TrialStore
+
+	MaxRequests int           // Requests per client per window (default 20)
+	MaxTokens   int           // Tokens per client per window, as reported by the handlers (default 20000)
+	Window      time.Duration // Default 24h
+
+	// Authenticate reports whether the request carries a valid API key.
+	// Authenticated requests skip the trial; nil treats every request as
+	// anonymous. See APIKeyAuth.
+	Authenticate func(c simplehttp.Context) bool
+
+	// ClientIP identifies anonymous clients (default: the connection's
+	// address). Behind a proxy, read the header it sets instead, e.g.
+	// CF-Connecting-IP; headers sent by clients directly can be forged.
+	ClientIP func(c simplehttp.Context) string
+}
+
+// DefaultTrialConfig returns the default trial configuration
+func DefaultTrialConfig() TrialConfig {
+	return TrialConfig{
+		Store:       NewMemoryTrialStore(),
+		MaxRequests: 20,
+		MaxTokens:   20000,
+		Window:      24 * time.Hour,
+	}
+}
+
+// Trial lets unauthenticated clients try the handlers on a small per-IP
+// budget of requests and tokens before requiring an API key
+type Trial struct {
+	config TrialConfig
+}
+
+// NewTrial creates a trial with the given configuration; zero fields use
+// DefaultTrialConfig
+func NewTrial(config TrialConfig) *Trial {
+	defaults := DefaultTrialConfig()
+	if config.Store == nil {
+		config.Store = defaults.Store
+	}
+	if config.MaxRequests <= 0 {
+		config.MaxRequests = defaults.MaxRequests
+	}
+	if config.MaxTokens <= 0 {
+		config.MaxTokens = defaults.MaxTokens
+	}
+	if config.Window <= 0 {
+		config.Window = defaults.Window
+	}
+	if config.ClientIP == nil {
+		config.ClientIP = remoteIP
+	}
+	return &Trial{config: config}
+}
+
+// NewTrialSimple creates a trial accepting the given API keys, with the
+// default budget
+func NewTrialSimple(apiKeys ...string) *Trial {
+	return NewTrial(TrialConfig{Authenticate: APIKeyAuth(apiKeys...)})
+}
+
+// Middleware returns the simplehttp middleware enforcing the trial. Each
+// anonymous request counts against the client's budget; once it is spent
+// the client gets a 401 until the window resets or it sends an API key.
+// Tokens are counted from the usage the handlers of this package report.
+func (t *Trial) Middleware() simplehttp.Middleware {
+	return simplehttp.WithName("simpleai trial", func(next simplehttp.HandlerFunc) simplehttp.HandlerFunc {
+		return func(c simplehttp.Context) error {
+			if t.config.Authenticate != nil && t.config.Authenticate(c) {
+				return next(c)
+			}
+
+			key := t.config.ClientIP(c)
+			usage, err := t.config.Store.Add(c.Context(), key, 1, 0, t.config.Window)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{
+					"error": err.Error(),
+				})
+			}
+			t.setHeaders(c, usage)
+			if usage.Requests > t.config.MaxRequests || usage.Tokens >= t.config.MaxTokens {
+				c.SetResponseHeader("WWW-Authenticate", "Bearer")
+				c.SetResponseHeader("Retry-After", strconv.Itoa(int(time.Until(usage.Reset).Seconds())+1))
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"error": "trial limit reached, an API key is required",
+					"code":  CodeTrialExhausted,
+				})
+			}
+
+			c.SetContext(context.WithValue(c.Context(), trialKey{}, &trialClient{trial: t, key: key}))
+			return next(c)
+		}
+	})
+}
+
+// Usage returns an anonymous client's usage in the current window
+func (t *Trial) Usage(ctx context.Context, ip string) (TrialUsage, error) {
+	return t.config.Store.Add(ctx, ip, 0, 0, t.config.Window)
+}
+
+// setHeaders reports the remaining budget
+func (t *Trial) setHeaders(c simplehttp.Context, usage TrialUsage) {
+	c.SetResponseHeader(TrialRequestsHeader, strconv.Itoa(max(t.config.MaxRequests-usage.Requests, 0)))
+	c.SetResponseHeader(TrialTokensHeader, strconv.Itoa(max(t.config.MaxTokens-usage.Tokens, 0)))
+}
+
+type trialKey struct{}
+
+// trialClient is the anonymous client of a request
+type trialClient struct {
+	trial *Trial
+	key   string
+}
+
+// recordTokens counts tokens against the trial budget of the request's
+// client, if it is anonymous
+func recordTokens(ctx context.Context, tokens int) {
+	client, ok := ctx.Value(trialKey{}).(*trialClient)
+	if !ok || tokens <= 0 {
+		return
+	}
+	t := client.trial
+	// The response is already on its way, so a store failure only loses
+	// the count
+	t.config.Store.Add(context.WithoutCancel(ctx), client.key, 0, tokens, t.config.Window)
+}
+
+// APIKeyAuth returns an Authenticate function accepting the given keys as
+// a bearer token or in the X-API-Key header
+func APIKeyAuth(keys ...string) func(c simplehttp.Context) bool {
+	return func(c simplehttp.Context) bool {
+		given := requestAPIKey(c)
+		if given == "" {
+			return false
+		}
+		for _, key := range keys {
+			if subtle.ConstantTimeCompare([]byte(given), []byte(key)) == 1 {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// requestAPIKey returns the bearer token or X-API-Key header of a request
+func requestAPIKey(c simplehttp.Context) string {
+	if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return c.GetHeader("X-API-Key")
+}
+
+// remoteIP returns the connection's address without its port
+func remoteIP(c simplehttp.Context) string {
+	addr := c.GetHeaders().RemoteIP
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// MemoryTrialStore counts trial usage in memory
+type MemoryTrialStore struct {
+	mu     sync.Mutex
+	usage  map[string]TrialUsage
+	pruned time.Time
+}
+
+// NewMemoryTrialStore creates an in-memory trial store
+func NewMemoryTrialStore() *MemoryTrialStore {
+	return &MemoryTrialStore{usage: make(map[string]TrialUsage)}
+}
+
+// Add updates key's usage, starting a new window once the last one ended
+func (m *MemoryTrialStore) Add(ctx context.Context, key string, requests, tokens int, window time.Duration) (TrialUsage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.pruned) > time.Minute {
+		for k, u := range m.usage {
+			if now.After(u.Reset) {
+				delete(m.usage, k)
+			}
+		}
+		m.pruned = now
+	}
+
+	usage, ok := m.usage[key]
+	if !ok || now.After(usage.Reset) {
+		usage = TrialUsage{Reset: now.Add(window)}
+	}
+	usage.Requests += requests
+	usage.Tokens += tokens
+	m.usage[key] = usage
+	return usage, nil
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/medatechnology/simpleai/usage"
+	"github.com/medatechnology/simplehttp"
+)
+
+// UsageRequest is a usage ledger query
+type UsageRequest struct {
+	usage.Filter
+	GroupBy []usage.Dimension `json:"group_by,omitempty"`
+	Window  string            `json:"window,omitempty"` // e.g. "24h"; empty for one bucket
+}
+
+// UsageResponse holds the aggregated usage
+type UsageResponse struct {
+	Totals []usage.Total `json:"totals"`
+}
+
+// UsageHandler creates an HTTP handler that answers usage ledger queries.
+// Protect it like any billing endpoint.
+func UsageHandler(ledger *usage.Ledger, opts ...HandlerOption) simplehttp.HandlerFunc {
+	cfg := newHandlerConfig(opts)
+	return func(c simplehttp.Context) error {
+		cfg.setHeaders(c, false)
+		var req UsageRequest
+		if err := c.BindJSON(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid request: " + err.Error(),
+			})
+		}
+
+		q := usage.Query{Filter: req.Filter, GroupBy: req.GroupBy}
+		if req.Window != "" {
+			window, err := time.ParseDuration(req.Window)
+			if err != nil || window <= 0 {
+				return c.JSON(http.StatusBadRequest, map[string]string{
+					"error": "invalid window: " + req.Window,
+				})
+			}
+			q.Window = window
+		}
+
+		totals, err := ledger.Query(c.Context(), q)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		if totals == nil {
+			totals = []usage.Total{}
+		}
+		return c.JSON(http.StatusOK, UsageResponse{Totals: totals})
+	}
+}
@@ -0,0 +1,211 @@
+package simpleai
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Idempotency errors
+var (
+	ErrIdempotencyKeyReused = errors.New("simpleai: idempotency key reused with a different request")
+	ErrIdempotencyNotFound  = errors.New("simpleai: idempotency key not found")
+)
+
+// IdempotencyConfig holds configuration for idempotent completions, see
+// WithIdempotency
+type IdempotencyConfig struct {
+	// Store keeps the responses of completed keys (default: in memory)
+	Store IdempotencyStore
+
+	// Window is how long a key returns its original response (default 24h)
+	Window time.Duration
+
+	// OnSaveError is called when a response can't be stored. The response
+	// is still returned, but a retry of its key calls the provider again.
+	OnSaveError func(key string, err error)
+}
+
+// DefaultIdempotencyConfig returns the default idempotency configuration
+func DefaultIdempotencyConfig() IdempotencyConfig {
+	return IdempotencyConfig{
+		Store:  NewMemoryIdempotencyStore(),
+		Window: 24 * time.Hour,
+	}
+}
+
+// IdempotencyRecord is the stored outcome of a completion with an
+// idempotency key
+type IdempotencyRecord struct {
+	Key         string    `json:"key"`
+	RequestHash string    `json:"request_hash"` // Detects a key reused for another request
+	Response    Response  `json:"response"`
+	Time        time.Time `json:"time"`
+	Expires     time.Time `json:"expires"`
+}
+
+// IdempotencyStore persists the responses of idempotent completions
+type IdempotencyStore interface {
+	// Get returns ErrIdempotencyNotFound for unknown or expired keys
+	Get(ctx context.Context, key string) (IdempotencyRecord, error)
+	Save(ctx context.Context, record IdempotencyRecord) error
+}
+
+type idempotencyKey struct{}
+
+// ContextWithIdempotencyKey returns a context whose completions use key as
+// their idempotency key
+func ContextWithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// IdempotencyKeyFromContext returns the idempotency key stored in ctx, or ""
+func IdempotencyKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	return key
+}
+
+// subIdempotencyKey derives a key for one of several calls made for a keyed
+// request, e.g. each sample of CompleteConsistent, so a retry replays every
+// call instead of collapsing them into one
+func subIdempotencyKey(ctx context.Context, step string) context.Context {
+	if key := IdempotencyKeyFromContext(ctx); key != "" {
+		return ContextWithIdempotencyKey(ctx, key+"/"+step)
+	}
+	return ctx
+}
+
+// idempotentCall is a completion shared by concurrent retries of one key
+type idempotentCall struct {
+	hash string
+	done chan struct{}
+	resp *Response
+	err  error
+}
+
+// completeIdempotent returns the stored response for key, or runs the
+// completion once and stores its response. Retries that arrive while the
+// first call is in flight wait for its result. Failed calls are not stored,
+// so they can be retried; neither are responses the store fails to save.
+func (c *Client) completeIdempotent(ctx context.Context, config *IdempotencyConfig, key string, req *Request, run func(context.Context) (*Response, error)) (*Response, error) {
+	hash := hashRequest(req)
+
+	record, err := config.Store.Get(ctx, key)
+	if err == nil {
+		if record.RequestHash != hash {
+			return nil, ErrIdempotencyKeyReused
+		}
+		return record.Response.Clone(), nil
+	}
+	if !errors.Is(err, ErrIdempotencyNotFound) {
+		return nil, err
+	}
+
+	c.idempotencyMu.Lock()
+	if c.idempotent == nil {
+		c.idempotent = make(map[string]*idempotentCall)
+	}
+	call, shared := c.idempotent[key]
+	if shared && call.hash != hash {
+		c.idempotencyMu.Unlock()
+		return nil, ErrIdempotencyKeyReused
+	}
+	if !shared {
+		call = &idempotentCall{hash: hash, done: make(chan struct{})}
+		c.idempotent[key] = call
+		go func() {
+			// Detached so one caller leaving does not fail the retries
+			ctx := context.WithoutCancel(ctx)
+			call.resp, call.err = run(ctx)
+			if call.err == nil {
+				// The provider call succeeded and is billed; failing to store
+				// it must not lose the response
+				now := time.Now()
+				err := config.Store.Save(ctx, IdempotencyRecord{
+					Key:         key,
+					RequestHash: hash,
+					Response:    *call.resp.Clone(),
+					Time:        now,
+					Expires:     now.Add(config.Window),
+				})
+				if err != nil && config.OnSaveError != nil {
+					config.OnSaveError(key, err)
+				}
+			}
+
+			c.idempotencyMu.Lock()
+			delete(c.idempotent, key)
+			c.idempotencyMu.Unlock()
+			close(call.done)
+		}()
+	}
+	c.idempotencyMu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-call.done:
+	}
+	if call.err != nil {
+		return nil, call.err
+	}
+	return call.resp.Clone(), nil
+}
+
+// hashRequest returns a stable hash of the request's messages, model and
+// generation parameters
+func hashRequest(req *Request) string {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func newResponseID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "resp_" + hex.EncodeToString(b)
+}
+
+// MemoryIdempotencyStore keeps idempotency records in memory
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+}
+
+// NewMemoryIdempotencyStore creates an in-memory idempotency store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]IdempotencyRecord)}
+}
+
+// Get returns the unexpired record of key
+func (m *MemoryIdempotencyStore) Get(ctx context.Context, key string) (IdempotencyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.records[key]
+	if !ok || time.Now().After(record.Expires) {
+		return IdempotencyRecord{}, ErrIdempotencyNotFound
+	}
+	return record, nil
+}
+
+// Save stores a record, dropping expired ones
+func (m *MemoryIdempotencyStore) Save(ctx context.Context, record IdempotencyRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for key, r := range m.records {
+		if now.After(r.Expires) {
+			delete(m.records, key)
+		}
+	}
+	m.records[record.Key] = record
+	return nil
+}
@@ -0,0 +1,84 @@
+package simpleai
+
+import (
+	"strings"
+	"time"
+
+	"github.com/medatechnology/simpleai/template"
+)
+
+// DefaultInjectionTemplate is how WithContextInjection describes the
+// current time and user unless ContextInjectionConfig.Template is set
+const DefaultInjectionTemplate = `Current date and time: {{.Time}} ({{.TimeZone}})
+{{- if .Profile}}
+User profile:
+{{- range $key, $value := .Profile}}
+- {{$key}}: {{$value}}
+{{- end}}
+{{- end}}`
+
+// ContextInjectionConfig configures what a chat tells the model about the
+// present on every turn
+type ContextInjectionConfig struct {
+	// Location is the user's time zone (default time.Local)
+	Location *time.Location
+
+	// Now returns the current time (default time.Now)
+	Now func() time.Time
+
+	// Profile returns facts about the user, e.g. name, plan and
+	// preferences. It is called on every turn, so it can read fresh
+	// values from a store; nil leaves the profile out.
+	Profile func() map[string]string
+
+	// Template formats the block as a Go template with {{.Now}} (a
+	// time.Time in Location), {{.Time}}, {{.TimeZone}}, {{.Profile}} and
+	// {{.Locale}} (default DefaultInjectionTemplate)
+	Template string
+}
+
+// DefaultContextInjectionConfig returns the current time in the local time
+// zone, without a profile
+func DefaultContextInjectionConfig() ContextInjectionConfig {
+	return ContextInjectionConfig{
+		Location: time.Local,
+		Now:      time.Now,
+		Template: DefaultInjectionTemplate,
+	}
+}
+
+// injectedContext renders the time and profile block for the current turn,
+// or "" when injection is off
+func (c *Chat) injectedContext() string {
+	config := c.injection
+	if config == nil {
+		return ""
+	}
+
+	now := config.Now().In(config.Location)
+	zone := config.Location.String()
+	if zone == "Local" {
+		zone = now.Format("MST")
+	}
+	if zone != "UTC" {
+		zone += ", UTC" + now.Format("-07:00")
+	}
+	data := map[string]any{
+		"Now":      now,
+		"Time":     now.Format("Monday, January 2, 2006 15:04"),
+		"TimeZone": zone,
+		"Locale":   c.locale,
+	}
+	if config.Profile != nil {
+		if profile := config.Profile(); len(profile) > 0 {
+			data["Profile"] = profile
+		}
+	}
+
+	text, err := template.Prompt(config.Template, data)
+	if err != nil {
+		// A broken custom template still gives the model the date
+		text, _ = template.Prompt(DefaultInjectionTemplate, data)
+	}
+	return strings.TrimSpace(text)
+}
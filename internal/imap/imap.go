@@ -0,0 +1,201 @@
+// Package imap is a minimal IMAP4rev1 client, enough for the email bot to
+// poll a mailbox without an external dependency: login, select, search,
+// fetch and flag messages by UID.
+package imap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// maxLiteralSize bounds a single fetched message
+const maxLiteralSize = 32 << 20
+
+// Client is a connection to an IMAP server. It is not safe for concurrent use.
+type Client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	tag    int
+}
+
+// response is an untagged server response with any literals it carried
+type response struct {
+	text     string
+	literals [][]byte
+}
+
+// Dial connects to addr (host:port) using implicit TLS, as on port 993
+func Dial(ctx context.Context, addr string) (*Client, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	d := tls.Dialer{Config: &tls.Config{ServerName: host}}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c := &Client{conn: conn, reader: bufio.NewReader(conn)}
+	greeting, err := c.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("imap: unexpected greeting %q", greeting)
+	}
+	return c, nil
+}
+
+// Login authenticates with a username and password
+func (c *Client) Login(username, password string) error {
+	_, err := c.cmd("LOGIN " + quote(username) + " " + quote(password))
+	return err
+}
+
+// Select opens a mailbox for reading and flagging
+func (c *Client) Select(mailbox string) error {
+	_, err := c.cmd("SELECT " + quote(mailbox))
+	return err
+}
+
+// Search returns the UIDs of messages matching criteria (e.g. "UNSEEN")
+func (c *Client) Search(criteria string) ([]uint32, error) {
+	responses, err := c.cmd("UID SEARCH " + criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	var uids []uint32
+	for _, r := range responses {
+		fields := strings.Fields(r.text)
+		if len(fields) < 2 || fields[1] != "SEARCH" {
+			continue
+		}
+		for _, f := range fields[2:] {
+			if uid, err := strconv.ParseUint(f, 10, 32); err == nil {
+				uids = append(uids, uint32(uid))
+			}
+		}
+	}
+	return uids, nil
+}
+
+// Fetch returns the raw RFC 822 message without marking it as seen
+func (c *Client) Fetch(uid uint32) ([]byte, error) {
+	responses, err := c.cmd(fmt.Sprintf("UID FETCH %d BODY.PEEK[]", uid))
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range responses {
+		if strings.Contains(r.text, "FETCH") && len(r.literals) > 0 {
+			return r.literals[0], nil
+		}
+	}
+	return nil, fmt.Errorf("imap: message %d not found", uid)
+}
+
+// AddFlags adds flags (e.g. `\Seen`) to a message
+func (c *Client) AddFlags(uid uint32, flags ...string) error {
+	_, err := c.cmd(fmt.Sprintf("UID STORE %d +FLAGS.SILENT (%s)", uid, strings.Join(flags, " ")))
+	return err
+}
+
+// Logout ends the session and closes the connection
+func (c *Client) Logout() error {
+	_, err := c.cmd("LOGOUT")
+	c.conn.Close()
+	return err
+}
+
+// Close closes the connection without logging out
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// cmd sends a tagged command and collects untagged responses until the
+// tagged status, which must be OK
+func (c *Client) cmd(command string) ([]response, error) {
+	c.tag++
+	tag := "a" + strconv.Itoa(c.tag)
+	if _, err := io.WriteString(c.conn, tag+" "+command+"\r\n"); err != nil {
+		return nil, err
+	}
+
+	var responses []response
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if status, ok := strings.CutPrefix(line, tag+" "); ok {
+			if !strings.HasPrefix(status, "OK") {
+				return nil, fmt.Errorf("imap: %s", status)
+			}
+			return responses, nil
+		}
+
+		r := response{text: line}
+		// A line ending in {n} is followed by n bytes, then the rest of the line
+		for {
+			size, ok := literalSize(line)
+			if !ok {
+				break
+			}
+			if size > maxLiteralSize {
+				return nil, errors.New("imap: literal too large")
+			}
+			literal := make([]byte, size)
+			if _, err := io.ReadFull(c.reader, literal); err != nil {
+				return nil, err
+			}
+			r.literals = append(r.literals, literal)
+			if line, err = c.readLine(); err != nil {
+				return nil, err
+			}
+			r.text += line
+		}
+		responses = append(responses, r)
+	}
+}
+
+func (c *Client) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func literalSize(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	open := strings.LastIndexByte(line, '{')
+	if open < 0 {
+		return 0, false
+	}
+	size, err := strconv.Atoi(line[open+1 : len(line)-1])
+	if err != nil || size < 0 {
+		return 0, false
+	}
+	return size, true
+}
+
+// quote makes a quoted string; CR and LF can't be quoted and are dropped
+func quote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r", "", "\n", "").Replace(s)
+	return `"` + s + `"`
+}
@@ -0,0 +1,154 @@
+// Package lang detects the language of text and names language codes
+package lang
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Base normalizes a language tag to its lowercase base code
+func Base(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag
+}
+
+var names = map[string]string{
+	"ar": "Arabic", "de": "German", "el": "Greek", "en": "English",
+	"es": "Spanish", "fr": "French", "he": "Hebrew", "hi": "Hindi",
+	"id": "Indonesian", "it": "Italian", "ja": "Japanese", "ko": "Korean",
+	"nl": "Dutch", "pt": "Portuguese", "ru": "Russian", "th": "Thai",
+	"uk": "Ukrainian", "zh": "Chinese",
+}
+
+// Name returns the English name of a language code, or the code
+// itself when unknown
+func Name(code string) string {
+	if name, ok := names[Base(code)]; ok {
+		return name
+	}
+	return code
+}
+
+// Stopwords are common words of languages written in Latin script
+var Stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "of", "to", "that", "it", "you", "with", "for", "this", "was", "not", "have", "be"},
+	"es": {"el", "los", "las", "que", "y", "es", "por", "con", "para", "una", "se", "del", "está", "pero", "muy", "como"},
+	"fr": {"le", "les", "et", "est", "des", "que", "une", "pour", "pas", "vous", "dans", "du", "avec", "sont", "ce", "qui"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "mit", "sie", "ich", "auf", "für", "sind", "auch"},
+	"it": {"il", "di", "che", "è", "per", "un", "non", "sono", "con", "della", "gli", "anche", "come", "questo", "nel", "ci"},
+	"pt": {"o", "os", "que", "é", "não", "um", "uma", "para", "com", "do", "da", "em", "você", "são", "mas", "isso"},
+	"nl": {"de", "het", "een", "en", "is", "van", "niet", "dat", "op", "te", "zijn", "met", "voor", "ik", "je", "ook"},
+	"id": {"yang", "dan", "di", "ini", "itu", "dengan", "untuk", "tidak", "dari", "ada", "saya", "anda", "akan", "dalam", "adalah", "bisa"},
+}
+
+// Code is usually English whatever the prose language, so it is ignored
+var codePattern = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`")
+
+// Detect returns the ISO 639-1 code of the language text is
+// written in, or "" when it is too short or ambiguous. It recognizes
+// languages by script (Chinese, Japanese, Korean, Russian, Ukrainian,
+// Arabic, Hebrew, Greek, Thai, Hindi) and by common words (English,
+// Spanish, French, German, Italian, Portuguese, Dutch, Indonesian).
+func Detect(text string) string {
+	text = codePattern.ReplaceAllString(text, " ")
+
+	scripts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Hangul, r):
+			scripts["ko"]++
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			scripts["kana"]++
+		case unicode.Is(unicode.Han, r):
+			scripts["han"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			scripts["cyrillic"]++
+			if strings.ContainsRune("іїєґІЇЄҐ", r) {
+				scripts["uk"]++
+			}
+		case unicode.Is(unicode.Arabic, r):
+			scripts["ar"]++
+		case unicode.Is(unicode.Hebrew, r):
+			scripts["he"]++
+		case unicode.Is(unicode.Greek, r):
+			scripts["el"]++
+		case unicode.Is(unicode.Thai, r):
+			scripts["th"]++
+		case unicode.Is(unicode.Devanagari, r):
+			scripts["hi"]++
+		case unicode.Is(unicode.Latin, r):
+			scripts["latin"]++
+		}
+	}
+	if letters < 10 {
+		return ""
+	}
+
+	best, count := "", 0
+	for script, n := range scripts {
+		if script != "uk" && n > count {
+			best, count = script, n
+		}
+	}
+	switch best {
+	case "kana":
+		return "ja"
+	case "han":
+		// Japanese mixes kanji with kana
+		if scripts["kana"]*10 > count {
+			return "ja"
+		}
+		return "zh"
+	case "cyrillic":
+		if scripts["uk"] > 0 {
+			return "uk"
+		}
+		return "ru"
+	case "latin":
+		return detectLatin(text)
+	}
+	return best
+}
+
+// detectLatin tells Latin-script languages apart by their common words
+func detectLatin(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	scores := make(map[string]int)
+	for _, word := range words {
+		for code, list := range Stopwords {
+			for _, stop := range list {
+				if word == stop {
+					scores[code]++
+					break
+				}
+			}
+		}
+	}
+
+	best, first, second := "", 0, 0
+	for code, score := range scores {
+		switch {
+		case score > first:
+			best, first, second = code, score, first
+		case score > second:
+			second = score
+		}
+	}
+	// Require a clear winner
+	if first < 2 || first*2 < second*3 {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,24 @@
+// Package pii masks common personal data in text
+package pii
+
+import "regexp"
+
+var patterns = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`), "[EMAIL]"},
+	{regexp.MustCompile(`\b(?:\d[ \-]?){13,19}\b`), "[CARD]"},
+	{regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), "[SSN]"},
+	{regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), "[IP]"},
+	{regexp.MustCompile(`\+?\(?\d{1,4}\)?[ .\-]?\(?\d{2,4}\)?[ .\-]?\d{3,4}[ .\-]?\d{3,4}\b`), "[PHONE]"},
+}
+
+// Scrub replaces email addresses, card numbers, US social security numbers,
+// phone numbers and IPv4 addresses with placeholders such as [EMAIL]
+func Scrub(text string) string {
+	for _, p := range patterns {
+		text = p.pattern.ReplaceAllString(text, p.replacement)
+	}
+	return text
+}
@@ -0,0 +1,154 @@
+// Package sse reads server-sent event streams as specified by the HTML
+// standard: multi-line data fields, comments, event types and ids, and
+// CR, LF or CRLF line endings.
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxEventSize is the largest event data read when no limit is given
+const DefaultMaxEventSize = 4 << 20
+
+// ErrEventTooLarge is returned when an event exceeds the maximum size
+var ErrEventTooLarge = errors.New("sse: event too large")
+
+// Event is one dispatched server-sent event
+type Event struct {
+	Type  string // The event field; empty means "message"
+	Data  string // Data lines joined with "\n"
+	ID    string // The last event ID seen on the stream
+	Retry int    // Reconnection time in milliseconds, 0 if not sent
+}
+
+// Reader reads events from a stream. Use it like bufio.Scanner:
+//
+//	events := sse.NewReader(body, 0)
+//	for events.Next() {
+//		handle(events.Event())
+//	}
+//	if err := events.Err(); err != nil { ... }
+type Reader struct {
+	scanner *bufio.Scanner
+	max     int
+	event   Event
+	lastID  string
+	started bool
+	err     error
+}
+
+// NewReader creates a reader that fails with ErrEventTooLarge on events
+// with more than maxEventSize bytes of data (0 uses DefaultMaxEventSize)
+func NewReader(r io.Reader, maxEventSize int) *Reader {
+	if maxEventSize <= 0 {
+		maxEventSize = DefaultMaxEventSize
+	}
+	scanner := bufio.NewScanner(r)
+	// Room for the field name on top of the data
+	scanner.Buffer(make([]byte, 0, min(maxEventSize, 64*1024)), maxEventSize+64)
+	scanner.Split(scanLines)
+	return &Reader{scanner: scanner, max: maxEventSize}
+}
+
+// Next advances to the next event, returning false at the end of the
+// stream or on error. An unterminated event at the end is discarded.
+func (r *Reader) Next() bool {
+	var data strings.Builder
+	var eventType string
+	hasData := false
+
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+		if !r.started {
+			r.started = true
+			line = strings.TrimPrefix(line, "\uFEFF")
+		}
+
+		if line == "" {
+			if !hasData {
+				eventType = ""
+				continue
+			}
+			r.event = Event{Type: eventType, Data: data.String(), ID: r.lastID, Retry: r.event.Retry}
+			return true
+		}
+		if line[0] == ':' {
+			continue // Comment, often used as a keep-alive
+		}
+
+		field, value, found := strings.Cut(line, ":")
+		if found {
+			value = strings.TrimPrefix(value, " ")
+		}
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+			if data.Len() > r.max {
+				r.err = ErrEventTooLarge
+				return false
+			}
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				r.lastID = value
+			}
+		case "retry":
+			if value != "" && strings.Trim(value, "0123456789") == "" {
+				r.event.Retry, _ = strconv.Atoi(value)
+			}
+		}
+	}
+
+	r.err = r.scanner.Err()
+	if errors.Is(r.err, bufio.ErrTooLong) {
+		r.err = ErrEventTooLarge
+	}
+	return false
+}
+
+// Event returns the event read by the last call to Next
+func (r *Reader) Event() Event {
+	return r.event
+}
+
+// Err returns the first error that stopped Next, nil at a clean end
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// scanLines splits on CRLF, LF or CR
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		// A CR may be followed by an LF we haven't read yet
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		return 0, nil, nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
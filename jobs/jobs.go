@@ -0,0 +1,316 @@
+// Package jobs runs completions asynchronously: callers submit a request,
+// get a job ID and poll for the result while a worker pool consumes a
+// pluggable queue. Use it for long generations that outlive HTTP timeouts.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/goutil/simplelog"
+	"github.com/medatechnology/simpleai"
+)
+
+// Common errors
+var (
+	ErrJobNotFound = errors.New("jobs: job not found")
+	ErrQueueFull   = errors.New("jobs: queue is full")
+)
+
+// Status is the state of a job
+type Status string
+
+// Job states
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// Job is an asynchronous completion
+type Job struct {
+	ID       string                   `json:"id"`
+	Status   Status                   `json:"status"`
+	Request  simpleai.Request         `json:"request"`
+	Metadata simpleai.RequestMetadata `json:"metadata,omitzero"` // From the submitting context
+	Owner    string                   `json:"owner,omitempty"`   // Who submitted the job, see WithOwner
+
+	Response *simpleai.Response `json:"response,omitempty"`
+	Error    string             `json:"error,omitempty"`
+
+	CreatedAt  time.Time `json:"created_at"`
+	StartedAt  time.Time `json:"started_at,omitzero"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+}
+
+// Done reports whether the job has finished
+func (j Job) Done() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed
+}
+
+// Queue hands job IDs to the workers. Implement it on Redis (LPUSH and
+// BRPOP) to share work across instances.
+type Queue interface {
+	// Push enqueues a job ID
+	Push(ctx context.Context, id string) error
+	// Pop blocks until a job ID is available or ctx is done
+	Pop(ctx context.Context) (string, error)
+}
+
+// Store keeps jobs and their results
+type Store interface {
+	Save(ctx context.Context, job Job) error
+	// Get returns ErrJobNotFound for unknown or expired jobs
+	Get(ctx context.Context, id string) (Job, error)
+}
+
+// Config holds runner configuration
+type Config struct {
+	Queue Queue // Default: in memory, see NewMemoryQueue
+	Store Store // Default: in memory, keeping finished jobs for a day
+
+	// Workers is the number of jobs run at once (default 4)
+	Workers int
+
+	// Timeout bounds each job (default 10 minutes)
+	Timeout time.Duration
+
+	// OnFailure is called when a job fails
+	OnFailure func(job Job)
+}
+
+// DefaultConfig returns sensible defaults
+func DefaultConfig() Config {
+	return Config{
+		Queue:   NewMemoryQueue(0),
+		Store:   NewMemoryStore(24 * time.Hour),
+		Workers: 4,
+		Timeout: 10 * time.Minute,
+		OnFailure: func(job Job) {
+			simplelog.LogErr(errors.New(job.Error), "job "+job.ID+" failed")
+		},
+	}
+}
+
+// Runner submits completions to the queue and runs them on a worker pool
+type Runner struct {
+	client *simpleai.Client
+	config Config
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+	onDone  map[string]func(Job) // By job ID, see WithOnDone
+}
+
+// New creates a runner; call Start to run the workers
+func New(client *simpleai.Client, config Config) *Runner {
+	defaults := DefaultConfig()
+	if config.Queue == nil {
+		config.Queue = defaults.Queue
+	}
+	if config.Store == nil {
+		config.Store = defaults.Store
+	}
+	if config.Workers <= 0 {
+		config.Workers = defaults.Workers
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.OnFailure == nil {
+		config.OnFailure = defaults.OnFailure
+	}
+	return &Runner{client: client, config: config}
+}
+
+// NewSimple creates a runner with in-memory queue and store and starts it
+func NewSimple(client *simpleai.Client) *Runner {
+	r := New(client, DefaultConfig())
+	r.Start(context.Background())
+	return r
+}
+
+// SubmitOption configures a submitted job
+type SubmitOption func(*submitOptions)
+
+type submitOptions struct {
+	owner  string
+	onDone func(Job)
+}
+
+// WithOwner records who submitted the job in Job.Owner, so readers can be
+// checked against it
+func WithOwner(owner string) SubmitOption {
+	return func(o *submitOptions) {
+		o.owner = owner
+	}
+}
+
+// WithOnDone calls fn once the job has finished, e.g. to bill its usage.
+// It is only called if this runner runs the job, not another instance
+// sharing the queue.
+func WithOnDone(fn func(job Job)) SubmitOption {
+	return func(o *submitOptions) {
+		o.onDone = fn
+	}
+}
+
+// Submit queues a completion and returns its job. The request metadata of
+// ctx is kept with the job and applied when it runs. If the job can't be
+// queued, it is stored as failed.
+func (r *Runner) Submit(ctx context.Context, req *simpleai.Request, opts ...SubmitOption) (Job, error) {
+	var o submitOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	job := Job{
+		ID:        newJobID(),
+		Status:    StatusQueued,
+		Request:   *req,
+		Owner:     o.owner,
+		CreatedAt: time.Now(),
+	}
+	job.Request.Stream = false
+	if md, ok := simpleai.RequestMetadataFromContext(ctx); ok {
+		job.Metadata = md
+	}
+
+	if err := r.config.Store.Save(ctx, job); err != nil {
+		return Job{}, err
+	}
+	if o.onDone != nil {
+		r.mu.Lock()
+		if r.onDone == nil {
+			r.onDone = make(map[string]func(Job))
+		}
+		r.onDone[job.ID] = o.onDone
+		r.mu.Unlock()
+	}
+	if err := r.config.Queue.Push(ctx, job.ID); err != nil {
+		r.takeOnDone(job.ID)
+		// Without this, the job would stay queued forever
+		job.Status = StatusFailed
+		job.Error = err.Error()
+		job.FinishedAt = time.Now()
+		if err := r.config.Store.Save(context.WithoutCancel(ctx), job); err != nil {
+			simplelog.LogErr(err, "jobs: saving job "+job.ID+" failed")
+		}
+		return Job{}, err
+	}
+	return job, nil
+}
+
+// takeOnDone removes and returns the WithOnDone callback of a job
+func (r *Runner) takeOnDone(id string) func(Job) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn := r.onDone[id]
+	delete(r.onDone, id)
+	return fn
+}
+
+// Get returns a job and, once it is done, its result
+func (r *Runner) Get(ctx context.Context, id string) (Job, error) {
+	return r.config.Store.Get(ctx, id)
+}
+
+// Start runs the workers in the background until ctx is cancelled or Stop
+// is called
+func (r *Runner) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	r.mu.Lock()
+	r.cancel = cancel
+	r.mu.Unlock()
+
+	for range r.config.Workers {
+		r.running.Add(1)
+		go r.work(ctx)
+	}
+}
+
+// Stop stops taking jobs and waits for running ones to finish
+func (r *Runner) Stop() {
+	r.mu.Lock()
+	cancel := r.cancel
+	r.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	r.running.Wait()
+}
+
+func (r *Runner) work(ctx context.Context) {
+	defer r.running.Done()
+	for {
+		id, err := r.config.Queue.Pop(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			simplelog.LogErr(err, "jobs: queue pop failed")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		// A job started before Stop runs to completion
+		r.run(context.WithoutCancel(ctx), id)
+	}
+}
+
+// run executes one job and stores its result
+func (r *Runner) run(ctx context.Context, id string) {
+	job, err := r.config.Store.Get(ctx, id)
+	if err != nil {
+		if !errors.Is(err, ErrJobNotFound) {
+			simplelog.LogErr(err, "jobs: loading job "+id+" failed")
+		}
+		return
+	}
+	if job.Status != StatusQueued {
+		return // Delivered twice
+	}
+
+	job.Status = StatusRunning
+	job.StartedAt = time.Now()
+	if err := r.config.Store.Save(ctx, job); err != nil {
+		simplelog.LogErr(err, "jobs: saving job "+id+" failed")
+		return
+	}
+
+	runCtx, cancel := context.WithTimeout(simpleai.WithRequestMetadata(ctx, job.Metadata), r.config.Timeout)
+	resp, err := r.client.Complete(runCtx, &job.Request)
+	cancel()
+
+	job.FinishedAt = time.Now()
+	if err != nil {
+		job.Status = StatusFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = StatusSucceeded
+		job.Response = resp
+	}
+	if err := r.config.Store.Save(ctx, job); err != nil {
+		simplelog.LogErr(err, "jobs: saving job "+id+" failed")
+	}
+	if job.Status == StatusFailed {
+		r.config.OnFailure(job)
+	}
+	if fn := r.takeOnDone(id); fn != nil {
+		fn(job)
+	}
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "job_" + hex.EncodeToString(b)
+}
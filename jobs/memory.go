@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryQueue is an in-process queue
+type MemoryQueue struct {
+	ch chan string
+}
+
+// NewMemoryQueue creates an in-memory queue holding up to capacity waiting
+// jobs (0 = 1000)
+func NewMemoryQueue(capacity int) *MemoryQueue {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &MemoryQueue{ch: make(chan string, capacity)}
+}
+
+// Push enqueues a job ID, or returns ErrQueueFull
+func (q *MemoryQueue) Push(ctx context.Context, id string) error {
+	select {
+	case q.ch <- id:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Pop waits for the next job ID
+func (q *MemoryQueue) Pop(ctx context.Context) (string, error) {
+	select {
+	case id := <-q.ch:
+		return id, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// MemoryStore keeps jobs in memory
+type MemoryStore struct {
+	mu        sync.RWMutex
+	jobs      map[string]Job
+	retention time.Duration
+	pruned    time.Time
+}
+
+// NewMemoryStore creates an in-memory store that forgets finished jobs
+// after retention (0 = never)
+func NewMemoryStore(retention time.Duration) *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]Job), retention: retention}
+}
+
+// Save stores a job, dropping expired ones
+func (m *MemoryStore) Save(ctx context.Context, job Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now := time.Now(); m.retention > 0 && now.Sub(m.pruned) > time.Minute {
+		for id, j := range m.jobs {
+			if m.expired(j, now) {
+				delete(m.jobs, id)
+			}
+		}
+		m.pruned = now
+	}
+	m.jobs[job.ID] = job
+	return nil
+}
+
+// Get returns the job with the given ID
+func (m *MemoryStore) Get(ctx context.Context, id string) (Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	job, ok := m.jobs[id]
+	if !ok || m.expired(job, time.Now()) {
+		return Job{}, ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (m *MemoryStore) expired(job Job, now time.Time) bool {
+	return m.retention > 0 && job.Done() && now.Sub(job.FinishedAt) > m.retention
+}
@@ -0,0 +1,77 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/medatechnology/simpleai"
+)
+
+type judgingKey struct{}
+
+// judging marks ctx as a judge request, so a Guard on the judge's own
+// client doesn't judge the judge
+func judging(ctx context.Context) context.Context {
+	return context.WithValue(ctx, judgingKey{}, true)
+}
+
+// GuardConfig holds configuration for the guard middleware
+type GuardConfig struct {
+	// MinOverall is the lowest Result.Overall passed through (default 0.5)
+	MinOverall float64
+
+	// FailClosed returns the judge's error when the judge itself fails;
+	// by default the response is passed through
+	FailClosed bool
+
+	// OnResult is called with every judgement, e.g. to log scores
+	OnResult func(req *simpleai.Request, resp *simpleai.Response, result *Result)
+}
+
+// DefaultGuardConfig returns the default guard settings
+func DefaultGuardConfig() GuardConfig {
+	return GuardConfig{MinOverall: 0.5}
+}
+
+// Guard returns middleware that judges every completion and fails those
+// scoring below MinOverall with ErrBelowThreshold. It adds a judge call to
+// each request, so reserve it for high-stakes endpoints. Streams are not
+// judged.
+func (j *Judge) Guard(config GuardConfig) simpleai.Middleware {
+	if config.MinOverall <= 0 {
+		config.MinOverall = DefaultGuardConfig().MinOverall
+	}
+	return simpleai.MiddlewareFunc(func(next simpleai.Handler) simpleai.Handler {
+		return func(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+			resp, err := next(ctx, req)
+			if err != nil || resp == nil || ctx.Value(judgingKey{}) != nil {
+				return resp, err
+			}
+
+			result, err := j.Evaluate(ctx, Input{Prompt: lastUserMessage(req), Response: resp.Content})
+			if err != nil {
+				if config.FailClosed {
+					return nil, err
+				}
+				return resp, nil
+			}
+			if config.OnResult != nil {
+				config.OnResult(req, resp, result)
+			}
+			if result.Overall < config.MinOverall {
+				return nil, fmt.Errorf("%w: %.2f", ErrBelowThreshold, result.Overall)
+			}
+			return resp, nil
+		}
+	})
+}
+
+// lastUserMessage returns the content of the request's last user message
+func lastUserMessage(req *simpleai.Request) string {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == simpleai.RoleUser {
+			return req.Messages[i].Content
+		}
+	}
+	return ""
+}
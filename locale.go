@@ -0,0 +1,81 @@
+package simpleai
+
+import (
+	"context"
+	"maps"
+	"strings"
+
+	"github.com/medatechnology/simpleai/internal/lang"
+	"github.com/medatechnology/simpleai/template"
+)
+
+// Locale returns the conversation's locale: the one set with WithLocale or
+// SetLocale, or the language detected in the first user message that had
+// enough text to tell. It is "" until then.
+func (c *Chat) Locale() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.locale
+}
+
+// SetLocale sets the conversation's locale, e.g. when the user picks a
+// language; "" detects it again from the next user message
+func (c *Chat) SetLocale(locale string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.locale = locale
+}
+
+// detectLocaleOf sets the locale from a user message if none is set yet;
+// the caller must hold the write lock
+func (c *Chat) detectLocaleOf(text string) {
+	if c.locale != "" || c.detectLocale == nil {
+		return
+	}
+	c.locale = c.detectLocale(text)
+}
+
+// systemPrompt returns the system prompt, rendering the system template
+// with the chat's vars and locale. A template that fails to render is
+// sent as written, so a bad variable never drops the prompt.
+func (c *Chat) systemPrompt() string {
+	if c.systemTemplate == "" {
+		return c.system
+	}
+	data := make(map[string]any, len(c.systemVars)+2)
+	data["Locale"] = c.locale
+	data["Language"] = ""
+	if c.locale != "" {
+		data["Language"] = lang.Name(c.locale)
+	}
+	maps.Copy(data, c.systemVars)
+
+	text, err := template.Prompt(c.systemTemplate, data)
+	if err != nil {
+		return c.systemTemplate
+	}
+	return text
+}
+
+// Translate translates text into the language of locale, e.g. a chat's
+// Locale, keeping formatting, placeholders and code as they are. Text
+// already in that language comes back unchanged, without a model call.
+func (c *Client) Translate(ctx context.Context, text, locale string, opts ...RequestOption) (string, error) {
+	if strings.TrimSpace(text) == "" {
+		return "", ErrEmptyMessage
+	}
+	if locale == "" || lang.Detect(text) == lang.Base(locale) {
+		return text, nil
+	}
+
+	req := &Request{
+		Messages: []Message{{Role: RoleUser, Content: text}},
+		SystemPrompt: "Translate the user's text into " + lang.Name(locale) + ". Keep its formatting, " +
+			"placeholders such as {name} or {{.Name}}, URLs and code unchanged. Reply with only the translation.",
+	}
+	resp, err := c.Complete(ctx, req, opts...)
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
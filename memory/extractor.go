@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// AIExtractor uses an AI provider to extract facts as triples
+type AIExtractor struct {
+	provider simpleai.Provider
+	model    string
+}
+
+// NewAIExtractor creates an extractor using the given AI provider
+func NewAIExtractor(provider simpleai.Provider) *AIExtractor {
+	return &AIExtractor{
+		provider: provider,
+	}
+}
+
+// NewAIExtractorWithModel creates an extractor with a specific model
+func NewAIExtractorWithModel(provider simpleai.Provider, model string) *AIExtractor {
+	return &AIExtractor{
+		provider: provider,
+		model:    model,
+	}
+}
+
+// Extract returns the facts stated in the last message
+func (e *AIExtractor) Extract(ctx context.Context, messages []simpleai.Message) ([]Triple, error) {
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	var sb strings.Builder
+	for i, msg := range messages {
+		speaker := string(msg.Role)
+		if msg.Name != "" {
+			speaker = msg.Name
+		}
+		if i == len(messages)-1 {
+			sb.WriteString("\nExtract facts from this message:\n")
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n", speaker, msg.Content))
+	}
+
+	req := &simpleai.Request{
+		Messages: []simpleai.Message{
+			{
+				Role:    simpleai.RoleUser,
+				Content: sb.String(),
+			},
+		},
+		SystemPrompt: `Extract the lasting facts stated in the last message as subject-relation-object triples.
+Reply with only a JSON array such as:
+[{"subject": "user", "relation": "takes", "object": "ibuprofen", "type": "medication"}]
+- Refer to the speaker as "user", or by name if known; resolve pronouns using the earlier messages
+- Use short lowercase relations with underscores, e.g. "takes", "allergic_to", "works_at", "has_condition"
+- Type names the kind of object, e.g. "medication", "condition", "person", "place", "date"
+- Skip questions, greetings and opinions about the conversation
+Reply with [] if there are no facts.`,
+		Model:       e.model,
+		MaxTokens:   800,
+		Temperature: 0.1, // Low temperature for consistent extraction
+	}
+
+	resp, err := e.provider.Complete(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("fact extraction failed: %w", err)
+	}
+
+	content := resp.Content
+	start, end := strings.Index(content, "["), strings.LastIndex(content, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("fact extraction failed: no JSON array in reply")
+	}
+	var triples []Triple
+	if err := json.Unmarshal([]byte(content[start:end+1]), &triples); err != nil {
+		return nil, fmt.Errorf("fact extraction failed: %w", err)
+	}
+	return triples, nil
+}
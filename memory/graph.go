@@ -0,0 +1,290 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// Triple is a fact as a subject–relation–object statement, such as
+// ("patient", "takes", "ibuprofen")
+type Triple struct {
+	Subject  string    `json:"subject"`
+	Relation string    `json:"relation"` // Lowercase with underscores, e.g. "allergic_to"
+	Object   string    `json:"object"`
+	Type     string    `json:"type,omitempty"`   // Kind of object, e.g. "medication", "person", "date"
+	Source   string    `json:"source,omitempty"` // ID of the message the fact came from
+	Time     time.Time `json:"time,omitzero"`
+}
+
+// String renders the triple as a sentence-like line
+func (t Triple) String() string {
+	return t.Subject + " " + strings.ReplaceAll(t.Relation, "_", " ") + " " + t.Object
+}
+
+// TripleExtractor extracts facts from the last message, using the earlier
+// ones to resolve references such as "it" or "my doctor"
+type TripleExtractor interface {
+	Extract(ctx context.Context, messages []simpleai.Message) ([]Triple, error)
+}
+
+// GraphMemoryConfig holds configuration for graph memory
+type GraphMemoryConfig struct {
+	MemoryConfig
+
+	// Extractor turns messages into triples (required)
+	Extractor TripleExtractor
+
+	// Roles whose messages facts are extracted from (default user)
+	Roles []simpleai.Role
+
+	// Window is the number of earlier messages given to the extractor as
+	// context (default 4)
+	Window int
+
+	// RecentMessages is the number of recent messages GetRelevant returns
+	// with the facts (default 5)
+	RecentMessages int
+}
+
+// DefaultGraphMemoryConfig returns sensible defaults; Extractor must
+// still be set
+func DefaultGraphMemoryConfig() GraphMemoryConfig {
+	return GraphMemoryConfig{
+		MemoryConfig:   DefaultMemoryConfig(),
+		Roles:          []simpleai.Role{simpleai.RoleUser},
+		Window:         4,
+		RecentMessages: 5,
+	}
+}
+
+// GraphMemory keeps recent messages like Simple and a small knowledge
+// graph of the facts extracted from them, for structured recall such as
+// "which medications has the patient mentioned?"
+type GraphMemory struct {
+	simple *Simple
+	config GraphMemoryConfig
+
+	mu      sync.RWMutex
+	triples []Triple
+	recent  []simpleai.Message // Extraction context
+}
+
+// NewGraphMemory creates a graph memory
+func NewGraphMemory(config GraphMemoryConfig) *GraphMemory {
+	defaults := DefaultGraphMemoryConfig()
+	if len(config.Roles) == 0 {
+		config.Roles = defaults.Roles
+	}
+	if config.Window <= 0 {
+		config.Window = defaults.Window
+	}
+	if config.RecentMessages <= 0 {
+		config.RecentMessages = defaults.RecentMessages
+	}
+	return &GraphMemory{
+		simple: NewSimple(config.MemoryConfig),
+		config: config,
+	}
+}
+
+// Add adds a message to memory and extracts its facts. Extraction errors
+// don't fail Add; the message is still remembered.
+func (m *GraphMemory) Add(ctx context.Context, msg simpleai.Message) error {
+	if err := m.simple.Add(ctx, msg); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	window := append(slices.Clone(m.recent), msg)
+	m.recent = window
+	if len(m.recent) > m.config.Window {
+		m.recent = m.recent[len(m.recent)-m.config.Window:]
+	}
+	m.mu.Unlock()
+
+	if m.config.Extractor == nil || !slices.Contains(m.config.Roles, msg.Role) {
+		return nil
+	}
+	triples, err := m.config.Extractor.Extract(ctx, window)
+	if err != nil {
+		return nil
+	}
+	for i := range triples {
+		triples[i].Source = msg.ID
+		if triples[i].Time.IsZero() {
+			triples[i].Time = time.Now()
+		}
+	}
+	m.AddTriples(triples...)
+	return nil
+}
+
+// AddTriples stores facts directly, e.g. ones loaded from a database. A
+// fact with the same subject, relation and object replaces the stored one.
+func (m *GraphMemory) AddTriples(triples ...Triple) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range triples {
+		t.Subject = strings.TrimSpace(t.Subject)
+		t.Object = strings.TrimSpace(t.Object)
+		t.Relation = normalizeRelation(t.Relation)
+		t.Type = strings.ToLower(strings.TrimSpace(t.Type))
+		if t.Subject == "" || t.Relation == "" || t.Object == "" {
+			continue
+		}
+		m.triples = slices.DeleteFunc(m.triples, func(s Triple) bool {
+			return sameEntity(s.Subject, t.Subject) && s.Relation == t.Relation && sameEntity(s.Object, t.Object)
+		})
+		m.triples = append(m.triples, t)
+	}
+}
+
+// Find returns the facts matching pattern, oldest first. Empty fields match
+// anything; entities compare case-insensitively, e.g.
+// Find(Triple{Subject: "patient", Type: "medication"}).
+func (m *GraphMemory) Find(pattern Triple) []Triple {
+	pattern.Relation = normalizeRelation(pattern.Relation)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []Triple
+	for _, t := range m.triples {
+		if (pattern.Subject == "" || sameEntity(t.Subject, pattern.Subject)) &&
+			(pattern.Relation == "" || t.Relation == pattern.Relation) &&
+			(pattern.Object == "" || sameEntity(t.Object, pattern.Object)) &&
+			(pattern.Type == "" || strings.EqualFold(t.Type, pattern.Type)) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// Related returns the facts about entity, as subject or object, and those
+// about the entities linked to it, up to depth hops away (default 1)
+func (m *GraphMemory) Related(entity string, depth int) []Triple {
+	if depth <= 0 {
+		depth = 1
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	visited := map[string]bool{strings.ToLower(strings.TrimSpace(entity)): true}
+	frontier := []string{entity}
+	included := make([]bool, len(m.triples))
+	for hop := 0; hop < depth && len(frontier) > 0; hop++ {
+		var next []string
+		for i, t := range m.triples {
+			if included[i] {
+				continue
+			}
+			for _, e := range frontier {
+				var other string
+				switch {
+				case sameEntity(t.Subject, e):
+					other = t.Object
+				case sameEntity(t.Object, e):
+					other = t.Subject
+				default:
+					continue
+				}
+				included[i] = true
+				if key := strings.ToLower(other); !visited[key] {
+					visited[key] = true
+					next = append(next, other)
+				}
+				break
+			}
+		}
+		frontier = next
+	}
+
+	var result []Triple
+	for i, t := range m.triples {
+		if included[i] {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// Triples returns all facts, oldest first
+func (m *GraphMemory) Triples() []Triple {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.triples)
+}
+
+// GetMessages retrieves recent messages, respecting token limits
+func (m *GraphMemory) GetMessages(ctx context.Context, maxTokens int) ([]simpleai.Message, error) {
+	return m.simple.GetMessages(ctx, maxTokens)
+}
+
+// GetRelevant returns a system message listing the topK facts that best
+// match query by keywords (facts of a matching type count), followed by
+// the recent messages
+func (m *GraphMemory) GetRelevant(ctx context.Context, query string, topK int) ([]simpleai.Message, error) {
+	m.mu.RLock()
+	docs := make([]string, len(m.triples))
+	for i, t := range m.triples {
+		docs[i] = t.String() + " " + t.Type
+	}
+	var facts []string
+	for _, i := range rankByKeywords(query, docs, topK) {
+		facts = append(facts, "- "+m.triples[i].String())
+	}
+	m.mu.RUnlock()
+
+	var result []simpleai.Message
+	if len(facts) > 0 {
+		result = append(result, simpleai.Message{
+			Role:    simpleai.RoleSystem,
+			Content: "Known facts:\n" + strings.Join(facts, "\n"),
+		})
+	}
+
+	recent, err := m.simple.GetMessages(ctx, m.config.MaxTokens)
+	if err != nil {
+		return nil, err
+	}
+	if len(recent) > m.config.RecentMessages {
+		recent = recent[len(recent)-m.config.RecentMessages:]
+	}
+	return append(result, recent...), nil
+}
+
+// Clear clears messages and facts
+func (m *GraphMemory) Clear(ctx context.Context) error {
+	m.mu.Lock()
+	m.triples = nil
+	m.recent = nil
+	m.mu.Unlock()
+	return m.simple.Clear(ctx)
+}
+
+// Count returns message count
+func (m *GraphMemory) Count() int {
+	return m.simple.Count()
+}
+
+// TokenCount returns total tokens
+func (m *GraphMemory) TokenCount() int {
+	return m.simple.TokenCount()
+}
+
+// normalizeRelation lowercases a relation and joins its words with
+// underscores, so "Allergic to" and "allergic_to" match
+func normalizeRelation(relation string) string {
+	words := strings.FieldsFunc(strings.ToLower(relation), func(r rune) bool {
+		return r == ' ' || r == '_' || r == '-'
+	})
+	return strings.Join(words, "_")
+}
+
+func sameEntity(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// stopWords are ignored when ranking by keywords
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "can": true, "do": true, "does": true,
+	"for": true, "from": true, "had": true, "has": true, "have": true, "how": true,
+	"i": true, "if": true, "in": true, "is": true, "it": true, "its": true,
+	"me": true, "my": true, "no": true, "not": true, "of": true, "on": true,
+	"or": true, "so": true, "that": true, "the": true, "this": true, "to": true,
+	"was": true, "we": true, "what": true, "when": true, "which": true, "who": true,
+	"will": true, "with": true, "you": true, "your": true,
+}
+
+// terms splits text into lowercased words without stop words
+func terms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	result := words[:0]
+	for _, w := range words {
+		if !stopWords[w] {
+			result = append(result, w)
+		}
+	}
+	return result
+}
+
+// rankByKeywords scores docs against query with TF-IDF and returns the
+// indexes of the topK best matches (score > 0), best first
+func rankByKeywords(query string, docs []string, topK int) []int {
+	queryTerms := terms(query)
+	if len(queryTerms) == 0 || len(docs) == 0 {
+		return nil
+	}
+
+	// Term frequencies per document and document frequencies per term
+	tfs := make([]map[string]float64, len(docs))
+	df := make(map[string]int)
+	for i, doc := range docs {
+		tf := make(map[string]float64)
+		words := terms(doc)
+		for _, w := range words {
+			tf[w]++
+		}
+		for w := range tf {
+			tf[w] /= float64(len(words))
+			df[w]++
+		}
+		tfs[i] = tf
+	}
+
+	type scored struct {
+		index int
+		score float64
+	}
+	var results []scored
+	n := float64(len(docs))
+	for i, tf := range tfs {
+		score := 0.0
+		for _, w := range queryTerms {
+			if tf[w] > 0 {
+				// Smoothed IDF keeps terms found in every document positive
+				score += tf[w] * math.Log(1+n/float64(df[w]))
+			}
+		}
+		if score > 0 {
+			results = append(results, scored{i, score})
+		}
+	}
+
+	// Best first; ties prefer the more recent message
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].score != results[b].score {
+			return results[a].score > results[b].score
+		}
+		return results[a].index > results[b].index
+	})
+	if topK > 0 && len(results) > topK {
+		results = results[:topK]
+	}
+
+	indexes := make([]int, len(results))
+	for i, r := range results {
+		indexes[i] = r.index
+	}
+	return indexes
+}
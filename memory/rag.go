@@ -10,10 +10,10 @@ import (
 
 // RAGMemory combines simple memory with RAG for intelligent retrieval
 type RAGMemory struct {
-	simple     *Simple
-	rag        *rag.RAG
-	messageID  int
-	config     RAGMemoryConfig
+	simple    *Simple
+	rag       *rag.RAG
+	messageID int
+	config    RAGMemoryConfig
 }
 
 // RAGMemoryConfig holds configuration for RAG memory
@@ -55,6 +55,9 @@ func (m *RAGMemory) Add(ctx context.Context, msg simpleai.Message) error {
 	// Add to RAG store
 	m.messageID++
 	id := fmt.Sprintf("msg_%d", m.messageID)
+	if msg.ID != "" {
+		id = msg.ID
+	}
 	if err := m.rag.AddMessage(ctx, msg, id); err != nil {
 		// Log but don't fail - simple memory still works
 		return nil
@@ -2,6 +2,7 @@ package memory
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"github.com/medatechnology/simpleai"
@@ -9,13 +10,13 @@ import (
 
 // Simple is an in-memory implementation of Memory with token-based limits
 type Simple struct {
-	messages     []simpleai.Message
-	tokenCounts  []int
-	totalTokens  int
-	config       MemoryConfig
-	summarizer   Summarizer
-	summary      string
-	mu           sync.RWMutex
+	messages    []simpleai.Message
+	tokenCounts []int
+	totalTokens int
+	config      MemoryConfig
+	summarizer  Summarizer
+	summary     string
+	mu          sync.RWMutex
 }
 
 // NewSimple creates a new simple in-memory store
@@ -102,9 +103,28 @@ func (s *Simple) GetMessages(ctx context.Context, maxTokens int) ([]simpleai.Mes
 	return result, nil
 }
 
-// GetRelevant is not supported in simple memory (returns all messages)
+// GetRelevant returns up to topK messages ranked by TF-IDF keyword relevance
+// to the query, in conversation order. No embeddings are needed.
 func (s *Simple) GetRelevant(ctx context.Context, query string, topK int) ([]simpleai.Message, error) {
-	return s.GetMessages(ctx, s.config.MaxTokens)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if topK <= 0 {
+		topK = 5
+	}
+
+	docs := make([]string, len(s.messages))
+	for i, msg := range s.messages {
+		docs[i] = msg.Content
+	}
+	indexes := rankByKeywords(query, docs, topK)
+	sort.Ints(indexes)
+
+	result := make([]simpleai.Message, 0, len(indexes))
+	for _, i := range indexes {
+		result = append(result, s.messages[i])
+	}
+	return result, nil
 }
 
 // Clear clears all messages
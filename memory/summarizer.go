@@ -38,7 +38,11 @@ func (s *AISummarizer) Summarize(ctx context.Context, messages []simpleai.Messag
 	// Build conversation text
 	var sb strings.Builder
 	for _, msg := range messages {
-		sb.WriteString(fmt.Sprintf("%s: %s\n", msg.Role, msg.Content))
+		speaker := string(msg.Role)
+		if msg.Name != "" {
+			speaker = msg.Name
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n", speaker, msg.Content))
 	}
 
 	req := &simpleai.Request{
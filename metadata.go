@@ -0,0 +1,70 @@
+package simpleai
+
+import (
+	"context"
+	"sync"
+)
+
+// RequestMetadata describes who and what a request is for.
+// Providers forward it where supported (e.g. OpenAI's user field, extra
+// HTTP headers) and middleware such as logging records it automatically.
+type RequestMetadata struct {
+	UserID    string            `json:"user_id,omitempty"`
+	SessionID string            `json:"session_id,omitempty"`
+	TraceID   string            `json:"trace_id,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"` // Extra HTTP headers sent to the provider
+	Tags      map[string]string `json:"tags,omitempty"`    // Free-form labels for logging and attribution
+}
+
+type requestMetadataKey struct{}
+
+// WithRequestMetadata returns a context carrying request metadata
+func WithRequestMetadata(ctx context.Context, md RequestMetadata) context.Context {
+	return context.WithValue(ctx, requestMetadataKey{}, md)
+}
+
+// RequestMetadataFromContext returns the request metadata stored in ctx, if any
+func RequestMetadataFromContext(ctx context.Context) (RequestMetadata, bool) {
+	md, ok := ctx.Value(requestMetadataKey{}).(RequestMetadata)
+	return md, ok
+}
+
+type servedByKey struct{}
+
+// servedBy records which provider handled a request; middleware such as
+// fallback updates it when it switches providers
+type servedBy struct {
+	mu   sync.Mutex
+	name string
+}
+
+// withProvider returns a context recording name as the request's provider.
+// Each client call gets its own record.
+func withProvider(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, servedByKey{}, &servedBy{name: name})
+}
+
+// SetProviderName records the provider that handled the request. Middleware
+// that sends a request to a different provider than the client's, such as a
+// fallback, calls it so logging and usage tracking attribute the request
+// correctly.
+func SetProviderName(ctx context.Context, name string) {
+	if s, ok := ctx.Value(servedByKey{}).(*servedBy); ok {
+		s.mu.Lock()
+		s.name = name
+		s.mu.Unlock()
+	}
+}
+
+// ProviderName returns the name of the provider handling the request. Before
+// the next handler runs it is the client's provider; afterwards it is the
+// provider that produced the response.
+func ProviderName(ctx context.Context) string {
+	s, ok := ctx.Value(servedByKey{}).(*servedBy)
+	if !ok {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.name
+}
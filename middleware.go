@@ -5,11 +5,20 @@ import "context"
 // Handler is a function that processes a request and returns a response
 type Handler func(ctx context.Context, req *Request) (*Response, error)
 
+// StreamHandler is a function that processes a streaming request
+type StreamHandler func(ctx context.Context, req *Request) (<-chan StreamEvent, error)
+
 // Middleware wraps a handler to add functionality
 type Middleware interface {
 	Wrap(next Handler) Handler
 }
 
+// StreamMiddleware is implemented by middleware that also wraps streaming requests.
+// Middleware that does not implement it is skipped by Client.Stream.
+type StreamMiddleware interface {
+	WrapStream(next StreamHandler) StreamHandler
+}
+
 // MiddlewareFunc is a function that implements Middleware
 type MiddlewareFunc func(next Handler) Handler
 
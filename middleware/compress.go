@@ -0,0 +1,338 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/contextbuilder"
+	"github.com/medatechnology/simpleai/internal/lang"
+)
+
+// Compressor shortens text to about ratio of its length, keeping what the
+// model needs to answer
+type Compressor interface {
+	Compress(ctx context.Context, text string, ratio float64) (string, error)
+}
+
+// CompressorFunc adapts a function to the Compressor interface
+type CompressorFunc func(ctx context.Context, text string, ratio float64) (string, error)
+
+// Compress implements Compressor
+func (f CompressorFunc) Compress(ctx context.Context, text string, ratio float64) (string, error) {
+	return f(ctx, text, ratio)
+}
+
+// CompressConfig holds configuration for the prompt compression middleware
+type CompressConfig struct {
+	Ratio     float64 // Share of the words to keep (default 0.5)
+	MinTokens int     // Shorter texts are sent as is (default 500)
+
+	// Roles whose messages and documents are compressed (default user and
+	// tool). System prompts are left alone: instructions don't survive
+	// compression well.
+	Roles []simpleai.Role
+
+	// KeepLast sends the last message untouched, e.g. when it holds only
+	// the question and context comes earlier
+	KeepLast bool
+
+	Compressor   Compressor       // Default HeuristicCompressor
+	TokenCounter func(string) int // Default contextbuilder.EstimateTokens
+
+	// OnCompress is called with the prompt tokens before and after
+	// compression, for each request that was compressed
+	OnCompress func(before, after int)
+}
+
+// DefaultCompressConfig returns sensible defaults
+func DefaultCompressConfig() CompressConfig {
+	return CompressConfig{
+		Ratio:      0.5,
+		MinTokens:  500,
+		Roles:      []simpleai.Role{simpleai.RoleUser, simpleai.RoleTool},
+		Compressor: HeuristicCompressor(),
+	}
+}
+
+// compress implements both simpleai.Middleware and simpleai.StreamMiddleware
+type compress struct {
+	config CompressConfig
+}
+
+// Compress creates a middleware that removes low-information words from
+// long context before it is sent, to cut the cost of big RAG prompts.
+// Compression fails open: if the compressor errors, the text is sent as is.
+func Compress(config CompressConfig) simpleai.Middleware {
+	defaults := DefaultCompressConfig()
+	if config.Ratio <= 0 || config.Ratio >= 1 {
+		config.Ratio = defaults.Ratio
+	}
+	if config.MinTokens <= 0 {
+		config.MinTokens = defaults.MinTokens
+	}
+	if len(config.Roles) == 0 {
+		config.Roles = defaults.Roles
+	}
+	if config.Compressor == nil {
+		config.Compressor = defaults.Compressor
+	}
+	if config.TokenCounter == nil {
+		config.TokenCounter = contextbuilder.EstimateTokens
+	}
+	return &compress{config: config}
+}
+
+// CompressSimple creates a prompt compression middleware keeping about
+// ratio of the words of long user and tool messages
+func CompressSimple(ratio float64) simpleai.Middleware {
+	config := DefaultCompressConfig()
+	config.Ratio = ratio
+	return Compress(config)
+}
+
+// Wrap implements simpleai.Middleware
+func (c *compress) Wrap(next simpleai.Handler) simpleai.Handler {
+	return func(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+		return next(ctx, c.compress(ctx, req))
+	}
+}
+
+// WrapStream implements simpleai.StreamMiddleware
+func (c *compress) WrapStream(next simpleai.StreamHandler) simpleai.StreamHandler {
+	return func(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
+		return next(ctx, c.compress(ctx, req))
+	}
+}
+
+// compress returns a copy of req with long texts compressed
+func (c *compress) compress(ctx context.Context, req *simpleai.Request) *simpleai.Request {
+	before, after := 0, 0
+	shorten := func(text string) string {
+		tokens := c.config.TokenCounter(text)
+		if tokens < c.config.MinTokens {
+			return text
+		}
+		short, err := c.config.Compressor.Compress(ctx, text, c.config.Ratio)
+		if err != nil || short == "" {
+			return text
+		}
+		before += tokens
+		after += c.config.TokenCounter(short)
+		return short
+	}
+
+	r := *req
+	r.Messages = slices.Clone(req.Messages)
+	for i := range r.Messages {
+		msg := &r.Messages[i]
+		if !slices.Contains(c.config.Roles, msg.Role) || (c.config.KeepLast && i == len(r.Messages)-1) {
+			continue
+		}
+		msg.Content = shorten(msg.Content)
+		if len(msg.Documents) > 0 {
+			msg.Documents = slices.Clone(msg.Documents)
+			for j := range msg.Documents {
+				msg.Documents[j].Content = shorten(msg.Documents[j].Content)
+			}
+		}
+	}
+
+	if before == 0 {
+		return req
+	}
+	if c.config.OnCompress != nil {
+		c.config.OnCompress(before, after)
+	}
+	return &r
+}
+
+// fillerWords carry little information on their own
+var fillerWords = func() map[string]bool {
+	words := map[string]bool{}
+	for _, w := range strings.Fields(`a an the and or but so of to in on at by for from with as into onto
+		is are was were be been being am do does did has have had will would shall should can could may might must
+		it its this that these those there here then than also just very really quite rather such some any
+		which who whom whose what when where while whether about over under again further once
+		i me my we our you your he him his she her they them their
+		not no nor only own same too both each few more most other all`) {
+		words[w] = true
+	}
+	// Function words of the languages the language middleware knows
+	for _, list := range lang.Stopwords {
+		for _, w := range list {
+			words[w] = true
+		}
+	}
+	// Negations change the meaning
+	delete(words, "not")
+	delete(words, "no")
+	delete(words, "nor")
+	return words
+}()
+
+// HeuristicCompressor removes low-information words in the spirit of
+// LLMLingua, without a model. Repeated lines are dropped first; then words
+// are ranked by self-information within the text, so filler words and
+// frequent terms go before rare ones. Numbers, names, negations, questions,
+// headings and code blocks are kept.
+func HeuristicCompressor() Compressor {
+	return CompressorFunc(func(ctx context.Context, text string, ratio float64) (string, error) {
+		return compressText(text, ratio), nil
+	})
+}
+
+// compressWord is a word of the text with its importance
+type compressWord struct {
+	line  int
+	text  string
+	score float64
+}
+
+func compressText(text string, ratio float64) string {
+	lines := strings.Split(text, "\n")
+
+	// Lines that are kept whole, and repeated lines that are dropped
+	keep := make([]bool, len(lines))
+	drop := make([]bool, len(lines))
+	seen := map[string]bool{}
+	inCode := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			keep[i] = true
+			inCode = !inCode
+			continue
+		}
+		switch {
+		case inCode, trimmed == "", strings.HasPrefix(trimmed, "#"), strings.HasSuffix(trimmed, "?"):
+			keep[i] = true
+		case seen[trimmed]:
+			drop[i] = true
+		}
+		seen[trimmed] = true
+	}
+
+	// Word frequencies over the compressible lines
+	var words []compressWord
+	counts := map[string]int{}
+	for i, line := range lines {
+		if keep[i] || drop[i] {
+			continue
+		}
+		for _, w := range strings.Fields(line) {
+			words = append(words, compressWord{line: i, text: w})
+			counts[wordCore(w)]++
+		}
+	}
+	if len(words) == 0 {
+		return text
+	}
+
+	total := float64(len(words))
+	for i := range words {
+		words[i].score = wordScore(words[i].text, counts, total)
+	}
+
+	// Drop the least informative words, later ones first on ties
+	order := make([]int, len(words))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		if words[a].score != words[b].score {
+			if words[a].score < words[b].score {
+				return -1
+			}
+			return 1
+		}
+		return b - a
+	})
+	removed := make([]bool, len(words))
+	for _, i := range order[:len(words)-int(math.Ceil(total*ratio))] {
+		if math.IsInf(words[i].score, 1) {
+			break
+		}
+		removed[i] = true
+	}
+
+	// Rebuild the text line by line
+	kept := make([][]string, len(lines))
+	for i, w := range words {
+		if !removed[i] {
+			kept[w.line] = append(kept[w.line], w.text)
+			continue
+		}
+		// Keep sentence boundaries readable
+		if n := len(kept[w.line]); n > 0 && strings.ContainsAny(w.text[len(w.text)-1:], ".!?;:") && !endsWithPunct(kept[w.line][n-1]) {
+			kept[w.line][n-1] += w.text[len(w.text)-1:]
+		}
+	}
+	var out []string
+	for i, line := range lines {
+		switch {
+		case keep[i]:
+			out = append(out, line)
+		case len(kept[i]) > 0:
+			out = append(out, strings.Join(kept[i], " "))
+		}
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// wordScore is the self-information of a word within the text; +Inf marks
+// words that are never removed
+func wordScore(word string, counts map[string]int, total float64) float64 {
+	core := wordCore(word)
+	switch {
+	case core == "":
+		return 0 // Stray punctuation
+	case core == "not" || core == "no" || core == "nor" || strings.HasSuffix(core, "n't"):
+		return math.Inf(1)
+	case strings.IndexFunc(core, unicode.IsDigit) >= 0:
+		return math.Inf(1)
+	case fillerWords[core]:
+		return 0.1 * math.Log(total/float64(counts[core]))
+	}
+	score := math.Log(total/float64(counts[core])) + 1
+	if r := []rune(strings.TrimLeft(word, `"'([`)); len(r) > 0 && unicode.IsUpper(r[0]) {
+		score += 1 // Likely a name
+	}
+	return score
+}
+
+// wordCore lowercases a word without surrounding punctuation
+func wordCore(word string) string {
+	return strings.ToLower(strings.TrimFunc(word, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	}))
+}
+
+func endsWithPunct(word string) bool {
+	return word != "" && strings.ContainsAny(word[len(word)-1:], ".!?;:,")
+}
+
+// ModelCompressor asks a (small, cheap) model to compress text, for better
+// fidelity than the heuristic at the cost of a call
+func ModelCompressor(client *simpleai.Client) Compressor {
+	return CompressorFunc(func(ctx context.Context, text string, ratio float64) (string, error) {
+		words := int(float64(len(strings.Fields(text))) * ratio)
+		prompt := fmt.Sprintf(`Compress the text below to about %d words for another language model to read.
+Drop filler, repetition and formatting; keep every fact, number, name, date, quote, question and instruction.
+Telegraphic style is fine. Reply with the compressed text only.
+
+%s`, words, text)
+		resp, err := client.Complete(ctx, &simpleai.Request{
+			Messages:    []simpleai.Message{{Role: simpleai.RoleUser, Content: prompt}},
+			Temperature: 0.3,
+		})
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(resp.Content), nil
+	})
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// DedupConfig holds configuration for deduplication middleware
+type DedupConfig struct {
+	// KeyFunc computes the key identifying identical requests.
+	// Defaults to RequestHash.
+	KeyFunc func(req *simpleai.Request) string
+
+	// OnShared is called when a request joins an in-flight call instead of
+	// issuing its own
+	OnShared func(key string)
+}
+
+// inflightCall is a provider call shared by identical concurrent requests
+type inflightCall struct {
+	done chan struct{}
+	resp *simpleai.Response
+	err  error
+}
+
+// Dedup creates a middleware that coalesces identical concurrent requests.
+// The first request for a key calls the provider; identical requests that
+// arrive while it is in flight wait for and share its result.
+// The shared call is detached from the first caller's cancellation so one
+// caller leaving does not fail the others.
+func Dedup(config DedupConfig) simpleai.Middleware {
+	if config.KeyFunc == nil {
+		config.KeyFunc = RequestHash
+	}
+
+	var mu sync.Mutex
+	calls := make(map[string]*inflightCall)
+
+	return simpleai.MiddlewareFunc(func(next simpleai.Handler) simpleai.Handler {
+		return func(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+			key := config.KeyFunc(req)
+			if key == "" {
+				return next(ctx, req)
+			}
+
+			mu.Lock()
+			call, shared := calls[key]
+			if !shared {
+				call = &inflightCall{done: make(chan struct{})}
+				calls[key] = call
+				go func() {
+					call.resp, call.err = next(context.WithoutCancel(ctx), req)
+
+					mu.Lock()
+					delete(calls, key)
+					mu.Unlock()
+					close(call.done)
+				}()
+			}
+			mu.Unlock()
+
+			if shared && config.OnShared != nil {
+				config.OnShared(key)
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-call.done:
+			}
+
+			if call.err != nil {
+				return nil, call.err
+			}
+
+			// Each caller gets its own copy of the response
+			return call.resp.Clone(), nil
+		}
+	})
+}
+
+// DedupSimple creates a deduplication middleware with default settings
+func DedupSimple() simpleai.Middleware {
+	return Dedup(DedupConfig{})
+}
+
+// RequestHash returns a stable hash of the request's messages, model and
+// generation parameters
+func RequestHash(req *simpleai.Request) string {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
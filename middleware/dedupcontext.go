@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/contextbuilder"
+)
+
+// DedupContextConfig holds configuration for the context deduplication
+// middleware
+type DedupContextConfig struct {
+	Threshold float64 // Share of a paragraph already sent to drop it (default 0.8)
+	MinWords  int     // Shorter paragraphs are always kept (default 20)
+
+	// Placeholder replaces a message whose every paragraph was dropped, so
+	// tool results keep their call and turns keep alternating
+	Placeholder string
+
+	// OnDuplicate is called with each dropped paragraph
+	OnDuplicate func(paragraph string)
+}
+
+// DefaultDedupContextConfig returns sensible defaults
+func DefaultDedupContextConfig() DedupContextConfig {
+	return DedupContextConfig{
+		Threshold:   0.8,
+		MinWords:    20,
+		Placeholder: "(omitted: repeats earlier context)",
+	}
+}
+
+// dedupContext implements both simpleai.Middleware and
+// simpleai.StreamMiddleware
+type dedupContext struct {
+	config DedupContextConfig
+}
+
+// DedupContext creates a middleware that drops repeated context before it
+// is sent, such as a tool result pasted back by the user or a long answer
+// quoted in the next turn. Messages are compared paragraph by paragraph
+// with contextbuilder.Dedup. System prompts and the last message are always
+// sent whole; earlier history that repeats them or itself is dropped.
+// Documents are left alone so citations keep their indexes.
+func DedupContext(config DedupContextConfig) simpleai.Middleware {
+	defaults := DefaultDedupContextConfig()
+	if config.Threshold <= 0 || config.Threshold > 1 {
+		config.Threshold = defaults.Threshold
+	}
+	if config.MinWords <= 0 {
+		config.MinWords = defaults.MinWords
+	}
+	if config.Placeholder == "" {
+		config.Placeholder = defaults.Placeholder
+	}
+	return &dedupContext{config: config}
+}
+
+// DedupContextSimple creates a context deduplication middleware with
+// default settings
+func DedupContextSimple() simpleai.Middleware {
+	return DedupContext(DefaultDedupContextConfig())
+}
+
+// Wrap implements simpleai.Middleware
+func (d *dedupContext) Wrap(next simpleai.Handler) simpleai.Handler {
+	return func(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+		return next(ctx, d.dedup(req))
+	}
+}
+
+// WrapStream implements simpleai.StreamMiddleware
+func (d *dedupContext) WrapStream(next simpleai.StreamHandler) simpleai.StreamHandler {
+	return func(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
+		return next(ctx, d.dedup(req))
+	}
+}
+
+// dedup returns a copy of req without repeated paragraphs
+func (d *dedupContext) dedup(req *simpleai.Request) *simpleai.Request {
+	if len(req.Messages) < 2 {
+		return req
+	}
+	seen := contextbuilder.NewDedup(d.config.Threshold, d.config.MinWords)
+	last := len(req.Messages) - 1
+
+	// What is always sent whole
+	seen.Add(req.SystemPrompt)
+	seen.Add(req.Messages[last].Content)
+	for _, msg := range req.Messages[:last] {
+		if msg.Role == simpleai.RoleSystem {
+			seen.Add(msg.Content)
+		}
+	}
+
+	r := *req
+	changed := false
+	for i, msg := range req.Messages[:last] {
+		if msg.Role == simpleai.RoleSystem || msg.Content == "" {
+			continue
+		}
+		var kept []string
+		dropped := false
+		for _, paragraph := range strings.Split(msg.Content, "\n\n") {
+			if strings.TrimSpace(paragraph) != "" && seen.Duplicate(paragraph) {
+				dropped = true
+				if d.config.OnDuplicate != nil {
+					d.config.OnDuplicate(paragraph)
+				}
+				continue
+			}
+			kept = append(kept, paragraph)
+		}
+		if !dropped {
+			continue
+		}
+		if !changed {
+			r.Messages = slices.Clone(req.Messages)
+			changed = true
+		}
+		content := strings.TrimSpace(strings.Join(kept, "\n\n"))
+		if content == "" && len(msg.ToolCalls) == 0 {
+			content = d.config.Placeholder
+		}
+		r.Messages[i].Content = content
+	}
+
+	if !changed {
+		return req
+	}
+	return &r
+}
@@ -2,53 +2,202 @@ package middleware
 
 import (
 	"context"
+	"errors"
 
 	"github.com/medatechnology/simpleai"
 )
 
 // FallbackConfig holds configuration for fallback middleware
 type FallbackConfig struct {
-	Providers []simpleai.Provider // Fallback providers in order
+	Providers []simpleai.Provider              // Fallback providers in order
 	OnError   func(err error, provider string) // Optional callback on error
+
+	// Models maps a fallback provider name to the model it should use,
+	// since the primary's model name is usually meaningless to other providers
+	Models map[string]string
+
+	// AdaptRequest optionally rewrites the request for a fallback provider.
+	// It receives a copy of the original request and runs after Models is applied.
+	AdaptRequest func(provider simpleai.Provider, req *simpleai.Request) *simpleai.Request
+
+	// Degrade wraps the fallback providers with simpleai.Degrade, so tools
+	// and documents are emulated on providers that don't support them
+	Degrade bool
 }
 
-// Fallback creates a fallback middleware that tries alternative providers
+// fallback implements both simpleai.Middleware and simpleai.StreamMiddleware
+type fallback struct {
+	config FallbackConfig
+}
+
+// Fallback creates a fallback middleware that tries alternative providers.
+// It also wraps Client.Stream: if the primary stream fails before the first
+// token arrives, the next provider is tried.
 func Fallback(config FallbackConfig) simpleai.Middleware {
-	return simpleai.MiddlewareFunc(func(next simpleai.Handler) simpleai.Handler {
-		return func(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
-			// Try primary provider first
-			resp, err := next(ctx, req)
+	if config.Degrade {
+		providers := make([]simpleai.Provider, len(config.Providers))
+		for i, provider := range config.Providers {
+			providers[i] = simpleai.Degrade(provider)
+		}
+		config.Providers = providers
+	}
+	return &fallback{config: config}
+}
+
+// Wrap implements simpleai.Middleware
+func (f *fallback) Wrap(next simpleai.Handler) simpleai.Handler {
+	return func(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+		// Try primary provider first
+		resp, err := next(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		// A dry run shows the primary request; don't replace it
+		if isDryRun(err) {
+			return nil, err
+		}
+
+		// Report error if callback provided
+		f.reportError(err, "primary")
+
+		// Try fallback providers
+		for _, provider := range f.config.Providers {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			default:
+			}
+
+			resp, err = provider.Complete(ctx, f.adapt(provider, req))
 			if err == nil {
+				simpleai.SetProviderName(ctx, provider.Name())
 				return resp, nil
 			}
 
-			// Report error if callback provided
-			if config.OnError != nil {
-				config.OnError(err, "primary")
+			f.reportError(err, provider.Name())
+		}
+
+		// All providers failed
+		return nil, err
+	}
+}
+
+// WrapStream implements simpleai.StreamMiddleware
+func (f *fallback) WrapStream(next simpleai.StreamHandler) simpleai.StreamHandler {
+	return func(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
+		// Try primary provider first
+		stream, err := startStream(ctx, next, req)
+		if err == nil {
+			return stream, nil
+		}
+		// A dry run shows the primary request; don't replace it
+		if isDryRun(err) {
+			return nil, err
+		}
+
+		f.reportError(err, "primary")
+
+		// Try fallback providers
+		for _, provider := range f.config.Providers {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			default:
 			}
 
-			// Try fallback providers
-			for _, provider := range config.Providers {
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				default:
-				}
-
-				resp, err = provider.Complete(ctx, req)
-				if err == nil {
-					return resp, nil
-				}
-
-				if config.OnError != nil {
-					config.OnError(err, provider.Name())
-				}
+			stream, err = startStream(ctx, provider.Stream, f.adapt(provider, req))
+			if err == nil {
+				simpleai.SetProviderName(ctx, provider.Name())
+				return stream, nil
 			}
 
-			// All providers failed
-			return nil, err
+			f.reportError(err, provider.Name())
 		}
-	})
+
+		// All providers failed
+		return nil, err
+	}
+}
+
+// adapt returns the request to send to a fallback provider
+func (f *fallback) adapt(provider simpleai.Provider, req *simpleai.Request) *simpleai.Request {
+	if len(f.config.Models) == 0 && f.config.AdaptRequest == nil {
+		return req
+	}
+
+	adapted := *req
+	if model, ok := f.config.Models[provider.Name()]; ok {
+		adapted.Model = model
+	}
+	if f.config.AdaptRequest != nil {
+		return f.config.AdaptRequest(provider, &adapted)
+	}
+	return &adapted
+}
+
+func isDryRun(err error) bool {
+	var dry *simpleai.DryRun
+	return errors.As(err, &dry)
+}
+
+func (f *fallback) reportError(err error, provider string) {
+	if f.config.OnError != nil {
+		f.config.OnError(err, provider)
+	}
+}
+
+// startStream opens a stream and waits for its first event.
+// An error before the first token is returned as an error so the caller can
+// switch providers; once a token has arrived the stream is committed.
+func startStream(ctx context.Context, open simpleai.StreamHandler, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
+	stream, err := open(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var first simpleai.StreamEvent
+	select {
+	case <-ctx.Done():
+		go drain(stream)
+		return nil, ctx.Err()
+	case event, ok := <-stream:
+		if !ok {
+			return nil, simpleai.ErrStreamClosed
+		}
+		if event.Error != nil {
+			go drain(stream)
+			return nil, event.Error
+		}
+		first = event
+	}
+
+	out := make(chan simpleai.StreamEvent)
+	go func() {
+		defer close(out)
+		select {
+		case out <- first:
+		case <-ctx.Done():
+			drain(stream)
+			return
+		}
+		for event := range stream {
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				// The consumer may be gone
+				drain(stream)
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// drain consumes the remaining events so the provider goroutine can exit
+func drain(stream <-chan simpleai.StreamEvent) {
+	for range stream {
+	}
 }
 
 // FallbackSimple creates a fallback middleware with just providers
@@ -67,3 +216,12 @@ func FallbackWithLogging(logger func(msg string), providers ...simpleai.Provider
 		},
 	})
 }
+
+// FallbackWithModels creates a fallback middleware that sends each fallback
+// provider its own model name (keyed by provider name)
+func FallbackWithModels(models map[string]string, providers ...simpleai.Provider) simpleai.Middleware {
+	return Fallback(FallbackConfig{
+		Providers: providers,
+		Models:    models,
+	})
+}
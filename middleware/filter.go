@@ -0,0 +1,327 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// ErrBannedContent is returned when a response contains banned terms and
+// the filter rejects it
+var ErrBannedContent = errors.New("simpleai: response contains banned content")
+
+// FilterAction is what the output filter does with banned terms
+type FilterAction int
+
+const (
+	FilterMask       FilterAction = iota // Replace banned terms (default)
+	FilterReject                         // Fail with ErrBannedContent
+	FilterRegenerate                     // Ask the model again, then reject
+)
+
+// OutputFilterConfig holds configuration for the output filter middleware
+type OutputFilterConfig struct {
+	// Words are banned words and phrases, matched case-insensitively as
+	// whole words
+	Words []string
+	// Patterns are banned regular expressions
+	Patterns []*regexp.Regexp
+	// Find returns the byte ranges of banned content in text, like
+	// regexp.FindAllStringIndex
+	Find func(text string) [][]int
+
+	Action FilterAction
+
+	// Mask replaces each banned term; by default every letter becomes '*'
+	Mask string
+
+	// MaxRegenerations is the number of new attempts before rejecting
+	// (default 2)
+	MaxRegenerations int
+	// Instruction is added to the system prompt when regenerating
+	Instruction string
+
+	// Holdback is the number of bytes streams hold back so terms split
+	// across chunks are caught (default 64, at least the longest word)
+	Holdback int
+
+	// OnMatch is called with the banned terms found in a response
+	OnMatch func(terms []string)
+}
+
+// DefaultOutputFilterConfig returns sensible defaults
+func DefaultOutputFilterConfig() OutputFilterConfig {
+	return OutputFilterConfig{
+		Action:           FilterMask,
+		MaxRegenerations: 2,
+		Instruction:      "Do not use profanity or offensive language.",
+		Holdback:         64,
+	}
+}
+
+// outputFilter implements both simpleai.Middleware and simpleai.StreamMiddleware
+type outputFilter struct {
+	config OutputFilterConfig
+	words  *regexp.Regexp
+}
+
+// OutputFilter creates a middleware that filters banned terms out of
+// responses before they reach chat history or HTTP clients. Streams support
+// masking and rejection; FilterRegenerate rejects streams since their text
+// was already sent.
+func OutputFilter(config OutputFilterConfig) simpleai.Middleware {
+	defaults := DefaultOutputFilterConfig()
+	if config.MaxRegenerations <= 0 {
+		config.MaxRegenerations = defaults.MaxRegenerations
+	}
+	if config.Instruction == "" {
+		config.Instruction = defaults.Instruction
+	}
+	if config.Holdback <= 0 {
+		config.Holdback = defaults.Holdback
+	}
+
+	f := &outputFilter{config: config}
+	if len(config.Words) > 0 {
+		// Longest first so phrases win over the words they contain
+		words := make([]string, 0, len(config.Words))
+		for _, word := range config.Words {
+			if word = strings.TrimSpace(word); word != "" {
+				words = append(words, regexp.QuoteMeta(word))
+				f.config.Holdback = max(f.config.Holdback, len(word)+1)
+			}
+		}
+		sort.Slice(words, func(i, j int) bool { return len(words[i]) > len(words[j]) })
+		if len(words) > 0 {
+			f.words = regexp.MustCompile(`(?i)` + strings.Join(words, "|"))
+		}
+	}
+	return f
+}
+
+// OutputFilterSimple creates an output filter masking the given words
+func OutputFilterSimple(words ...string) simpleai.Middleware {
+	config := DefaultOutputFilterConfig()
+	config.Words = words
+	return OutputFilter(config)
+}
+
+// Wrap implements simpleai.Middleware
+func (f *outputFilter) Wrap(next simpleai.Handler) simpleai.Handler {
+	return func(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+		resp, err := next(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+
+		usage := simpleai.Usage{}
+		for attempt := 0; ; attempt++ {
+			ranges := f.find(resp.Content)
+			if len(ranges) == 0 {
+				break
+			}
+			f.matched(resp.Content, ranges)
+
+			if f.config.Action == FilterMask {
+				resp.Content = f.mask(resp.Content, ranges)
+				break
+			}
+			if f.config.Action == FilterReject || attempt >= f.config.MaxRegenerations {
+				return nil, ErrBannedContent
+			}
+
+			retry := *req
+			retry.SystemPrompt = strings.TrimSpace(req.SystemPrompt + "\n\n" + f.config.Instruction)
+			usage = addUsage(usage, resp.Usage)
+			if resp, err = next(ctx, &retry); err != nil {
+				return nil, err
+			}
+		}
+
+		resp.Usage = addUsage(usage, resp.Usage)
+		return resp, nil
+	}
+}
+
+// WrapStream implements simpleai.StreamMiddleware. The last Holdback bytes
+// are held until more text arrives, so output lags slightly.
+func (f *outputFilter) WrapStream(next simpleai.StreamHandler) simpleai.StreamHandler {
+	return func(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
+		stream, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		out := make(chan simpleai.StreamEvent)
+		go func() {
+			defer close(out)
+
+			var pending string
+			for event := range stream {
+				final := event.Done || event.Error != nil
+				text, err := f.flush(&pending, event.Content, final)
+				if err != nil {
+					out <- simpleai.StreamEvent{Error: err, Done: true}
+					go func() {
+						for range stream {
+						}
+					}()
+					return
+				}
+
+				if text == "" && event.Content != "" && !final {
+					continue // Held back
+				}
+				event.Content = text
+				out <- event
+				if final {
+					return
+				}
+			}
+
+			// The stream ended without a final event
+			if text, err := f.flush(&pending, "", true); err != nil {
+				out <- simpleai.StreamEvent{Error: err, Done: true}
+			} else if text != "" {
+				out <- simpleai.StreamEvent{Content: text}
+			}
+		}()
+		return out, nil
+	}
+}
+
+// flush adds content to pending and returns the filtered text that can be
+// sent. Unless final, it keeps the tail where a banned term may still be
+// arriving.
+func (f *outputFilter) flush(pending *string, content string, final bool) (string, error) {
+	text := *pending + content
+	cut := len(text)
+	if !final {
+		cut = f.cut(text)
+	}
+
+	var ranges [][]int
+	for _, r := range f.find(text) {
+		if final || r[0] < cut {
+			ranges = append(ranges, r)
+			cut = max(cut, r[1])
+		}
+	}
+	if len(ranges) > 0 {
+		f.matched(text, ranges)
+		if f.config.Action != FilterMask {
+			return "", ErrBannedContent
+		}
+	}
+
+	*pending = text[cut:]
+	return f.mask(text[:cut], ranges), nil
+}
+
+// cut returns where text can be split, leaving at least Holdback bytes and
+// preferring a word boundary
+func (f *outputFilter) cut(text string) int {
+	cut := len(text) - f.config.Holdback
+	if cut <= 0 {
+		return 0
+	}
+	if i := strings.LastIndexFunc(text[:cut], unicode.IsSpace); i >= 0 {
+		return i + 1
+	}
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return cut
+}
+
+// find returns the sorted, merged byte ranges of banned content in text
+func (f *outputFilter) find(text string) [][]int {
+	var ranges [][]int
+	if f.words != nil {
+		for _, r := range f.words.FindAllStringIndex(text, -1) {
+			if wordBoundary(text, r[0], r[1]) {
+				ranges = append(ranges, r)
+			}
+		}
+	}
+	for _, pattern := range f.config.Patterns {
+		ranges = append(ranges, pattern.FindAllStringIndex(text, -1)...)
+	}
+	if f.config.Find != nil {
+		ranges = append(ranges, f.config.Find(text)...)
+	}
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	merged := [][]int{ranges[0]}
+	for _, r := range ranges[1:] {
+		last := merged[len(merged)-1]
+		if r[0] <= last[1] {
+			last[1] = max(last[1], r[1])
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// wordBoundary reports whether text[start:end] is not part of a longer word
+func wordBoundary(text string, start, end int) bool {
+	if before, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && isWordRune(before) {
+		return false
+	}
+	if after, _ := utf8.DecodeRuneInString(text[end:]); end < len(text) && isWordRune(after) {
+		return false
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// mask replaces the ranges of text
+func (f *outputFilter) mask(text string, ranges [][]int) string {
+	if len(ranges) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, r := range ranges {
+		b.WriteString(text[last:r[0]])
+		if f.config.Mask != "" {
+			b.WriteString(f.config.Mask)
+		} else {
+			for _, c := range text[r[0]:r[1]] {
+				if unicode.IsSpace(c) {
+					b.WriteRune(c)
+				} else {
+					b.WriteByte('*')
+				}
+			}
+		}
+		last = r[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func (f *outputFilter) matched(text string, ranges [][]int) {
+	if f.config.OnMatch == nil {
+		return
+	}
+	terms := make([]string, len(ranges))
+	for i, r := range ranges {
+		terms[i] = text[r[0]:r[1]]
+	}
+	f.config.OnMatch(terms)
+}
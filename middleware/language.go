@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/internal/lang"
+)
+
+// ErrLanguageMismatch is returned in strict mode when the response is still
+// in the wrong language after all re-prompts
+var ErrLanguageMismatch = errors.New("simpleai: response is not in the required language")
+
+// LanguageConfig holds configuration for the language enforcement middleware
+type LanguageConfig struct {
+	// Language is the required ISO 639-1 code (e.g. "de"; "pt-BR" is
+	// treated as "pt"). WithResponseLanguage overrides it per request; with
+	// neither, responses pass through.
+	Language string
+
+	// MaxRetries is the number of re-prompts after a mismatch (default 1)
+	MaxRetries int
+
+	// Detect returns the language code of text, or "" when unsure.
+	// Defaults to DetectLanguage.
+	Detect func(text string) string
+
+	// Instruction builds the re-prompt for a language name
+	Instruction func(language string) string
+
+	// Strict fails with ErrLanguageMismatch when retries are exhausted;
+	// otherwise the last response is returned
+	Strict bool
+
+	// OnMismatch is called on every mismatch
+	OnMismatch func(want, got string)
+}
+
+// DefaultLanguageConfig returns sensible defaults
+func DefaultLanguageConfig() LanguageConfig {
+	return LanguageConfig{
+		MaxRetries: 1,
+		Detect:     DetectLanguage,
+		Instruction: func(language string) string {
+			return fmt.Sprintf("Your previous answer was not in %s. Rewrite it in %s with the same content, and answer only in %s from now on.",
+				language, language, language)
+		},
+	}
+}
+
+type languageKey struct{}
+
+// WithResponseLanguage returns a context carrying the language a response
+// must be in, overriding LanguageConfig.Language
+func WithResponseLanguage(ctx context.Context, language string) context.Context {
+	return context.WithValue(ctx, languageKey{}, language)
+}
+
+// Language creates a middleware that detects the language of each response
+// and re-prompts the model when it doesn't match the required language.
+// Small models often drift to English (or to the language of the context
+// documents) with multilingual users. Streams are not checked.
+func Language(config LanguageConfig) simpleai.Middleware {
+	defaults := DefaultLanguageConfig()
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaults.MaxRetries
+	}
+	if config.Detect == nil {
+		config.Detect = defaults.Detect
+	}
+	if config.Instruction == nil {
+		config.Instruction = defaults.Instruction
+	}
+
+	return simpleai.MiddlewareFunc(func(next simpleai.Handler) simpleai.Handler {
+		return func(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+			want := config.Language
+			if code, ok := ctx.Value(languageKey{}).(string); ok && code != "" {
+				want = code
+			}
+			want = lang.Base(want)
+
+			resp, err := next(ctx, req)
+			if err != nil || want == "" {
+				return resp, err
+			}
+
+			usage := simpleai.Usage{}
+			for attempt := 0; ; attempt++ {
+				got := config.Detect(resp.Content)
+				if got == "" || got == want {
+					break
+				}
+
+				if config.OnMismatch != nil {
+					config.OnMismatch(want, got)
+				}
+				if attempt >= config.MaxRetries {
+					if config.Strict {
+						return nil, fmt.Errorf("%w: want %s, got %s", ErrLanguageMismatch, want, got)
+					}
+					break
+				}
+
+				// Show the model its answer and ask for a rewrite
+				retry := *req
+				retry.Messages = append(append([]simpleai.Message{}, req.Messages...),
+					simpleai.Message{Role: simpleai.RoleAssistant, Content: resp.Content},
+					simpleai.Message{Role: simpleai.RoleUser, Content: config.Instruction(LanguageName(want))},
+				)
+				retry.AssistantPrefix = ""
+
+				usage = addUsage(usage, resp.Usage)
+				retried, err := next(ctx, &retry)
+				if err != nil {
+					return nil, err
+				}
+				resp = retried
+			}
+
+			resp.Usage = addUsage(usage, resp.Usage)
+			return resp, nil
+		}
+	})
+}
+
+// LanguageSimple creates a language enforcement middleware with default
+// settings for one language
+func LanguageSimple(language string) simpleai.Middleware {
+	config := DefaultLanguageConfig()
+	config.Language = language
+	return Language(config)
+}
+
+func addUsage(a, b simpleai.Usage) simpleai.Usage {
+	return simpleai.Usage{
+		PromptTokens:     a.PromptTokens + b.PromptTokens,
+		CompletionTokens: a.CompletionTokens + b.CompletionTokens,
+		TotalTokens:      a.TotalTokens + b.TotalTokens,
+	}
+}
+
+// LanguageName returns the English name of a language code, or the code
+// itself when unknown
+func LanguageName(code string) string {
+	return lang.Name(code)
+}
+
+// DetectLanguage returns the ISO 639-1 code of the language text is
+// written in, or "" when it is too short or ambiguous. It recognizes
+// languages by script (Chinese, Japanese, Korean, Russian, Ukrainian,
+// Arabic, Hebrew, Greek, Thai, Hindi) and by common words (English,
+// Spanish, French, German, Italian, Portuguese, Dutch, Indonesian).
+func DetectLanguage(text string) string {
+	return lang.Detect(text)
+}
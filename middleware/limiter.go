@@ -0,0 +1,242 @@
+package middleware
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// Limiter errors
+var (
+	ErrQueueFull    = errors.New("simpleai: request queue is full")
+	ErrQueueTimeout = errors.New("simpleai: timed out waiting in request queue")
+)
+
+// Request priorities (higher runs first)
+const (
+	PriorityLow    = -10
+	PriorityNormal = 0
+	PriorityHigh   = 10
+)
+
+// LimiterConfig holds configuration for the concurrency limiter middleware
+type LimiterConfig struct {
+	MaxConcurrent int           // Maximum in-flight provider calls
+	MaxQueueSize  int           // Maximum waiting requests (0 = unlimited)
+	MaxQueueTime  time.Duration // Default maximum time a request may wait (0 = no limit)
+}
+
+// DefaultLimiterConfig returns sensible defaults
+func DefaultLimiterConfig() LimiterConfig {
+	return LimiterConfig{
+		MaxConcurrent: 10,
+		MaxQueueSize:  100,
+		MaxQueueTime:  30 * time.Second,
+	}
+}
+
+type priorityKey struct{}
+type queueTimeKey struct{}
+
+// WithPriority returns a context carrying the request priority for the limiter
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// WithMaxQueueTime returns a context carrying a per-request maximum queue time,
+// overriding LimiterConfig.MaxQueueTime
+func WithMaxQueueTime(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, queueTimeKey{}, d)
+}
+
+// limiter implements both simpleai.Middleware and simpleai.StreamMiddleware
+type limiter struct {
+	config  LimiterConfig
+	mu      sync.Mutex
+	active  int
+	seq     uint64
+	waiting waitQueue
+}
+
+// Limiter creates a middleware that caps concurrent provider calls.
+// Excess requests wait in a priority queue (see WithPriority); streams hold
+// their slot until the stream channel is closed.
+func Limiter(config LimiterConfig) simpleai.Middleware {
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = 1
+	}
+	return &limiter{config: config}
+}
+
+// LimiterSimple creates a limiter with the given concurrency and default queueing
+func LimiterSimple(maxConcurrent int) simpleai.Middleware {
+	config := DefaultLimiterConfig()
+	config.MaxConcurrent = maxConcurrent
+	return Limiter(config)
+}
+
+// Wrap implements simpleai.Middleware
+func (l *limiter) Wrap(next simpleai.Handler) simpleai.Handler {
+	return func(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+		if err := l.acquire(ctx); err != nil {
+			return nil, err
+		}
+		defer l.release()
+
+		return next(ctx, req)
+	}
+}
+
+// WrapStream implements simpleai.StreamMiddleware
+func (l *limiter) WrapStream(next simpleai.StreamHandler) simpleai.StreamHandler {
+	return func(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
+		if err := l.acquire(ctx); err != nil {
+			return nil, err
+		}
+
+		stream, err := next(ctx, req)
+		if err != nil {
+			l.release()
+			return nil, err
+		}
+
+		out := make(chan simpleai.StreamEvent)
+		go func() {
+			defer close(out)
+			defer l.release()
+			for event := range stream {
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					// The consumer may be gone; drain so the provider can
+					// exit and the slot is released
+					for range stream {
+					}
+					return
+				}
+			}
+		}()
+
+		return out, nil
+	}
+}
+
+// acquire takes a slot, waiting in the queue if none is free
+func (l *limiter) acquire(ctx context.Context) error {
+	l.mu.Lock()
+	if l.active < l.config.MaxConcurrent && l.waiting.Len() == 0 {
+		l.active++
+		l.mu.Unlock()
+		return nil
+	}
+	if l.config.MaxQueueSize > 0 && l.waiting.Len() >= l.config.MaxQueueSize {
+		l.mu.Unlock()
+		return ErrQueueFull
+	}
+
+	priority := PriorityNormal
+	if p, ok := ctx.Value(priorityKey{}).(int); ok {
+		priority = p
+	}
+	l.seq++
+	w := &waiter{
+		ready:    make(chan struct{}),
+		priority: priority,
+		seq:      l.seq,
+	}
+	heap.Push(&l.waiting, w)
+	l.mu.Unlock()
+
+	maxWait := l.config.MaxQueueTime
+	if d, ok := ctx.Value(queueTimeKey{}).(time.Duration); ok {
+		maxWait = d
+	}
+	var timeout <-chan time.Time
+	if maxWait > 0 {
+		timer := time.NewTimer(maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = ErrQueueTimeout
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.index < 0 {
+		// Slot was granted while we were giving up; hand it on
+		l.releaseLocked()
+	} else {
+		heap.Remove(&l.waiting, w.index)
+	}
+	return err
+}
+
+// release frees a slot, handing it to the highest-priority waiter if any
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.releaseLocked()
+}
+
+func (l *limiter) releaseLocked() {
+	if l.waiting.Len() > 0 {
+		w := heap.Pop(&l.waiting).(*waiter)
+		close(w.ready)
+		return
+	}
+	l.active--
+}
+
+// waiter is a queued request
+type waiter struct {
+	ready    chan struct{}
+	priority int
+	seq      uint64
+	index    int
+}
+
+// waitQueue is a heap ordered by priority, then arrival
+type waitQueue []*waiter
+
+func (q waitQueue) Len() int { return len(q) }
+
+func (q waitQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waitQueue) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waitQueue) Pop() any {
+	old := *q
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*q = old[:n-1]
+	return w
+}
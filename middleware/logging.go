@@ -2,59 +2,188 @@ package middleware
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
 	"time"
+	"unicode/utf8"
 
 	"github.com/medatechnology/goutil/simplelog"
 	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/internal/pii"
 )
 
 // LogEntry represents a log entry for AI requests
 type LogEntry struct {
 	Timestamp    time.Time
-	Provider     string
+	Provider     string // Provider that handled the request, after any fallback
 	Model        string
 	Duration     time.Duration
 	InputTokens  int
 	OutputTokens int
 	Error        error
+	Metadata     simpleai.RequestMetadata // From simpleai.WithRequestMetadata, if set
+	RequestID    string                   // Metadata.TraceID, or a random ID
+	Transfer     simpleai.Transfer        // HTTP payload sizes, when the provider reports them
+
+	// Previews of the last message and the reply, set with
+	// LoggingConfig.LogRequest and redacted per LoggingConfig.Redaction
+	Prompt   string
+	Response string
+
+	// Streams only
+	Stream           bool
+	TimeToFirstToken time.Duration
+	TokensPerSecond  float64 // Output rate after the first token
 }
 
 // Logger is a function that receives log entries
 type Logger func(entry LogEntry)
 
+// Redaction decides how much prompt and response text reaches the logs
+type Redaction int
+
+const (
+	// RedactPII keeps previews with personal data such as emails and phone
+	// numbers masked (default)
+	RedactPII Redaction = iota
+	// RedactAll replaces previews with their length
+	RedactAll
+	// RedactNone keeps previews as they are
+	RedactNone
+)
+
 // LoggingConfig holds configuration for logging middleware
 type LoggingConfig struct {
 	Logger     Logger
-	LogRequest bool // Log request details (can be verbose)
+	LogRequest bool // Log prompt and response previews (can be verbose)
+
+	Redaction     Redaction
+	PreviewLength int                 // Characters kept per preview (default 200)
+	Scrub         func(string) string // Masks personal data for RedactPII (default: emails, card numbers, SSNs, phone numbers, IPs)
 }
 
-// Logging creates a logging middleware
+// Logging creates a logging middleware. Streams are logged when they end,
+// with time to first token and output rate.
 func Logging(config LoggingConfig) simpleai.Middleware {
-	return simpleai.MiddlewareFunc(func(next simpleai.Handler) simpleai.Handler {
-		return func(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
-			start := time.Now()
+	if config.PreviewLength <= 0 {
+		config.PreviewLength = 200
+	}
+	if config.Scrub == nil {
+		config.Scrub = pii.Scrub
+	}
+	return &logging{config: config}
+}
 
-			resp, err := next(ctx, req)
+// logging implements both simpleai.Middleware and simpleai.StreamMiddleware
+type logging struct {
+	config LoggingConfig
+}
 
-			entry := LogEntry{
-				Timestamp: start,
-				Model:     req.Model,
-				Duration:  time.Since(start),
-				Error:     err,
-			}
+// Wrap implements simpleai.Middleware
+func (l *logging) Wrap(next simpleai.Handler) simpleai.Handler {
+	return func(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+		start := time.Now()
 
-			if resp != nil {
-				entry.InputTokens = resp.Usage.PromptTokens
-				entry.OutputTokens = resp.Usage.CompletionTokens
-			}
+		resp, err := next(ctx, req)
 
-			if config.Logger != nil {
-				config.Logger(entry)
-			}
+		entry := l.newEntry(ctx, req, start)
+		entry.Provider = simpleai.ProviderName(ctx)
+		entry.Transfer, _ = simpleai.TransferFromContext(ctx)
+		entry.Duration = time.Since(start)
+		entry.Error = err
 
-			return resp, err
+		if resp != nil {
+			entry.InputTokens = resp.Usage.PromptTokens
+			entry.OutputTokens = resp.Usage.CompletionTokens
+			entry.Response = l.preview(resp.Content)
 		}
-	})
+
+		l.log(entry)
+		return resp, err
+	}
+}
+
+// WrapStream implements simpleai.StreamMiddleware
+func (l *logging) WrapStream(next simpleai.StreamHandler) simpleai.StreamHandler {
+	return func(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
+		start := time.Now()
+		entry := l.newEntry(ctx, req, start)
+		entry.Stream = true
+
+		stream, err := next(ctx, req)
+		entry.Provider = simpleai.ProviderName(ctx)
+		if err != nil {
+			entry.Duration = time.Since(start)
+			entry.Error = err
+			l.log(entry)
+			return nil, err
+		}
+
+		return simpleai.TraceStream(ctx, stream, start, nil, func(t simpleai.StreamTiming) {
+			entry.Duration = t.Duration()
+			entry.Error = t.Err
+			entry.Transfer, _ = simpleai.TransferFromContext(ctx)
+			entry.OutputTokens = t.OutputTokens
+			if t.Usage != nil {
+				entry.InputTokens = t.Usage.PromptTokens
+			}
+			entry.TimeToFirstToken = t.TimeToFirstToken()
+			entry.TokensPerSecond = t.TokensPerSecond()
+			entry.Response = l.preview(t.Content)
+			l.log(entry)
+		}), nil
+	}
+}
+
+// newEntry starts an entry with the request's identity and prompt preview
+func (l *logging) newEntry(ctx context.Context, req *simpleai.Request, start time.Time) LogEntry {
+	entry := LogEntry{Timestamp: start, Model: req.Model}
+	entry.Metadata, _ = simpleai.RequestMetadataFromContext(ctx)
+	entry.RequestID = entry.Metadata.TraceID
+	if entry.RequestID == "" {
+		entry.RequestID = newLogID()
+	}
+	if n := len(req.Messages); n > 0 {
+		entry.Prompt = l.preview(req.Messages[n-1].Content)
+	}
+	return entry
+}
+
+// preview truncates and redacts text, or returns "" unless LogRequest is set
+func (l *logging) preview(text string) string {
+	if !l.config.LogRequest || text == "" {
+		return ""
+	}
+	switch l.config.Redaction {
+	case RedactAll:
+		return fmt.Sprintf("[redacted %d chars]", utf8.RuneCountInString(text))
+	case RedactPII:
+		text = l.config.Scrub(text)
+	}
+	if runes := []rune(text); len(runes) > l.config.PreviewLength {
+		text = string(runes[:l.config.PreviewLength]) + "…"
+	}
+	return text
+}
+
+func (l *logging) log(entry LogEntry) {
+	if l.config.Logger != nil {
+		l.config.Logger(entry)
+	}
+}
+
+// summary describes a successful request for the simple loggers
+func (e LogEntry) summary() string {
+	if !e.Stream {
+		return "AI request completed in " + e.Duration.String()
+	}
+	return fmt.Sprintf("AI stream completed in %s (first token after %s, %.1f tokens/s)",
+		e.Duration, e.TimeToFirstToken, e.TokensPerSecond)
 }
 
 // SimpleLogger creates a logging middleware with a simple log function
@@ -64,7 +193,7 @@ func SimpleLogger(logFn func(msg string)) simpleai.Middleware {
 			if entry.Error != nil {
 				logFn("AI request failed: " + entry.Error.Error())
 			} else {
-				logFn("AI request completed in " + entry.Duration.String())
+				logFn(entry.summary())
 			}
 		},
 	})
@@ -77,10 +206,81 @@ func GoutilLogger(debugLevel int) simpleai.Middleware {
 			if entry.Error != nil {
 				simplelog.LogErr(entry.Error, "AI request failed")
 			} else {
-				simplelog.LogInfoStr("SimpleAI", debugLevel,
-					"Request completed in "+entry.Duration.String(),
-				)
+				simplelog.LogInfoStr("SimpleAI", debugLevel, entry.summary())
 			}
 		},
 	})
 }
+
+// Attrs returns the entry as structured log attributes; empty fields are
+// left out
+func (e LogEntry) Attrs() []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("request_id", e.RequestID),
+		slog.Duration("duration", e.Duration),
+		slog.Int("input_tokens", e.InputTokens),
+		slog.Int("output_tokens", e.OutputTokens),
+	}
+	optional := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, slog.String(key, value))
+		}
+	}
+	optional("provider", e.Provider)
+	optional("model", e.Model)
+	optional("user_id", e.Metadata.UserID)
+	optional("session_id", e.Metadata.SessionID)
+	if len(e.Metadata.Tags) > 0 {
+		tags := make([]any, 0, len(e.Metadata.Tags))
+		for _, k := range slices.Sorted(maps.Keys(e.Metadata.Tags)) {
+			tags = append(tags, slog.String(k, e.Metadata.Tags[k]))
+		}
+		attrs = append(attrs, slog.Group("tags", tags...))
+	}
+	if t := e.Transfer; t != (simpleai.Transfer{}) {
+		attrs = append(attrs,
+			slog.Int64("request_bytes", t.RequestBytes),
+			slog.Int64("sent_bytes", t.SentBytes),
+			slog.Int64("response_bytes", t.ResponseBytes),
+		)
+	}
+	if e.Stream {
+		attrs = append(attrs,
+			slog.Bool("stream", true),
+			slog.Duration("ttft", e.TimeToFirstToken),
+			slog.Float64("tokens_per_second", e.TokensPerSecond),
+		)
+	}
+	optional("prompt", e.Prompt)
+	optional("response", e.Response)
+	if e.Error != nil {
+		attrs = append(attrs, slog.String("error", e.Error.Error()))
+	}
+	return attrs
+}
+
+// SlogLogger writes entries to logger, at Error level for failed requests
+// and Info otherwise. Any slog.Handler works, e.g. one shipping to an
+// observability backend.
+func SlogLogger(logger *slog.Logger) Logger {
+	return func(entry LogEntry) {
+		level, msg := slog.LevelInfo, "ai request"
+		if entry.Error != nil {
+			level, msg = slog.LevelError, "ai request failed"
+		}
+		logger.LogAttrs(context.Background(), level, msg, entry.Attrs()...)
+	}
+}
+
+// JSONLogger creates a logging middleware writing one JSON object per
+// request to w. config.Logger is replaced.
+func JSONLogger(w io.Writer, config LoggingConfig) simpleai.Middleware {
+	config.Logger = SlogLogger(slog.New(slog.NewJSONHandler(w, nil)))
+	return Logging(config)
+}
+
+func newLogID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "req_" + hex.EncodeToString(b)
+}
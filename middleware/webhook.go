@@ -0,0 +1,262 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// Webhook event types
+const (
+	WebhookEventCompletion = "completion"
+	WebhookEventError      = "error"
+)
+
+// Webhook signature headers
+const (
+	WebhookSignatureHeader = "X-SimpleAI-Signature" // "sha256=<hex HMAC of timestamp.body>"
+	WebhookTimestampHeader = "X-SimpleAI-Timestamp" // Unix seconds, part of the signed payload
+)
+
+// WebhookEvent is the JSON payload posted to webhook URLs
+type WebhookEvent struct {
+	Type         string                   `json:"type"` // completion or error
+	Timestamp    time.Time                `json:"timestamp"`
+	Model        string                   `json:"model,omitempty"`
+	Stream       bool                     `json:"stream,omitempty"`
+	DurationMs   int64                    `json:"duration_ms"`
+	Usage        simpleai.Usage           `json:"usage"`
+	FinishReason string                   `json:"finish_reason,omitempty"`
+	Content      string                   `json:"content,omitempty"` // Only with WebhookConfig.IncludeContent
+	Error        string                   `json:"error,omitempty"`
+	Metadata     simpleai.RequestMetadata `json:"metadata,omitempty"`
+}
+
+// WebhookConfig holds configuration for the webhook middleware
+type WebhookConfig struct {
+	URLs   []string
+	Secret string // HMAC-SHA256 signing key; unsigned when empty
+
+	// ErrorsOnly posts only failures
+	ErrorsOnly bool
+
+	// IncludeContent adds the response text to completion events
+	IncludeContent bool
+
+	MaxAttempts  int           // Delivery attempts per URL (default 3)
+	InitialDelay time.Duration // Delay before the first retry, doubled each time (default 1s)
+	Timeout      time.Duration // Per-attempt HTTP timeout (default 10s)
+
+	// OnDeliveryError is called when a URL still fails after all attempts
+	OnDeliveryError func(url string, event WebhookEvent, err error)
+}
+
+// DefaultWebhookConfig returns sensible defaults
+func DefaultWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		MaxAttempts:  3,
+		InitialDelay: 1 * time.Second,
+		Timeout:      10 * time.Second,
+	}
+}
+
+// webhook implements both simpleai.Middleware and simpleai.StreamMiddleware
+type webhook struct {
+	config WebhookConfig
+	client *http.Client
+}
+
+// Webhook creates a middleware that posts completion and error events to
+// webhook URLs. Delivery happens in the background with retries and never
+// delays or fails the request.
+func Webhook(config WebhookConfig) simpleai.Middleware {
+	defaults := DefaultWebhookConfig()
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.InitialDelay <= 0 {
+		config.InitialDelay = defaults.InitialDelay
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	return &webhook{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+// Wrap implements simpleai.Middleware
+func (w *webhook) Wrap(next simpleai.Handler) simpleai.Handler {
+	return func(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+		start := time.Now()
+		resp, err := next(ctx, req)
+
+		event := w.event(ctx, req, start, err)
+		if resp != nil {
+			event.Model = resp.Model
+			event.Usage = resp.Usage
+			event.FinishReason = resp.FinishReason
+			if w.config.IncludeContent {
+				event.Content = resp.Content
+			}
+		}
+		w.send(event)
+
+		return resp, err
+	}
+}
+
+// WrapStream implements simpleai.StreamMiddleware; the event is sent when
+// the stream ends
+func (w *webhook) WrapStream(next simpleai.StreamHandler) simpleai.StreamHandler {
+	return func(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
+		start := time.Now()
+		stream, err := next(ctx, req)
+		if err != nil {
+			w.send(w.event(ctx, req, start, err))
+			return nil, err
+		}
+
+		out := make(chan simpleai.StreamEvent)
+		go func() {
+			defer close(out)
+
+			var content strings.Builder
+			var streamErr error
+			var finishReason string
+			for event := range stream {
+				content.WriteString(event.Content)
+				if event.Error != nil {
+					streamErr = event.Error
+				}
+				if event.FinishReason != "" {
+					finishReason = event.FinishReason
+				}
+				out <- event
+			}
+
+			event := w.event(ctx, req, start, streamErr)
+			event.Stream = true
+			event.FinishReason = finishReason
+			if w.config.IncludeContent {
+				event.Content = content.String()
+			}
+			w.send(event)
+		}()
+
+		return out, nil
+	}
+}
+
+func (w *webhook) event(ctx context.Context, req *simpleai.Request, start time.Time, err error) WebhookEvent {
+	event := WebhookEvent{
+		Type:       WebhookEventCompletion,
+		Timestamp:  start,
+		Model:      req.Model,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		event.Type = WebhookEventError
+		event.Error = err.Error()
+	}
+	event.Metadata, _ = simpleai.RequestMetadataFromContext(ctx)
+	event.Metadata.Headers = nil // May contain provider credentials
+	return event
+}
+
+// send delivers the event to every URL in the background
+func (w *webhook) send(event WebhookEvent) {
+	if len(w.config.URLs) == 0 || (w.config.ErrorsOnly && event.Type != WebhookEventError) {
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	for _, url := range w.config.URLs {
+		go func(url string) {
+			if err := w.deliver(url, body); err != nil && w.config.OnDeliveryError != nil {
+				w.config.OnDeliveryError(url, event, err)
+			}
+		}(url)
+	}
+}
+
+func (w *webhook) deliver(url string, body []byte) error {
+	delay := w.config.InitialDelay
+	var lastErr error
+
+	for attempt := 1; attempt <= w.config.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if w.config.Secret != "" {
+			ts := strconv.FormatInt(time.Now().Unix(), 10)
+			req.Header.Set(WebhookTimestampHeader, ts)
+			req.Header.Set(WebhookSignatureHeader, SignWebhook(w.config.Secret, ts, body))
+		}
+
+		resp, err := w.client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("webhook returned status %d", resp.StatusCode)
+		// Client errors other than rate limiting won't succeed on retry
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return lastErr
+		}
+	}
+
+	return lastErr
+}
+
+// SignWebhook returns the signature header value for a payload:
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body))
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks a received webhook's signature and rejects
+// timestamps older than maxAge (0 disables the age check)
+func VerifyWebhook(secret string, header http.Header, body []byte, maxAge time.Duration) bool {
+	ts := header.Get(WebhookTimestampHeader)
+	if ts == "" {
+		return false
+	}
+	if maxAge > 0 {
+		sec, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil || time.Since(time.Unix(sec, 0)) > maxAge {
+			return false
+		}
+	}
+	expected := SignWebhook(secret, ts, body)
+	return hmac.Equal([]byte(expected), []byte(header.Get(WebhookSignatureHeader)))
+}
@@ -0,0 +1,131 @@
+package simpleai
+
+import (
+	"cmp"
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// fallbackMaxTokens is the default MaxTokens for models missing from the
+// catalog
+const fallbackMaxTokens = 4096
+
+// ModelInfo describes the token limits of a model
+type ModelInfo struct {
+	ContextWindow   int // Prompt and output tokens combined
+	MaxOutputTokens int // Largest allowed MaxTokens (0 = limited by the context window only)
+}
+
+// ModelNamer is implemented by providers that report their default model
+type ModelNamer interface {
+	Model() string
+}
+
+var (
+	modelsMu sync.RWMutex
+	// models maps model names, or name prefixes covering dated versions, to
+	// their limits
+	models = map[string]ModelInfo{
+		// Anthropic
+		"claude-3-haiku":    {ContextWindow: 200000, MaxOutputTokens: 4096},
+		"claude-3-opus":     {ContextWindow: 200000, MaxOutputTokens: 4096},
+		"claude-3-5-haiku":  {ContextWindow: 200000, MaxOutputTokens: 8192},
+		"claude-3-5-sonnet": {ContextWindow: 200000, MaxOutputTokens: 8192},
+		"claude-3-7-sonnet": {ContextWindow: 200000, MaxOutputTokens: 64000},
+		"claude-sonnet-4":   {ContextWindow: 200000, MaxOutputTokens: 64000},
+		"claude-opus-4":     {ContextWindow: 200000, MaxOutputTokens: 32000},
+
+		// OpenAI
+		"gpt-3.5-turbo": {ContextWindow: 16385, MaxOutputTokens: 4096},
+		"gpt-4":         {ContextWindow: 8192, MaxOutputTokens: 4096},
+		"gpt-4-turbo":   {ContextWindow: 128000, MaxOutputTokens: 4096},
+		"gpt-4o":        {ContextWindow: 128000, MaxOutputTokens: 16384},
+		"gpt-4.1":       {ContextWindow: 1047576, MaxOutputTokens: 32768},
+		"o1":            {ContextWindow: 200000, MaxOutputTokens: 100000},
+		"o3":            {ContextWindow: 200000, MaxOutputTokens: 100000},
+		"o4-mini":       {ContextWindow: 200000, MaxOutputTokens: 100000},
+
+		// Google
+		"gemini-1.5-flash": {ContextWindow: 1048576, MaxOutputTokens: 8192},
+		"gemini-1.5-pro":   {ContextWindow: 2097152, MaxOutputTokens: 8192},
+		"gemini-2.0-flash": {ContextWindow: 1048576, MaxOutputTokens: 8192},
+		"gemini-2.5":       {ContextWindow: 1048576, MaxOutputTokens: 65536},
+
+		// Mistral
+		"mistral-large":     {ContextWindow: 131072},
+		"mistral-small":     {ContextWindow: 32768},
+		"open-mistral-nemo": {ContextWindow: 131072},
+		"ministral":         {ContextWindow: 131072},
+		"codestral":         {ContextWindow: 256000},
+
+		// Groq
+		"llama-3.1-8b-instant":    {ContextWindow: 131072, MaxOutputTokens: 8192},
+		"llama-3.3-70b-versatile": {ContextWindow: 131072, MaxOutputTokens: 32768},
+		"mixtral-8x7b-32768":      {ContextWindow: 32768},
+
+		// Perplexity
+		"sonar": {ContextWindow: 127072},
+	}
+)
+
+// RegisterModel adds or replaces the limits of a model. name also matches
+// longer names it prefixes, such as dated versions.
+func RegisterModel(name string, info ModelInfo) {
+	modelsMu.Lock()
+	defer modelsMu.Unlock()
+	models[name] = info
+}
+
+// LookupModel returns the limits of a model, matching the longest
+// registered name that prefixes it
+func LookupModel(model string) (ModelInfo, bool) {
+	modelsMu.RLock()
+	defer modelsMu.RUnlock()
+	if info, ok := models[model]; ok {
+		return info, true
+	}
+	var best string
+	for name := range models {
+		if len(name) > len(best) && strings.HasPrefix(model, name) {
+			best = name
+		}
+	}
+	if best == "" {
+		return ModelInfo{}, false
+	}
+	return models[best], true
+}
+
+// defaultMaxTokens returns MaxTokens for a request without one: the
+// configured default, or what's left of the model's context window after
+// the prompt, capped at the model's output limit
+func defaultMaxTokens(req *Request, provider Provider, config ClientConfig) int {
+	if config.DefaultMaxTokens > 0 {
+		return config.DefaultMaxTokens
+	}
+
+	model := cmp.Or(req.Model, config.DefaultModel)
+	if namer, ok := provider.(ModelNamer); ok && model == "" {
+		model = namer.Model()
+	}
+	info, ok := LookupModel(model)
+	if !ok {
+		return fallbackMaxTokens
+	}
+
+	// Token counts are estimates, so leave a margin
+	prompt := provider.CountTokens(req.SystemPrompt + req.AssistantPrefix)
+	for _, msg := range req.Messages {
+		prompt += provider.CountTokens(msg.Content) + 4
+	}
+	for _, tool := range req.Tools {
+		schema, _ := json.Marshal(tool.Parameters)
+		prompt += provider.CountTokens(tool.Name + tool.Description + string(schema))
+	}
+	available := info.ContextWindow - prompt*11/10
+	if info.MaxOutputTokens > 0 {
+		available = min(available, info.MaxOutputTokens)
+	}
+	return max(available, 1)
+}
@@ -0,0 +1,402 @@
+// Package moderation scores requests and responses for safety, keeps an
+// audit log of the category scores and answers queries over flagged
+// conversations for trust-and-safety review.
+//
+//	audit := moderation.New(moderation.Config{
+//		Moderator: moderation.NewOpenAIFromEnv(),
+//		Block:     true,
+//	})
+//	client := simpleai.NewClient(p, simpleai.WithMiddleware(audit.Middleware()))
+//
+//	sessions, err := audit.FlaggedSessions(ctx, moderation.Filter{Unreviewed: true})
+package moderation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/medatechnology/goutil/simplelog"
+	"github.com/medatechnology/simpleai"
+)
+
+// ErrFlagged is returned by blocking middleware for flagged content
+var ErrFlagged = errors.New("moderation: content flagged")
+
+// Result is the moderation verdict on a text
+type Result struct {
+	Flagged    bool               `json:"flagged"`
+	Categories []string           `json:"categories,omitempty"` // Flagged categories
+	Scores     map[string]float64 `json:"scores,omitempty"`     // Category scores from 0 to 1
+}
+
+// Moderator scores text for safety
+type Moderator interface {
+	Moderate(ctx context.Context, text string) (Result, error)
+}
+
+// ModeratorFunc is a function that implements Moderator
+type ModeratorFunc func(ctx context.Context, text string) (Result, error)
+
+// Moderate implements Moderator
+func (f ModeratorFunc) Moderate(ctx context.Context, text string) (Result, error) {
+	return f(ctx, text)
+}
+
+// Direction tells whether a text was sent to or received from the model
+type Direction string
+
+const (
+	DirectionInput  Direction = "input"
+	DirectionOutput Direction = "output"
+)
+
+// Review statuses
+const (
+	ReviewConfirmed = "confirmed" // The content violates policy
+	ReviewDismissed = "dismissed" // False positive
+	ReviewEscalated = "escalated"
+)
+
+// Review is a reviewer's decision on an audit entry
+type Review struct {
+	Status   string    `json:"status"`
+	Reviewer string    `json:"reviewer,omitempty"`
+	Note     string    `json:"note,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Entry is the audit record of one moderated text
+type Entry struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	UserID    string    `json:"user_id,omitempty"`
+	SessionID string    `json:"session_id,omitempty"`
+	Direction Direction `json:"direction"`
+	Text      string    `json:"text,omitempty"` // Only with Config.IncludeText
+	Result
+	Blocked bool    `json:"blocked,omitempty"`
+	Review  *Review `json:"review,omitempty"`
+}
+
+// Filter selects audit entries. Empty fields match everything; From is
+// inclusive and To exclusive.
+type Filter struct {
+	From        time.Time `json:"from,omitzero"`
+	To          time.Time `json:"to,omitzero"`
+	UserID      string    `json:"user_id,omitempty"`
+	SessionID   string    `json:"session_id,omitempty"`
+	Direction   Direction `json:"direction,omitempty"`
+	FlaggedOnly bool      `json:"flagged_only,omitempty"`
+	Unreviewed  bool      `json:"unreviewed,omitempty"`
+	// Category matches entries scoring at least MinScore in it; without a
+	// category, MinScore applies to the highest score
+	Category string  `json:"category,omitempty"`
+	MinScore float64 `json:"min_score,omitempty"`
+}
+
+// Match reports whether e passes the filter
+func (f Filter) Match(e Entry) bool {
+	if (!f.From.IsZero() && e.Time.Before(f.From)) ||
+		(!f.To.IsZero() && !e.Time.Before(f.To)) ||
+		(f.UserID != "" && e.UserID != f.UserID) ||
+		(f.SessionID != "" && e.SessionID != f.SessionID) ||
+		(f.Direction != "" && e.Direction != f.Direction) ||
+		(f.FlaggedOnly && !e.Flagged) ||
+		(f.Unreviewed && e.Review != nil) {
+		return false
+	}
+	if f.Category != "" {
+		return e.Scores[f.Category] >= f.MinScore && (f.MinScore > 0 || slices.Contains(e.Categories, f.Category))
+	}
+	return f.MinScore == 0 || maxScore(e.Scores) >= f.MinScore
+}
+
+// Config holds configuration for an Auditor
+type Config struct {
+	Moderator Moderator // Required
+	Store     Store     // Defaults to a MemoryStore
+
+	// Directions are the texts moderated (default both). Inputs are the
+	// latest user message of each request.
+	Directions []Direction
+
+	// Threshold also flags texts scoring at least this in any category
+	// (0 keeps the moderator's verdict)
+	Threshold float64
+
+	// Block fails flagged requests and responses with ErrFlagged. Streamed
+	// output is audited after it was sent, so it can't be blocked.
+	Block bool
+
+	// IncludeText stores the moderated text with each entry for reviewers
+	IncludeText bool
+
+	// OnFlag is called for every flagged entry
+	OnFlag func(e Entry)
+
+	// OnError receives moderation and store errors; they are logged by
+	// default and never fail requests
+	OnError func(err error)
+}
+
+// Auditor moderates traffic through a client and keeps the audit log
+type Auditor struct {
+	config Config
+}
+
+// New creates an auditor
+func New(config Config) *Auditor {
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if len(config.Directions) == 0 {
+		config.Directions = []Direction{DirectionInput, DirectionOutput}
+	}
+	if config.OnError == nil {
+		config.OnError = func(err error) {
+			simplelog.LogErr(err, "moderation: audit failed")
+		}
+	}
+	return &Auditor{config: config}
+}
+
+// check moderates a text and returns its audit entry
+func (a *Auditor) check(ctx context.Context, direction Direction, text string) (Entry, error) {
+	if a.config.Moderator == nil {
+		return Entry{}, errors.New("moderation: moderator is required")
+	}
+	result, err := a.config.Moderator.Moderate(ctx, text)
+	if err != nil {
+		return Entry{}, err
+	}
+	if a.config.Threshold > 0 {
+		for category, score := range result.Scores {
+			if score >= a.config.Threshold && !slices.Contains(result.Categories, category) {
+				result.Categories = append(result.Categories, category)
+			}
+		}
+		result.Flagged = result.Flagged || len(result.Categories) > 0
+	}
+	sort.Strings(result.Categories)
+
+	md, _ := simpleai.RequestMetadataFromContext(ctx)
+	e := Entry{
+		ID:        newEntryID(),
+		Time:      time.Now(),
+		UserID:    md.UserID,
+		SessionID: md.SessionID,
+		Direction: direction,
+		Result:    result,
+	}
+	if a.config.IncludeText {
+		e.Text = text
+	}
+	return e, nil
+}
+
+// record stores an entry; the caller's cancellation doesn't lose it
+func (a *Auditor) record(ctx context.Context, e Entry) {
+	if err := a.config.Store.Add(context.WithoutCancel(ctx), e); err != nil {
+		a.config.OnError(err)
+	}
+	if e.Flagged && a.config.OnFlag != nil {
+		a.config.OnFlag(e)
+	}
+}
+
+// Entries returns the audit entries matching f
+func (a *Auditor) Entries(ctx context.Context, f Filter) ([]Entry, error) {
+	return a.config.Store.Query(ctx, f)
+}
+
+// Review records a reviewer's decision on an entry
+func (a *Auditor) Review(ctx context.Context, id string, review Review) error {
+	if review.Time.IsZero() {
+		review.Time = time.Now()
+	}
+	return a.config.Store.SetReview(ctx, id, review)
+}
+
+// Session summarizes the flagged entries of one conversation
+type Session struct {
+	SessionID  string             `json:"session_id"`
+	UserID     string             `json:"user_id,omitempty"`
+	Flagged    int                `json:"flagged"`
+	Unreviewed int                `json:"unreviewed"`
+	Scores     map[string]float64 `json:"scores"` // Highest score per category
+	First      time.Time          `json:"first"`
+	Last       time.Time          `json:"last"`
+}
+
+// FlaggedSessions returns the conversations with flagged entries matching f,
+// most recent first. Entries without a session are grouped by user.
+func (a *Auditor) FlaggedSessions(ctx context.Context, f Filter) ([]Session, error) {
+	f.FlaggedOnly = true
+	entries, err := a.config.Store.Query(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make(map[string]*Session)
+	var order []string
+	for _, e := range entries {
+		key := e.SessionID
+		if key == "" {
+			key = "user:" + e.UserID
+		}
+		s, ok := sessions[key]
+		if !ok {
+			s = &Session{SessionID: e.SessionID, UserID: e.UserID, Scores: make(map[string]float64), First: e.Time}
+			sessions[key] = s
+			order = append(order, key)
+		}
+		s.Flagged++
+		if e.Review == nil {
+			s.Unreviewed++
+		}
+		for category, score := range e.Scores {
+			s.Scores[category] = max(s.Scores[category], score)
+		}
+		if e.Time.After(s.Last) {
+			s.Last = e.Time
+		}
+	}
+
+	result := make([]Session, 0, len(order))
+	for _, key := range order {
+		result = append(result, *sessions[key])
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Last.After(result[j].Last) })
+	return result, nil
+}
+
+// Middleware moderates the requests and responses of a client. Moderation
+// errors are reported to OnError and let the request through.
+func (a *Auditor) Middleware() simpleai.Middleware {
+	return &middleware{auditor: a}
+}
+
+type middleware struct {
+	auditor *Auditor
+}
+
+// Wrap implements simpleai.Middleware
+func (m *middleware) Wrap(next simpleai.Handler) simpleai.Handler {
+	return func(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+		if err := m.checkInput(ctx, req); err != nil {
+			return nil, err
+		}
+
+		resp, err := next(ctx, req)
+		if err != nil || !m.enabled(DirectionOutput) || resp.Content == "" {
+			return resp, err
+		}
+
+		e, err := m.auditor.check(ctx, DirectionOutput, resp.Content)
+		if err != nil {
+			m.auditor.config.OnError(err)
+			return resp, nil
+		}
+		e.Blocked = e.Flagged && m.auditor.config.Block
+		m.auditor.record(ctx, e)
+		if e.Blocked {
+			return nil, flaggedError(e)
+		}
+		return resp, nil
+	}
+}
+
+// WrapStream implements simpleai.StreamMiddleware; output is audited when
+// the stream ends
+func (m *middleware) WrapStream(next simpleai.StreamHandler) simpleai.StreamHandler {
+	return func(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
+		if err := m.checkInput(ctx, req); err != nil {
+			return nil, err
+		}
+
+		stream, err := next(ctx, req)
+		if err != nil || !m.enabled(DirectionOutput) {
+			return stream, err
+		}
+
+		out := make(chan simpleai.StreamEvent)
+		go func() {
+			defer close(out)
+			var content strings.Builder
+			for event := range stream {
+				content.WriteString(event.Content)
+				out <- event
+			}
+			if content.Len() == 0 {
+				return
+			}
+			ctx := context.WithoutCancel(ctx)
+			e, err := m.auditor.check(ctx, DirectionOutput, content.String())
+			if err != nil {
+				m.auditor.config.OnError(err)
+				return
+			}
+			m.auditor.record(ctx, e)
+		}()
+		return out, nil
+	}
+}
+
+// checkInput moderates the latest user message; earlier ones were checked
+// on their own turn
+func (m *middleware) checkInput(ctx context.Context, req *simpleai.Request) error {
+	if !m.enabled(DirectionInput) {
+		return nil
+	}
+	var text string
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == simpleai.RoleUser {
+			text = req.Messages[i].Content
+			break
+		}
+	}
+	if text == "" {
+		return nil
+	}
+
+	e, err := m.auditor.check(ctx, DirectionInput, text)
+	if err != nil {
+		m.auditor.config.OnError(err)
+		return nil
+	}
+	e.Blocked = e.Flagged && m.auditor.config.Block
+	m.auditor.record(ctx, e)
+	if e.Blocked {
+		return flaggedError(e)
+	}
+	return nil
+}
+
+func (m *middleware) enabled(direction Direction) bool {
+	return slices.Contains(m.auditor.config.Directions, direction)
+}
+
+func flaggedError(e Entry) error {
+	return fmt.Errorf("%w (%s): %s", ErrFlagged, e.Direction, strings.Join(e.Categories, ", "))
+}
+
+func maxScore(scores map[string]float64) float64 {
+	highest := 0.0
+	for _, score := range scores {
+		highest = max(highest, score)
+	}
+	return highest
+}
+
+func newEntryID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "mod_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,92 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	medahttp "github.com/medatechnology/goutil/http"
+)
+
+const (
+	OpenAIModerationURL = "https://api.openai.com/v1/moderations"
+	OpenAIDefaultModel  = "omni-moderation-latest"
+)
+
+// OpenAIConfig holds configuration for the OpenAI moderation API
+type OpenAIConfig struct {
+	APIKey string
+	Model  string
+}
+
+// OpenAI implements Moderator using OpenAI's moderation API
+type OpenAI struct {
+	config OpenAIConfig
+	client medahttp.HttpClient
+}
+
+// NewOpenAI creates an OpenAI moderator
+func NewOpenAI(config OpenAIConfig) *OpenAI {
+	if config.Model == "" {
+		config.Model = OpenAIDefaultModel
+	}
+
+	client := medahttp.NewHttp()
+	client.SetHeader(map[string][]string{
+		"Content-Type":  {"application/json"},
+		"Authorization": {"Bearer " + config.APIKey},
+	})
+
+	return &OpenAI{
+		config: config,
+		client: client,
+	}
+}
+
+// NewOpenAIFromEnv creates an OpenAI moderator using OPENAI_API_KEY
+func NewOpenAIFromEnv() *OpenAI {
+	return NewOpenAI(OpenAIConfig{APIKey: os.Getenv("OPENAI_API_KEY")})
+}
+
+// Moderate scores text with the moderation API
+func (o *OpenAI) Moderate(ctx context.Context, text string) (Result, error) {
+	req := openaiModerationRequest{
+		Model: o.config.Model,
+		Input: text,
+	}
+
+	var resp openaiModerationResponse
+	statusCode, err := o.client.Post(OpenAIModerationURL, req, &resp, nil)
+	if err != nil {
+		return Result{}, fmt.Errorf("moderation request failed: %w", err)
+	}
+	if statusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("moderation request failed with status %d", statusCode)
+	}
+	if len(resp.Results) == 0 {
+		return Result{}, fmt.Errorf("no moderation results returned")
+	}
+
+	r := resp.Results[0]
+	result := Result{Flagged: r.Flagged, Scores: r.CategoryScores}
+	for category, flagged := range r.Categories {
+		if flagged {
+			result.Categories = append(result.Categories, category)
+		}
+	}
+	return result, nil
+}
+
+type openaiModerationRequest struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+type openaiModerationResponse struct {
+	Results []struct {
+		Flagged        bool               `json:"flagged"`
+		Categories     map[string]bool    `json:"categories"`
+		CategoryScores map[string]float64 `json:"category_scores"`
+	} `json:"results"`
+}
@@ -0,0 +1,174 @@
+package moderation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrEntryNotFound is returned when reviewing an unknown audit entry
+var ErrEntryNotFound = errors.New("moderation: audit entry not found")
+
+// Store persists audit entries. Implement it to keep the audit log in a
+// database.
+type Store interface {
+	Add(ctx context.Context, e Entry) error
+	// Query returns the entries matching f, oldest first
+	Query(ctx context.Context, f Filter) ([]Entry, error)
+	// SetReview records a reviewer's decision on an entry
+	SetReview(ctx context.Context, id string, review Review) error
+}
+
+// MemoryStore keeps audit entries in memory
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries []Entry
+}
+
+// NewMemoryStore creates an in-memory audit store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Add appends an entry
+func (m *MemoryStore) Add(ctx context.Context, e Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, e)
+	return nil
+}
+
+// Query returns the matching entries
+func (m *MemoryStore) Query(ctx context.Context, f Filter) ([]Entry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []Entry
+	for _, e := range m.entries {
+		if f.Match(e) {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+// SetReview updates the review of an entry
+func (m *MemoryStore) SetReview(ctx context.Context, id string, review Review) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.entries {
+		if m.entries[i].ID == id {
+			m.entries[i].Review = &review
+			return nil
+		}
+	}
+	return ErrEntryNotFound
+}
+
+// FileStore appends audit entries to a JSON Lines file. Reviews are
+// appended as well and applied when reading.
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileStore creates a file-backed audit store at path, creating its
+// directory if needed
+func NewFileStore(path string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{path: path}, nil
+}
+
+// fileLine is an entry or a review of an earlier entry
+type fileLine struct {
+	Entry
+	ReviewOf string `json:"review_of,omitempty"`
+}
+
+// Add appends an entry as one line
+func (f *FileStore) Add(ctx context.Context, e Entry) error {
+	return f.append(fileLine{Entry: e})
+}
+
+// SetReview appends a review of an entry
+func (f *FileStore) SetReview(ctx context.Context, id string, review Review) error {
+	entries, err := f.Query(ctx, Filter{})
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.ID == id {
+			return f.append(fileLine{Entry: Entry{Review: &review}, ReviewOf: id})
+		}
+	}
+	return ErrEntryNotFound
+}
+
+func (f *FileStore) append(line fileLine) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Query scans the file for matching entries
+func (f *FileStore) Query(ctx context.Context, filter Filter) ([]Entry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.Open(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 4<<20)
+	for scanner.Scan() {
+		var line fileLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue // Skip a line torn by a crash
+		}
+		if line.ReviewOf != "" {
+			if i, ok := index[line.ReviewOf]; ok {
+				entries[i].Review = line.Review
+			}
+			continue
+		}
+		index[line.ID] = len(entries)
+		entries = append(entries, line.Entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var result []Entry
+	for _, e := range entries {
+		if filter.Match(e) {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,183 @@
+package observe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// LangfuseConfig holds configuration for the Langfuse exporter
+type LangfuseConfig struct {
+	Host      string // Default https://cloud.langfuse.com
+	PublicKey string
+	SecretKey string
+
+	// Release and Environment label every trace
+	Release     string
+	Environment string
+
+	Client *http.Client // Default http.DefaultClient
+}
+
+// Langfuse exports spans to the Langfuse ingestion API. Root spans become
+// traces, generations become generations and everything else becomes spans.
+type Langfuse struct {
+	config LangfuseConfig
+}
+
+// NewLangfuse creates a Langfuse exporter
+func NewLangfuse(config LangfuseConfig) *Langfuse {
+	if config.Host == "" {
+		config.Host = "https://cloud.langfuse.com"
+	}
+	config.Host = strings.TrimSuffix(config.Host, "/")
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	return &Langfuse{config: config}
+}
+
+// NewLangfuseFromEnv creates a Langfuse exporter using LANGFUSE_PUBLIC_KEY,
+// LANGFUSE_SECRET_KEY and, if set, LANGFUSE_HOST
+func NewLangfuseFromEnv() *Langfuse {
+	return NewLangfuse(LangfuseConfig{
+		Host:      os.Getenv("LANGFUSE_HOST"),
+		PublicKey: os.Getenv("LANGFUSE_PUBLIC_KEY"),
+		SecretKey: os.Getenv("LANGFUSE_SECRET_KEY"),
+	})
+}
+
+// langfuseEvent is one entry of an ingestion batch
+type langfuseEvent struct {
+	ID        string         `json:"id"`
+	Type      string         `json:"type"`
+	Timestamp time.Time      `json:"timestamp"`
+	Body      map[string]any `json:"body"`
+}
+
+// Export implements Exporter
+func (l *Langfuse) Export(ctx context.Context, spans []*Span) error {
+	batch := make([]langfuseEvent, 0, len(spans)+1)
+	for _, span := range spans {
+		if span.ParentID == "" {
+			batch = append(batch, langfuseEvent{ID: newID(), Type: "trace-create", Timestamp: span.End, Body: l.trace(span)})
+		}
+		eventType, body := l.observation(span)
+		batch = append(batch, langfuseEvent{ID: newID(), Type: eventType, Timestamp: span.End, Body: body})
+	}
+
+	data, err := json.Marshal(map[string]any{"batch": batch})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.config.Host+"/api/public/ingestion", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(l.config.PublicKey, l.config.SecretKey)
+
+	resp, err := l.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("langfuse returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	// Status 207 lists the events that were rejected
+	var result struct {
+		Errors []struct {
+			ID      string `json:"id"`
+			Status  int    `json:"status"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &result) == nil && len(result.Errors) > 0 {
+		first := result.Errors[0]
+		return fmt.Errorf("langfuse rejected %d of %d events (status %d: %s)", len(result.Errors), len(batch), first.Status, first.Message)
+	}
+	return nil
+}
+
+func (l *Langfuse) trace(span *Span) map[string]any {
+	body := map[string]any{
+		"id":        span.TraceID,
+		"name":      span.Name,
+		"timestamp": span.Start,
+		"input":     span.Input,
+		"output":    span.Output,
+	}
+	setString(body, "userId", span.UserID)
+	setString(body, "sessionId", span.SessionID)
+	setString(body, "release", l.config.Release)
+	setString(body, "environment", l.config.Environment)
+	if len(span.Tags) > 0 {
+		tags := make([]string, 0, len(span.Tags))
+		for k, v := range span.Tags {
+			tags = append(tags, k+":"+v)
+		}
+		body["tags"] = tags
+		body["metadata"] = span.Tags
+	}
+	return body
+}
+
+func (l *Langfuse) observation(span *Span) (string, map[string]any) {
+	body := map[string]any{
+		"id":        span.ID,
+		"traceId":   span.TraceID,
+		"name":      span.Name,
+		"startTime": span.Start,
+		"endTime":   span.End,
+		"input":     span.Input,
+		"output":    span.Output,
+	}
+	setString(body, "parentObservationId", span.ParentID)
+	setString(body, "environment", l.config.Environment)
+
+	metadata := map[string]any{"kind": span.Kind}
+	for k, v := range span.Metadata {
+		metadata[k] = v
+	}
+	body["metadata"] = metadata
+	if span.Error != "" {
+		body["level"] = "ERROR"
+		body["statusMessage"] = span.Error
+	}
+
+	if span.Kind != KindGeneration {
+		return "span-create", body
+	}
+	setString(body, "model", span.Model)
+	if len(span.Parameters) > 0 {
+		body["modelParameters"] = span.Parameters
+	}
+	if !span.CompletionStart.IsZero() {
+		body["completionStartTime"] = span.CompletionStart
+	}
+	if span.Usage != nil {
+		body["usage"] = map[string]any{
+			"input":  span.Usage.PromptTokens,
+			"output": span.Usage.CompletionTokens,
+			"total":  span.Usage.TotalTokens,
+			"unit":   "TOKENS",
+		}
+	}
+	return "generation-create", body
+}
+
+// setString sets key when value is not empty
+func setString(body map[string]any, key, value string) {
+	if value != "" {
+		body[key] = value
+	}
+}
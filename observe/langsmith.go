@@ -0,0 +1,181 @@
+package observe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// LangSmithConfig holds configuration for the LangSmith exporter
+type LangSmithConfig struct {
+	Endpoint string // Default https://api.smith.langchain.com
+	APIKey   string
+	Project  string // Default "default"
+
+	Client *http.Client // Default http.DefaultClient
+}
+
+// LangSmith exports spans as runs to the LangSmith batch API, or any
+// endpoint compatible with it. Generations become "llm" runs, tools "tool"
+// runs, retrievals "retriever" runs and everything else "chain" runs.
+type LangSmith struct {
+	config LangSmithConfig
+}
+
+// NewLangSmith creates a LangSmith exporter
+func NewLangSmith(config LangSmithConfig) *LangSmith {
+	if config.Endpoint == "" {
+		config.Endpoint = "https://api.smith.langchain.com"
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	if config.Project == "" {
+		config.Project = "default"
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	return &LangSmith{config: config}
+}
+
+// NewLangSmithFromEnv creates a LangSmith exporter using LANGSMITH_API_KEY
+// and, if set, LANGSMITH_ENDPOINT and LANGSMITH_PROJECT
+func NewLangSmithFromEnv() *LangSmith {
+	return NewLangSmith(LangSmithConfig{
+		Endpoint: os.Getenv("LANGSMITH_ENDPOINT"),
+		APIKey:   os.Getenv("LANGSMITH_API_KEY"),
+		Project:  os.Getenv("LANGSMITH_PROJECT"),
+	})
+}
+
+// Export implements Exporter. Runs are posted complete, with their end
+// time and outputs, in a single batch request.
+func (l *LangSmith) Export(ctx context.Context, spans []*Span) error {
+	runs := make([]map[string]any, len(spans))
+	for i, span := range spans {
+		runs[i] = l.run(span)
+	}
+
+	data, err := json.Marshal(map[string]any{"post": runs})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.config.Endpoint+"/runs/batch", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", l.config.APIKey)
+
+	resp, err := l.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("langsmith returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (l *LangSmith) run(span *Span) map[string]any {
+	runType := "chain"
+	switch span.Kind {
+	case KindGeneration:
+		runType = "llm"
+	case KindTool:
+		runType = "tool"
+	case KindRetrieval:
+		runType = "retriever"
+	}
+
+	metadata := map[string]any{"kind": span.Kind}
+	for k, v := range span.Metadata {
+		metadata[k] = v
+	}
+	setString(metadata, "user_id", span.UserID)
+	setString(metadata, "session_id", span.SessionID)
+	if span.Model != "" {
+		metadata["ls_model_name"] = span.Model
+	}
+	for k, v := range span.Parameters {
+		metadata["ls_"+k] = v
+	}
+
+	run := map[string]any{
+		"id":           span.ID,
+		"trace_id":     span.TraceID,
+		"dotted_order": dottedOrder(span),
+		"name":         span.Name,
+		"run_type":     runType,
+		"start_time":   span.Start.UTC(),
+		"end_time":     span.End.UTC(),
+		"inputs":       wrapValue("input", span.Input),
+		"outputs":      wrapValue("output", span.Output),
+		"session_name": l.config.Project,
+		"extra":        map[string]any{"metadata": metadata},
+	}
+	setString(run, "parent_run_id", span.ParentID)
+	setString(run, "error", span.Error)
+	if span.Usage != nil {
+		run["outputs"].(map[string]any)["usage_metadata"] = map[string]any{
+			"input_tokens":  span.Usage.PromptTokens,
+			"output_tokens": span.Usage.CompletionTokens,
+			"total_tokens":  span.Usage.TotalTokens,
+		}
+	}
+	if len(span.Tags) > 0 {
+		tags := make([]string, 0, len(span.Tags))
+		for k, v := range span.Tags {
+			tags = append(tags, k+":"+v)
+		}
+		run["tags"] = tags
+	}
+	if !span.CompletionStart.IsZero() {
+		run["events"] = []map[string]any{{"name": "new_token", "time": span.CompletionStart.UTC()}}
+	}
+	return run
+}
+
+// dottedOrder is LangSmith's sort key: the start time and ID of every
+// ancestor from the root, joined by dots
+func dottedOrder(span *Span) string {
+	var parts []string
+	for s := span; s != nil; s = s.parent {
+		start := s.Start.UTC()
+		parts = append(parts, fmt.Sprintf("%s%06dZ%s", start.Format("20060102T150405"), start.Nanosecond()/1000, s.ID))
+	}
+	slices.Reverse(parts)
+	return strings.Join(parts, ".")
+}
+
+// wrapValue puts v in the object LangSmith expects for inputs and outputs.
+// Messages are passed as "messages" so chat runs render as a conversation.
+func wrapValue(key string, v any) map[string]any {
+	switch v := v.(type) {
+	case nil:
+		return map[string]any{}
+	case map[string]any:
+		if v != nil {
+			return maps.Clone(v)
+		}
+		return map[string]any{}
+	case []simpleai.Message:
+		return map[string]any{"messages": v}
+	case json.RawMessage:
+		var object map[string]any
+		if json.Unmarshal(v, &object) == nil && object != nil {
+			return object
+		}
+	}
+	return map[string]any{key: v}
+}
@@ -0,0 +1,111 @@
+package observe
+
+import (
+	"context"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// generation implements both simpleai.Middleware and simpleai.StreamMiddleware
+type generation struct {
+	tracer *Tracer
+}
+
+// Middleware records every client call as a generation span, nested under
+// the span in the request context
+func (t *Tracer) Middleware() simpleai.Middleware {
+	return &generation{tracer: t}
+}
+
+// Wrap implements simpleai.Middleware
+func (g *generation) Wrap(next simpleai.Handler) simpleai.Handler {
+	return func(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+		ctx, span := g.start(ctx, req)
+		resp, err := next(ctx, req)
+
+		span.SetMetadata("provider", simpleai.ProviderName(ctx))
+		setTransfer(ctx, span)
+		if resp == nil {
+			span.Finish(nil, err)
+			return resp, err
+		}
+		if resp.Model != "" {
+			span.Model = resp.Model
+		}
+		usage := resp.Usage
+		span.Usage = &usage
+		if resp.FinishReason != "" {
+			span.SetMetadata("finish_reason", resp.FinishReason)
+		}
+		span.Finish(output(resp.Content, resp.ToolCalls), err)
+		return resp, err
+	}
+}
+
+// WrapStream implements simpleai.StreamMiddleware; the span ends with the
+// stream and records when the first token arrived
+func (g *generation) WrapStream(next simpleai.StreamHandler) simpleai.StreamHandler {
+	return func(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
+		ctx, span := g.start(ctx, req)
+		stream, err := next(ctx, req)
+		if err != nil {
+			span.SetMetadata("provider", simpleai.ProviderName(ctx))
+			span.Finish(nil, err)
+			return nil, err
+		}
+
+		return simpleai.TraceStream(ctx, stream, span.Start, nil, func(timing simpleai.StreamTiming) {
+			span.SetMetadata("provider", simpleai.ProviderName(ctx))
+			span.SetMetadata("stream", true)
+			setTransfer(ctx, span)
+			span.CompletionStart = timing.FirstToken
+			span.End = timing.End
+			span.Usage = timing.Usage
+			span.Finish(timing.Content, timing.Err)
+		}), nil
+	}
+}
+
+func (g *generation) start(ctx context.Context, req *simpleai.Request) (context.Context, *Span) {
+	messages := req.Messages
+	if req.SystemPrompt != "" {
+		messages = append([]simpleai.Message{{Role: simpleai.RoleSystem, Content: req.SystemPrompt}}, messages...)
+	}
+	ctx, span := g.tracer.Start(ctx, "completion", KindGeneration, messages)
+	span.Model = req.Model
+	span.Parameters = map[string]any{}
+	if req.MaxTokens > 0 {
+		span.Parameters["max_tokens"] = req.MaxTokens
+	}
+	if req.Temperature > 0 {
+		span.Parameters["temperature"] = req.Temperature
+	}
+	if req.TopP > 0 {
+		span.Parameters["top_p"] = req.TopP
+	}
+	if len(req.Tools) > 0 {
+		names := make([]string, len(req.Tools))
+		for i, tool := range req.Tools {
+			names[i] = tool.Name
+		}
+		span.SetMetadata("tools", names)
+	}
+	return ctx, span
+}
+
+// setTransfer records the request's HTTP payload sizes on the span
+func setTransfer(ctx context.Context, span *Span) {
+	if t, _ := simpleai.TransferFromContext(ctx); t != (simpleai.Transfer{}) {
+		span.SetMetadata("request_bytes", t.RequestBytes)
+		span.SetMetadata("sent_bytes", t.SentBytes)
+		span.SetMetadata("response_bytes", t.ResponseBytes)
+	}
+}
+
+// output is the response text, or the message when the model called tools
+func output(content string, calls []simpleai.ToolCall) any {
+	if len(calls) == 0 {
+		return content
+	}
+	return simpleai.Message{Role: simpleai.RoleAssistant, Content: content, ToolCalls: calls}
+}
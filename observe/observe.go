@@ -0,0 +1,310 @@
+// Package observe exports request traces to LLM observability tools such as
+// Langfuse and LangSmith. A Tracer records spans: client calls become
+// generations, and chains, agents and tools become spans around them, so a
+// run shows up as one tree.
+//
+//	tracer := observe.NewTracer(observe.TracerConfig{Exporter: observe.NewLangfuseFromEnv()})
+//	defer tracer.Close(context.Background())
+//	client := simpleai.NewClient(p, simpleai.WithMiddleware(tracer.Middleware()))
+//
+//	ctx, span := tracer.Start(ctx, "answer-ticket", observe.KindChain, ticket)
+//	reply, err := client.Complete(ctx, req) // Recorded as a child generation
+//	span.Finish(reply, err)
+package observe
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// ErrQueueFull is passed to TracerConfig.OnError when spans are dropped
+// because the exporter cannot keep up
+var ErrQueueFull = errors.New("observe: span queue full")
+
+// Kind is what a span represents
+type Kind string
+
+const (
+	KindChain      Kind = "chain"      // A pipeline, graph or any multi-step run
+	KindAgent      Kind = "agent"      // One agent turn
+	KindTool       Kind = "tool"       // A tool call
+	KindRetrieval  Kind = "retrieval"  // A knowledge base lookup
+	KindGeneration Kind = "generation" // A model call
+)
+
+// Span is one timed step of a trace
+type Span struct {
+	ID       string
+	TraceID  string // ID of the root span
+	ParentID string // Empty for the root
+	Name     string
+	Kind     Kind
+
+	Start           time.Time
+	End             time.Time
+	CompletionStart time.Time // First token of a streamed generation
+
+	Input  any
+	Output any
+	Error  string
+
+	// Generations only
+	Model      string
+	Parameters map[string]any
+	Usage      *simpleai.Usage
+
+	// From the request metadata
+	UserID    string
+	SessionID string
+	Tags      map[string]string
+
+	Metadata map[string]any
+
+	parent *Span
+	tracer *Tracer
+	once   sync.Once
+}
+
+// SetMetadata adds a metadata value to the span
+func (s *Span) SetMetadata(key string, value any) {
+	if s.Metadata == nil {
+		s.Metadata = map[string]any{}
+	}
+	s.Metadata[key] = value
+}
+
+// Finish ends the span with its output and error and queues it for export.
+// Only the first call has an effect.
+func (s *Span) Finish(output any, err error) {
+	s.once.Do(func() {
+		if s.End.IsZero() {
+			s.End = time.Now()
+		}
+		s.Output = output
+		if err != nil {
+			s.Error = err.Error()
+		}
+		if s.tracer.config.HideContent {
+			s.Input, s.Output = nil, nil
+		}
+		s.tracer.enqueue(s)
+	})
+}
+
+// Exporter ships finished spans to an observability backend. Spans arrive in
+// the order they finish, so children come before their parents.
+type Exporter interface {
+	Export(ctx context.Context, spans []*Span) error
+}
+
+// TracerConfig holds configuration for a Tracer
+type TracerConfig struct {
+	Exporter Exporter // Required
+
+	BatchSize     int           // Spans per export (default 50)
+	FlushInterval time.Duration // Export at least this often (default 5s)
+	MaxQueue      int           // Spans held while exports are slow; more are dropped (default 10000)
+	Timeout       time.Duration // Per export (default 10s)
+
+	// HideContent drops inputs and outputs, exporting only structure,
+	// timing, models and usage
+	HideContent bool
+
+	// OnError is called when an export fails or spans are dropped; exports
+	// never fail requests
+	OnError func(err error)
+}
+
+// DefaultTracerConfig returns sensible defaults
+func DefaultTracerConfig() TracerConfig {
+	return TracerConfig{
+		BatchSize:     50,
+		FlushInterval: 5 * time.Second,
+		MaxQueue:      10000,
+		Timeout:       10 * time.Second,
+	}
+}
+
+// Tracer records spans and exports them in the background
+type Tracer struct {
+	config TracerConfig
+
+	mu      sync.Mutex
+	queue   []*Span
+	exports sync.Mutex // Serializes exports so batches arrive in order
+
+	wake    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	close   sync.Once
+}
+
+// NewTracer creates a tracer and starts its export loop
+func NewTracer(config TracerConfig) *Tracer {
+	defaults := DefaultTracerConfig()
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaults.FlushInterval
+	}
+	if config.MaxQueue <= 0 {
+		config.MaxQueue = defaults.MaxQueue
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	t := &Tracer{
+		config:  config,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go t.loop()
+	return t
+}
+
+// NewTracerSimple creates a tracer with default batching
+func NewTracerSimple(exporter Exporter) *Tracer {
+	config := DefaultTracerConfig()
+	config.Exporter = exporter
+	return NewTracer(config)
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns the span ctx is running in, if any
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Start opens a span as a child of the span in ctx, or as the root of a new
+// trace, and returns a context that nests further spans under it. Call
+// Finish on the span when the step is done.
+func (t *Tracer) Start(ctx context.Context, name string, kind Kind, input any) (context.Context, *Span) {
+	span := &Span{
+		ID:     newID(),
+		Name:   name,
+		Kind:   kind,
+		Start:  time.Now(),
+		Input:  input,
+		tracer: t,
+	}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.parent = parent
+		span.ParentID = parent.ID
+		span.TraceID = parent.TraceID
+	} else {
+		span.TraceID = span.ID
+	}
+	if md, ok := simpleai.RequestMetadataFromContext(ctx); ok {
+		span.UserID = md.UserID
+		span.SessionID = md.SessionID
+		span.Tags = md.Tags
+		if md.TraceID != "" {
+			span.SetMetadata("trace_id", md.TraceID)
+		}
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Flush exports every finished span now
+func (t *Tracer) Flush(ctx context.Context) error {
+	t.exports.Lock()
+	defer t.exports.Unlock()
+	for {
+		batch := t.take()
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := t.export(ctx, batch); err != nil {
+			return err
+		}
+	}
+}
+
+// Close stops the export loop and flushes the remaining spans
+func (t *Tracer) Close(ctx context.Context) error {
+	t.close.Do(func() { close(t.done) })
+	<-t.stopped
+	return t.Flush(ctx)
+}
+
+func (t *Tracer) enqueue(span *Span) {
+	t.mu.Lock()
+	if len(t.queue) >= t.config.MaxQueue {
+		t.mu.Unlock()
+		t.report(ErrQueueFull)
+		return
+	}
+	t.queue = append(t.queue, span)
+	full := len(t.queue) >= t.config.BatchSize
+	t.mu.Unlock()
+
+	if full {
+		select {
+		case t.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// take removes up to one batch from the queue
+func (t *Tracer) take() []*Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := min(len(t.queue), t.config.BatchSize)
+	batch := t.queue[:n:n]
+	t.queue = t.queue[n:]
+	return batch
+}
+
+func (t *Tracer) loop() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(t.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+		case <-t.wake:
+		}
+		if err := t.Flush(context.Background()); err != nil {
+			t.report(err)
+		}
+	}
+}
+
+// export sends one batch; failed batches are reported and dropped
+func (t *Tracer) export(ctx context.Context, batch []*Span) error {
+	ctx, cancel := context.WithTimeout(ctx, t.config.Timeout)
+	defer cancel()
+	if err := t.config.Exporter.Export(ctx, batch); err != nil {
+		return fmt.Errorf("observe: export of %d spans failed: %w", len(batch), err)
+	}
+	return nil
+}
+
+func (t *Tracer) report(err error) {
+	if t.config.OnError != nil {
+		t.config.OnError(err)
+	}
+}
+
+// newID returns a random UUID, the ID format both Langfuse and LangSmith
+// accept
+func newID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // Version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,112 @@
+package observe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/agent"
+	"github.com/medatechnology/simpleai/pipeline"
+)
+
+// Tool wraps an agent tool so every call is recorded as a tool span
+func (t *Tracer) Tool(tool agent.Tool) agent.Tool {
+	run := tool.Run
+	tool.Run = func(ctx context.Context, args json.RawMessage) (string, error) {
+		ctx, span := t.Start(ctx, tool.Name, KindTool, args)
+		result, err := run(ctx, args)
+		span.Finish(result, err)
+		return result, err
+	}
+	return tool
+}
+
+// Tools wraps each tool with Tool
+func (t *Tracer) Tools(tools ...agent.Tool) []agent.Tool {
+	wrapped := make([]agent.Tool, len(tools))
+	for i, tool := range tools {
+		wrapped[i] = t.Tool(tool)
+	}
+	return wrapped
+}
+
+// participant records each turn of a wrapped agent.Participant
+type participant struct {
+	agent.Participant
+	tracer *Tracer
+}
+
+// Participant wraps a workflow participant so each of its turns is an agent
+// span, with the generations and tool calls it makes nested inside
+func (t *Tracer) Participant(p agent.Participant) agent.Participant {
+	return &participant{Participant: p, tracer: t}
+}
+
+// Respond implements agent.Participant
+func (p *participant) Respond(ctx context.Context, history []simpleai.Message) (simpleai.Message, error) {
+	ctx, span := p.tracer.Start(ctx, p.Name(), KindAgent, history)
+	msg, err := p.Participant.Respond(ctx, history)
+	span.Finish(msg.Content, err)
+	return msg, err
+}
+
+// Pipeline runs p inside a chain span and records each step, including the
+// steps of branches, as a child span with its outputs. Generations made by
+// the steps nest under the pipeline span.
+func (t *Tracer) Pipeline(ctx context.Context, p *pipeline.Pipeline, input map[string]any) (*pipeline.Result, error) {
+	ctx, span := t.Start(ctx, p.Name(), KindChain, input)
+	result, err := p.Run(ctx, input)
+	if result != nil {
+		t.recordSteps(span, result.Trace)
+		span.Finish(result.State.Values(), err)
+	} else {
+		span.Finish(nil, err)
+	}
+	return result, err
+}
+
+// recordSteps adds a span per step under root. A step nests under the
+// branch step whose path prefixes its own ("route/billing/answer" is
+// inside "route").
+func (t *Tracer) recordSteps(root *Span, trace *pipeline.Trace) {
+	spans := make(map[string]*Span, len(trace.Steps))
+	for _, step := range trace.Steps {
+		span := &Span{
+			ID:        newID(),
+			TraceID:   root.TraceID,
+			Name:      step.Step,
+			Kind:      KindChain,
+			Start:     step.Start,
+			End:       step.Start.Add(step.Duration),
+			UserID:    root.UserID,
+			SessionID: root.SessionID,
+			Tags:      root.Tags,
+			tracer:    t,
+		}
+		span.SetMetadata("path", step.Path)
+		span.SetMetadata("attempts", step.Attempts)
+		spans[step.Path] = span
+	}
+
+	// Branch steps are traced after their sub-steps, so link parents once
+	// every span exists
+	for _, step := range trace.Steps {
+		span := spans[step.Path]
+		parent, best := root, ""
+		for path, candidate := range spans {
+			if strings.HasPrefix(step.Path, path+"/") && len(path) > len(best) {
+				parent, best = candidate, path
+			}
+		}
+		span.parent = parent
+		span.ParentID = parent.ID
+
+		var err error
+		if step.Error != "" {
+			err = errors.New(step.Error)
+		}
+		span.Finish(step.Outputs, err)
+	}
+}
@@ -1,5 +1,7 @@
 package simpleai
 
+import "context"
+
 // Option is a functional option for configuring the Client
 type Option func(*Client)
 
@@ -10,7 +12,8 @@ func WithMiddleware(m Middleware) Option {
 	}
 }
 
-// WithDefaultMaxTokens sets the default max tokens
+// WithDefaultMaxTokens sets a fixed default max tokens instead of deriving
+// it from the model's context window
 func WithDefaultMaxTokens(n int) Option {
 	return func(c *Client) {
 		c.config.DefaultMaxTokens = n
@@ -31,6 +34,80 @@ func WithDefaultModel(model string) Option {
 	}
 }
 
+// WithStreamBuffer sets how many stream events are buffered between the
+// provider and the consumer
+func WithStreamBuffer(size int) Option {
+	return func(c *Client) {
+		c.config.StreamBuffer = size
+	}
+}
+
+// WithBackpressure sets what streams do when the consumer falls behind
+func WithBackpressure(policy BackpressurePolicy) Option {
+	return func(c *Client) {
+		c.config.Backpressure = policy
+	}
+}
+
+// WithStreamRetry reconnects streams that fail mid-response, resuming from
+// the delivered text
+func WithStreamRetry(config StreamRetryConfig) Option {
+	return func(c *Client) {
+		c.config.StreamRetry = &config
+	}
+}
+
+// WithStreamTimestamps stamps every stream event with the time it arrived
+// from the provider, for latency tracing
+func WithStreamTimestamps() Option {
+	return func(c *Client) {
+		c.config.StreamTimestamps = true
+	}
+}
+
+// WithSimulatedStreaming makes Stream call Complete and deliver the answer
+// word by word, for providers or models without streaming support, so
+// stream consumers work the same everywhere
+func WithSimulatedStreaming(config SimulatedStreamConfig) Option {
+	return func(c *Client) {
+		c.config.SimulateStream = &config
+	}
+}
+
+// WithReplay records every request and its outcome in store, so
+// Client.Replay can re-run it. Use it while debugging: records hold the full
+// prompts and responses.
+func WithReplay(store ReplayStore) Option {
+	return func(c *Client) {
+		c.config.Replay = store
+	}
+}
+
+// WithIdempotency makes completions with an idempotency key (see
+// WithIdempotencyKey) return the stored response when the key is retried
+// within the window, instead of calling the provider and billing again.
+// Zero fields use DefaultIdempotencyConfig.
+func WithIdempotency(config IdempotencyConfig) Option {
+	return func(c *Client) {
+		defaults := DefaultIdempotencyConfig()
+		if config.Store == nil {
+			config.Store = defaults.Store
+		}
+		if config.Window <= 0 {
+			config.Window = defaults.Window
+		}
+		c.config.Idempotency = &config
+	}
+}
+
+// WithDryRun makes every call return the provider request as a *DryRun
+// error instead of sending it
+func WithDryRun() Option {
+	return func(c *Client) {
+		c.config.DryRun = true
+	}
+}
+
 // ChatOption is a functional option for configuring a Chat session
 type ChatOption func(*Chat)
 
@@ -41,6 +118,52 @@ func WithSystem(prompt string) ChatOption {
 	}
 }
 
+// WithSystemTemplate sets the system prompt as a Go template rendered
+// every turn with vars plus {{.Locale}} and {{.Language}} (e.g. "de" and
+// "German"), so the prompt follows the conversation's language
+func WithSystemTemplate(tmpl string, vars map[string]any) ChatOption {
+	return func(chat *Chat) {
+		chat.systemTemplate = tmpl
+		chat.systemVars = vars
+	}
+}
+
+// WithContextInjection adds the current date, time and time zone, and
+// optionally a user profile, to the system prompt on every turn, so the
+// model doesn't assume a stale date in long sessions. Zero fields use
+// DefaultContextInjectionConfig.
+func WithContextInjection(config ContextInjectionConfig) ChatOption {
+	defaults := DefaultContextInjectionConfig()
+	if config.Location == nil {
+		config.Location = defaults.Location
+	}
+	if config.Now == nil {
+		config.Now = defaults.Now
+	}
+	if config.Template == "" {
+		config.Template = defaults.Template
+	}
+	return func(chat *Chat) {
+		chat.injection = &config
+	}
+}
+
+// WithLocale fixes the conversation's locale instead of detecting it
+func WithLocale(locale string) ChatOption {
+	return func(chat *Chat) {
+		chat.locale = locale
+	}
+}
+
+// WithLocaleDetector sets how the locale is detected from the first user
+// message (default: a built-in detector returning ISO 639-1 codes); nil
+// disables detection
+func WithLocaleDetector(detect func(text string) string) ChatOption {
+	return func(chat *Chat) {
+		chat.detectLocale = detect
+	}
+}
+
 // WithHistoryLimit sets the maximum number of messages to keep in history
 func WithHistoryLimit(limit int) ChatOption {
 	return func(chat *Chat) {
@@ -55,7 +178,9 @@ func WithMessages(messages []Message) ChatOption {
 	}
 }
 
-// WithMaxTokens sets the maximum tokens for history (token-based truncation)
+// WithMaxTokens sets the token budget for history (token-based truncation).
+// Requests are also built to fit it: the system prompt and current message
+// always go in, then the most recent history, then the conversation summary.
 func WithMaxTokens(maxTokens int) ChatOption {
 	return func(chat *Chat) {
 		chat.maxTokens = maxTokens
@@ -76,3 +201,123 @@ func WithAutocompact(config AutocompactConfig) ChatOption {
 		chat.autocompact = &config
 	}
 }
+
+// WithStreamErrorPolicy sets what Stream keeps in history when a stream
+// fails or ends early (default StreamDiscardTurn)
+func WithStreamErrorPolicy(policy StreamErrorPolicy) ChatOption {
+	return func(chat *Chat) {
+		chat.streamErrorPolicy = policy
+	}
+}
+
+// WithRequestOptions sets request options applied to every turn of the chat,
+// before any per-call options
+func WithRequestOptions(opts ...RequestOption) ChatOption {
+	return func(chat *Chat) {
+		chat.requestOptions = append(chat.requestOptions, opts...)
+	}
+}
+
+// RequestOption is a functional option for a single Complete, Stream,
+// Generate or Chat call. It overrides client defaults for that call only.
+type RequestOption func(*requestOptions)
+
+type requestOptions struct {
+	req            *Request
+	metadata       *RequestMetadata
+	idempotencyKey string
+	stopCondition  StopCondition
+}
+
+// applyRequestOptions returns a copy of req with the options applied, and
+// the context carrying any metadata set by the options
+func applyRequestOptions(ctx context.Context, req *Request, opts []RequestOption) (context.Context, *Request) {
+	if len(opts) == 0 {
+		return ctx, req
+	}
+
+	r := *req
+	ro := &requestOptions{req: &r}
+	for _, opt := range opts {
+		opt(ro)
+	}
+
+	if ro.metadata != nil {
+		ctx = WithRequestMetadata(ctx, *ro.metadata)
+	}
+	if ro.idempotencyKey != "" {
+		ctx = ContextWithIdempotencyKey(ctx, ro.idempotencyKey)
+	}
+	if ro.stopCondition != nil {
+		ctx = context.WithValue(ctx, stopConditionKey{}, ro.stopCondition)
+	}
+	return ctx, &r
+}
+
+// WithModel sets the model for the request
+func WithModel(model string) RequestOption {
+	return func(o *requestOptions) {
+		o.req.Model = model
+	}
+}
+
+// WithTemperature sets the sampling temperature for the request
+func WithTemperature(t float64) RequestOption {
+	return func(o *requestOptions) {
+		o.req.Temperature = t
+	}
+}
+
+// WithMaxOutputTokens sets the maximum tokens to generate for the request
+func WithMaxOutputTokens(n int) RequestOption {
+	return func(o *requestOptions) {
+		o.req.MaxTokens = n
+	}
+}
+
+// WithSystemPrompt sets the system prompt for the request
+func WithSystemPrompt(prompt string) RequestOption {
+	return func(o *requestOptions) {
+		o.req.SystemPrompt = prompt
+	}
+}
+
+// WithStop sets the stop sequences for the request
+func WithStop(stop ...string) RequestOption {
+	return func(o *requestOptions) {
+		o.req.Stop = stop
+	}
+}
+
+// WithMetadata attaches request metadata (user, session, trace IDs, headers),
+// replacing any metadata already in the context
+func WithMetadata(md RequestMetadata) RequestOption {
+	return func(o *requestOptions) {
+		o.metadata = &md
+	}
+}
+
+// WithIdempotencyKey sets the request's idempotency key: retries with the
+// same key return the original response on clients using WithIdempotency.
+// Keys are shared by everyone using the store, so prefix keys taken from
+// callers with their identity.
+func WithIdempotencyKey(key string) RequestOption {
+	return func(o *requestOptions) {
+		o.idempotencyKey = key
+	}
+}
+
+// WithTools sets the tools the model may call for the request
+func WithTools(tools ...Tool) RequestOption {
+	return func(o *requestOptions) {
+		o.req.Tools = tools
+	}
+}
+
+// WithToolChoice sets how the model chooses tools: auto, none, required or
+// a tool name
+func WithToolChoice(choice string) RequestOption {
+	return func(o *requestOptions) {
+		o.req.ToolChoice = choice
+	}
+}
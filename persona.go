@@ -0,0 +1,113 @@
+package simpleai
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/medatechnology/simpleai/template"
+)
+
+// ErrUnknownPersona is returned when no persona is registered under a name
+var ErrUnknownPersona = errors.New("simpleai: unknown persona")
+
+// Persona is a reusable chat setup: a system prompt template together with
+// the model, sampling settings and tools it works best with
+type Persona struct {
+	Name        string
+	Description string
+
+	// System is the system prompt, rendered as a Go template with the
+	// persona's Vars overridden by the caller's vars, and the chat's
+	// {{.Locale}} and {{.Language}}
+	System string
+	Vars   map[string]any
+
+	Model       string  // Empty uses the client default
+	Temperature float64 // 0 uses the client default
+	MaxTokens   int     // 0 uses the client default
+	Tools       []Tool
+
+	// Options are extra chat options, e.g. a history limit or autocompact
+	Options []ChatOption
+}
+
+// ChatOptions renders the persona into chat options
+func (p Persona) ChatOptions(vars map[string]any) ([]ChatOption, error) {
+	data := maps.Clone(p.Vars)
+	if data == nil {
+		data = make(map[string]any)
+	}
+	maps.Copy(data, vars)
+
+	// Rendered once to catch errors; the chat renders it each turn so the
+	// prompt can use {{.Locale}} and {{.Language}}
+	if _, err := template.Prompt(p.System, data); err != nil {
+		return nil, fmt.Errorf("simpleai: persona %s: %w", p.Name, err)
+	}
+
+	var reqOpts []RequestOption
+	if p.Model != "" {
+		reqOpts = append(reqOpts, WithModel(p.Model))
+	}
+	if p.Temperature != 0 {
+		reqOpts = append(reqOpts, WithTemperature(p.Temperature))
+	}
+	if p.MaxTokens != 0 {
+		reqOpts = append(reqOpts, WithMaxOutputTokens(p.MaxTokens))
+	}
+	if len(p.Tools) > 0 {
+		reqOpts = append(reqOpts, WithTools(p.Tools...))
+	}
+
+	opts := []ChatOption{WithSystemTemplate(p.System, data)}
+	if len(reqOpts) > 0 {
+		opts = append(opts, WithRequestOptions(reqOpts...))
+	}
+	return append(opts, p.Options...), nil
+}
+
+var (
+	personasMu sync.RWMutex
+	personas   = make(map[string]Persona)
+)
+
+// RegisterPersona adds or replaces a persona in the global registry
+func RegisterPersona(p Persona) {
+	personasMu.Lock()
+	defer personasMu.Unlock()
+	personas[p.Name] = p
+}
+
+// GetPersona returns a registered persona
+func GetPersona(name string) (Persona, bool) {
+	personasMu.RLock()
+	defer personasMu.RUnlock()
+	p, ok := personas[name]
+	return p, ok
+}
+
+// PersonaNames returns the names of all registered personas, sorted
+func PersonaNames() []string {
+	personasMu.RLock()
+	defer personasMu.RUnlock()
+	return slices.Sorted(maps.Keys(personas))
+}
+
+// NewChatFromPersona creates a chat set up as the named persona. vars fill
+// the persona's system prompt template; opts are applied after the
+// persona's own options.
+func (c *Client) NewChatFromPersona(name string, vars map[string]any, opts ...ChatOption) (*Chat, error) {
+	p, ok := GetPersona(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPersona, name)
+	}
+
+	personaOpts, err := p.ChatOptions(vars)
+	if err != nil {
+		return nil, err
+	}
+	return c.NewChat(append(personaOpts, opts...)...), nil
+}
@@ -0,0 +1,174 @@
+// Package pipeline chains LLM calls and code into multi-step flows
+// (prompt → extract → branch → prompt) with typed values between steps,
+// per-step retries and execution traces.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// StepFunc is the work done by a step; it reads inputs from and writes
+// outputs to the state
+type StepFunc func(ctx context.Context, s *State) error
+
+// Step is a named unit of work in a pipeline
+type Step struct {
+	name    string
+	run     StepFunc
+	retries int
+	backoff time.Duration
+}
+
+// NewStep creates a step from a function
+func NewStep(name string, fn StepFunc) *Step {
+	return &Step{name: name, run: fn}
+}
+
+// Name returns the step name
+func (s *Step) Name() string {
+	return s.name
+}
+
+// WithRetries retries the step up to n more times on error, waiting
+// backoff × attempt between attempts
+func (s *Step) WithRetries(n int, backoff time.Duration) *Step {
+	s.retries = n
+	s.backoff = backoff
+	return s
+}
+
+// Pipeline is an ordered list of steps
+type Pipeline struct {
+	name  string
+	steps []*Step
+}
+
+// New creates a pipeline
+func New(name string, steps ...*Step) *Pipeline {
+	return &Pipeline{name: name, steps: steps}
+}
+
+// Then appends a step
+func (p *Pipeline) Then(step *Step) *Pipeline {
+	p.steps = append(p.steps, step)
+	return p
+}
+
+// Name returns the pipeline name
+func (p *Pipeline) Name() string {
+	return p.name
+}
+
+// Result is the outcome of a pipeline run
+type Result struct {
+	State *State
+	Trace *Trace
+}
+
+// Trace records the execution of a pipeline run
+type Trace struct {
+	Pipeline string
+	Start    time.Time
+	Duration time.Duration
+	Steps    []StepTrace
+}
+
+// StepTrace records the execution of one step
+type StepTrace struct {
+	Step     string         // Step name
+	Path     string         // Step path including enclosing branches, e.g. "route/billing/answer"
+	Start    time.Time      //
+	Duration time.Duration  //
+	Attempts int            // Number of attempts made
+	Error    string         // Final error, if the step failed
+	Outputs  map[string]any // State values the step added or changed
+}
+
+// Run executes the pipeline with the given input values. On error the
+// result still holds the state and trace up to the failing step.
+func (p *Pipeline) Run(ctx context.Context, input map[string]any) (*Result, error) {
+	result := &Result{
+		State: NewState(input),
+		Trace: &Trace{Pipeline: p.name, Start: time.Now()},
+	}
+
+	err := p.run(ctx, result.State, result.Trace, "")
+	result.Trace.Duration = time.Since(result.Trace.Start)
+	return result, err
+}
+
+func (p *Pipeline) run(ctx context.Context, s *State, trace *Trace, prefix string) error {
+	for _, step := range p.steps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := step.execute(ctx, s, trace, prefix+step.name); err != nil {
+			return fmt.Errorf("pipeline %s: step %s: %w", p.name, step.name, err)
+		}
+	}
+	return nil
+}
+
+// Execute runs the step with its retries against s and appends the result
+// to trace. It lets other executors, such as the graph package, reuse steps.
+func (step *Step) Execute(ctx context.Context, s *State, trace *Trace) error {
+	return step.execute(ctx, s, trace, step.name)
+}
+
+func (step *Step) execute(ctx context.Context, s *State, trace *Trace, path string) error {
+	before := s.Values()
+	st := StepTrace{Step: step.name, Path: path, Start: time.Now()}
+
+	// Branch steps append the traces of their sub-steps
+	ctx = withTrace(ctx, trace, path)
+
+	var err error
+	for attempt := 0; attempt <= step.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				err = ctx.Err()
+				attempt = step.retries + 1
+				continue
+			case <-time.After(step.backoff * time.Duration(attempt)):
+			}
+		}
+		st.Attempts++
+		if err = step.run(ctx, s); err == nil {
+			break
+		}
+	}
+
+	st.Duration = time.Since(st.Start)
+	st.Outputs = diff(before, s.Values())
+	if err != nil {
+		st.Error = err.Error()
+	}
+	trace.Steps = append(trace.Steps, st)
+	return err
+}
+
+// diff returns the values in after that are new or changed
+func diff(before, after map[string]any) map[string]any {
+	changed := map[string]any{}
+	for k, v := range after {
+		if old, ok := before[k]; !ok || !reflect.DeepEqual(old, v) {
+			changed[k] = v
+		}
+	}
+	return changed
+}
+
+type traceKey struct{}
+
+type traceContext struct {
+	trace *Trace
+	path  string
+}
+
+func withTrace(ctx context.Context, trace *Trace, path string) context.Context {
+	return context.WithValue(ctx, traceKey{}, traceContext{trace: trace, path: path})
+}
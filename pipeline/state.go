@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// State holds the values passed between steps
+type State struct {
+	mu     sync.RWMutex
+	values map[string]any
+}
+
+// NewState creates a state with initial values
+func NewState(values map[string]any) *State {
+	s := &State{values: make(map[string]any, len(values))}
+	for k, v := range values {
+		s.values[k] = v
+	}
+	return s
+}
+
+// Get returns the value stored under name
+func (s *State) Get(name string) (any, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.values[name]
+	return v, ok
+}
+
+// Set stores a value under name
+func (s *State) Set(name string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[name] = value
+}
+
+// Values returns a copy of all values, e.g. for template data
+func (s *State) Values() map[string]any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	values := make(map[string]any, len(s.values))
+	for k, v := range s.values {
+		values[k] = v
+	}
+	return values
+}
+
+// Key is a typed handle to a state value, used to connect step outputs to
+// the inputs of later steps
+type Key[T any] struct {
+	name string
+}
+
+// NewKey creates a typed key
+func NewKey[T any](name string) Key[T] {
+	return Key[T]{name: name}
+}
+
+// Name returns the key name, which is also the template variable name
+func (k Key[T]) Name() string {
+	return k.name
+}
+
+// Get returns the typed value for key; ok is false if it is missing or
+// cannot be converted to T. Values decoded from JSON (e.g. a restored
+// checkpoint) are converted back to T.
+func Get[T any](s *State, key Key[T]) (T, bool) {
+	var zero T
+	v, ok := s.Get(key.name)
+	if !ok {
+		return zero, false
+	}
+	if t, ok := v.(T); ok {
+		return t, true
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return zero, false
+	}
+	var t T
+	if err := json.Unmarshal(data, &t); err != nil {
+		return zero, false
+	}
+	return t, true
+}
+
+// Set stores a typed value for key
+func Set[T any](s *State, key Key[T], value T) {
+	s.Set(key.name, value)
+}
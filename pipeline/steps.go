@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/template"
+)
+
+// Prompt creates a step that renders prompt as a template over the state
+// values, sends it to the client and stores the reply under out
+func Prompt(name string, client *simpleai.Client, prompt string, out Key[string], opts ...simpleai.RequestOption) *Step {
+	return NewStep(name, func(ctx context.Context, s *State) error {
+		input, err := template.Prompt(prompt, s.Values())
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Complete(ctx, &simpleai.Request{
+			Messages: []simpleai.Message{{Role: simpleai.RoleUser, Content: input}},
+		}, opts...)
+		if err != nil {
+			return err
+		}
+
+		Set(s, out, strings.TrimSpace(resp.Content))
+		return nil
+	})
+}
+
+// Extract creates a step that renders prompt as a template over the state
+// values and decodes the model's JSON reply into a T stored under out
+func Extract[T any](name string, client *simpleai.Client, prompt string, out Key[T], opts ...simpleai.RequestOption) *Step {
+	return NewStep(name, func(ctx context.Context, s *State) error {
+		input, err := template.Prompt(prompt, s.Values())
+		if err != nil {
+			return err
+		}
+
+		var value T
+		if err := client.Extract(ctx, input, &value, opts...); err != nil {
+			return err
+		}
+
+		Set(s, out, value)
+		return nil
+	})
+}
+
+// Transform creates a step that computes out from in with plain code
+func Transform[In, Out any](name string, in Key[In], out Key[Out], fn func(ctx context.Context, value In) (Out, error)) *Step {
+	return NewStep(name, func(ctx context.Context, s *State) error {
+		value, ok := Get(s, in)
+		if !ok {
+			return fmt.Errorf("missing or mistyped input %q", in.Name())
+		}
+
+		result, err := fn(ctx, value)
+		if err != nil {
+			return err
+		}
+
+		Set(s, out, result)
+		return nil
+	})
+}
+
+// Branch creates a step that runs one of several sub-pipelines, chosen from
+// the state. An empty choice skips the branch; an unknown choice is an error.
+// The sub-pipeline's steps appear in the trace under "name/choice/".
+func Branch(name string, choose func(s *State) string, branches map[string]*Pipeline) *Step {
+	return NewStep(name, func(ctx context.Context, s *State) error {
+		choice := choose(s)
+		if choice == "" {
+			return nil
+		}
+
+		p, ok := branches[choice]
+		if !ok {
+			return fmt.Errorf("no branch %q", choice)
+		}
+
+		tc, ok := ctx.Value(traceKey{}).(traceContext)
+		if !ok {
+			tc = traceContext{trace: &Trace{}, path: name}
+		}
+		return p.run(ctx, s, tc.trace, tc.path+"/"+choice+"/")
+	})
+}
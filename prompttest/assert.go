@@ -0,0 +1,115 @@
+package prompttest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/medatechnology/simpleai/agent"
+	"github.com/medatechnology/simpleai/judge"
+)
+
+// Assertion is a check of a case's response. Check returns a short detail
+// for the snapshot, such as a score, and an error if the check fails.
+type Assertion struct {
+	Name  string
+	Check func(ctx context.Context, res *Result) (string, error)
+}
+
+// Contains requires the response to contain text, ignoring case
+func Contains(text string) Assertion {
+	return Assertion{
+		Name: fmt.Sprintf("contains %q", text),
+		Check: func(ctx context.Context, res *Result) (string, error) {
+			if !strings.Contains(strings.ToLower(res.Response.Content), strings.ToLower(text)) {
+				return "", fmt.Errorf("response does not contain %q", text)
+			}
+			return "", nil
+		},
+	}
+}
+
+// NotContains requires the response not to contain text, ignoring case
+func NotContains(text string) Assertion {
+	return Assertion{
+		Name: fmt.Sprintf("does not contain %q", text),
+		Check: func(ctx context.Context, res *Result) (string, error) {
+			if strings.Contains(strings.ToLower(res.Response.Content), strings.ToLower(text)) {
+				return "", fmt.Errorf("response contains %q", text)
+			}
+			return "", nil
+		},
+	}
+}
+
+// Matches requires the response to match a regular expression
+func Matches(pattern string) Assertion {
+	re := regexp.MustCompile(pattern)
+	return Assertion{
+		Name: fmt.Sprintf("matches %s", pattern),
+		Check: func(ctx context.Context, res *Result) (string, error) {
+			if !re.MatchString(res.Response.Content) {
+				return "", fmt.Errorf("response does not match %s", pattern)
+			}
+			return "", nil
+		},
+	}
+}
+
+// JSONSchema requires the response to be JSON valid against a JSON schema,
+// as agent.ValidateArgs checks tool arguments. A surrounding code fence is
+// ignored.
+func JSONSchema(schema map[string]any) Assertion {
+	return Assertion{
+		Name: "matches JSON schema",
+		Check: func(ctx context.Context, res *Result) (string, error) {
+			content := strings.TrimSpace(res.Response.Content)
+			if strings.HasPrefix(content, "```") {
+				content = strings.TrimPrefix(content, "```json")
+				content = strings.TrimPrefix(content, "```")
+				content = strings.TrimSuffix(strings.TrimSpace(content), "```")
+			}
+			if !json.Valid([]byte(content)) {
+				return "", fmt.Errorf("response is not valid JSON")
+			}
+			if _, err := agent.ValidateArgs(schema, json.RawMessage(content)); err != nil {
+				return "", err
+			}
+			return "", nil
+		},
+	}
+}
+
+// JudgeScore requires the judge's overall score of the response to be at
+// least minOverall, from 0 to 1. A config without a client judges with the
+// case's client, so judge calls are recorded and replayed with the case.
+func JudgeScore(config judge.Config, minOverall float64) Assertion {
+	return Assertion{
+		Name: fmt.Sprintf("judge score >= %.2f", minOverall),
+		Check: func(ctx context.Context, res *Result) (string, error) {
+			cfg := config
+			if cfg.Client == nil {
+				cfg.Client = res.Client
+			}
+			result, err := judge.New(cfg).Evaluate(ctx, judge.Input{
+				Prompt:   res.Case.Prompt,
+				Response: res.Response.Content,
+			})
+			if err != nil {
+				return "", err
+			}
+
+			scores := make([]string, len(result.Scores))
+			for i, s := range result.Scores {
+				scores[i] = fmt.Sprintf("%s %g", s.Criterion, s.Score)
+			}
+			detail := fmt.Sprintf("%.2f (%s)", result.Overall, strings.Join(scores, ", "))
+			if result.Overall < minOverall {
+				return detail, fmt.Errorf("judge score %s is below %.2f", detail, minOverall)
+			}
+			return detail, nil
+		},
+	}
+}
@@ -0,0 +1,249 @@
+// Package prompttest runs prompt regression tests under go test. Each case
+// sends a prompt, checks the response with assertions and writes a
+// snapshot file to review in diffs. Cases replay recorded fixtures by
+// default, so they run offline and deterministically in CI; set
+// PROMPTTEST_LIVE=1 to call the real provider, re-record the fixtures and
+// rewrite the snapshots.
+//
+//	func TestPrompts(t *testing.T) {
+//		prompttest.Run(t, prompttest.Config{Provider: provider.NewMistralSimple(os.Getenv("MISTRAL_API_KEY"))},
+//			prompttest.Case{
+//				Name:   "capital",
+//				Prompt: "What is the capital of France?",
+//				Assert: []prompttest.Assertion{prompttest.Contains("Paris")},
+//			},
+//		)
+//	}
+package prompttest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// Environment variables selecting the mode
+const (
+	// LiveEnv, when set, calls the provider and re-records fixtures and
+	// snapshots
+	LiveEnv = "PROMPTTEST_LIVE"
+	// UpdateEnv, when set, rewrites snapshots that differ in replay mode
+	UpdateEnv = "PROMPTTEST_UPDATE"
+)
+
+// Config holds configuration for a test run
+type Config struct {
+	// Provider is called in live mode; replay mode doesn't need it
+	Provider simpleai.Provider
+
+	// ClientOptions configure the client in both modes, e.g. a default
+	// model or temperature
+	ClientOptions []simpleai.Option
+
+	// Dir holds the fixtures (<case>.json) and snapshots (<case>.snap)
+	// (default "testdata/prompttest")
+	Dir string
+
+	// Live calls Provider instead of replaying (default: whether
+	// PROMPTTEST_LIVE is set)
+	Live bool
+
+	// Timeout bounds each case (default 2 minutes)
+	Timeout time.Duration
+}
+
+// DefaultConfig returns the default directory and timeout, in the mode
+// chosen by the environment
+func DefaultConfig() Config {
+	return Config{
+		Dir:     filepath.Join("testdata", "prompttest"),
+		Live:    os.Getenv(LiveEnv) != "",
+		Timeout: 2 * time.Minute,
+	}
+}
+
+// Case is a prompt and what its response must satisfy
+type Case struct {
+	// Name identifies the case in test output and file names
+	Name string
+
+	System   string
+	Messages []simpleai.Message // Earlier conversation, if any
+	Prompt   string             // The user message
+
+	// Options apply to the request, e.g. WithModel
+	Options []simpleai.RequestOption
+
+	Assert []Assertion
+}
+
+// Result is a case's completed request, as seen by assertions
+type Result struct {
+	Case     Case
+	Request  *simpleai.Request
+	Response *simpleai.Response
+
+	// Client is the live or replaying client; assertions make their own
+	// calls with it, so they are recorded and replayed too
+	Client *simpleai.Client
+}
+
+// Run runs each case as a subtest of t
+func Run(t *testing.T, config Config, cases ...Case) {
+	t.Helper()
+	defaults := DefaultConfig()
+	if config.Dir == "" {
+		config.Dir = defaults.Dir
+	}
+	if !config.Live {
+		config.Live = defaults.Live
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			runCase(t, config, c)
+		})
+	}
+}
+
+// runCase sends one case, checks it and records or compares its files
+func runCase(t *testing.T, config Config, c Case) {
+	base := filepath.Join(config.Dir, fileName(c.Name))
+	store := simpleai.NewMemoryReplayStore(0)
+
+	var client *simpleai.Client
+	if config.Live {
+		if config.Provider == nil {
+			t.Fatalf("prompttest: %s is set but Config.Provider is nil", LiveEnv)
+		}
+		client = simpleai.NewClient(config.Provider, append(slices.Clone(config.ClientOptions), simpleai.WithReplay(store))...)
+	} else {
+		if err := loadFixture(base+".json", store); err != nil {
+			t.Fatalf("prompttest: %v (record it with %s=1)", err, LiveEnv)
+		}
+		client = simpleai.NewClient(simpleai.NewReplayProvider(store), config.ClientOptions...)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+	defer cancel()
+
+	req := &simpleai.Request{
+		SystemPrompt: c.System,
+		Messages:     append(slices.Clone(c.Messages), simpleai.Message{Role: simpleai.RoleUser, Content: c.Prompt}),
+	}
+	resp, err := client.Complete(ctx, req, c.Options...)
+	if err != nil {
+		if errors.Is(err, simpleai.ErrNoRecording) {
+			t.Fatalf("prompttest: %v; the prompt changed, re-record it with %s=1", err, LiveEnv)
+		}
+		t.Fatalf("prompttest: %v", err)
+	}
+
+	res := &Result{Case: c, Request: req, Response: resp, Client: client}
+	results := make([]outcome, len(c.Assert))
+	for i, a := range c.Assert {
+		results[i].name = a.Name
+		results[i].detail, results[i].err = a.Check(ctx, res)
+		if results[i].err != nil {
+			t.Errorf("%s: %v", a.Name, results[i].err)
+		}
+	}
+
+	snapshot := renderSnapshot(res, results)
+	if config.Live {
+		if err := saveFixture(base+".json", store); err != nil {
+			t.Fatalf("prompttest: saving fixture: %v", err)
+		}
+		if err := writeFile(base+".snap", snapshot); err != nil {
+			t.Fatalf("prompttest: saving snapshot: %v", err)
+		}
+		return
+	}
+	compareSnapshot(t, base+".snap", snapshot)
+}
+
+// compareSnapshot fails the test if the snapshot file differs, or
+// rewrites it when PROMPTTEST_UPDATE is set
+func compareSnapshot(t *testing.T, path, got string) {
+	t.Helper()
+	want, err := os.ReadFile(path)
+	if err == nil && string(want) == got {
+		return
+	}
+	if os.Getenv(UpdateEnv) != "" || errors.Is(err, os.ErrNotExist) {
+		if err := writeFile(path, got); err != nil {
+			t.Fatalf("prompttest: saving snapshot: %v", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("prompttest: %v", err)
+	}
+	t.Errorf("prompttest: snapshot %s differs (accept with %s=1):\n%s", path, UpdateEnv, diffLines(string(want), got))
+}
+
+// loadFixture reads recorded calls into store
+func loadFixture(path string, store simpleai.ReplayStore) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var records []simpleai.ReplayRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return fmt.Errorf("fixture %s: %w", path, err)
+	}
+	for _, record := range records {
+		store.Save(context.Background(), record)
+	}
+	return nil
+}
+
+// saveFixture writes the recorded calls, oldest first
+func saveFixture(path string, store simpleai.ReplayStore) error {
+	records, err := store.List(context.Background(), 0)
+	if err != nil {
+		return err
+	}
+	slices.Reverse(records)
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, string(data)+"\n")
+}
+
+func writeFile(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0o644)
+}
+
+// fileName turns a case name into a file name
+func fileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, strings.TrimSpace(name))
+	if name == "" {
+		return "case"
+	}
+	return name
+}
@@ -0,0 +1,83 @@
+package prompttest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// outcome is the result of one assertion
+type outcome struct {
+	name   string
+	detail string
+	err    error
+}
+
+// renderSnapshot writes a case's prompt, response and assertion outcomes as
+// plain text, so changes show up as readable diffs
+func renderSnapshot(res *Result, results []outcome) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n", res.Case.Name)
+	if res.Response.Model != "" {
+		fmt.Fprintf(&sb, "model: %s\n", res.Response.Model)
+	}
+	if res.Response.FinishReason != "" {
+		fmt.Fprintf(&sb, "finish_reason: %s\n", res.Response.FinishReason)
+	}
+
+	section(&sb, "system", res.Case.System)
+	for _, msg := range res.Case.Messages {
+		section(&sb, string(msg.Role), msg.Content)
+	}
+	section(&sb, "prompt", res.Case.Prompt)
+	section(&sb, "response", res.Response.Content)
+
+	if len(results) > 0 {
+		sb.WriteString("\n--- assertions\n")
+		for _, r := range results {
+			status := "PASS"
+			if r.err != nil {
+				status = "FAIL"
+			}
+			line := status + " " + r.name
+			if r.detail != "" {
+				line += ": " + r.detail
+			}
+			sb.WriteString(line + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// section adds a titled block, if there is any text
+func section(sb *strings.Builder, title, text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	sb.WriteString("\n--- " + title + "\n" + strings.TrimSpace(text) + "\n")
+}
+
+// diffLines lists the lines that differ between two snapshots, by line
+// number
+func diffLines(want, got string) string {
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+	var sb strings.Builder
+	for i := 0; i < max(len(a), len(b)); i++ {
+		var x, y string
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x == y {
+			continue
+		}
+		if i < len(a) {
+			fmt.Fprintf(&sb, "%4d - %s\n", i+1, x)
+		}
+		if i < len(b) {
+			fmt.Fprintf(&sb, "%4d + %s\n", i+1, y)
+		}
+	}
+	return sb.String()
+}
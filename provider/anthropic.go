@@ -1,7 +1,6 @@
 package provider
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -12,6 +11,7 @@ import (
 	medahttp "github.com/medatechnology/goutil/http"
 	"github.com/medatechnology/goutil/utils"
 	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/internal/sse"
 )
 
 const (
@@ -28,12 +28,19 @@ type AnthropicConfig struct {
 	MaxTokens   int
 	Temperature float64
 	TopP        float64
+
+	// MaxEventSize limits a single stream event in bytes (default 4MB)
+	MaxEventSize int
+
+	// Interceptors see the raw HTTP requests and responses, in order
+	Interceptors []Interceptor
 }
 
 // Anthropic implements the Provider interface for Anthropic's Claude
 type Anthropic struct {
-	config AnthropicConfig
-	client medahttp.HttpClient
+	config  AnthropicConfig
+	client  medahttp.HttpClient
+	headers map[string][]string
 }
 
 // NewAnthropic creates a new Anthropic provider
@@ -51,16 +58,19 @@ func NewAnthropic(config AnthropicConfig) *Anthropic {
 		config.Temperature = 0.7
 	}
 
-	client := medahttp.NewHttp()
-	client.SetHeader(map[string][]string{
+	headers := map[string][]string{
 		"Content-Type":      {"application/json"},
 		"x-api-key":         {config.APIKey},
 		"anthropic-version": {AnthropicAPIVersion},
-	})
+	}
+
+	client := medahttp.NewHttp()
+	client.SetHeader(headers)
 
 	return &Anthropic{
-		config: config,
-		client: client,
+		config:  config,
+		client:  client,
+		headers: headers,
 	}
 }
 
@@ -78,12 +88,25 @@ func (a *Anthropic) Name() string {
 	return "anthropic"
 }
 
+// Capabilities returns the request features the API handles natively
+func (a *Anthropic) Capabilities() simpleai.Capabilities {
+	return simpleai.Capabilities{Tools: true, Documents: true, StreamPrefix: true}
+}
+
+// Model returns the default model
+func (a *Anthropic) Model() string {
+	return a.config.Model
+}
+
 // Complete sends a completion request to Anthropic
 func (a *Anthropic) Complete(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
 	anthropicReq := a.buildRequest(req)
+	if userID := metadataUserID(ctx); userID != "" {
+		anthropicReq.Metadata = &anthropicMetadata{UserID: userID}
+	}
 
 	var anthropicResp anthropicResponse
-	statusCode, err := a.client.Post(
+	statusCode, err := a.httpClient(ctx).Post(
 		a.config.BaseURL+"/v1/messages",
 		anthropicReq,
 		&anthropicResp,
@@ -102,16 +125,22 @@ func (a *Anthropic) Complete(ctx context.Context, req *simpleai.Request) (*simpl
 		)
 	}
 
-	return a.parseResponse(&anthropicResp), nil
+	resp := a.parseResponse(&anthropicResp)
+	resp.Content = anthropicPrefix(req) + resp.Content
+	applyStop(req, resp)
+	return resp, nil
 }
 
 // Stream sends a streaming completion request
 func (a *Anthropic) Stream(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
 	anthropicReq := a.buildRequest(req)
+	if userID := metadataUserID(ctx); userID != "" {
+		anthropicReq.Metadata = &anthropicMetadata{UserID: userID}
+	}
 	anthropicReq.Stream = true
 
 	// Use goutil PostStream for raw response access
-	resp, err := a.client.PostStream(a.config.BaseURL+"/v1/messages", anthropicReq)
+	resp, err := a.httpClient(ctx).PostStream(a.config.BaseURL+"/v1/messages", anthropicReq)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -124,7 +153,7 @@ func (a *Anthropic) Stream(ctx context.Context, req *simpleai.Request) (<-chan s
 	out := make(chan simpleai.StreamEvent)
 	go a.streamResponse(ctx, resp.Body, out)
 
-	return out, nil
+	return stopStream(req, prefixStream(anthropicPrefix(req), out)), nil
 }
 
 // CountTokens estimates token count (approximate)
@@ -133,6 +162,11 @@ func (a *Anthropic) CountTokens(text string) int {
 	return len(text) / 4
 }
 
+// httpClient returns the HTTP client to use for a request
+func (a *Anthropic) httpClient(ctx context.Context) httpDoer {
+	return requestClient(ctx, a.client, a.headers, a.config.Interceptors)
+}
+
 // Internal types for Anthropic API
 type anthropicRequest struct {
 	Model       string             `json:"model"`
@@ -143,11 +177,56 @@ type anthropicRequest struct {
 	TopP        float64            `json:"top_p,omitempty"`
 	Stream      bool               `json:"stream,omitempty"`
 	Stop        []string           `json:"stop_sequences,omitempty"`
+	Metadata    *anthropicMetadata `json:"metadata,omitempty"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+	ToolChoice  *anthropicChoice   `json:"tool_choice,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type anthropicChoice struct {
+	Type string `json:"type"` // auto, any, none or tool
+	Name string `json:"name,omitempty"`
+}
+
+type anthropicMetadata struct {
+	UserID string `json:"user_id,omitempty"`
 }
 
 type anthropicMessage struct {
 	Role    string `json:"role"`
-	Content string `json:"content"`
+	Content any    `json:"content"` // string, or []anthropicInputBlock for documents and tool use
+}
+
+// anthropicInputBlock is a content block sent in a request message
+type anthropicInputBlock struct {
+	Type      string                   `json:"type"`
+	Text      string                   `json:"text,omitempty"`
+	Source    *anthropicDocumentSource `json:"source,omitempty"`
+	Title     string                   `json:"title,omitempty"`
+	Context   string                   `json:"context,omitempty"`
+	Citations *anthropicCitationConfig `json:"citations,omitempty"`
+
+	// tool_use and tool_result blocks
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type anthropicDocumentSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicCitationConfig struct {
+	Enabled bool `json:"enabled"`
 }
 
 type anthropicResponse struct {
@@ -162,8 +241,29 @@ type anthropicResponse struct {
 }
 
 type anthropicContentBlock struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type      string              `json:"type"`
+	Text      string              `json:"text"`
+	Citations []anthropicCitation `json:"citations,omitempty"`
+
+	// tool_use blocks
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+// anthropicCitation is a cited span; which location fields are set depends on Type
+// (char_location, page_location or content_block_location)
+type anthropicCitation struct {
+	Type            string `json:"type"`
+	CitedText       string `json:"cited_text"`
+	DocumentIndex   int    `json:"document_index"`
+	DocumentTitle   string `json:"document_title"`
+	StartCharIndex  int    `json:"start_char_index"`
+	EndCharIndex    int    `json:"end_char_index"`
+	StartPageNumber int    `json:"start_page_number"`
+	EndPageNumber   int    `json:"end_page_number"`
+	StartBlockIndex int    `json:"start_block_index"`
+	EndBlockIndex   int    `json:"end_block_index"`
 }
 
 type anthropicUsage struct {
@@ -190,9 +290,12 @@ type anthropicStreamEvent struct {
 }
 
 type anthropicDelta struct {
-	Type       string `json:"type"`
-	Text       string `json:"text"`
-	StopReason string `json:"stop_reason,omitempty"`
+	Type         string             `json:"type"`
+	Text         string             `json:"text"`
+	PartialJSON  string             `json:"partial_json,omitempty"` // input_json_delta
+	Citation     *anthropicCitation `json:"citation,omitempty"`     // citations_delta
+	StopReason   string             `json:"stop_reason,omitempty"`
+	StopSequence string             `json:"stop_sequence,omitempty"`
 }
 
 func (a *Anthropic) buildRequest(req *simpleai.Request) *anthropicRequest {
@@ -201,12 +304,20 @@ func (a *Anthropic) buildRequest(req *simpleai.Request) *anthropicRequest {
 
 	for _, msg := range req.Messages {
 		if msg.Role == simpleai.RoleSystem {
-			systemPrompt = msg.Content
+			systemPrompt = joinSystem(systemPrompt, msg.Content)
 			continue
 		}
 		messages = append(messages, anthropicMessage{
-			Role:    string(msg.Role),
-			Content: msg.Content,
+			Role:    anthropicRole(msg.Role),
+			Content: anthropicContent(msg),
+		})
+	}
+
+	// Prefill: the model continues the final assistant turn
+	if prefix := anthropicPrefix(req); prefix != "" {
+		messages = append(messages, anthropicMessage{
+			Role:    "assistant",
+			Content: prefix,
 		})
 	}
 
@@ -238,7 +349,105 @@ func (a *Anthropic) buildRequest(req *simpleai.Request) *anthropicRequest {
 		Temperature: temp,
 		TopP:        req.TopP,
 		Stop:        req.Stop,
+		Tools:       anthropicTools(req.Tools),
+		ToolChoice:  anthropicToolChoice(req.ToolChoice),
+	}
+}
+
+func anthropicTools(tools []simpleai.Tool) []anthropicTool {
+	if len(tools) == 0 {
+		return nil
+	}
+	out := make([]anthropicTool, len(tools))
+	for i, tool := range tools {
+		out[i] = anthropicTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: toolParameters(tool),
+		}
 	}
+	return out
+}
+
+// anthropicToolChoice maps a tool choice; Anthropic calls "required" "any"
+func anthropicToolChoice(choice string) *anthropicChoice {
+	switch choice {
+	case "":
+		return nil
+	case simpleai.ToolChoiceAuto, simpleai.ToolChoiceNone:
+		return &anthropicChoice{Type: choice}
+	case simpleai.ToolChoiceRequired:
+		return &anthropicChoice{Type: "any"}
+	default:
+		return &anthropicChoice{Type: "tool", Name: choice}
+	}
+}
+
+// anthropicPrefix returns the assistant prefix; the API rejects a final
+// assistant turn ending in whitespace
+func anthropicPrefix(req *simpleai.Request) string {
+	return strings.TrimRight(req.AssistantPrefix, " \t\n")
+}
+
+// anthropicRole maps roles to Anthropic's; tool results are sent by the user
+func anthropicRole(role simpleai.Role) string {
+	if role == simpleai.RoleTool {
+		return "user"
+	}
+	return string(role)
+}
+
+// anthropicContent returns the message content, expanding attached documents
+// into citation-enabled document blocks followed by the text, and tool calls
+// and results into tool_use and tool_result blocks
+func anthropicContent(msg simpleai.Message) any {
+	if msg.Role == simpleai.RoleTool {
+		return []anthropicInputBlock{{
+			Type:      "tool_result",
+			ToolUseID: msg.ToolCallID,
+			Content:   msg.Content,
+		}}
+	}
+
+	if len(msg.ToolCalls) > 0 {
+		blocks := make([]anthropicInputBlock, 0, len(msg.ToolCalls)+1)
+		if msg.Content != "" {
+			blocks = append(blocks, anthropicInputBlock{Type: "text", Text: msg.Content})
+		}
+		for _, call := range msg.ToolCalls {
+			blocks = append(blocks, anthropicInputBlock{
+				Type:  "tool_use",
+				ID:    call.ID,
+				Name:  call.Name,
+				Input: toolArguments(call.Arguments),
+			})
+		}
+		return blocks
+	}
+
+	content := speakerContent(msg)
+	if len(msg.Documents) == 0 {
+		return content
+	}
+
+	blocks := make([]anthropicInputBlock, 0, len(msg.Documents)+1)
+	for _, doc := range msg.Documents {
+		blocks = append(blocks, anthropicInputBlock{
+			Type: "document",
+			Source: &anthropicDocumentSource{
+				Type:      "text",
+				MediaType: "text/plain",
+				Data:      doc.Content,
+			},
+			Title:     doc.Title,
+			Context:   doc.Context,
+			Citations: &anthropicCitationConfig{Enabled: true},
+		})
+	}
+	if content != "" {
+		blocks = append(blocks, anthropicInputBlock{Type: "text", Text: content})
+	}
+	return blocks
 }
 
 func (a *Anthropic) handleError(resp *http.Response) error {
@@ -264,9 +473,21 @@ func (a *Anthropic) handleError(resp *http.Response) error {
 
 func (a *Anthropic) parseResponse(resp *anthropicResponse) *simpleai.Response {
 	var content string
+	var citations []simpleai.Citation
+	var toolCalls []simpleai.ToolCall
 	for _, block := range resp.Content {
-		if block.Type == "text" {
+		switch block.Type {
+		case "text":
 			content += block.Text
+			for _, c := range block.Citations {
+				citations = append(citations, c.toCitation())
+			}
+		case "tool_use":
+			toolCalls = append(toolCalls, simpleai.ToolCall{
+				ID:        block.ID,
+				Name:      block.Name,
+				Arguments: string(block.Input),
+			})
 		}
 	}
 
@@ -274,6 +495,9 @@ func (a *Anthropic) parseResponse(resp *anthropicResponse) *simpleai.Response {
 		Content:      content,
 		Model:        resp.Model,
 		FinishReason: resp.StopReason,
+		StopSequence: resp.StopSequence,
+		Citations:    citations,
+		ToolCalls:    toolCalls,
 		Usage: simpleai.Usage{
 			PromptTokens:     resp.Usage.InputTokens,
 			CompletionTokens: resp.Usage.OutputTokens,
@@ -282,12 +506,50 @@ func (a *Anthropic) parseResponse(resp *anthropicResponse) *simpleai.Response {
 	}
 }
 
+// toCitation converts an Anthropic citation to the common format
+func (c anthropicCitation) toCitation() simpleai.Citation {
+	citation := simpleai.Citation{
+		DocumentIndex: c.DocumentIndex,
+		DocumentTitle: c.DocumentTitle,
+		CitedText:     c.CitedText,
+	}
+	switch c.Type {
+	case "char_location":
+		citation.Start, citation.End = c.StartCharIndex, c.EndCharIndex
+	case "page_location":
+		citation.Start, citation.End = c.StartPageNumber, c.EndPageNumber
+	case "content_block_location":
+		citation.Start, citation.End = c.StartBlockIndex, c.EndBlockIndex
+	}
+	return citation
+}
+
 func (a *Anthropic) streamResponse(ctx context.Context, body io.ReadCloser, out chan<- simpleai.StreamEvent) {
 	defer close(out)
 	defer body.Close()
 
-	scanner := bufio.NewScanner(body)
-	for scanner.Scan() {
+	// Input tokens come with message_start, output tokens with message_delta
+	var usage simpleai.Usage
+	// Tool calls start with a tool_use block and stream their input as JSON
+	// fragments; calls maps block indexes to toolCalls
+	var toolCalls []simpleai.ToolCall
+	calls := make(map[int]int)
+	var citations []simpleai.Citation
+	final := func(event simpleai.StreamEvent) simpleai.StreamEvent {
+		if usage.PromptTokens > 0 || usage.CompletionTokens > 0 {
+			usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+			event.Usage = &usage
+		}
+		for i := range toolCalls {
+			toolCalls[i].Arguments = string(toolArguments(toolCalls[i].Arguments))
+		}
+		event.ToolCalls = toolCalls
+		event.Citations = citations
+		return event
+	}
+
+	events := sse.NewReader(body, a.config.MaxEventSize)
+	for events.Next() {
 		select {
 		case <-ctx.Done():
 			out <- simpleai.StreamEvent{Error: ctx.Err(), Done: true}
@@ -295,14 +557,9 @@ func (a *Anthropic) streamResponse(ctx context.Context, body io.ReadCloser, out
 		default:
 		}
 
-		line := scanner.Text()
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
-
-		data := strings.TrimPrefix(line, "data: ")
+		data := events.Event().Data
 		if data == "[DONE]" {
-			out <- simpleai.StreamEvent{Done: true}
+			out <- final(simpleai.StreamEvent{Done: true})
 			return
 		}
 
@@ -312,25 +569,52 @@ func (a *Anthropic) streamResponse(ctx context.Context, body io.ReadCloser, out
 		}
 
 		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				usage.PromptTokens = event.Message.Usage.InputTokens
+			}
+		case "content_block_start":
+			if block := event.ContentBlock; block != nil && block.Type == "tool_use" {
+				calls[event.Index] = len(toolCalls)
+				toolCalls = append(toolCalls, simpleai.ToolCall{ID: block.ID, Name: block.Name})
+			}
 		case "content_block_delta":
-			if event.Delta != nil && event.Delta.Text != "" {
-				out <- simpleai.StreamEvent{Content: event.Delta.Text}
+			if event.Delta == nil {
+				continue
+			}
+			switch event.Delta.Type {
+			case "input_json_delta":
+				if i, ok := calls[event.Index]; ok {
+					toolCalls[i].Arguments += event.Delta.PartialJSON
+				}
+			case "citations_delta":
+				if event.Delta.Citation != nil {
+					citations = append(citations, event.Delta.Citation.toCitation())
+				}
+			default:
+				if event.Delta.Text != "" {
+					out <- simpleai.StreamEvent{Content: event.Delta.Text}
+				}
 			}
 		case "message_delta":
+			if event.Usage != nil {
+				usage.CompletionTokens = event.Usage.OutputTokens
+			}
 			if event.Delta != nil && event.Delta.StopReason != "" {
-				out <- simpleai.StreamEvent{
+				out <- final(simpleai.StreamEvent{
 					Done:         true,
 					FinishReason: event.Delta.StopReason,
-				}
+					StopSequence: event.Delta.StopSequence,
+				})
 				return
 			}
 		case "message_stop":
-			out <- simpleai.StreamEvent{Done: true}
+			out <- final(simpleai.StreamEvent{Done: true})
 			return
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
+	if err := events.Err(); err != nil {
 		out <- simpleai.StreamEvent{Error: err, Done: true}
 	}
 }
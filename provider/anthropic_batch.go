@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// Batch processing statuses reported by the Message Batches API
+const (
+	AnthropicBatchInProgress = "in_progress"
+	AnthropicBatchCanceling  = "canceling"
+	AnthropicBatchEnded      = "ended"
+)
+
+// AnthropicBatchRequest is a single request in a message batch
+type AnthropicBatchRequest struct {
+	CustomID string // Caller-chosen ID used to match results
+	Request  *simpleai.Request
+}
+
+// AnthropicBatch describes a message batch
+type AnthropicBatch struct {
+	ID               string                      `json:"id"`
+	Type             string                      `json:"type"`
+	ProcessingStatus string                      `json:"processing_status"`
+	RequestCounts    AnthropicBatchRequestCounts `json:"request_counts"`
+	CreatedAt        string                      `json:"created_at"`
+	EndedAt          string                      `json:"ended_at"`
+	ExpiresAt        string                      `json:"expires_at"`
+	ResultsURL       string                      `json:"results_url"`
+}
+
+// AnthropicBatchRequestCounts tallies batch requests by state
+type AnthropicBatchRequestCounts struct {
+	Processing int `json:"processing"`
+	Succeeded  int `json:"succeeded"`
+	Errored    int `json:"errored"`
+	Canceled   int `json:"canceled"`
+	Expired    int `json:"expired"`
+}
+
+// AnthropicBatchResult is the outcome of one request in a finished batch
+type AnthropicBatchResult struct {
+	CustomID string
+	Type     string             // succeeded, errored, canceled or expired
+	Response *simpleai.Response // Set when Type is succeeded
+	Error    error              // Set when Type is errored
+}
+
+type anthropicBatchCreate struct {
+	Requests []anthropicBatchItem `json:"requests"`
+}
+
+type anthropicBatchItem struct {
+	CustomID string            `json:"custom_id"`
+	Params   *anthropicRequest `json:"params"`
+}
+
+type anthropicBatchLine struct {
+	CustomID string `json:"custom_id"`
+	Result   struct {
+		Type    string                  `json:"type"`
+		Message *anthropicResponse      `json:"message"`
+		Error   *anthropicErrorResponse `json:"error"`
+	} `json:"result"`
+}
+
+// CreateBatch submits requests to the Message Batches API for asynchronous
+// processing at reduced cost. Poll GetBatch until ProcessingStatus is
+// AnthropicBatchEnded, then read BatchResults.
+func (a *Anthropic) CreateBatch(ctx context.Context, requests []AnthropicBatchRequest) (*AnthropicBatch, error) {
+	body := anthropicBatchCreate{
+		Requests: make([]anthropicBatchItem, 0, len(requests)),
+	}
+	for _, r := range requests {
+		body.Requests = append(body.Requests, anthropicBatchItem{
+			CustomID: r.CustomID,
+			Params:   a.buildRequest(r.Request),
+		})
+	}
+
+	var batch AnthropicBatch
+	statusCode, err := a.httpClient(ctx).Post(a.config.BaseURL+"/v1/messages/batches", body, &batch, nil)
+	if err != nil {
+		return nil, fmt.Errorf("batch request failed: %w", err)
+	}
+	if statusCode != 200 {
+		return nil, simpleai.NewProviderError("anthropic", int(statusCode), "batch request failed", "http_error")
+	}
+
+	return &batch, nil
+}
+
+// GetBatch retrieves the current state of a message batch
+func (a *Anthropic) GetBatch(ctx context.Context, batchID string) (*AnthropicBatch, error) {
+	var batch AnthropicBatch
+	statusCode, err := a.httpClient(ctx).Get(a.config.BaseURL+"/v1/messages/batches/"+batchID, &batch, nil)
+	if err != nil {
+		return nil, fmt.Errorf("batch request failed: %w", err)
+	}
+	if statusCode != 200 {
+		return nil, simpleai.NewProviderError("anthropic", int(statusCode), "batch request failed", "http_error")
+	}
+
+	return &batch, nil
+}
+
+// CancelBatch cancels a message batch that is still processing
+func (a *Anthropic) CancelBatch(ctx context.Context, batchID string) (*AnthropicBatch, error) {
+	var batch AnthropicBatch
+	statusCode, err := a.httpClient(ctx).Post(a.config.BaseURL+"/v1/messages/batches/"+batchID+"/cancel", nil, &batch, nil)
+	if err != nil {
+		return nil, fmt.Errorf("batch request failed: %w", err)
+	}
+	if statusCode != 200 {
+		return nil, simpleai.NewProviderError("anthropic", int(statusCode), "batch request failed", "http_error")
+	}
+
+	return &batch, nil
+}
+
+// BatchResults downloads the results of an ended batch.
+// Results are returned in the order the API provides, which may differ from
+// submission order; match them by CustomID.
+func (a *Anthropic) BatchResults(ctx context.Context, batchID string) ([]AnthropicBatchResult, error) {
+	batch, err := a.GetBatch(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch.ResultsURL == "" {
+		return nil, fmt.Errorf("batch %s has no results yet (status %s)", batchID, batch.ProcessingStatus)
+	}
+
+	resp, err := a.httpClient(ctx).GetStream(batch.ResultsURL)
+	if err != nil {
+		return nil, fmt.Errorf("batch results request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, a.handleError(resp)
+	}
+
+	var results []AnthropicBatchResult
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var line anthropicBatchLine
+		if err := decoder.Decode(&line); err == io.EOF {
+			break
+		} else if err != nil {
+			return results, fmt.Errorf("failed to decode batch results: %w", err)
+		}
+
+		result := AnthropicBatchResult{
+			CustomID: line.CustomID,
+			Type:     line.Result.Type,
+		}
+		if line.Result.Message != nil {
+			result.Response = a.parseResponse(line.Result.Message)
+		}
+		if line.Result.Error != nil {
+			result.Error = simpleai.NewProviderError(
+				"anthropic",
+				0,
+				line.Result.Error.Error.Message,
+				line.Result.Error.Error.Type,
+			)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+)
+
+// GzipConfig holds configuration for request compression
+type GzipConfig struct {
+	// MinBytes is the smallest body worth compressing (default 32KB);
+	// short prompts are sent as is
+	MinBytes int
+
+	// Level is the gzip level (default gzip.DefaultCompression)
+	Level int
+}
+
+// DefaultGzipConfig returns the default compression settings
+func DefaultGzipConfig() GzipConfig {
+	return GzipConfig{
+		MinBytes: 32 << 10,
+		Level:    gzip.DefaultCompression,
+	}
+}
+
+// NewGzipInterceptor creates an interceptor that gzips large request
+// bodies, such as RAG-stuffed prompts. Use it only with APIs that accept
+// "Content-Encoding: gzip" requests, such as Google's (Gemini, Vertex AI),
+// or behind a gateway that decompresses them.
+func NewGzipInterceptor(config GzipConfig) Interceptor {
+	if config.MinBytes <= 0 {
+		config.MinBytes = DefaultGzipConfig().MinBytes
+	}
+	if config.Level == 0 {
+		config.Level = DefaultGzipConfig().Level
+	}
+	return RequestInterceptorFunc(func(req *http.Request) error {
+		if req.GetBody == nil || req.ContentLength < int64(config.MinBytes) || req.Header.Get("Content-Encoding") != "" {
+			return nil
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		var buf bytes.Buffer
+		zw, err := gzip.NewWriterLevel(&buf, config.Level)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(zw, body); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+
+		compressed := buf.Bytes()
+		req.Body = io.NopCloser(bytes.NewReader(compressed))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(compressed)), nil
+		}
+		req.ContentLength = int64(len(compressed))
+		req.Header.Set("Content-Encoding", "gzip")
+		return nil
+	})
+}
+
+// NewGzipInterceptorSimple creates a gzip interceptor with the default
+// settings
+func NewGzipInterceptorSimple() Interceptor {
+	return NewGzipInterceptor(DefaultGzipConfig())
+}
@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	medahttp "github.com/medatechnology/goutil/http"
+	"github.com/medatechnology/simpleai"
+)
+
+// dryRunClient is an httpDoer that returns each request as a
+// *simpleai.DryRun error instead of sending it
+type dryRunClient struct {
+	headers map[string][]string
+}
+
+func (c *dryRunClient) Post(url string, body any, _ any, _ any) (medahttp.StatusCode, error) {
+	return 0, c.dryRun(http.MethodPost, url, body)
+}
+
+func (c *dryRunClient) Get(url string, _ any, _ any) (medahttp.StatusCode, error) {
+	return 0, c.dryRun(http.MethodGet, url, nil)
+}
+
+func (c *dryRunClient) PostStream(url string, data any) (*http.Response, error) {
+	return nil, c.dryRun(http.MethodPost, url, data)
+}
+
+func (c *dryRunClient) GetStream(url string) (*http.Response, error) {
+	return nil, c.dryRun(http.MethodGet, url, nil)
+}
+
+func (c *dryRunClient) dryRun(method, rawURL string, body any) error {
+	dry := &simpleai.DryRun{
+		Method: method,
+		URL:    redactURL(rawURL),
+		Header: make(http.Header, len(c.headers)),
+	}
+	for k, v := range c.headers {
+		if secretHeader(k) {
+			v = []string{"REDACTED"}
+		}
+		dry.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+	}
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		dry.Body = payload
+	}
+	return dry
+}
+
+func secretHeader(name string) bool {
+	switch strings.ToLower(name) {
+	case "authorization", "x-api-key", "x-goog-api-key", "api-key":
+		return true
+	}
+	return false
+}
+
+// redactURL hides API keys passed in the query string
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || !u.Query().Has("key") {
+		return rawURL
+	}
+	q := u.Query()
+	q.Set("key", "REDACTED")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
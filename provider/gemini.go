@@ -1,17 +1,16 @@
 package provider
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 
 	medahttp "github.com/medatechnology/goutil/http"
 	"github.com/medatechnology/goutil/utils"
 	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/internal/sse"
 )
 
 const (
@@ -27,12 +26,19 @@ type GeminiConfig struct {
 	MaxTokens   int
 	Temperature float64
 	TopP        float64
+
+	// MaxEventSize limits a single stream event in bytes (default 4MB)
+	MaxEventSize int
+
+	// Interceptors see the raw HTTP requests and responses, in order
+	Interceptors []Interceptor
 }
 
 // Gemini implements the Provider interface for Google's Gemini
 type Gemini struct {
-	config GeminiConfig
-	client medahttp.HttpClient
+	config  GeminiConfig
+	client  medahttp.HttpClient
+	headers map[string][]string
 }
 
 // NewGemini creates a new Gemini provider
@@ -50,14 +56,17 @@ func NewGemini(config GeminiConfig) *Gemini {
 		config.Temperature = 0.7
 	}
 
-	client := medahttp.NewHttp()
-	client.SetHeader(map[string][]string{
+	headers := map[string][]string{
 		"Content-Type": {"application/json"},
-	})
+	}
+
+	client := medahttp.NewHttp()
+	client.SetHeader(headers)
 
 	return &Gemini{
-		config: config,
-		client: client,
+		config:  config,
+		client:  client,
+		headers: headers,
 	}
 }
 
@@ -75,9 +84,19 @@ func (g *Gemini) Name() string {
 	return "gemini"
 }
 
+// Capabilities returns the request features the API handles natively
+func (g *Gemini) Capabilities() simpleai.Capabilities {
+	return simpleai.Capabilities{Tools: true}
+}
+
+// Model returns the default model
+func (g *Gemini) Model() string {
+	return g.config.Model
+}
+
 // Complete sends a completion request to Gemini
 func (g *Gemini) Complete(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
-	geminiReq := g.buildRequest(req)
+	geminiReq := g.buildRequest(emulatePrefix(req))
 
 	model := req.Model
 	if model == "" {
@@ -88,7 +107,7 @@ func (g *Gemini) Complete(ctx context.Context, req *simpleai.Request) (*simpleai
 		g.config.BaseURL, model, g.config.APIKey)
 
 	var geminiResp geminiResponse
-	statusCode, err := g.client.Post(url, geminiReq, &geminiResp, nil)
+	statusCode, err := g.httpClient(ctx).Post(url, geminiReq, &geminiResp, nil)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -102,12 +121,15 @@ func (g *Gemini) Complete(ctx context.Context, req *simpleai.Request) (*simpleai
 		)
 	}
 
-	return g.parseResponse(&geminiResp, model), nil
+	resp := g.parseResponse(&geminiResp, model)
+	resp.Content = ensurePrefix(resp.Content, req.AssistantPrefix)
+	applyStop(req, resp)
+	return resp, nil
 }
 
 // Stream sends a streaming completion request
 func (g *Gemini) Stream(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
-	geminiReq := g.buildRequest(req)
+	geminiReq := g.buildRequest(emulatePrefix(req))
 
 	model := req.Model
 	if model == "" {
@@ -118,7 +140,7 @@ func (g *Gemini) Stream(ctx context.Context, req *simpleai.Request) (<-chan simp
 		g.config.BaseURL, model, g.config.APIKey)
 
 	// Use goutil PostStream for raw response access
-	resp, err := g.client.PostStream(url, geminiReq)
+	resp, err := g.httpClient(ctx).PostStream(url, geminiReq)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -131,7 +153,7 @@ func (g *Gemini) Stream(ctx context.Context, req *simpleai.Request) (<-chan simp
 	out := make(chan simpleai.StreamEvent)
 	go g.streamResponse(ctx, resp.Body, out)
 
-	return out, nil
+	return stopStream(req, out), nil
 }
 
 // CountTokens estimates token count
@@ -139,11 +161,35 @@ func (g *Gemini) CountTokens(text string) int {
 	return len(text) / 4
 }
 
+// httpClient returns the HTTP client to use for a request
+func (g *Gemini) httpClient(ctx context.Context) httpDoer {
+	return requestClient(ctx, g.client, g.headers, g.config.Interceptors)
+}
+
 // Internal types for Gemini API
 type geminiRequest struct {
-	Contents          []geminiContent  `json:"contents"`
-	SystemInstruction *geminiContent   `json:"systemInstruction,omitempty"`
-	GenerationConfig  geminiGenConfig  `json:"generationConfig,omitempty"`
+	Contents          []geminiContent   `json:"contents"`
+	SystemInstruction *geminiContent    `json:"systemInstruction,omitempty"`
+	GenerationConfig  geminiGenConfig   `json:"generationConfig,omitempty"`
+	Tools             []geminiTool      `json:"tools,omitempty"`
+	ToolConfig        *geminiToolConfig `json:"toolConfig,omitempty"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+type geminiToolConfig struct {
+	FunctionCallingConfig struct {
+		Mode                 string   `json:"mode"` // AUTO, ANY or NONE
+		AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+	} `json:"functionCallingConfig"`
 }
 
 type geminiContent struct {
@@ -152,7 +198,19 @@ type geminiContent struct {
 }
 
 type geminiPart struct {
-	Text string `json:"text"`
+	Text             string                  `json:"text,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiFunctionCall struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
 }
 
 type geminiGenConfig struct {
@@ -189,30 +247,24 @@ type geminiErrorResponse struct {
 
 func (g *Gemini) buildRequest(req *simpleai.Request) *geminiRequest {
 	contents := make([]geminiContent, 0, len(req.Messages))
-	var systemContent *geminiContent
+	var system string
 
 	for _, msg := range req.Messages {
 		if msg.Role == simpleai.RoleSystem {
-			systemContent = &geminiContent{
-				Parts: []geminiPart{{Text: msg.Content}},
-			}
+			system = joinSystem(system, msg.Content)
 			continue
 		}
 
-		role := "user"
-		if msg.Role == simpleai.RoleAssistant {
-			role = "model"
-		}
-
-		contents = append(contents, geminiContent{
-			Role:  role,
-			Parts: []geminiPart{{Text: msg.Content}},
-		})
+		contents = append(contents, geminiMessageContent(msg))
 	}
 
 	if req.SystemPrompt != "" {
+		system = req.SystemPrompt
+	}
+	var systemContent *geminiContent
+	if system != "" {
 		systemContent = &geminiContent{
-			Parts: []geminiPart{{Text: req.SystemPrompt}},
+			Parts: []geminiPart{{Text: system}},
 		}
 	}
 
@@ -226,7 +278,7 @@ func (g *Gemini) buildRequest(req *simpleai.Request) *geminiRequest {
 		temp = g.config.Temperature
 	}
 
-	return &geminiRequest{
+	geminiReq := &geminiRequest{
 		Contents:          contents,
 		SystemInstruction: systemContent,
 		GenerationConfig: geminiGenConfig{
@@ -236,6 +288,77 @@ func (g *Gemini) buildRequest(req *simpleai.Request) *geminiRequest {
 			StopSequences:   req.Stop,
 		},
 	}
+
+	if len(req.Tools) > 0 {
+		decls := make([]geminiFunctionDeclaration, len(req.Tools))
+		for i, tool := range req.Tools {
+			decls[i] = geminiFunctionDeclaration{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			}
+		}
+		geminiReq.Tools = []geminiTool{{FunctionDeclarations: decls}}
+		geminiReq.ToolConfig = geminiToolChoice(req.ToolChoice)
+	}
+
+	return geminiReq
+}
+
+// geminiToolChoice maps a tool choice to a function calling mode; a tool
+// name becomes ANY restricted to that function
+func geminiToolChoice(choice string) *geminiToolConfig {
+	config := &geminiToolConfig{}
+	switch choice {
+	case "":
+		return nil
+	case simpleai.ToolChoiceAuto:
+		config.FunctionCallingConfig.Mode = "AUTO"
+	case simpleai.ToolChoiceNone:
+		config.FunctionCallingConfig.Mode = "NONE"
+	case simpleai.ToolChoiceRequired:
+		config.FunctionCallingConfig.Mode = "ANY"
+	default:
+		config.FunctionCallingConfig.Mode = "ANY"
+		config.FunctionCallingConfig.AllowedFunctionNames = []string{choice}
+	}
+	return config
+}
+
+// geminiMessageContent converts a message to Gemini content. Tool calls become
+// functionCall parts and tool results functionResponse parts.
+func geminiMessageContent(msg simpleai.Message) geminiContent {
+	switch {
+	case msg.Role == simpleai.RoleTool:
+		return geminiContent{
+			Role: "user",
+			Parts: []geminiPart{{
+				FunctionResponse: &geminiFunctionResponse{
+					Name:     msg.Name,
+					Response: map[string]any{"content": msg.Content},
+				},
+			}},
+		}
+	case msg.Role == simpleai.RoleAssistant:
+		parts := make([]geminiPart, 0, len(msg.ToolCalls)+1)
+		if msg.Content != "" || len(msg.ToolCalls) == 0 {
+			parts = append(parts, geminiPart{Text: speakerContent(msg)})
+		}
+		for _, call := range msg.ToolCalls {
+			parts = append(parts, geminiPart{
+				FunctionCall: &geminiFunctionCall{
+					Name: call.Name,
+					Args: toolArguments(call.Arguments),
+				},
+			})
+		}
+		return geminiContent{Role: "model", Parts: parts}
+	default:
+		return geminiContent{
+			Role:  "user",
+			Parts: []geminiPart{{Text: speakerContent(msg)}},
+		}
+	}
 }
 
 func (g *Gemini) handleError(resp *http.Response) error {
@@ -262,12 +385,21 @@ func (g *Gemini) handleError(resp *http.Response) error {
 func (g *Gemini) parseResponse(resp *geminiResponse, model string) *simpleai.Response {
 	var content string
 	var finishReason string
+	var toolCalls []simpleai.ToolCall
 
 	if len(resp.Candidates) > 0 {
 		candidate := resp.Candidates[0]
 		finishReason = candidate.FinishReason
-		if len(candidate.Content.Parts) > 0 {
-			content = candidate.Content.Parts[0].Text
+		for _, part := range candidate.Content.Parts {
+			content += part.Text
+			if part.FunctionCall != nil {
+				// Gemini matches results by function name, not call ID
+				toolCalls = append(toolCalls, simpleai.ToolCall{
+					ID:        toolCallID(len(toolCalls)),
+					Name:      part.FunctionCall.Name,
+					Arguments: string(toolArguments(string(part.FunctionCall.Args))),
+				})
+			}
 		}
 	}
 
@@ -275,6 +407,7 @@ func (g *Gemini) parseResponse(resp *geminiResponse, model string) *simpleai.Res
 		Content:      content,
 		Model:        model,
 		FinishReason: finishReason,
+		ToolCalls:    toolCalls,
 		Usage: simpleai.Usage{
 			PromptTokens:     resp.UsageMetadata.PromptTokenCount,
 			CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
@@ -287,8 +420,12 @@ func (g *Gemini) streamResponse(ctx context.Context, body io.ReadCloser, out cha
 	defer close(out)
 	defer body.Close()
 
-	scanner := bufio.NewScanner(body)
-	for scanner.Scan() {
+	var usage *simpleai.Usage
+	// Function calls arrive whole, in any chunk; they are sent with the
+	// final event
+	var toolCalls []simpleai.ToolCall
+	events := sse.NewReader(body, g.config.MaxEventSize)
+	for events.Next() {
 		select {
 		case <-ctx.Done():
 			out <- simpleai.StreamEvent{Error: ctx.Err(), Done: true}
@@ -296,36 +433,53 @@ func (g *Gemini) streamResponse(ctx context.Context, body io.ReadCloser, out cha
 		default:
 		}
 
-		line := scanner.Text()
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
-
-		data := strings.TrimPrefix(line, "data: ")
+		data := events.Event().Data
 
 		var resp geminiResponse
 		if err := json.Unmarshal([]byte(data), &resp); err != nil {
 			continue
 		}
 
+		// Each chunk carries the usage so far
+		if resp.UsageMetadata.TotalTokenCount > 0 {
+			usage = &simpleai.Usage{
+				PromptTokens:     resp.UsageMetadata.PromptTokenCount,
+				CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
+				TotalTokens:      resp.UsageMetadata.TotalTokenCount,
+			}
+		}
+
 		if len(resp.Candidates) > 0 {
 			candidate := resp.Candidates[0]
-			if len(candidate.Content.Parts) > 0 {
-				out <- simpleai.StreamEvent{Content: candidate.Content.Parts[0].Text}
+			for _, part := range candidate.Content.Parts {
+				if part.Text != "" {
+					out <- simpleai.StreamEvent{Content: part.Text}
+				}
+				if part.FunctionCall != nil {
+					// Gemini matches results by function name, not call ID
+					toolCalls = append(toolCalls, simpleai.ToolCall{
+						ID:        toolCallID(len(toolCalls)),
+						Name:      part.FunctionCall.Name,
+						Arguments: string(toolArguments(string(part.FunctionCall.Args))),
+					})
+				}
 			}
 			if candidate.FinishReason != "" && candidate.FinishReason != "STOP" {
 				out <- simpleai.StreamEvent{
 					Done:         true,
 					FinishReason: candidate.FinishReason,
+					ToolCalls:    toolCalls,
+					Usage:        usage,
 				}
 				return
 			}
 		}
 	}
 
-	out <- simpleai.StreamEvent{Done: true}
-
-	if err := scanner.Err(); err != nil {
+	if err := events.Err(); err != nil {
 		out <- simpleai.StreamEvent{Error: err, Done: true}
+		return
 	}
+
+	out <- simpleai.StreamEvent{Done: true, ToolCalls: toolCalls, Usage: usage}
 }
@@ -0,0 +1,263 @@
+package provider
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/simpleai"
+)
+
+const (
+	googleCloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+	googleTokenURL           = "https://oauth2.googleapis.com/token"
+	googleMetadataTokenURL   = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// GoogleTokenSource supplies OAuth2 access tokens for Google Cloud APIs
+type GoogleTokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticGoogleToken is a GoogleTokenSource that always returns the same token,
+// e.g. from `gcloud auth print-access-token`
+type StaticGoogleToken string
+
+// Token implements GoogleTokenSource
+func (t StaticGoogleToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+// googleCredentials is the subset of a credentials JSON file we understand
+type googleCredentials struct {
+	Type         string `json:"type"` // service_account or authorized_user
+	ProjectID    string `json:"project_id"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type googleTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+}
+
+// adcTokenSource implements Application Default Credentials: a credentials
+// file (GOOGLE_APPLICATION_CREDENTIALS or the gcloud well-known file), falling
+// back to the GCE/GKE/Cloud Run metadata server. Tokens are cached until
+// shortly before they expire.
+type adcTokenSource struct {
+	creds  *googleCredentials
+	key    *rsa.PrivateKey
+	client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewGoogleADCTokenSource creates a token source using Application Default Credentials.
+// It returns the project ID found in the credentials file, if any.
+func NewGoogleADCTokenSource() (GoogleTokenSource, string, error) {
+	ts := &adcTokenSource{client: &http.Client{Timeout: 30 * time.Second}}
+
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		path = googleWellKnownCredentialsFile()
+		if _, err := os.Stat(path); err != nil {
+			// No file: use the metadata server
+			return ts, "", nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read google credentials: %w", err)
+	}
+	return newGoogleCredentialsTokenSource(ts, data)
+}
+
+// NewGoogleCredentialsTokenSource creates a token source from the contents of a
+// service account or authorized user credentials JSON file.
+// It returns the project ID found in the credentials, if any.
+func NewGoogleCredentialsTokenSource(credentialsJSON []byte) (GoogleTokenSource, string, error) {
+	ts := &adcTokenSource{client: &http.Client{Timeout: 30 * time.Second}}
+	return newGoogleCredentialsTokenSource(ts, credentialsJSON)
+}
+
+func newGoogleCredentialsTokenSource(ts *adcTokenSource, data []byte) (GoogleTokenSource, string, error) {
+	var creds googleCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, "", fmt.Errorf("invalid google credentials: %w", err)
+	}
+
+	switch creds.Type {
+	case "service_account":
+		key, err := parseGooglePrivateKey(creds.PrivateKey)
+		if err != nil {
+			return nil, "", err
+		}
+		ts.key = key
+		if creds.TokenURI == "" {
+			creds.TokenURI = googleTokenURL
+		}
+	case "authorized_user":
+		if creds.RefreshToken == "" {
+			return nil, "", errors.New("google credentials: missing refresh_token")
+		}
+	default:
+		return nil, "", fmt.Errorf("google credentials: unsupported type %q", creds.Type)
+	}
+
+	ts.creds = &creds
+	return ts, creds.ProjectID, nil
+}
+
+// Token implements GoogleTokenSource
+func (s *adcTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Before(s.expires) {
+		return s.token, nil
+	}
+
+	var resp *googleTokenResponse
+	var err error
+	switch {
+	case s.creds == nil:
+		resp, err = s.metadataToken(ctx)
+	case s.creds.Type == "service_account":
+		resp, err = s.serviceAccountToken(ctx)
+	default:
+		resp, err = s.refreshToken(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	s.token = resp.AccessToken
+	// Refresh a minute early to avoid using a token that expires in flight
+	s.expires = time.Now().Add(time.Duration(resp.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
+
+func (s *adcTokenSource) serviceAccountToken(ctx context.Context) (*googleTokenResponse, error) {
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   s.creds.ClientEmail,
+		"scope": googleCloudPlatformScope,
+		"aud":   s.creds.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign google token request: %w", err)
+	}
+
+	return s.exchange(ctx, s.creds.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(sig)},
+	})
+}
+
+func (s *adcTokenSource) refreshToken(ctx context.Context) (*googleTokenResponse, error) {
+	return s.exchange(ctx, googleTokenURL, url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {s.creds.ClientID},
+		"client_secret": {s.creds.ClientSecret},
+		"refresh_token": {s.creds.RefreshToken},
+	})
+}
+
+func (s *adcTokenSource) exchange(ctx context.Context, tokenURL string, form url.Values) (*googleTokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return s.doTokenRequest(req)
+}
+
+func (s *adcTokenSource) metadataToken(ctx context.Context) (*googleTokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, googleMetadataTokenURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	return s.doTokenRequest(req)
+}
+
+func (s *adcTokenSource) doTokenRequest(req *http.Request) (*googleTokenResponse, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("google token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, simpleai.NewProviderError("vertexai", resp.StatusCode, "token request failed: "+string(body), "auth_error")
+	}
+
+	var token googleTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("invalid google token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("google token response has no access_token")
+	}
+	return &token, nil
+}
+
+func parseGooglePrivateKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("google credentials: invalid private_key")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+			return rsaKey, nil
+		}
+		return nil, errors.New("google credentials: private_key is not RSA")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("google credentials: invalid private_key: %w", err)
+	}
+	return key, nil
+}
+
+func googleWellKnownCredentialsFile() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "gcloud", "application_default_credentials.json")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+}
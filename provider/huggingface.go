@@ -0,0 +1,410 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/medatechnology/goutil/utils"
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/internal/sse"
+)
+
+const (
+	HuggingFaceDefaultBaseURL = "https://api-inference.huggingface.co"
+	HuggingFaceDefaultModel   = "meta-llama/Llama-3.1-8B-Instruct"
+)
+
+// Hugging Face API modes
+const (
+	// HuggingFaceModeChat uses the OpenAI-compatible Messages API
+	// (/v1/chat/completions), served by both the Inference API and TGI
+	HuggingFaceModeChat = "chat"
+
+	// HuggingFaceModeGenerate uses the raw text-generation routes
+	// (/generate and /generate_stream on TGI, /models/{model} on the Inference API)
+	HuggingFaceModeGenerate = "generate"
+)
+
+// HuggingFaceConfig holds configuration for the Hugging Face provider
+type HuggingFaceConfig struct {
+	APIKey      string // Hugging Face token (optional for self-hosted TGI)
+	BaseURL     string // Inference API by default; set to a TGI server URL for self-hosting
+	Model       string // Model repo ID (ignored by TGI, which serves a single model)
+	Mode        string // HuggingFaceModeChat (default) or HuggingFaceModeGenerate
+	MaxTokens   int
+	Temperature float64
+	TopP        float64
+
+	// PromptFormat renders messages into a single prompt for generate mode.
+	// Defaults to DefaultPromptFormat; use the model's chat template for best results.
+	PromptFormat func(system string, messages []simpleai.Message) string
+
+	// MaxEventSize limits a single stream event in bytes (default 4MB)
+	MaxEventSize int
+
+	// Interceptors see the raw HTTP requests and responses, in order
+	Interceptors []Interceptor
+}
+
+// HuggingFace implements the Provider interface for the Hugging Face
+// Inference API and self-hosted text-generation-inference (TGI) servers
+type HuggingFace struct {
+	config HuggingFaceConfig
+	compat *openaiCompat // Chat mode and the shared HTTP client
+}
+
+// NewHuggingFace creates a new Hugging Face provider
+func NewHuggingFace(config HuggingFaceConfig) *HuggingFace {
+	if config.BaseURL == "" {
+		config.BaseURL = HuggingFaceDefaultBaseURL
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.Model == "" {
+		config.Model = HuggingFaceDefaultModel
+	}
+	if config.Mode == "" {
+		config.Mode = HuggingFaceModeChat
+	}
+	if config.MaxTokens == 0 {
+		config.MaxTokens = 1024
+	}
+	if config.Temperature == 0 {
+		config.Temperature = 0.7
+	}
+	if config.PromptFormat == nil {
+		config.PromptFormat = DefaultPromptFormat
+	}
+
+	headers := map[string][]string{
+		"Content-Type": {"application/json"},
+	}
+	if config.APIKey != "" {
+		headers["Authorization"] = []string{"Bearer " + config.APIKey}
+	}
+
+	h := &HuggingFace{config: config}
+	h.compat = newOpenAICompat("huggingface", headers)
+	h.compat.model = config.Model
+	h.compat.maxTokens = config.MaxTokens
+	h.compat.temperature = config.Temperature
+	h.compat.maxEventSize = config.MaxEventSize
+	h.compat.interceptors = config.Interceptors
+	h.compat.url = h.chatURL
+	h.compat.handleErr = h.handleError
+	return h
+}
+
+// NewHuggingFaceFromEnv creates a Hugging Face provider from environment variables
+// Environment variables: HF_TOKEN, HF_MODEL (optional), HF_BASE_URL (optional, for TGI)
+func NewHuggingFaceFromEnv() *HuggingFace {
+	return NewHuggingFace(HuggingFaceConfig{
+		APIKey:  utils.GetEnvString("HF_TOKEN", ""),
+		Model:   utils.GetEnvString("HF_MODEL", HuggingFaceDefaultModel),
+		BaseURL: utils.GetEnvString("HF_BASE_URL", HuggingFaceDefaultBaseURL),
+	})
+}
+
+// Name returns the provider name
+func (h *HuggingFace) Name() string {
+	return "huggingface"
+}
+
+// Capabilities returns the request features the API handles natively
+func (h *HuggingFace) Capabilities() simpleai.Capabilities {
+	return simpleai.Capabilities{StreamPrefix: true}
+}
+
+// Model returns the default model
+func (h *HuggingFace) Model() string {
+	return h.config.Model
+}
+
+// Complete sends a completion request to Hugging Face
+func (h *HuggingFace) Complete(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+	if h.config.Mode == HuggingFaceModeGenerate {
+		return h.completeGenerate(ctx, req)
+	}
+
+	return h.compat.complete(ctx, req)
+}
+
+// Stream sends a streaming completion request
+func (h *HuggingFace) Stream(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
+	if h.config.Mode == HuggingFaceModeGenerate {
+		return h.streamGenerate(ctx, req)
+	}
+
+	return h.compat.stream(ctx, req)
+}
+
+// CountTokens estimates token count
+func (h *HuggingFace) CountTokens(text string) int {
+	return len(text) / 4
+}
+
+// httpClient returns the HTTP client to use for a request
+func (h *HuggingFace) httpClient(ctx context.Context) httpDoer {
+	return h.compat.httpClient(ctx)
+}
+
+// serverless reports whether the provider targets the hosted Inference API
+// rather than a self-hosted TGI server
+func (h *HuggingFace) serverless() bool {
+	return h.config.BaseURL == HuggingFaceDefaultBaseURL
+}
+
+func (h *HuggingFace) chatURL(model string) string {
+	if h.serverless() {
+		return h.config.BaseURL + "/models/" + model + "/v1/chat/completions"
+	}
+	return h.config.BaseURL + "/v1/chat/completions"
+}
+
+func (h *HuggingFace) generateURL(model string, stream bool) string {
+	if h.serverless() {
+		return h.config.BaseURL + "/models/" + model
+	}
+	if stream {
+		return h.config.BaseURL + "/generate_stream"
+	}
+	return h.config.BaseURL + "/generate"
+}
+
+// DefaultPromptFormat renders messages as a plain role-prefixed transcript
+// ending with an open assistant turn
+func DefaultPromptFormat(system string, messages []simpleai.Message) string {
+	var sb strings.Builder
+	if system != "" {
+		sb.WriteString("System: " + system + "\n\n")
+	}
+	for _, msg := range messages {
+		switch {
+		case msg.Role == simpleai.RoleSystem:
+			sb.WriteString("System: ")
+		case msg.Role == simpleai.RoleTool:
+			sb.WriteString("Tool (" + msg.Name + "): ")
+		case msg.Name != "":
+			sb.WriteString(msg.Name + ": ")
+		case msg.Role == simpleai.RoleAssistant:
+			sb.WriteString("Assistant: ")
+		default:
+			sb.WriteString("User: ")
+		}
+		sb.WriteString(msg.Content + "\n\n")
+	}
+	sb.WriteString("Assistant:")
+	return sb.String()
+}
+
+// Internal types for the text-generation API
+type hfGenerateRequest struct {
+	Inputs     string       `json:"inputs"`
+	Parameters hfParameters `json:"parameters"`
+	Stream     bool         `json:"stream,omitempty"`
+}
+
+type hfParameters struct {
+	MaxNewTokens   int      `json:"max_new_tokens,omitempty"`
+	Temperature    float64  `json:"temperature,omitempty"`
+	TopP           float64  `json:"top_p,omitempty"`
+	Stop           []string `json:"stop,omitempty"`
+	ReturnFullText bool     `json:"return_full_text"`
+	Details        bool     `json:"details"`
+}
+
+type hfGenerateResponse struct {
+	GeneratedText string     `json:"generated_text"`
+	Details       *hfDetails `json:"details,omitempty"`
+}
+
+type hfDetails struct {
+	FinishReason    string `json:"finish_reason"`
+	GeneratedTokens int    `json:"generated_tokens"`
+}
+
+type hfStreamResponse struct {
+	Token struct {
+		Text    string `json:"text"`
+		Special bool   `json:"special"`
+	} `json:"token"`
+	GeneratedText *string    `json:"generated_text"`
+	Details       *hfDetails `json:"details"`
+}
+
+type hfErrorResponse struct {
+	Error     string `json:"error"`
+	ErrorType string `json:"error_type"`
+}
+
+func (h *HuggingFace) buildGenerateRequest(req *simpleai.Request, stream bool) *hfGenerateRequest {
+	system := req.SystemPrompt
+	messages := req.Messages
+
+	maxTokens := req.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = h.config.MaxTokens
+	}
+
+	temp := req.Temperature
+	if temp == 0 {
+		temp = h.config.Temperature
+	}
+
+	return &hfGenerateRequest{
+		Inputs: h.config.PromptFormat(system, messages) + req.AssistantPrefix,
+		Parameters: hfParameters{
+			MaxNewTokens: maxTokens,
+			Temperature:  temp,
+			TopP:         req.TopP,
+			Stop:         req.Stop,
+			Details:      true,
+		},
+		Stream: stream,
+	}
+}
+
+func (h *HuggingFace) completeGenerate(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+	model := req.Model
+	if model == "" {
+		model = h.config.Model
+	}
+	hfReq := h.buildGenerateRequest(req, false)
+
+	// The Inference API returns an array, TGI a single object
+	var raw json.RawMessage
+	statusCode, err := h.httpClient(ctx).Post(h.generateURL(model, false), hfReq, &raw, nil)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if statusCode != 200 {
+		return nil, simpleai.NewProviderError(
+			"huggingface",
+			int(statusCode),
+			"request failed",
+			"http_error",
+		)
+	}
+
+	var hfResp hfGenerateResponse
+	var list []hfGenerateResponse
+	if err := json.Unmarshal(raw, &list); err == nil {
+		if len(list) > 0 {
+			hfResp = list[0]
+		}
+	} else if err := json.Unmarshal(raw, &hfResp); err != nil {
+		return nil, simpleai.ErrInvalidResponse
+	}
+
+	resp := &simpleai.Response{
+		Content: req.AssistantPrefix + hfResp.GeneratedText,
+		Model:   model,
+	}
+	if hfResp.Details != nil {
+		resp.FinishReason = hfResp.Details.FinishReason
+		resp.Usage.CompletionTokens = hfResp.Details.GeneratedTokens
+		resp.Usage.TotalTokens = hfResp.Details.GeneratedTokens
+	}
+	applyStop(req, resp)
+	return resp, nil
+}
+
+func (h *HuggingFace) streamGenerate(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
+	model := req.Model
+	if model == "" {
+		model = h.config.Model
+	}
+
+	resp, err := h.httpClient(ctx).PostStream(h.generateURL(model, true), h.buildGenerateRequest(req, true))
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, h.handleError(resp)
+	}
+
+	out := make(chan simpleai.StreamEvent)
+	go h.streamGenerateResponse(ctx, resp.Body, out)
+
+	return stopStream(req, prefixStream(req.AssistantPrefix, out)), nil
+}
+
+func (h *HuggingFace) handleError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+
+	var errResp hfErrorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != "" {
+		return simpleai.NewProviderError(
+			"huggingface",
+			resp.StatusCode,
+			errResp.Error,
+			errResp.ErrorType,
+		)
+	}
+
+	var openaiErr openaiErrorResponse
+	if err := json.Unmarshal(body, &openaiErr); err == nil && openaiErr.Error.Message != "" {
+		return simpleai.NewProviderError(
+			"huggingface",
+			resp.StatusCode,
+			openaiErr.Error.Message,
+			openaiErr.Error.Type,
+		)
+	}
+
+	return simpleai.NewProviderError(
+		"huggingface",
+		resp.StatusCode,
+		string(body),
+		"unknown",
+	)
+}
+
+func (h *HuggingFace) streamGenerateResponse(ctx context.Context, body io.ReadCloser, out chan<- simpleai.StreamEvent) {
+	defer close(out)
+	defer body.Close()
+
+	events := sse.NewReader(body, h.config.MaxEventSize)
+	for events.Next() {
+		select {
+		case <-ctx.Done():
+			out <- simpleai.StreamEvent{Error: ctx.Err(), Done: true}
+			return
+		default:
+		}
+
+		data := events.Event().Data
+
+		var resp hfStreamResponse
+		if err := json.Unmarshal([]byte(data), &resp); err != nil {
+			continue
+		}
+
+		if resp.Token.Text != "" && !resp.Token.Special {
+			out <- simpleai.StreamEvent{Content: resp.Token.Text}
+		}
+
+		// The final event carries the full generated text and details
+		if resp.GeneratedText != nil {
+			event := simpleai.StreamEvent{Done: true}
+			if resp.Details != nil {
+				event.FinishReason = resp.Details.FinishReason
+			}
+			out <- event
+			return
+		}
+	}
+
+	if err := events.Err(); err != nil {
+		out <- simpleai.StreamEvent{Error: err, Done: true}
+		return
+	}
+
+	out <- simpleai.StreamEvent{Done: true}
+}
@@ -0,0 +1,177 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	medahttp "github.com/medatechnology/goutil/http"
+	"github.com/medatechnology/simpleai"
+)
+
+// Interceptor sees the raw HTTP traffic of a provider, for debugging,
+// header injection or custom auth schemes. Set it with the Interceptors
+// field of a provider config.
+//
+// InterceptRequest runs before a request is sent and may modify it; the
+// body can be read with req.GetBody. InterceptResponse runs before the
+// provider reads a response; it may read resp.Body as long as it replaces
+// it, e.g. with io.NopCloser(bytes.NewReader(body)). For streams the body
+// is the live event stream. Returning an error aborts the call.
+type Interceptor interface {
+	InterceptRequest(req *http.Request) error
+	InterceptResponse(resp *http.Response) error
+}
+
+// RequestInterceptorFunc is an Interceptor that only sees requests
+type RequestInterceptorFunc func(req *http.Request) error
+
+// InterceptRequest implements Interceptor
+func (f RequestInterceptorFunc) InterceptRequest(req *http.Request) error {
+	return f(req)
+}
+
+// InterceptResponse implements Interceptor
+func (f RequestInterceptorFunc) InterceptResponse(resp *http.Response) error {
+	return nil
+}
+
+// ResponseInterceptorFunc is an Interceptor that only sees responses
+type ResponseInterceptorFunc func(resp *http.Response) error
+
+// InterceptRequest implements Interceptor
+func (f ResponseInterceptorFunc) InterceptRequest(req *http.Request) error {
+	return nil
+}
+
+// InterceptResponse implements Interceptor
+func (f ResponseInterceptorFunc) InterceptResponse(resp *http.Response) error {
+	return f(resp)
+}
+
+// httpDoer is the part of medahttp.HttpClient the providers use
+type httpDoer interface {
+	Post(url string, body any, result any, errorResponse any) (medahttp.StatusCode, error)
+	Get(url string, result any, errorResponse any) (medahttp.StatusCode, error)
+	PostStream(url string, data any) (*http.Response, error)
+	GetStream(url string) (*http.Response, error)
+}
+
+// interceptClient is an httpDoer that runs interceptors around each request
+// and records its payload sizes with simpleai.AddTransfer. It follows the
+// medahttp client's conventions for status codes and errors.
+type interceptClient struct {
+	ctx          context.Context
+	headers      map[string][]string
+	interceptors []Interceptor
+}
+
+func (c *interceptClient) Post(url string, body any, result any, _ any) (medahttp.StatusCode, error) {
+	resp, err := c.do(http.MethodPost, url, body)
+	if err != nil {
+		return 0, err
+	}
+	return decodeResponse(resp, result)
+}
+
+func (c *interceptClient) Get(url string, result any, _ any) (medahttp.StatusCode, error) {
+	resp, err := c.do(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	return decodeResponse(resp, result)
+}
+
+func (c *interceptClient) PostStream(url string, data any) (*http.Response, error) {
+	return c.do(http.MethodPost, url, data)
+}
+
+func (c *interceptClient) GetStream(url string) (*http.Response, error) {
+	return c.do(http.MethodGet, url, nil)
+}
+
+func (c *interceptClient) do(method, url string, body any) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(c.ctx, method, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		req.Body, req.GetBody, req.ContentLength = http.NoBody, nil, 0
+	}
+	for k, v := range c.headers {
+		req.Header[k] = append([]string(nil), v...)
+	}
+
+	for _, i := range c.interceptors {
+		if err := i.InterceptRequest(req); err != nil {
+			return nil, err
+		}
+	}
+
+	sent := req.ContentLength
+	if sent < 0 {
+		sent = 0
+	}
+	simpleai.AddTransfer(c.ctx, simpleai.Transfer{RequestBytes: int64(len(payload)), SentBytes: sent})
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, i := range c.interceptors {
+		if err := i.InterceptResponse(resp); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
+	resp.Body = &countingBody{ReadCloser: resp.Body, ctx: c.ctx}
+	return resp, nil
+}
+
+// countingBody records the response bytes as they are read, so streams
+// report their size as they go
+type countingBody struct {
+	io.ReadCloser
+	ctx context.Context
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		simpleai.AddTransfer(b.ctx, simpleai.Transfer{ResponseBytes: int64(n)})
+	}
+	return n, err
+}
+
+// decodeResponse decodes a successful JSON response into result, and turns
+// error responses into an error carrying the body
+func decodeResponse(resp *http.Response, result any) (medahttp.StatusCode, error) {
+	defer resp.Body.Close()
+
+	status := medahttp.StatusCode(resp.StatusCode)
+	if resp.StatusCode >= 400 {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return 0, err
+		}
+		return status, errors.New(string(body))
+	}
+	if result != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return 0, err
+		}
+	}
+	return status, nil
+}
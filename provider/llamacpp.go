@@ -0,0 +1,440 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	medahttp "github.com/medatechnology/goutil/http"
+	"github.com/medatechnology/goutil/utils"
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/internal/sse"
+)
+
+const (
+	LlamaCppDefaultBaseURL = "http://localhost:8080"
+)
+
+// LlamaCppConfig holds configuration for the llama.cpp server provider
+type LlamaCppConfig struct {
+	BaseURL     string
+	APIKey      string // Matches the server's --api-key, if set
+	Model       string // Informational; the server serves a single model
+	MaxTokens   int
+	Temperature float64
+	TopP        float64
+
+	// Grammar is a default GBNF grammar applied to every request
+	// unless overridden with WithLlamaCppOptions
+	Grammar string
+
+	// PromptFormat renders messages into the raw prompt sent to /completion.
+	// Defaults to DefaultPromptFormat; use the model's chat template for best results.
+	PromptFormat func(system string, messages []simpleai.Message) string
+
+	// MaxEventSize limits a single stream event in bytes (default 4MB)
+	MaxEventSize int
+
+	// Interceptors see the raw HTTP requests and responses, in order
+	Interceptors []Interceptor
+}
+
+// LlamaCppOptions are per-request llama.cpp generation options
+type LlamaCppOptions struct {
+	Grammar    string         // GBNF grammar constraining the output
+	JSONSchema map[string]any // JSON schema converted to a grammar by the server
+	NProbs     int            // Number of top token probabilities to return per token
+	SlotID     *int           // Pin the request to a slot (nil lets the server choose)
+	NoCache    bool           // Disable prompt (KV) cache reuse
+}
+
+type llamaCppOptionsKey struct{}
+
+// WithLlamaCppOptions attaches llama.cpp options to the request context
+func WithLlamaCppOptions(ctx context.Context, opts LlamaCppOptions) context.Context {
+	return context.WithValue(ctx, llamaCppOptionsKey{}, opts)
+}
+
+func llamaCppOptionsFromContext(ctx context.Context) LlamaCppOptions {
+	opts, _ := ctx.Value(llamaCppOptionsKey{}).(LlamaCppOptions)
+	return opts
+}
+
+// LlamaCpp implements the Provider interface for llama.cpp's HTTP server
+// (llama-server), with grammar-constrained generation and slot management
+type LlamaCpp struct {
+	config  LlamaCppConfig
+	client  medahttp.HttpClient
+	headers map[string][]string
+}
+
+// NewLlamaCpp creates a new llama.cpp provider
+func NewLlamaCpp(config LlamaCppConfig) *LlamaCpp {
+	if config.BaseURL == "" {
+		config.BaseURL = LlamaCppDefaultBaseURL
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.MaxTokens == 0 {
+		config.MaxTokens = 1024
+	}
+	if config.Temperature == 0 {
+		config.Temperature = 0.7
+	}
+	if config.PromptFormat == nil {
+		config.PromptFormat = DefaultPromptFormat
+	}
+
+	headers := map[string][]string{
+		"Content-Type": {"application/json"},
+	}
+	if config.APIKey != "" {
+		headers["Authorization"] = []string{"Bearer " + config.APIKey}
+	}
+
+	client := medahttp.NewHttp()
+	client.SetHeader(headers)
+
+	return &LlamaCpp{
+		config:  config,
+		client:  client,
+		headers: headers,
+	}
+}
+
+// NewLlamaCppFromEnv creates a llama.cpp provider from environment variables
+// Environment variables: LLAMACPP_BASE_URL (optional), LLAMACPP_API_KEY (optional)
+func NewLlamaCppFromEnv() *LlamaCpp {
+	return NewLlamaCpp(LlamaCppConfig{
+		BaseURL: utils.GetEnvString("LLAMACPP_BASE_URL", LlamaCppDefaultBaseURL),
+		APIKey:  utils.GetEnvString("LLAMACPP_API_KEY", ""),
+	})
+}
+
+// Name returns the provider name
+func (l *LlamaCpp) Name() string {
+	return "llamacpp"
+}
+
+// Capabilities returns the request features the API handles natively
+func (l *LlamaCpp) Capabilities() simpleai.Capabilities {
+	return simpleai.Capabilities{StreamPrefix: true}
+}
+
+// Model returns the default model
+func (l *LlamaCpp) Model() string {
+	return l.config.Model
+}
+
+// Complete sends a completion request to the llama.cpp server
+func (l *LlamaCpp) Complete(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+	result, err := l.CompleteWithProbs(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return result.Response, nil
+}
+
+// LlamaCppResult is a completion together with llama.cpp-specific details
+type LlamaCppResult struct {
+	Response      *simpleai.Response
+	SlotID        int
+	Probabilities []LlamaCppTokenProbs // Set when NProbs > 0
+}
+
+// LlamaCppTokenProbs holds the generated token and its top candidates
+type LlamaCppTokenProbs struct {
+	Token       string              `json:"token"`
+	LogProb     float64             `json:"logprob"`
+	TopLogProbs []LlamaCppTokenProb `json:"top_logprobs"`
+}
+
+// LlamaCppTokenProb is a candidate token with its log probability
+type LlamaCppTokenProb struct {
+	Token   string  `json:"token"`
+	LogProb float64 `json:"logprob"`
+}
+
+// CompleteWithProbs sends a completion request and returns the raw
+// llama.cpp details, including token probabilities and the slot used
+func (l *LlamaCpp) CompleteWithProbs(ctx context.Context, req *simpleai.Request) (*LlamaCppResult, error) {
+	llamaReq := l.buildRequest(ctx, req, false)
+
+	var llamaResp llamaCppResponse
+	statusCode, err := l.httpClient(ctx).Post(l.config.BaseURL+"/completion", llamaReq, &llamaResp, nil)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if statusCode != 200 {
+		return nil, simpleai.NewProviderError(
+			"llamacpp",
+			int(statusCode),
+			"request failed",
+			"http_error",
+		)
+	}
+
+	resp := l.parseResponse(&llamaResp)
+	resp.Content = req.AssistantPrefix + resp.Content
+	applyStop(req, resp)
+
+	return &LlamaCppResult{
+		Response:      resp,
+		SlotID:        llamaResp.SlotID,
+		Probabilities: llamaResp.Probabilities,
+	}, nil
+}
+
+// Stream sends a streaming completion request
+func (l *LlamaCpp) Stream(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
+	llamaReq := l.buildRequest(ctx, req, true)
+
+	resp, err := l.httpClient(ctx).PostStream(l.config.BaseURL+"/completion", llamaReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, l.handleError(resp)
+	}
+
+	out := make(chan simpleai.StreamEvent)
+	go l.streamResponse(ctx, resp.Body, out)
+
+	return stopStream(req, prefixStream(req.AssistantPrefix, out)), nil
+}
+
+// CountTokens estimates token count
+func (l *LlamaCpp) CountTokens(text string) int {
+	return len(text) / 4
+}
+
+// httpClient returns the HTTP client to use for a request
+func (l *LlamaCpp) httpClient(ctx context.Context) httpDoer {
+	return requestClient(ctx, l.client, l.headers, l.config.Interceptors)
+}
+
+// LlamaCppSlot describes a server slot (a parallel sequence with its own KV cache)
+type LlamaCppSlot struct {
+	ID           int    `json:"id"`
+	NCtx         int    `json:"n_ctx"`
+	IsProcessing bool   `json:"is_processing"`
+	Prompt       string `json:"prompt"`
+}
+
+// LlamaCppSlotResult reports the outcome of a slot action
+type LlamaCppSlotResult struct {
+	SlotID    int    `json:"id_slot"`
+	Filename  string `json:"filename"`
+	NSaved    int    `json:"n_saved"`
+	NWritten  int    `json:"n_written"`
+	NRestored int    `json:"n_restored"`
+	NRead     int    `json:"n_read"`
+	NErased   int    `json:"n_erased"`
+}
+
+// Slots lists the server slots (requires the server's /slots endpoint to be enabled)
+func (l *LlamaCpp) Slots(ctx context.Context) ([]LlamaCppSlot, error) {
+	var slots []LlamaCppSlot
+	statusCode, err := l.httpClient(ctx).Get(l.config.BaseURL+"/slots", &slots, nil)
+	if err != nil {
+		return nil, fmt.Errorf("slots request failed: %w", err)
+	}
+	if statusCode != 200 {
+		return nil, simpleai.NewProviderError("llamacpp", int(statusCode), "slots request failed", "http_error")
+	}
+	return slots, nil
+}
+
+// SaveSlot saves a slot's KV cache to filename in the server's --slot-save-path
+func (l *LlamaCpp) SaveSlot(ctx context.Context, slotID int, filename string) (*LlamaCppSlotResult, error) {
+	return l.slotAction(ctx, slotID, "save", map[string]string{"filename": filename})
+}
+
+// RestoreSlot restores a slot's KV cache from a file saved with SaveSlot
+func (l *LlamaCpp) RestoreSlot(ctx context.Context, slotID int, filename string) (*LlamaCppSlotResult, error) {
+	return l.slotAction(ctx, slotID, "restore", map[string]string{"filename": filename})
+}
+
+// EraseSlot clears a slot's KV cache
+func (l *LlamaCpp) EraseSlot(ctx context.Context, slotID int) (*LlamaCppSlotResult, error) {
+	return l.slotAction(ctx, slotID, "erase", map[string]string{})
+}
+
+func (l *LlamaCpp) slotAction(ctx context.Context, slotID int, action string, body any) (*LlamaCppSlotResult, error) {
+	url := fmt.Sprintf("%s/slots/%d?action=%s", l.config.BaseURL, slotID, action)
+
+	var result LlamaCppSlotResult
+	statusCode, err := l.httpClient(ctx).Post(url, body, &result, nil)
+	if err != nil {
+		return nil, fmt.Errorf("slot %s failed: %w", action, err)
+	}
+	if statusCode != 200 {
+		return nil, simpleai.NewProviderError("llamacpp", int(statusCode), "slot "+action+" failed", "http_error")
+	}
+	return &result, nil
+}
+
+// Internal types for the llama.cpp server API
+type llamaCppRequest struct {
+	Prompt      string         `json:"prompt"`
+	NPredict    int            `json:"n_predict,omitempty"`
+	Temperature float64        `json:"temperature,omitempty"`
+	TopP        float64        `json:"top_p,omitempty"`
+	Stop        []string       `json:"stop,omitempty"`
+	Grammar     string         `json:"grammar,omitempty"`
+	JSONSchema  map[string]any `json:"json_schema,omitempty"`
+	NProbs      int            `json:"n_probs,omitempty"`
+	SlotID      *int           `json:"id_slot,omitempty"`
+	CachePrompt bool           `json:"cache_prompt"`
+	Stream      bool           `json:"stream"`
+}
+
+type llamaCppResponse struct {
+	Content         string               `json:"content"`
+	Model           string               `json:"model"`
+	Stop            bool                 `json:"stop"`
+	StopType        string               `json:"stop_type"`
+	StoppingWord    string               `json:"stopping_word"`
+	SlotID          int                  `json:"id_slot"`
+	TokensPredicted int                  `json:"tokens_predicted"`
+	TokensEvaluated int                  `json:"tokens_evaluated"`
+	Probabilities   []LlamaCppTokenProbs `json:"completion_probabilities"`
+}
+
+type llamaCppErrorResponse struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+func (l *LlamaCpp) buildRequest(ctx context.Context, req *simpleai.Request, stream bool) *llamaCppRequest {
+	opts := llamaCppOptionsFromContext(ctx)
+
+	maxTokens := req.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = l.config.MaxTokens
+	}
+
+	temp := req.Temperature
+	if temp == 0 {
+		temp = l.config.Temperature
+	}
+
+	grammar := opts.Grammar
+	if grammar == "" && opts.JSONSchema == nil {
+		grammar = l.config.Grammar
+	}
+
+	return &llamaCppRequest{
+		Prompt:      l.config.PromptFormat(req.SystemPrompt, req.Messages) + req.AssistantPrefix,
+		NPredict:    maxTokens,
+		Temperature: temp,
+		TopP:        req.TopP,
+		Stop:        req.Stop,
+		Grammar:     grammar,
+		JSONSchema:  opts.JSONSchema,
+		NProbs:      opts.NProbs,
+		SlotID:      opts.SlotID,
+		CachePrompt: !opts.NoCache,
+		Stream:      stream,
+	}
+}
+
+func (l *LlamaCpp) handleError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+
+	var errResp llamaCppErrorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
+		return simpleai.NewProviderError(
+			"llamacpp",
+			resp.StatusCode,
+			errResp.Error.Message,
+			errResp.Error.Type,
+		)
+	}
+
+	return simpleai.NewProviderError(
+		"llamacpp",
+		resp.StatusCode,
+		string(body),
+		"unknown",
+	)
+}
+
+func (l *LlamaCpp) parseResponse(resp *llamaCppResponse) *simpleai.Response {
+	model := resp.Model
+	if model == "" {
+		model = l.config.Model
+	}
+
+	return &simpleai.Response{
+		Content:      resp.Content,
+		Model:        model,
+		FinishReason: llamaCppFinishReason(resp.StopType),
+		StopSequence: resp.StoppingWord,
+		Usage: simpleai.Usage{
+			PromptTokens:     resp.TokensEvaluated,
+			CompletionTokens: resp.TokensPredicted,
+			TotalTokens:      resp.TokensEvaluated + resp.TokensPredicted,
+		},
+	}
+}
+
+// llamaCppFinishReason maps the server stop type to the common finish reasons
+func llamaCppFinishReason(stopType string) string {
+	switch stopType {
+	case "limit":
+		return "length"
+	case "eos", "word":
+		return "stop"
+	default:
+		return stopType
+	}
+}
+
+func (l *LlamaCpp) streamResponse(ctx context.Context, body io.ReadCloser, out chan<- simpleai.StreamEvent) {
+	defer close(out)
+	defer body.Close()
+
+	events := sse.NewReader(body, l.config.MaxEventSize)
+	for events.Next() {
+		select {
+		case <-ctx.Done():
+			out <- simpleai.StreamEvent{Error: ctx.Err(), Done: true}
+			return
+		default:
+		}
+
+		var resp llamaCppResponse
+		if err := json.Unmarshal([]byte(events.Event().Data), &resp); err != nil {
+			continue
+		}
+
+		if resp.Content != "" {
+			out <- simpleai.StreamEvent{Content: resp.Content}
+		}
+
+		if resp.Stop {
+			out <- simpleai.StreamEvent{
+				Done:         true,
+				FinishReason: llamaCppFinishReason(resp.StopType),
+				StopSequence: resp.StoppingWord,
+			}
+			return
+		}
+	}
+
+	if err := events.Err(); err != nil {
+		out <- simpleai.StreamEvent{Error: err, Done: true}
+		return
+	}
+
+	out <- simpleai.StreamEvent{Done: true}
+}
@@ -0,0 +1,49 @@
+package provider
+
+import (
+	"context"
+
+	medahttp "github.com/medatechnology/goutil/http"
+	"github.com/medatechnology/simpleai"
+)
+
+// requestClient returns an HTTP client for a single request. When the context
+// carries metadata headers, a client with the provider headers plus the
+// metadata headers is returned; when interceptors are set or the client call
+// records payload sizes, a client that runs the interceptors and measures
+// the payloads is returned; in dry-run mode, a client that returns requests
+// instead of sending them is returned. Otherwise the shared client is used
+// as is.
+func requestClient(ctx context.Context, client medahttp.HttpClient, headers map[string][]string, interceptors []Interceptor) httpDoer {
+	md, ok := simpleai.RequestMetadataFromContext(ctx)
+	dryRun := simpleai.IsDryRun(ctx)
+	_, measured := simpleai.TransferFromContext(ctx)
+	if (!ok || len(md.Headers) == 0) && len(interceptors) == 0 && !measured && !dryRun {
+		return client
+	}
+
+	merged := make(map[string][]string, len(headers)+len(md.Headers))
+	for k, v := range headers {
+		merged[k] = v
+	}
+	for k, v := range md.Headers {
+		merged[k] = []string{v}
+	}
+
+	switch {
+	case dryRun:
+		return &dryRunClient{headers: merged}
+	case len(interceptors) > 0 || measured:
+		return &interceptClient{ctx: ctx, headers: merged, interceptors: interceptors}
+	}
+
+	c := medahttp.NewHttp()
+	c.SetHeader(merged)
+	return c
+}
+
+// metadataUserID returns the end-user ID from the request metadata, if any
+func metadataUserID(ctx context.Context) string {
+	md, _ := simpleai.RequestMetadataFromContext(ctx)
+	return md.UserID
+}
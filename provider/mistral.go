@@ -1,22 +1,17 @@
 package provider
 
 import (
-	"bufio"
+	"cmp"
 	"context"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
-	"strings"
 
-	medahttp "github.com/medatechnology/goutil/http"
 	"github.com/medatechnology/goutil/utils"
 	"github.com/medatechnology/simpleai"
 )
 
 const (
-	MistralDefaultBaseURL = "https://api.mistral.ai"
-	MistralDefaultModel   = "mistral-large-latest"
+	MistralDefaultBaseURL  = "https://api.mistral.ai"
+	MistralDefaultModel    = "mistral-large-latest"
+	MistralDefaultFIMModel = "codestral-latest"
 )
 
 // MistralConfig holds configuration for the Mistral provider
@@ -28,12 +23,47 @@ type MistralConfig struct {
 	Temperature float64
 	TopP        float64
 	SafePrompt  bool // Enable Mistral's safety prompt
+
+	// MaxEventSize limits a single stream event in bytes (default 4MB)
+	MaxEventSize int
+
+	// Interceptors see the raw HTTP requests and responses, in order
+	Interceptors []Interceptor
+}
+
+// Mistral tool choice modes; any other value forces the named tool
+const (
+	MistralToolChoiceAuto     = "auto"
+	MistralToolChoiceNone     = "none"
+	MistralToolChoiceAny      = "any"
+	MistralToolChoiceRequired = "required"
+)
+
+// MistralOptions are per-request Mistral features
+type MistralOptions struct {
+	Tools             []simpleai.Tool // Overrides Request.Tools
+	ToolChoice        string          // auto (default), none, any, required or a tool name; overrides Request.ToolChoice
+	ParallelToolCalls *bool
+
+	// Prefix is a partial assistant message the model must continue.
+	// The returned content starts with the prefix. Request.AssistantPrefix
+	// takes precedence.
+	Prefix string
+
+	RandomSeed int
+}
+
+type mistralOptionsKey struct{}
+
+// WithMistralOptions attaches Mistral options to the request context
+func WithMistralOptions(ctx context.Context, opts MistralOptions) context.Context {
+	return context.WithValue(ctx, mistralOptionsKey{}, opts)
 }
 
 // Mistral implements the Provider interface for Mistral AI models
 type Mistral struct {
 	config MistralConfig
-	client medahttp.HttpClient
+	compat *openaiCompat
 }
 
 // NewMistral creates a new Mistral provider
@@ -56,13 +86,21 @@ func NewMistral(config MistralConfig) *Mistral {
 		"Authorization": {"Bearer " + config.APIKey},
 	}
 
-	client := medahttp.NewHttp()
-	client.SetHeader(headers)
-
-	return &Mistral{
-		config: config,
-		client: client,
+	m := &Mistral{config: config}
+	m.compat = newOpenAICompat("mistral", headers)
+	m.compat.model = config.Model
+	m.compat.maxTokens = config.MaxTokens
+	m.compat.temperature = config.Temperature
+	m.compat.maxEventSize = config.MaxEventSize
+	m.compat.interceptors = config.Interceptors
+	m.compat.url = func(string) string {
+		return config.BaseURL + "/v1/chat/completions"
 	}
+	m.compat.message = toMistralMessage
+	m.compat.customize = m.customize
+	m.compat.nativePrefix = true
+	m.compat.omitUser = true
+	return m
 }
 
 // NewMistralFromEnv creates a Mistral provider from environment variables
@@ -79,53 +117,25 @@ func (m *Mistral) Name() string {
 	return "mistral"
 }
 
-// Complete sends a completion request to Mistral
-func (m *Mistral) Complete(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
-	mistralReq := m.buildRequest(req)
-
-	var mistralResp mistralResponse
-	statusCode, err := m.client.Post(
-		m.config.BaseURL+"/v1/chat/completions",
-		mistralReq,
-		&mistralResp,
-		nil,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
+// Capabilities returns the request features the API handles natively
+func (m *Mistral) Capabilities() simpleai.Capabilities {
+	return simpleai.Capabilities{Tools: true, StreamPrefix: true}
+}
 
-	if statusCode != 200 {
-		return nil, simpleai.NewProviderError(
-			"mistral",
-			int(statusCode),
-			"request failed",
-			"http_error",
-		)
-	}
+// Model returns the default model
+func (m *Mistral) Model() string {
+	return m.config.Model
+}
 
-	return m.parseResponse(&mistralResp), nil
+// Complete sends a completion request to Mistral
+func (m *Mistral) Complete(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+	return m.compat.complete(ctx, req)
 }
 
-// Stream sends a streaming completion request
+// Stream sends a streaming completion request.
+// Tool calls are delivered on the final (Done) event.
 func (m *Mistral) Stream(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
-	mistralReq := m.buildRequest(req)
-	mistralReq.Stream = true
-
-	// Use goutil PostStream for raw response access
-	resp, err := m.client.PostStream(m.config.BaseURL+"/v1/chat/completions", mistralReq)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		defer resp.Body.Close()
-		return nil, m.handleError(resp)
-	}
-
-	out := make(chan simpleai.StreamEvent)
-	go m.streamResponse(ctx, resp.Body, out)
-
-	return out, nil
+	return m.compat.stream(ctx, req)
 }
 
 // CountTokens estimates token count
@@ -133,182 +143,41 @@ func (m *Mistral) CountTokens(text string) int {
 	return len(text) / 4
 }
 
-// Internal types for Mistral API (OpenAI-compatible format)
-type mistralRequest struct {
-	Model       string           `json:"model"`
-	Messages    []mistralMessage `json:"messages"`
-	MaxTokens   int              `json:"max_tokens,omitempty"`
-	Temperature float64          `json:"temperature,omitempty"`
-	TopP        float64          `json:"top_p,omitempty"`
-	Stream      bool             `json:"stream,omitempty"`
-	SafePrompt  bool             `json:"safe_prompt,omitempty"`
-	RandomSeed  int              `json:"random_seed,omitempty"`
-}
-
-type mistralMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-type mistralResponse struct {
-	ID      string          `json:"id"`
-	Object  string          `json:"object"`
-	Created int64           `json:"created"`
-	Model   string          `json:"model"`
-	Choices []mistralChoice `json:"choices"`
-	Usage   mistralUsage    `json:"usage"`
-}
-
-type mistralChoice struct {
-	Index        int            `json:"index"`
-	Message      mistralMessage `json:"message"`
-	Delta        mistralMessage `json:"delta"`
-	FinishReason string         `json:"finish_reason"`
-}
-
-type mistralUsage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
-}
-
-type mistralErrorResponse struct {
-	Error struct {
-		Message string `json:"message"`
-		Type    string `json:"type"`
-		Code    string `json:"code"`
-	} `json:"error"`
-}
-
-func (m *Mistral) buildRequest(req *simpleai.Request) *mistralRequest {
-	messages := make([]mistralMessage, 0, len(req.Messages)+1)
-
-	if req.SystemPrompt != "" {
-		messages = append(messages, mistralMessage{
-			Role:    "system",
-			Content: req.SystemPrompt,
-		})
-	}
-
-	for _, msg := range req.Messages {
-		messages = append(messages, mistralMessage{
-			Role:    string(msg.Role),
-			Content: msg.Content,
-		})
-	}
-
-	model := req.Model
-	if model == "" {
-		model = m.config.Model
-	}
-
-	maxTokens := req.MaxTokens
-	if maxTokens == 0 {
-		maxTokens = m.config.MaxTokens
-	}
-
-	temp := req.Temperature
-	if temp == 0 {
-		temp = m.config.Temperature
+// toMistralMessage converts a message; Mistral only accepts names on tool
+// results, so other speakers are folded into the content
+func toMistralMessage(msg simpleai.Message) openaiMessage {
+	m := toOpenAIMessage(msg)
+	if msg.Role != simpleai.RoleTool {
+		m.Name = ""
+		m.Content = speakerContent(msg)
 	}
-
-	return &mistralRequest{
-		Model:       model,
-		Messages:    messages,
-		MaxTokens:   maxTokens,
-		Temperature: temp,
-		TopP:        req.TopP,
-		SafePrompt:  m.config.SafePrompt,
-	}
-}
-
-func (m *Mistral) handleError(resp *http.Response) error {
-	body, _ := io.ReadAll(resp.Body)
-
-	var errResp mistralErrorResponse
-	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
-		return simpleai.NewProviderError(
-			"mistral",
-			resp.StatusCode,
-			errResp.Error.Message,
-			errResp.Error.Type,
-		)
-	}
-
-	return simpleai.NewProviderError(
-		"mistral",
-		resp.StatusCode,
-		string(body),
-		"unknown",
-	)
+	return m
 }
 
-func (m *Mistral) parseResponse(resp *mistralResponse) *simpleai.Response {
-	var content string
-	var finishReason string
-
-	if len(resp.Choices) > 0 {
-		content = resp.Choices[0].Message.Content
-		finishReason = resp.Choices[0].FinishReason
-	}
+// customize applies the Mistral options and native assistant prefix
+func (m *Mistral) customize(ctx context.Context, req *simpleai.Request, body *openaiRequest) {
+	opts, _ := ctx.Value(mistralOptionsKey{}).(MistralOptions)
 
-	return &simpleai.Response{
-		Content:      content,
-		Model:        resp.Model,
-		FinishReason: finishReason,
-		Usage: simpleai.Usage{
-			PromptTokens:     resp.Usage.PromptTokens,
-			CompletionTokens: resp.Usage.CompletionTokens,
-			TotalTokens:      resp.Usage.TotalTokens,
-		},
+	if prefix := cmp.Or(req.AssistantPrefix, opts.Prefix); prefix != "" {
+		body.Messages = append(body.Messages, openaiMessage{
+			Role:    "assistant",
+			Content: prefix,
+			Prefix:  true,
+		})
 	}
-}
-
-func (m *Mistral) streamResponse(ctx context.Context, body io.ReadCloser, out chan<- simpleai.StreamEvent) {
-	defer close(out)
-	defer body.Close()
 
-	scanner := bufio.NewScanner(body)
-	for scanner.Scan() {
-		select {
-		case <-ctx.Done():
-			out <- simpleai.StreamEvent{Error: ctx.Err(), Done: true}
-			return
-		default:
-		}
+	body.SafePrompt = m.config.SafePrompt
+	body.RandomSeed = opts.RandomSeed
+	body.ParallelToolCalls = opts.ParallelToolCalls
 
-		line := scanner.Text()
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
-
-		data := strings.TrimPrefix(line, "data: ")
-		if data == "[DONE]" {
-			out <- simpleai.StreamEvent{Done: true}
-			return
-		}
-
-		var resp mistralResponse
-		if err := json.Unmarshal([]byte(data), &resp); err != nil {
-			continue
-		}
-
-		if len(resp.Choices) > 0 {
-			choice := resp.Choices[0]
-			if choice.Delta.Content != "" {
-				out <- simpleai.StreamEvent{Content: choice.Delta.Content}
-			}
-			if choice.FinishReason != "" {
-				out <- simpleai.StreamEvent{
-					Done:         true,
-					FinishReason: choice.FinishReason,
-				}
-				return
-			}
-		}
+	// Typed options take precedence over the generic request fields
+	if len(opts.Tools) > 0 {
+		body.Tools = openaiTools(opts.Tools)
 	}
-
-	if err := scanner.Err(); err != nil {
-		out <- simpleai.StreamEvent{Error: err, Done: true}
+	switch toolChoice := cmp.Or(opts.ToolChoice, req.ToolChoice); toolChoice {
+	case MistralToolChoiceAny:
+		body.ToolChoice = toolChoice
+	default:
+		body.ToolChoice = openaiToolChoice(toolChoice)
 	}
 }
@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// MistralFIMRequest is a fill-in-the-middle code completion request
+type MistralFIMRequest struct {
+	Model       string // Defaults to MistralDefaultFIMModel
+	Prompt      string // Code before the cursor
+	Suffix      string // Code after the cursor
+	MaxTokens   int
+	MinTokens   int
+	Temperature float64
+	TopP        float64
+	Stop        []string
+	RandomSeed  int
+}
+
+type mistralFIMRequest struct {
+	Model       string   `json:"model"`
+	Prompt      string   `json:"prompt"`
+	Suffix      string   `json:"suffix,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	MinTokens   int      `json:"min_tokens,omitempty"`
+	Temperature float64  `json:"temperature,omitempty"`
+	TopP        float64  `json:"top_p,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	RandomSeed  int      `json:"random_seed,omitempty"`
+	Stream      bool     `json:"stream,omitempty"`
+}
+
+// FIM completes the code between Prompt and Suffix using the
+// /v1/fim/completions endpoint (Codestral models)
+func (m *Mistral) FIM(ctx context.Context, req *MistralFIMRequest) (*simpleai.Response, error) {
+	return m.compat.post(ctx, m.config.BaseURL+"/v1/fim/completions", m.buildFIMRequest(req))
+}
+
+// FIMStream sends a streaming fill-in-the-middle request
+func (m *Mistral) FIMStream(ctx context.Context, req *MistralFIMRequest) (<-chan simpleai.StreamEvent, error) {
+	fimReq := m.buildFIMRequest(req)
+	fimReq.Stream = true
+
+	return m.compat.postStream(ctx, m.config.BaseURL+"/v1/fim/completions", fimReq)
+}
+
+func (m *Mistral) buildFIMRequest(req *MistralFIMRequest) *mistralFIMRequest {
+	model := req.Model
+	if model == "" {
+		model = MistralDefaultFIMModel
+	}
+
+	maxTokens := req.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = m.config.MaxTokens
+	}
+
+	return &mistralFIMRequest{
+		Model:       model,
+		Prompt:      req.Prompt,
+		Suffix:      req.Suffix,
+		MaxTokens:   maxTokens,
+		MinTokens:   req.MinTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		Stop:        req.Stop,
+		RandomSeed:  req.RandomSeed,
+	}
+}
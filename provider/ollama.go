@@ -2,6 +2,7 @@ package provider
 
 import (
 	"bufio"
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	medahttp "github.com/medatechnology/goutil/http"
 	"github.com/medatechnology/goutil/utils"
 	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/internal/sse"
 )
 
 const (
@@ -26,12 +28,19 @@ type OllamaConfig struct {
 	MaxTokens   int
 	Temperature float64
 	TopP        float64
+
+	// MaxEventSize limits a single stream event in bytes (default 4MB)
+	MaxEventSize int
+
+	// Interceptors see the raw HTTP requests and responses, in order
+	Interceptors []Interceptor
 }
 
 // Ollama implements the Provider interface for local Ollama models
 type Ollama struct {
-	config OllamaConfig
-	client medahttp.HttpClient
+	config  OllamaConfig
+	client  medahttp.HttpClient
+	headers map[string][]string
 }
 
 // NewOllama creates a new Ollama provider
@@ -49,14 +58,17 @@ func NewOllama(config OllamaConfig) *Ollama {
 		config.Temperature = 0.7
 	}
 
-	client := medahttp.NewHttp()
-	client.SetHeader(map[string][]string{
+	headers := map[string][]string{
 		"Content-Type": {"application/json"},
-	})
+	}
+
+	client := medahttp.NewHttp()
+	client.SetHeader(headers)
 
 	return &Ollama{
-		config: config,
-		client: client,
+		config:  config,
+		client:  client,
+		headers: headers,
 	}
 }
 
@@ -74,12 +86,22 @@ func (o *Ollama) Name() string {
 	return "ollama"
 }
 
+// Capabilities returns the request features the API handles natively
+func (o *Ollama) Capabilities() simpleai.Capabilities {
+	return simpleai.Capabilities{Tools: true, StreamPrefix: true}
+}
+
+// Model returns the default model
+func (o *Ollama) Model() string {
+	return o.config.Model
+}
+
 // Complete sends a completion request to Ollama
 func (o *Ollama) Complete(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
 	ollamaReq := o.buildRequest(req, false)
 
 	var ollamaResp ollamaResponse
-	statusCode, err := o.client.Post(
+	statusCode, err := o.httpClient(ctx).Post(
 		o.config.BaseURL+"/api/chat",
 		ollamaReq,
 		&ollamaResp,
@@ -98,7 +120,10 @@ func (o *Ollama) Complete(ctx context.Context, req *simpleai.Request) (*simpleai
 		)
 	}
 
-	return o.parseResponse(&ollamaResp), nil
+	resp := o.parseResponse(&ollamaResp)
+	resp.Content = req.AssistantPrefix + resp.Content
+	applyStop(req, resp)
+	return resp, nil
 }
 
 // Stream sends a streaming completion request
@@ -106,7 +131,7 @@ func (o *Ollama) Stream(ctx context.Context, req *simpleai.Request) (<-chan simp
 	ollamaReq := o.buildRequest(req, true)
 
 	// Use goutil PostStream for raw response access
-	resp, err := o.client.PostStream(o.config.BaseURL+"/api/chat", ollamaReq)
+	resp, err := o.httpClient(ctx).PostStream(o.config.BaseURL+"/api/chat", ollamaReq)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -119,7 +144,7 @@ func (o *Ollama) Stream(ctx context.Context, req *simpleai.Request) (<-chan simp
 	out := make(chan simpleai.StreamEvent)
 	go o.streamResponse(ctx, resp.Body, out)
 
-	return out, nil
+	return stopStream(req, prefixStream(req.AssistantPrefix, out)), nil
 }
 
 // CountTokens estimates token count
@@ -127,17 +152,32 @@ func (o *Ollama) CountTokens(text string) int {
 	return len(text) / 4
 }
 
+// httpClient returns the HTTP client to use for a request
+func (o *Ollama) httpClient(ctx context.Context) httpDoer {
+	return requestClient(ctx, o.client, o.headers, o.config.Interceptors)
+}
+
 // Internal types for Ollama API
 type ollamaRequest struct {
 	Model    string          `json:"model"`
 	Messages []ollamaMessage `json:"messages"`
 	Stream   bool            `json:"stream"`
 	Options  ollamaOptions   `json:"options,omitempty"`
+	Tools    []openaiTool    `json:"tools,omitempty"`
 }
 
 type ollamaMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
 }
 
 type ollamaOptions struct {
@@ -176,9 +216,27 @@ func (o *Ollama) buildRequest(req *simpleai.Request, stream bool) *ollamaRequest
 	}
 
 	for _, msg := range req.Messages {
-		messages = append(messages, ollamaMessage{
+		m := ollamaMessage{
 			Role:    string(msg.Role),
-			Content: msg.Content,
+			Content: speakerContent(msg),
+		}
+		if msg.Role == simpleai.RoleTool {
+			m.ToolName = msg.Name
+		}
+		for _, call := range msg.ToolCalls {
+			var tc ollamaToolCall
+			tc.Function.Name = call.Name
+			tc.Function.Arguments = toolArguments(call.Arguments)
+			m.ToolCalls = append(m.ToolCalls, tc)
+		}
+		messages = append(messages, m)
+	}
+
+	// A trailing assistant message is continued rather than answered
+	if req.AssistantPrefix != "" {
+		messages = append(messages, ollamaMessage{
+			Role:    "assistant",
+			Content: req.AssistantPrefix,
 		})
 	}
 
@@ -201,6 +259,7 @@ func (o *Ollama) buildRequest(req *simpleai.Request, stream bool) *ollamaRequest
 		Model:    model,
 		Messages: messages,
 		Stream:   stream,
+		Tools:    openaiTools(req.Tools),
 		Options: ollamaOptions{
 			NumPredict:  maxTokens,
 			Temperature: temp,
@@ -232,10 +291,20 @@ func (o *Ollama) handleError(resp *http.Response) error {
 }
 
 func (o *Ollama) parseResponse(resp *ollamaResponse) *simpleai.Response {
+	var toolCalls []simpleai.ToolCall
+	for i, call := range resp.Message.ToolCalls {
+		toolCalls = append(toolCalls, simpleai.ToolCall{
+			ID:        toolCallID(i),
+			Name:      call.Function.Name,
+			Arguments: string(call.Function.Arguments),
+		})
+	}
+
 	return &simpleai.Response{
 		Content:      resp.Message.Content,
 		Model:        resp.Model,
 		FinishReason: resp.DoneReason,
+		ToolCalls:    toolCalls,
 		Usage: simpleai.Usage{
 			PromptTokens:     resp.PromptEvalCount,
 			CompletionTokens: resp.EvalCount,
@@ -248,13 +317,26 @@ func (o *Ollama) streamResponse(ctx context.Context, body io.ReadCloser, out cha
 	defer close(out)
 	defer body.Close()
 
-	scanner := bufio.NewScanner(body)
-	for scanner.Scan() {
+	// Once ctx is done nobody may be reading, so sends give up rather
+	// than block the goroutine
+	send := func(event simpleai.StreamEvent) bool {
 		select {
+		case out <- event:
+			return true
 		case <-ctx.Done():
-			out <- simpleai.StreamEvent{Error: ctx.Err(), Done: true}
+			return false
+		}
+	}
+
+	// Ollama streams newline-delimited JSON rather than SSE. Tool calls
+	// may arrive before the final chunk.
+	var toolCalls []simpleai.ToolCall
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(nil, cmp.Or(o.config.MaxEventSize, sse.DefaultMaxEventSize))
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			send(simpleai.StreamEvent{Error: ctx.Err(), Done: true})
 			return
-		default:
 		}
 
 		line := strings.TrimSpace(scanner.Text())
@@ -267,20 +349,36 @@ func (o *Ollama) streamResponse(ctx context.Context, body io.ReadCloser, out cha
 			continue
 		}
 
+		for _, call := range resp.Message.ToolCalls {
+			toolCalls = append(toolCalls, simpleai.ToolCall{
+				ID:        toolCallID(len(toolCalls)),
+				Name:      call.Function.Name,
+				Arguments: string(call.Function.Arguments),
+			})
+		}
+
 		if resp.Message.Content != "" {
-			out <- simpleai.StreamEvent{Content: resp.Message.Content}
+			if !send(simpleai.StreamEvent{Content: resp.Message.Content}) {
+				return
+			}
 		}
 
 		if resp.Done {
-			out <- simpleai.StreamEvent{
+			send(simpleai.StreamEvent{
 				Done:         true,
 				FinishReason: resp.DoneReason,
-			}
+				ToolCalls:    toolCalls,
+				Usage: &simpleai.Usage{
+					PromptTokens:     resp.PromptEvalCount,
+					CompletionTokens: resp.EvalCount,
+					TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+				},
+			})
 			return
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		out <- simpleai.StreamEvent{Error: err, Done: true}
+		send(simpleai.StreamEvent{Error: err, Done: true})
 	}
 }
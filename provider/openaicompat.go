@@ -0,0 +1,402 @@
+package provider
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	medahttp "github.com/medatechnology/goutil/http"
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/internal/sse"
+)
+
+// openaiCompat implements the OpenAI chat completions protocol for every
+// provider that speaks it (OpenAI, Groq, Mistral and Hugging Face). A
+// provider sets its endpoint and defaults and adjusts requests with hooks;
+// request building, response parsing and streaming live here.
+type openaiCompat struct {
+	name         string // Provider name used in errors
+	client       medahttp.HttpClient
+	headers      map[string][]string
+	interceptors []Interceptor
+
+	// Request defaults
+	model        string
+	maxTokens    int
+	temperature  float64
+	maxEventSize int
+
+	// url returns the chat completions URL for a model
+	url func(model string) string
+	// message converts a message; toOpenAIMessage when nil
+	message func(msg simpleai.Message) openaiMessage
+	// customize sets provider-specific request fields
+	customize func(ctx context.Context, req *simpleai.Request, body *openaiRequest)
+	// handleErr decodes non-OK responses; handleError when nil
+	handleErr func(resp *http.Response) error
+
+	// nativePrefix is set when customize sends the assistant prefix and the
+	// API returns content starting with it; otherwise the prefix is emulated
+	nativePrefix bool
+	// omitUser is set for APIs without the user field
+	omitUser bool
+	// streamUsage asks for usage on streams through stream_options; other
+	// APIs either send it unasked or not at all
+	streamUsage bool
+}
+
+// newOpenAICompat creates the shared base with a client sending headers
+func newOpenAICompat(name string, headers map[string][]string) *openaiCompat {
+	client := medahttp.NewHttp()
+	client.SetHeader(headers)
+	return &openaiCompat{name: name, client: client, headers: headers}
+}
+
+// Internal types for the OpenAI chat completions API
+type openaiRequest struct {
+	Model       string          `json:"model"`
+	Messages    []openaiMessage `json:"messages"`
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+	Temperature float64         `json:"temperature,omitempty"`
+	TopP        float64         `json:"top_p,omitempty"`
+	Stream      bool            `json:"stream,omitempty"`
+	Stop        []string        `json:"stop,omitempty"`
+	User        string          `json:"user,omitempty"`
+	Tools       []openaiTool    `json:"tools,omitempty"`
+	ToolChoice  any             `json:"tool_choice,omitempty"`
+
+	ParallelToolCalls *bool `json:"parallel_tool_calls,omitempty"`
+
+	StreamOptions *openaiStreamOptions `json:"stream_options,omitempty"`
+
+	// Mistral extensions
+	SafePrompt bool `json:"safe_prompt,omitempty"`
+	RandomSeed int  `json:"random_seed,omitempty"`
+}
+
+type openaiStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openaiMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	Name       string           `json:"name,omitempty"`
+	ToolCalls  []openaiToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+	Prefix     bool             `json:"prefix,omitempty"` // Mistral assistant prefill
+}
+
+type openaiToolCall struct {
+	Index    *int   `json:"index,omitempty"` // Set on streamed deltas
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openaiResponse struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []openaiChoice `json:"choices"`
+	Usage   openaiUsage    `json:"usage"`
+}
+
+type openaiChoice struct {
+	Index        int           `json:"index"`
+	Message      openaiMessage `json:"message"`
+	Delta        openaiMessage `json:"delta"`
+	FinishReason string        `json:"finish_reason"`
+}
+
+type openaiUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type openaiErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+	} `json:"error"`
+}
+
+// complete sends a chat completion request
+func (c *openaiCompat) complete(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+	body := c.buildRequest(ctx, req)
+	resp, err := c.post(ctx, c.url(body.Model), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.nativePrefix {
+		resp.Content = ensurePrefix(resp.Content, req.AssistantPrefix)
+	}
+	applyStop(req, resp)
+	return resp, nil
+}
+
+// stream sends a streaming chat completion request
+func (c *openaiCompat) stream(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
+	body := c.buildRequest(ctx, req)
+	body.Stream = true
+	if c.streamUsage {
+		body.StreamOptions = &openaiStreamOptions{IncludeUsage: true}
+	}
+	stream, err := c.postStream(ctx, c.url(body.Model), body)
+	if err != nil {
+		return nil, err
+	}
+	return stopStream(req, stream), nil
+}
+
+// post sends body to url and parses the completion response
+func (c *openaiCompat) post(ctx context.Context, url string, body any) (*simpleai.Response, error) {
+	var openaiResp openaiResponse
+	statusCode, err := c.httpClient(ctx).Post(url, body, &openaiResp, nil)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if statusCode != 200 {
+		return nil, simpleai.NewProviderError(
+			c.name,
+			int(statusCode),
+			"request failed",
+			"http_error",
+		)
+	}
+
+	return c.parseResponse(&openaiResp), nil
+}
+
+// postStream sends body to url and streams the response events
+func (c *openaiCompat) postStream(ctx context.Context, url string, body any) (<-chan simpleai.StreamEvent, error) {
+	// Use goutil PostStream for raw response access
+	resp, err := c.httpClient(ctx).PostStream(url, body)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		if c.handleErr != nil {
+			return nil, c.handleErr(resp)
+		}
+		return nil, c.handleError(resp)
+	}
+
+	out := make(chan simpleai.StreamEvent)
+	go c.streamResponse(ctx, resp.Body, out)
+
+	return out, nil
+}
+
+// httpClient returns the HTTP client to use for a request
+func (c *openaiCompat) httpClient(ctx context.Context) httpDoer {
+	return requestClient(ctx, c.client, c.headers, c.interceptors)
+}
+
+func (c *openaiCompat) buildRequest(ctx context.Context, req *simpleai.Request) *openaiRequest {
+	if !c.nativePrefix {
+		req = emulatePrefix(req)
+	}
+
+	messages := make([]openaiMessage, 0, len(req.Messages)+1)
+
+	if req.SystemPrompt != "" {
+		messages = append(messages, openaiMessage{
+			Role:    "system",
+			Content: req.SystemPrompt,
+		})
+	}
+
+	convert := c.message
+	if convert == nil {
+		convert = toOpenAIMessage
+	}
+	for _, msg := range req.Messages {
+		messages = append(messages, convert(msg))
+	}
+
+	body := &openaiRequest{
+		Model:       cmp.Or(req.Model, c.model),
+		Messages:    messages,
+		MaxTokens:   cmp.Or(req.MaxTokens, c.maxTokens),
+		Temperature: cmp.Or(req.Temperature, c.temperature),
+		TopP:        req.TopP,
+		Stop:        req.Stop,
+		Tools:       openaiTools(req.Tools),
+		ToolChoice:  openaiToolChoice(req.ToolChoice),
+	}
+	if !c.omitUser {
+		body.User = metadataUserID(ctx)
+	}
+	if c.customize != nil {
+		c.customize(ctx, req, body)
+	}
+	return body
+}
+
+// toOpenAIMessage converts a message, including names and tool calls,
+// to the OpenAI chat format
+func toOpenAIMessage(msg simpleai.Message) openaiMessage {
+	m := openaiMessage{
+		Role:       string(msg.Role),
+		Content:    msg.Content,
+		Name:       openaiName(msg.Name),
+		ToolCallID: msg.ToolCallID,
+	}
+	for _, call := range msg.ToolCalls {
+		tc := openaiToolCall{ID: call.ID, Type: "function"}
+		tc.Function.Name = call.Name
+		tc.Function.Arguments = call.Arguments
+		m.ToolCalls = append(m.ToolCalls, tc)
+	}
+	return m
+}
+
+func (c *openaiCompat) handleError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+
+	var errResp openaiErrorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
+		return simpleai.NewProviderError(
+			c.name,
+			resp.StatusCode,
+			errResp.Error.Message,
+			errResp.Error.Type,
+		)
+	}
+
+	return simpleai.NewProviderError(
+		c.name,
+		resp.StatusCode,
+		string(body),
+		"unknown",
+	)
+}
+
+func (c *openaiCompat) parseResponse(resp *openaiResponse) *simpleai.Response {
+	var content string
+	var finishReason string
+	var toolCalls []simpleai.ToolCall
+
+	if len(resp.Choices) > 0 {
+		content = resp.Choices[0].Message.Content
+		finishReason = resp.Choices[0].FinishReason
+		toolCalls = fromOpenAIToolCalls(resp.Choices[0].Message.ToolCalls)
+	}
+
+	return &simpleai.Response{
+		Content:      content,
+		Model:        resp.Model,
+		FinishReason: finishReason,
+		ToolCalls:    toolCalls,
+		Usage: simpleai.Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+	}
+}
+
+func (c *openaiCompat) streamResponse(ctx context.Context, body io.ReadCloser, out chan<- simpleai.StreamEvent) {
+	defer close(out)
+	defer body.Close()
+
+	// Tool calls arrive in deltas and usage may follow the finish reason;
+	// deliver them on the final event
+	var toolCalls []simpleai.ToolCall
+	var final *simpleai.StreamEvent
+	var usage *simpleai.Usage
+
+	events := sse.NewReader(body, c.maxEventSize)
+	for events.Next() {
+		select {
+		case <-ctx.Done():
+			out <- simpleai.StreamEvent{Error: ctx.Err(), Done: true}
+			return
+		default:
+		}
+
+		data := events.Event().Data
+		if data == "[DONE]" {
+			if final == nil {
+				final = &simpleai.StreamEvent{Done: true, ToolCalls: toolCalls}
+			}
+			break
+		}
+
+		var resp openaiResponse
+		if err := json.Unmarshal([]byte(data), &resp); err != nil {
+			continue
+		}
+		if resp.Usage.TotalTokens > 0 {
+			usage = &simpleai.Usage{
+				PromptTokens:     resp.Usage.PromptTokens,
+				CompletionTokens: resp.Usage.CompletionTokens,
+				TotalTokens:      resp.Usage.TotalTokens,
+			}
+		}
+
+		if len(resp.Choices) > 0 {
+			choice := resp.Choices[0]
+			if choice.Delta.Content != "" {
+				out <- simpleai.StreamEvent{Content: choice.Delta.Content}
+			}
+			toolCalls = mergeToolCallDeltas(toolCalls, choice.Delta.ToolCalls)
+			if choice.FinishReason != "" {
+				final = &simpleai.StreamEvent{
+					Done:         true,
+					FinishReason: choice.FinishReason,
+					ToolCalls:    toolCalls,
+				}
+				if !c.streamUsage {
+					break // Usage, if any, came with this chunk
+				}
+			}
+		}
+	}
+
+	if err := events.Err(); err != nil && final == nil {
+		out <- simpleai.StreamEvent{Error: err, Done: true}
+		return
+	}
+	if final != nil {
+		final.Usage = usage
+		out <- *final
+	}
+}
+
+// mergeToolCallDeltas adds streamed tool call deltas to calls. OpenAI sends
+// a call's ID and name first and its arguments in later fragments; Mistral
+// sends each call whole.
+func mergeToolCallDeltas(calls []simpleai.ToolCall, deltas []openaiToolCall) []simpleai.ToolCall {
+	for _, delta := range deltas {
+		if delta.ID == "" && len(calls) > 0 {
+			i := len(calls) - 1
+			if delta.Index != nil && *delta.Index < len(calls) {
+				i = *delta.Index
+			}
+			calls[i].Name += delta.Function.Name
+			calls[i].Arguments += delta.Function.Arguments
+			continue
+		}
+		calls = append(calls, simpleai.ToolCall{
+			ID:        delta.ID,
+			Name:      delta.Function.Name,
+			Arguments: delta.Function.Arguments,
+		})
+	}
+	return calls
+}
@@ -0,0 +1,386 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	medahttp "github.com/medatechnology/goutil/http"
+	"github.com/medatechnology/goutil/utils"
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/internal/sse"
+)
+
+const (
+	PerplexityDefaultBaseURL = "https://api.perplexity.ai"
+	PerplexityDefaultModel   = "sonar"
+)
+
+// PerplexityConfig holds configuration for the Perplexity provider
+type PerplexityConfig struct {
+	APIKey      string
+	BaseURL     string
+	Model       string // sonar, sonar-pro, sonar-reasoning, ...
+	MaxTokens   int
+	Temperature float64
+	TopP        float64
+
+	// Default search options, overridable per request with WithPerplexityOptions
+	SearchDomainFilter  []string // Restrict (or with a "-" prefix, exclude) domains
+	SearchRecencyFilter string   // hour, day, week or month
+
+	// MaxEventSize limits a single stream event in bytes (default 4MB)
+	MaxEventSize int
+
+	// Interceptors see the raw HTTP requests and responses, in order
+	Interceptors []Interceptor
+}
+
+// PerplexityOptions are per-request web search options
+type PerplexityOptions struct {
+	SearchDomainFilter     []string
+	SearchRecencyFilter    string
+	ReturnRelatedQuestions bool
+}
+
+type perplexityOptionsKey struct{}
+
+// WithPerplexityOptions attaches Perplexity search options to the request context
+func WithPerplexityOptions(ctx context.Context, opts PerplexityOptions) context.Context {
+	return context.WithValue(ctx, perplexityOptionsKey{}, opts)
+}
+
+// Perplexity implements the Provider interface for Perplexity's search-grounded
+// sonar models. Web sources are returned in Response.Citations.
+type Perplexity struct {
+	config  PerplexityConfig
+	client  medahttp.HttpClient
+	headers map[string][]string
+}
+
+// NewPerplexity creates a new Perplexity provider
+func NewPerplexity(config PerplexityConfig) *Perplexity {
+	if config.BaseURL == "" {
+		config.BaseURL = PerplexityDefaultBaseURL
+	}
+	if config.Model == "" {
+		config.Model = PerplexityDefaultModel
+	}
+	if config.MaxTokens == 0 {
+		config.MaxTokens = 4096
+	}
+	if config.Temperature == 0 {
+		config.Temperature = 0.2
+	}
+
+	headers := map[string][]string{
+		"Content-Type":  {"application/json"},
+		"Authorization": {"Bearer " + config.APIKey},
+	}
+
+	client := medahttp.NewHttp()
+	client.SetHeader(headers)
+
+	return &Perplexity{
+		config:  config,
+		client:  client,
+		headers: headers,
+	}
+}
+
+// NewPerplexityFromEnv creates a Perplexity provider from environment variables
+// Environment variables: PERPLEXITY_API_KEY, PERPLEXITY_MODEL (optional)
+func NewPerplexityFromEnv() *Perplexity {
+	return NewPerplexity(PerplexityConfig{
+		APIKey: utils.GetEnvString("PERPLEXITY_API_KEY", ""),
+		Model:  utils.GetEnvString("PERPLEXITY_MODEL", PerplexityDefaultModel),
+	})
+}
+
+// Name returns the provider name
+func (p *Perplexity) Name() string {
+	return "perplexity"
+}
+
+// Capabilities returns the request features the API handles natively
+func (p *Perplexity) Capabilities() simpleai.Capabilities {
+	return simpleai.Capabilities{}
+}
+
+// Model returns the default model
+func (p *Perplexity) Model() string {
+	return p.config.Model
+}
+
+// Complete sends a completion request to Perplexity
+func (p *Perplexity) Complete(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+	pplxReq := p.buildRequest(ctx, emulatePrefix(req))
+
+	var pplxResp perplexityResponse
+	statusCode, err := p.httpClient(ctx).Post(
+		p.config.BaseURL+"/chat/completions",
+		pplxReq,
+		&pplxResp,
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if statusCode != 200 {
+		return nil, simpleai.NewProviderError(
+			"perplexity",
+			int(statusCode),
+			"request failed",
+			"http_error",
+		)
+	}
+
+	resp := p.parseResponse(&pplxResp)
+	resp.Content = ensurePrefix(resp.Content, req.AssistantPrefix)
+	applyStop(req, resp)
+	return resp, nil
+}
+
+// Stream sends a streaming completion request.
+// Citations are delivered on the final (Done) event.
+func (p *Perplexity) Stream(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
+	pplxReq := p.buildRequest(ctx, emulatePrefix(req))
+	pplxReq.Stream = true
+
+	resp, err := p.httpClient(ctx).PostStream(p.config.BaseURL+"/chat/completions", pplxReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, p.handleError(resp)
+	}
+
+	out := make(chan simpleai.StreamEvent)
+	go p.streamResponse(ctx, resp.Body, out)
+
+	return stopStream(req, out), nil
+}
+
+// CountTokens estimates token count
+func (p *Perplexity) CountTokens(text string) int {
+	return len(text) / 4
+}
+
+// httpClient returns the HTTP client to use for a request
+func (p *Perplexity) httpClient(ctx context.Context) httpDoer {
+	return requestClient(ctx, p.client, p.headers, p.config.Interceptors)
+}
+
+// Internal types for Perplexity API
+type perplexityRequest struct {
+	Model                  string          `json:"model"`
+	Messages               []openaiMessage `json:"messages"`
+	MaxTokens              int             `json:"max_tokens,omitempty"`
+	Temperature            float64         `json:"temperature,omitempty"`
+	TopP                   float64         `json:"top_p,omitempty"`
+	Stream                 bool            `json:"stream,omitempty"`
+	SearchDomainFilter     []string        `json:"search_domain_filter,omitempty"`
+	SearchRecencyFilter    string          `json:"search_recency_filter,omitempty"`
+	ReturnRelatedQuestions bool            `json:"return_related_questions,omitempty"`
+}
+
+type perplexityResponse struct {
+	ID            string                   `json:"id"`
+	Model         string                   `json:"model"`
+	Choices       []openaiChoice           `json:"choices"`
+	Usage         openaiUsage              `json:"usage"`
+	Citations     []string                 `json:"citations"`
+	SearchResults []perplexitySearchResult `json:"search_results"`
+}
+
+type perplexitySearchResult struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	Date  string `json:"date"`
+}
+
+func (p *Perplexity) buildRequest(ctx context.Context, req *simpleai.Request) *perplexityRequest {
+	messages := make([]openaiMessage, 0, len(req.Messages)+1)
+
+	if req.SystemPrompt != "" {
+		messages = append(messages, openaiMessage{
+			Role:    "system",
+			Content: req.SystemPrompt,
+		})
+	}
+
+	for _, msg := range req.Messages {
+		// Perplexity has no tool role or names; fold them into the content
+		role, content := string(msg.Role), speakerContent(msg)
+		if msg.Role == simpleai.RoleTool {
+			role, content = "user", "Result of "+msg.Name+": "+msg.Content
+		}
+		messages = append(messages, openaiMessage{
+			Role:    role,
+			Content: content,
+		})
+	}
+
+	model := req.Model
+	if model == "" {
+		model = p.config.Model
+	}
+
+	maxTokens := req.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = p.config.MaxTokens
+	}
+
+	temp := req.Temperature
+	if temp == 0 {
+		temp = p.config.Temperature
+	}
+
+	opts, ok := ctx.Value(perplexityOptionsKey{}).(PerplexityOptions)
+	if !ok {
+		opts = PerplexityOptions{
+			SearchDomainFilter:  p.config.SearchDomainFilter,
+			SearchRecencyFilter: p.config.SearchRecencyFilter,
+		}
+	}
+
+	return &perplexityRequest{
+		Model:                  model,
+		Messages:               messages,
+		MaxTokens:              maxTokens,
+		Temperature:            temp,
+		TopP:                   req.TopP,
+		SearchDomainFilter:     opts.SearchDomainFilter,
+		SearchRecencyFilter:    opts.SearchRecencyFilter,
+		ReturnRelatedQuestions: opts.ReturnRelatedQuestions,
+	}
+}
+
+func (p *Perplexity) handleError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+
+	var errResp openaiErrorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
+		return simpleai.NewProviderError(
+			"perplexity",
+			resp.StatusCode,
+			errResp.Error.Message,
+			errResp.Error.Type,
+		)
+	}
+
+	return simpleai.NewProviderError(
+		"perplexity",
+		resp.StatusCode,
+		string(body),
+		"unknown",
+	)
+}
+
+func (p *Perplexity) parseResponse(resp *perplexityResponse) *simpleai.Response {
+	var content string
+	var finishReason string
+
+	if len(resp.Choices) > 0 {
+		content = resp.Choices[0].Message.Content
+		finishReason = resp.Choices[0].FinishReason
+	}
+
+	return &simpleai.Response{
+		Content:      content,
+		Model:        resp.Model,
+		FinishReason: finishReason,
+		Usage: simpleai.Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+		Citations: resp.citations(),
+	}
+}
+
+// citations converts the web sources into citations. Search results carry
+// titles, so they are preferred over the bare URL list. DocumentIndex matches
+// the [n] markers in the answer text, minus one.
+func (resp *perplexityResponse) citations() []simpleai.Citation {
+	if len(resp.SearchResults) > 0 {
+		citations := make([]simpleai.Citation, 0, len(resp.SearchResults))
+		for i, r := range resp.SearchResults {
+			citations = append(citations, simpleai.Citation{
+				DocumentIndex: i,
+				DocumentTitle: r.Title,
+				URL:           r.URL,
+			})
+		}
+		return citations
+	}
+
+	if len(resp.Citations) == 0 {
+		return nil
+	}
+	citations := make([]simpleai.Citation, 0, len(resp.Citations))
+	for i, url := range resp.Citations {
+		citations = append(citations, simpleai.Citation{
+			DocumentIndex: i,
+			URL:           url,
+		})
+	}
+	return citations
+}
+
+func (p *Perplexity) streamResponse(ctx context.Context, body io.ReadCloser, out chan<- simpleai.StreamEvent) {
+	defer close(out)
+	defer body.Close()
+
+	// Every chunk repeats the sources; keep the latest for the final event
+	var citations []simpleai.Citation
+
+	events := sse.NewReader(body, p.config.MaxEventSize)
+	for events.Next() {
+		select {
+		case <-ctx.Done():
+			out <- simpleai.StreamEvent{Error: ctx.Err(), Done: true}
+			return
+		default:
+		}
+
+		data := events.Event().Data
+		if data == "[DONE]" {
+			out <- simpleai.StreamEvent{Done: true, Citations: citations}
+			return
+		}
+
+		var resp perplexityResponse
+		if err := json.Unmarshal([]byte(data), &resp); err != nil {
+			continue
+		}
+
+		if c := resp.citations(); c != nil {
+			citations = c
+		}
+
+		if len(resp.Choices) > 0 {
+			choice := resp.Choices[0]
+			if choice.Delta.Content != "" {
+				out <- simpleai.StreamEvent{Content: choice.Delta.Content}
+			}
+			if choice.FinishReason != "" {
+				out <- simpleai.StreamEvent{
+					Done:         true,
+					FinishReason: choice.FinishReason,
+					Citations:    citations,
+				}
+				return
+			}
+		}
+	}
+
+	if err := events.Err(); err != nil {
+		out <- simpleai.StreamEvent{Error: err, Done: true}
+	}
+}
@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"strings"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// emulatePrefix returns a copy of req that instructs the model to begin its
+// answer with the assistant prefix, for providers without native prefill.
+// The request is returned unchanged when it has no prefix.
+func emulatePrefix(req *simpleai.Request) *simpleai.Request {
+	if req.AssistantPrefix == "" {
+		return req
+	}
+
+	instruction := "Begin your response with exactly the following text and continue from it:\n" + req.AssistantPrefix
+
+	r := *req
+	if r.SystemPrompt != "" {
+		r.SystemPrompt += "\n\n" + instruction
+		return &r
+	}
+
+	// Extend an existing system message so it is not overridden
+	for i := len(r.Messages) - 1; i >= 0; i-- {
+		if r.Messages[i].Role == simpleai.RoleSystem {
+			r.Messages = append([]simpleai.Message(nil), r.Messages...)
+			r.Messages[i].Content += "\n\n" + instruction
+			return &r
+		}
+	}
+
+	r.SystemPrompt = instruction
+	return &r
+}
+
+// ensurePrefix prepends the assistant prefix to content unless the
+// model already started with it
+func ensurePrefix(content, prefix string) string {
+	if prefix == "" || strings.HasPrefix(content, prefix) {
+		return content
+	}
+	return prefix + content
+}
+
+// prefixStream forwards events from stream, emitting the assistant prefix
+// first so the streamed text matches the complete response
+func prefixStream(prefix string, stream <-chan simpleai.StreamEvent) <-chan simpleai.StreamEvent {
+	if prefix == "" {
+		return stream
+	}
+
+	out := make(chan simpleai.StreamEvent)
+	go func() {
+		defer close(out)
+		out <- simpleai.StreamEvent{Content: prefix}
+		for event := range stream {
+			out <- event
+		}
+	}()
+	return out
+}
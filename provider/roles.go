@@ -0,0 +1,55 @@
+package provider
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// speakerContent returns the message content prefixed with the speaker name,
+// for providers without a name field
+func speakerContent(msg simpleai.Message) string {
+	if msg.Name == "" || msg.Role == simpleai.RoleTool {
+		return msg.Content
+	}
+	return msg.Name + ": " + msg.Content
+}
+
+// joinSystem combines system messages for providers that take a single
+// system prompt
+func joinSystem(system, content string) string {
+	if system == "" {
+		return content
+	}
+	return system + "\n\n" + content
+}
+
+// openaiName returns name restricted to the characters OpenAI-compatible
+// APIs accept (letters, digits, underscore and hyphen, at most 64)
+func openaiName(name string) string {
+	if name == "" {
+		return ""
+	}
+	clean := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	if len(clean) > 64 {
+		clean = clean[:64]
+	}
+	return clean
+}
+
+// toolArguments returns the tool call arguments as a JSON object,
+// for providers that expect structured arguments
+func toolArguments(arguments string) json.RawMessage {
+	if arguments == "" || !json.Valid([]byte(arguments)) {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(arguments)
+}
@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// applyStop normalizes stop sequence handling across providers. The stop
+// sequence reported by the provider (resp.StopSequence) is used when set;
+// otherwise a request stop sequence left at the end of the content is
+// detected. The matched sequence is stripped from Content unless
+// req.IncludeStop is set.
+func applyStop(req *simpleai.Request, resp *simpleai.Response) {
+	matched := resp.StopSequence
+	if matched == "" {
+		for _, stop := range req.Stop {
+			if stop != "" && strings.HasSuffix(resp.Content, stop) {
+				matched = stop
+				break
+			}
+		}
+	}
+	if matched == "" {
+		return
+	}
+
+	resp.StopSequence = matched
+	resp.Content = strings.TrimSuffix(resp.Content, matched)
+	if req.IncludeStop {
+		resp.Content += matched
+	}
+}
+
+// stopStream applies applyStop to a stream. Text that could be the start of
+// a stop sequence is held back until the next event shows it isn't, so a
+// matched sequence at the end can be stripped like in Complete. The stream
+// is returned unchanged when the request has no stop sequences.
+func stopStream(req *simpleai.Request, stream <-chan simpleai.StreamEvent) <-chan simpleai.StreamEvent {
+	if len(req.Stop) == 0 {
+		return stream
+	}
+	hold := 0
+	for _, stop := range req.Stop {
+		hold = max(hold, len(stop))
+	}
+
+	out := make(chan simpleai.StreamEvent)
+	go func() {
+		defer close(out)
+		var held string
+		for event := range stream {
+			held += event.Content
+			if !event.Done && event.Error == nil {
+				// Keep the last hold bytes, cut at a rune boundary
+				cut := max(len(held)-hold, 0)
+				for cut > 0 && !utf8.RuneStart(held[cut]) {
+					cut--
+				}
+				if cut > 0 {
+					event.Content, held = held[:cut], held[cut:]
+					out <- event
+				}
+				continue
+			}
+
+			if event.Error == nil {
+				resp := &simpleai.Response{Content: held, StopSequence: event.StopSequence}
+				applyStop(req, resp)
+				held, event.StopSequence = resp.Content, resp.StopSequence
+			}
+			if held != "" {
+				out <- simpleai.StreamEvent{Content: held}
+				held = ""
+			}
+			event.Content = ""
+			out <- event
+		}
+		if held != "" {
+			// Closed without a final event
+			out <- simpleai.StreamEvent{Content: held}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// openaiTool is the OpenAI-compatible function tool format, also used by
+// Groq and Ollama
+type openaiTool struct {
+	Type     string         `json:"type"`
+	Function openaiFunction `json:"function"`
+}
+
+type openaiFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+func openaiTools(tools []simpleai.Tool) []openaiTool {
+	if len(tools) == 0 {
+		return nil
+	}
+	out := make([]openaiTool, len(tools))
+	for i, tool := range tools {
+		out[i] = openaiTool{
+			Type: "function",
+			Function: openaiFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  toolParameters(tool),
+			},
+		}
+	}
+	return out
+}
+
+// openaiToolChoice maps a tool choice to the OpenAI format; a tool name
+// becomes a function object
+func openaiToolChoice(choice string) any {
+	switch choice {
+	case "":
+		return nil
+	case simpleai.ToolChoiceAuto, simpleai.ToolChoiceNone, simpleai.ToolChoiceRequired:
+		return choice
+	default:
+		return map[string]any{
+			"type":     "function",
+			"function": map[string]string{"name": choice},
+		}
+	}
+}
+
+func fromOpenAIToolCalls(calls []openaiToolCall) []simpleai.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]simpleai.ToolCall, len(calls))
+	for i, call := range calls {
+		out[i] = simpleai.ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		}
+	}
+	return out
+}
+
+// toolParameters returns the tool's JSON schema, defaulting to an object
+// without properties since some APIs require a schema
+func toolParameters(tool simpleai.Tool) map[string]any {
+	if tool.Parameters != nil {
+		return tool.Parameters
+	}
+	return map[string]any{"type": "object", "properties": map[string]any{}}
+}
+
+// toolCallID returns an ID for APIs that don't assign one to tool calls
+func toolCallID(i int) string {
+	return fmt.Sprintf("call_%d", i)
+}
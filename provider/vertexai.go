@@ -0,0 +1,227 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	medahttp "github.com/medatechnology/goutil/http"
+	"github.com/medatechnology/goutil/utils"
+	"github.com/medatechnology/simpleai"
+)
+
+const (
+	VertexAIDefaultLocation = "us-central1"
+	VertexAIDefaultModel    = "gemini-1.5-pro"
+)
+
+// VertexAIConfig holds configuration for the Vertex AI Gemini provider
+type VertexAIConfig struct {
+	ProjectID   string // Defaults to the project in the credentials file
+	Location    string // e.g. us-central1, europe-west4 or global
+	BaseURL     string // Overrides the regional endpoint
+	Model       string
+	MaxTokens   int
+	Temperature float64
+	TopP        float64
+
+	// TokenSource supplies OAuth2 access tokens.
+	// Defaults to Application Default Credentials.
+	TokenSource GoogleTokenSource
+
+	// MaxEventSize limits a single stream event in bytes (default 4MB)
+	MaxEventSize int
+
+	// Interceptors see the raw HTTP requests and responses, in order
+	Interceptors []Interceptor
+}
+
+// VertexAI implements the Provider interface for Gemini models served
+// through Google Cloud Vertex AI, authenticated with OAuth2 instead of an API key
+type VertexAI struct {
+	config  VertexAIConfig
+	gemini  *Gemini // Shared request building and response parsing
+	initErr error
+}
+
+// NewVertexAI creates a new Vertex AI provider.
+// Credential errors are reported by the first Complete or Stream call.
+func NewVertexAI(config VertexAIConfig) *VertexAI {
+	if config.Location == "" {
+		config.Location = VertexAIDefaultLocation
+	}
+	if config.Model == "" {
+		config.Model = VertexAIDefaultModel
+	}
+	if config.MaxTokens == 0 {
+		config.MaxTokens = 4096
+	}
+	if config.Temperature == 0 {
+		config.Temperature = 0.7
+	}
+
+	var initErr error
+	if config.TokenSource == nil {
+		ts, projectID, err := NewGoogleADCTokenSource()
+		if err != nil {
+			initErr = err
+		}
+		config.TokenSource = ts
+		if config.ProjectID == "" {
+			config.ProjectID = projectID
+		}
+	}
+	if initErr == nil && config.ProjectID == "" {
+		initErr = fmt.Errorf("vertexai: project ID is required")
+	}
+
+	if config.BaseURL == "" {
+		if config.Location == "global" {
+			config.BaseURL = "https://aiplatform.googleapis.com"
+		} else {
+			config.BaseURL = "https://" + config.Location + "-aiplatform.googleapis.com"
+		}
+	}
+
+	return &VertexAI{
+		config: config,
+		gemini: &Gemini{
+			config: GeminiConfig{
+				Model:        config.Model,
+				MaxTokens:    config.MaxTokens,
+				Temperature:  config.Temperature,
+				TopP:         config.TopP,
+				MaxEventSize: config.MaxEventSize,
+			},
+		},
+		initErr: initErr,
+	}
+}
+
+// NewVertexAIFromEnv creates a Vertex AI provider from environment variables
+// Environment variables: GOOGLE_CLOUD_PROJECT, GOOGLE_CLOUD_LOCATION (optional),
+// VERTEXAI_MODEL (optional), GOOGLE_APPLICATION_CREDENTIALS (optional, see ADC)
+func NewVertexAIFromEnv() *VertexAI {
+	return NewVertexAI(VertexAIConfig{
+		ProjectID: utils.GetEnvString("GOOGLE_CLOUD_PROJECT", ""),
+		Location:  utils.GetEnvString("GOOGLE_CLOUD_LOCATION", VertexAIDefaultLocation),
+		Model:     utils.GetEnvString("VERTEXAI_MODEL", VertexAIDefaultModel),
+	})
+}
+
+// Name returns the provider name
+func (v *VertexAI) Name() string {
+	return "vertexai"
+}
+
+// Capabilities returns the request features the API handles natively
+func (v *VertexAI) Capabilities() simpleai.Capabilities {
+	return simpleai.Capabilities{Tools: true}
+}
+
+// Model returns the default model
+func (v *VertexAI) Model() string {
+	return v.config.Model
+}
+
+// Complete sends a completion request to Vertex AI
+func (v *VertexAI) Complete(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+	client, err := v.httpClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	model := req.Model
+	if model == "" {
+		model = v.config.Model
+	}
+
+	var geminiResp geminiResponse
+	statusCode, err := client.Post(v.modelURL(model, "generateContent"), v.gemini.buildRequest(emulatePrefix(req)), &geminiResp, nil)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if statusCode != 200 {
+		return nil, simpleai.NewProviderError(
+			"vertexai",
+			int(statusCode),
+			"request failed",
+			"http_error",
+		)
+	}
+
+	resp := v.gemini.parseResponse(&geminiResp, model)
+	resp.Content = ensurePrefix(resp.Content, req.AssistantPrefix)
+	applyStop(req, resp)
+	return resp, nil
+}
+
+// Stream sends a streaming completion request
+func (v *VertexAI) Stream(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
+	client, err := v.httpClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	model := req.Model
+	if model == "" {
+		model = v.config.Model
+	}
+
+	resp, err := client.PostStream(v.modelURL(model, "streamGenerateContent")+"?alt=sse", v.gemini.buildRequest(emulatePrefix(req)))
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		err := v.gemini.handleError(resp)
+		if providerErr, ok := err.(*simpleai.ProviderError); ok {
+			providerErr.Provider = "vertexai"
+		}
+		return nil, err
+	}
+
+	out := make(chan simpleai.StreamEvent)
+	go v.gemini.streamResponse(ctx, resp.Body, out)
+
+	return stopStream(req, out), nil
+}
+
+// CountTokens estimates token count
+func (v *VertexAI) CountTokens(text string) int {
+	return len(text) / 4
+}
+
+// modelURL returns the publisher model endpoint for the given method
+func (v *VertexAI) modelURL(model, method string) string {
+	return fmt.Sprintf("%s/v1/projects/%s/locations/%s/publishers/google/models/%s:%s",
+		v.config.BaseURL, v.config.ProjectID, v.config.Location, model, method)
+}
+
+// httpClient returns a client authorized with a fresh access token
+func (v *VertexAI) httpClient(ctx context.Context) (httpDoer, error) {
+	if v.initErr != nil {
+		return nil, v.initErr
+	}
+
+	// Dry runs redact the token, so don't fetch one
+	var token string
+	if !simpleai.IsDryRun(ctx) {
+		var err error
+		if token, err = v.config.TokenSource.Token(ctx); err != nil {
+			return nil, fmt.Errorf("vertexai: failed to get access token: %w", err)
+		}
+	}
+
+	headers := map[string][]string{
+		"Content-Type":  {"application/json"},
+		"Authorization": {"Bearer " + token},
+	}
+
+	client := medahttp.NewHttp()
+	client.SetHeader(headers)
+
+	return requestClient(ctx, client, headers, v.config.Interceptors), nil
+}
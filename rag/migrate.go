@@ -0,0 +1,203 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+
+	"github.com/medatechnology/simpleai/embedding"
+)
+
+// ErrNotListable is returned when migrating from a store that can't list
+// its documents
+var ErrNotListable = errors.New("rag: store does not implement DocumentLister")
+
+// DocumentLister is implemented by vector stores that can return all their
+// documents, which migrating them requires
+type DocumentLister interface {
+	Documents(ctx context.Context) ([]embedding.Document, error)
+}
+
+// MigrationConfig holds configuration for re-embedding a store
+type MigrationConfig struct {
+	From     VectorStore        // Current store; must implement DocumentLister
+	To       VectorStore        // Store receiving the new embeddings
+	Embedder embedding.Embedder // New embedder
+
+	BatchSize int // Documents per EmbedBatch call (default 64)
+
+	// OnProgress is called after each batch with the documents migrated so
+	// far and the total
+	OnProgress func(done, total int)
+}
+
+// Migration re-embeds every document of a store with a new embedder, so
+// switching embedding models needs no custom scripts. During the migration,
+// Store keeps serving searches from the old store while writing to both,
+// so documents added meanwhile aren't lost.
+type Migration struct {
+	config MigrationConfig
+
+	mu      sync.Mutex
+	written map[string]bool // Migrated, or written or deleted through Store
+	clears  int             // Clear calls through Store
+}
+
+// NewMigration creates a migration
+func NewMigration(config MigrationConfig) *Migration {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 64
+	}
+	return &Migration{config: config, written: make(map[string]bool)}
+}
+
+// Run copies the documents of From into To with new embeddings. Documents
+// written through Store after Run listed them are left as written. Run can
+// be called again after a failure; it skips documents already migrated.
+func (m *Migration) Run(ctx context.Context) error {
+	lister, ok := m.config.From.(DocumentLister)
+	if !ok {
+		return ErrNotListable
+	}
+	m.mu.Lock()
+	clears := m.clears
+	m.mu.Unlock()
+	docs, err := lister.Documents(ctx)
+	if err != nil {
+		return err
+	}
+
+	total := len(docs)
+	for start := 0; start < total; start += m.config.BatchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch := docs[start:min(start+m.config.BatchSize, total)]
+
+		texts := make([]string, len(batch))
+		for i, doc := range batch {
+			texts[i] = doc.Content
+		}
+		embeddings, err := m.config.Embedder.EmbedBatch(ctx, texts)
+		if err != nil {
+			return err
+		}
+		if len(embeddings) != len(batch) {
+			return errors.New("rag: embedder returned a wrong number of embeddings")
+		}
+
+		migrated := make([]embedding.Document, 0, len(batch))
+		m.mu.Lock()
+		if m.clears != clears {
+			m.mu.Unlock()
+			return nil // Store was cleared since the listing
+		}
+		for i, doc := range batch {
+			if m.written[doc.ID] {
+				continue
+			}
+			doc.Embedding = embeddings[i]
+			doc.Metadata = maps.Clone(doc.Metadata)
+			migrated = append(migrated, doc)
+		}
+		// Held across the write so Store can't interleave an older version
+		err = m.config.To.AddBatch(ctx, migrated)
+		if err == nil {
+			for _, doc := range migrated {
+				m.written[doc.ID] = true
+			}
+		}
+		m.mu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		if m.config.OnProgress != nil {
+			m.config.OnProgress(start+len(batch), total)
+		}
+	}
+	return nil
+}
+
+// Store returns a store for the migration window: writes go to both stores
+// (re-embedded for To), reads come from From. Once Run has finished,
+// switch to a RAG with the new embedder and To.
+func (m *Migration) Store() VectorStore {
+	return &dualStore{m: m}
+}
+
+// dualStore writes to both stores of a migration
+type dualStore struct {
+	m *Migration
+}
+
+// Add adds doc to both stores
+func (d *dualStore) Add(ctx context.Context, doc embedding.Document) error {
+	return d.AddBatch(ctx, []embedding.Document{doc})
+}
+
+// AddBatch adds docs to both stores
+func (d *dualStore) AddBatch(ctx context.Context, docs []embedding.Document) error {
+	config := d.m.config
+	if err := config.From.AddBatch(ctx, docs); err != nil {
+		return err
+	}
+
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = doc.Content
+	}
+	embeddings, err := config.Embedder.EmbedBatch(ctx, texts)
+	if err != nil {
+		return err
+	}
+	if len(embeddings) != len(docs) {
+		return errors.New("rag: embedder returned a wrong number of embeddings")
+	}
+	migrated := make([]embedding.Document, len(docs))
+	for i, doc := range docs {
+		doc.Embedding = embeddings[i]
+		migrated[i] = doc
+	}
+
+	d.m.mu.Lock()
+	defer d.m.mu.Unlock()
+	for _, doc := range docs {
+		d.m.written[doc.ID] = true
+	}
+	return config.To.AddBatch(ctx, migrated)
+}
+
+// Search searches the old store
+func (d *dualStore) Search(ctx context.Context, queryEmbedding []float64, topK int) ([]SearchResult, error) {
+	return d.m.config.From.Search(ctx, queryEmbedding, topK)
+}
+
+// Delete removes a document from both stores
+func (d *dualStore) Delete(ctx context.Context, id string) error {
+	if err := d.m.config.From.Delete(ctx, id); err != nil {
+		return err
+	}
+	d.m.mu.Lock()
+	defer d.m.mu.Unlock()
+	d.m.written[id] = true
+	return d.m.config.To.Delete(ctx, id)
+}
+
+// Clear empties both stores
+func (d *dualStore) Clear(ctx context.Context) error {
+	if err := d.m.config.From.Clear(ctx); err != nil {
+		return err
+	}
+	d.m.mu.Lock()
+	defer d.m.mu.Unlock()
+	d.m.clears++
+	clear(d.m.written)
+	return d.m.config.To.Clear(ctx)
+}
+
+// Count returns the number of documents in the old store
+func (d *dualStore) Count() int {
+	return d.m.config.From.Count()
+}
@@ -2,8 +2,12 @@ package rag
 
 import (
 	"context"
+	"maps"
+	"slices"
+	"time"
 
 	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/contextbuilder"
 	"github.com/medatechnology/simpleai/embedding"
 )
 
@@ -54,8 +58,38 @@ type Config struct {
 
 	// IncludeMetadata includes document metadata in context
 	IncludeMetadata bool
+
+	// TokenCounter counts tokens for MaxTokens (default: ~4 characters per token)
+	TokenCounter func(string) int
+
+	// DuplicateThreshold is the similarity to a stored document at which
+	// added content counts as a near-duplicate (0 = no check). Near-duplicates
+	// are handled by DuplicatePolicy.
+	DuplicateThreshold float64
+
+	// DuplicatePolicy decides what happens to near-duplicates (default
+	// DuplicateSkip)
+	DuplicatePolicy DuplicatePolicy
+
+	// OnDuplicate is called with the ID of each near-duplicate, the ID of
+	// the stored document it matched and their similarity
+	OnDuplicate func(id, existingID string, similarity float64)
 }
 
+// DuplicatePolicy decides what happens to a near-duplicate on ingest
+type DuplicatePolicy int
+
+const (
+	// DuplicateSkip keeps the stored document and drops the new one
+	DuplicateSkip DuplicatePolicy = iota
+	// DuplicateMerge keeps one document under the stored ID with the
+	// longer content, the metadata of both (the stored values win) and the
+	// new ID listed in the "duplicate_ids" metadata
+	DuplicateMerge
+	// DuplicateReplace deletes the stored document and adds the new one
+	DuplicateReplace
+)
+
 // DefaultConfig returns sensible defaults
 func DefaultConfig() Config {
 	return Config{
@@ -85,20 +119,110 @@ func (r *RAG) AddMessage(ctx context.Context, msg simpleai.Message, id string) e
 		return err
 	}
 
+	metadata := map[string]any{
+		"role": string(msg.Role),
+	}
+	if msg.ID != "" {
+		metadata["message_id"] = msg.ID
+	}
+	if !msg.Timestamp.IsZero() {
+		metadata["timestamp"] = msg.Timestamp.Format(time.RFC3339Nano)
+	}
+	if len(msg.Metadata) > 0 {
+		metadata["message_metadata"] = msg.Metadata
+	}
+
 	doc := embedding.Document{
 		ID:        id,
 		Content:   msg.Content,
 		Embedding: emb,
-		Metadata: map[string]any{
-			"role": string(msg.Role),
-		},
+		Metadata:  metadata,
+	}
+
+	return r.AddDocument(ctx, doc)
+}
+
+// AddDocument adds a document to the store, embedding its content if it
+// has no embedding yet. Near-duplicates are handled as configured by
+// DuplicateThreshold.
+func (r *RAG) AddDocument(ctx context.Context, doc embedding.Document) error {
+	if doc.Embedding == nil {
+		emb, err := r.embedder.Embed(ctx, doc.Content)
+		if err != nil {
+			return err
+		}
+		doc.Embedding = emb
+	}
+	if r.config.DuplicateThreshold <= 0 {
+		return r.store.Add(ctx, doc)
+	}
+
+	results, err := r.store.Search(ctx, doc.Embedding, 1)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 || results[0].Similarity < r.config.DuplicateThreshold || results[0].Document.ID == doc.ID {
+		return r.store.Add(ctx, doc)
 	}
 
-	return r.store.Add(ctx, doc)
+	existing := results[0].Document
+	if r.config.OnDuplicate != nil {
+		r.config.OnDuplicate(doc.ID, existing.ID, results[0].Similarity)
+	}
+	switch r.config.DuplicatePolicy {
+	case DuplicateMerge:
+		return r.store.Add(ctx, mergeDocuments(existing, doc))
+	case DuplicateReplace:
+		if err := r.store.Delete(ctx, existing.ID); err != nil {
+			return err
+		}
+		return r.store.Add(ctx, doc)
+	}
+	return nil
+}
+
+// mergeDocuments folds a near-duplicate into the stored document
+func mergeDocuments(existing, doc embedding.Document) embedding.Document {
+	merged := existing
+	if len(doc.Content) > len(existing.Content) {
+		merged.Content = doc.Content
+		merged.Embedding = doc.Embedding
+	}
+
+	merged.Metadata = make(map[string]any, len(existing.Metadata)+len(doc.Metadata)+1)
+	maps.Copy(merged.Metadata, doc.Metadata)
+	maps.Copy(merged.Metadata, existing.Metadata)
+	ids, _ := existing.Metadata["duplicate_ids"].([]string)
+	merged.Metadata["duplicate_ids"] = append(slices.Clip(ids), doc.ID)
+
+	// Live as long as the longer-lived of the two
+	if existing.ExpiresAt.IsZero() || doc.ExpiresAt.IsZero() {
+		merged.ExpiresAt = time.Time{}
+	} else if doc.ExpiresAt.After(existing.ExpiresAt) {
+		merged.ExpiresAt = doc.ExpiresAt
+	}
+	return merged
 }
 
 // Retrieve finds relevant messages for a query
 func (r *RAG) Retrieve(ctx context.Context, query string) ([]simpleai.Message, error) {
+	results, err := r.Search(ctx, query, r.config.TopK)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert to messages
+	var messages []simpleai.Message
+	for _, result := range results {
+		messages = append(messages, documentMessage(result.Document))
+	}
+
+	return messages, nil
+}
+
+// Search returns up to k documents at least MinSimilarity similar to query,
+// most similar first
+func (r *RAG) Search(ctx context.Context, query string, k int) ([]SearchResult, error) {
 	// Generate query embedding
 	queryEmb, err := r.embedder.Embed(ctx, query)
 	if err != nil {
@@ -106,33 +230,39 @@ func (r *RAG) Retrieve(ctx context.Context, query string) ([]simpleai.Message, e
 	}
 
 	// Search for similar documents
-	results, err := r.store.Search(ctx, queryEmb, r.config.TopK)
+	results, err := r.store.Search(ctx, queryEmb, k)
 	if err != nil {
 		return nil, err
 	}
 
-	// Convert to messages
-	var messages []simpleai.Message
+	var relevant []SearchResult
 	for _, result := range results {
-		if result.Similarity < r.config.MinSimilarity {
-			continue
-		}
-
-		role := simpleai.RoleUser
-		if roleStr, ok := result.Document.Metadata["role"].(string); ok {
-			role = simpleai.Role(roleStr)
+		if result.Similarity >= r.config.MinSimilarity {
+			relevant = append(relevant, result)
 		}
-
-		messages = append(messages, simpleai.Message{
-			Role:    role,
-			Content: result.Document.Content,
-		})
 	}
+	return relevant, nil
+}
 
-	return messages, nil
+// documentMessage restores a message stored by AddMessage
+func documentMessage(doc embedding.Document) simpleai.Message {
+	msg := simpleai.Message{
+		Role:    simpleai.RoleUser,
+		Content: doc.Content,
+	}
+	if role, ok := doc.Metadata["role"].(string); ok {
+		msg.Role = simpleai.Role(role)
+	}
+	msg.ID, _ = doc.Metadata["message_id"].(string)
+	if ts, ok := doc.Metadata["timestamp"].(string); ok {
+		msg.Timestamp, _ = time.Parse(time.RFC3339Nano, ts)
+	}
+	msg.Metadata, _ = doc.Metadata["message_metadata"].(map[string]any)
+	return msg
 }
 
-// BuildContext builds context from retrieved messages
+// BuildContext builds context from retrieved messages, keeping the most
+// relevant ones that fit in MaxTokens
 func (r *RAG) BuildContext(ctx context.Context, query string) (string, error) {
 	messages, err := r.Retrieve(ctx, query)
 	if err != nil {
@@ -143,13 +273,21 @@ func (r *RAG) BuildContext(ctx context.Context, query string) (string, error) {
 		return "", nil
 	}
 
-	var context string
-	context = "[Relevant context from previous conversations]\n"
-	for _, msg := range messages {
-		context += msg.Content + "\n---\n"
+	items := make([]string, len(messages))
+	for i, msg := range messages {
+		items[i] = msg.Content + "\n---"
 	}
 
-	return context, nil
+	// Results are ordered by similarity, so the least relevant go first
+	built := contextbuilder.New(r.config.MaxTokens, r.config.TokenCounter).
+		Add(contextbuilder.Section{Name: "header", Items: []string{"[Relevant context from previous conversations]"}, Required: true}).
+		AddItems("documents", items, 0, contextbuilder.DropNewest).
+		Build()
+
+	if len(built.Section("documents").Items) == 0 {
+		return "", nil
+	}
+	return built.Text() + "\n", nil
 }
 
 // Store returns the underlying vector store
@@ -161,3 +299,8 @@ func (r *RAG) Store() VectorStore {
 func (r *RAG) Embedder() embedding.Embedder {
 	return r.embedder
 }
+
+// Config returns the RAG configuration
+func (r *RAG) Config() Config {
+	return r.config
+}
@@ -2,22 +2,50 @@ package rag
 
 import (
 	"context"
+	"slices"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/medatechnology/simpleai/embedding"
 )
 
+// MemoryStoreConfig holds configuration for a MemoryStore
+type MemoryStoreConfig struct {
+	// TTL is the lifetime of documents added without ExpiresAt
+	// (0 = forever)
+	TTL time.Duration
+
+	// MaxDocuments evicts the least recently retrieved documents once the
+	// store holds more (0 = unlimited)
+	MaxDocuments int
+
+	// OnEvict is called with each document dropped for expiry or the
+	// document limit
+	OnEvict func(doc embedding.Document)
+}
+
 // MemoryStore is an in-memory vector store implementation
 type MemoryStore struct {
 	documents []embedding.Document
+	lastUsed  map[string]time.Time // Last added or retrieved, by document ID
+	config    MemoryStoreConfig
 	mu        sync.RWMutex
 }
 
 // NewMemoryStore creates a new in-memory vector store
 func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithConfig(MemoryStoreConfig{})
+}
+
+// NewMemoryStoreWithConfig creates an in-memory vector store with document
+// expiry and a document limit, so memories derived from conversations
+// don't grow forever
+func NewMemoryStoreWithConfig(config MemoryStoreConfig) *MemoryStore {
 	return &MemoryStore{
 		documents: []embedding.Document{},
+		lastUsed:  make(map[string]time.Time),
+		config:    config,
 	}
 }
 
@@ -26,6 +54,12 @@ func (m *MemoryStore) Add(ctx context.Context, doc embedding.Document) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	now := time.Now()
+	if doc.ExpiresAt.IsZero() && m.config.TTL > 0 {
+		doc.ExpiresAt = now.Add(m.config.TTL)
+	}
+	m.lastUsed[doc.ID] = now
+
 	// Check for duplicate ID and update if exists
 	for i, d := range m.documents {
 		if d.ID == doc.ID {
@@ -35,6 +69,7 @@ func (m *MemoryStore) Add(ctx context.Context, doc embedding.Document) error {
 	}
 
 	m.documents = append(m.documents, doc)
+	m.evict(now)
 	return nil
 }
 
@@ -50,9 +85,11 @@ func (m *MemoryStore) AddBatch(ctx context.Context, docs []embedding.Document) e
 
 // Search finds the top-k most similar documents
 func (m *MemoryStore) Search(ctx context.Context, queryEmbedding []float64, topK int) ([]SearchResult, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
+	now := time.Now()
+	m.expire(now)
 	if len(m.documents) == 0 {
 		return nil, nil
 	}
@@ -77,6 +114,9 @@ func (m *MemoryStore) Search(ctx context.Context, queryEmbedding []float64, topK
 		topK = len(results)
 	}
 
+	for _, result := range results[:topK] {
+		m.lastUsed[result.Document.ID] = now
+	}
 	return results[:topK], nil
 }
 
@@ -88,6 +128,7 @@ func (m *MemoryStore) Delete(ctx context.Context, id string) error {
 	for i, doc := range m.documents {
 		if doc.ID == id {
 			m.documents = append(m.documents[:i], m.documents[i+1:]...)
+			delete(m.lastUsed, id)
 			return nil
 		}
 	}
@@ -99,12 +140,72 @@ func (m *MemoryStore) Clear(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.documents = []embedding.Document{}
+	clear(m.lastUsed)
 	return nil
 }
 
+// Documents returns a copy of all documents, implementing DocumentLister
+func (m *MemoryStore) Documents(ctx context.Context) ([]embedding.Document, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire(time.Now())
+	return slices.Clone(m.documents), nil
+}
+
+// Prune drops expired documents and returns how many it dropped. Expired
+// documents are never returned, so pruning only frees memory; call it
+// periodically for stores that are written more than searched.
+func (m *MemoryStore) Prune(ctx context.Context) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.expire(time.Now())
+}
+
 // Count returns the number of documents
 func (m *MemoryStore) Count() int {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire(time.Now())
 	return len(m.documents)
 }
+
+// expire drops the documents past their expiry
+func (m *MemoryStore) expire(now time.Time) int {
+	dropped := 0
+	m.documents = slices.DeleteFunc(m.documents, func(doc embedding.Document) bool {
+		if doc.ExpiresAt.IsZero() || now.Before(doc.ExpiresAt) {
+			return false
+		}
+		m.dropped(doc)
+		dropped++
+		return true
+	})
+	return dropped
+}
+
+// evict drops expired documents, then the least recently used ones until
+// the store is within MaxDocuments
+func (m *MemoryStore) evict(now time.Time) {
+	if m.config.MaxDocuments <= 0 || len(m.documents) <= m.config.MaxDocuments {
+		return
+	}
+	m.expire(now)
+	for len(m.documents) > m.config.MaxDocuments {
+		oldest := 0
+		for i, doc := range m.documents {
+			if m.lastUsed[doc.ID].Before(m.lastUsed[m.documents[oldest].ID]) {
+				oldest = i
+			}
+		}
+		m.dropped(m.documents[oldest])
+		m.documents = slices.Delete(m.documents, oldest, oldest+1)
+	}
+}
+
+// dropped forgets an evicted document and reports it
+func (m *MemoryStore) dropped(doc embedding.Document) {
+	delete(m.lastUsed, doc.ID)
+	if m.config.OnEvict != nil {
+		m.config.OnEvict(doc)
+	}
+}
@@ -0,0 +1,386 @@
+package simpleai
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/simpleai/contextbuilder"
+)
+
+// Replay errors
+var (
+	ErrNoReplayStore  = errors.New("simpleai: replay is not enabled (see WithReplay)")
+	ErrReplayNotFound = errors.New("simpleai: replay record not found")
+	ErrNoRecording    = errors.New("simpleai: no recorded response for request")
+)
+
+// ReplayRecord is a request and its outcome as captured by WithReplay
+type ReplayRecord struct {
+	ID       string          `json:"id"`
+	ReplayOf string          `json:"replay_of,omitempty"` // ID of the record this call replayed
+	Time     time.Time       `json:"time"`
+	Duration time.Duration   `json:"duration"`
+	Provider string          `json:"provider"`
+	Stream   bool            `json:"stream,omitempty"`
+	Metadata RequestMetadata `json:"metadata,omitzero"` // Without headers, which may hold credentials
+
+	// Request as it entered the middleware chain, with the client defaults
+	// and request options applied
+	Request Request `json:"request"`
+
+	// Response, assembled from the events for streams; nil when the call
+	// failed before producing one
+	Response *Response `json:"response,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// ReplayStore persists replay records
+type ReplayStore interface {
+	Save(ctx context.Context, record ReplayRecord) error
+	// Get returns ErrReplayNotFound for unknown IDs
+	Get(ctx context.Context, id string) (ReplayRecord, error)
+	// List returns up to limit records, newest first (limit <= 0 = all)
+	List(ctx context.Context, limit int) ([]ReplayRecord, error)
+}
+
+type replayOfKey struct{}
+
+// Replay re-runs a recorded request through the client, with the same
+// messages, parameters and request metadata. Options apply on top, e.g.
+// WithModel to compare another model. The new call is recorded too, with
+// ReplayOf set to id.
+func (c *Client) Replay(ctx context.Context, id string, opts ...RequestOption) (*Response, error) {
+	_, _, config := c.snapshot()
+	if config.Replay == nil {
+		return nil, ErrNoReplayStore
+	}
+	record, err := config.Replay.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	req := record.Request
+	req.Messages = slices.Clone(req.Messages)
+	req.Stream = false
+	if _, ok := RequestMetadataFromContext(ctx); !ok {
+		ctx = WithRequestMetadata(ctx, record.Metadata)
+	}
+	ctx = context.WithValue(ctx, replayOfKey{}, id)
+	return c.Complete(ctx, &req, opts...)
+}
+
+// startReplay captures req before the middleware chain can change it
+func startReplay(ctx context.Context, req *Request) ReplayRecord {
+	record := ReplayRecord{
+		ID:      newReplayID(),
+		Time:    time.Now(),
+		Stream:  req.Stream,
+		Request: *req,
+	}
+	record.Request.Messages = slices.Clone(req.Messages)
+	record.ReplayOf, _ = ctx.Value(replayOfKey{}).(string)
+	record.Metadata, _ = RequestMetadataFromContext(ctx)
+	record.Metadata.Headers = nil
+	return record
+}
+
+// finishReplay saves the record; failures never affect the request
+func finishReplay(ctx context.Context, store ReplayStore, record ReplayRecord, resp *Response, err error) {
+	record.Duration = time.Since(record.Time)
+	record.Provider = ProviderName(ctx)
+	if resp != nil {
+		copied := *resp
+		record.Response = &copied
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	store.Save(context.WithoutCancel(ctx), record)
+}
+
+// replayStream records the stream when it ends and sets ReplayID on its
+// final event
+func replayStream(ctx context.Context, store ReplayStore, record ReplayRecord, in <-chan StreamEvent) <-chan StreamEvent {
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+		resp := &Response{Model: record.Request.Model}
+		var streamErr error
+		saved := false
+		for event := range in {
+			resp.Content += event.Content
+			if event.Done {
+				resp.FinishReason = event.FinishReason
+				resp.Citations = event.Citations
+				resp.ToolCalls = event.ToolCalls
+				resp.StopSequence = event.StopSequence
+				if event.Usage != nil {
+					resp.Usage = *event.Usage
+				}
+			}
+			if event.Error != nil {
+				streamErr = event.Error
+			}
+			if event.terminal() && !saved {
+				event.ReplayID = record.ID
+				finishReplay(ctx, store, record, resp, streamErr)
+				saved = true
+			}
+			select {
+			case out <- event:
+			case <-ctx.Done():
+			}
+		}
+		if !saved {
+			finishReplay(ctx, store, record, resp, streamErr)
+		}
+	}()
+	return out
+}
+
+func newReplayID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "rpl_" + hex.EncodeToString(b)
+}
+
+// MemoryReplayStore keeps replay records in memory
+type MemoryReplayStore struct {
+	mu      sync.RWMutex
+	records []ReplayRecord
+	max     int
+}
+
+// NewMemoryReplayStore creates an in-memory replay store that keeps the
+// latest max records (0 = unlimited)
+func NewMemoryReplayStore(max int) *MemoryReplayStore {
+	return &MemoryReplayStore{max: max}
+}
+
+// Save appends a record, dropping the oldest beyond the limit
+func (m *MemoryReplayStore) Save(ctx context.Context, record ReplayRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, record)
+	if m.max > 0 && len(m.records) > m.max {
+		m.records = slices.Delete(m.records, 0, len(m.records)-m.max)
+	}
+	return nil
+}
+
+// Get returns the record with the given ID
+func (m *MemoryReplayStore) Get(ctx context.Context, id string) (ReplayRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, record := range m.records {
+		if record.ID == id {
+			return record, nil
+		}
+	}
+	return ReplayRecord{}, ErrReplayNotFound
+}
+
+// List returns the newest records first
+func (m *MemoryReplayStore) List(ctx context.Context, limit int) ([]ReplayRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	records := slices.Clone(m.records)
+	slices.Reverse(records)
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+// FileReplayStore keeps one JSON file per record in a directory
+type FileReplayStore struct {
+	dir string
+}
+
+// NewFileReplayStore creates a file-backed replay store in dir, creating
+// it if needed
+func NewFileReplayStore(dir string) (*FileReplayStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileReplayStore{dir: dir}, nil
+}
+
+// path maps an ID to its file, refusing IDs that would leave the directory
+func (f *FileReplayStore) path(id string) (string, bool) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return "", false
+	}
+	return filepath.Join(f.dir, id+".json"), true
+}
+
+// Save writes the record file
+func (f *FileReplayStore) Save(ctx context.Context, record ReplayRecord) error {
+	path, ok := f.path(record.ID)
+	if !ok {
+		return fmt.Errorf("simpleai: invalid replay ID %q", record.ID)
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Get reads the record file
+func (f *FileReplayStore) Get(ctx context.Context, id string) (ReplayRecord, error) {
+	path, ok := f.path(id)
+	if !ok {
+		return ReplayRecord{}, ErrReplayNotFound
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ReplayRecord{}, ErrReplayNotFound
+	}
+	if err != nil {
+		return ReplayRecord{}, err
+	}
+	var record ReplayRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return ReplayRecord{}, err
+	}
+	return record, nil
+}
+
+// List reads every record file and returns the newest first
+func (f *FileReplayStore) List(ctx context.Context, limit int) ([]ReplayRecord, error) {
+	matches, err := filepath.Glob(filepath.Join(f.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	records := make([]ReplayRecord, 0, len(matches))
+	for _, match := range matches {
+		record, err := f.Get(ctx, strings.TrimSuffix(filepath.Base(match), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	slices.SortFunc(records, func(a, b ReplayRecord) int { return b.Time.Compare(a.Time) })
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+// ReplayProvider is a provider that answers from replay records instead of
+// a model: each request gets the response recorded for the same request,
+// or ErrNoRecording. Recorded failures are returned as errors. Use it to
+// run tests offline and deterministically on calls captured with
+// WithReplay. Requests match on everything sent to the model except the
+// stream flag and output token limit, which depend on the client and
+// provider.
+type ReplayProvider struct {
+	store ReplayStore
+
+	mu    sync.Mutex
+	index map[string]ReplayRecord // By replayKey, newest record
+}
+
+// NewReplayProvider creates a provider replaying the records in store.
+// Records saved to store later are found too.
+func NewReplayProvider(store ReplayStore) *ReplayProvider {
+	return &ReplayProvider{store: store}
+}
+
+// Complete returns the recorded response to req
+func (p *ReplayProvider) Complete(ctx context.Context, req *Request) (*Response, error) {
+	record, err := p.lookup(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if record.Error != "" && record.Response == nil {
+		return nil, errors.New(record.Error)
+	}
+	resp := *record.Response
+	resp.Transfer = nil
+	return &resp, nil
+}
+
+// Stream returns the recorded response as a single event
+func (p *ReplayProvider) Stream(ctx context.Context, req *Request) (<-chan StreamEvent, error) {
+	resp, err := p.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan StreamEvent, 1)
+	usage := resp.Usage
+	out <- StreamEvent{
+		Content:      resp.Content,
+		Done:         true,
+		FinishReason: resp.FinishReason,
+		Citations:    resp.Citations,
+		ToolCalls:    resp.ToolCalls,
+		StopSequence: resp.StopSequence,
+		Usage:        &usage,
+	}
+	close(out)
+	return out, nil
+}
+
+// CountTokens estimates the token count of text
+func (p *ReplayProvider) CountTokens(text string) int {
+	return contextbuilder.EstimateTokens(text)
+}
+
+// Name returns "replay"
+func (p *ReplayProvider) Name() string {
+	return "replay"
+}
+
+// lookup finds the record for req, reloading the store on a miss
+func (p *ReplayProvider) lookup(ctx context.Context, req *Request) (ReplayRecord, error) {
+	key := replayKey(req)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if record, ok := p.index[key]; ok {
+		return record, nil
+	}
+	records, err := p.store.List(ctx, 0)
+	if err != nil {
+		return ReplayRecord{}, err
+	}
+	p.index = make(map[string]ReplayRecord, len(records))
+	for _, record := range records {
+		if record.Response == nil && record.Error == "" {
+			continue
+		}
+		// Newest first, so the latest recording of a request wins
+		if k := replayKey(&record.Request); p.index[k].ID == "" {
+			p.index[k] = record
+		}
+	}
+	if record, ok := p.index[key]; ok {
+		return record, nil
+	}
+	return ReplayRecord{}, fmt.Errorf("%w: %.80q", ErrNoRecording, lastUserContent(req.Messages))
+}
+
+// replayKey identifies a request by what is sent to the model
+func replayKey(req *Request) string {
+	r := *req
+	r.Stream = false
+	r.MaxTokens = 0
+	r.Messages = make([]Message, len(req.Messages))
+	for i, msg := range req.Messages {
+		msg.ID, msg.Timestamp, msg.Metadata = "", time.Time{}, nil
+		r.Messages[i] = msg
+	}
+	return hashRequest(&r)
+}
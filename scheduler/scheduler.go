@@ -0,0 +1,332 @@
+// Package scheduler runs recurring LLM jobs (daily summaries, digests) on
+// cron-like schedules, with overlap protection, result sinks and failure
+// alerts.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/goutil/simplelog"
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/template"
+)
+
+// Common errors
+var (
+	ErrDuplicateJob = errors.New("scheduler: job already registered")
+	ErrUnknownJob   = errors.New("scheduler: unknown job")
+	ErrJobRunning   = errors.New("scheduler: job is already running")
+)
+
+// RunFunc produces a job's output
+type RunFunc func(ctx context.Context, client *simpleai.Client) (string, error)
+
+// Job is a registered recurring task
+type Job struct {
+	Name     string
+	Schedule Schedule
+
+	// Prompt is a template rendered with Data (and .Now) and sent to the
+	// client. Ignored when Run is set.
+	Prompt string
+	Data   map[string]any
+
+	// Run replaces Prompt for jobs that need more than one call
+	Run RunFunc
+
+	// Options are applied to the Prompt request
+	Options []simpleai.RequestOption
+
+	// Timeout bounds a single run (default: Config.Timeout)
+	Timeout time.Duration
+
+	// Sinks receive every result, successful or not
+	Sinks []Sink
+}
+
+// Result is the outcome of one job run
+type Result struct {
+	Job      string
+	Start    time.Time
+	Duration time.Duration
+	Output   string
+	Err      error
+}
+
+// Sink receives job results, e.g. to email a digest or store a summary
+type Sink interface {
+	Deliver(ctx context.Context, result Result) error
+}
+
+// SinkFunc adapts a function to a Sink
+type SinkFunc func(ctx context.Context, result Result) error
+
+// Deliver calls f
+func (f SinkFunc) Deliver(ctx context.Context, result Result) error {
+	return f(ctx, result)
+}
+
+// Config holds scheduler configuration
+type Config struct {
+	// Timeout bounds each run (default 5 minutes)
+	Timeout time.Duration
+
+	// Sinks receive the results of every job
+	Sinks []Sink
+
+	// OnFailure is called when a run or one of its sinks fails
+	OnFailure func(result Result)
+
+	// OnSkip is called when a run is skipped because the previous run of
+	// the same job has not finished
+	OnSkip func(job string, at time.Time)
+}
+
+// DefaultConfig returns sensible defaults
+func DefaultConfig() Config {
+	return Config{
+		Timeout: 5 * time.Minute,
+		OnFailure: func(result Result) {
+			simplelog.LogErr(result.Err, "scheduled job "+result.Job+" failed")
+		},
+	}
+}
+
+type entry struct {
+	job     Job
+	next    time.Time
+	running bool
+}
+
+// Scheduler runs registered jobs on their schedules
+type Scheduler struct {
+	client *simpleai.Client
+	config Config
+
+	mu      sync.Mutex
+	jobs    map[string]*entry
+	wake    chan struct{}
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+}
+
+// New creates a scheduler
+func New(client *simpleai.Client, config Config) *Scheduler {
+	if config.Timeout == 0 {
+		config.Timeout = DefaultConfig().Timeout
+	}
+	if config.OnFailure == nil {
+		config.OnFailure = DefaultConfig().OnFailure
+	}
+	return &Scheduler{
+		client: client,
+		config: config,
+		jobs:   make(map[string]*entry),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Add registers a job
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Schedule == nil {
+		return errors.New("scheduler: job needs a name and a schedule")
+	}
+	if job.Prompt == "" && job.Run == nil {
+		return fmt.Errorf("scheduler: job %s needs a Prompt or Run", job.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[job.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateJob, job.Name)
+	}
+	s.jobs[job.Name] = &entry{job: job, next: job.Schedule.Next(time.Now())}
+	s.notify()
+	return nil
+}
+
+// Remove unregisters a job; a run in progress is not interrupted
+func (s *Scheduler) Remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, name)
+	s.notify()
+}
+
+// Next returns when a job runs next
+func (s *Scheduler) Next(name string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.jobs[name]
+	if !ok {
+		return time.Time{}, false
+	}
+	return e.next, true
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the scheduler in the background until ctx is cancelled or
+// Stop is called
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	go s.loop(ctx)
+}
+
+// Stop stops scheduling and waits for running jobs to finish
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	s.running.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context) {
+	for {
+		now := time.Now()
+		wait := time.Hour
+
+		s.mu.Lock()
+		for _, e := range s.jobs {
+			if !e.next.After(now) {
+				s.dispatch(ctx, e, now)
+			}
+			if d := e.next.Sub(now); d < wait {
+				wait = d
+			}
+		}
+		s.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// dispatch starts a due job unless its previous run is still going.
+// The caller holds s.mu.
+func (s *Scheduler) dispatch(ctx context.Context, e *entry, now time.Time) {
+	e.next = e.job.Schedule.Next(now)
+	if e.running {
+		if s.config.OnSkip != nil {
+			go s.config.OnSkip(e.job.Name, now)
+		}
+		return
+	}
+
+	e.running = true
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		s.execute(ctx, e.job)
+		s.mu.Lock()
+		e.running = false
+		s.mu.Unlock()
+	}()
+}
+
+// RunNow runs a job immediately, outside its schedule, and returns its
+// result. It fails with ErrJobRunning if the job is already running.
+func (s *Scheduler) RunNow(ctx context.Context, name string) (Result, error) {
+	s.mu.Lock()
+	e, ok := s.jobs[name]
+	if !ok {
+		s.mu.Unlock()
+		return Result{}, fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+	if e.running {
+		s.mu.Unlock()
+		return Result{}, fmt.Errorf("%w: %s", ErrJobRunning, name)
+	}
+	e.running = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		e.running = false
+		s.mu.Unlock()
+	}()
+
+	result := s.execute(ctx, e.job)
+	return result, result.Err
+}
+
+// execute runs a job and delivers its result to the sinks
+func (s *Scheduler) execute(ctx context.Context, job Job) Result {
+	timeout := job.Timeout
+	if timeout == 0 {
+		timeout = s.config.Timeout
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := Result{Job: job.Name, Start: time.Now()}
+	result.Output, result.Err = s.run(runCtx, job, result.Start)
+	result.Duration = time.Since(result.Start)
+
+	if result.Err != nil {
+		s.config.OnFailure(result)
+	}
+
+	// Deliver even if the run context timed out
+	sinkCtx, sinkCancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer sinkCancel()
+	for _, sink := range append(append([]Sink{}, s.config.Sinks...), job.Sinks...) {
+		if err := sink.Deliver(sinkCtx, result); err != nil {
+			s.config.OnFailure(Result{
+				Job:      job.Name,
+				Start:    result.Start,
+				Duration: result.Duration,
+				Output:   result.Output,
+				Err:      fmt.Errorf("sink: %w", err),
+			})
+		}
+	}
+
+	return result
+}
+
+func (s *Scheduler) run(ctx context.Context, job Job, now time.Time) (string, error) {
+	if job.Run != nil {
+		return job.Run(ctx, s.client)
+	}
+
+	data := map[string]any{"Now": now}
+	for k, v := range job.Data {
+		data[k] = v
+	}
+	prompt, err := template.Prompt(job.Prompt, data)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.client.Complete(ctx, &simpleai.Request{
+		Messages: []simpleai.Message{{Role: simpleai.RoleUser, Content: prompt}},
+	}, job.Options...)
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
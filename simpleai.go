@@ -3,20 +3,60 @@ package simpleai
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sync"
 )
 
-// Client is the main entry point for the simpleai library
+// Client is the main entry point for the simpleai library. It is safe for
+// concurrent use; derive variants with Clone.
 type Client struct {
+	mu         sync.RWMutex
 	provider   Provider
 	middleware []Middleware
 	config     *ClientConfig
+
+	idempotencyMu sync.Mutex
+	idempotent    map[string]*idempotentCall // In-flight calls by idempotency key
 }
 
 // ClientConfig holds client configuration
 type ClientConfig struct {
-	DefaultModel       string
+	DefaultModel string
+	// DefaultMaxTokens is used when a request sets no MaxTokens. When 0 it
+	// is derived from the model catalog: the context window left after the
+	// prompt, capped at the model's output limit (4096 for unknown models).
 	DefaultMaxTokens   int
 	DefaultTemperature float64
+
+	// StreamBuffer is the number of stream events buffered between the
+	// provider and the consumer (default 0, unbuffered)
+	StreamBuffer int
+	// Backpressure decides what happens when the consumer falls behind
+	// (default BackpressureBlock)
+	Backpressure BackpressurePolicy
+
+	// DryRun makes every call return the provider request as a *DryRun
+	// error instead of sending it
+	DryRun bool
+
+	// StreamRetry reconnects streams that die mid-response (nil = off)
+	StreamRetry *StreamRetryConfig
+
+	// StreamTimestamps sets StreamEvent.Time on every event as it arrives
+	// from the provider
+	StreamTimestamps bool
+
+	// SimulateStream fakes streams from Complete for providers or models
+	// that cannot stream (nil = off)
+	SimulateStream *SimulatedStreamConfig
+
+	// Replay records every request and its outcome for Client.Replay
+	// (nil = off)
+	Replay ReplayStore
+
+	// Idempotency returns the original response when a completion's
+	// idempotency key is retried (nil = off)
+	Idempotency *IdempotencyConfig
 }
 
 // NewClient creates a new simpleai client with the given provider
@@ -25,7 +65,6 @@ func NewClient(provider Provider, opts ...Option) *Client {
 		provider:   provider,
 		middleware: []Middleware{},
 		config: &ClientConfig{
-			DefaultMaxTokens:   4096,
 			DefaultTemperature: 0.7,
 		},
 	}
@@ -37,49 +76,174 @@ func NewClient(provider Provider, opts ...Option) *Client {
 	return c
 }
 
-// Complete sends a completion request through the middleware chain
-func (c *Client) Complete(ctx context.Context, req *Request) (*Response, error) {
-	if c.provider == nil {
+// Complete sends a completion request through the middleware chain.
+// Request options apply to a copy of req.
+func (c *Client) Complete(ctx context.Context, req *Request, opts ...RequestOption) (*Response, error) {
+	provider, middleware, config := c.snapshot()
+	if provider == nil {
 		return nil, fmt.Errorf("no provider configured")
 	}
+	ctx, req = applyRequestOptions(ctx, req, opts)
+	ctx = withProvider(ctx, provider.Name())
+	ctx = withTransfer(ctx)
+	if config.DryRun {
+		ctx = ContextWithDryRun(ctx)
+	}
 
 	// Apply defaults if not set
 	if req.MaxTokens == 0 {
-		req.MaxTokens = c.config.DefaultMaxTokens
+		req.MaxTokens = defaultMaxTokens(req, provider, config)
 	}
 	if req.Temperature == 0 {
-		req.Temperature = c.config.DefaultTemperature
+		req.Temperature = config.DefaultTemperature
 	}
 
 	// Build middleware chain
 	handler := func(ctx context.Context, req *Request) (*Response, error) {
-		return c.provider.Complete(ctx, req)
+		return provider.Complete(ctx, req)
 	}
 
 	// Apply middleware in reverse order
-	for i := len(c.middleware) - 1; i >= 0; i-- {
-		handler = c.middleware[i].Wrap(handler)
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i].Wrap(handler)
 	}
 
-	return handler(ctx, req)
+	run := func(ctx context.Context) (*Response, error) {
+		var record ReplayRecord
+		if config.Replay != nil {
+			record = startReplay(ctx, req)
+		}
+		resp, err := handler(ctx, req)
+		if resp != nil && resp.ID == "" {
+			resp.ID = newResponseID()
+		}
+		if t, _ := TransferFromContext(ctx); resp != nil && t != (Transfer{}) {
+			resp.Transfer = &t
+		}
+		if config.Replay != nil {
+			if resp != nil {
+				resp.ReplayID = record.ID
+			}
+			finishReplay(ctx, config.Replay, record, resp, err)
+		}
+		return resp, err
+	}
+
+	if key := IdempotencyKeyFromContext(ctx); key != "" && config.Idempotency != nil && !IsDryRun(ctx) {
+		return c.completeIdempotent(ctx, config.Idempotency, key, req, run)
+	}
+	return run(ctx)
 }
 
-// Stream sends a streaming completion request
-func (c *Client) Stream(ctx context.Context, req *Request) (<-chan StreamEvent, error) {
-	if c.provider == nil {
+// Stream sends a streaming completion request.
+// Request options apply to a copy of req.
+func (c *Client) Stream(ctx context.Context, req *Request, opts ...RequestOption) (<-chan StreamEvent, error) {
+	provider, middleware, config := c.snapshot()
+	if provider == nil {
 		return nil, fmt.Errorf("no provider configured")
 	}
+	ctx, req = applyRequestOptions(ctx, req, opts)
+	ctx = withProvider(ctx, provider.Name())
+	ctx = withTransfer(ctx)
+	if config.DryRun {
+		ctx = ContextWithDryRun(ctx)
+	}
 
 	// Apply defaults
 	if req.MaxTokens == 0 {
-		req.MaxTokens = c.config.DefaultMaxTokens
+		req.MaxTokens = defaultMaxTokens(req, provider, config)
 	}
 	if req.Temperature == 0 {
-		req.Temperature = c.config.DefaultTemperature
+		req.Temperature = config.DefaultTemperature
 	}
 	req.Stream = true
 
-	return c.provider.Stream(ctx, req)
+	// Build stream middleware chain
+	handler := func(ctx context.Context, req *Request) (<-chan StreamEvent, error) {
+		var stream <-chan StreamEvent
+		var err error
+		if config.SimulateStream != nil && config.SimulateStream.simulates(req.Model, provider) {
+			stream, err = simulateStream(ctx, provider, req, *config.SimulateStream)
+		} else {
+			stream, err = provider.Stream(ctx, req)
+		}
+		if err != nil || !config.StreamTimestamps {
+			return stream, err
+		}
+		return stampStream(ctx, stream), nil
+	}
+
+	// Apply stream-capable middleware in reverse order
+	for i := len(middleware) - 1; i >= 0; i-- {
+		if sm, ok := middleware[i].(StreamMiddleware); ok {
+			handler = sm.WrapStream(handler)
+		}
+	}
+
+	var record ReplayRecord
+	if config.Replay != nil {
+		record = startReplay(ctx, req)
+	}
+
+	// A stop condition cancels only the provider request; replay and
+	// buffering keep ctx so the final event still reaches the consumer
+	providerCtx, stop, cancel := withStopCondition(ctx)
+	stream, err := handler(providerCtx, req)
+	if err != nil {
+		cancel()
+		if config.Replay != nil {
+			finishReplay(ctx, config.Replay, record, nil, err)
+		}
+		return nil, err
+	}
+	if config.StreamRetry != nil {
+		stream = retryStream(providerCtx, *config.StreamRetry, streamsPrefix(provider), req, handler, stream)
+	}
+	if stop != nil {
+		stream = stopStream(ctx, stream, stop, cancel)
+	}
+	if config.Replay != nil {
+		stream = replayStream(ctx, config.Replay, record, stream)
+	}
+	return bufferStream(ctx, stream, config.StreamBuffer, config.Backpressure), nil
+}
+
+// CompleteViaStream sends req as a stream and assembles the events into a
+// Response, for providers or models whose streaming endpoint is more
+// reliable than their blocking one. Stream options such as WithStreamRetry
+// apply. On a stream error the partial response is returned with it.
+func (c *Client) CompleteViaStream(ctx context.Context, req *Request, opts ...RequestOption) (*Response, error) {
+	r := *req
+	_, applied := applyRequestOptions(ctx, &r, opts)
+	model := applied.Model
+	if namer, ok := c.Provider().(ModelNamer); ok && model == "" {
+		model = namer.Model()
+	}
+
+	stream, err := c.Stream(ctx, &r, opts...)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := CollectStream(stream)
+	resp.Model = model
+	return resp, err
+}
+
+// Generate sends a single prompt and returns the response text
+func (c *Client) Generate(ctx context.Context, prompt string, opts ...RequestOption) (string, error) {
+	if prompt == "" {
+		return "", ErrEmptyMessage
+	}
+
+	req := &Request{
+		Messages: []Message{{Role: RoleUser, Content: prompt}},
+	}
+
+	resp, err := c.Complete(ctx, req, opts...)
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
 }
 
 // NewChat creates a new chat session with the client's provider
@@ -89,18 +253,55 @@ func (c *Client) NewChat(opts ...ChatOption) *Chat {
 
 // CountTokens estimates token count for the given text
 func (c *Client) CountTokens(text string) int {
-	if c.provider == nil {
+	provider := c.Provider()
+	if provider == nil {
 		return 0
 	}
-	return c.provider.CountTokens(text)
+	return provider.CountTokens(text)
 }
 
 // Provider returns the underlying provider
 func (c *Client) Provider() Provider {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.provider
 }
 
-// SetProvider changes the provider
+// SetProvider changes the provider. Requests already in flight keep the
+// previous one.
 func (c *Client) SetProvider(p Provider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.provider = p
 }
+
+// Config returns a copy of the client configuration
+func (c *Client) Config() ClientConfig {
+	_, _, config := c.snapshot()
+	return config
+}
+
+// Clone returns a new client with the same provider, middleware and
+// configuration, then applies opts to it. The original is unaffected, so
+// per-tenant variants can be derived from a shared base client:
+//
+//	tenant := base.Clone(simpleai.WithDefaultModel("gpt-4o-mini"), simpleai.WithMiddleware(quota))
+func (c *Client) Clone(opts ...Option) *Client {
+	provider, middleware, config := c.snapshot()
+	clone := &Client{
+		provider:   provider,
+		middleware: middleware,
+		config:     &config,
+	}
+	for _, opt := range opts {
+		opt(clone)
+	}
+	return clone
+}
+
+// snapshot returns the provider, middleware and configuration for one call
+func (c *Client) snapshot() (Provider, []Middleware, ClientConfig) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.provider, slices.Clip(c.middleware), *c.config
+}
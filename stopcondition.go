@@ -0,0 +1,166 @@
+package simpleai
+
+import (
+	"context"
+	"strings"
+)
+
+// FinishStopCondition is the finish reason of a stream ended by a
+// StopCondition
+const FinishStopCondition = "stop_condition"
+
+// StopCondition starts the check of one stream: the returned function is
+// called with each chunk of content in order and reports whether generation
+// should stop. Checks see every chunk once, so they can keep state instead
+// of rescanning the output; see StopWhen for checks on the whole output.
+type StopCondition func() func(chunk string) bool
+
+type stopConditionKey struct{}
+
+// WithStopCondition ends a stream as soon as cond returns true: the request
+// to the provider is cancelled, so providers that bill per output token
+// stop charging, and the stream ends with FinishStopCondition. The event
+// that triggered the stop is still delivered. Unlike WithStop, cond sees
+// the whole output and can stop on structure, e.g. StopWhenJSONComplete.
+// It applies to Stream and CompleteViaStream.
+func WithStopCondition(cond StopCondition) RequestOption {
+	return func(o *requestOptions) {
+		o.stopCondition = cond
+	}
+}
+
+// withStopCondition returns the request's stop condition, if any, and the
+// context for the provider request with the function that cancels it
+func withStopCondition(ctx context.Context) (context.Context, StopCondition, context.CancelFunc) {
+	cond, _ := ctx.Value(stopConditionKey{}).(StopCondition)
+	if cond == nil {
+		return ctx, nil, func() {}
+	}
+	providerCtx, cancel := context.WithCancel(ctx)
+	return providerCtx, cond, cancel
+}
+
+// StopWhen stops once fn returns true for the output so far. fn is called
+// after every chunk with the whole output, so keep it cheap for long
+// generations.
+func StopWhen(fn func(output string) bool) StopCondition {
+	return func() func(string) bool {
+		var output strings.Builder
+		return func(chunk string) bool {
+			output.WriteString(chunk)
+			return fn(output.String())
+		}
+	}
+}
+
+// StopOnPhrase stops once the output contains any of phrases, ignoring
+// case, e.g. to cut off a forbidden answer or a runaway list. Only the end
+// of the output that a phrase could still span is searched again.
+func StopOnPhrase(phrases ...string) StopCondition {
+	lower := make([]string, 0, len(phrases))
+	longest := 0
+	for _, p := range phrases {
+		if p != "" {
+			lower = append(lower, strings.ToLower(p))
+			longest = max(longest, len(lower[len(lower)-1]))
+		}
+	}
+	return func() func(string) bool {
+		var tail string // The end of the output a phrase may continue from
+		return func(chunk string) bool {
+			window := tail + strings.ToLower(chunk)
+			for _, p := range lower {
+				if strings.Contains(window, p) {
+					return true
+				}
+			}
+			tail = window[max(len(window)-longest+1, 0):]
+			return false
+		}
+	}
+}
+
+// StopWhenJSONComplete stops once the first JSON object or array in the
+// output is closed, so trailing commentary isn't generated. Braces inside
+// strings are ignored.
+func StopWhenJSONComplete() StopCondition {
+	return func() func(string) bool {
+		depth := 0
+		inString, escaped := false, false
+		return func(chunk string) bool {
+			for _, r := range chunk {
+				switch {
+				case escaped:
+					escaped = false
+				case inString:
+					switch r {
+					case '\\':
+						escaped = true
+					case '"':
+						inString = false
+					}
+				case r == '"':
+					// Quotes in text before the value don't open a string
+					inString = depth > 0
+				case r == '{' || r == '[':
+					depth++
+				case (r == '}' || r == ']') && depth > 0:
+					depth--
+					if depth == 0 {
+						return true
+					}
+				}
+			}
+			return false
+		}
+	}
+}
+
+// stopStream relays in until cond is met, then cancels the provider request
+// with cancel and ends the stream. Sends give up once ctx is done, and the
+// rest of in is drained so the provider's goroutine can exit.
+func stopStream(ctx context.Context, in <-chan StreamEvent, cond StopCondition, cancel context.CancelFunc) <-chan StreamEvent {
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+		defer cancel()
+		defer func() {
+			go func() {
+				for range in {
+				}
+			}()
+		}()
+
+		send := func(event StreamEvent) bool {
+			select {
+			case out <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		check := cond()
+		for event := range in {
+			if event.Content == "" || event.terminal() {
+				if !send(event) || event.terminal() {
+					return
+				}
+				continue
+			}
+
+			if !check(event.Content) {
+				if !send(event) {
+					return
+				}
+				continue
+			}
+
+			cancel()
+			if send(event) {
+				send(StreamEvent{Done: true, FinishReason: FinishStopCondition, Time: event.Time})
+			}
+			return
+		}
+	}()
+	return out
+}
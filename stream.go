@@ -0,0 +1,151 @@
+package simpleai
+
+import (
+	"context"
+	"strings"
+)
+
+// BackpressurePolicy decides what a stream does when its consumer reads
+// more slowly than the provider produces
+type BackpressurePolicy string
+
+const (
+	// BackpressureBlock makes the provider wait once the buffer is full.
+	// Nothing is lost, but a slow consumer stalls reading the provider's
+	// response, which can trigger provider timeouts. This is the default.
+	BackpressureBlock BackpressurePolicy = "block"
+	// BackpressureAccumulate never makes the provider wait: content that
+	// arrives while the consumer is busy is merged into one event. Nothing
+	// is lost, but events arrive in larger chunks.
+	BackpressureAccumulate BackpressurePolicy = "accumulate"
+	// BackpressureDrop never makes the provider wait: content events that
+	// don't fit in the buffer are dropped. Final and error events are always
+	// delivered. Use it only when partial text is acceptable, e.g. previews.
+	BackpressureDrop BackpressurePolicy = "drop"
+)
+
+// bufferStream relays in through a buffer of size events using policy. A
+// blocking stream without a buffer is returned unchanged.
+func bufferStream(ctx context.Context, in <-chan StreamEvent, size int, policy BackpressurePolicy) <-chan StreamEvent {
+	switch policy {
+	case BackpressureAccumulate:
+		return accumulateStream(ctx, in, size)
+	case BackpressureDrop:
+		return dropStream(ctx, in, max(size, 1))
+	}
+	if size <= 0 {
+		return in
+	}
+
+	out := make(chan StreamEvent, size)
+	go func() {
+		defer close(out)
+		for event := range in {
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				// The consumer may be gone; drain so the provider can exit
+			}
+		}
+	}()
+	return out
+}
+
+// terminal reports whether an event ends the stream
+func (e StreamEvent) terminal() bool {
+	return e.Done || e.Error != nil
+}
+
+func accumulateStream(ctx context.Context, in <-chan StreamEvent, size int) <-chan StreamEvent {
+	out := make(chan StreamEvent, size)
+	go func() {
+		defer close(out)
+		var queue []StreamEvent
+
+		for in != nil || len(queue) > 0 {
+			var send chan<- StreamEvent
+			var next StreamEvent
+			if len(queue) > 0 {
+				send, next = out, queue[0]
+			}
+
+			select {
+			case event, ok := <-in:
+				if !ok {
+					in = nil
+					continue
+				}
+				// Merge into the pending content event; it carries nothing
+				// but content, so the merged event loses nothing
+				if n := len(queue); n > 0 && !queue[n-1].terminal() {
+					event.Content = queue[n-1].Content + event.Content
+					queue[n-1] = event
+				} else {
+					queue = append(queue, event)
+				}
+			case send <- next:
+				queue = queue[1:]
+			case <-ctx.Done():
+				for range in {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func dropStream(ctx context.Context, in <-chan StreamEvent, size int) <-chan StreamEvent {
+	out := make(chan StreamEvent, size)
+	go func() {
+		defer close(out)
+		for event := range in {
+			if event.terminal() {
+				select {
+				case out <- event:
+				case <-ctx.Done():
+				}
+				continue
+			}
+			select {
+			case out <- event:
+			default:
+				// Consumer is behind; drop this chunk
+			}
+		}
+	}()
+	return out
+}
+
+// CollectStream reads stream to its end and assembles the events into a
+// Response. The error is the stream's error event, or ErrStreamClosed when
+// it closed without a final event; the partial response is returned with
+// it. Model is left empty since events don't carry it.
+func CollectStream(stream <-chan StreamEvent) (*Response, error) {
+	resp := &Response{}
+	var content strings.Builder
+	var err error
+	done := false
+	for event := range stream {
+		content.WriteString(event.Content)
+		if event.Done {
+			done = true
+			resp.FinishReason = event.FinishReason
+			resp.Citations = event.Citations
+			resp.ToolCalls = event.ToolCalls
+			resp.StopSequence = event.StopSequence
+			resp.ReplayID = event.ReplayID
+			if event.Usage != nil {
+				resp.Usage = *event.Usage
+			}
+		}
+		if event.Error != nil && err == nil {
+			err = event.Error
+		}
+	}
+	resp.Content = content.String()
+	if err == nil && !done {
+		err = ErrStreamClosed
+	}
+	return resp, err
+}
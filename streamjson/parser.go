@@ -0,0 +1,354 @@
+// Package streamjson parses JSON while a model is still writing it, so UIs
+// can render structured replies progressively: a title as soon as it is
+// typed, list items as they arrive.
+//
+//	updates, err := streamjson.Extract[Recipe](ctx, client, "A quick pasta recipe")
+//	for u := range updates {
+//		render(u.Value) // Partial: unfinished strings hold their text so far
+//		for _, path := range u.Completed {
+//			log.Println("finished", path) // "title", "steps[0]", ...
+//		}
+//	}
+package streamjson
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ErrIncomplete is returned when a stream ends before its JSON value does
+var ErrIncomplete = errors.New("streamjson: incomplete JSON")
+
+// Parser accumulates JSON text chunk by chunk and parses the partial value.
+// Text before the first '{' or '[' (prose, a code fence) and after the end
+// of the value is ignored.
+type Parser struct {
+	buf       strings.Builder
+	started   bool
+	value     any
+	completed map[string]bool
+	done      bool
+	err       error
+}
+
+// NewParser creates a parser
+func NewParser() *Parser {
+	return &Parser{completed: make(map[string]bool)}
+}
+
+// Write adds a chunk and returns the paths of the values it finished, in
+// document order. Paths join object keys with dots and array indexes with
+// brackets, e.g. "items[2].name"; the whole value is "".
+func (p *Parser) Write(chunk string) ([]string, error) {
+	if p.done || p.err != nil {
+		return nil, p.err
+	}
+	if !p.started {
+		start := strings.IndexAny(chunk, "{[")
+		if start < 0 {
+			return nil, nil
+		}
+		chunk = chunk[start:]
+		p.started = true
+	}
+	p.buf.WriteString(chunk)
+
+	s := &scanner{text: p.buf.String()}
+	value, _, complete, err := s.value("")
+	if err != nil {
+		p.err = err
+		return nil, err
+	}
+	p.value = value
+	p.done = complete
+
+	var completed []string
+	for _, path := range s.completed {
+		if !p.completed[path] {
+			p.completed[path] = true
+			completed = append(completed, path)
+		}
+	}
+	return completed, nil
+}
+
+// Value returns the value parsed so far: maps, slices, strings, json.Number,
+// bools and nil. Unfinished strings hold their text so far; unfinished keys
+// and literals are left out.
+func (p *Parser) Value() any {
+	return p.value
+}
+
+// Decode stores the value parsed so far in out, as json.Unmarshal would
+// with the missing parts left zero
+func (p *Parser) Decode(out any) error {
+	if p.value == nil {
+		return nil
+	}
+	data, err := json.Marshal(p.value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// Done reports whether the JSON value is complete
+func (p *Parser) Done() bool {
+	return p.done
+}
+
+// Text returns the JSON text received so far
+func (p *Parser) Text() string {
+	return p.buf.String()
+}
+
+// scanner is a recursive descent parser over possibly truncated JSON
+type scanner struct {
+	text      string
+	pos       int
+	completed []string
+}
+
+// value parses the value at pos. ok reports whether there is something to
+// show for it; complete whether it ended.
+func (s *scanner) value(path string) (v any, ok, complete bool, err error) {
+	s.space()
+	if s.pos >= len(s.text) {
+		return nil, false, false, nil
+	}
+	switch c := s.text[s.pos]; {
+	case c == '{':
+		v, complete, err = s.object(path)
+		ok = true
+	case c == '[':
+		v, complete, err = s.array(path)
+		ok = true
+	case c == '"':
+		v, complete, err = s.string()
+		ok = true
+	case c == '-' || c >= '0' && c <= '9':
+		v, ok, complete, err = s.number()
+	case c == 't' || c == 'f' || c == 'n':
+		v, ok, complete, err = s.literal()
+	default:
+		err = s.errorf("unexpected %q", c)
+	}
+	if complete && err == nil {
+		s.completed = append(s.completed, path)
+	}
+	return v, ok, complete, err
+}
+
+func (s *scanner) object(path string) (map[string]any, bool, error) {
+	obj := map[string]any{}
+	s.pos++ // {
+	for {
+		s.space()
+		if s.pos >= len(s.text) {
+			return obj, false, nil
+		}
+		switch s.text[s.pos] {
+		case '}':
+			s.pos++
+			return obj, true, nil
+		case ',':
+			s.pos++
+			continue
+		case '"':
+		default:
+			return obj, false, s.errorf("unexpected %q in object", s.text[s.pos])
+		}
+
+		key, complete, err := s.string()
+		if err != nil || !complete {
+			return obj, false, err
+		}
+		s.space()
+		if s.pos >= len(s.text) {
+			return obj, false, nil
+		}
+		if s.text[s.pos] != ':' {
+			return obj, false, s.errorf("expected ':' after key %q", key)
+		}
+		s.pos++
+
+		child := key
+		if path != "" {
+			child = path + "." + key
+		}
+		v, ok, complete, err := s.value(child)
+		if ok {
+			obj[key] = v
+		}
+		if err != nil || !complete {
+			return obj, false, err
+		}
+	}
+}
+
+func (s *scanner) array(path string) ([]any, bool, error) {
+	arr := []any{}
+	s.pos++ // [
+	for {
+		s.space()
+		if s.pos >= len(s.text) {
+			return arr, false, nil
+		}
+		switch s.text[s.pos] {
+		case ']':
+			s.pos++
+			return arr, true, nil
+		case ',':
+			s.pos++
+			continue
+		}
+
+		v, ok, complete, err := s.value(path + "[" + strconv.Itoa(len(arr)) + "]")
+		if ok {
+			arr = append(arr, v)
+		}
+		if err != nil || !complete {
+			return arr, false, err
+		}
+	}
+}
+
+// string decodes a string at pos, returning the text so far if it is
+// unfinished
+func (s *scanner) string() (string, bool, error) {
+	s.pos++ // "
+	var b strings.Builder
+	for s.pos < len(s.text) {
+		c := s.text[s.pos]
+		switch {
+		case c == '"':
+			s.pos++
+			return b.String(), true, nil
+		case c == '\\':
+			if s.pos+1 >= len(s.text) {
+				return b.String(), false, nil
+			}
+			e := s.text[s.pos+1]
+			switch e {
+			case '"', '\\', '/':
+				b.WriteByte(e)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				r, size, complete := s.unicode(s.pos)
+				if !complete {
+					return b.String(), false, nil
+				}
+				if r < 0 {
+					return b.String(), false, s.errorf("invalid unicode escape")
+				}
+				b.WriteRune(r)
+				s.pos += size
+				continue
+			default:
+				return b.String(), false, s.errorf("invalid escape %q", e)
+			}
+			s.pos += 2
+		default:
+			r, size := utf8.DecodeRuneInString(s.text[s.pos:])
+			if r == utf8.RuneError && size == 1 && !utf8.FullRuneInString(s.text[s.pos:]) {
+				return b.String(), false, nil // A chunk split a character
+			}
+			b.WriteString(s.text[s.pos : s.pos+size])
+			s.pos += size
+		}
+	}
+	return b.String(), false, nil
+}
+
+// unicode decodes a \uXXXX escape at i, with its low surrogate if any
+func (s *scanner) unicode(i int) (r rune, size int, complete bool) {
+	hex := func(at int) (rune, bool) {
+		if at+6 > len(s.text) {
+			return 0, false
+		}
+		n, err := strconv.ParseUint(s.text[at+2:at+6], 16, 16)
+		if err != nil {
+			return -1, true
+		}
+		return rune(n), true
+	}
+	r, complete = hex(i)
+	if !complete || r < 0 {
+		return r, 0, complete
+	}
+	if !utf16.IsSurrogate(r) {
+		return r, 6, true
+	}
+	if i+8 > len(s.text) {
+		return 0, 0, false
+	}
+	if s.text[i+6:i+8] != `\u` {
+		return utf8.RuneError, 6, true
+	}
+	low, complete := hex(i + 6)
+	if !complete || low < 0 {
+		return low, 0, complete
+	}
+	return utf16.DecodeRune(r, low), 12, true
+}
+
+// number parses a number at pos. An unfinished number is shown as far as
+// it is valid.
+func (s *scanner) number() (any, bool, bool, error) {
+	start := s.pos
+	for s.pos < len(s.text) && strings.IndexByte("+-0123456789.eE", s.text[s.pos]) >= 0 {
+		s.pos++
+	}
+	text := s.text[start:s.pos]
+	complete := s.pos < len(s.text)
+	if !complete {
+		text = strings.TrimRight(text, "+-.eE")
+	}
+	if _, err := strconv.ParseFloat(text, 64); err != nil {
+		if complete {
+			return nil, false, false, s.errorf("invalid number %q", text)
+		}
+		return nil, false, false, nil
+	}
+	return json.Number(text), true, complete, nil
+}
+
+// literal parses true, false or null at pos
+func (s *scanner) literal() (any, bool, bool, error) {
+	for word, v := range map[string]any{"true": true, "false": false, "null": nil} {
+		rest := s.text[s.pos:]
+		if strings.HasPrefix(rest, word) {
+			s.pos += len(word)
+			return v, true, true, nil
+		}
+		if strings.HasPrefix(word, rest) {
+			s.pos = len(s.text)
+			return nil, false, false, nil
+		}
+	}
+	return nil, false, false, s.errorf("invalid literal")
+}
+
+func (s *scanner) space() {
+	for s.pos < len(s.text) && strings.IndexByte(" \t\r\n", s.text[s.pos]) >= 0 {
+		s.pos++
+	}
+}
+
+func (s *scanner) errorf(format string, args ...any) error {
+	return fmt.Errorf("streamjson: "+format+" at offset %d", append(args, s.pos)...)
+}
@@ -0,0 +1,90 @@
+package streamjson
+
+import (
+	"context"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// Update is the state of a streamed JSON value after a chunk
+type Update[T any] struct {
+	Value     T        // Partial value; fields not yet received are zero
+	Completed []string // Paths of the values finished by this chunk, e.g. "items[2].name"
+	Done      bool     // The stream ended; Value is final unless Err is set
+	Err       error    // Provider, parse or decode error, on the last update
+}
+
+// Decode parses the JSON a model streams into T, sending an update for
+// every chunk that changes the value. The last update has Done set; its
+// Err is ErrIncomplete if the stream ended before the value did.
+func Decode[T any](ctx context.Context, stream <-chan simpleai.StreamEvent) <-chan Update[T] {
+	out := make(chan Update[T])
+	go func() {
+		defer close(out)
+		parser := NewParser()
+		send := func(u Update[T]) bool {
+			select {
+			case out <- u:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		finish := func(err error) {
+			var value T
+			decodeErr := parser.Decode(&value)
+			if err == nil && parser.Done() {
+				err = decodeErr
+			}
+			send(Update[T]{Value: value, Done: true, Err: err})
+		}
+
+		for event := range stream {
+			if event.Error != nil {
+				finish(event.Error)
+				return
+			}
+			if event.Content != "" && !parser.Done() {
+				before := parser.Text()
+				completed, err := parser.Write(event.Content)
+				if err != nil {
+					finish(err)
+					return
+				}
+				if parser.Text() != before {
+					var value T
+					parser.Decode(&value) // Partial values may not fit T yet
+					if !send(Update[T]{Value: value, Completed: completed}) {
+						return
+					}
+				}
+			}
+			if event.Done {
+				break
+			}
+		}
+
+		if !parser.Done() {
+			finish(ErrIncomplete)
+			return
+		}
+		finish(nil)
+	}()
+	return out
+}
+
+// Extract is a streaming simpleai.Client.Extract: it asks the model to
+// answer input with JSON shaped like T and decodes the reply as it streams.
+// Unlike Extract it can't retry invalid JSON, since the reply is already
+// shown.
+func Extract[T any](ctx context.Context, client *simpleai.Client, input string, opts ...simpleai.RequestOption) (<-chan Update[T], error) {
+	var shape T
+	stream, err := client.Stream(ctx, &simpleai.Request{
+		Messages:     []simpleai.Message{{Role: simpleai.RoleUser, Content: input}},
+		SystemPrompt: simpleai.JSONPrompt(shape),
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return Decode[T](ctx, stream), nil
+}
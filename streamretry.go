@@ -0,0 +1,235 @@
+package simpleai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrStreamDiverged is returned when a restarted stream produces different
+// text from what was already delivered
+var ErrStreamDiverged = errors.New("simpleai: restarted stream diverged from the delivered text")
+
+// StreamRetryMode selects how a failed stream is re-issued
+type StreamRetryMode string
+
+const (
+	// StreamResume re-issues the request with the delivered text as the
+	// assistant prefix, so the model continues where the stream died
+	// (default). It needs a provider whose streams start with the prefix
+	// (Capabilities.StreamPrefix); streams of other providers are restarted
+	// as with StreamRestart.
+	StreamResume StreamRetryMode = "resume"
+	// StreamRestart re-issues the original request and skips the text that
+	// was already delivered. The stream fails with ErrStreamDiverged if the
+	// new answer doesn't start the same way.
+	StreamRestart StreamRetryMode = "restart"
+)
+
+// StreamRetryConfig configures reconnecting streams that die mid-response
+type StreamRetryConfig struct {
+	MaxRetries int             // Reconnects per stream (default 2)
+	Delay      time.Duration   // Wait before reconnecting (default 500ms)
+	Mode       StreamRetryMode // Default StreamResume
+
+	// Retryable decides whether an error is worth reconnecting for
+	// (default: anything but context cancellation)
+	Retryable func(err error) bool
+
+	// OnRetry is called before each reconnect
+	OnRetry func(attempt int, err error)
+}
+
+// DefaultStreamRetryConfig returns sensible defaults
+func DefaultStreamRetryConfig() StreamRetryConfig {
+	return StreamRetryConfig{
+		MaxRetries: 2,
+		Delay:      500 * time.Millisecond,
+		Mode:       StreamResume,
+		Retryable: func(err error) bool {
+			return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+		},
+	}
+}
+
+// retryStream forwards stream and, when it fails or closes without a final
+// event, re-issues req through handler so the consumer sees one seamless
+// response. streamPrefix reports whether the provider's streams start with
+// the assistant prefix, which resuming relies on.
+func retryStream(ctx context.Context, config StreamRetryConfig, streamPrefix bool, req *Request, handler StreamHandler, stream <-chan StreamEvent) <-chan StreamEvent {
+	defaults := DefaultStreamRetryConfig()
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaults.MaxRetries
+	}
+	if config.Delay <= 0 {
+		config.Delay = defaults.Delay
+	}
+	if config.Mode == "" {
+		config.Mode = defaults.Mode
+	}
+	if config.Mode == StreamResume && !streamPrefix {
+		// A continuation can't be told apart from a repeat of the prefix
+		config.Mode = StreamRestart
+	}
+	if config.Retryable == nil {
+		config.Retryable = defaults.Retryable
+	}
+
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+
+		// Once ctx is done nobody may be reading, so sends give up rather
+		// than block the goroutine
+		send := func(event StreamEvent) bool {
+			select {
+			case out <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var delivered strings.Builder
+		var replay *replaySkipper
+		for attempt := 1; ; attempt++ {
+			err := forwardAttempt(ctx, stream, replay, config.Mode, &delivered, send)
+			if err == nil {
+				return
+			}
+			if attempt > config.MaxRetries || ctx.Err() != nil || errors.Is(err, ErrStreamDiverged) || !config.Retryable(err) {
+				send(StreamEvent{Error: err, Done: true})
+				return
+			}
+
+			if config.OnRetry != nil {
+				config.OnRetry(attempt, err)
+			}
+			select {
+			case <-ctx.Done():
+				send(StreamEvent{Error: ctx.Err(), Done: true})
+				return
+			case <-time.After(config.Delay):
+			}
+
+			retry := *req
+			if config.Mode == StreamRestart {
+				replay = &replaySkipper{target: delivered.String()}
+			} else {
+				prefix := delivered.String()
+				if !strings.HasPrefix(prefix, req.AssistantPrefix) {
+					prefix = req.AssistantPrefix + prefix
+				}
+				retry.AssistantPrefix = prefix
+				replay = &replaySkipper{target: prefix}
+			}
+
+			stream, err = handler(ctx, &retry)
+			if err != nil {
+				stream = errorStream(err)
+			}
+		}
+	}()
+	return out
+}
+
+// streamsPrefix reports whether provider's streams start with the
+// assistant prefix
+func streamsPrefix(provider Provider) bool {
+	reporter, ok := provider.(CapabilityReporter)
+	return ok && reporter.Capabilities().StreamPrefix
+}
+
+// forwardAttempt forwards one attempt's events with send, skipping replayed
+// text. It returns nil after a successful final event, or why the attempt
+// failed. An attempt given up early is drained so its provider can exit.
+func forwardAttempt(ctx context.Context, stream <-chan StreamEvent, replay *replaySkipper, mode StreamRetryMode, delivered *strings.Builder, send func(StreamEvent) bool) error {
+	drain := func() {
+		go func() {
+			for range stream {
+			}
+		}()
+	}
+	var failure error
+	for event := range stream {
+		if event.Error != nil {
+			failure = event.Error
+			continue
+		}
+
+		text := event.Content
+		if replay != nil {
+			var diverged bool
+			text, diverged = replay.feed(text)
+			if diverged && mode == StreamRestart {
+				drain()
+				return ErrStreamDiverged
+			}
+		}
+		delivered.WriteString(text)
+
+		if text == "" && event.Content != "" && !event.Done {
+			continue // Replayed text
+		}
+		event.Content = text
+		if !send(event) {
+			drain()
+			return ctx.Err()
+		}
+		if event.Done {
+			drain()
+			return nil
+		}
+	}
+	if failure == nil {
+		failure = ErrStreamClosed
+	}
+	return failure
+}
+
+// replaySkipper drops the start of a reconnected stream that repeats text
+// already delivered
+type replaySkipper struct {
+	target string
+	buf    string
+	done   bool
+	strip  string // Whitespace to drop from the next text
+}
+
+// feed returns the new text in chunk. diverged reports that the stream
+// doesn't repeat the target; the buffered text is then returned as is.
+func (r *replaySkipper) feed(chunk string) (text string, diverged bool) {
+	if r.done {
+		if r.strip != "" && chunk != "" {
+			chunk, r.strip = strings.TrimPrefix(chunk, r.strip), ""
+		}
+		return chunk, false
+	}
+	r.buf += chunk
+
+	// Anthropic drops trailing whitespace from the prefix, so match without it
+	target := strings.TrimRight(r.target, " \t\n")
+	if len(r.buf) < len(target) {
+		if strings.HasPrefix(target, r.buf) {
+			return "", false
+		}
+		r.done = true
+		return r.buf, true
+	}
+
+	r.done = true
+	if !strings.HasPrefix(r.buf, target) {
+		return r.buf, true
+	}
+	r.strip = r.target[len(target):]
+	return r.feed(r.buf[len(target):])
+}
+
+// errorStream returns a closed stream holding one error event
+func errorStream(err error) <-chan StreamEvent {
+	out := make(chan StreamEvent, 1)
+	out <- StreamEvent{Error: err, Done: true}
+	close(out)
+	return out
+}
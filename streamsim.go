@@ -0,0 +1,127 @@
+package simpleai
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SimulatedStreamConfig configures faking streams from complete responses
+type SimulatedStreamConfig struct {
+	// Models are the model names, or prefixes of them, whose streams are
+	// simulated (empty = every stream). The provider's default model is
+	// matched when a request names none.
+	Models []string
+
+	WordsPerChunk int           // Words per event (default 1)
+	Delay         time.Duration // Pause between events (default 20ms; negative = none)
+}
+
+// DefaultSimulatedStreamConfig returns sensible defaults
+func DefaultSimulatedStreamConfig() SimulatedStreamConfig {
+	return SimulatedStreamConfig{
+		WordsPerChunk: 1,
+		Delay:         20 * time.Millisecond,
+	}
+}
+
+// simulates reports whether streams for model are simulated
+func (c *SimulatedStreamConfig) simulates(model string, provider Provider) bool {
+	if len(c.Models) == 0 {
+		return true
+	}
+	if model == "" {
+		if namer, ok := provider.(ModelNamer); ok {
+			model = namer.Model()
+		}
+	}
+	for _, prefix := range c.Models {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// simulateStream calls Complete and replays the response as word-sized
+// events, ending with a final event that carries the finish reason, usage
+// and tool calls
+func simulateStream(ctx context.Context, provider Provider, req *Request, config SimulatedStreamConfig) (<-chan StreamEvent, error) {
+	defaults := DefaultSimulatedStreamConfig()
+	if config.WordsPerChunk <= 0 {
+		config.WordsPerChunk = defaults.WordsPerChunk
+	}
+	if config.Delay == 0 {
+		config.Delay = defaults.Delay
+	}
+
+	complete := *req
+	complete.Stream = false
+	resp, err := provider.Complete(ctx, &complete)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+		send := func(event StreamEvent) bool {
+			select {
+			case out <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		words := splitWords(resp.Content)
+		for i := 0; i < len(words); i += config.WordsPerChunk {
+			if i > 0 && config.Delay > 0 {
+				select {
+				case <-time.After(config.Delay):
+				case <-ctx.Done():
+					send(StreamEvent{Error: ctx.Err()})
+					return
+				}
+			}
+			chunk := strings.Join(words[i:min(i+config.WordsPerChunk, len(words))], "")
+			if !send(StreamEvent{Content: chunk}) {
+				return
+			}
+		}
+
+		usage := resp.Usage
+		send(StreamEvent{
+			Done:         true,
+			FinishReason: resp.FinishReason,
+			Citations:    resp.Citations,
+			ToolCalls:    resp.ToolCalls,
+			StopSequence: resp.StopSequence,
+			Usage:        &usage,
+		})
+	}()
+	return out, nil
+}
+
+// splitWords cuts s into words that keep their trailing whitespace, so the
+// pieces join back to s
+func splitWords(s string) []string {
+	var words []string
+	start := 0
+	word := false // The current piece has a non-space character
+	afterSpace := false
+	for i, r := range s {
+		space := unicode.IsSpace(r)
+		if !space && afterSpace && word {
+			words = append(words, s[start:i])
+			start = i
+		}
+		word = word || !space
+		afterSpace = space
+	}
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+	return words
+}
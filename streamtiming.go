@@ -0,0 +1,132 @@
+package simpleai
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/simpleai/contextbuilder"
+)
+
+// StreamTiming holds the latency measurements of one stream
+type StreamTiming struct {
+	Start      time.Time // Request sent
+	FirstToken time.Time // First event with content (zero if there was none)
+	End        time.Time // Final event, error or close
+
+	Events       int    // Events with content
+	OutputTokens int    // From Usage when the provider reports it, otherwise estimated
+	Usage        *Usage // From the final event, if reported
+	Err          error  // Error event or stream creation error, if any
+	Content      string // Text delivered
+}
+
+// TimeToFirstToken is how long the consumer waited for the first content
+func (t StreamTiming) TimeToFirstToken() time.Duration {
+	if t.FirstToken.IsZero() {
+		return 0
+	}
+	return t.FirstToken.Sub(t.Start)
+}
+
+// Duration is the time from request to the end of the stream
+func (t StreamTiming) Duration() time.Duration {
+	return t.End.Sub(t.Start)
+}
+
+// TokensPerSecond is the output rate after the first token, or 0 when it
+// cannot be measured
+func (t StreamTiming) TokensPerSecond() float64 {
+	if t.FirstToken.IsZero() || t.OutputTokens <= 1 {
+		return 0
+	}
+	generation := t.End.Sub(t.FirstToken).Seconds()
+	if generation <= 0 {
+		return 0
+	}
+	// The first token arrived at FirstToken, so the rest took generation
+	return float64(t.OutputTokens-1) / generation
+}
+
+// TraceStream relays in, timing it from start, and calls done once with the
+// measurements when it ends. Events keep the Time they were stamped with
+// (see WithStreamTimestamps); others are timed on arrival. countTokens
+// estimates output tokens when the provider reports no usage (nil = ~4
+// characters per token).
+func TraceStream(ctx context.Context, in <-chan StreamEvent, start time.Time, countTokens func(string) int, done func(StreamTiming)) <-chan StreamEvent {
+	if countTokens == nil {
+		countTokens = contextbuilder.EstimateTokens
+	}
+
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+		timing := StreamTiming{Start: start}
+		var text strings.Builder
+		var once sync.Once
+		finish := func(end time.Time) {
+			once.Do(func() {
+				timing.End = end
+				timing.Content = text.String()
+				if timing.Usage != nil && timing.Usage.CompletionTokens > 0 {
+					timing.OutputTokens = timing.Usage.CompletionTokens
+				} else {
+					timing.OutputTokens = countTokens(timing.Content)
+				}
+				if done != nil {
+					done(timing)
+				}
+			})
+		}
+		defer func() { finish(time.Now()) }()
+
+		for event := range in {
+			at := event.Time
+			if at.IsZero() {
+				at = time.Now()
+			}
+			if event.Content != "" {
+				if timing.FirstToken.IsZero() {
+					timing.FirstToken = at
+				}
+				timing.Events++
+				text.WriteString(event.Content)
+			}
+			if event.Usage != nil {
+				timing.Usage = event.Usage
+			}
+			if event.Error != nil {
+				timing.Err = event.Error
+			}
+			if event.terminal() {
+				finish(at)
+			}
+
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				// The consumer may be gone; drain so the provider can exit
+			}
+		}
+	}()
+	return out
+}
+
+// stampStream sets Time on every event as it arrives from the provider
+func stampStream(ctx context.Context, in <-chan StreamEvent) <-chan StreamEvent {
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+		for event := range in {
+			if event.Time.IsZero() {
+				event.Time = time.Now()
+			}
+			select {
+			case out <- event:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,163 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// ErrIncludeCycle is returned when templates include each other in a loop
+var ErrIncludeCycle = errors.New("template: include cycle")
+
+// invalidate drops compiled templates after a change; callers hold the
+// write lock
+func (e *Engine) invalidate() {
+	clear(e.compiled)
+	e.generation++
+}
+
+// compiledTemplate returns the named template together with everything it
+// includes, compiling it on first use
+func (e *Engine) compiledTemplate(name string) (*template.Template, error) {
+	e.mu.RLock()
+	if tmpl, ok := e.compiled[name]; ok {
+		e.mu.RUnlock()
+		return tmpl, nil
+	}
+	main, ok := e.templates[name]
+	if !ok {
+		e.mu.RUnlock()
+		return nil, fmt.Errorf("template %s not found", name)
+	}
+	tmpl, err := e.compile(main, e.sources[main])
+	generation := e.generation
+	e.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	if e.generation == generation {
+		e.compiled[name] = tmpl
+	}
+	e.mu.Unlock()
+	return tmpl, nil
+}
+
+// compile parses main and the partials and templates it includes into one
+// set. Includes are parsed before the templates that use them, so a
+// template's {{define}} overrides a {{block}} default of a base template it
+// includes. Callers hold the read lock.
+func (e *Engine) compile(main *template.Template, content string) (*template.Template, error) {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	var order []*template.Template
+	state := make(map[*template.Template]int)
+
+	var visit func(t *template.Template, path []string) error
+	visit = func(t *template.Template, path []string) error {
+		path = append(path, t.Name())
+		switch state[t] {
+		case visiting:
+			return fmt.Errorf("%w: %s", ErrIncludeCycle, strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+		state[t] = visiting
+
+		for _, ref := range references(t) {
+			if t.Lookup(ref) != nil {
+				continue // Defined in the same source
+			}
+			dep := e.include(ref)
+			if dep == nil {
+				if main.Lookup(ref) != nil {
+					continue // Provided by the including template
+				}
+				return fmt.Errorf("template %s: included template %q not found", t.Name(), ref)
+			}
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+
+		state[t] = visited
+		order = append(order, t)
+		return nil
+	}
+	if err := visit(main, nil); err != nil {
+		return nil, err
+	}
+
+	set := template.New(main.Name()).Funcs(e.funcs)
+	for _, t := range order {
+		source := e.sources[t]
+		if t == main {
+			source = content
+		}
+		if _, err := set.New(t.Name()).Parse(source); err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", t.Name(), err)
+		}
+	}
+	return set.Lookup(main.Name()), nil
+}
+
+// include returns the partial or template that provides name: a partial or
+// template of that name, or a partial that {{define}}s it
+func (e *Engine) include(name string) *template.Template {
+	if t, ok := e.partials[name]; ok {
+		return t
+	}
+	if t, ok := e.templates[name]; ok {
+		return t
+	}
+	for _, t := range e.partials {
+		if t.Lookup(name) != nil {
+			return t
+		}
+	}
+	return nil
+}
+
+// references returns the names included with {{template}} anywhere in t's
+// source, including its {{define}} blocks
+func references(t *template.Template) []string {
+	seen := make(map[string]bool)
+	var names []string
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.IfNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			if !seen[n.Name] {
+				seen[n.Name] = true
+				names = append(names, n.Name)
+			}
+		}
+	}
+	for _, defined := range t.Templates() {
+		if defined.Tree != nil {
+			walk(defined.Tree.Root)
+		}
+	}
+	return names
+}
@@ -0,0 +1,95 @@
+package template
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/medatechnology/simpleai/internal/lang"
+)
+
+// numberFormat is how a locale writes numbers
+type numberFormat struct {
+	group, decimal string
+}
+
+var numberFormats = map[string]numberFormat{
+	"de": {".", ","}, "es": {".", ","}, "it": {".", ","}, "pt": {".", ","},
+	"nl": {".", ","}, "id": {".", ","}, "el": {".", ","},
+	"fr": {" ", ","}, "ru": {" ", ","}, "uk": {" ", ","},
+}
+
+// dateLayouts are the short date formats of locales, by full tag first
+// and then by base language
+var dateLayouts = map[string]string{
+	"en":    "Jan 2, 2006",
+	"en-gb": "2 Jan 2006", "en-au": "2 Jan 2006", "en-in": "2 Jan 2006",
+	"de": "2.1.2006", "ru": "02.01.2006", "uk": "02.01.2006",
+	"fr": "02/01/2006", "es": "02/01/2006", "it": "02/01/2006",
+	"pt": "02/01/2006", "id": "02/01/2006", "el": "2/1/2006",
+	"nl": "2-1-2006", "ja": "2006/01/02", "zh": "2006/01/02",
+	"ko": "2006. 1. 2.",
+}
+
+// LanguageName returns the English name of a locale's language, e.g.
+// "German" for "de-AT", or the locale itself when unknown
+func LanguageName(locale string) string {
+	return lang.Name(locale)
+}
+
+// FormatNumber writes an integer or float with the locale's digit
+// grouping and decimal mark, e.g. 1234.5 is "1.234,5" in "de". Unknown
+// locales use "1,234.5".
+func FormatNumber(locale string, value any) string {
+	var s string
+	switch v := value.(type) {
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		s = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s = fmt.Sprint(v)
+	default:
+		return fmt.Sprint(value)
+	}
+
+	format, ok := numberFormats[lang.Base(locale)]
+	if !ok {
+		format = numberFormat{",", "."}
+	}
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, frac, hasFrac := strings.Cut(s, ".")
+
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(format.group)
+		}
+		b.WriteRune(r)
+	}
+	if hasFrac {
+		b.WriteString(format.decimal)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+// FormatDate writes a date in the locale's short format, e.g. "Mar 5,
+// 2025" in "en" and "05.03.2025" in "ru". Unknown locales use ISO 8601.
+func FormatDate(locale string, t time.Time) string {
+	tag := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(locale)), "_", "-")
+	layout, ok := dateLayouts[tag]
+	if !ok {
+		layout, ok = dateLayouts[lang.Base(tag)]
+	}
+	if !ok {
+		layout = time.DateOnly
+	}
+	return t.Format(layout)
+}
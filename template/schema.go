@@ -0,0 +1,181 @@
+package template
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// VarType is the expected type of a template variable
+type VarType string
+
+const (
+	TypeAny    VarType = "any"
+	TypeString VarType = "string"
+	TypeInt    VarType = "int" // Integers, including whole float64 values from JSON
+	TypeNumber VarType = "number"
+	TypeBool   VarType = "bool"
+	TypeList   VarType = "list" // Slices and arrays
+	TypeMap    VarType = "map"  // Maps and structs
+)
+
+// Var declares a variable a template uses
+type Var struct {
+	Name     string
+	Type     VarType // Default TypeAny
+	Optional bool    // Required unless set
+}
+
+// Schema declares the variables of a template
+type Schema []Var
+
+// ValidationError lists the variables that are missing or have the wrong
+// type
+type ValidationError struct {
+	Template string
+	Missing  []string
+	Mistyped []string // "Age (want int, got string)"
+}
+
+func (e *ValidationError) Error() string {
+	var problems []string
+	if len(e.Missing) > 0 {
+		problems = append(problems, "missing "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Mistyped) > 0 {
+		problems = append(problems, "wrong type "+strings.Join(e.Mistyped, ", "))
+	}
+	name := e.Template
+	if name == "" {
+		name = "inline"
+	}
+	return fmt.Sprintf("template %s: invalid variables: %s", name, strings.Join(problems, "; "))
+}
+
+// Validate checks data, a map with string keys or a struct, against the
+// schema. It returns a *ValidationError listing every problem.
+func (s Schema) Validate(data any) error {
+	verr := &ValidationError{}
+	for _, v := range s {
+		value, ok := lookupVar(data, v.Name)
+		if !ok {
+			if !v.Optional {
+				verr.Missing = append(verr.Missing, v.Name)
+			}
+			continue
+		}
+		if !matchesType(value, v.Type) {
+			verr.Mistyped = append(verr.Mistyped, fmt.Sprintf("%s (want %s, got %s)", v.Name, v.Type, value.Type()))
+		}
+	}
+	if len(verr.Missing) > 0 || len(verr.Mistyped) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// ParseSchema parses front-matter lines of the form "Name: type", with a
+// "?" after the type for optional variables:
+//
+//	Patient: string
+//	Age: int
+//	Notes: string?
+func ParseSchema(text string) (Schema, error) {
+	var schema Schema
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, typ, _ := strings.Cut(line, ":")
+		name, typ = strings.TrimSpace(name), strings.TrimSpace(typ)
+		if name == "" {
+			return nil, fmt.Errorf("schema line %d: missing variable name", i+1)
+		}
+
+		v := Var{Name: name}
+		typ, v.Optional = strings.CutSuffix(typ, "?")
+		v.Type = VarType(typ)
+		switch v.Type {
+		case "":
+			v.Type = TypeAny
+		case TypeAny, TypeString, TypeInt, TypeNumber, TypeBool, TypeList, TypeMap:
+		default:
+			return nil, fmt.Errorf("schema line %d: unknown type %q", i+1, typ)
+		}
+		schema = append(schema, v)
+	}
+	return schema, nil
+}
+
+// splitFrontMatter separates a leading "---" delimited schema block from
+// the template body
+func splitFrontMatter(content string) (schema Schema, body string, err error) {
+	rest, ok := strings.CutPrefix(content, "---\n")
+	if !ok {
+		return nil, content, nil
+	}
+	header, body, ok := strings.Cut(rest, "\n---\n")
+	if !ok {
+		return nil, content, nil
+	}
+	schema, err = ParseSchema(header)
+	return schema, body, err
+}
+
+// lookupVar finds a variable in a map or struct
+func lookupVar(data any, name string) (reflect.Value, bool) {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+
+	var field reflect.Value
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}, false
+		}
+		field = v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+	case reflect.Struct:
+		field = v.FieldByName(name)
+	}
+	for field.IsValid() && field.Kind() == reflect.Interface {
+		field = field.Elem()
+	}
+	if !field.IsValid() || (field.Kind() == reflect.Pointer && field.IsNil()) {
+		return reflect.Value{}, false
+	}
+	return field, true
+}
+
+func matchesType(v reflect.Value, typ VarType) bool {
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	switch typ {
+	case TypeString:
+		return v.Kind() == reflect.String
+	case TypeInt:
+		switch {
+		case v.CanInt(), v.CanUint():
+			return true
+		case v.CanFloat():
+			return v.Float() == math.Trunc(v.Float())
+		}
+		return false
+	case TypeNumber:
+		return v.CanInt() || v.CanUint() || v.CanFloat()
+	case TypeBool:
+		return v.Kind() == reflect.Bool
+	case TypeList:
+		return v.Kind() == reflect.Slice || v.Kind() == reflect.Array
+	case TypeMap:
+		return v.Kind() == reflect.Map || v.Kind() == reflect.Struct
+	}
+	return true
+}
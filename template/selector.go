@@ -0,0 +1,174 @@
+package template
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/medatechnology/simpleai/embedding"
+)
+
+// ErrNoTemplateMatch is returned when no template is similar enough to a
+// query and there is no fallback
+var ErrNoTemplateMatch = errors.New("template: no template matches the query")
+
+// Candidate describes a template the selector may choose
+type Candidate struct {
+	Name        string   // Template name in the engine
+	Description string   // What the template is for
+	Examples    []string // Sample queries it should handle
+}
+
+// Match is a template scored against a query
+type Match struct {
+	Name  string
+	Score float64 // Highest cosine similarity to the description or an example
+}
+
+// SelectorConfig holds configuration for a Selector
+type SelectorConfig struct {
+	Embedder embedding.Embedder // Required
+
+	// Threshold is the lowest score accepted (0 accepts the best match)
+	Threshold float64
+
+	// Fallback is the template used when no candidate reaches Threshold;
+	// without one, Select returns ErrNoTemplateMatch
+	Fallback string
+}
+
+// Selector chooses the template for a query by embedding similarity, e.g. to
+// route a generic endpoint to specialized prompts
+type Selector struct {
+	engine *Engine
+	config SelectorConfig
+
+	mu         sync.RWMutex
+	candidates []selectorCandidate
+}
+
+type selectorCandidate struct {
+	name    string
+	vectors [][]float64
+}
+
+// NewSelector creates a selector over the templates of engine
+func NewSelector(engine *Engine, config SelectorConfig) *Selector {
+	return &Selector{engine: engine, config: config}
+}
+
+// Add embeds the descriptions and examples of candidates. Adding a name
+// again replaces it.
+func (s *Selector) Add(ctx context.Context, candidates ...Candidate) error {
+	if s.config.Embedder == nil {
+		return errors.New("template: selector embedder is required")
+	}
+
+	var texts []string
+	for _, c := range candidates {
+		if c.Description != "" {
+			texts = append(texts, c.Description)
+		}
+		texts = append(texts, c.Examples...)
+	}
+	if len(texts) == 0 {
+		return nil
+	}
+	vectors, err := s.config.Embedder.EmbedBatch(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("failed to embed candidates: %w", err)
+	}
+	if len(vectors) != len(texts) {
+		return fmt.Errorf("failed to embed candidates: got %d embeddings for %d texts", len(vectors), len(texts))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range candidates {
+		n := len(c.Examples)
+		if c.Description != "" {
+			n++
+		}
+		entry := selectorCandidate{name: c.Name, vectors: vectors[:n:n]}
+		vectors = vectors[n:]
+
+		i := s.index(c.Name)
+		if i < 0 {
+			s.candidates = append(s.candidates, entry)
+		} else {
+			s.candidates[i] = entry
+		}
+	}
+	return nil
+}
+
+// Remove drops a candidate
+func (s *Selector) Remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.index(name); i >= 0 {
+		s.candidates = append(s.candidates[:i], s.candidates[i+1:]...)
+	}
+}
+
+func (s *Selector) index(name string) int {
+	for i, c := range s.candidates {
+		if c.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Rank scores every candidate against query, best first
+func (s *Selector) Rank(ctx context.Context, query string) ([]Match, error) {
+	if s.config.Embedder == nil {
+		return nil, errors.New("template: selector embedder is required")
+	}
+	vector, err := s.config.Embedder.Embed(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	s.mu.RLock()
+	matches := make([]Match, 0, len(s.candidates))
+	for _, c := range s.candidates {
+		m := Match{Name: c.name}
+		for _, v := range c.vectors {
+			m.Score = max(m.Score, embedding.CosineSimilarity(vector, v))
+		}
+		matches = append(matches, m)
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	return matches, nil
+}
+
+// Select returns the best template for query, or the fallback when none
+// reaches the threshold
+func (s *Selector) Select(ctx context.Context, query string) (Match, error) {
+	matches, err := s.Rank(ctx, query)
+	if err != nil {
+		return Match{}, err
+	}
+	if len(matches) > 0 && matches[0].Score >= s.config.Threshold {
+		return matches[0], nil
+	}
+	if s.config.Fallback != "" {
+		return Match{Name: s.config.Fallback}, nil
+	}
+	return Match{}, ErrNoTemplateMatch
+}
+
+// Execute selects the template for query and executes it with data
+func (s *Selector) Execute(ctx context.Context, query string, data interface{}) (name, text string, err error) {
+	match, err := s.Select(ctx, query)
+	if err != nil {
+		return "", "", err
+	}
+	text, err = s.engine.Execute(match.Name, data)
+	return match.Name, text, err
+}
@@ -3,22 +3,33 @@ package template
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"strings"
 	"sync"
 	"text/template"
 )
 
-// Engine manages prompt templates
+// Engine manages prompt templates. Templates can include partials and other
+// templates of the engine with {{template "name" .}}.
 type Engine struct {
-	templates map[string]*template.Template
-	mu        sync.RWMutex
-	funcs     template.FuncMap
+	templates  map[string]*template.Template
+	partials   map[string]*template.Template
+	sources    map[*template.Template]string
+	compiled   map[string]*template.Template // Templates with their includes, by name
+	schemas    map[string]Schema
+	generation int
+	mu         sync.RWMutex
+	funcs      template.FuncMap
 }
 
 // NewEngine creates a new template engine
 func NewEngine() *Engine {
 	return &Engine{
 		templates: make(map[string]*template.Template),
+		partials:  make(map[string]*template.Template),
+		sources:   make(map[*template.Template]string),
+		compiled:  make(map[string]*template.Template),
+		schemas:   make(map[string]Schema),
 		funcs:     defaultFuncs(),
 	}
 }
@@ -43,6 +54,9 @@ func defaultFuncs() template.FuncMap {
 		"list": func(items ...interface{}) []interface{} {
 			return items
 		},
+		"languageName": LanguageName,
+		"formatNumber": FormatNumber,
+		"formatDate":   FormatDate,
 	}
 }
 
@@ -51,44 +65,130 @@ func (e *Engine) AddFunc(name string, fn interface{}) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	e.funcs[name] = fn
+	e.invalidate()
 }
 
-// Load loads a template from a string
+// Load loads a template from a string. The template may start with a
+// front-matter block declaring its variables (see ParseSchema):
+//
+//	---
+//	Name: string
+//	Age: int
+//	---
+//	Patient: {{.Name}}, {{.Age}}
 func (e *Engine) Load(name, content string) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	tmpl, err := template.New(name).Funcs(e.funcs).Parse(content)
+	schema, content, err := splitFrontMatter(content)
+	if err != nil {
+		return fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+	tmpl, err := e.parse(name, content)
 	if err != nil {
 		return fmt.Errorf("failed to parse template %s: %w", name, err)
 	}
 
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.templates[name] = tmpl
+	e.sources[tmpl] = content
+	if schema != nil {
+		e.schemas[name] = schema
+	}
+	e.invalidate()
 	return nil
 }
 
-// LoadFile loads a template from a file
-func (e *Engine) LoadFile(name, path string) error {
+// SetSchema declares the variables of a template, replacing any front-matter
+// declaration. Execute then fails with a *ValidationError instead of
+// rendering "<no value>" for missing variables.
+func (e *Engine) SetSchema(name string, schema Schema) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	e.schemas[name] = schema
+}
+
+// Schema returns the declared variables of a template
+func (e *Engine) Schema(name string) (Schema, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	schema, ok := e.schemas[name]
+	return schema, ok
+}
+
+// Validate checks data against the declared variables of a template
+func (e *Engine) Validate(name string, data interface{}) error {
+	schema, ok := e.Schema(name)
+	if !ok {
+		return nil
+	}
+	if err := schema.Validate(data); err != nil {
+		err.(*ValidationError).Template = name
+		return err
+	}
+	return nil
+}
 
-	tmpl, err := template.New(name).Funcs(e.funcs).ParseFiles(path)
+// LoadFile loads a template from a file
+func (e *Engine) LoadFile(name, path string) error {
+	content, err := os.ReadFile(path)
 	if err != nil {
 		return fmt.Errorf("failed to load template file %s: %w", path, err)
 	}
+	return e.Load(name, string(content))
+}
 
-	e.templates[name] = tmpl
+// LoadPartial registers a shared snippet, such as a safety footer or
+// formatting rules, that templates include with {{template "name" .}}.
+// A partial may also {{define}} several named snippets.
+func (e *Engine) LoadPartial(name, content string) error {
+	tmpl, err := e.parse(name, content)
+	if err != nil {
+		return fmt.Errorf("failed to parse partial %s: %w", name, err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.partials[name] = tmpl
+	e.sources[tmpl] = content
+	e.invalidate()
 	return nil
 }
 
-// Execute executes a template with the given data
-func (e *Engine) Execute(name string, data interface{}) (string, error) {
+// LoadPartialFile registers a partial from a file
+func (e *Engine) LoadPartialFile(name, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to load partial file %s: %w", path, err)
+	}
+	return e.LoadPartial(name, string(content))
+}
+
+// Partials returns all registered partial names
+func (e *Engine) Partials() []string {
 	e.mu.RLock()
-	tmpl, ok := e.templates[name]
-	e.mu.RUnlock()
+	defer e.mu.RUnlock()
 
-	if !ok {
-		return "", fmt.Errorf("template %s not found", name)
+	names := make([]string, 0, len(e.partials))
+	for name := range e.partials {
+		names = append(names, name)
+	}
+	return names
+}
+
+// parse parses one template on its own
+func (e *Engine) parse(name, content string) (*template.Template, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return template.New(name).Funcs(e.funcs).Parse(content)
+}
+
+// Execute executes a template with the given data
+func (e *Engine) Execute(name string, data interface{}) (string, error) {
+	tmpl, err := e.compiledTemplate(name)
+	if err != nil {
+		return "", err
+	}
+	if err := e.Validate(name, data); err != nil {
+		return "", err
 	}
 
 	var buf bytes.Buffer
@@ -99,13 +199,29 @@ func (e *Engine) Execute(name string, data interface{}) (string, error) {
 	return buf.String(), nil
 }
 
-// ExecuteString executes a template string directly (without registration)
+// ExecuteString executes a template string directly (without registration).
+// Variables declared in front-matter are validated.
 func (e *Engine) ExecuteString(content string, data interface{}) (string, error) {
-	tmpl, err := template.New("inline").Funcs(e.funcs).Parse(content)
+	schema, content, err := splitFrontMatter(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse inline template: %w", err)
+	}
+	if err := schema.Validate(data); err != nil {
+		return "", err
+	}
+
+	inline, err := e.parse("inline", content)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse inline template: %w", err)
 	}
 
+	e.mu.RLock()
+	tmpl, err := e.compile(inline, content)
+	e.mu.RUnlock()
+	if err != nil {
+		return "", err
+	}
+
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, data); err != nil {
 		return "", fmt.Errorf("failed to execute inline template: %w", err)
@@ -138,14 +254,30 @@ func (e *Engine) Names() []string {
 func (e *Engine) Delete(name string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	delete(e.sources, e.templates[name])
 	delete(e.templates, name)
+	delete(e.schemas, name)
+	e.invalidate()
+}
+
+// DeletePartial removes a partial
+func (e *Engine) DeletePartial(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.sources, e.partials[name])
+	delete(e.partials, name)
+	e.invalidate()
 }
 
-// Clear removes all templates
+// Clear removes all templates and partials
 func (e *Engine) Clear() {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	e.templates = make(map[string]*template.Template)
+	e.partials = make(map[string]*template.Template)
+	e.sources = make(map[*template.Template]string)
+	e.schemas = make(map[string]Schema)
+	e.invalidate()
 }
 
 // Prompt is a convenience function to quickly execute a template string
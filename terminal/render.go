@@ -0,0 +1,469 @@
+// Package terminal renders streamed markdown replies to a terminal with ANSI
+// styling, for CLI chats. Text is written as it arrives; only the few
+// characters whose meaning depends on what follows (a "*" that may become
+// "**", the start of a line that may be a list item) are held back.
+//
+//	stream, err := client.Stream(ctx, req)
+//	resp, err := terminal.NewRendererSimple(os.Stdout).Render(stream)
+package terminal
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/medatechnology/simpleai"
+)
+
+// Styles are the ANSI escape sequences used for each markdown element
+type Styles struct {
+	Heading   string
+	Bold      string
+	Italic    string
+	Code      string // Inline code
+	CodeBlock string // Fenced code and its language label
+	Quote     string
+	Bullet    string // List bullets and numbers
+	Rule      string // Horizontal rules
+}
+
+// DefaultStyles returns styles that read well on dark and light terminals
+func DefaultStyles() Styles {
+	return Styles{
+		Heading:   "\x1b[1;35m",
+		Bold:      "\x1b[1m",
+		Italic:    "\x1b[3m",
+		Code:      "\x1b[36m",
+		CodeBlock: "\x1b[36m",
+		Quote:     "\x1b[2;3m",
+		Bullet:    "\x1b[33m",
+		Rule:      "\x1b[2m",
+	}
+}
+
+// RendererConfig holds configuration for a Renderer
+type RendererConfig struct {
+	Styles Styles
+
+	// Plain writes no escape sequences: markdown markers are still removed
+	// and bullets drawn, for terminals without color or log files
+	Plain bool
+
+	RuleWidth int // Width of horizontal rules (default 40)
+}
+
+// DefaultRendererConfig returns sensible defaults. Plain is set when the
+// NO_COLOR environment variable is set or TERM is "dumb".
+func DefaultRendererConfig() RendererConfig {
+	return RendererConfig{
+		Styles:    DefaultStyles(),
+		Plain:     os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb",
+		RuleWidth: 40,
+	}
+}
+
+// lineKind is the block element of the current line
+type lineKind int
+
+const (
+	lineText lineKind = iota
+	lineHeading
+	lineQuote
+	lineCode
+)
+
+// Renderer turns markdown text into styled terminal output as it is
+// written. It implements io.Writer and is safe for concurrent use.
+type Renderer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	config RendererConfig
+
+	pending   string // Text whose meaning depends on what follows
+	lineStart bool
+	line      lineKind
+	inFence   bool
+	bold      bool
+	italic    bool
+	code      bool
+	styled    bool // An escape sequence is active
+	prev      byte // Last character written
+	err       error
+}
+
+// NewRenderer creates a renderer writing to w
+func NewRenderer(w io.Writer, config RendererConfig) *Renderer {
+	if config.Styles == (Styles{}) {
+		config.Styles = DefaultStyles()
+	}
+	if config.RuleWidth <= 0 {
+		config.RuleWidth = 40
+	}
+	return &Renderer{w: w, config: config, lineStart: true}
+}
+
+// NewRendererSimple creates a renderer writing to w with default settings
+func NewRendererSimple(w io.Writer) *Renderer {
+	return NewRenderer(w, DefaultRendererConfig())
+}
+
+// Write renders markdown text
+func (r *Renderer) Write(p []byte) (int, error) {
+	return len(p), r.WriteString(string(p))
+}
+
+// WriteString renders markdown text
+func (r *Renderer) WriteString(text string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending += text
+	r.process(false)
+	return r.err
+}
+
+// Flush writes any text held back and resets the terminal style. Call it
+// when the reply ends; unclosed styles don't leak into later output.
+func (r *Renderer) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.process(true)
+	r.bold, r.italic, r.code, r.inFence = false, false, false, false
+	r.line = lineText
+	r.apply()
+	return r.err
+}
+
+// Render writes the content of stream as it arrives, flushes, and returns
+// the assembled response as simpleai.CollectStream does
+func (r *Renderer) Render(stream <-chan simpleai.StreamEvent) (*simpleai.Response, error) {
+	tee := make(chan simpleai.StreamEvent)
+	go func() {
+		defer close(tee)
+		for event := range stream {
+			if event.Content != "" {
+				r.WriteString(event.Content)
+			}
+			tee <- event
+		}
+	}()
+	resp, err := simpleai.CollectStream(tee)
+	if flushErr := r.Flush(); err == nil {
+		err = flushErr
+	}
+	return resp, err
+}
+
+// process renders as much of the pending text as can be decided; final
+// renders all of it
+func (r *Renderer) process(final bool) {
+	for r.pending != "" && r.err == nil {
+		if r.lineStart {
+			if !r.block(final) {
+				return
+			}
+			continue
+		}
+
+		if r.line == lineCode {
+			i := strings.IndexByte(r.pending, '\n')
+			if i < 0 {
+				r.text(r.pending)
+				r.pending = ""
+				return
+			}
+			r.text(r.pending[:i])
+			r.newline()
+			r.pending = r.pending[i+1:]
+			continue
+		}
+
+		switch c := r.pending[0]; c {
+		case '\n':
+			r.newline()
+			r.pending = r.pending[1:]
+
+		case '`':
+			r.code = !r.code
+			r.apply()
+			r.pending = r.pending[1:]
+
+		case '\\':
+			if len(r.pending) == 1 {
+				if !final {
+					return
+				}
+				r.text("\\")
+				r.pending = ""
+				continue
+			}
+			if r.code || r.pending[1] == '\n' {
+				r.text("\\")
+				r.pending = r.pending[1:]
+				continue
+			}
+			r.text(r.pending[1:2])
+			r.pending = r.pending[2:]
+
+		case '*', '_':
+			if r.code {
+				r.text(r.pending[:1])
+				r.pending = r.pending[1:]
+				continue
+			}
+			if len(r.pending) == 1 && !final {
+				return // May be the first half of "**"
+			}
+			next := byte(' ')
+			if len(r.pending) > 1 {
+				next = r.pending[1]
+			}
+			if next == c {
+				r.bold = !r.bold
+				r.apply()
+				r.pending = r.pending[2:]
+				continue
+			}
+			if r.emphasis(c, next) {
+				r.italic = !r.italic
+				r.apply()
+			} else {
+				r.text(r.pending[:1])
+			}
+			r.pending = r.pending[1:]
+
+		default:
+			i := strings.IndexAny(r.pending, "\n`\\*_")
+			if i < 0 {
+				i = len(r.pending)
+			}
+			r.text(r.pending[:i])
+			r.pending = r.pending[i:]
+		}
+	}
+}
+
+// emphasis reports whether a single marker c, followed by next, opens or
+// closes italics rather than being a literal (as in "2 * 3" or "snake_case")
+func (r *Renderer) emphasis(c, next byte) bool {
+	if r.italic {
+		return r.prev != ' ' && (c == '*' || !isWordByte(next))
+	}
+	return next != ' ' && next != '\n' && (c == '*' || !isWordByte(r.prev))
+}
+
+// block reads the block markers at the start of a line. It returns false
+// if more text is needed to decide.
+func (r *Renderer) block(final bool) bool {
+	line, complete := r.pending, final
+	if i := strings.IndexByte(r.pending, '\n'); i >= 0 {
+		line, complete = r.pending[:i], true
+	}
+	trimmed := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(trimmed)]
+
+	if !complete && undecided(trimmed) {
+		return false
+	}
+
+	consumeLine := func() {
+		r.pending = strings.TrimPrefix(r.pending[len(line):], "\n")
+	}
+	styles := r.config.Styles
+
+	fence := strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+	if fence && !complete {
+		return false // Wait for the language
+	}
+
+	if r.inFence {
+		if fence {
+			r.inFence = false
+			consumeLine()
+			return true
+		}
+		r.line = lineCode
+		r.lineStart = false
+		r.apply()
+		return true
+	}
+
+	switch {
+	case fence:
+		r.inFence = true
+		if lang := strings.TrimSpace(trimmed[3:]); lang != "" {
+			r.styledText(styles.CodeBlock, "["+lang+"]")
+			r.raw("\n")
+		}
+		consumeLine()
+		return true
+
+	case isRule(trimmed):
+		r.styledText(styles.Rule, strings.Repeat("─", r.config.RuleWidth))
+		r.raw("\n")
+		consumeLine()
+		return true
+	}
+
+	r.lineStart = false
+	r.line = lineText
+	rest := trimmed
+	switch {
+	case headingLevel(trimmed) > 0:
+		r.line = lineHeading
+		rest = trimmed[headingLevel(trimmed)+1:]
+
+	case len(trimmed) >= 2 && strings.IndexByte("-*+", trimmed[0]) >= 0 && trimmed[1] == ' ':
+		r.raw(indent)
+		r.styledText(styles.Bullet, "•")
+		r.raw(" ")
+		rest = trimmed[2:]
+
+	case listNumber(trimmed) > 0:
+		n := listNumber(trimmed)
+		r.raw(indent)
+		r.styledText(styles.Bullet, trimmed[:n])
+		r.raw(" ")
+		rest = trimmed[n+1:]
+
+	case strings.HasPrefix(trimmed, ">"):
+		r.line = lineQuote
+		r.styledText(styles.Quote, "│ ")
+		rest = strings.TrimPrefix(trimmed[1:], " ")
+
+	default:
+		rest = line
+	}
+	r.pending = r.pending[len(line)-len(rest):]
+	r.apply()
+	return true
+}
+
+// undecided reports whether an unfinished line start may still turn out
+// to be a block marker
+func undecided(trimmed string) bool {
+	if trimmed == "" {
+		return true
+	}
+	if strings.Trim(trimmed, "-*_ ") == "" || strings.Trim(trimmed, "#") == "" {
+		return true // A rule or heading marker so far
+	}
+	if digits := strings.TrimLeft(trimmed, "0123456789"); len(trimmed) < 11 && (digits == "" || digits == "." || digits == ")") {
+		return true // A list number so far
+	}
+	switch trimmed[0] {
+	case '`', '~':
+		return len(trimmed) < 3
+	case '-', '*', '+', '>', '#':
+		return len(trimmed) < 2
+	}
+	return false
+}
+
+// headingLevel returns the number of # of an ATX heading, or 0
+func headingLevel(line string) int {
+	n := 0
+	for n < len(line) && line[n] == '#' {
+		n++
+	}
+	if n == 0 || n > 6 || n >= len(line) || line[n] != ' ' {
+		return 0
+	}
+	return n
+}
+
+// listNumber returns the length of an ordered list marker such as "12.",
+// or 0
+func listNumber(line string) int {
+	n := 0
+	for n < len(line) && line[n] >= '0' && line[n] <= '9' {
+		n++
+	}
+	if n == 0 || n > 9 || n+1 >= len(line) || (line[n] != '.' && line[n] != ')') || line[n+1] != ' ' {
+		return 0
+	}
+	return n + 1
+}
+
+// isRule reports whether a line is a horizontal rule such as "---" or "* * *"
+func isRule(line string) bool {
+	marks := strings.ReplaceAll(strings.TrimSpace(line), " ", "")
+	if len(marks) < 3 {
+		return false
+	}
+	return strings.Trim(marks, marks[:1]) == "" && strings.IndexByte("-*_", marks[0]) >= 0
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// newline ends the current line; inline styles don't carry over
+func (r *Renderer) newline() {
+	r.bold, r.italic, r.code = false, false, false
+	r.line = lineText
+	r.apply()
+	r.raw("\n")
+	r.lineStart = true
+	r.prev = ' '
+}
+
+// apply switches the terminal to the current style
+func (r *Renderer) apply() {
+	if r.config.Plain {
+		return
+	}
+	styles := r.config.Styles
+	var codes strings.Builder
+	switch r.line {
+	case lineHeading:
+		codes.WriteString(styles.Heading)
+	case lineQuote:
+		codes.WriteString(styles.Quote)
+	case lineCode:
+		codes.WriteString(styles.CodeBlock)
+	}
+	if r.bold {
+		codes.WriteString(styles.Bold)
+	}
+	if r.italic {
+		codes.WriteString(styles.Italic)
+	}
+	if r.code {
+		codes.WriteString(styles.Code)
+	}
+	if r.styled {
+		r.raw("\x1b[0m")
+	}
+	r.raw(codes.String())
+	r.styled = codes.Len() > 0
+}
+
+// styledText writes text in style, then restores the current style
+func (r *Renderer) styledText(style, text string) {
+	if r.config.Plain {
+		r.raw(text)
+		return
+	}
+	if r.styled {
+		r.raw("\x1b[0m")
+	}
+	r.raw(style + text + "\x1b[0m")
+	r.styled = false
+}
+
+// text writes content
+func (r *Renderer) text(s string) {
+	if s == "" {
+		return
+	}
+	r.raw(s)
+	r.prev = s[len(s)-1]
+}
+
+func (r *Renderer) raw(s string) {
+	if s == "" || r.err != nil {
+		return
+	}
+	_, r.err = io.WriteString(r.w, s)
+}
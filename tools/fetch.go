@@ -0,0 +1,624 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode"
+
+	"golang.org/x/net/html"
+
+	"github.com/medatechnology/simpleai/agent"
+	"github.com/medatechnology/simpleai/contextbuilder"
+)
+
+// Fetch errors
+var (
+	ErrDomainNotAllowed  = errors.New("tools: domain is not allowed")
+	ErrPrivateAddress    = errors.New("tools: private and local addresses cannot be fetched")
+	ErrRobotsDisallowed  = errors.New("tools: robots.txt disallows this URL")
+	ErrUnsupportedScheme = errors.New("tools: only http and https URLs can be fetched")
+)
+
+// Page is the readable content of a fetched URL
+type Page struct {
+	URL       string `json:"url"` // After redirects
+	Title     string `json:"title,omitempty"`
+	Text      string `json:"text"`
+	Truncated bool   `json:"truncated,omitempty"` // Text was cut to MaxTokens
+}
+
+// FetchConfig holds configuration for a Fetcher
+type FetchConfig struct {
+	// AllowedDomains restricts fetching to these domains and their
+	// subdomains (empty = any domain not blocked)
+	AllowedDomains []string
+	BlockedDomains []string
+
+	// AllowPrivateNetworks lets URLs reach loopback, link-local (including
+	// cloud metadata endpoints), private and unspecified addresses. By
+	// default they are refused after DNS resolution, on redirects too, and
+	// proxy environment variables are ignored so the check can't be
+	// bypassed.
+	AllowPrivateNetworks bool
+
+	MaxTokens    int              // Budget for the page text (default 4000)
+	TokenCounter func(string) int // Default contextbuilder.EstimateTokens
+	MaxBytes     int64            // Largest response read (default 2MB)
+
+	UserAgent    string        // Also the robots.txt user agent
+	IgnoreRobots bool          // Fetch pages robots.txt disallows
+	Timeout      time.Duration // Per request
+	CacheTTL     time.Duration // Pages and robots.txt files are cached this long (0 = no page cache)
+}
+
+// DefaultFetchConfig returns sensible defaults
+func DefaultFetchConfig() FetchConfig {
+	return FetchConfig{
+		MaxTokens: 4000,
+		MaxBytes:  2 << 20,
+		UserAgent: "simpleai-fetch/1.0",
+		Timeout:   20 * time.Second,
+		CacheTTL:  15 * time.Minute,
+	}
+}
+
+// Fetcher downloads pages for agents and reduces them to readable text
+type Fetcher struct {
+	config FetchConfig
+	client *http.Client
+
+	mu     sync.Mutex
+	pages  map[string]cachedPage
+	robots map[string]cachedRobots
+}
+
+type cachedPage struct {
+	page    Page
+	expires time.Time
+}
+
+type cachedRobots struct {
+	rules   robotsRules
+	expires time.Time
+}
+
+// NewFetcher creates a fetcher
+func NewFetcher(config FetchConfig) *Fetcher {
+	defaults := DefaultFetchConfig()
+	if config.MaxTokens <= 0 {
+		config.MaxTokens = defaults.MaxTokens
+	}
+	if config.TokenCounter == nil {
+		config.TokenCounter = contextbuilder.EstimateTokens
+	}
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = defaults.MaxBytes
+	}
+	if config.UserAgent == "" {
+		config.UserAgent = defaults.UserAgent
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+
+	f := &Fetcher{
+		config: config,
+		pages:  make(map[string]cachedPage),
+		robots: make(map[string]cachedRobots),
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !config.AllowPrivateNetworks {
+		transport.Proxy = nil
+		transport.DialContext = (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   refusePrivateAddress,
+		}).DialContext
+	}
+	f.client = &http.Client{
+		Timeout:   config.Timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			// Redirects must stay within the allowlist
+			return f.checkDomain(req.URL)
+		},
+	}
+	return f
+}
+
+// NewFetcherSimple creates a fetcher limited to the given domains
+func NewFetcherSimple(allowedDomains ...string) *Fetcher {
+	config := DefaultFetchConfig()
+	config.AllowedDomains = allowedDomains
+	return NewFetcher(config)
+}
+
+// Fetch downloads rawURL and returns its readable text, cut to MaxTokens
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Page, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Page{}, fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Page{}, ErrUnsupportedScheme
+	}
+	if err := f.checkDomain(u); err != nil {
+		return Page{}, err
+	}
+	u.Fragment = ""
+	key := u.String()
+
+	if page, ok := f.cachedPage(key); ok {
+		return page, nil
+	}
+	if !f.config.IgnoreRobots {
+		rules, err := f.robotsRules(ctx, u)
+		if err != nil {
+			return Page{}, err
+		}
+		if !rules.allowed(u.RequestURI()) {
+			return Page{}, fmt.Errorf("%w: %s", ErrRobotsDisallowed, key)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return Page{}, err
+	}
+	req.Header.Set("User-Agent", f.config.UserAgent)
+	req.Header.Set("Accept", "text/html, text/plain;q=0.9, */*;q=0.5")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Page{}, fmt.Errorf("fetch failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Page{}, fmt.Errorf("fetch failed with status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.config.MaxBytes))
+	if err != nil {
+		return Page{}, fmt.Errorf("fetch failed: %w", err)
+	}
+
+	page := Page{URL: resp.Request.URL.String()}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml" || mediaType == "":
+		page.Title, page.Text = readable(string(body))
+	case strings.HasPrefix(mediaType, "text/") || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		page.Text = strings.TrimSpace(string(body))
+	default:
+		return Page{}, fmt.Errorf("tools: cannot read %s content", mediaType)
+	}
+
+	built := contextbuilder.New(f.config.MaxTokens, f.config.TokenCounter).
+		AddText("page", page.Text, 0, contextbuilder.TruncateEnd).
+		Build()
+	page.Text, page.Truncated = built.Text(), built.Truncated
+
+	f.storePage(key, page)
+	return page, nil
+}
+
+// Tool returns the fetcher as an agent tool named "fetch_url"
+func (f *Fetcher) Tool() agent.Tool {
+	description := "Download a web page and return its main text without navigation or ads. Use it to read pages found by a search."
+	if len(f.config.AllowedDomains) > 0 {
+		description += " Only these domains can be fetched: " + strings.Join(f.config.AllowedDomains, ", ") + "."
+	}
+	return agent.NewTool("fetch_url", description, map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"url": map[string]any{"type": "string", "description": "Absolute http or https URL"},
+		},
+		"required": []string{"url"},
+	}, func(ctx context.Context, args json.RawMessage) (string, error) {
+		var input struct {
+			URL string `json:"url"`
+		}
+		if err := json.Unmarshal(args, &input); err != nil {
+			return "", err
+		}
+		page, err := f.Fetch(ctx, input.URL)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(page)
+		return string(data), err
+	})
+}
+
+// checkDomain applies the allow and block lists
+func (f *Fetcher) checkDomain(u *url.URL) error {
+	host := strings.ToLower(u.Hostname())
+	matches := func(domains []string) bool {
+		return slices.ContainsFunc(domains, func(d string) bool {
+			d = strings.ToLower(strings.TrimPrefix(d, "."))
+			return host == d || strings.HasSuffix(host, "."+d)
+		})
+	}
+	if matches(f.config.BlockedDomains) ||
+		(len(f.config.AllowedDomains) > 0 && !matches(f.config.AllowedDomains)) {
+		return fmt.Errorf("%w: %s", ErrDomainNotAllowed, host)
+	}
+	return nil
+}
+
+// Special-purpose ranges that netip's predicates don't cover but that can
+// still reach the host's networks
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "This network"
+	netip.MustParsePrefix("100.64.0.0/10"),   // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),   // Benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),     // Reserved, and broadcast
+	netip.MustParsePrefix("::/96"),           // IPv4-compatible
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // Local-use NAT64
+	netip.MustParsePrefix("::ffff:0:0:0/96"), // IPv4-translated
+}
+
+// refusePrivateAddress is a dialer control that refuses connections to
+// addresses inside the host's networks. It runs after DNS resolution, so
+// hostnames that resolve to such addresses are caught.
+func refusePrivateAddress(network, address string, conn syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, address)
+	}
+	if ip := addrPort.Addr(); isPrivateAddress(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, ip)
+	}
+	return nil
+}
+
+// isPrivateAddress reports whether ip, or the IPv4 address it maps, is not
+// a public unicast address
+func isPrivateAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() {
+		return true
+	}
+	return slices.ContainsFunc(reservedPrefixes, func(p netip.Prefix) bool {
+		return p.Contains(ip)
+	})
+}
+
+func (f *Fetcher) cachedPage(key string) (Page, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entry, ok := f.pages[key]
+	if !ok || time.Now().After(entry.expires) {
+		delete(f.pages, key)
+		return Page{}, false
+	}
+	return entry.page, true
+}
+
+func (f *Fetcher) storePage(key string, page Page) {
+	if f.config.CacheTTL <= 0 {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	for k, entry := range f.pages {
+		if now.After(entry.expires) {
+			delete(f.pages, k)
+		}
+	}
+	f.pages[key] = cachedPage{page: page, expires: now.Add(f.config.CacheTTL)}
+}
+
+// robotsRules returns the robots.txt rules of u's host for our user agent.
+// A missing robots.txt allows everything; an unreachable one is an error.
+func (f *Fetcher) robotsRules(ctx context.Context, u *url.URL) (robotsRules, error) {
+	origin := u.Scheme + "://" + u.Host
+	f.mu.Lock()
+	entry, ok := f.robots[origin]
+	f.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.rules, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return robotsRules{}, err
+	}
+	req.Header.Set("User-Agent", f.config.UserAgent)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return robotsRules{}, fmt.Errorf("failed to read robots.txt: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Other statuses (e.g. 404) mean no restrictions
+	var rules robotsRules
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512<<10))
+		rules = parseRobots(string(body), f.config.UserAgent)
+	case resp.StatusCode >= 500:
+		return robotsRules{}, fmt.Errorf("failed to read robots.txt: status %d", resp.StatusCode)
+	}
+
+	ttl := f.config.CacheTTL
+	if ttl <= 0 {
+		ttl = DefaultFetchConfig().CacheTTL
+	}
+	f.mu.Lock()
+	f.robots[origin] = cachedRobots{rules: rules, expires: time.Now().Add(ttl)}
+	f.mu.Unlock()
+	return rules, nil
+}
+
+// robotsRules are the Allow and Disallow lines of one robots.txt group
+type robotsRules struct {
+	allow    []string
+	disallow []string
+}
+
+// allowed applies the longest matching rule; Allow wins ties
+func (r robotsRules) allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	longest := func(rules []string) int {
+		n := -1
+		for _, rule := range rules {
+			if robotsMatch(rule, path) {
+				n = max(n, len(rule))
+			}
+		}
+		return n
+	}
+	disallow := longest(r.disallow)
+	return disallow < 0 || longest(r.allow) >= disallow
+}
+
+// robotsMatch matches a robots.txt path pattern with * and $ wildcards
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	parts := strings.Split(strings.TrimSuffix(pattern, "$"), "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		j := strings.Index(rest, part)
+		if j < 0 {
+			return false
+		}
+		rest = rest[j+len(part):]
+	}
+	return !anchored || rest == ""
+}
+
+// parseRobots picks the group for userAgent, falling back to "*"
+func parseRobots(text, userAgent string) robotsRules {
+	agent := strings.ToLower(userAgent)
+	if name, _, ok := strings.Cut(agent, "/"); ok {
+		agent = name
+	}
+
+	groups := make(map[string]*robotsRules)
+	var current []string // User agents of the group being read
+	readingAgents := false
+	for _, line := range strings.Split(text, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !readingAgents {
+				current = nil
+			}
+			readingAgents = true
+			name := strings.ToLower(value)
+			current = append(current, name)
+			if groups[name] == nil {
+				groups[name] = &robotsRules{}
+			}
+		case "allow", "disallow":
+			readingAgents = false
+			if value == "" {
+				continue // "Disallow:" allows everything
+			}
+			for _, name := range current {
+				if key == "allow" {
+					groups[name].allow = append(groups[name].allow, value)
+				} else {
+					groups[name].disallow = append(groups[name].disallow, value)
+				}
+			}
+		default:
+			readingAgents = false
+		}
+	}
+
+	// The most specific group wins, as RFC 9309 applies the longest match
+	best := ""
+	for name := range groups {
+		if name != "*" && strings.Contains(agent, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		best = "*"
+	}
+	if rules, ok := groups[best]; ok {
+		return *rules
+	}
+	return robotsRules{}
+}
+
+// Elements that never hold the main content
+var boilerplateTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "svg": true,
+	"iframe": true, "form": true, "button": true, "nav": true, "header": true,
+	"footer": true, "aside": true, "head": true,
+}
+
+// Words in the classes and ids of navigation, ads and other page chrome,
+// matched against whole words so "subheader" or "canvas" don't count
+var boilerplateHints = map[string]bool{
+	"nav": true, "navbar": true, "navigation": true, "menu": true, "sidebar": true,
+	"footer": true, "header": true, "cookie": true, "cookies": true, "banner": true,
+	"breadcrumb": true, "breadcrumbs": true, "share": true, "social": true,
+	"related": true, "comment": true, "comments": true, "ad": true, "ads": true,
+	"advert": true, "advertisement": true, "promo": true, "newsletter": true, "popup": true,
+}
+
+var blockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true, "br": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"li": true, "tr": true, "pre": true, "blockquote": true, "table": true,
+	"ul": true, "ol": true, "dl": true, "dt": true, "dd": true, "figcaption": true,
+}
+
+// readable extracts the title and main text of an HTML page, preferring its
+// <article> or <main> element and dropping navigation and other chrome
+func readable(document string) (title, text string) {
+	root, err := html.Parse(strings.NewReader(document))
+	if err != nil {
+		return "", ""
+	}
+	if t := findElement(root, "title"); t != nil {
+		title = strings.Join(strings.Fields(textContent(t)), " ")
+	}
+
+	content := root
+	for _, tag := range []string{"article", "main"} {
+		if n := findElement(root, tag); n != nil {
+			content = n
+			break
+		}
+	}
+	if body := findElement(root, "body"); content == root && body != nil {
+		content = body
+	}
+
+	var b strings.Builder
+	writeText(&b, content, content)
+	return title, collapseLines(b.String())
+}
+
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+// writeText writes the text under n, skipping boilerplate below root and
+// breaking lines at block elements
+func writeText(b *strings.Builder, n, root *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(n.Data)
+		return
+	case html.CommentNode:
+		return
+	case html.ElementNode:
+		if n != root && isBoilerplate(n) {
+			return
+		}
+	}
+
+	block := n.Type == html.ElementNode && blockTags[n.Data]
+	if block {
+		b.WriteString("\n")
+	}
+	if n.Type == html.ElementNode && n.Data == "li" {
+		b.WriteString("- ")
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeText(b, c, root)
+	}
+	if block {
+		b.WriteString("\n")
+	} else if n.Type == html.ElementNode && (n.Data == "td" || n.Data == "th") {
+		b.WriteString(" | ")
+	}
+}
+
+func isBoilerplate(n *html.Node) bool {
+	if boilerplateTags[n.Data] {
+		return true
+	}
+	for _, attr := range n.Attr {
+		switch attr.Key {
+		case "hidden":
+			return true
+		case "aria-hidden":
+			if attr.Val == "true" {
+				return true
+			}
+		case "role":
+			if attr.Val == "navigation" || attr.Val == "banner" || attr.Val == "contentinfo" {
+				return true
+			}
+		case "class", "id":
+			// "site-header main_nav" has the words site, header, main and nav
+			words := strings.FieldsFunc(strings.ToLower(attr.Val), func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			})
+			if slices.ContainsFunc(words, func(w string) bool { return boilerplateHints[w] }) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// collapseLines squeezes whitespace within lines and drops empty ones
+func collapseLines(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" && line != "-" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,383 @@
+// Package tools provides built-in agent tools
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/medatechnology/simpleai/agent"
+)
+
+// Language is a language the interpreter can run
+type Language string
+
+const (
+	Go         Language = "go"
+	JavaScript Language = "javascript"
+)
+
+// ErrUnsupportedLanguage is returned for languages without a runner
+var ErrUnsupportedLanguage = errors.New("tools: unsupported language")
+
+// goRuntimeReserve is added to the address-space limit of Go programs, whose
+// runtime reserves virtual memory it never touches
+const goRuntimeReserve = 1 << 30
+
+// Result is the outcome of running code
+type Result struct {
+	Stdout    string        `json:"stdout"`
+	Stderr    string        `json:"stderr,omitempty"`
+	ExitCode  int           `json:"exit_code"`
+	Duration  time.Duration `json:"duration"`
+	TimedOut  bool          `json:"timed_out,omitempty"`
+	Truncated bool          `json:"truncated,omitempty"` // Output exceeded MaxOutput
+}
+
+// Runner executes code in one language. Implement it to run code in a
+// sandbox, such as a container, a WASM runtime or an embedded interpreter.
+type Runner interface {
+	Run(ctx context.Context, code string, limits Limits) (Result, error)
+}
+
+// RunnerFunc adapts a function to a Runner
+type RunnerFunc func(ctx context.Context, code string, limits Limits) (Result, error)
+
+// Run calls f
+func (f RunnerFunc) Run(ctx context.Context, code string, limits Limits) (Result, error) {
+	return f(ctx, code, limits)
+}
+
+// Limits bound a single run
+type Limits struct {
+	CPUTime     time.Duration // CPU seconds, enforced with ulimit -t
+	MemoryLimit int64         // Bytes
+	MaxOutput   int           // Bytes kept per stream
+}
+
+// InterpreterConfig holds configuration for an Interpreter
+type InterpreterConfig struct {
+	// Languages the tool offers (default: every language with a runner)
+	Languages []Language
+
+	// UnsafeHostProcesses adds Go and JavaScript runners, when their
+	// toolchains are installed, that run programs as host processes. They
+	// are limited but NOT sandboxed: programs can reach the network and any
+	// file the host user can. Off by default; enable it only for trusted
+	// code, or when the host is itself a sandbox such as a throwaway
+	// container.
+	UnsafeHostProcesses bool
+
+	Timeout     time.Duration // Wall-clock limit per run, including compilation
+	CPUTime     time.Duration
+	MemoryLimit int64 // Bytes
+	MaxOutput   int   // Bytes of stdout and of stderr returned
+
+	GoBinary   string // Default "go" from PATH
+	NodeBinary string // Default "node" from PATH
+
+	// Env is passed to programs; the host environment is not, apart from
+	// PATH
+	Env []string
+
+	// Runners provide languages, e.g. a container or WASM-backed runner;
+	// they replace host runners of the same language
+	Runners map[Language]Runner
+}
+
+// DefaultInterpreterConfig returns sensible defaults
+func DefaultInterpreterConfig() InterpreterConfig {
+	return InterpreterConfig{
+		Timeout:     30 * time.Second,
+		CPUTime:     10 * time.Second,
+		MemoryLimit: 512 << 20,
+		MaxOutput:   16 << 10,
+		GoBinary:    "go",
+		NodeBinary:  "node",
+	}
+}
+
+// Interpreter runs model-written code through Runners with time and output
+// limits. It has no languages unless given Runners or UnsafeHostProcesses.
+type Interpreter struct {
+	config  InterpreterConfig
+	runners map[Language]Runner
+}
+
+// NewInterpreter creates an interpreter; with UnsafeHostProcesses, languages
+// whose toolchain is missing are left out
+func NewInterpreter(config InterpreterConfig) *Interpreter {
+	defaults := DefaultInterpreterConfig()
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.CPUTime <= 0 {
+		config.CPUTime = defaults.CPUTime
+	}
+	if config.MemoryLimit <= 0 {
+		config.MemoryLimit = defaults.MemoryLimit
+	}
+	if config.MaxOutput <= 0 {
+		config.MaxOutput = defaults.MaxOutput
+	}
+	if config.GoBinary == "" {
+		config.GoBinary = defaults.GoBinary
+	}
+	if config.NodeBinary == "" {
+		config.NodeBinary = defaults.NodeBinary
+	}
+
+	i := &Interpreter{config: config, runners: make(map[Language]Runner)}
+	if config.UnsafeHostProcesses {
+		if _, err := exec.LookPath(config.GoBinary); err == nil {
+			i.runners[Go] = RunnerFunc(i.runGoUnsafe)
+		}
+		if _, err := exec.LookPath(config.NodeBinary); err == nil {
+			i.runners[JavaScript] = RunnerFunc(i.runJavaScriptUnsafe)
+		}
+	}
+	for lang, runner := range config.Runners {
+		i.runners[lang] = runner
+	}
+	return i
+}
+
+// NewInterpreterSimple creates an interpreter for one language's runner
+// with default limits
+func NewInterpreterSimple(lang Language, runner Runner) *Interpreter {
+	config := DefaultInterpreterConfig()
+	config.Runners = map[Language]Runner{lang: runner}
+	return NewInterpreter(config)
+}
+
+// Languages returns the languages that can run, sorted
+func (i *Interpreter) Languages() []Language {
+	var langs []Language
+	for lang := range i.runners {
+		if len(i.config.Languages) == 0 || slices.Contains(i.config.Languages, lang) {
+			langs = append(langs, lang)
+		}
+	}
+	slices.Sort(langs)
+	return langs
+}
+
+// Run executes code and returns its captured output. A program that fails
+// or times out is not an error; its Result says so.
+func (i *Interpreter) Run(ctx context.Context, lang Language, code string) (Result, error) {
+	runner, ok := i.runners[lang]
+	if !ok || !slices.Contains(i.Languages(), lang) {
+		return Result{}, fmt.Errorf("%w: %s", ErrUnsupportedLanguage, lang)
+	}
+	ctx, cancel := context.WithTimeout(ctx, i.config.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	result, err := runner.Run(ctx, code, Limits{
+		CPUTime:     i.config.CPUTime,
+		MemoryLimit: i.config.MemoryLimit,
+		MaxOutput:   i.config.MaxOutput,
+	})
+	if result.Duration == 0 {
+		result.Duration = time.Since(start)
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.TimedOut = true
+	}
+	return result, err
+}
+
+// Tool returns the interpreter as an agent tool named "run_code". The model
+// receives the program's output, exit code and whether it timed out.
+func (i *Interpreter) Tool() agent.Tool {
+	langs := i.Languages()
+	names := make([]string, len(langs))
+	for n, lang := range langs {
+		names[n] = string(lang)
+	}
+
+	description := fmt.Sprintf("Run a short program and return its output. Use it for calculations and data analysis. "+
+		"Print results to stdout. Programs are stopped after %s.", i.config.Timeout)
+	if slices.Contains(langs, Go) {
+		description += " Go programs must be a complete main package using only the standard library."
+	}
+
+	return agent.NewTool("run_code", description, map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"language": map[string]any{"type": "string", "enum": names},
+			"code":     map[string]any{"type": "string", "description": "Complete program source"},
+		},
+		"required": []string{"language", "code"},
+	}, func(ctx context.Context, args json.RawMessage) (string, error) {
+		var input struct {
+			Language Language `json:"language"`
+			Code     string   `json:"code"`
+		}
+		if err := json.Unmarshal(args, &input); err != nil {
+			return "", err
+		}
+		result, err := i.Run(ctx, input.Language, input.Code)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(result)
+		return string(data), err
+	})
+}
+
+// runGoUnsafe compiles the program in a temporary directory and runs the
+// binary as an unsandboxed host process
+func (i *Interpreter) runGoUnsafe(ctx context.Context, code string, limits Limits) (Result, error) {
+	dir, err := os.MkdirTemp("", "simpleai-go-")
+	if err != nil {
+		return Result{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(code), 0o600); err != nil {
+		return Result{}, err
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+
+	build := exec.CommandContext(ctx, i.config.GoBinary, "build", "-o", "prog", "main.go")
+	build.Dir = dir
+	killProcessGroup(build)
+	build.Env = append(i.env(dir),
+		"GOCACHE="+filepath.Join(cacheDir, "simpleai-interpreter"),
+		"GOFLAGS=",
+		"GOPROXY=off",
+		"GOTOOLCHAIN=local",
+		"CGO_ENABLED=0",
+	)
+	var buildOutput bytes.Buffer
+	build.Stdout, build.Stderr = &buildOutput, &buildOutput
+	err = build.Run()
+	reapProcessGroup(build)
+	if err != nil {
+		if ctx.Err() != nil {
+			return Result{TimedOut: true, ExitCode: -1}, nil
+		}
+		// Compilation errors go back to the model like runtime errors
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return Result{}, fmt.Errorf("failed to build Go program: %w", err)
+		}
+		stderr, truncated := truncate(buildOutput.String(), limits.MaxOutput)
+		return Result{Stderr: stderr, ExitCode: exitErr.ExitCode(), Truncated: truncated}, nil
+	}
+
+	env := append(i.env(dir), "GOMEMLIMIT="+strconv.FormatInt(limits.MemoryLimit, 10))
+	return i.execute(ctx, dir, env, limits.MemoryLimit+goRuntimeReserve, limits, filepath.Join(dir, "prog"))
+}
+
+// runJavaScriptUnsafe runs the program with node as an unsandboxed host
+// process, capping its heap
+func (i *Interpreter) runJavaScriptUnsafe(ctx context.Context, code string, limits Limits) (Result, error) {
+	dir, err := os.MkdirTemp("", "simpleai-js-")
+	if err != nil {
+		return Result{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "main.js"), []byte(code), 0o600); err != nil {
+		return Result{}, err
+	}
+	heap := fmt.Sprintf("--max-old-space-size=%d", max(limits.MemoryLimit>>20, 16))
+	// node reserves far more address space than it uses, so its memory is
+	// bounded by the heap flag rather than ulimit -v
+	return i.execute(ctx, dir, i.env(dir), 0, limits, i.config.NodeBinary, heap, "main.js")
+}
+
+// execute runs a command under ulimit when a POSIX shell is available.
+// addressSpace of 0 leaves virtual memory unlimited. The command runs in its
+// own process group, which is killed when it exits or ctx ends, so processes
+// it starts don't outlive it.
+func (i *Interpreter) execute(ctx context.Context, dir string, env []string, addressSpace int64, limits Limits, name string, args ...string) (Result, error) {
+	var cmd *exec.Cmd
+	if sh, err := exec.LookPath("sh"); err == nil && runtime.GOOS != "windows" {
+		// dash accepts one option per ulimit call
+		script := fmt.Sprintf("ulimit -t %d", max(int(limits.CPUTime.Seconds()), 1))
+		if addressSpace > 0 {
+			script += fmt.Sprintf("; ulimit -v %d", addressSpace>>10)
+		}
+		script += `; exec "$@"`
+		cmd = exec.CommandContext(ctx, sh, append([]string{"-c", script, "sh", name}, args...)...)
+	} else {
+		cmd = exec.CommandContext(ctx, name, args...)
+	}
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.WaitDelay = time.Second
+	killProcessGroup(cmd)
+
+	stdout := &limitedBuffer{limit: limits.MaxOutput}
+	stderr := &limitedBuffer{limit: limits.MaxOutput}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	reapProcessGroup(cmd)
+	result := Result{
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		Duration:  time.Since(start),
+		Truncated: stdout.truncated || stderr.truncated,
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) && ctx.Err() == nil {
+			return result, fmt.Errorf("failed to run program: %w", err)
+		}
+		result.ExitCode = -1
+		if exitErr != nil {
+			result.ExitCode = exitErr.ExitCode()
+		}
+	}
+	return result, nil
+}
+
+// env is the minimal environment of a program
+func (i *Interpreter) env(dir string) []string {
+	return append([]string{"PATH=" + os.Getenv("PATH"), "HOME=" + dir, "TMPDIR=" + dir}, i.config.Env...)
+}
+
+// limitedBuffer keeps the first limit bytes written and discards the rest
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room < len(p) {
+		b.buf.Write(p[:max(room, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
+
+func truncate(s string, limit int) (string, bool) {
+	if len(s) <= limit {
+		return s, false
+	}
+	return strings.ToValidUTF8(s[:limit], ""), true
+}
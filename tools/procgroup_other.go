@@ -0,0 +1,12 @@
+//go:build !unix
+
+package tools
+
+import "os/exec"
+
+// killProcessGroup is a no-op where process groups aren't available; only
+// cmd itself is killed when its context ends
+func killProcessGroup(cmd *exec.Cmd) {}
+
+// reapProcessGroup is a no-op where process groups aren't available
+func reapProcessGroup(cmd *exec.Cmd) {}
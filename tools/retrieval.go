@@ -0,0 +1,180 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/medatechnology/simpleai/agent"
+	"github.com/medatechnology/simpleai/contextbuilder"
+	"github.com/medatechnology/simpleai/rag"
+)
+
+// RetrievalConfig holds configuration for a Retrieval tool
+type RetrievalConfig struct {
+	Name        string // Tool name (default "search_knowledge_base")
+	Description string // What the knowledge base contains, shown to the model
+
+	DefaultK int // Passages returned when the model gives no k (default 5)
+	MaxK     int // Upper bound on k (default 20)
+
+	MaxTokens    int              // Budget for the returned passages (default 2000)
+	TokenCounter func(string) int // Default contextbuilder.EstimateTokens
+
+	// SourceKeys are the document metadata keys tried, in order, for the
+	// citation label; the document ID is used when none is set
+	SourceKeys []string
+}
+
+// DefaultRetrievalConfig returns sensible defaults
+func DefaultRetrievalConfig() RetrievalConfig {
+	return RetrievalConfig{
+		Name:        "search_knowledge_base",
+		Description: "Search the knowledge base for passages relevant to a question.",
+		DefaultK:    5,
+		MaxK:        20,
+		MaxTokens:   2000,
+		SourceKeys:  []string{"source", "title", "url"},
+	}
+}
+
+// Retrieval exposes a RAG store as an agent tool, so the model decides when
+// to retrieve instead of receiving context on every turn
+type Retrieval struct {
+	rag    *rag.RAG
+	config RetrievalConfig
+}
+
+// Passage is a retrieved document with its citation
+type Passage struct {
+	Citation int     `json:"citation"` // 1-based number the model cites as [n]
+	Source   string  `json:"source"`
+	Score    float64 `json:"score"`
+	Content  string  `json:"content"`
+}
+
+// NewRetrieval creates a retrieval tool over r
+func NewRetrieval(r *rag.RAG, config RetrievalConfig) *Retrieval {
+	defaults := DefaultRetrievalConfig()
+	if config.Name == "" {
+		config.Name = defaults.Name
+	}
+	if config.Description == "" {
+		config.Description = defaults.Description
+	}
+	if config.DefaultK <= 0 {
+		config.DefaultK = defaults.DefaultK
+	}
+	if config.MaxK <= 0 {
+		config.MaxK = defaults.MaxK
+	}
+	if config.MaxTokens <= 0 {
+		config.MaxTokens = defaults.MaxTokens
+	}
+	if config.TokenCounter == nil {
+		config.TokenCounter = contextbuilder.EstimateTokens
+	}
+	if len(config.SourceKeys) == 0 {
+		config.SourceKeys = defaults.SourceKeys
+	}
+	return &Retrieval{rag: r, config: config}
+}
+
+// NewRetrievalSimple creates a retrieval tool with default settings
+func NewRetrievalSimple(r *rag.RAG) *Retrieval {
+	return NewRetrieval(r, DefaultRetrievalConfig())
+}
+
+// Search returns up to k passages for query, most relevant first, that fit
+// in MaxTokens
+func (t *Retrieval) Search(ctx context.Context, query string, k int) ([]Passage, error) {
+	if k <= 0 {
+		k = t.config.DefaultK
+	}
+	k = min(k, t.config.MaxK)
+
+	results, err := t.rag.Search(ctx, query, k)
+	if err != nil {
+		return nil, err
+	}
+
+	passages := make([]Passage, 0, len(results))
+	items := make([]string, 0, len(results))
+	for i, result := range results {
+		passages = append(passages, Passage{
+			Citation: i + 1,
+			Source:   t.source(result.Document.ID, result.Document.Metadata),
+			Score:    result.Similarity,
+			Content:  result.Document.Content,
+		})
+		items = append(items, result.Document.Content)
+	}
+
+	// Drop the least relevant passages that do not fit
+	built := contextbuilder.New(t.config.MaxTokens, t.config.TokenCounter).
+		AddItems("passages", items, 0, contextbuilder.DropNewest).
+		Build()
+	return passages[:len(built.Section("passages").Items)], nil
+}
+
+// Tool returns the retrieval tool. The result lists numbered passages with
+// their sources, and the model is asked to cite them as [n].
+func (t *Retrieval) Tool() agent.Tool {
+	description := t.config.Description + " Returns numbered passages with their sources; cite them as [n] in the answer."
+	return agent.NewTool(t.config.Name, description, map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{"type": "string", "description": "What to look for"},
+			"k": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Number of passages (default %d, max %d)", t.config.DefaultK, t.config.MaxK),
+			},
+		},
+		"required": []string{"query"},
+	}, func(ctx context.Context, args json.RawMessage) (string, error) {
+		var input struct {
+			Query string `json:"query"`
+			K     int    `json:"k"`
+		}
+		if err := json.Unmarshal(args, &input); err != nil {
+			return "", err
+		}
+		passages, err := t.Search(ctx, input.Query, input.K)
+		if err != nil {
+			return "", err
+		}
+		return FormatPassages(passages), nil
+	})
+}
+
+// FormatPassages renders passages for the model:
+//
+//	[1] handbook.pdf (score 0.83)
+//	Refunds are issued within 14 days...
+func FormatPassages(passages []Passage) string {
+	if len(passages) == 0 {
+		return "No relevant passages found."
+	}
+	var b strings.Builder
+	for i, p := range passages {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "[%d] %s (score %.2f)\n%s", p.Citation, p.Source, p.Score, p.Content)
+	}
+	return b.String()
+}
+
+// source labels a document for citation
+func (t *Retrieval) source(id string, metadata map[string]any) string {
+	for _, key := range t.config.SourceKeys {
+		if s, ok := metadata[key].(string); ok && s != "" {
+			return s
+		}
+	}
+	if id == "" {
+		return "unknown"
+	}
+	return id
+}
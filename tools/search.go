@@ -0,0 +1,410 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/agent"
+)
+
+// Search API endpoints
+const (
+	TavilySearchURL = "https://api.tavily.com/search"
+	BraveSearchURL  = "https://api.search.brave.com/res/v1/web/search"
+)
+
+// ErrNoSearchEngine is returned when a search has no engine configured
+var ErrNoSearchEngine = errors.New("tools: search engine is required")
+
+// SearchResult is a web search hit in the same shape for every engine
+type SearchResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+}
+
+// SearchEngine queries a web search API
+type SearchEngine interface {
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
+}
+
+// SearchConfig holds configuration for a Search
+type SearchConfig struct {
+	Engine     SearchEngine // Required
+	MaxResults int          // Results per query (default 5)
+
+	// RequestsPerMinute caps calls to the engine; extra calls wait
+	// (0 = unlimited)
+	RequestsPerMinute int
+
+	// Summarizer, when set, condenses the results into a short answer to
+	// the query with numbered sources, instead of returning raw snippets
+	Summarizer    *simpleai.Client
+	SummaryPrompt string // System prompt for the summarizer
+}
+
+// DefaultSearchSummaryPrompt asks for a grounded summary with citations
+const DefaultSearchSummaryPrompt = `Summarize the search results as they relate to the query in a few sentences.
+Use only facts from the results and cite them by number, e.g. [1].
+If the results do not answer the query, say so.`
+
+// DefaultSearchConfig returns sensible defaults
+func DefaultSearchConfig() SearchConfig {
+	return SearchConfig{
+		MaxResults:        5,
+		RequestsPerMinute: 60,
+		SummaryPrompt:     DefaultSearchSummaryPrompt,
+	}
+}
+
+// Search runs web searches for agents with rate limiting and optional
+// summarization
+type Search struct {
+	config  SearchConfig
+	limiter *rateLimiter
+}
+
+// NewSearch creates a web search tool
+func NewSearch(config SearchConfig) *Search {
+	if config.MaxResults <= 0 {
+		config.MaxResults = 5
+	}
+	if config.SummaryPrompt == "" {
+		config.SummaryPrompt = DefaultSearchSummaryPrompt
+	}
+	return &Search{config: config, limiter: newRateLimiter(config.RequestsPerMinute)}
+}
+
+// NewSearchSimple creates a web search tool with default settings
+func NewSearchSimple(engine SearchEngine) *Search {
+	config := DefaultSearchConfig()
+	config.Engine = engine
+	return NewSearch(config)
+}
+
+// Search returns up to MaxResults results for query
+func (s *Search) Search(ctx context.Context, query string) ([]SearchResult, error) {
+	if s.config.Engine == nil {
+		return nil, ErrNoSearchEngine
+	}
+	if err := s.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	results, err := s.config.Engine.Search(ctx, query, s.config.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) > s.config.MaxResults {
+		results = results[:s.config.MaxResults]
+	}
+	return results, nil
+}
+
+// Summarize condenses results into an answer to query with numbered sources
+func (s *Search) Summarize(ctx context.Context, query string, results []SearchResult) (string, error) {
+	if s.config.Summarizer == nil {
+		return "", errors.New("tools: search summarizer is required")
+	}
+	return s.config.Summarizer.Generate(ctx,
+		fmt.Sprintf("Query: %s\n\nResults:\n%s", query, formatResults(results)),
+		simpleai.WithSystemPrompt(s.config.SummaryPrompt))
+}
+
+// Tool returns the search as an agent tool named "web_search". The model
+// receives the results as JSON, or a summary followed by the numbered
+// sources when a summarizer is configured. Results are cached for five
+// minutes.
+func (s *Search) Tool() agent.Tool {
+	return agent.NewTool("web_search",
+		"Search the web for current information. Returns titles, URLs and snippets of the top results.",
+		map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]any{"type": "string", "description": "Search query"},
+			},
+			"required": []string{"query"},
+		},
+		func(ctx context.Context, args json.RawMessage) (string, error) {
+			var input struct {
+				Query string `json:"query"`
+			}
+			if err := json.Unmarshal(args, &input); err != nil {
+				return "", err
+			}
+			results, err := s.Search(ctx, input.Query)
+			if err != nil {
+				return "", err
+			}
+			if len(results) == 0 {
+				return "No results found.", nil
+			}
+			if s.config.Summarizer == nil {
+				data, err := json.Marshal(results)
+				return string(data), err
+			}
+			summary, err := s.Summarize(ctx, input.Query, results)
+			if err != nil {
+				return "", err
+			}
+			return summary + "\n\nSources:\n" + formatSources(results), nil
+		}).WithCache(5 * time.Minute)
+}
+
+func formatResults(results []SearchResult) string {
+	var b strings.Builder
+	for i, r := range results {
+		fmt.Fprintf(&b, "[%d] %s\n%s\n%s\n\n", i+1, r.Title, r.URL, r.Snippet)
+	}
+	return b.String()
+}
+
+func formatSources(results []SearchResult) string {
+	var b strings.Builder
+	for i, r := range results {
+		fmt.Fprintf(&b, "[%d] %s - %s\n", i+1, r.Title, r.URL)
+	}
+	return b.String()
+}
+
+// TavilyConfig holds configuration for the Tavily search API
+type TavilyConfig struct {
+	APIKey string
+	Depth  string // "basic" (default) or "advanced"
+}
+
+// Tavily implements SearchEngine with the Tavily API
+type Tavily struct {
+	config TavilyConfig
+	client *http.Client
+}
+
+// NewTavily creates a Tavily search engine
+func NewTavily(config TavilyConfig) *Tavily {
+	if config.Depth == "" {
+		config.Depth = "basic"
+	}
+	return &Tavily{config: config, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// NewTavilyFromEnv creates a Tavily search engine using TAVILY_API_KEY
+func NewTavilyFromEnv() *Tavily {
+	return NewTavily(TavilyConfig{APIKey: os.Getenv("TAVILY_API_KEY")})
+}
+
+// Search queries Tavily
+func (t *Tavily) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	body := map[string]any{
+		"query":        query,
+		"max_results":  limit,
+		"search_depth": t.config.Depth,
+	}
+	var resp struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	headers := map[string]string{"Authorization": "Bearer " + t.config.APIKey}
+	if err := doJSON(ctx, t.client, http.MethodPost, TavilySearchURL, headers, body, &resp); err != nil {
+		return nil, fmt.Errorf("tavily search failed: %w", err)
+	}
+
+	results := make([]SearchResult, 0, len(resp.Results))
+	for _, r := range resp.Results {
+		results = append(results, SearchResult{Title: r.Title, URL: r.URL, Snippet: r.Content})
+	}
+	return results, nil
+}
+
+// BraveConfig holds configuration for the Brave Search API
+type BraveConfig struct {
+	APIKey string
+}
+
+// Brave implements SearchEngine with the Brave Search API
+type Brave struct {
+	config BraveConfig
+	client *http.Client
+}
+
+// NewBrave creates a Brave search engine
+func NewBrave(config BraveConfig) *Brave {
+	return &Brave{config: config, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// NewBraveFromEnv creates a Brave search engine using BRAVE_API_KEY
+func NewBraveFromEnv() *Brave {
+	return NewBrave(BraveConfig{APIKey: os.Getenv("BRAVE_API_KEY")})
+}
+
+// Search queries Brave
+func (b *Brave) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	params := url.Values{"q": {query}, "count": {strconv.Itoa(min(limit, 20))}}
+	var resp struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	headers := map[string]string{"X-Subscription-Token": b.config.APIKey}
+	if err := doJSON(ctx, b.client, http.MethodGet, BraveSearchURL+"?"+params.Encode(), headers, nil, &resp); err != nil {
+		return nil, fmt.Errorf("brave search failed: %w", err)
+	}
+
+	results := make([]SearchResult, 0, len(resp.Web.Results))
+	for _, r := range resp.Web.Results {
+		results = append(results, SearchResult{Title: r.Title, URL: r.URL, Snippet: stripTags(r.Description)})
+	}
+	return results, nil
+}
+
+// SearxNGConfig holds configuration for a SearxNG instance
+type SearxNGConfig struct {
+	BaseURL string // e.g. http://localhost:8888; the JSON format must be enabled
+}
+
+// SearxNG implements SearchEngine with a self-hosted SearxNG instance
+type SearxNG struct {
+	config SearxNGConfig
+	client *http.Client
+}
+
+// NewSearxNG creates a SearxNG search engine
+func NewSearxNG(config SearxNGConfig) *SearxNG {
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	return &SearxNG{config: config, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Search queries SearxNG
+func (s *SearxNG) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	params := url.Values{"q": {query}, "format": {"json"}}
+	var resp struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := doJSON(ctx, s.client, http.MethodGet, s.config.BaseURL+"/search?"+params.Encode(), nil, nil, &resp); err != nil {
+		return nil, fmt.Errorf("searxng search failed: %w", err)
+	}
+
+	results := make([]SearchResult, 0, min(len(resp.Results), limit))
+	for _, r := range resp.Results {
+		if len(results) == limit {
+			break
+		}
+		results = append(results, SearchResult{Title: r.Title, URL: r.URL, Snippet: r.Content})
+	}
+	return results, nil
+}
+
+// doJSON sends a request with an optional JSON body and decodes a JSON
+// response
+func doJSON(ctx context.Context, client *http.Client, method, url string, headers map[string]string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// stripTags removes the <strong> highlighting some APIs put in snippets
+func stripTags(s string) string {
+	var b strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// rateLimiter spaces calls evenly to stay under a per-minute budget
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	if perMinute <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{interval: time.Minute / time.Duration(perMinute)}
+}
+
+// wait blocks until the caller's slot, or returns the context's error
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l.interval == 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	slot := now
+	if l.next.After(now) {
+		slot = l.next
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
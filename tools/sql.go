@@ -0,0 +1,408 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/simpleai/agent"
+)
+
+// ErrNotReadOnly is returned for statements other than a single query
+var ErrNotReadOnly = errors.New("tools: only single read-only queries are allowed")
+
+// Dialect selects the schema introspection query and placeholder style
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	MySQL    Dialect = "mysql"
+	SQLite   Dialect = "sqlite"
+)
+
+// Statements a query may start with
+var readOnlyKeywords = []string{"select", "with", "explain", "show", "describe", "values"}
+
+// Keywords refused anywhere in a query, e.g. in a data-modifying CTE
+var writeKeywords = []string{
+	"insert", "update", "delete", "merge", "upsert", "into",
+	"create", "drop", "alter", "truncate", "grant", "revoke",
+	"attach", "detach", "copy", "call",
+}
+
+// SQLConfig holds configuration for a SQL tool
+type SQLConfig struct {
+	DB      *sql.DB // Required; prefer a connection with a read-only database user
+	Dialect Dialect // Default Postgres
+
+	// Tables limits the schema shown to the model (empty = every table).
+	// It does not restrict queries; use database grants for that.
+	Tables []string
+
+	// AllowReadWriteTx runs queries in a normal transaction, still rolled
+	// back, when the driver doesn't support read-only ones. Only the
+	// keyword check then stops writes, so use it with a read-only database
+	// user. By default such drivers fail.
+	AllowReadWriteTx bool
+
+	MaxRows      int           // Rows returned per query (default 100)
+	MaxCellChars int           // Longer values are cut (default 200)
+	Timeout      time.Duration // Per query
+}
+
+// DefaultSQLConfig returns sensible defaults
+func DefaultSQLConfig() SQLConfig {
+	return SQLConfig{
+		Dialect:      Postgres,
+		MaxRows:      100,
+		MaxCellChars: 200,
+		Timeout:      10 * time.Second,
+	}
+}
+
+// SQL lets agents explore a database schema and run read-only queries
+type SQL struct {
+	config SQLConfig
+
+	mu     sync.Mutex
+	schema string
+}
+
+// NewSQL creates a SQL tool
+func NewSQL(config SQLConfig) *SQL {
+	defaults := DefaultSQLConfig()
+	if config.Dialect == "" {
+		config.Dialect = defaults.Dialect
+	}
+	if config.MaxRows <= 0 {
+		config.MaxRows = defaults.MaxRows
+	}
+	if config.MaxCellChars <= 0 {
+		config.MaxCellChars = defaults.MaxCellChars
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	return &SQL{config: config}
+}
+
+// NewSQLSimple creates a SQL tool with default limits
+func NewSQLSimple(db *sql.DB, dialect Dialect) *SQL {
+	config := DefaultSQLConfig()
+	config.DB = db
+	config.Dialect = dialect
+	return NewSQL(config)
+}
+
+// QueryResult holds the rows of a query
+type QueryResult struct {
+	Columns   []string
+	Rows      [][]string
+	Truncated bool // More rows than MaxRows
+}
+
+// Markdown renders the result as a markdown table
+func (r QueryResult) Markdown() string {
+	if len(r.Columns) == 0 {
+		return "(no columns)"
+	}
+	var b strings.Builder
+	b.WriteString("| " + strings.Join(r.Columns, " | ") + " |\n")
+	b.WriteString("|" + strings.Repeat(" --- |", len(r.Columns)) + "\n")
+	for _, row := range r.Rows {
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+	}
+	switch {
+	case len(r.Rows) == 0:
+		b.WriteString("\n(0 rows)")
+	case r.Truncated:
+		fmt.Fprintf(&b, "\n(first %d rows shown; add a LIMIT or aggregate for the rest)", len(r.Rows))
+	default:
+		fmt.Fprintf(&b, "\n(%d rows)", len(r.Rows))
+	}
+	return b.String()
+}
+
+// Schema describes the tables and columns, one table per line:
+//
+//	orders(id integer, customer_id integer, total numeric, note text null)
+//
+// It is read once and cached; call Refresh after migrations.
+func (s *SQL) Schema(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.schema != "" {
+		return s.schema, nil
+	}
+
+	var query string
+	switch s.config.Dialect {
+	case Postgres:
+		query = `SELECT table_name, column_name, data_type, is_nullable FROM information_schema.columns
+			WHERE table_schema = current_schema() ORDER BY table_name, ordinal_position`
+	case MySQL:
+		query = `SELECT table_name, column_name, data_type, is_nullable FROM information_schema.columns
+			WHERE table_schema = DATABASE() ORDER BY table_name, ordinal_position`
+	case SQLite:
+		query = `SELECT m.name, p.name, p.type, CASE WHEN p."notnull" = 1 THEN 'NO' ELSE 'YES' END
+			FROM sqlite_master m JOIN pragma_table_info(m.name) p
+			WHERE m.type IN ('table', 'view') AND m.name NOT LIKE 'sqlite_%' ORDER BY m.name, p.cid`
+	default:
+		return "", fmt.Errorf("tools: unknown SQL dialect %q", s.config.Dialect)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+	rows, err := s.config.DB.QueryContext(ctx, query)
+	if err != nil {
+		return "", fmt.Errorf("failed to read schema: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	columns := make(map[string][]string)
+	for rows.Next() {
+		var table, column, typ, nullable string
+		if err := rows.Scan(&table, &column, &typ, &nullable); err != nil {
+			return "", fmt.Errorf("failed to read schema: %w", err)
+		}
+		if len(s.config.Tables) > 0 && !slices.Contains(s.config.Tables, table) {
+			continue
+		}
+		if _, ok := columns[table]; !ok {
+			tables = append(tables, table)
+		}
+		col := column + " " + strings.ToLower(typ)
+		if strings.EqualFold(nullable, "YES") {
+			col += " null"
+		}
+		columns[table] = append(columns[table], col)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	lines := make([]string, len(tables))
+	for i, table := range tables {
+		lines[i] = table + "(" + strings.Join(columns[table], ", ") + ")"
+	}
+	s.schema = strings.Join(lines, "\n")
+	return s.schema, nil
+}
+
+// Refresh forgets the cached schema
+func (s *SQL) Refresh() {
+	s.mu.Lock()
+	s.schema = ""
+	s.mu.Unlock()
+}
+
+// Query runs a single read-only query with args bound to its placeholders.
+// It runs in a read-only transaction that is always rolled back.
+func (s *SQL) Query(ctx context.Context, query string, args ...any) (QueryResult, error) {
+	query, err := checkReadOnly(query)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+	tx, err := s.config.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil && s.config.AllowReadWriteTx && strings.Contains(err.Error(), "read-only") {
+		// Drivers without read-only transactions still get the keyword
+		// check and the rollback
+		tx, err = s.config.DB.BeginTx(ctx, nil)
+	}
+	if err != nil {
+		return QueryResult{}, fmt.Errorf("failed to start query: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return QueryResult{}, err
+	}
+	defer rows.Close()
+
+	var result QueryResult
+	if result.Columns, err = rows.Columns(); err != nil {
+		return QueryResult{}, err
+	}
+	values := make([]any, len(result.Columns))
+	pointers := make([]any, len(values))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if len(result.Rows) == s.config.MaxRows {
+			result.Truncated = true
+			break
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return QueryResult{}, err
+		}
+		row := make([]string, len(values))
+		for i, v := range values {
+			row[i] = s.formatCell(v)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result, rows.Err()
+}
+
+// Tools returns "sql_schema", which describes the tables, and "sql_query",
+// which runs a read-only query and returns a markdown table
+func (s *SQL) Tools() []agent.Tool {
+	placeholder := "$1, $2"
+	if s.config.Dialect != Postgres {
+		placeholder = "?"
+	}
+
+	schema := agent.NewTool("sql_schema",
+		"List the database tables with their columns and types. Call it before writing queries.",
+		map[string]any{"type": "object", "properties": map[string]any{}},
+		func(ctx context.Context, args json.RawMessage) (string, error) {
+			return s.Schema(ctx)
+		})
+
+	query := agent.NewTool("sql_query",
+		fmt.Sprintf("Run one read-only %s query (SELECT or WITH) and return the rows as a markdown table. "+
+			"At most %d rows are returned, so aggregate or add LIMIT. Pass user-supplied values as params "+
+			"bound to %s placeholders instead of writing them into the query.", s.config.Dialect, s.config.MaxRows, placeholder),
+		map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query":  map[string]any{"type": "string"},
+				"params": map[string]any{"type": "array", "items": map[string]any{}, "description": "Values for the placeholders, in order"},
+			},
+			"required": []string{"query"},
+		},
+		func(ctx context.Context, args json.RawMessage) (string, error) {
+			var input struct {
+				Query  string `json:"query"`
+				Params []any  `json:"params"`
+			}
+			if err := json.Unmarshal(args, &input); err != nil {
+				return "", err
+			}
+			result, err := s.Query(ctx, input.Query, input.Params...)
+			if err != nil {
+				return "", err
+			}
+			return result.Markdown(), nil
+		})
+
+	return []agent.Tool{schema, query}
+}
+
+// formatCell renders a value for a markdown table cell
+func (s *SQL) formatCell(v any) string {
+	var text string
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		text = string(v)
+	case time.Time:
+		text = v.Format(time.RFC3339)
+	default:
+		text = fmt.Sprint(v)
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	text = strings.ReplaceAll(text, "|", `\|`)
+	if runes := []rune(text); len(runes) > s.config.MaxCellChars {
+		text = string(runes[:s.config.MaxCellChars]) + "…"
+	}
+	return text
+}
+
+// checkReadOnly accepts a single statement starting with a query keyword
+// and without write keywords, and returns it without a trailing semicolon.
+// The read-only transaction is the real guard; this rejects writes early
+// with a clear message.
+func checkReadOnly(query string) (string, error) {
+	query = strings.TrimSpace(query)
+	query = strings.TrimSpace(strings.TrimSuffix(query, ";"))
+	if strings.Contains(query, ";") {
+		return "", ErrNotReadOnly
+	}
+
+	// Skip leading comments and parentheses to find the first keyword
+	rest := query
+	for {
+		rest = strings.TrimLeft(rest, " \t\r\n(")
+		switch {
+		case strings.HasPrefix(rest, "--"):
+			_, rest, _ = strings.Cut(rest, "\n")
+		case strings.HasPrefix(rest, "/*"):
+			_, rest, _ = strings.Cut(rest, "*/")
+		default:
+			words := strings.Fields(strings.ToLower(rest))
+			if len(words) == 0 || !slices.Contains(readOnlyKeywords, words[0]) {
+				return "", ErrNotReadOnly
+			}
+			// Dialects differ on backslashes in strings, so check both ways
+			// in case a keyword hides in what only one of them sees as a string
+			for _, word := range append(sqlWords(query, false), sqlWords(query, true)...) {
+				if slices.Contains(writeKeywords, word) {
+					return "", fmt.Errorf("%w: %s is not allowed", ErrNotReadOnly, strings.ToUpper(word))
+				}
+			}
+			return query, nil
+		}
+	}
+}
+
+// sqlWords returns the lowercased bare words of a query, skipping string
+// literals, quoted identifiers and comments. With backslashEscapes, a
+// backslash in quotes escapes the next character, as in MySQL.
+func sqlWords(query string, backslashEscapes bool) []string {
+	var words []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, strings.ToLower(word.String()))
+			word.Reset()
+		}
+	}
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			flush()
+			// Doubled quotes inside are two adjacent quoted runs
+			for i++; i < len(query) && query[i] != c; i++ {
+				if backslashEscapes && query[i] == '\\' {
+					i++
+				}
+			}
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			flush()
+			if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(query)
+			}
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			flush()
+			if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(query)
+			}
+		case c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80:
+			word.WriteByte(c)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return words
+}
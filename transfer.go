@@ -0,0 +1,52 @@
+package simpleai
+
+import (
+	"context"
+	"sync"
+)
+
+// Transfer is the size of the HTTP payloads sent to and received from
+// providers for one request. Retries and fallbacks add up.
+type Transfer struct {
+	RequestBytes  int64 `json:"request_bytes"`  // Request bodies before compression
+	SentBytes     int64 `json:"sent_bytes"`     // Request bodies as sent, after compression
+	ResponseBytes int64 `json:"response_bytes"` // Response bodies as read, after decompression
+}
+
+type transferKey struct{}
+
+// transferRecord accumulates a request's payload sizes
+type transferRecord struct {
+	mu       sync.Mutex
+	transfer Transfer
+}
+
+// withTransfer returns a context recording the request's payload sizes.
+// Each client call gets its own record.
+func withTransfer(ctx context.Context) context.Context {
+	return context.WithValue(ctx, transferKey{}, &transferRecord{})
+}
+
+// AddTransfer adds payload sizes to the request's totals. Providers call it
+// for every HTTP exchange.
+func AddTransfer(ctx context.Context, t Transfer) {
+	if r, ok := ctx.Value(transferKey{}).(*transferRecord); ok {
+		r.mu.Lock()
+		r.transfer.RequestBytes += t.RequestBytes
+		r.transfer.SentBytes += t.SentBytes
+		r.transfer.ResponseBytes += t.ResponseBytes
+		r.mu.Unlock()
+	}
+}
+
+// TransferFromContext returns the payload sizes of the request so far. It
+// reports false outside a client call.
+func TransferFromContext(ctx context.Context) (Transfer, bool) {
+	r, ok := ctx.Value(transferKey{}).(*transferRecord)
+	if !ok {
+		return Transfer{}, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.transfer, true
+}
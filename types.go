@@ -2,6 +2,10 @@ package simpleai
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"time"
 )
 
 // Role represents the role of a message sender
@@ -11,12 +15,82 @@ const (
 	RoleSystem    Role = "system"
 	RoleUser      Role = "user"
 	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool" // Tool result; set ToolCallID and Name (the tool name)
 )
 
 // Message represents a single message in a conversation
 type Message struct {
-	Role    Role   `json:"role"`
+	Role       Role       `json:"role"`
+	Content    string     `json:"content"`
+	Name       string     `json:"name,omitempty"`         // Speaker name, or the tool name for RoleTool
+	ToolCallID string     `json:"tool_call_id,omitempty"` // ID of the call a RoleTool message answers
+	Documents  []Document `json:"documents,omitempty"`    // Source documents the model can cite (provider support varies)
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // Tool calls made by the assistant
+
+	// Bookkeeping for history, UIs and persistence; never sent to providers
+	ID        string         `json:"id,omitempty"`
+	Timestamp time.Time      `json:"timestamp,omitzero"`
+	Metadata  map[string]any `json:"metadata,omitempty"` // App data such as source document IDs
+}
+
+// NewMessageID returns a random message ID
+func NewMessageID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "msg_" + hex.EncodeToString(b)
+}
+
+// stampMessage fills in a missing role, ID and timestamp
+func stampMessage(msg Message, role Role) Message {
+	if msg.Role == "" {
+		msg.Role = role
+	}
+	if msg.ID == "" {
+		msg.ID = NewMessageID()
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	return msg
+}
+
+// Document is a source document attached to a message for grounded answers
+type Document struct {
+	Title   string `json:"title,omitempty"`
 	Content string `json:"content"`
+	Context string `json:"context,omitempty"` // Extra context about the document, not cited
+}
+
+// Citation is a span of a source that supports part of the response
+type Citation struct {
+	DocumentIndex int    `json:"document_index"` // Index into the request's documents, in order of appearance
+	DocumentTitle string `json:"document_title,omitempty"`
+	URL           string `json:"url,omitempty"` // Source URL for web-grounded providers
+	CitedText     string `json:"cited_text,omitempty"`
+	Start         int    `json:"start,omitempty"` // Start of the cited span (character, page or block index)
+	End           int    `json:"end,omitempty"`   // End of the cited span (exclusive)
+}
+
+// Tool describes a function the model may call
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"` // JSON schema of the arguments
+}
+
+// Tool choice modes for Request.ToolChoice; any other value forces the
+// named tool
+const (
+	ToolChoiceAuto     = "auto"     // The model decides (default)
+	ToolChoiceNone     = "none"     // The model must not call tools
+	ToolChoiceRequired = "required" // The model must call at least one tool
+)
+
+// ToolCall is a function call requested by the model
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON-encoded arguments
 }
 
 // Request represents a completion request to an AI provider
@@ -29,14 +103,47 @@ type Request struct {
 	Stop         []string  `json:"stop,omitempty"`
 	Stream       bool      `json:"stream,omitempty"`
 	SystemPrompt string    `json:"system_prompt,omitempty"`
+
+	// AssistantPrefix is the start of the assistant's answer, which the model
+	// must continue (e.g. "{" to force JSON). Response.Content includes it.
+	// Providers without native prefill are instructed to begin with it.
+	AssistantPrefix string `json:"assistant_prefix,omitempty"`
+
+	// IncludeStop keeps the matched stop sequence at the end of
+	// Response.Content; by default it is stripped
+	IncludeStop bool `json:"include_stop,omitempty"`
+
+	// Tools the model may call; calls are returned in Response.ToolCalls
+	// (provider support varies)
+	Tools      []Tool `json:"tools,omitempty"`
+	ToolChoice string `json:"tool_choice,omitempty"` // auto (default), none, required or a tool name
 }
 
 // Response represents a completion response from an AI provider
 type Response struct {
-	Content      string `json:"content"`
-	Model        string `json:"model"`
-	FinishReason string `json:"finish_reason"`
-	Usage        Usage  `json:"usage"`
+	ID           string     `json:"id,omitempty"` // Unique per completion; retries with an idempotency key get the original
+	Content      string     `json:"content"`
+	Model        string     `json:"model"`
+	FinishReason string     `json:"finish_reason"`
+	Usage        Usage      `json:"usage"`
+	Citations    []Citation `json:"citations,omitempty"`
+	ToolCalls    []ToolCall `json:"tool_calls,omitempty"`
+	StopSequence string     `json:"stop_sequence,omitempty"` // Stop sequence that ended generation, when known
+	ReplayID     string     `json:"replay_id,omitempty"`     // ID of the recorded call (see WithReplay)
+	Transfer     *Transfer  `json:"transfer,omitempty"`      // HTTP payload sizes, when the provider reports them
+}
+
+// Clone returns a deep copy of the response, so callers sharing one
+// completion can't modify each other's tool calls or citations
+func (r *Response) Clone() *Response {
+	clone := *r
+	clone.Citations = slices.Clone(r.Citations)
+	clone.ToolCalls = slices.Clone(r.ToolCalls)
+	if r.Transfer != nil {
+		transfer := *r.Transfer
+		clone.Transfer = &transfer
+	}
+	return &clone
 }
 
 // Usage represents token usage statistics
@@ -48,10 +155,16 @@ type Usage struct {
 
 // StreamEvent represents a streaming response event
 type StreamEvent struct {
-	Content      string `json:"content"`
-	Done         bool   `json:"done"`
-	FinishReason string `json:"finish_reason,omitempty"`
-	Error        error  `json:"error,omitempty"`
+	Content      string     `json:"content"`
+	Done         bool       `json:"done"`
+	FinishReason string     `json:"finish_reason,omitempty"`
+	Citations    []Citation `json:"citations,omitempty"`     // Sources, on the final event (provider support varies)
+	ToolCalls    []ToolCall `json:"tool_calls,omitempty"`    // Tool calls, on the final event
+	StopSequence string     `json:"stop_sequence,omitempty"` // Stop sequence that ended generation, on the final event
+	Usage        *Usage     `json:"usage,omitempty"`         // Token usage, on the final event (provider support varies)
+	Error        error      `json:"error,omitempty"`
+	Time         time.Time  `json:"time,omitzero"`       // When the event arrived from the provider (see WithStreamTimestamps)
+	ReplayID     string     `json:"replay_id,omitempty"` // ID of the recorded call, on the final event (see WithReplay)
 }
 
 // Provider defines the interface for AI providers
@@ -0,0 +1,267 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/goutil/simplelog"
+)
+
+// Metric is the quantity an alert watches
+type Metric string
+
+const (
+	MetricTokens   Metric = "tokens"
+	MetricCost     Metric = "cost" // Needs Config.Prices
+	MetricRequests Metric = "requests"
+)
+
+// Alert reasons
+const (
+	ReasonThreshold = "threshold" // The interval passed AlertConfig.Threshold
+	ReasonAnomaly   = "anomaly"   // The interval passed Factor times the baseline
+)
+
+// Alert reports an interval whose usage is too high
+type Alert struct {
+	Time     time.Time `json:"time"`
+	Reason   string    `json:"reason"`
+	Metric   Metric    `json:"metric"`
+	Group    Total     `json:"group"`    // The grouped fields and the current interval's totals
+	Value    float64   `json:"value"`    // The metric so far in the current interval
+	Baseline float64   `json:"baseline"` // Average of the previous intervals
+	Limit    float64   `json:"limit"`    // The value that was exceeded
+}
+
+func (a Alert) String() string {
+	return fmt.Sprintf("usage %s alert: %s %.2f in the current interval (limit %.2f, baseline %.2f)",
+		a.Reason, a.Metric, a.Value, a.Limit, a.Baseline)
+}
+
+// AlertConfig holds configuration for a Monitor
+type AlertConfig struct {
+	Metric   Metric        // Default MetricTokens
+	Interval time.Duration // Length of an interval (default 1h)
+	Baseline int           // Previous intervals averaged into the baseline (default 24)
+
+	// Factor alerts when the current interval exceeds Factor times the
+	// baseline (default 3). Intervals without a baseline never alert.
+	Factor float64
+	// MinValue ignores anomalies below this value, so quiet periods don't
+	// alert on small bursts
+	MinValue float64
+	// Threshold alerts when the current interval exceeds it, regardless of
+	// the baseline (0 disables)
+	Threshold float64
+
+	// GroupBy evaluates each group separately, e.g. ByUser to catch one
+	// runaway user; empty watches the total
+	GroupBy []Dimension
+	Filter  Filter
+
+	CheckEvery time.Duration // How often Run checks (default 1m)
+	Cooldown   time.Duration // Minimum time between alerts per group (default Interval)
+
+	// Delivery; alerts are logged when neither is set
+	OnAlert     func(alert Alert)
+	WebhookURLs []string // Receive the Alert as JSON
+	Log         bool     // Also log alerts with simplelog
+}
+
+// DefaultAlertConfig returns sensible defaults
+func DefaultAlertConfig() AlertConfig {
+	return AlertConfig{
+		Metric:     MetricTokens,
+		Interval:   time.Hour,
+		Baseline:   24,
+		Factor:     3,
+		CheckEvery: time.Minute,
+	}
+}
+
+// Monitor checks the ledger for usage spikes, catching runaway agent loops
+// before the bill arrives
+type Monitor struct {
+	ledger *Ledger
+	config AlertConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	lastAlert map[string]time.Time
+}
+
+// NewMonitor creates a monitor on a ledger
+func NewMonitor(ledger *Ledger, config AlertConfig) *Monitor {
+	defaults := DefaultAlertConfig()
+	if config.Metric == "" {
+		config.Metric = defaults.Metric
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.Baseline <= 0 {
+		config.Baseline = defaults.Baseline
+	}
+	if config.Factor <= 0 {
+		config.Factor = defaults.Factor
+	}
+	if config.CheckEvery <= 0 {
+		config.CheckEvery = defaults.CheckEvery
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = config.Interval
+	}
+	if config.OnAlert == nil && len(config.WebhookURLs) == 0 {
+		config.Log = true
+	}
+
+	return &Monitor{
+		ledger:    ledger,
+		config:    config,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		lastAlert: make(map[string]time.Time),
+	}
+}
+
+// Run checks every CheckEvery until ctx is canceled
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.config.CheckEvery)
+	defer ticker.Stop()
+	for {
+		if _, err := m.Check(ctx); err != nil {
+			simplelog.LogErr(err, "usage: alert check failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check compares the current interval with the baseline, delivers new
+// alerts and returns them
+func (m *Monitor) Check(ctx context.Context) ([]Alert, error) {
+	now := time.Now()
+	interval := int64(m.config.Interval)
+	current := time.Unix(0, now.UnixNano()/interval*interval).UTC()
+
+	filter := m.config.Filter
+	filter.From = current.Add(-time.Duration(m.config.Baseline) * m.config.Interval)
+	filter.To = time.Time{}
+	totals, err := m.ledger.Query(ctx, Query{
+		Filter:  filter,
+		GroupBy: m.config.GroupBy,
+		Window:  m.config.Interval,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Split each group into the current interval and the baseline
+	type group struct {
+		current  Total
+		previous float64
+	}
+	groups := make(map[string]*group)
+	for _, t := range totals {
+		key := groupKey(t)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{current: Total{UserID: t.UserID, SessionID: t.SessionID, Provider: t.Provider, Model: t.Model}}
+			groups[key] = g
+		}
+		if t.Start.Equal(current) {
+			g.current = t
+		} else {
+			g.previous += m.value(t)
+		}
+	}
+
+	var alerts []Alert
+	for key, g := range groups {
+		alert := Alert{
+			Time:     now,
+			Metric:   m.config.Metric,
+			Group:    g.current,
+			Value:    m.value(g.current),
+			Baseline: g.previous / float64(m.config.Baseline),
+		}
+		switch {
+		case m.config.Threshold > 0 && alert.Value > m.config.Threshold:
+			alert.Reason, alert.Limit = ReasonThreshold, m.config.Threshold
+		case alert.Baseline > 0 && alert.Value >= m.config.MinValue && alert.Value > m.config.Factor*alert.Baseline:
+			alert.Reason, alert.Limit = ReasonAnomaly, m.config.Factor*alert.Baseline
+		default:
+			continue
+		}
+
+		if !m.cooledDown(key+"\x00"+alert.Reason, now) {
+			continue
+		}
+		alerts = append(alerts, alert)
+		m.deliver(alert)
+	}
+	return alerts, nil
+}
+
+// value returns the watched metric of a total
+func (m *Monitor) value(t Total) float64 {
+	switch m.config.Metric {
+	case MetricCost:
+		return t.Cost
+	case MetricRequests:
+		return float64(t.Requests)
+	default:
+		return float64(t.TotalTokens)
+	}
+}
+
+// cooledDown reports whether an alert for key may fire, and records it
+func (m *Monitor) cooledDown(key string, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if last, ok := m.lastAlert[key]; ok && now.Sub(last) < m.config.Cooldown {
+		return false
+	}
+	m.lastAlert[key] = now
+	return true
+}
+
+func (m *Monitor) deliver(alert Alert) {
+	if m.config.Log {
+		simplelog.LogErr(fmt.Errorf("%s", alert), "usage alert")
+	}
+	if m.config.OnAlert != nil {
+		m.config.OnAlert(alert)
+	}
+	if len(m.config.WebhookURLs) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(alert)
+	if err != nil {
+		simplelog.LogErr(err, "usage: failed to encode alert")
+		return
+	}
+	for _, url := range m.config.WebhookURLs {
+		resp, err := m.client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			simplelog.LogErr(err, "usage: failed to post alert to "+url)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			simplelog.LogErr(fmt.Errorf("status %d", resp.StatusCode), "usage: failed to post alert to "+url)
+		}
+	}
+}
+
+func groupKey(t Total) string {
+	return t.UserID + "\x00" + t.SessionID + "\x00" + t.Provider + "\x00" + t.Model
+}
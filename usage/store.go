@@ -0,0 +1,115 @@
+package usage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store persists usage records. Implement it to keep the ledger in a
+// database.
+type Store interface {
+	Add(ctx context.Context, r Record) error
+	// Query returns the records matching f, oldest first
+	Query(ctx context.Context, f Filter) ([]Record, error)
+}
+
+// MemoryStore keeps records in memory
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records []Record
+}
+
+// NewMemoryStore creates an in-memory usage store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Add appends a record
+func (m *MemoryStore) Add(ctx context.Context, r Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, r)
+	return nil
+}
+
+// Query returns the matching records
+func (m *MemoryStore) Query(ctx context.Context, f Filter) ([]Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []Record
+	for _, r := range m.records {
+		if f.Match(r) {
+			result = append(result, r)
+		}
+	}
+	return result, nil
+}
+
+// FileStore appends records to a JSON Lines file
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileStore creates a file-backed usage store at path, creating its
+// directory if needed
+func NewFileStore(path string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{path: path}, nil
+}
+
+// Add appends a record as one line
+func (f *FileStore) Add(ctx context.Context, r Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Query scans the file for matching records
+func (f *FileStore) Query(ctx context.Context, filter Filter) ([]Record, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.Open(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var result []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue // Skip a line torn by a crash
+		}
+		if filter.Match(r) {
+			result = append(result, r)
+		}
+	}
+	return result, scanner.Err()
+}
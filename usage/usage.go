@@ -0,0 +1,306 @@
+// Package usage keeps a ledger of token usage attributed to users,
+// sessions, providers and models, so teams can bill internal users for
+// their consumption.
+//
+//	ledger := usage.New(usage.Config{})
+//	client := simpleai.NewClient(p, simpleai.WithMiddleware(ledger.Middleware()))
+//
+//	// Requests are attributed with simpleai.WithRequestMetadata
+//	totals, err := ledger.Query(ctx, usage.Query{
+//		Filter:  usage.Filter{From: monthStart},
+//		GroupBy: []usage.Dimension{usage.ByUser},
+//	})
+package usage
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/medatechnology/goutil/simplelog"
+	"github.com/medatechnology/simpleai"
+	"github.com/medatechnology/simpleai/contextbuilder"
+)
+
+// Record is the token usage of one request
+type Record struct {
+	Time             time.Time         `json:"time"`
+	UserID           string            `json:"user_id,omitempty"`
+	SessionID        string            `json:"session_id,omitempty"`
+	Provider         string            `json:"provider,omitempty"`
+	Model            string            `json:"model,omitempty"`
+	PromptTokens     int               `json:"prompt_tokens"`
+	CompletionTokens int               `json:"completion_tokens"`
+	TotalTokens      int               `json:"total_tokens"`
+	Cost             float64           `json:"cost,omitempty"`      // From Config.Prices
+	Estimated        bool              `json:"estimated,omitempty"` // Counted locally, e.g. for streams
+	Tags             map[string]string `json:"tags,omitempty"`
+}
+
+// Filter selects records. Empty fields match everything; From is
+// inclusive and To exclusive.
+type Filter struct {
+	From      time.Time `json:"from,omitzero"`
+	To        time.Time `json:"to,omitzero"`
+	UserID    string    `json:"user_id,omitempty"`
+	SessionID string    `json:"session_id,omitempty"`
+	Provider  string    `json:"provider,omitempty"`
+	Model     string    `json:"model,omitempty"`
+}
+
+// Match reports whether r passes the filter
+func (f Filter) Match(r Record) bool {
+	return (f.From.IsZero() || !r.Time.Before(f.From)) &&
+		(f.To.IsZero() || r.Time.Before(f.To)) &&
+		(f.UserID == "" || r.UserID == f.UserID) &&
+		(f.SessionID == "" || r.SessionID == f.SessionID) &&
+		(f.Provider == "" || r.Provider == f.Provider) &&
+		(f.Model == "" || r.Model == f.Model)
+}
+
+// Dimension is a record field totals can be grouped by
+type Dimension string
+
+const (
+	ByUser     Dimension = "user"
+	BySession  Dimension = "session"
+	ByProvider Dimension = "provider"
+	ByModel    Dimension = "model"
+)
+
+// Query selects records and aggregates them into totals
+type Query struct {
+	Filter
+	GroupBy []Dimension
+	// Window splits totals into time buckets (e.g. 24*time.Hour for daily
+	// totals), aligned to the Unix epoch in UTC; 0 gives one bucket
+	Window time.Duration
+}
+
+// Total is the aggregated usage of one group and time window. Only the
+// fields of the grouped dimensions are set.
+type Total struct {
+	Start            time.Time `json:"start,omitzero"` // Window start; zero without a window
+	UserID           string    `json:"user_id,omitempty"`
+	SessionID        string    `json:"session_id,omitempty"`
+	Provider         string    `json:"provider,omitempty"`
+	Model            string    `json:"model,omitempty"`
+	Requests         int       `json:"requests"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	Cost             float64   `json:"cost,omitempty"`
+}
+
+// Price is the cost of a model per million tokens, in any currency
+type Price struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// Config holds configuration for a Ledger
+type Config struct {
+	Store Store // Defaults to a MemoryStore
+
+	// Provider names the provider for requests that don't pass through a
+	// simpleai.Client, which records the provider that served each request
+	// (see simpleai.ProviderName)
+	Provider string
+
+	// Prices maps model names to prices, to compute Record.Cost
+	Prices map[string]Price
+
+	// OnError receives store errors from the middleware; they are logged
+	// by default and never fail requests
+	OnError func(err error)
+}
+
+// Ledger records usage and answers queries over it
+type Ledger struct {
+	config Config
+}
+
+// New creates a ledger
+func New(config Config) *Ledger {
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if config.OnError == nil {
+		config.OnError = func(err error) {
+			simplelog.LogErr(err, "usage: failed to record usage")
+		}
+	}
+	return &Ledger{config: config}
+}
+
+// Record adds a record; a zero Time is set to now and the cost is
+// computed from the configured prices
+func (l *Ledger) Record(ctx context.Context, r Record) error {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	if r.TotalTokens == 0 {
+		r.TotalTokens = r.PromptTokens + r.CompletionTokens
+	}
+	if price, ok := l.config.Prices[r.Model]; ok && r.Cost == 0 {
+		r.Cost = (float64(r.PromptTokens)*price.Prompt + float64(r.CompletionTokens)*price.Completion) / 1e6
+	}
+	return l.config.Store.Add(ctx, r)
+}
+
+// Records returns the records matching f
+func (l *Ledger) Records(ctx context.Context, f Filter) ([]Record, error) {
+	return l.config.Store.Query(ctx, f)
+}
+
+// Query aggregates the matching records, sorted by window and then by the
+// grouped fields
+func (l *Ledger) Query(ctx context.Context, q Query) ([]Total, error) {
+	records, err := l.config.Store.Query(ctx, q.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		start                          int64
+		user, session, provider, model string
+	}
+	totals := make(map[key]*Total)
+	for _, r := range records {
+		k := key{}
+		total := Total{}
+		if q.Window > 0 {
+			k.start = r.Time.UnixNano() / int64(q.Window) * int64(q.Window)
+			total.Start = time.Unix(0, k.start).UTC()
+		}
+		for _, d := range q.GroupBy {
+			switch d {
+			case ByUser:
+				k.user, total.UserID = r.UserID, r.UserID
+			case BySession:
+				k.session, total.SessionID = r.SessionID, r.SessionID
+			case ByProvider:
+				k.provider, total.Provider = r.Provider, r.Provider
+			case ByModel:
+				k.model, total.Model = r.Model, r.Model
+			}
+		}
+
+		t, ok := totals[k]
+		if !ok {
+			t = &total
+			totals[k] = t
+		}
+		t.Requests++
+		t.PromptTokens += r.PromptTokens
+		t.CompletionTokens += r.CompletionTokens
+		t.TotalTokens += r.TotalTokens
+		t.Cost += r.Cost
+	}
+
+	result := make([]Total, 0, len(totals))
+	for _, t := range totals {
+		result = append(result, *t)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if !a.Start.Equal(b.Start) {
+			return a.Start.Before(b.Start)
+		}
+		return strings.Join([]string{a.UserID, a.SessionID, a.Provider, a.Model}, "\x00") <
+			strings.Join([]string{b.UserID, b.SessionID, b.Provider, b.Model}, "\x00")
+	})
+	return result, nil
+}
+
+// Middleware records the usage of every request made through a client.
+// Streams report no usage, so their tokens are estimated.
+func (l *Ledger) Middleware() simpleai.Middleware {
+	return &middleware{ledger: l}
+}
+
+type middleware struct {
+	ledger *Ledger
+}
+
+// Wrap implements simpleai.Middleware
+func (m *middleware) Wrap(next simpleai.Handler) simpleai.Handler {
+	return func(ctx context.Context, req *simpleai.Request) (*simpleai.Response, error) {
+		resp, err := next(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+
+		r := m.record(ctx, req)
+		if resp.Model != "" {
+			r.Model = resp.Model
+		}
+		r.PromptTokens = resp.Usage.PromptTokens
+		r.CompletionTokens = resp.Usage.CompletionTokens
+		r.TotalTokens = resp.Usage.TotalTokens
+		m.add(ctx, r)
+		return resp, nil
+	}
+}
+
+// WrapStream implements simpleai.StreamMiddleware
+func (m *middleware) WrapStream(next simpleai.StreamHandler) simpleai.StreamHandler {
+	return func(ctx context.Context, req *simpleai.Request) (<-chan simpleai.StreamEvent, error) {
+		stream, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		out := make(chan simpleai.StreamEvent)
+		go func() {
+			defer close(out)
+			var content strings.Builder
+			for event := range stream {
+				content.WriteString(event.Content)
+				if event.Done && event.Error == nil {
+					r := m.record(ctx, req)
+					r.PromptTokens = promptTokens(req)
+					r.CompletionTokens = contextbuilder.EstimateTokens(content.String())
+					r.Estimated = true
+					m.add(ctx, r)
+				}
+				out <- event
+			}
+		}()
+		return out, nil
+	}
+}
+
+// record starts a record attributed from the request metadata
+func (m *middleware) record(ctx context.Context, req *simpleai.Request) Record {
+	md, _ := simpleai.RequestMetadataFromContext(ctx)
+	provider := simpleai.ProviderName(ctx)
+	if provider == "" {
+		provider = m.ledger.config.Provider
+	}
+	return Record{
+		Time:      time.Now(),
+		UserID:    md.UserID,
+		SessionID: md.SessionID,
+		Provider:  provider,
+		Model:     req.Model,
+		Tags:      md.Tags,
+	}
+}
+
+func (m *middleware) add(ctx context.Context, r Record) {
+	// Record even if the caller's context was canceled right after the reply
+	if err := m.ledger.Record(context.WithoutCancel(ctx), r); err != nil {
+		m.ledger.config.OnError(err)
+	}
+}
+
+// promptTokens estimates the prompt size of a request
+func promptTokens(req *simpleai.Request) int {
+	tokens := contextbuilder.EstimateTokens(req.SystemPrompt)
+	for _, msg := range req.Messages {
+		tokens += contextbuilder.EstimateTokens(msg.Content)
+	}
+	return tokens
+}
@@ -0,0 +1,195 @@
+package simpleai
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// VerifyConfig configures chain-of-verification
+type VerifyConfig struct {
+	// MaxQuestions caps the verification questions asked about the draft
+	// (default 5)
+	MaxQuestions int
+
+	// Concurrency is how many questions are answered at once (default: all)
+	Concurrency int
+
+	// Model plans and answers the verification questions (default: the
+	// request's model). The draft and the final answer always use the
+	// request's model.
+	Model string
+}
+
+// DefaultVerifyConfig returns the default verification settings
+func DefaultVerifyConfig() VerifyConfig {
+	return VerifyConfig{MaxQuestions: 5}
+}
+
+// VerifyCheck is a verification question and its independent answer
+type VerifyCheck struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+// VerifyResult is the outcome of CompleteVerified
+type VerifyResult struct {
+	Response *Response     // The revised answer
+	Draft    *Response     // The answer before verification
+	Checks   []VerifyCheck // Questions whose answers informed the revision
+	Usage    Usage         // Summed over every call
+}
+
+const verifyPlanPrompt = `List up to %d short questions that would check the facts in the draft answer below: names, dates, numbers and other claims that could be wrong. Each question must make sense on its own, without the draft.
+
+Question: %s
+
+Draft answer:
+%s`
+
+const verifyAnswerPrompt = "Answer the question concisely and factually. If you are not sure, say so."
+
+const verifyRevisePrompt = `Verification questions about your answer, each answered independently:
+
+%s
+
+Revise your answer: correct anything these answers contradict, drop claims they cast doubt on and keep the rest. Reply with only the final answer.`
+
+// CompleteVerified answers req with chain-of-verification: it drafts an
+// answer, asks the model for questions that check the draft's facts,
+// answers each one on its own so the draft cannot bias it, then revises
+// the draft against those answers. It costs about three calls plus one
+// per question and reduces hallucinated facts in long answers. Questions
+// that fail to get an answer are left out; with an idempotency key, each
+// call gets its own key derived from it.
+func (c *Client) CompleteVerified(ctx context.Context, req *Request, config VerifyConfig, opts ...RequestOption) (*VerifyResult, error) {
+	if config.MaxQuestions <= 0 {
+		config.MaxQuestions = DefaultVerifyConfig().MaxQuestions
+	}
+	ctx, req = applyRequestOptions(ctx, req, opts)
+
+	draft, err := c.Complete(subIdempotencyKey(ctx, "draft"), req)
+	if err != nil {
+		return nil, err
+	}
+	result := &VerifyResult{Response: draft, Draft: draft}
+	result.addUsage(draft.Usage)
+
+	var verifyOpts []RequestOption
+	if config.Model != "" {
+		verifyOpts = append(verifyOpts, WithModel(config.Model))
+	}
+
+	// Plan the questions
+	var plan struct {
+		Questions []string `json:"questions"`
+	}
+	planResp, err := c.Complete(subIdempotencyKey(ctx, "plan"), &Request{
+		Messages: []Message{{
+			Role:    RoleUser,
+			Content: fmt.Sprintf(verifyPlanPrompt, config.MaxQuestions, lastUserContent(req.Messages), draft.Content),
+		}},
+		SystemPrompt: JSONPrompt(&plan),
+		Model:        req.Model,
+	}, verifyOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("simpleai: planning verification: %w", err)
+	}
+	result.addUsage(planResp.Usage)
+	var questions []string
+	if decodeJSONReply(planResp.Content, &plan) == nil {
+		questions = plan.Questions
+	} else {
+		questions = questionLines(planResp.Content)
+	}
+	if len(questions) > config.MaxQuestions {
+		questions = questions[:config.MaxQuestions]
+	}
+	if len(questions) == 0 {
+		return result, nil
+	}
+
+	// Answer each question without the draft in context
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = len(questions)
+	}
+	answers := make([]*Response, len(questions))
+	errs := make([]error, len(questions))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, question := range questions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			answers[i], errs[i] = c.Complete(subIdempotencyKey(ctx, "check/"+strconv.Itoa(i)), &Request{
+				Messages:     []Message{{Role: RoleUser, Content: question}},
+				SystemPrompt: verifyAnswerPrompt,
+				Model:        req.Model,
+			}, verifyOpts...)
+		}()
+	}
+	wg.Wait()
+
+	var checks strings.Builder
+	for i, question := range questions {
+		if errs[i] != nil {
+			continue
+		}
+		result.addUsage(answers[i].Usage)
+		check := VerifyCheck{Question: question, Answer: strings.TrimSpace(answers[i].Content)}
+		result.Checks = append(result.Checks, check)
+		fmt.Fprintf(&checks, "Q: %s\nA: %s\n\n", check.Question, check.Answer)
+	}
+	if len(result.Checks) == 0 {
+		return nil, fmt.Errorf("simpleai: answering verification questions: %w", errs[0])
+	}
+
+	// Revise the draft in the original conversation
+	revise := *req
+	revise.Messages = append(append([]Message(nil), req.Messages...),
+		Message{Role: RoleAssistant, Content: draft.Content},
+		Message{Role: RoleUser, Content: fmt.Sprintf(verifyRevisePrompt, strings.TrimSpace(checks.String()))},
+	)
+	final, err := c.Complete(subIdempotencyKey(ctx, "revise"), &revise)
+	if err != nil {
+		return nil, fmt.Errorf("simpleai: revising answer: %w", err)
+	}
+	result.addUsage(final.Usage)
+	result.Response = final
+	return result, nil
+}
+
+// addUsage adds a call's tokens to the result's usage
+func (r *VerifyResult) addUsage(u Usage) {
+	r.Usage.PromptTokens += u.PromptTokens
+	r.Usage.CompletionTokens += u.CompletionTokens
+	r.Usage.TotalTokens += u.TotalTokens
+}
+
+// lastUserContent returns the content of the last user message
+func lastUserContent(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleUser {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// questionLines reads questions from a plain-text list, for replies that
+// are not the requested JSON
+func questionLines(text string) []string {
+	var questions []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*0123456789.)"))
+		if strings.HasSuffix(line, "?") {
+			questions = append(questions, line)
+		}
+	}
+	return questions
+}